@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/raskell-io/sentinel-hub/internal/audit"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/spf13/cobra"
+)
+
+func auditCmd() *cobra.Command {
+	var dbURL string
+
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Inspect the audit log",
+	}
+	cmd.PersistentFlags().StringVar(&dbURL, "database-url", "sqlite://hub.db", "Database connection URL")
+
+	var asJSON bool
+	verifyCmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the audit log hash chain and checkpoints",
+		Long: `Verify the audit log hash chain and report the first broken link.
+
+Checkpoint signatures are checked when HUB_AUDIT_CHECKPOINT_KEY is set.
+Exits with an error if the chain does not verify.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, _, err := auditCheckpointConfigFromEnv()
+			if err != nil {
+				return err
+			}
+
+			db, err := store.New(dbURL)
+			if err != nil {
+				return fmt.Errorf("failed to initialize database: %w", err)
+			}
+			defer db.Close()
+
+			result, err := audit.Verify(context.Background(), db, key)
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(result); err != nil {
+					return err
+				}
+			} else {
+				printVerifyResult(result)
+			}
+
+			if !result.OK {
+				return errors.New("audit log verification failed")
+			}
+			return nil
+		},
+	}
+	verifyCmd.Flags().BoolVar(&asJSON, "json", false, "Print the result as JSON")
+
+	cmd.AddCommand(verifyCmd)
+	return cmd
+}
+
+// printVerifyResult describes a verification result for `hub audit verify`.
+func printVerifyResult(r *audit.Result) {
+	if r.Break != nil {
+		fmt.Printf("Broken link at sequence %d: %s\n", r.Break.Sequence, r.Break.Reason)
+		if r.Break.EntryID != "" {
+			fmt.Printf("  Entry:      %s\n", r.Break.EntryID)
+		}
+		if r.Break.CheckpointID != "" {
+			fmt.Printf("  Checkpoint: %s\n", r.Break.CheckpointID)
+		}
+		fmt.Printf("%d entries verified before the break\n", r.Entries)
+		return
+	}
+
+	if r.Entries == 0 {
+		fmt.Println("No chained audit log entries")
+	} else {
+		fmt.Printf("Verified %d entries (sequence %d to %d)\n", r.Entries, r.FirstSequence, r.LastSequence)
+	}
+	if r.SignaturesVerified {
+		fmt.Printf("Matched %d signed checkpoints\n", r.Checkpoints)
+	} else {
+		fmt.Printf("Matched %d checkpoints (signatures not checked; HUB_AUDIT_CHECKPOINT_KEY is not set)\n", r.Checkpoints)
+	}
+	if r.LegacyEntries > 0 {
+		fmt.Printf("%d entries predate the hash chain and were not verified\n", r.LegacyEntries)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/spf13/cobra"
+)
+
+func backupCmd() *cobra.Command {
+	var dbURL, out string
+
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Write a consistent copy of a SQLite database, even while the hub is running",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := store.Backup(context.Background(), dbURL, out); err != nil {
+				return err
+			}
+			fmt.Printf("Backup written to %s\n", out)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dbURL, "database-url", "sqlite://hub.db", "Database connection URL")
+	cmd.Flags().StringVar(&out, "out", "", "Backup file to create")
+	cmd.MarkFlagRequired("out")
+
+	return cmd
+}
+
+func restoreCmd() *cobra.Command {
+	var dbURL, in string
+
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Replace a SQLite database with a backup; stop the hub first",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := store.Restore(context.Background(), in, dbURL); err != nil {
+				return err
+			}
+			fmt.Printf("Restored %s\n", in)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dbURL, "database-url", "sqlite://hub.db", "Database connection URL")
+	cmd.Flags().StringVar(&in, "in", "", "Backup file to restore")
+	cmd.MarkFlagRequired("in")
+
+	return cmd
+}
+
+func exportCmd() *cobra.Command {
+	var dbURL, out string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export configs, instances, users and deployment history as JSON",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := store.New(dbURL)
+			if err != nil {
+				return fmt.Errorf("failed to initialize database: %w", err)
+			}
+			defer db.Close()
+
+			snap, err := db.ExportSnapshot(context.Background())
+			if err != nil {
+				return err
+			}
+
+			w := io.Writer(os.Stdout)
+			if out != "-" {
+				f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+				if err != nil {
+					return fmt.Errorf("failed to create export file: %w", err)
+				}
+				defer f.Close()
+				w = f
+			}
+
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(snap); err != nil {
+				return fmt.Errorf("failed to write export: %w", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dbURL, "database-url", "sqlite://hub.db", "Database connection URL")
+	cmd.Flags().StringVar(&out, "out", "-", "Export file to create (- for stdout)")
+
+	return cmd
+}
+
+func importCmd() *cobra.Command {
+	var dbURL, in string
+
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import a JSON export into a database without configs, instances or deployments",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			r := io.Reader(os.Stdin)
+			if in != "-" {
+				f, err := os.Open(in)
+				if err != nil {
+					return fmt.Errorf("failed to open export file: %w", err)
+				}
+				defer f.Close()
+				r = f
+			}
+
+			var snap store.Snapshot
+			if err := json.NewDecoder(r).Decode(&snap); err != nil {
+				return fmt.Errorf("failed to read export: %w", err)
+			}
+
+			db, err := store.New(dbURL)
+			if err != nil {
+				return fmt.Errorf("failed to initialize database: %w", err)
+			}
+			defer db.Close()
+
+			result, err := db.ImportSnapshot(context.Background(), &snap)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Imported %d users (%d already existed), %d configs with %d versions, %d instances, %d deployments\n",
+				result.Users, result.SkippedUsers, result.Configs, result.ConfigVersions, result.Instances, result.Deployments)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dbURL, "database-url", "sqlite://hub.db", "Database connection URL")
+	cmd.Flags().StringVar(&in, "in", "-", "Export file to import (- for stdin)")
+
+	return cmd
+}
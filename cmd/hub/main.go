@@ -6,16 +6,28 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/raskell-io/sentinel-hub/internal/api"
+	"github.com/raskell-io/sentinel-hub/internal/audit"
 	"github.com/raskell-io/sentinel-hub/internal/auth"
+	"github.com/raskell-io/sentinel-hub/internal/compose"
+	"github.com/raskell-io/sentinel-hub/internal/events"
 	"github.com/raskell-io/sentinel-hub/internal/fleet"
+	"github.com/raskell-io/sentinel-hub/internal/gitops"
 	hubgrpc "github.com/raskell-io/sentinel-hub/internal/grpc"
+	"github.com/raskell-io/sentinel-hub/internal/notify"
+	"github.com/raskell-io/sentinel-hub/internal/retention"
+	"github.com/raskell-io/sentinel-hub/internal/secrets"
 	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/raskell-io/sentinel-hub/internal/webhook"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -41,6 +53,12 @@ func main() {
 	}
 
 	rootCmd.AddCommand(serveCmd())
+	rootCmd.AddCommand(migrateCmd())
+	rootCmd.AddCommand(backupCmd())
+	rootCmd.AddCommand(restoreCmd())
+	rootCmd.AddCommand(exportCmd())
+	rootCmd.AddCommand(importCmd())
+	rootCmd.AddCommand(auditCmd())
 	rootCmd.AddCommand(versionCmd())
 
 	if err := rootCmd.Execute(); err != nil {
@@ -98,27 +116,130 @@ func runServer(httpPort, grpcPort int, dbURL string) error {
 
 	log.Info().Msg("Database initialized successfully")
 
-	// Create auth service
-	jwtSecret := os.Getenv("HUB_JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "dev-secret-change-in-production" // Default for development
-		log.Warn().Msg("HUB_JWT_SECRET not set, using insecure default (do not use in production)")
-	}
-
+	// Create auth service. Tokens are signed with the key ring; HUB_JWT_SECRET
+	// only keeps HS256 tokens issued by earlier versions valid until they expire.
 	authConfig := auth.DefaultConfig()
-	authConfig.JWTSecret = jwtSecret
+	authConfig.JWTSecret = os.Getenv("HUB_JWT_SECRET")
+	oidcConfig, err := oidcConfigFromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid OIDC configuration: %w", err)
+	}
+	authConfig.OIDC = oidcConfig
+	if authConfig.Lockout, err = lockoutConfigFromEnv(authConfig.Lockout); err != nil {
+		return fmt.Errorf("invalid login lockout configuration: %w", err)
+	}
+	// Forwarded client addresses are only believed from these proxies
+	trustedProxies, err := auth.ParseTrustedProxies(os.Getenv("HUB_TRUSTED_PROXIES"))
+	if err != nil {
+		return fmt.Errorf("invalid HUB_TRUSTED_PROXIES: %w", err)
+	}
+	authConfig.PasswordResetURL = os.Getenv("HUB_PASSWORD_RESET_URL")
+	if issuer := os.Getenv("HUB_MFA_ISSUER"); issuer != "" {
+		authConfig.MFAIssuer = issuer
+	}
+	// HUB_MFA_REQUIRED_ROLES is a comma-separated list of roles, e.g. "admin,operator"
+	if roles := os.Getenv("HUB_MFA_REQUIRED_ROLES"); roles != "" {
+		for _, role := range strings.Split(roles, ",") {
+			role := store.UserRole(strings.TrimSpace(role))
+			if !validRole(role) {
+				return fmt.Errorf("invalid role %q in HUB_MFA_REQUIRED_ROLES", role)
+			}
+			authConfig.MFARequiredRoles = append(authConfig.MFARequiredRoles, role)
+		}
+	}
 	authService := auth.NewService(db, authConfig)
 
+	// Audit logs go to the database and any configured external sinks
+	auditRecorder, err := auditRecorderFromEnv(db)
+	if err != nil {
+		return fmt.Errorf("invalid audit sink configuration: %w", err)
+	}
+	authService.SetAuditRecorder(auditRecorder)
+
+	keyRing, err := keyRingFromEnv(db, authConfig.RefreshTokenExpiry)
+	if err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
+	authService.SetKeyRing(keyRing)
+	keysCtx, stopKeyRotation := context.WithCancel(context.Background())
+	defer stopKeyRotation()
+	go runKeyRotation(keysCtx, keyRing)
+	notifier, err := notifierFromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid notifier configuration: %w", err)
+	}
+	if notifier != nil {
+		authService.SetNotifier(notifier)
+	}
+	if authService.OIDCEnabled() {
+		log.Info().Str("issuer", oidcConfig.IssuerURL).Msg("OIDC single sign-on enabled")
+	}
+
 	// Seed initial admin user if configured
 	if err := seedAdminUser(db, authService); err != nil {
 		log.Warn().Err(err).Msg("Failed to seed admin user")
 	}
 
+	// Delete expired and old data in the background
+	retentionConfig, err := retentionConfigFromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid retention configuration: %w", err)
+	}
+	retentionCtx, stopRetention := context.WithCancel(context.Background())
+	defer stopRetention()
+	go retention.NewSweeper(db, retentionConfig).Run(retentionCtx)
+
+	// Sign checkpoints of the audit hash chain if a key is configured
+	auditKey, checkpointInterval, err := auditCheckpointConfigFromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid audit checkpoint configuration: %w", err)
+	}
+	checkpointCtx, stopCheckpoints := context.WithCancel(context.Background())
+	defer stopCheckpoints()
+	if auditKey != nil {
+		go audit.NewCheckpointer(db, auditKey, checkpointInterval).Run(checkpointCtx)
+	}
+
+	// Deliver deployment and fleet events to webhooks and live event streams
+	webhookConfig, err := webhookConfigFromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid webhook configuration: %w", err)
+	}
+	eventLogSize, err := eventLogSizeFromEnv()
+	if err != nil {
+		return err
+	}
+	webhookDispatcher := webhook.NewDispatcher(db, webhookConfig)
+	eventLog := events.NewLog(eventLogSize)
+	eventBus := events.NewBus()
+	eventBus.Subscribe(webhookDispatcher.Handle)
+	eventBus.Subscribe(eventLog.Handle)
+	webhookCtx, stopWebhooks := context.WithCancel(context.Background())
+	defer stopWebhooks()
+	go webhookDispatcher.Run(webhookCtx)
+
+	// Encrypt secrets with the local master key
+	secretKeeper, err := secretKeeperFromEnv(db)
+	if err != nil {
+		return err
+	}
+
+	// Keep layered configs up to date as their layers change
+	configResolver := compose.NewResolver(db)
+	configResolver.SetEventBus(eventBus)
+
 	// Create gRPC server
 	grpcServer := hubgrpc.NewServer(db, grpcPort)
+	grpcServer.FleetService().SetAuditRecorder(auditRecorder)
+	grpcServer.FleetService().SetEventBus(eventBus)
+	grpcServer.FleetService().SetSecretKeeper(secretKeeper)
+	grpcServer.FleetService().SetConfigResolver(configResolver)
+	prometheus.MustRegister(hubgrpc.NewDriftedInstancesGauge(db))
 
 	// Create deployment orchestrator
 	orchestrator := fleet.NewOrchestrator(db, grpcServer.FleetService())
+	orchestrator.SetAuditRecorder(auditRecorder)
+	orchestrator.SetEventBus(eventBus)
 	if err := orchestrator.Start(); err != nil {
 		return fmt.Errorf("failed to start orchestrator: %w", err)
 	}
@@ -126,6 +247,23 @@ func runServer(httpPort, grpcPort int, dbURL string) error {
 	// Wire up status reporting from agents to orchestrator
 	grpcServer.FleetService().SetDeploymentStatusHandler(orchestrator.ReportInstanceStatus)
 
+	// Sync configs from a Git repository or directory if one is configured
+	gitopsConfig, err := gitopsConfigFromEnv()
+	if err != nil {
+		return fmt.Errorf("invalid GitOps configuration: %w", err)
+	}
+	gitopsCtx, stopGitOps := context.WithCancel(context.Background())
+	defer stopGitOps()
+	var gitopsSyncer *gitops.Syncer
+	if gitopsConfig.Path != "" {
+		gitopsSyncer = gitops.NewSyncer(db, gitopsConfig)
+		gitopsSyncer.SetConfigResolver(configResolver)
+		gitopsSyncer.SetAuditRecorder(auditRecorder)
+		gitopsSyncer.SetDeployer(orchestrator)
+		go gitopsSyncer.Run(gitopsCtx)
+		log.Info().Str("path", gitopsConfig.Path).Msg("GitOps sync enabled")
+	}
+
 	// Start gRPC server in background
 	go func() {
 		if err := grpcServer.Start(); err != nil {
@@ -135,18 +273,28 @@ func runServer(httpPort, grpcPort int, dbURL string) error {
 
 	// Create API handlers
 	handler := api.NewHandler(db, orchestrator)
+	handler.SetAuditRecorder(auditRecorder)
+	handler.SetEventLog(eventLog)
+	handler.SetConfigResolver(configResolver)
+	handler.SetSecretKeeper(secretKeeper)
+	if gitopsSyncer != nil {
+		handler.SetGitOpsSyncer(gitopsSyncer)
+	}
 	authHandler := api.NewAuthHandler(authService)
 	userHandler := api.NewUserHandler(db, authService)
+	userHandler.SetAuditRecorder(auditRecorder)
+	userHandler.SetAuditCheckpointKey(auditKey)
+	userHandler.SetWebhookDispatcher(webhookDispatcher)
 
 	// Setup router
 	r := chi.NewRouter()
 
 	// Middleware
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(auth.RealIP(trustedProxies))
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(timeoutExceptStreams(60 * time.Second))
 
 	// CORS middleware for development
 	r.Use(func(next http.Handler) http.Handler {
@@ -173,7 +321,7 @@ func runServer(httpPort, grpcPort int, dbURL string) error {
 
 	r.Get("/ready", func(w http.ResponseWriter, r *http.Request) {
 		// Check database connection
-		if err := db.DB().Ping(); err != nil {
+		if err := db.Ping(r.Context()); err != nil {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusServiceUnavailable)
 			w.Write([]byte(`{"status":"not ready","error":"database unavailable"}`))
@@ -191,11 +339,25 @@ func runServer(httpPort, grpcPort int, dbURL string) error {
 		fmt.Fprintf(w, `{"connected_agents":%d}`, grpcServer.FleetService().GetSubscriberCount())
 	})
 
+	// Prometheus metrics
+	r.Handle("/metrics", promhttp.Handler())
+
+	// Public keys for verifying hub-issued tokens
+	r.Get("/.well-known/jwks.json", authHandler.JWKS)
+
 	// API routes
 	r.Route("/api/v1", func(r chi.Router) {
 		// Public auth routes (no authentication required)
 		r.Post("/auth/login", authHandler.Login)
 		r.Post("/auth/refresh", authHandler.Refresh)
+		r.Post("/auth/reset-password", authHandler.ResetPassword)
+
+		// MFA endpoints accept an access token or, for users who must enroll
+		// before logging in, the mfa_token returned by login.
+		r.Post("/auth/mfa/enroll", authHandler.MFAEnroll)
+		r.Post("/auth/mfa/verify", authHandler.MFAVerify)
+		r.Get("/auth/oidc/login", authHandler.OIDCLogin)
+		r.Get("/auth/oidc/callback", authHandler.OIDCCallback)
 
 		// Authenticated routes
 		r.Group(func(r chi.Router) {
@@ -204,54 +366,149 @@ func runServer(httpPort, grpcPort int, dbURL string) error {
 			// Auth endpoints (authenticated)
 			r.Post("/auth/logout", authHandler.Logout)
 			r.Get("/auth/me", authHandler.GetCurrentUser)
-
-			// Read-only routes (all authenticated users: viewer, operator, admin)
-			r.Get("/instances", handler.ListInstances)
-			r.Get("/instances/{id}", handler.GetInstance)
-			r.Get("/configs", handler.ListConfigs)
-			r.Get("/configs/{id}", handler.GetConfig)
-			r.Get("/configs/{id}/versions", handler.ListConfigVersions)
-			r.Get("/deployments", handler.ListDeployments)
-			r.Get("/deployments/{id}", handler.GetDeployment)
-
-			// Operator+ routes (create/update resources)
+			r.Post("/auth/oidc/link", authHandler.OIDCLink)
+
+			// Resource routes are gated by permission; handlers then check the
+			// specific instance or config against label and name selectors.
+			perm := authService.RequirePermission
+
+			// Instances
+			r.With(perm(auth.ScopeInstancesRead)).Get("/instances", handler.ListInstances)
+			r.With(perm(auth.ScopeInstancesRead)).Get("/instances/{id}", handler.GetInstance)
+			r.With(perm(auth.ScopeInstancesWrite)).Post("/instances", handler.CreateInstance)
+			r.With(perm(auth.ScopeInstancesWrite)).Put("/instances/{id}", handler.UpdateInstance)
+			r.With(perm(auth.ScopeInstancesDelete)).Delete("/instances/{id}", handler.DeleteInstance)
+			r.With(perm(auth.ScopeInstancesRead)).Get("/instances/{id}/assignment", handler.GetInstanceAssignment)
+			r.With(perm(auth.ScopeInstancesRead)).Get("/instances/{id}/variables", handler.ListInstanceVariables)
+			r.With(perm(auth.ScopeInstancesWrite)).Put("/instances/{id}/variables/{name}", handler.SetInstanceVariable)
+			r.With(perm(auth.ScopeInstancesWrite)).Delete("/instances/{id}/variables/{name}", handler.DeleteInstanceVariable)
+
+			// Fleet-wide template variables
+			r.With(perm(auth.ScopeInstancesRead)).Get("/variables", handler.ListVariables)
+			r.With(perm(auth.ScopeInstancesWrite)).Put("/variables/{name}", handler.SetVariable)
+			r.With(perm(auth.ScopeInstancesWrite)).Delete("/variables/{name}", handler.DeleteVariable)
+
+			// Configs
+			r.With(perm(auth.ScopeConfigsRead)).Get("/configs", handler.ListConfigs)
+			r.With(perm(auth.ScopeConfigsRead)).Get("/configs/{id}", handler.GetConfig)
+			r.With(perm(auth.ScopeConfigsRead)).Get("/configs/{id}/versions", handler.ListConfigVersions)
+			r.With(perm(auth.ScopeConfigsRead)).Get("/configs/{id}/diff", handler.DiffConfigVersions)
+			r.With(perm(auth.ScopeConfigsRead)).Get("/configs/{id}/render", handler.RenderConfig)
+			r.With(perm(auth.ScopeConfigsRead)).Get("/configs/{id}/dependents", handler.ListConfigDependents)
+			r.With(perm(auth.ScopeConfigsWrite)).Post("/configs", handler.CreateConfig)
+			r.With(perm(auth.ScopeConfigsWrite)).Put("/configs/{id}", handler.UpdateConfig)
+			r.With(perm(auth.ScopeConfigsWrite)).Post("/configs/{id}/rollback", handler.RollbackConfig)
+			r.With(perm(auth.ScopeConfigsWrite)).Put("/configs/{id}/layers", handler.SetConfigLayers)
+			r.With(perm(auth.ScopeDeploymentsCreate)).Post("/configs/{id}/versions/{version}/approve", handler.ApproveConfigVersion)
+			r.With(perm(auth.ScopeConfigsDelete)).Delete("/configs/{id}", handler.DeleteConfig)
+
+			// GitOps sync status
+			r.With(perm(auth.ScopeConfigsRead)).Get("/gitops", handler.GetGitOpsStatus)
+			r.With(perm(auth.ScopeConfigsWrite)).Post("/gitops/sync", handler.SyncGitOps)
+
+			// Secrets (values are write-only)
+			r.With(perm(auth.ScopeSecretsRead)).Get("/secrets", handler.ListSecrets)
+			r.With(perm(auth.ScopeSecretsRead)).Get("/secrets/{name}", handler.GetSecret)
+			r.With(perm(auth.ScopeSecretsWrite)).Put("/secrets/{name}", handler.SetSecret)
+			r.With(perm(auth.ScopeSecretsWrite)).Delete("/secrets/{name}", handler.DeleteSecret)
+
+			// Config assignments (desired state by label selector)
+			r.With(perm(auth.ScopeDeploymentsRead)).Get("/assignments", handler.ListConfigAssignments)
+			r.With(perm(auth.ScopeDeploymentsCreate)).Post("/assignments", handler.CreateConfigAssignment)
+			r.With(perm(auth.ScopeDeploymentsRead)).Get("/assignments/{id}", handler.GetConfigAssignment)
+			r.With(perm(auth.ScopeDeploymentsCreate)).Put("/assignments/{id}", handler.UpdateConfigAssignment)
+			r.With(perm(auth.ScopeDeploymentsCreate)).Delete("/assignments/{id}", handler.DeleteConfigAssignment)
+
+			// Drift policies (remediation of local config changes by label selector)
+			r.With(perm(auth.ScopeDeploymentsRead)).Get("/drift-policies", handler.ListDriftPolicies)
+			r.With(perm(auth.ScopeDeploymentsCreate)).Post("/drift-policies", handler.CreateDriftPolicy)
+			r.With(perm(auth.ScopeDeploymentsRead)).Get("/drift-policies/{id}", handler.GetDriftPolicy)
+			r.With(perm(auth.ScopeDeploymentsCreate)).Put("/drift-policies/{id}", handler.UpdateDriftPolicy)
+			r.With(perm(auth.ScopeDeploymentsCreate)).Delete("/drift-policies/{id}", handler.DeleteDriftPolicy)
+
+			// Deployments
+			r.With(perm(auth.ScopeDeploymentsRead)).Get("/deployments", handler.ListDeployments)
+			r.With(perm(auth.ScopeDeploymentsRead)).Get("/deployments/{id}", handler.GetDeployment)
+			r.With(perm(auth.ScopeDeploymentsCreate)).Post("/deployments", handler.CreateDeployment)
+			r.With(perm(auth.ScopeDeploymentsCancel)).Post("/deployments/{id}/cancel", handler.CancelDeployment)
+
+			// Live event streams (Server-Sent Events)
+			r.With(perm(auth.ScopeDeploymentsRead)).Get("/deployments/{id}/events", handler.StreamDeploymentEvents)
+			r.With(perm(auth.ScopeInstancesRead)).Get("/events", handler.StreamEvents)
+
+			// API token and session management (interactive sessions only, so
+			// tokens cannot mint tokens)
 			r.Group(func(r chi.Router) {
-				r.Use(authService.RequireRole(store.UserRoleAdmin, store.UserRoleOperator))
-
-				// Instance management (operators can create/update)
-				r.Post("/instances", handler.CreateInstance)
-				r.Put("/instances/{id}", handler.UpdateInstance)
+				r.Use(authService.RequireUserSession())
 
-				// Config management (operators can create/update)
-				r.Post("/configs", handler.CreateConfig)
-				r.Put("/configs/{id}", handler.UpdateConfig)
-				r.Post("/configs/{id}/rollback", handler.RollbackConfig)
+				r.Get("/tokens", userHandler.ListAPITokens)
+				r.Post("/tokens", userHandler.CreateAPIToken)
+				r.Delete("/tokens/{id}", userHandler.RevokeAPIToken)
 
-				// Deployments (operators can create/cancel)
-				r.Post("/deployments", handler.CreateDeployment)
-				r.Post("/deployments/{id}/cancel", handler.CancelDeployment)
+				// Login sessions
+				r.Get("/auth/sessions", userHandler.ListSessions)
+				r.Delete("/auth/sessions/{id}", userHandler.RevokeSession)
 			})
 
 			// Admin-only routes
 			r.Group(func(r chi.Router) {
 				r.Use(authService.RequireRole(store.UserRoleAdmin))
 
-				// Delete operations
-				r.Delete("/instances/{id}", handler.DeleteInstance)
-				r.Delete("/configs/{id}", handler.DeleteConfig)
-
 				// User management
 				r.Route("/users", func(r chi.Router) {
-					r.Get("/", userHandler.ListUsers)
-					r.Post("/", userHandler.CreateUser)
-					r.Get("/{id}", userHandler.GetUser)
-					r.Put("/{id}", userHandler.UpdateUser)
-					r.Delete("/{id}", userHandler.DeleteUser)
-					r.Post("/{id}/reset-password", userHandler.ResetPassword)
+					r.With(authService.RequireScope(auth.ScopeUsersRead)).Get("/", userHandler.ListUsers)
+					r.With(authService.RequireScope(auth.ScopeUsersWrite)).Post("/", userHandler.CreateUser)
+					r.With(authService.RequireScope(auth.ScopeUsersRead)).Get("/{id}", userHandler.GetUser)
+					r.With(authService.RequireScope(auth.ScopeUsersWrite)).Put("/{id}", userHandler.UpdateUser)
+					r.With(authService.RequireScope(auth.ScopeUsersWrite)).Delete("/{id}", userHandler.DeleteUser)
+					r.With(authService.RequireScope(auth.ScopeUsersWrite)).Post("/{id}/reset-password", userHandler.ResetPassword)
+					r.With(authService.RequireScope(auth.ScopeUsersWrite)).Post("/{id}/unlock", userHandler.UnlockUser)
+					r.With(authService.RequireScope(auth.ScopeUsersWrite)).Delete("/{id}/mfa", userHandler.ResetMFA)
+					r.With(authService.RequireScope(auth.ScopeUsersRead)).Get("/{id}/sessions", userHandler.ListUserSessions)
+					r.With(authService.RequireScope(auth.ScopeUsersWrite)).Delete("/{id}/sessions", userHandler.RevokeAllUserSessions)
+					r.With(authService.RequireScope(auth.ScopeUsersWrite)).Delete("/{id}/sessions/{sessionID}", userHandler.RevokeUserSession)
+					r.With(authService.RequireScope(auth.ScopeUsersRead)).Get("/{id}/roles", userHandler.ListUserRoles)
+					r.With(authService.RequireScope(auth.ScopeUsersWrite)).Post("/{id}/roles", userHandler.BindRole)
+					r.With(authService.RequireScope(auth.ScopeUsersWrite)).Delete("/{id}/roles/{roleID}", userHandler.UnbindRole)
+				})
+
+				// Custom roles (label- and name-scoped permissions)
+				r.Route("/roles", func(r chi.Router) {
+					r.With(authService.RequireScope(auth.ScopeUsersRead)).Get("/", userHandler.ListRoles)
+					r.With(authService.RequireScope(auth.ScopeUsersWrite)).Post("/", userHandler.CreateRole)
+					r.With(authService.RequireScope(auth.ScopeUsersRead)).Get("/{id}", userHandler.GetRole)
+					r.With(authService.RequireScope(auth.ScopeUsersWrite)).Put("/{id}", userHandler.UpdateRole)
+					r.With(authService.RequireScope(auth.ScopeUsersWrite)).Delete("/{id}", userHandler.DeleteRole)
+				})
+
+				// Service accounts (own API tokens for automation)
+				r.Route("/service-accounts", func(r chi.Router) {
+					r.Use(authService.RequireUserSession())
+					r.Get("/", userHandler.ListServiceAccounts)
+					r.Post("/", userHandler.CreateServiceAccount)
+				})
+
+				// Token signing keys
+				r.Route("/signing-keys", func(r chi.Router) {
+					r.Use(authService.RequireUserSession())
+					r.Get("/", userHandler.ListSigningKeys)
+					r.Post("/rotate", userHandler.RotateSigningKey)
+				})
+
+				// Outgoing webhooks
+				r.Route("/webhooks", func(r chi.Router) {
+					r.With(authService.RequireScope(auth.ScopeWebhooksRead)).Get("/", userHandler.ListWebhooks)
+					r.With(authService.RequireScope(auth.ScopeWebhooksWrite)).Post("/", userHandler.CreateWebhook)
+					r.With(authService.RequireScope(auth.ScopeWebhooksRead)).Get("/{id}", userHandler.GetWebhook)
+					r.With(authService.RequireScope(auth.ScopeWebhooksWrite)).Put("/{id}", userHandler.UpdateWebhook)
+					r.With(authService.RequireScope(auth.ScopeWebhooksWrite)).Delete("/{id}", userHandler.DeleteWebhook)
+					r.With(authService.RequireScope(auth.ScopeWebhooksRead)).Get("/{id}/deliveries", userHandler.ListWebhookDeliveries)
+					r.With(authService.RequireScope(auth.ScopeWebhooksWrite)).Post("/{id}/deliveries/{deliveryID}/redeliver", userHandler.RedeliverWebhookDelivery)
 				})
 
 				// Audit logs
-				r.Get("/audit-logs", userHandler.ListAuditLogs)
+				r.With(authService.RequireScope(auth.ScopeAuditRead)).Get("/audit-logs", userHandler.ListAuditLogs)
+				r.With(authService.RequireScope(auth.ScopeAuditRead)).Get("/audit-logs/verify", userHandler.VerifyAuditLogs)
 			})
 		})
 	})
@@ -274,6 +531,12 @@ func runServer(httpPort, grpcPort int, dbURL string) error {
 		<-quit
 		log.Info().Msg("Shutting down servers...")
 
+		stopKeyRotation()
+		stopRetention()
+		stopGitOps()
+		stopCheckpoints()
+		stopWebhooks()
+
 		// Stop orchestrator first (cancels in-progress deployments)
 		if err := orchestrator.Stop(); err != nil {
 			log.Error().Err(err).Msg("Error stopping orchestrator")
@@ -290,6 +553,11 @@ func runServer(httpPort, grpcPort int, dbURL string) error {
 		if err := srv.Shutdown(ctx); err != nil {
 			log.Fatal().Err(err).Msg("Could not gracefully shutdown HTTP server")
 		}
+
+		// Flush audit logs still queued for the sinks
+		if err := auditRecorder.Close(ctx); err != nil {
+			log.Error().Err(err).Msg("Error closing audit sinks")
+		}
 		close(done)
 	}()
 
@@ -304,7 +572,7 @@ func runServer(httpPort, grpcPort int, dbURL string) error {
 }
 
 // seedAdminUser creates an initial admin user if HUB_ADMIN_EMAIL and HUB_ADMIN_PASSWORD are set.
-func seedAdminUser(db *store.Store, authService *auth.Service) error {
+func seedAdminUser(db store.Store, authService *auth.Service) error {
 	adminEmail := os.Getenv("HUB_ADMIN_EMAIL")
 	adminPassword := os.Getenv("HUB_ADMIN_PASSWORD")
 
@@ -340,3 +608,411 @@ func seedAdminUser(db *store.Store, authService *auth.Service) error {
 
 	return nil
 }
+
+// oidcConfigFromEnv builds the OIDC configuration from HUB_OIDC_* environment variables.
+// OIDC stays disabled unless HUB_OIDC_ISSUER and HUB_OIDC_CLIENT_ID are set.
+func oidcConfigFromEnv() (auth.OIDCConfig, error) {
+	cfg := auth.OIDCConfig{
+		IssuerURL:            os.Getenv("HUB_OIDC_ISSUER"),
+		ClientID:             os.Getenv("HUB_OIDC_CLIENT_ID"),
+		ClientSecret:         os.Getenv("HUB_OIDC_CLIENT_SECRET"),
+		RedirectURL:          os.Getenv("HUB_OIDC_REDIRECT_URL"),
+		GroupsClaim:          os.Getenv("HUB_OIDC_GROUPS_CLAIM"),
+		PostLoginRedirectURL: os.Getenv("HUB_OIDC_POST_LOGIN_REDIRECT"),
+		DefaultRole:          store.UserRole(os.Getenv("HUB_OIDC_DEFAULT_ROLE")),
+	}
+	if !cfg.Enabled() {
+		return cfg, nil
+	}
+	if cfg.RedirectURL == "" {
+		return cfg, fmt.Errorf("HUB_OIDC_REDIRECT_URL is required when OIDC is enabled")
+	}
+
+	if scopes := os.Getenv("HUB_OIDC_SCOPES"); scopes != "" {
+		cfg.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+	}
+
+	// HUB_OIDC_ROLE_MAPPING is a comma-separated list of group=role pairs,
+	// e.g. "hub-admins=admin,sre=operator".
+	if mapping := os.Getenv("HUB_OIDC_ROLE_MAPPING"); mapping != "" {
+		cfg.RoleMapping = make(map[string]store.UserRole)
+		for _, pair := range strings.Split(mapping, ",") {
+			group, role, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok || group == "" {
+				return cfg, fmt.Errorf("invalid HUB_OIDC_ROLE_MAPPING entry %q", pair)
+			}
+			if !validRole(store.UserRole(role)) {
+				return cfg, fmt.Errorf("invalid role %q in HUB_OIDC_ROLE_MAPPING", role)
+			}
+			cfg.RoleMapping[group] = store.UserRole(role)
+		}
+	}
+
+	if cfg.DefaultRole != "" && !validRole(cfg.DefaultRole) {
+		return cfg, fmt.Errorf("invalid HUB_OIDC_DEFAULT_ROLE %q", cfg.DefaultRole)
+	}
+
+	return cfg, nil
+}
+
+// lockoutConfigFromEnv overrides login lockout settings from HUB_LOGIN_*
+// environment variables. A max of -1 disables that counter.
+func lockoutConfigFromEnv(cfg auth.LockoutConfig) (auth.LockoutConfig, error) {
+	ints := []struct {
+		env string
+		dst *int
+	}{
+		{"HUB_LOGIN_MAX_FAILURES", &cfg.MaxAccountFailures},
+		{"HUB_LOGIN_MAX_IP_FAILURES", &cfg.MaxIPFailures},
+	}
+	for _, v := range ints {
+		if raw := os.Getenv(v.env); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n == 0 {
+				return cfg, fmt.Errorf("invalid %s %q", v.env, raw)
+			}
+			*v.dst = n
+		}
+	}
+
+	durations := []struct {
+		env string
+		dst *time.Duration
+	}{
+		{"HUB_LOGIN_LOCKOUT", &cfg.BaseLockout},
+		{"HUB_LOGIN_MAX_LOCKOUT", &cfg.MaxLockout},
+		{"HUB_LOGIN_FAILURE_WINDOW", &cfg.FailureWindow},
+	}
+	for _, v := range durations {
+		if raw := os.Getenv(v.env); raw != "" {
+			d, err := time.ParseDuration(raw)
+			if err != nil || d <= 0 {
+				return cfg, fmt.Errorf("invalid %s %q", v.env, raw)
+			}
+			*v.dst = d
+		}
+	}
+
+	return cfg, nil
+}
+
+// keyRingFromEnv loads the token signing keys from the database or, with
+// HUB_JWT_KEY_DIR set, from PEM files in that directory.
+func keyRingFromEnv(db store.Store, refreshTokenExpiry time.Duration) (*auth.KeyRing, error) {
+	cfg := auth.KeyRingConfig{
+		Algorithm: os.Getenv("HUB_JWT_ALGORITHM"),
+		Overlap:   refreshTokenExpiry,
+	}
+
+	durations := []struct {
+		env string
+		dst *time.Duration
+	}{
+		{"HUB_JWT_KEY_ROTATION", &cfg.RotationInterval},
+		{"HUB_JWT_KEY_OVERLAP", &cfg.Overlap},
+	}
+	for _, v := range durations {
+		if raw := os.Getenv(v.env); raw != "" {
+			d, err := time.ParseDuration(raw)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("invalid %s %q", v.env, raw)
+			}
+			*v.dst = d
+		}
+	}
+	if cfg.Overlap < refreshTokenExpiry {
+		log.Warn().
+			Dur("overlap", cfg.Overlap).
+			Dur("refresh_token_expiry", refreshTokenExpiry).
+			Msg("HUB_JWT_KEY_OVERLAP is shorter than the refresh token lifetime; rotation will log users out")
+	}
+
+	var source auth.KeySource = db
+	if dir := os.Getenv("HUB_JWT_KEY_DIR"); dir != "" {
+		fileSource, err := auth.NewFileKeySource(dir)
+		if err != nil {
+			return nil, err
+		}
+		source = fileSource
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return auth.NewKeyRing(ctx, source, cfg)
+}
+
+// runKeyRotation periodically reloads the signing keys, picking up
+// rotations by other hub instances, and rotates the active key when due.
+func runKeyRotation(ctx context.Context, keyRing *auth.KeyRing) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		if _, err := keyRing.RotateIfDue(ctx); err != nil {
+			log.Error().Err(err).Msg("Failed to check signing key rotation")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// retentionConfigFromEnv reads HUB_RETENTION_* environment variables.
+// Retention periods default to keeping data forever.
+func retentionConfigFromEnv() (retention.Config, error) {
+	cfg := retention.DefaultConfig()
+	cfg.AuditArchiveDir = os.Getenv("HUB_RETENTION_AUDIT_ARCHIVE_DIR")
+
+	durations := []struct {
+		env string
+		dst *time.Duration
+	}{
+		{"HUB_RETENTION_INTERVAL", &cfg.Interval},
+		{"HUB_RETENTION_DEPLOYMENTS", &cfg.Deployments},
+		{"HUB_RETENTION_AUDIT_LOGS", &cfg.AuditLogs},
+		{"HUB_RETENTION_CONFIG_VERSIONS", &cfg.ConfigVersions},
+	}
+	for _, v := range durations {
+		if raw := os.Getenv(v.env); raw != "" {
+			d, err := time.ParseDuration(raw)
+			if err != nil || d < 0 {
+				return cfg, fmt.Errorf("invalid %s %q", v.env, raw)
+			}
+			*v.dst = d
+		}
+	}
+
+	if raw := os.Getenv("HUB_RETENTION_CONFIG_VERSIONS_KEEP"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid HUB_RETENTION_CONFIG_VERSIONS_KEEP %q", raw)
+		}
+		cfg.ConfigVersionsKeep = n
+	}
+
+	return cfg, nil
+}
+
+// gitopsConfigFromEnv reads HUB_GITOPS_* environment variables. Sync is
+// disabled unless HUB_GITOPS_PATH is set.
+func gitopsConfigFromEnv() (gitops.Config, error) {
+	cfg := gitops.DefaultConfig()
+	cfg.Path = os.Getenv("HUB_GITOPS_PATH")
+	cfg.Branch = os.Getenv("HUB_GITOPS_BRANCH")
+	cfg.Dir = os.Getenv("HUB_GITOPS_DIR")
+
+	if raw := os.Getenv("HUB_GITOPS_INTERVAL"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("invalid HUB_GITOPS_INTERVAL %q", raw)
+		}
+		cfg.Interval = d
+	}
+
+	switch strategy := store.DeploymentStrategy(os.Getenv("HUB_GITOPS_DEPLOY_STRATEGY")); strategy {
+	case "", store.DeploymentStrategyAllAtOnce, store.DeploymentStrategyRolling, store.DeploymentStrategyCanary:
+		cfg.DeployStrategy = strategy
+	default:
+		return cfg, fmt.Errorf("invalid HUB_GITOPS_DEPLOY_STRATEGY %q", strategy)
+	}
+	if raw := os.Getenv("HUB_GITOPS_DEPLOY_BATCH_SIZE"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("invalid HUB_GITOPS_DEPLOY_BATCH_SIZE %q", raw)
+		}
+		cfg.DeployBatchSize = n
+	}
+
+	if cfg.Path != "" {
+		if info, err := os.Stat(cfg.Path); err != nil || !info.IsDir() {
+			return cfg, fmt.Errorf("HUB_GITOPS_PATH %q is not a directory", cfg.Path)
+		}
+	}
+
+	return cfg, nil
+}
+
+// webhookConfigFromEnv reads HUB_WEBHOOK_MAX_ATTEMPTS and
+// HUB_WEBHOOK_RETRY_BACKOFF.
+func webhookConfigFromEnv() (webhook.Config, error) {
+	cfg := webhook.DefaultConfig()
+
+	if raw := os.Getenv("HUB_WEBHOOK_MAX_ATTEMPTS"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("invalid HUB_WEBHOOK_MAX_ATTEMPTS %q", raw)
+		}
+		cfg.MaxAttempts = n
+	}
+	if raw := os.Getenv("HUB_WEBHOOK_RETRY_BACKOFF"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("invalid HUB_WEBHOOK_RETRY_BACKOFF %q", raw)
+		}
+		cfg.RetryBackoff = d
+	}
+
+	return cfg, nil
+}
+
+// eventLogSizeFromEnv reads HUB_EVENT_LOG_SIZE, the number of recent events
+// kept for event streams to resume from.
+func eventLogSizeFromEnv() (int, error) {
+	raw := os.Getenv("HUB_EVENT_LOG_SIZE")
+	if raw == "" {
+		return events.DefaultLogSize, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid HUB_EVENT_LOG_SIZE %q", raw)
+	}
+	return n, nil
+}
+
+// secretKeeperFromEnv loads the secrets master key from
+// HUB_SECRETS_KEY_FILE (default hub-secrets.key), generating it on first
+// start.
+func secretKeeperFromEnv(db store.Store) (*secrets.Keeper, error) {
+	path := os.Getenv("HUB_SECRETS_KEY_FILE")
+	if path == "" {
+		path = "hub-secrets.key"
+	}
+	key, err := secrets.LoadMasterKey(path)
+	if err != nil {
+		return nil, err
+	}
+	return secrets.NewKeeper(db, key)
+}
+
+// timeoutExceptStreams is middleware.Timeout for every request except the
+// long-lived event streams.
+func timeoutExceptStreams(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		limited := middleware.Timeout(timeout)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/events") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			limited.ServeHTTP(w, r)
+		})
+	}
+}
+
+// auditCheckpointConfigFromEnv reads HUB_AUDIT_CHECKPOINT_KEY and
+// HUB_AUDIT_CHECKPOINT_INTERVAL. The key is nil if checkpoints are disabled.
+func auditCheckpointConfigFromEnv() ([]byte, time.Duration, error) {
+	interval := audit.DefaultCheckpointInterval
+	if raw := os.Getenv("HUB_AUDIT_CHECKPOINT_INTERVAL"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return nil, 0, fmt.Errorf("invalid HUB_AUDIT_CHECKPOINT_INTERVAL %q", raw)
+		}
+		interval = d
+	}
+
+	key := os.Getenv("HUB_AUDIT_CHECKPOINT_KEY")
+	if key == "" {
+		return nil, interval, nil
+	}
+	if len(key) < 32 {
+		return nil, 0, fmt.Errorf("HUB_AUDIT_CHECKPOINT_KEY must be at least 32 characters")
+	}
+	return []byte(key), interval, nil
+}
+
+// auditRecorderFromEnv creates the audit recorder with the sinks configured
+// by HUB_AUDIT_SYSLOG_ADDR, HUB_AUDIT_FILE and HUB_AUDIT_WEBHOOK_URL.
+func auditRecorderFromEnv(db store.Store) (*audit.Recorder, error) {
+	var sinks []audit.Sink
+
+	if addr := os.Getenv("HUB_AUDIT_SYSLOG_ADDR"); addr != "" {
+		sink, err := audit.NewSyslogSink(addr, os.Getenv("HUB_AUDIT_SYSLOG_APP_NAME"))
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if path := os.Getenv("HUB_AUDIT_FILE"); path != "" {
+		var maxSizeMB, maxBackups int
+		ints := []struct {
+			env string
+			dst *int
+		}{
+			{"HUB_AUDIT_FILE_MAX_SIZE_MB", &maxSizeMB},
+			{"HUB_AUDIT_FILE_MAX_BACKUPS", &maxBackups},
+		}
+		for _, v := range ints {
+			if raw := os.Getenv(v.env); raw != "" {
+				n, err := strconv.Atoi(raw)
+				if err != nil || n <= 0 {
+					return nil, fmt.Errorf("invalid %s %q", v.env, raw)
+				}
+				*v.dst = n
+			}
+		}
+
+		sink, err := audit.NewFileSink(path, int64(maxSizeMB)<<20, maxBackups)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if url := os.Getenv("HUB_AUDIT_WEBHOOK_URL"); url != "" {
+		sink, err := audit.NewWebhookSink(url, os.Getenv("HUB_AUDIT_WEBHOOK_TOKEN"))
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	cfg := audit.DefaultRecorderConfig()
+	if path := os.Getenv("HUB_AUDIT_DEAD_LETTER_FILE"); path != "" {
+		cfg.DeadLetterFile = path
+	}
+
+	for _, sink := range sinks {
+		log.Info().Str("sink", sink.Name()).Msg("Streaming audit logs")
+	}
+	return audit.NewRecorder(db, cfg, sinks...), nil
+}
+
+// notifierFromEnv builds the notifier used for password reset links from
+// HUB_NOTIFIER ("smtp", "file" or "log"). It returns nil if unset.
+func notifierFromEnv() (notify.Notifier, error) {
+	switch kind := os.Getenv("HUB_NOTIFIER"); kind {
+	case "":
+		return nil, nil
+	case "smtp":
+		return notify.NewSMTPNotifier(notify.SMTPConfig{
+			Addr:     os.Getenv("HUB_SMTP_ADDR"),
+			Username: os.Getenv("HUB_SMTP_USERNAME"),
+			Password: os.Getenv("HUB_SMTP_PASSWORD"),
+			From:     os.Getenv("HUB_SMTP_FROM"),
+		})
+	case "file":
+		path := os.Getenv("HUB_NOTIFY_FILE")
+		if path == "" {
+			return nil, fmt.Errorf("HUB_NOTIFY_FILE is required for the file notifier")
+		}
+		return notify.NewFileNotifier(path), nil
+	case "log":
+		return notify.LogNotifier{}, nil
+	default:
+		return nil, fmt.Errorf("unknown HUB_NOTIFIER %q (must be smtp, file or log)", kind)
+	}
+}
+
+// validRole reports whether role is one of the built-in user roles.
+func validRole(role store.UserRole) bool {
+	switch role {
+	case store.UserRoleAdmin, store.UserRoleOperator, store.UserRoleViewer:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/spf13/cobra"
+)
+
+func migrateCmd() *cobra.Command {
+	var dbURL string
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage database schema migrations",
+	}
+	cmd.PersistentFlags().StringVar(&dbURL, "database-url", "sqlite://hub.db", "Database connection URL")
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show applied and pending migrations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withMigrator(dbURL, func(ctx context.Context, m *store.Migrator) error {
+				statuses, err := m.Status(ctx)
+				if err != nil {
+					return err
+				}
+
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+				for _, s := range statuses {
+					fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, migrationState(s))
+				}
+				return w.Flush()
+			})
+		},
+	}
+
+	var target int
+	upCmd := &cobra.Command{
+		Use:   "up",
+		Short: "Apply pending migrations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withMigrator(dbURL, func(ctx context.Context, m *store.Migrator) error {
+				applied, err := m.Up(ctx, target)
+				for _, s := range applied {
+					fmt.Printf("Applied %s\n", s.Name)
+				}
+				if err == nil && len(applied) == 0 {
+					fmt.Println("No pending migrations")
+				}
+				return err
+			})
+		},
+	}
+	upCmd.Flags().IntVar(&target, "to", 0, "Stop after this version (default: latest)")
+
+	var steps int
+	downCmd := &cobra.Command{
+		Use:   "down",
+		Short: "Revert the most recently applied migrations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if steps < 1 {
+				return fmt.Errorf("--steps must be at least 1")
+			}
+			return withMigrator(dbURL, func(ctx context.Context, m *store.Migrator) error {
+				reverted, err := m.Down(ctx, steps)
+				for _, s := range reverted {
+					fmt.Printf("Reverted %s\n", s.Name)
+				}
+				return err
+			})
+		},
+	}
+	downCmd.Flags().IntVar(&steps, "steps", 1, "Number of migrations to revert")
+
+	cmd.AddCommand(statusCmd, upCmd, downCmd)
+	return cmd
+}
+
+// withMigrator opens a migrator for the duration of fn.
+func withMigrator(dbURL string, fn func(ctx context.Context, m *store.Migrator) error) error {
+	m, err := store.NewMigrator(dbURL)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer m.Close()
+
+	return fn(context.Background(), m)
+}
+
+// migrationState describes a migration for `hub migrate status`.
+func migrationState(s store.MigrationStatus) string {
+	switch {
+	case s.Unknown:
+		return "applied " + s.AppliedAt.Format(time.RFC3339) + " (unknown to this hub)"
+	case s.Modified:
+		return "applied " + s.AppliedAt.Format(time.RFC3339) + " (modified since)"
+	case s.AppliedAt != nil:
+		return "applied " + s.AppliedAt.Format(time.RFC3339)
+	default:
+		return "pending"
+	}
+}
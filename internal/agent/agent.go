@@ -273,6 +273,13 @@ func (a *Agent) sendHeartbeat(ctx context.Context) {
 		LatencyP99Ms:   0,
 	}
 
+	// Report the hash of the config on disk, so the hub notices local edits
+	if content, err := a.sentinel.ReadCurrentConfig(); err != nil {
+		log.Warn().Err(err).Msg("Failed to read config")
+	} else if content != "" {
+		a.client.SetConfigHashFromContent(content)
+	}
+
 	resp, err := a.client.Heartbeat(ctx, state, message, metrics)
 	if err != nil {
 		log.Error().Err(err).Msg("Heartbeat failed")
@@ -317,6 +324,14 @@ func (a *Agent) processAction(ctx context.Context, action *pb.PendingAction) {
 			log.Error().Err(err).Msg("Failed to apply config")
 		}
 
+	case pb.ActionType_ACTION_TYPE_REPORT_CONFIG:
+		content, err := a.sentinel.ReadCurrentConfig()
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to read config")
+			return
+		}
+		a.client.ReportConfigContent(content)
+
 	case pb.ActionType_ACTION_TYPE_DRAIN:
 		log.Info().Msg("Drain requested")
 		// TODO: Implement drain logic
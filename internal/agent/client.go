@@ -48,6 +48,7 @@ type Client struct {
 	// Current config state
 	currentConfigVersion string
 	currentConfigHash    string
+	reportedContent      string // Sent with the next heartbeat, then cleared
 	configMu             sync.RWMutex
 
 	// Event handling
@@ -303,10 +304,12 @@ func (c *Client) Heartbeat(ctx context.Context, state pb.InstanceState, message
 		return nil, fmt.Errorf("not registered")
 	}
 
-	c.configMu.RLock()
+	c.configMu.Lock()
 	configVersion := c.currentConfigVersion
 	configHash := c.currentConfigHash
-	c.configMu.RUnlock()
+	configContent := c.reportedContent
+	c.reportedContent = ""
+	c.configMu.Unlock()
 
 	resp, err := client.Heartbeat(ctx, &pb.HeartbeatRequest{
 		InstanceId:           c.instanceID,
@@ -315,6 +318,7 @@ func (c *Client) Heartbeat(ctx context.Context, state pb.InstanceState, message
 		CurrentConfigVersion: configVersion,
 		CurrentConfigHash:    configHash,
 		Metrics:              metrics,
+		CurrentConfigContent: configContent,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("heartbeat failed: %w", err)
@@ -407,6 +411,24 @@ func (c *Client) SetConfigFromContent(version, content string) {
 	c.UpdateConfigState(version, hex.EncodeToString(hash[:]))
 }
 
+// SetConfigHashFromContent updates the reported hash from the config's
+// content on disk, keeping the reported version, so the hub notices local
+// edits.
+func (c *Client) SetConfigHashFromContent(content string) {
+	hash := sha256.Sum256([]byte(content))
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+	c.currentConfigHash = hex.EncodeToString(hash[:])
+}
+
+// ReportConfigContent sends the config's content with the next heartbeat,
+// for the hub to adopt local edits.
+func (c *Client) ReportConfigContent(content string) {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+	c.reportedContent = content
+}
+
 // AckDeployment acknowledges a deployment request.
 func (c *Client) AckDeployment(ctx context.Context, deploymentID string, accepted bool, reason string) error {
 	c.connMu.RLock()
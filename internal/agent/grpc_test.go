@@ -2,6 +2,8 @@ package agent
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"os"
@@ -58,6 +60,8 @@ type mockFleetService struct {
 
 	// Last received values
 	lastHeartbeatState   pb.InstanceState
+	lastConfigHash       string
+	lastConfigContent    string
 	lastDeploymentStatus pb.DeploymentState
 	lastDeploymentID     string
 }
@@ -98,6 +102,8 @@ func (m *mockFleetService) Heartbeat(ctx context.Context, req *pb.HeartbeatReque
 	if req.Status != nil {
 		m.lastHeartbeatState = req.Status.State
 	}
+	m.lastConfigHash = req.CurrentConfigHash
+	m.lastConfigContent = req.CurrentConfigContent
 
 	if m.heartbeatError != nil {
 		return nil, m.heartbeatError
@@ -454,6 +460,57 @@ func TestClient_Heartbeat_WithPendingActions(t *testing.T) {
 	}
 }
 
+func TestAgent_sendHeartbeat_ReportsLocalConfig(t *testing.T) {
+	ts := newTestServer()
+	defer ts.Stop()
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.kdl")
+	agent, err := New(Config{
+		HubURL:            "passthrough://bufnet",
+		InstanceName:      "test-instance",
+		SentinelConfig:    configPath,
+		StatePath:         filepath.Join(tmpDir, "state.json"),
+		HeartbeatInterval: 30 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	ctx := context.Background()
+	conn, _ := ts.Dial(ctx)
+	agent.client.conn = conn
+	agent.client.client = pb.NewFleetServiceClient(conn)
+	agent.client.token = "test-token"
+	agent.client.UpdateConfigState("1", ts.service.configHash)
+
+	// A local edit changes the reported hash, but not the version
+	edited := "server { listen 9090 }"
+	os.WriteFile(configPath, []byte(edited), 0644)
+	ts.service.pendingActions = []*pb.PendingAction{{ActionId: "action-1", Type: pb.ActionType_ACTION_TYPE_REPORT_CONFIG}}
+	agent.sendHeartbeat(ctx)
+
+	sum := sha256.Sum256([]byte(edited))
+	ts.service.mu.Lock()
+	hash, content := ts.service.lastConfigHash, ts.service.lastConfigContent
+	ts.service.pendingActions = nil
+	ts.service.mu.Unlock()
+	if hash != hex.EncodeToString(sum[:]) || content != "" {
+		t.Errorf("expected the hash of the edited config only, got %q, %q", hash, content)
+	}
+
+	// The content is sent with the next heartbeat once asked for
+	for _, want := range []string{edited, ""} {
+		agent.sendHeartbeat(ctx)
+		ts.service.mu.Lock()
+		content := ts.service.lastConfigContent
+		ts.service.mu.Unlock()
+		if content != want {
+			t.Errorf("heartbeat content = %q, want %q", content, want)
+		}
+	}
+}
+
 func TestClient_Deregister(t *testing.T) {
 	ts := newTestServer()
 	defer ts.Stop()
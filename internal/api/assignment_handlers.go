@@ -0,0 +1,416 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/raskell-io/sentinel-hub/internal/assign"
+	"github.com/raskell-io/sentinel-hub/internal/auth"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// Config Assignment Handlers
+// ============================================
+
+// CreateConfigAssignmentRequest represents the request body for creating
+// a config assignment.
+type CreateConfigAssignmentRequest struct {
+	Name     string            `json:"name"`
+	ConfigID string            `json:"config_id"`
+	Version  *int              `json:"version,omitempty"` // Latest approved when omitted
+	Selector map[string]string `json:"selector"`
+	Priority int               `json:"priority"`
+}
+
+// UpdateConfigAssignmentRequest represents the request body for updating
+// a config assignment.
+type UpdateConfigAssignmentRequest struct {
+	Name     *string            `json:"name,omitempty"`
+	ConfigID *string            `json:"config_id,omitempty"`
+	Version  *int               `json:"version,omitempty"`
+	Latest   bool               `json:"latest,omitempty"` // Unpins the version
+	Selector *map[string]string `json:"selector,omitempty"`
+	Priority *int               `json:"priority,omitempty"`
+}
+
+// ListConfigAssignmentsResponse represents the response for listing config
+// assignments.
+type ListConfigAssignmentsResponse struct {
+	Assignments []store.ConfigAssignment `json:"assignments"`
+	Total       int                      `json:"total"`
+}
+
+// InstanceAssignmentResponse is the config assignments select for an
+// instance. It is empty when no assignment matches.
+type InstanceAssignmentResponse struct {
+	InstanceID string `json:"instance_id"`
+	assign.Resolution
+}
+
+// assignmentConfigName returns the name of an assignment's config, or ""
+// if the config does not exist.
+func (h *Handler) assignmentConfigName(ctx context.Context, configID string) (string, error) {
+	cfg, err := h.store.GetConfig(ctx, configID)
+	if err != nil || cfg == nil {
+		return "", err
+	}
+	return cfg.Name, nil
+}
+
+// authorizeAssignment checks that the caller may deploy an assignment's
+// config to every instance its selector can match.
+func authorizeAssignment(ctx context.Context, scope string, a *store.ConfigAssignment, configName string) bool {
+	return auth.Authorize(ctx, scope, auth.DeploymentResource(a.Selector, configName))
+}
+
+// validateAssignment checks an assignment's fields and that its config and
+// pinned version exist, writing an error response if not. It returns the
+// config's name.
+func (h *Handler) validateAssignment(w http.ResponseWriter, r *http.Request, a *store.ConfigAssignment) (string, bool) {
+	ctx := r.Context()
+	if a.Name == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "name is required")
+		return "", false
+	}
+	if a.ConfigID == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "config_id is required")
+		return "", false
+	}
+
+	configName, err := h.assignmentConfigName(ctx, a.ConfigID)
+	if err != nil {
+		log.Error().Err(err).Str("config_id", a.ConfigID).Msg("Failed to get config")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to check config")
+		return "", false
+	}
+	if configName == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Config "+a.ConfigID+" does not exist")
+		return "", false
+	}
+
+	if a.Version != nil {
+		ver, err := h.store.GetConfigVersion(ctx, a.ConfigID, *a.Version)
+		if err != nil {
+			log.Error().Err(err).Str("config_id", a.ConfigID).Msg("Failed to get config version")
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to check config version")
+			return "", false
+		}
+		if ver == nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "version does not exist")
+			return "", false
+		}
+	}
+	return configName, true
+}
+
+// ListConfigAssignments handles GET /api/v1/assignments
+func (h *Handler) ListConfigAssignments(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	all, err := h.store.ListConfigAssignments(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list config assignments")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list assignments")
+		return
+	}
+
+	names := make(map[string]string)
+	visible := []store.ConfigAssignment{}
+	for i := range all {
+		a := &all[i]
+		name, ok := names[a.ConfigID]
+		if !ok {
+			if name, err = h.assignmentConfigName(ctx, a.ConfigID); err != nil {
+				log.Error().Err(err).Str("config_id", a.ConfigID).Msg("Failed to get config")
+				writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list assignments")
+				return
+			}
+			names[a.ConfigID] = name
+		}
+		if authorizeAssignment(ctx, auth.ScopeDeploymentsRead, a, name) {
+			visible = append(visible, *a)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, ListConfigAssignmentsResponse{
+		Assignments: visible,
+		Total:       len(visible),
+	})
+}
+
+// CreateConfigAssignment handles POST /api/v1/assignments
+func (h *Handler) CreateConfigAssignment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req CreateConfigAssignmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+		return
+	}
+
+	a := &store.ConfigAssignment{
+		Name:     req.Name,
+		ConfigID: req.ConfigID,
+		Version:  req.Version,
+		Selector: req.Selector,
+		Priority: req.Priority,
+	}
+	configName, ok := h.validateAssignment(w, r, a)
+	if !ok {
+		return
+	}
+	if !authorizeAssignment(ctx, auth.ScopeDeploymentsCreate, a, configName) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to assign this config to these instances")
+		return
+	}
+	if user := auth.GetUserFromContext(ctx); user != nil {
+		a.CreatedBy = &user.ID
+	}
+
+	if err := h.store.CreateConfigAssignment(ctx, a); err != nil {
+		if err.Error() == "config assignment with this name already exists" {
+			writeError(w, http.StatusConflict, "ALREADY_EXISTS", "Assignment with this name already exists")
+			return
+		}
+		log.Error().Err(err).Msg("Failed to create config assignment")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create assignment")
+		return
+	}
+
+	h.auditLog(r, "create", "assignment", a.ID, map[string]interface{}{
+		"name":      a.Name,
+		"config_id": a.ConfigID,
+		"version":   a.Version,
+		"selector":  a.Selector,
+		"priority":  a.Priority,
+	})
+	writeJSON(w, http.StatusCreated, a)
+}
+
+// getConfigAssignment loads the assignment named in the URL if the caller
+// may see it with scope, writing an error response if not.
+func (h *Handler) getConfigAssignment(w http.ResponseWriter, r *http.Request, scope string) (*store.ConfigAssignment, bool) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	a, err := h.store.GetConfigAssignment(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to get config assignment")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get assignment")
+		return nil, false
+	}
+	if a == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Assignment not found")
+		return nil, false
+	}
+
+	configName, err := h.assignmentConfigName(ctx, a.ConfigID)
+	if err != nil {
+		log.Error().Err(err).Str("config_id", a.ConfigID).Msg("Failed to get config")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get assignment")
+		return nil, false
+	}
+	if !authorizeAssignment(ctx, auth.ScopeDeploymentsRead, a, configName) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Assignment not found")
+		return nil, false
+	}
+	if scope != auth.ScopeDeploymentsRead && !authorizeAssignment(ctx, scope, a, configName) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to change this assignment")
+		return nil, false
+	}
+	return a, true
+}
+
+// GetConfigAssignment handles GET /api/v1/assignments/{id}
+func (h *Handler) GetConfigAssignment(w http.ResponseWriter, r *http.Request) {
+	a, ok := h.getConfigAssignment(w, r, auth.ScopeDeploymentsRead)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, a)
+}
+
+// UpdateConfigAssignment handles PUT /api/v1/assignments/{id}
+//
+// Changes apply to instances registering afterwards; instances that
+// already have a config keep it.
+func (h *Handler) UpdateConfigAssignment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	a, ok := h.getConfigAssignment(w, r, auth.ScopeDeploymentsCreate)
+	if !ok {
+		return
+	}
+
+	var req UpdateConfigAssignmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+		return
+	}
+	if req.Latest && req.Version != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "version and latest cannot be combined")
+		return
+	}
+
+	if req.Name != nil {
+		a.Name = *req.Name
+	}
+	if req.ConfigID != nil {
+		a.ConfigID = *req.ConfigID
+	}
+	if req.Version != nil {
+		a.Version = req.Version
+	}
+	if req.Latest {
+		a.Version = nil
+	}
+	if req.Selector != nil {
+		a.Selector = *req.Selector
+	}
+	if req.Priority != nil {
+		a.Priority = *req.Priority
+	}
+
+	configName, ok := h.validateAssignment(w, r, a)
+	if !ok {
+		return
+	}
+	if !authorizeAssignment(ctx, auth.ScopeDeploymentsCreate, a, configName) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to assign this config to these instances")
+		return
+	}
+
+	if err := h.store.UpdateConfigAssignment(ctx, a); err != nil {
+		switch err.Error() {
+		case "config assignment with this name already exists":
+			writeError(w, http.StatusConflict, "ALREADY_EXISTS", "Assignment with this name already exists")
+		case "config assignment not found":
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Assignment not found")
+		default:
+			log.Error().Err(err).Str("id", a.ID).Msg("Failed to update config assignment")
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update assignment")
+		}
+		return
+	}
+
+	h.auditLog(r, "update", "assignment", a.ID, map[string]interface{}{
+		"name":      a.Name,
+		"config_id": a.ConfigID,
+		"version":   a.Version,
+		"selector":  a.Selector,
+		"priority":  a.Priority,
+	})
+	writeJSON(w, http.StatusOK, a)
+}
+
+// DeleteConfigAssignment handles DELETE /api/v1/assignments/{id}
+func (h *Handler) DeleteConfigAssignment(w http.ResponseWriter, r *http.Request) {
+	a, ok := h.getConfigAssignment(w, r, auth.ScopeDeploymentsCreate)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteConfigAssignment(r.Context(), a.ID); err != nil {
+		if err.Error() == "config assignment not found" {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Assignment not found")
+			return
+		}
+		log.Error().Err(err).Str("id", a.ID).Msg("Failed to delete config assignment")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete assignment")
+		return
+	}
+
+	h.auditLog(r, "delete", "assignment", a.ID, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetInstanceAssignment handles GET /api/v1/instances/{id}/assignment
+//
+// It shows the config assignments select for the instance now, including
+// conflicts, whether or not the instance already runs a config.
+func (h *Handler) GetInstanceAssignment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	inst, err := h.store.GetInstance(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to get instance")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get instance")
+		return
+	}
+	if inst == nil || !auth.Authorize(ctx, auth.ScopeInstancesRead, auth.InstanceResource(inst.Labels)) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Instance not found")
+		return
+	}
+
+	res, err := assign.Resolve(ctx, h.store, inst.Labels)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to resolve config assignments")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to resolve assignments")
+		return
+	}
+
+	resp := InstanceAssignmentResponse{InstanceID: inst.ID}
+	if res != nil {
+		resp.Resolution = *res
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// ApproveConfigVersion handles POST /api/v1/configs/{id}/versions/{version}/approve
+//
+// Approved versions are what assignments following the config hand to new
+// instances. Versions are also approved when a deployment of them
+// completes.
+func (h *Handler) ApproveConfigVersion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil || version <= 0 {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "version must be a positive version number")
+		return
+	}
+
+	cfg, err := h.store.GetConfig(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to get config")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to approve config version")
+		return
+	}
+	if cfg == nil || !auth.Authorize(ctx, auth.ScopeConfigsRead, auth.ConfigResource(cfg.Name)) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Config not found")
+		return
+	}
+	if !auth.Authorize(ctx, auth.ScopeDeploymentsCreate, auth.ConfigResource(cfg.Name)) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to approve versions of this config")
+		return
+	}
+
+	if err := h.store.ApproveConfigVersion(ctx, id, version); err != nil {
+		if err.Error() == "config version not found" {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Version not found")
+			return
+		}
+		log.Error().Err(err).Str("id", id).Int("version", version).Msg("Failed to approve config version")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to approve config version")
+		return
+	}
+
+	ver, err := h.store.GetConfigVersion(ctx, id, version)
+	if err != nil || ver == nil {
+		log.Error().Err(err).Str("id", id).Int("version", version).Msg("Failed to get config version")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get config version")
+		return
+	}
+
+	h.auditLog(r, "approve", "config", id, map[string]interface{}{
+		"version": version,
+	})
+	writeJSON(w, http.StatusOK, ver)
+}
@@ -2,10 +2,14 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
-	"strings"
+	"net/url"
+	"strconv"
 
 	"github.com/raskell-io/sentinel-hub/internal/auth"
+	"github.com/raskell-io/sentinel-hub/internal/store"
 	"github.com/rs/zerolog/log"
 )
 
@@ -37,6 +41,8 @@ type LoginResponse struct {
 		Name  string `json:"name"`
 		Role  string `json:"role"`
 	} `json:"user"`
+	// RecoveryCodes is only set when MFA enrollment completes during login.
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
 }
 
 // Login handles POST /api/v1/auth/login
@@ -57,11 +63,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get client info
-	ipAddress := r.RemoteAddr
-	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
-		ipAddress = strings.Split(forwarded, ",")[0]
-	}
-	userAgent := r.Header.Get("User-Agent")
+	ipAddress, userAgent := clientInfo(r)
 
 	// Authenticate
 	tokenPair, err := h.authService.Login(r.Context(), req.Email, req.Password, ipAddress, userAgent)
@@ -70,6 +72,18 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusUnauthorized, "INVALID_CREDENTIALS", "Invalid email or password")
 			return
 		}
+		if writeLockedError(w, err) {
+			return
+		}
+		var challenge *auth.MFAChallenge
+		if errors.As(err, &challenge) {
+			writeJSON(w, http.StatusOK, MFAChallengeResponse{
+				MFARequired:           true,
+				MFAToken:              challenge.Token,
+				MFAEnrollmentRequired: challenge.EnrollmentRequired,
+			})
+			return
+		}
 		log.Error().Err(err).Msg("Login failed")
 		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Login failed")
 		return
@@ -83,6 +97,71 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	writeJSON(w, http.StatusOK, newLoginResponse(tokenPair, user))
+}
+
+// RedeemResetTokenRequest represents the request body for redeeming a password reset token.
+type RedeemResetTokenRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// ResetPassword handles POST /api/v1/auth/reset-password
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req RedeemResetTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+		return
+	}
+
+	if req.Token == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "token is required")
+		return
+	}
+	if req.NewPassword == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "new_password is required")
+		return
+	}
+
+	if err := h.authService.ResetPasswordWithToken(r.Context(), req.Token, req.NewPassword); err != nil {
+		switch err {
+		case auth.ErrInvalidResetToken:
+			writeError(w, http.StatusBadRequest, "INVALID_TOKEN", "Invalid or expired reset token")
+		case auth.ErrPasswordTooShort:
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Password must be at least 8 characters")
+		case auth.ErrPasswordNoUppercase:
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Password must contain at least one uppercase letter")
+		case auth.ErrPasswordNoLowercase:
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Password must contain at least one lowercase letter")
+		case auth.ErrPasswordNoDigit:
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Password must contain at least one digit")
+		default:
+			log.Error().Err(err).Msg("Failed to redeem reset token")
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to reset password")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ResetPasswordResponse{
+		Message: "Password has been reset successfully",
+	})
+}
+
+// writeLockedError writes a 429 response with a Retry-After header if err is
+// a login lockout, and reports whether it did.
+func writeLockedError(w http.ResponseWriter, err error) bool {
+	var locked *auth.LockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+	seconds := int(math.Ceil(locked.RetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeError(w, http.StatusTooManyRequests, "TOO_MANY_ATTEMPTS", "Too many failed login attempts, try again later")
+	return true
+}
+
+// newLoginResponse builds a LoginResponse from a token pair and its user.
+func newLoginResponse(tokenPair *auth.TokenPair, user *store.User) LoginResponse {
 	resp := LoginResponse{
 		AccessToken:  tokenPair.AccessToken,
 		RefreshToken: tokenPair.RefreshToken,
@@ -93,10 +172,185 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	resp.User.Email = user.Email
 	resp.User.Name = user.Name
 	resp.User.Role = string(user.Role)
+	return resp
+}
+
+// clientInfo extracts the client IP address and user agent from a request.
+// The address is the connection's, which auth.RealIP replaces with the
+// forwarded client address only for requests from trusted proxies.
+func clientInfo(r *http.Request) (ipAddress, userAgent string) {
+	return r.RemoteAddr, r.Header.Get("User-Agent")
+}
+
+// oidcStateCookie is the cookie carrying the signed OIDC login state.
+const oidcStateCookie = "hub_oidc_state"
+
+// OIDCLogin handles GET /api/v1/auth/oidc/login
+func (h *AuthHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.authService.OIDCEnabled() {
+		writeError(w, http.StatusNotFound, "OIDC_DISABLED", "OIDC single sign-on is not configured")
+		return
+	}
+
+	authURL, stateToken, err := h.authService.BeginOIDCLogin(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to start OIDC login")
+		writeError(w, http.StatusBadGateway, "OIDC_ERROR", "Failed to contact identity provider")
+		return
+	}
+
+	setOIDCStateCookie(w, r, stateToken)
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback handles GET /api/v1/auth/oidc/callback
+func (h *AuthHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if !h.authService.OIDCEnabled() {
+		writeError(w, http.StatusNotFound, "OIDC_DISABLED", "OIDC single sign-on is not configured")
+		return
+	}
+
+	query := r.URL.Query()
+	if idpErr := query.Get("error"); idpErr != "" {
+		log.Warn().
+			Str("error", idpErr).
+			Str("description", query.Get("error_description")).
+			Msg("Identity provider returned an error")
+		writeError(w, http.StatusUnauthorized, "OIDC_ERROR", "Identity provider rejected the login")
+		return
+	}
+
+	code := query.Get("code")
+	if code == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "code is required")
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_STATE", "Missing OIDC login state")
+		return
+	}
+
+	// The state cookie is single use
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    "",
+		Path:     "/api/v1/auth/oidc",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	ipAddress, userAgent := clientInfo(r)
+	tokenPair, user, err := h.authService.CompleteOIDCLogin(r.Context(), cookie.Value, query.Get("state"), code, ipAddress, userAgent)
+	var challenge *auth.MFAChallenge
+	if errors.As(err, &challenge) {
+		h.writeOIDCChallenge(w, r, challenge)
+		return
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrOIDCInvalidState):
+			writeError(w, http.StatusBadRequest, "INVALID_STATE", "Invalid or expired OIDC login state")
+		case errors.Is(err, auth.ErrOIDCInvalidIDToken):
+			writeError(w, http.StatusUnauthorized, "INVALID_TOKEN", "Identity provider returned an invalid ID token")
+		case errors.Is(err, auth.ErrOIDCNoRole):
+			writeError(w, http.StatusForbidden, "FORBIDDEN", "Your identity is not permitted to access the hub")
+		case errors.Is(err, auth.ErrOIDCNotLinked):
+			writeError(w, http.StatusConflict, "NOT_LINKED", "A user with this email already exists; sign in and link your identity provider account first")
+		case errors.Is(err, auth.ErrOIDCAlreadyLinked):
+			writeError(w, http.StatusConflict, "ALREADY_LINKED", "This identity provider account is linked to another user")
+		default:
+			log.Error().Err(err).Msg("OIDC login failed")
+			writeError(w, http.StatusBadGateway, "OIDC_ERROR", "OIDC login failed")
+		}
+		return
+	}
+
+	resp := newLoginResponse(tokenPair, user)
+
+	// Browser flow: hand the tokens to the web UI in the URL fragment,
+	// which is never sent to servers or logged by proxies.
+	if redirectURL := h.authService.OIDCProvider().Config().PostLoginRedirectURL; redirectURL != "" {
+		fragment := url.Values{}
+		fragment.Set("access_token", resp.AccessToken)
+		fragment.Set("refresh_token", resp.RefreshToken)
+		fragment.Set("expires_at", resp.ExpiresAt)
+		fragment.Set("token_type", resp.TokenType)
+		http.Redirect(w, r, redirectURL+"#"+fragment.Encode(), http.StatusFound)
+		return
+	}
 
 	writeJSON(w, http.StatusOK, resp)
 }
 
+// writeOIDCChallenge asks for the second factor of an OIDC login, in the
+// URL fragment for the browser flow like the tokens of a complete login.
+func (h *AuthHandler) writeOIDCChallenge(w http.ResponseWriter, r *http.Request, challenge *auth.MFAChallenge) {
+	if redirectURL := h.authService.OIDCProvider().Config().PostLoginRedirectURL; redirectURL != "" {
+		fragment := url.Values{}
+		fragment.Set("mfa_required", "true")
+		fragment.Set("mfa_token", challenge.Token)
+		fragment.Set("mfa_enrollment_required", strconv.FormatBool(challenge.EnrollmentRequired))
+		http.Redirect(w, r, redirectURL+"#"+fragment.Encode(), http.StatusFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MFAChallengeResponse{
+		MFARequired:           true,
+		MFAToken:              challenge.Token,
+		MFAEnrollmentRequired: challenge.EnrollmentRequired,
+	})
+}
+
+// OIDCLinkResponse is returned when starting to link an OIDC identity.
+type OIDCLinkResponse struct {
+	// URL is where the browser signs in at the identity provider.
+	URL string `json:"url"`
+}
+
+// OIDCLink handles POST /api/v1/auth/oidc/link
+//
+// It starts an OIDC login that links the identity to the caller's account
+// instead of matching it by email. The state cookie is set on the response,
+// so the browser must then visit the returned URL.
+func (h *AuthHandler) OIDCLink(w http.ResponseWriter, r *http.Request) {
+	if !h.authService.OIDCEnabled() {
+		writeError(w, http.StatusNotFound, "OIDC_DISABLED", "OIDC single sign-on is not configured")
+		return
+	}
+	user := auth.GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Not authenticated")
+		return
+	}
+
+	authURL, stateToken, err := h.authService.BeginOIDCLink(r.Context(), user)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to start OIDC link")
+		writeError(w, http.StatusBadGateway, "OIDC_ERROR", "Failed to contact identity provider")
+		return
+	}
+
+	setOIDCStateCookie(w, r, stateToken)
+	writeJSON(w, http.StatusOK, OIDCLinkResponse{URL: authURL})
+}
+
+// setOIDCStateCookie sets the cookie carrying the signed OIDC login state.
+func setOIDCStateCookie(w http.ResponseWriter, r *http.Request, stateToken string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    stateToken,
+		Path:     "/api/v1/auth/oidc",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
 // LogoutRequest represents the logout request body.
 type LogoutRequest struct {
 	RefreshToken string `json:"refresh_token"`
@@ -158,6 +412,8 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusUnauthorized, "TOKEN_EXPIRED", "Refresh token has expired")
 		case auth.ErrSessionRevoked:
 			writeError(w, http.StatusUnauthorized, "SESSION_REVOKED", "Session has been revoked")
+		case auth.ErrRefreshTokenReused:
+			writeError(w, http.StatusUnauthorized, "TOKEN_REUSED", "Refresh token was already used, session has been revoked")
 		case auth.ErrUserNotFound:
 			writeError(w, http.StatusUnauthorized, "USER_NOT_FOUND", "User not found")
 		default:
@@ -183,11 +439,17 @@ func (h *AuthHandler) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	mfaEnabled, err := h.authService.MFAEnabled(r.Context(), user.ID)
+	if err != nil {
+		log.Warn().Err(err).Str("user_id", user.ID).Msg("Failed to get mfa status")
+	}
+
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"id":            user.ID,
 		"email":         user.Email,
 		"name":          user.Name,
 		"role":          user.Role,
+		"mfa_enabled":   mfaEnabled,
 		"created_at":    user.CreatedAt,
 		"last_login_at": user.LastLoginAt,
 	})
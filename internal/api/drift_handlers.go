@@ -0,0 +1,246 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/raskell-io/sentinel-hub/internal/auth"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// Drift Policy Handlers
+// ============================================
+
+// CreateDriftPolicyRequest represents the request body for creating a drift
+// policy.
+type CreateDriftPolicyRequest struct {
+	Name     string            `json:"name"`
+	Selector map[string]string `json:"selector"`
+	Action   store.DriftAction `json:"action"`
+	Priority int               `json:"priority"`
+}
+
+// UpdateDriftPolicyRequest represents the request body for updating a drift
+// policy.
+type UpdateDriftPolicyRequest struct {
+	Name     *string            `json:"name,omitempty"`
+	Selector *map[string]string `json:"selector,omitempty"`
+	Action   *store.DriftAction `json:"action,omitempty"`
+	Priority *int               `json:"priority,omitempty"`
+}
+
+// ListDriftPoliciesResponse represents the response for listing drift
+// policies.
+type ListDriftPoliciesResponse struct {
+	Policies []store.DriftPolicy `json:"policies"`
+	Total    int                 `json:"total"`
+}
+
+// authorizeDriftPolicy checks that the caller may deploy to every instance
+// a policy's selector can match. The policy covers whichever configs those
+// instances run, so an empty config name only matches grants without a
+// config restriction.
+func authorizeDriftPolicy(ctx context.Context, scope string, p *store.DriftPolicy) bool {
+	return auth.Authorize(ctx, scope, auth.DeploymentResource(p.Selector, ""))
+}
+
+// validateDriftPolicy checks a drift policy's fields, writing an error
+// response if they are invalid.
+func validateDriftPolicy(w http.ResponseWriter, p *store.DriftPolicy) bool {
+	if p.Name == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "name is required")
+		return false
+	}
+	switch p.Action {
+	case store.DriftActionAlert, store.DriftActionRedeploy, store.DriftActionAdopt:
+	default:
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "action must be alert, redeploy or adopt")
+		return false
+	}
+	return true
+}
+
+// ListDriftPolicies handles GET /api/v1/drift-policies
+func (h *Handler) ListDriftPolicies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	all, err := h.store.ListDriftPolicies(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list drift policies")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list drift policies")
+		return
+	}
+
+	visible := []store.DriftPolicy{}
+	for i := range all {
+		if authorizeDriftPolicy(ctx, auth.ScopeDeploymentsRead, &all[i]) {
+			visible = append(visible, all[i])
+		}
+	}
+
+	writeJSON(w, http.StatusOK, ListDriftPoliciesResponse{
+		Policies: visible,
+		Total:    len(visible),
+	})
+}
+
+// CreateDriftPolicy handles POST /api/v1/drift-policies
+func (h *Handler) CreateDriftPolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req CreateDriftPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+		return
+	}
+
+	p := &store.DriftPolicy{
+		Name:     req.Name,
+		Selector: req.Selector,
+		Action:   req.Action,
+		Priority: req.Priority,
+	}
+	if !validateDriftPolicy(w, p) {
+		return
+	}
+	if !authorizeDriftPolicy(ctx, auth.ScopeDeploymentsCreate, p) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to set the drift policy of these instances")
+		return
+	}
+	if user := auth.GetUserFromContext(ctx); user != nil {
+		p.CreatedBy = &user.ID
+	}
+
+	if err := h.store.CreateDriftPolicy(ctx, p); err != nil {
+		if err.Error() == "drift policy with this name already exists" {
+			writeError(w, http.StatusConflict, "ALREADY_EXISTS", "Drift policy with this name already exists")
+			return
+		}
+		log.Error().Err(err).Msg("Failed to create drift policy")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create drift policy")
+		return
+	}
+
+	h.auditLog(r, "create", "drift_policy", p.ID, map[string]interface{}{
+		"name":     p.Name,
+		"selector": p.Selector,
+		"action":   p.Action,
+		"priority": p.Priority,
+	})
+	writeJSON(w, http.StatusCreated, p)
+}
+
+// getDriftPolicy loads the drift policy named in the URL if the caller may
+// see it with scope, writing an error response if not.
+func (h *Handler) getDriftPolicy(w http.ResponseWriter, r *http.Request, scope string) (*store.DriftPolicy, bool) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	p, err := h.store.GetDriftPolicy(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to get drift policy")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get drift policy")
+		return nil, false
+	}
+	if p == nil || !authorizeDriftPolicy(ctx, auth.ScopeDeploymentsRead, p) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Drift policy not found")
+		return nil, false
+	}
+	if scope != auth.ScopeDeploymentsRead && !authorizeDriftPolicy(ctx, scope, p) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to change this drift policy")
+		return nil, false
+	}
+	return p, true
+}
+
+// GetDriftPolicy handles GET /api/v1/drift-policies/{id}
+func (h *Handler) GetDriftPolicy(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.getDriftPolicy(w, r, auth.ScopeDeploymentsRead)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// UpdateDriftPolicy handles PUT /api/v1/drift-policies/{id}
+func (h *Handler) UpdateDriftPolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	p, ok := h.getDriftPolicy(w, r, auth.ScopeDeploymentsCreate)
+	if !ok {
+		return
+	}
+
+	var req UpdateDriftPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+		return
+	}
+
+	if req.Name != nil {
+		p.Name = *req.Name
+	}
+	if req.Selector != nil {
+		p.Selector = *req.Selector
+	}
+	if req.Action != nil {
+		p.Action = *req.Action
+	}
+	if req.Priority != nil {
+		p.Priority = *req.Priority
+	}
+
+	if !validateDriftPolicy(w, p) {
+		return
+	}
+	if !authorizeDriftPolicy(ctx, auth.ScopeDeploymentsCreate, p) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to set the drift policy of these instances")
+		return
+	}
+
+	if err := h.store.UpdateDriftPolicy(ctx, p); err != nil {
+		switch err.Error() {
+		case "drift policy with this name already exists":
+			writeError(w, http.StatusConflict, "ALREADY_EXISTS", "Drift policy with this name already exists")
+		case "drift policy not found":
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Drift policy not found")
+		default:
+			log.Error().Err(err).Str("id", p.ID).Msg("Failed to update drift policy")
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update drift policy")
+		}
+		return
+	}
+
+	h.auditLog(r, "update", "drift_policy", p.ID, map[string]interface{}{
+		"name":     p.Name,
+		"selector": p.Selector,
+		"action":   p.Action,
+		"priority": p.Priority,
+	})
+	writeJSON(w, http.StatusOK, p)
+}
+
+// DeleteDriftPolicy handles DELETE /api/v1/drift-policies/{id}
+func (h *Handler) DeleteDriftPolicy(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.getDriftPolicy(w, r, auth.ScopeDeploymentsCreate)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteDriftPolicy(r.Context(), p.ID); err != nil {
+		if err.Error() == "drift policy not found" {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Drift policy not found")
+			return
+		}
+		log.Error().Err(err).Str("id", p.ID).Msg("Failed to delete drift policy")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete drift policy")
+		return
+	}
+
+	h.auditLog(r, "delete", "drift_policy", p.ID, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
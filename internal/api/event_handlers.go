@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/raskell-io/sentinel-hub/internal/auth"
+	"github.com/raskell-io/sentinel-hub/internal/events"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// Event Stream Handlers
+// ============================================
+
+// streamKeepAlive is how often an idle event stream sends a comment, so
+// that proxies and load balancers keep the connection open.
+var streamKeepAlive = 15 * time.Second
+
+// resyncEvent tells a client that events may have been missed since its
+// Last-Event-ID, so it should reload the state it displays.
+const resyncEvent = "resync"
+
+// StreamEvents handles GET /api/v1/events
+func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	h.streamEvents(w, r, "")
+}
+
+// StreamDeploymentEvents handles GET /api/v1/deployments/{id}/events
+func (h *Handler) StreamDeploymentEvents(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	dep, err := h.store.GetDeployment(r.Context(), id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to get deployment")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get deployment")
+		return
+	}
+	if dep == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Deployment not found")
+		return
+	}
+
+	h.streamEvents(w, r, id)
+}
+
+// streamEvents sends events as Server-Sent Events until the client
+// disconnects, starting after the request's Last-Event-ID. Only events for
+// deploymentID are sent unless it is empty.
+func (h *Handler) streamEvents(w http.ResponseWriter, r *http.Request, deploymentID string) {
+	ctx := r.Context()
+
+	if h.events == nil {
+		writeError(w, http.StatusServiceUnavailable, "UNAVAILABLE", "Event streams are not enabled")
+		return
+	}
+
+	// Streams outlive the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Error().Err(err).Msg("Failed to clear write deadline for event stream")
+	}
+
+	backlog, live, complete, cancel := h.events.Subscribe(r.Header.Get("Last-Event-ID"))
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if !complete {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", resyncEvent)
+	}
+	for _, entry := range backlog {
+		if streamVisible(ctx, entry.Event, deploymentID) {
+			if err := writeStreamEntry(w, entry); err != nil {
+				return
+			}
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case entry, ok := <-live:
+			if !ok {
+				// Dropped for falling behind; the client reconnects and resumes
+				return
+			}
+			if !streamVisible(ctx, entry.Event, deploymentID) {
+				continue
+			}
+			if err := writeStreamEntry(w, entry); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeStreamEntry writes a log entry as a Server-Sent Event named after
+// the event type.
+func writeStreamEntry(w http.ResponseWriter, entry events.Entry) error {
+	data, err := json.Marshal(entry.Event)
+	if err != nil {
+		log.Error().Err(err).Str("event_type", string(entry.Event.Type)).Msg("Failed to encode event")
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", entry.ID, entry.Event.Type, data)
+	return err
+}
+
+// streamVisible reports whether an event belongs on a stream and the
+// request may see it. Instance events are checked against the instance's
+// labels, so label-scoped users and tokens only see their instances.
+func streamVisible(ctx context.Context, e events.Event, deploymentID string) bool {
+	if deploymentID != "" && e.DeploymentID() != deploymentID {
+		return false
+	}
+
+	switch data := e.Data.(type) {
+	case events.InstanceData:
+		return auth.Authorize(ctx, auth.ScopeInstancesRead, auth.InstanceResource(data.Labels))
+	case events.AssignmentConflictData:
+		return auth.Authorize(ctx, auth.ScopeInstancesRead, auth.InstanceResource(data.Labels))
+	case events.ConfigDriftedData:
+		return auth.Authorize(ctx, auth.ScopeInstancesRead, auth.InstanceResource(data.Labels))
+	case events.DeploymentInstanceData:
+		return auth.Authorize(ctx, auth.ScopeDeploymentsRead, auth.InstanceResource(data.Labels))
+	case events.ConfigResolvedData:
+		return auth.Authorize(ctx, auth.ScopeConfigsRead, auth.ConfigResource(data.ConfigName))
+	default:
+		return auth.HasPermission(ctx, auth.ScopeDeploymentsRead)
+	}
+}
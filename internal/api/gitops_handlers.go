@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/raskell-io/sentinel-hub/internal/auth"
+	"github.com/raskell-io/sentinel-hub/internal/gitops"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// GitOps Handlers
+// ============================================
+
+// visibleGitOpsStatus narrows a sync status to the files of configs the
+// caller may read.
+func visibleGitOpsStatus(r *http.Request, status gitops.Status) gitops.Status {
+	files := []gitops.FileStatus{}
+	for _, f := range status.Files {
+		if auth.Authorize(r.Context(), auth.ScopeConfigsRead, auth.ConfigResource(f.Config)) {
+			files = append(files, f)
+		}
+	}
+	status.Files = files
+	return status
+}
+
+// GetGitOpsStatus handles GET /api/v1/gitops
+func (h *Handler) GetGitOpsStatus(w http.ResponseWriter, r *http.Request) {
+	if h.gitops == nil {
+		writeError(w, http.StatusServiceUnavailable, "GITOPS_DISABLED", "GitOps sync is not configured")
+		return
+	}
+	writeJSON(w, http.StatusOK, visibleGitOpsStatus(r, h.gitops.Status()))
+}
+
+// SyncGitOps handles POST /api/v1/gitops/sync
+//
+// The sync runs with the hub's permissions, as the periodic one does; it
+// only applies what is already in the source sooner. Failed files are
+// reported in the returned status rather than as an error response.
+func (h *Handler) SyncGitOps(w http.ResponseWriter, r *http.Request) {
+	if h.gitops == nil {
+		writeError(w, http.StatusServiceUnavailable, "GITOPS_DISABLED", "GitOps sync is not configured")
+		return
+	}
+
+	status, err := h.gitops.Sync(r.Context())
+	if err != nil {
+		log.Warn().Err(err).Msg("GitOps sync failed")
+	}
+
+	h.auditLog(r, "sync", "gitops", status.Revision, map[string]interface{}{
+		"path":     status.Path,
+		"revision": status.Revision,
+		"error":    status.Error,
+	})
+	writeJSON(w, http.StatusOK, visibleGitOpsStatus(r, status))
+}
@@ -1,29 +1,76 @@
 package api
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/raskell-io/sentinel-hub/internal/audit"
 	"github.com/raskell-io/sentinel-hub/internal/auth"
+	"github.com/raskell-io/sentinel-hub/internal/compose"
+	"github.com/raskell-io/sentinel-hub/internal/diff"
+	"github.com/raskell-io/sentinel-hub/internal/events"
 	"github.com/raskell-io/sentinel-hub/internal/fleet"
+	"github.com/raskell-io/sentinel-hub/internal/gitops"
+	"github.com/raskell-io/sentinel-hub/internal/secrets"
 	"github.com/raskell-io/sentinel-hub/internal/store"
 	"github.com/rs/zerolog/log"
 )
 
 // Handler provides HTTP handlers for the Hub API.
 type Handler struct {
-	store        *store.Store
+	store        store.Store
 	orchestrator *fleet.Orchestrator
+	audit        *audit.Recorder
+	events       *events.Log
+	resolver     *compose.Resolver
+	secrets      *secrets.Keeper
+	gitops       *gitops.Syncer
 }
 
 // NewHandler creates a new Handler instance.
-func NewHandler(s *store.Store, o *fleet.Orchestrator) *Handler {
-	return &Handler{store: s, orchestrator: o}
+func NewHandler(s store.Store, o *fleet.Orchestrator) *Handler {
+	return &Handler{
+		store:        s,
+		orchestrator: o,
+		audit:        audit.NewRecorder(s, audit.RecorderConfig{}),
+		resolver:     compose.NewResolver(s),
+	}
+}
+
+// SetAuditRecorder sets the recorder audit logs are written through, so
+// that they also reach the configured audit sinks.
+func (h *Handler) SetAuditRecorder(r *audit.Recorder) {
+	h.audit = r
+}
+
+// SetEventLog sets the log that event streams are served from.
+func (h *Handler) SetEventLog(l *events.Log) {
+	h.events = l
+}
+
+// SetConfigResolver sets the resolver that keeps layered configs up to
+// date, so that it publishes to the configured event bus.
+func (h *Handler) SetConfigResolver(r *compose.Resolver) {
+	h.resolver = r
+}
+
+// SetSecretKeeper sets the keeper secrets are encrypted with. Without one,
+// secrets cannot be set.
+func (h *Handler) SetSecretKeeper(k *secrets.Keeper) {
+	h.secrets = k
+}
+
+// SetGitOpsSyncer sets the syncer whose status is served. Without one,
+// GitOps sync is reported as not configured.
+func (h *Handler) SetGitOpsSyncer(s *gitops.Syncer) {
+	h.gitops = s
 }
 
 // ErrorResponse represents an API error response.
@@ -71,7 +118,7 @@ func (h *Handler) auditLog(r *http.Request, action, resourceType, resourceID str
 		Details:      detailsJSON,
 	}
 
-	if err := h.store.CreateAuditLog(r.Context(), auditLog); err != nil {
+	if err := h.audit.Record(r.Context(), auditLog); err != nil {
 		log.Warn().Err(err).
 			Str("action", action).
 			Str("resource_type", resourceType).
@@ -99,6 +146,11 @@ func (h *Handler) ListInstances(w http.ResponseWriter, r *http.Request) {
 	if status := r.URL.Query().Get("status"); status != "" {
 		opts.Status = store.InstanceStatus(status)
 	}
+	if drifted := r.URL.Query().Get("drifted"); drifted != "" {
+		if d, err := strconv.ParseBool(drifted); err == nil {
+			opts.Drifted = &d
+		}
+	}
 	if limit := r.URL.Query().Get("limit"); limit != "" {
 		if l, err := strconv.Atoi(limit); err == nil {
 			opts.Limit = l
@@ -117,6 +169,15 @@ func (h *Handler) ListInstances(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Hide instances the caller may not read
+	visible := instances[:0]
+	for _, inst := range instances {
+		if auth.Authorize(ctx, auth.ScopeInstancesRead, auth.InstanceResource(inst.Labels)) {
+			visible = append(visible, inst)
+		}
+	}
+	instances = visible
+
 	if instances == nil {
 		instances = []store.Instance{}
 	}
@@ -156,6 +217,10 @@ func (h *Handler) CreateInstance(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "hostname is required")
 		return
 	}
+	if !auth.Authorize(ctx, auth.ScopeInstancesWrite, auth.InstanceResource(req.Labels)) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to create instances with these labels")
+		return
+	}
 
 	// Check if name already exists
 	existing, err := h.store.GetInstanceByName(ctx, req.Name)
@@ -202,7 +267,7 @@ func (h *Handler) GetInstance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if inst == nil {
+	if inst == nil || !auth.Authorize(ctx, auth.ScopeInstancesRead, auth.InstanceResource(inst.Labels)) {
 		writeError(w, http.StatusNotFound, "NOT_FOUND", "Instance not found")
 		return
 	}
@@ -218,6 +283,7 @@ type UpdateInstanceRequest struct {
 	SentinelVersion *string            `json:"sentinel_version,omitempty"`
 	Status          *string            `json:"status,omitempty"`
 	Labels          *map[string]string `json:"labels,omitempty"`
+	AutoFollow      *bool              `json:"auto_follow,omitempty"` // Follow the config's latest version
 }
 
 // UpdateInstance handles PUT /api/v1/instances/{id}
@@ -232,10 +298,14 @@ func (h *Handler) UpdateInstance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if inst == nil {
+	if inst == nil || !auth.Authorize(ctx, auth.ScopeInstancesRead, auth.InstanceResource(inst.Labels)) {
 		writeError(w, http.StatusNotFound, "NOT_FOUND", "Instance not found")
 		return
 	}
+	if !auth.Authorize(ctx, auth.ScopeInstancesWrite, auth.InstanceResource(inst.Labels)) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to update this instance")
+		return
+	}
 
 	var req UpdateInstanceRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -261,6 +331,19 @@ func (h *Handler) UpdateInstance(w http.ResponseWriter, r *http.Request) {
 	}
 	if req.Labels != nil {
 		inst.Labels = *req.Labels
+		if !auth.Authorize(ctx, auth.ScopeInstancesWrite, auth.InstanceResource(inst.Labels)) {
+			writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to set these labels")
+			return
+		}
+	}
+	if req.AutoFollow != nil && *req.AutoFollow != inst.AutoFollow {
+		// Following the latest version deploys every new version without a
+		// rollout, so it takes the right to deploy to the instance
+		if !auth.Authorize(ctx, auth.ScopeDeploymentsCreate, auth.InstanceResource(inst.Labels)) {
+			writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to change the auto-follow policy of this instance")
+			return
+		}
+		inst.AutoFollow = *req.AutoFollow
 	}
 
 	if err := h.store.UpdateInstance(ctx, inst); err != nil {
@@ -269,7 +352,7 @@ func (h *Handler) UpdateInstance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.auditLog(r, "update", "instance", inst.ID, map[string]string{"name": inst.Name})
+	h.auditLog(r, "update", "instance", inst.ID, map[string]interface{}{"name": inst.Name, "auto_follow": inst.AutoFollow})
 	writeJSON(w, http.StatusOK, inst)
 }
 
@@ -278,6 +361,17 @@ func (h *Handler) DeleteInstance(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	id := chi.URLParam(r, "id")
 
+	inst, err := h.store.GetInstance(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to get instance")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete instance")
+		return
+	}
+	if inst != nil && !auth.Authorize(ctx, auth.ScopeInstancesDelete, auth.InstanceResource(inst.Labels)) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to delete this instance")
+		return
+	}
+
 	if err := h.store.DeleteInstance(ctx, id); err != nil {
 		if err.Error() == "instance not found" {
 			writeError(w, http.StatusNotFound, "NOT_FOUND", "Instance not found")
@@ -325,6 +419,15 @@ func (h *Handler) ListConfigs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Hide configs the caller may not read
+	visible := configs[:0]
+	for _, cfg := range configs {
+		if auth.Authorize(ctx, auth.ScopeConfigsRead, auth.ConfigResource(cfg.Name)) {
+			visible = append(visible, cfg)
+		}
+	}
+	configs = visible
+
 	if configs == nil {
 		configs = []store.Config{}
 	}
@@ -337,9 +440,12 @@ func (h *Handler) ListConfigs(w http.ResponseWriter, r *http.Request) {
 
 // CreateConfigRequest represents the request body for creating a config.
 type CreateConfigRequest struct {
-	Name        string  `json:"name"`
-	Description *string `json:"description,omitempty"`
-	Content     string  `json:"content"` // KDL configuration content
+	Name        string   `json:"name"`
+	Description *string  `json:"description,omitempty"`
+	Content     string   `json:"content"`               // KDL configuration content
+	Template    bool     `json:"template,omitempty"`    // Render per instance on delivery
+	Layers      []string `json:"layers,omitempty"`      // Compose from these configs instead of content
+	AutoFollow  bool     `json:"auto_follow,omitempty"` // Instances follow the latest version
 }
 
 // CreateConfigResponse includes the config and its initial version.
@@ -362,10 +468,29 @@ func (h *Handler) CreateConfig(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "name is required")
 		return
 	}
-	if req.Content == "" {
+	if req.Content == "" && len(req.Layers) == 0 {
 		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "content is required")
 		return
 	}
+	if req.Content != "" && len(req.Layers) > 0 {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "content and layers cannot both be set")
+		return
+	}
+	if !auth.Authorize(ctx, auth.ScopeConfigsWrite, auth.ConfigResource(req.Name)) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to create a config with this name")
+		return
+	}
+	if req.AutoFollow && !auth.Authorize(ctx, auth.ScopeDeploymentsCreate, auth.ConfigResource(req.Name)) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to make instances follow this config")
+		return
+	}
+	if len(req.Layers) > 0 {
+		if _, ok := h.buildLayers(w, r, "", req.Layers); !ok {
+			return
+		}
+	} else if !validateTemplate(w, req.Template, req.Content) || !h.checkSecretReferences(w, r, req.Template, req.Content) {
+		return
+	}
 
 	// TODO: Validate KDL syntax
 
@@ -373,6 +498,7 @@ func (h *Handler) CreateConfig(w http.ResponseWriter, r *http.Request) {
 		ID:          uuid.New().String(),
 		Name:        req.Name,
 		Description: req.Description,
+		AutoFollow:  req.AutoFollow,
 	}
 
 	if err := h.store.CreateConfig(ctx, cfg); err != nil {
@@ -381,6 +507,11 @@ func (h *Handler) CreateConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if len(req.Layers) > 0 {
+		h.createComposedConfig(w, r, cfg, req.Layers)
+		return
+	}
+
 	// Create initial version
 	hash := sha256.Sum256([]byte(req.Content))
 	ver := &store.ConfigVersion{
@@ -389,6 +520,7 @@ func (h *Handler) CreateConfig(w http.ResponseWriter, r *http.Request) {
 		Version:     1,
 		Content:     req.Content,
 		ContentHash: hex.EncodeToString(hash[:]),
+		IsTemplate:  req.Template,
 	}
 
 	if err := h.store.CreateConfigVersion(ctx, ver); err != nil {
@@ -404,10 +536,40 @@ func (h *Handler) CreateConfig(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// createComposedConfig stores the layers of a newly created config and
+// resolves its first version from them.
+func (h *Handler) createComposedConfig(w http.ResponseWriter, r *http.Request, cfg *store.Config, layers []string) {
+	ctx := r.Context()
+
+	if err := h.store.SetConfigLayers(ctx, cfg.ID, layers); err != nil {
+		log.Error().Err(err).Msg("Failed to set config layers")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create config")
+		return
+	}
+
+	var createdBy *string
+	if user := auth.GetUserFromContext(ctx); user != nil {
+		createdBy = &user.ID
+	}
+	ver, err := h.resolver.Resolve(ctx, cfg, createdBy)
+	if err != nil || ver == nil {
+		log.Error().Err(err).Msg("Failed to resolve config")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create config version")
+		return
+	}
+
+	h.auditLog(r, "create", "config", cfg.ID, map[string]interface{}{"name": cfg.Name, "layers": layers})
+	writeJSON(w, http.StatusCreated, CreateConfigResponse{
+		Config:  *cfg,
+		Version: *ver,
+	})
+}
+
 // GetConfigResponse includes the config and its current version content.
 type GetConfigResponse struct {
 	Config         store.Config        `json:"config"`
 	CurrentVersion *store.ConfigVersion `json:"current_version,omitempty"`
+	Layers         []string             `json:"layers,omitempty"` // Set for composed configs
 }
 
 // GetConfig handles GET /api/v1/configs/{id}
@@ -422,7 +584,7 @@ func (h *Handler) GetConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if cfg == nil {
+	if cfg == nil || !auth.Authorize(ctx, auth.ScopeConfigsRead, auth.ConfigResource(cfg.Name)) {
 		writeError(w, http.StatusNotFound, "NOT_FOUND", "Config not found")
 		return
 	}
@@ -432,10 +594,15 @@ func (h *Handler) GetConfig(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		log.Error().Err(err).Str("id", id).Msg("Failed to get config version")
 	}
+	layers, err := h.store.ListConfigLayers(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to list config layers")
+	}
 
 	writeJSON(w, http.StatusOK, GetConfigResponse{
 		Config:         *cfg,
 		CurrentVersion: ver,
+		Layers:         layers,
 	})
 }
 
@@ -445,6 +612,8 @@ type UpdateConfigRequest struct {
 	Description   *string `json:"description,omitempty"`
 	Content       *string `json:"content,omitempty"` // If provided, creates a new version
 	ChangeSummary *string `json:"change_summary,omitempty"`
+	Template      *bool   `json:"template,omitempty"`    // Defaults to the current version's setting
+	AutoFollow    *bool   `json:"auto_follow,omitempty"` // Instances follow the latest version
 }
 
 // UpdateConfig handles PUT /api/v1/configs/{id}
@@ -459,10 +628,14 @@ func (h *Handler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if cfg == nil {
+	if cfg == nil || !auth.Authorize(ctx, auth.ScopeConfigsRead, auth.ConfigResource(cfg.Name)) {
 		writeError(w, http.StatusNotFound, "NOT_FOUND", "Config not found")
 		return
 	}
+	if !auth.Authorize(ctx, auth.ScopeConfigsWrite, auth.ConfigResource(cfg.Name)) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to update this config")
+		return
+	}
 
 	var req UpdateConfigRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -473,16 +646,50 @@ func (h *Handler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 	// Apply metadata updates
 	if req.Name != nil {
 		cfg.Name = *req.Name
+		if !auth.Authorize(ctx, auth.ScopeConfigsWrite, auth.ConfigResource(cfg.Name)) {
+			writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to rename the config to this name")
+			return
+		}
 	}
 	if req.Description != nil {
 		cfg.Description = req.Description
 	}
+	if req.AutoFollow != nil && *req.AutoFollow != cfg.AutoFollow {
+		// Following the latest version deploys every new version without a
+		// rollout, so it takes the right to deploy the config
+		if !auth.Authorize(ctx, auth.ScopeDeploymentsCreate, auth.ConfigResource(cfg.Name)) {
+			writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to change the auto-follow policy of this config")
+			return
+		}
+		cfg.AutoFollow = *req.AutoFollow
+	}
 
 	// If content is provided, create a new version
 	var newVersion *store.ConfigVersion
 	if req.Content != nil {
+		layers, err := h.store.ListConfigLayers(ctx, cfg.ID)
+		if err != nil {
+			log.Error().Err(err).Str("id", id).Msg("Failed to list config layers")
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update config")
+			return
+		}
+		if len(layers) > 0 {
+			writeError(w, http.StatusConflict, "CONFLICT", "Config is composed from layers; update a layer or its layer list instead")
+			return
+		}
+
 		// TODO: Validate KDL syntax
 
+		isTemplate := false
+		if req.Template != nil {
+			isTemplate = *req.Template
+		} else if current, err := h.store.GetConfigVersion(ctx, cfg.ID, cfg.CurrentVersion); err == nil && current != nil {
+			isTemplate = current.IsTemplate
+		}
+		if !validateTemplate(w, isTemplate, *req.Content) || !h.checkSecretReferences(w, r, isTemplate, *req.Content) {
+			return
+		}
+
 		hash := sha256.Sum256([]byte(*req.Content))
 		newVersion = &store.ConfigVersion{
 			ID:            uuid.New().String(),
@@ -490,6 +697,7 @@ func (h *Handler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 			Version:       cfg.CurrentVersion + 1,
 			Content:       *req.Content,
 			ContentHash:   hex.EncodeToString(hash[:]),
+			IsTemplate:    isTemplate,
 			ChangeSummary: req.ChangeSummary,
 		}
 
@@ -507,10 +715,14 @@ func (h *Handler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update config")
 		return
 	}
+	if newVersion != nil {
+		h.layersChanged(r, cfg)
+	}
 
 	h.auditLog(r, "update", "config", cfg.ID, map[string]interface{}{
 		"name":        cfg.Name,
 		"new_version": newVersion != nil,
+		"auto_follow": cfg.AutoFollow,
 	})
 	writeJSON(w, http.StatusOK, GetConfigResponse{
 		Config:         *cfg,
@@ -523,6 +735,41 @@ func (h *Handler) DeleteConfig(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	id := chi.URLParam(r, "id")
 
+	cfg, err := h.store.GetConfig(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to get config")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete config")
+		return
+	}
+	if cfg != nil && !auth.Authorize(ctx, auth.ScopeConfigsDelete, auth.ConfigResource(cfg.Name)) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to delete this config")
+		return
+	}
+	if cfg != nil {
+		dependents, err := h.store.ListConfigDependents(ctx, id)
+		if err != nil {
+			log.Error().Err(err).Str("id", id).Msg("Failed to list config dependents")
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete config")
+			return
+		}
+		if len(dependents) > 0 {
+			writeError(w, http.StatusConflict, "CONFLICT", "Config is a layer of other configs; remove it from their layers first")
+			return
+		}
+		assignments, err := h.store.ListConfigAssignments(ctx)
+		if err != nil {
+			log.Error().Err(err).Str("id", id).Msg("Failed to list config assignments")
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete config")
+			return
+		}
+		for _, a := range assignments {
+			if a.ConfigID == id {
+				writeError(w, http.StatusConflict, "CONFLICT", "Config is assigned to instances by assignment "+a.Name+"; delete the assignment first")
+				return
+			}
+		}
+	}
+
 	if err := h.store.DeleteConfig(ctx, id); err != nil {
 		if err.Error() == "config not found" {
 			writeError(w, http.StatusNotFound, "NOT_FOUND", "Config not found")
@@ -555,7 +802,7 @@ func (h *Handler) ListConfigVersions(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get config")
 		return
 	}
-	if cfg == nil {
+	if cfg == nil || !auth.Authorize(ctx, auth.ScopeConfigsRead, auth.ConfigResource(cfg.Name)) {
 		writeError(w, http.StatusNotFound, "NOT_FOUND", "Config not found")
 		return
 	}
@@ -577,6 +824,77 @@ func (h *Handler) ListConfigVersions(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// ConfigDiffResponse represents the response for diffing two config versions.
+type ConfigDiffResponse struct {
+	ConfigID    string `json:"config_id"`
+	FromVersion int    `json:"from_version"`
+	ToVersion   int    `json:"to_version"`
+	*diff.ConfigDiff
+}
+
+// DiffConfigVersions handles GET /api/v1/configs/{id}/diff?from=3&to=5
+//
+// to defaults to the current version and from to the version before it.
+func (h *Handler) DiffConfigVersions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	cfg, err := h.store.GetConfig(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to get config")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get config")
+		return
+	}
+	if cfg == nil || !auth.Authorize(ctx, auth.ScopeConfigsRead, auth.ConfigResource(cfg.Name)) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Config not found")
+		return
+	}
+
+	to := cfg.CurrentVersion
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = strconv.Atoi(v); err != nil || to <= 0 {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "to must be a positive version number")
+			return
+		}
+	}
+	from := to - 1
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = strconv.Atoi(v); err != nil || from <= 0 {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "from must be a positive version number")
+			return
+		}
+	}
+	if from <= 0 {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "from is required when diffing the first version")
+		return
+	}
+
+	versions := make(map[int]*store.ConfigVersion, 2)
+	for _, v := range []int{from, to} {
+		ver, err := h.store.GetConfigVersion(ctx, id, v)
+		if err != nil {
+			log.Error().Err(err).Str("id", id).Int("version", v).Msg("Failed to get config version")
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to diff config versions")
+			return
+		}
+		if ver == nil {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Version "+strconv.Itoa(v)+" not found")
+			return
+		}
+		versions[v] = ver
+	}
+
+	writeJSON(w, http.StatusOK, ConfigDiffResponse{
+		ConfigID:    id,
+		FromVersion: from,
+		ToVersion:   to,
+		ConfigDiff: diff.Compare(
+			cfg.Name+" v"+strconv.Itoa(from), cfg.Name+" v"+strconv.Itoa(to),
+			versions[from].Content, versions[to].Content,
+		),
+	})
+}
+
 // RollbackConfigRequest represents the request body for rolling back a config.
 type RollbackConfigRequest struct {
 	Version int `json:"version"`
@@ -593,10 +911,14 @@ func (h *Handler) RollbackConfig(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to rollback config")
 		return
 	}
-	if cfg == nil {
+	if cfg == nil || !auth.Authorize(ctx, auth.ScopeConfigsRead, auth.ConfigResource(cfg.Name)) {
 		writeError(w, http.StatusNotFound, "NOT_FOUND", "Config not found")
 		return
 	}
+	if !auth.Authorize(ctx, auth.ScopeConfigsWrite, auth.ConfigResource(cfg.Name)) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to roll back this config")
+		return
+	}
 
 	var req RollbackConfigRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -630,6 +952,8 @@ func (h *Handler) RollbackConfig(w http.ResponseWriter, r *http.Request) {
 		Version:       cfg.CurrentVersion + 1,
 		Content:       targetVersion.Content,
 		ContentHash:   hex.EncodeToString(hash[:]),
+		IsTemplate:    targetVersion.IsTemplate,
+		Layers:        targetVersion.Layers,
 		ChangeSummary: &summary,
 	}
 
@@ -645,6 +969,7 @@ func (h *Handler) RollbackConfig(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to rollback config")
 		return
 	}
+	h.layersChanged(r, cfg)
 
 	h.auditLog(r, "rollback", "config", cfg.ID, map[string]interface{}{
 		"from_version": cfg.CurrentVersion - 1,
@@ -711,6 +1036,7 @@ type CreateDeploymentRequest struct {
 	TargetLabels    map[string]string `json:"target_labels,omitempty"`  // Label selector (alternative to instance IDs)
 	Strategy        string            `json:"strategy,omitempty"`       // all_at_once, rolling, canary
 	BatchSize       int               `json:"batch_size,omitempty"`
+	Preview         bool              `json:"preview,omitempty"` // Return the diff per target instead of deploying
 }
 
 // CreateDeployment handles POST /api/v1/deployments
@@ -732,6 +1058,16 @@ func (h *Handler) CreateDeployment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Narrow label selectors to a label-restricted API token
+	if len(req.TargetInstances) == 0 {
+		labels, ok := auth.RestrictLabelSelector(ctx, req.TargetLabels)
+		if !ok {
+			writeError(w, http.StatusForbidden, "FORBIDDEN", "target_labels conflict with the token's label restriction")
+			return
+		}
+		req.TargetLabels = labels
+	}
+
 	// Determine config version
 	configVersion := 0
 	if req.ConfigVersion != nil {
@@ -744,15 +1080,39 @@ func (h *Handler) CreateDeployment(w http.ResponseWriter, r *http.Request) {
 		strategy = store.DeploymentStrategy(req.Strategy)
 	}
 
-	// Use orchestrator to create and start deployment
-	dep, err := h.orchestrator.CreateDeployment(ctx, fleet.CreateDeploymentRequest{
+	deploymentReq := fleet.CreateDeploymentRequest{
 		ConfigID:        req.ConfigID,
 		ConfigVersion:   configVersion,
 		TargetInstances: req.TargetInstances,
 		TargetLabels:    req.TargetLabels,
 		Strategy:        strategy,
 		BatchSize:       req.BatchSize,
-	})
+		Authorize: func(inst *store.Instance, cfg *store.Config) bool {
+			return auth.Authorize(ctx, auth.ScopeDeploymentsCreate, auth.DeploymentResource(inst.Labels, cfg.Name))
+		},
+	}
+
+	if req.Preview {
+		preview, err := h.orchestrator.PreviewDeployment(ctx, deploymentReq)
+		if errors.Is(err, fleet.ErrTargetNotPermitted) {
+			writeError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to preview deployment")
+			writeError(w, http.StatusBadRequest, "DEPLOYMENT_ERROR", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, preview)
+		return
+	}
+
+	// Use orchestrator to create and start deployment
+	dep, err := h.orchestrator.CreateDeployment(ctx, deploymentReq)
+	if errors.Is(err, fleet.ErrTargetNotPermitted) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+		return
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create deployment")
 		writeError(w, http.StatusBadRequest, "DEPLOYMENT_ERROR", err.Error())
@@ -797,6 +1157,25 @@ func (h *Handler) CancelDeployment(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	id := chi.URLParam(r, "id")
 
+	dep, err := h.store.GetDeployment(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to get deployment")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to cancel deployment")
+		return
+	}
+	if dep != nil {
+		allowed, err := h.authorizeDeployment(ctx, dep, auth.ScopeDeploymentsCancel)
+		if err != nil {
+			log.Error().Err(err).Str("id", id).Msg("Failed to check deployment permissions")
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to cancel deployment")
+			return
+		}
+		if !allowed {
+			writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to cancel this deployment")
+			return
+		}
+	}
+
 	// Use orchestrator to cancel the deployment (stops running tasks)
 	if err := h.orchestrator.CancelDeployment(ctx, id); err != nil {
 		log.Error().Err(err).Str("id", id).Msg("Failed to cancel deployment")
@@ -815,3 +1194,30 @@ func (h *Handler) CancelDeployment(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, status.Deployment)
 }
+
+// authorizeDeployment reports whether the request holds the permission for
+// every target instance of a deployment.
+func (h *Handler) authorizeDeployment(ctx context.Context, dep *store.Deployment, permission string) (bool, error) {
+	cfg, err := h.store.GetConfig(ctx, dep.ConfigID)
+	if err != nil {
+		return false, err
+	}
+	configName := ""
+	if cfg != nil {
+		configName = cfg.Name
+	}
+
+	for _, id := range dep.TargetInstances {
+		inst, err := h.store.GetInstance(ctx, id)
+		if err != nil {
+			return false, err
+		}
+		if inst == nil {
+			continue // Deleted since the deployment was created
+		}
+		if !auth.Authorize(ctx, permission, auth.DeploymentResource(inst.Labels, configName)) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
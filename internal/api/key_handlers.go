@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/raskell-io/sentinel-hub/internal/auth"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// Signing Key Handlers
+// ============================================
+
+// ListSigningKeysResponse represents the response for listing signing keys.
+type ListSigningKeysResponse struct {
+	Keys  []store.SigningKey `json:"keys"`
+	Total int                `json:"total"`
+}
+
+// JWKS handles GET /.well-known/jwks.json
+func (h *AuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	// Verifiers refetch on an unknown kid, so a short cache is enough
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, h.authService.JWKS())
+}
+
+// ListSigningKeys handles GET /api/v1/signing-keys
+func (h *UserHandler) ListSigningKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.authService.SigningKeys()
+	if err != nil {
+		if err == auth.ErrKeyRingNotConfigured {
+			writeError(w, http.StatusNotFound, "NOT_CONFIGURED", "Tokens are signed with a shared secret")
+			return
+		}
+		log.Error().Err(err).Msg("Failed to list signing keys")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list signing keys")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ListSigningKeysResponse{
+		Keys:  keys,
+		Total: len(keys),
+	})
+}
+
+// RotateSigningKey handles POST /api/v1/signing-keys/rotate
+func (h *UserHandler) RotateSigningKey(w http.ResponseWriter, r *http.Request) {
+	key, err := h.authService.RotateSigningKey(r.Context())
+	if err != nil {
+		if err == auth.ErrKeyRingNotConfigured {
+			writeError(w, http.StatusNotFound, "NOT_CONFIGURED", "Tokens are signed with a shared secret")
+			return
+		}
+		log.Error().Err(err).Msg("Failed to rotate signing key")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to rotate signing key")
+		return
+	}
+
+	h.auditLog(r, "rotate", "signing_key", key.ID, map[string]string{"algorithm": key.Algorithm})
+	writeJSON(w, http.StatusCreated, key)
+}
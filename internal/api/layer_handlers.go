@@ -0,0 +1,200 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/raskell-io/sentinel-hub/internal/auth"
+	"github.com/raskell-io/sentinel-hub/internal/compose"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// Layered Config Handlers
+// ============================================
+
+// SetConfigLayersRequest represents the request body for setting the layers
+// a config is composed from.
+type SetConfigLayersRequest struct {
+	Layers []string `json:"layers"` // Config IDs, base first
+}
+
+// ListConfigDependentsResponse represents the response for listing the
+// configs composed from a config.
+type ListConfigDependentsResponse struct {
+	Dependents []compose.Dependent `json:"dependents"`
+	Total      int                 `json:"total"`
+}
+
+// buildLayers checks that the caller can read every layer, that the layers
+// can be combined and that the caller may use the secrets the result refers
+// to, writing an error response if not.
+func (h *Handler) buildLayers(w http.ResponseWriter, r *http.Request, configID string, layerIDs []string) (*compose.Result, bool) {
+	ctx := r.Context()
+
+	for _, id := range layerIDs {
+		layer, err := h.store.GetConfig(ctx, id)
+		if err != nil {
+			log.Error().Err(err).Str("id", id).Msg("Failed to get config")
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get layer config")
+			return nil, false
+		}
+		if layer == nil || !auth.Authorize(ctx, auth.ScopeConfigsRead, auth.ConfigResource(layer.Name)) {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Layer config "+id+" not found")
+			return nil, false
+		}
+	}
+
+	if err := h.resolver.CheckLayers(ctx, configID, layerIDs); err != nil {
+		if errors.Is(err, compose.ErrInvalidLayers) {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+			return nil, false
+		}
+		log.Error().Err(err).Msg("Failed to check config layers")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to check config layers")
+		return nil, false
+	}
+
+	res, err := h.resolver.Build(ctx, layerIDs)
+	if err != nil {
+		if errors.Is(err, compose.ErrResolve) {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+			return nil, false
+		}
+		log.Error().Err(err).Msg("Failed to resolve config layers")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to resolve config layers")
+		return nil, false
+	}
+
+	// Layers may refer to secrets the caller cannot use
+	if !h.checkSecretReferences(w, r, res.IsTemplate, res.Content) {
+		return nil, false
+	}
+	return res, true
+}
+
+// layersChanged re-resolves the configs composed from cfg after it got a
+// new version. Failures leave those configs on their previous version and
+// are logged by the resolver, so they do not fail the request.
+func (h *Handler) layersChanged(r *http.Request, cfg *store.Config) {
+	var createdBy *string
+	if user := auth.GetUserFromContext(r.Context()); user != nil {
+		createdBy = &user.ID
+	}
+	if _, err := h.resolver.LayerChanged(r.Context(), cfg.ID, createdBy); err != nil {
+		log.Warn().Err(err).Str("config_id", cfg.ID).Msg("Some composed configs could not be resolved")
+	}
+}
+
+// SetConfigLayers handles PUT /api/v1/configs/{id}/layers
+//
+// The config gets a new version resolved from the layers. An empty list
+// turns it back into a plain config that keeps its current content.
+func (h *Handler) SetConfigLayers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	cfg, err := h.store.GetConfig(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to get config")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to set config layers")
+		return
+	}
+	if cfg == nil || !auth.Authorize(ctx, auth.ScopeConfigsRead, auth.ConfigResource(cfg.Name)) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Config not found")
+		return
+	}
+	if !auth.Authorize(ctx, auth.ScopeConfigsWrite, auth.ConfigResource(cfg.Name)) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to update this config")
+		return
+	}
+
+	var req SetConfigLayersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+		return
+	}
+	if len(req.Layers) > 0 {
+		if _, ok := h.buildLayers(w, r, cfg.ID, req.Layers); !ok {
+			return
+		}
+	}
+
+	if err := h.store.SetConfigLayers(ctx, cfg.ID, req.Layers); err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to set config layers")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to set config layers")
+		return
+	}
+
+	var createdBy *string
+	if user := auth.GetUserFromContext(ctx); user != nil {
+		createdBy = &user.ID
+	}
+	newVersion, err := h.resolver.Resolve(ctx, cfg, createdBy)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to resolve config")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to resolve config")
+		return
+	}
+	if newVersion != nil {
+		h.layersChanged(r, cfg)
+	}
+
+	h.auditLog(r, "update", "config", cfg.ID, map[string]interface{}{
+		"name":        cfg.Name,
+		"layers":      req.Layers,
+		"new_version": newVersion != nil,
+	})
+
+	layers := req.Layers
+	if len(layers) == 0 {
+		layers = nil
+	}
+	writeJSON(w, http.StatusOK, GetConfigResponse{
+		Config:         *cfg,
+		CurrentVersion: newVersion,
+		Layers:         layers,
+	})
+}
+
+// ListConfigDependents handles GET /api/v1/configs/{id}/dependents
+//
+// Dependents are the configs composed from this one, directly or through
+// other composed configs, with the instances that still need a redeploy.
+func (h *Handler) ListConfigDependents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	cfg, err := h.store.GetConfig(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to get config")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get config")
+		return
+	}
+	if cfg == nil || !auth.Authorize(ctx, auth.ScopeConfigsRead, auth.ConfigResource(cfg.Name)) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Config not found")
+		return
+	}
+
+	dependents, err := h.resolver.Dependents(ctx, cfg.ID)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to list config dependents")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list config dependents")
+		return
+	}
+
+	visible := []compose.Dependent{}
+	for _, d := range dependents {
+		if auth.Authorize(ctx, auth.ScopeConfigsRead, auth.ConfigResource(d.Name)) {
+			visible = append(visible, d)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, ListConfigDependentsResponse{
+		Dependents: visible,
+		Total:      len(visible),
+	})
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/raskell-io/sentinel-hub/internal/auth"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// MFA Handlers
+// ============================================
+
+// MFAChallengeResponse is returned by login when a second factor is required.
+type MFAChallengeResponse struct {
+	MFARequired           bool   `json:"mfa_required"`
+	MFAToken              string `json:"mfa_token"`
+	MFAEnrollmentRequired bool   `json:"mfa_enrollment_required"`
+}
+
+// MFAVerifyRequest represents the request body for verifying an MFA code.
+type MFAVerifyRequest struct {
+	Code     string `json:"code"`
+	MFAToken string `json:"mfa_token,omitempty"` // Set when completing a login
+}
+
+// MFARecoveryCodesResponse returns recovery codes, which are only shown once.
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// mfaUser returns the user for an MFA endpoint, authenticated either with an
+// access token or with the mfa_token of a login that requires enrollment.
+func (h *AuthHandler) mfaUser(w http.ResponseWriter, r *http.Request) (*store.User, bool) {
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || parts[1] == "" {
+		writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Missing or invalid authorization header")
+		return nil, false
+	}
+
+	user, err := h.authService.GetUserFromToken(r.Context(), parts[1])
+	if errors.Is(err, auth.ErrInvalidTokenType) {
+		user, err = h.authService.GetUserFromMFAToken(r.Context(), parts[1])
+	}
+	if err != nil {
+		switch err {
+		case auth.ErrTokenExpired:
+			writeError(w, http.StatusUnauthorized, "TOKEN_EXPIRED", "Token has expired")
+		case auth.ErrInvalidToken, auth.ErrInvalidTokenType, auth.ErrUserNotFound:
+			writeError(w, http.StatusUnauthorized, "INVALID_TOKEN", "Invalid token")
+		default:
+			log.Error().Err(err).Msg("Failed to authenticate mfa request")
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Authentication failed")
+		}
+		return nil, false
+	}
+	return user, true
+}
+
+// MFAEnroll handles POST /api/v1/auth/mfa/enroll
+func (h *AuthHandler) MFAEnroll(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.mfaUser(w, r)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.authService.BeginMFAEnrollment(r.Context(), user)
+	if err != nil {
+		if err == auth.ErrMFAAlreadyEnabled {
+			writeError(w, http.StatusConflict, "MFA_ALREADY_ENABLED", "MFA is already enabled for this account")
+			return
+		}
+		log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to start mfa enrollment")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to start MFA enrollment")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, enrollment)
+}
+
+// MFAVerify handles POST /api/v1/auth/mfa/verify
+//
+// With an mfa_token it completes a login and returns a token pair. Otherwise
+// it confirms the authenticated user's pending enrollment.
+func (h *AuthHandler) MFAVerify(w http.ResponseWriter, r *http.Request) {
+	var req MFAVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+		return
+	}
+
+	if req.Code == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "code is required")
+		return
+	}
+
+	if req.MFAToken != "" {
+		h.verifyMFALogin(w, r, req)
+		return
+	}
+
+	user, ok := h.mfaUser(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.authService.ConfirmMFAEnrollment(r.Context(), user, req.Code)
+	if err != nil {
+		switch err {
+		case auth.ErrInvalidMFACode:
+			writeError(w, http.StatusBadRequest, "INVALID_MFA_CODE", "Invalid MFA code")
+		case auth.ErrMFANotEnrolled:
+			writeError(w, http.StatusBadRequest, "MFA_NOT_ENROLLED", "Start enrollment before verifying")
+		case auth.ErrMFAAlreadyEnabled:
+			writeError(w, http.StatusConflict, "MFA_ALREADY_ENABLED", "MFA is already enabled for this account")
+		default:
+			log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to confirm mfa enrollment")
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to verify MFA code")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MFARecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// verifyMFALogin completes the second step of a login.
+func (h *AuthHandler) verifyMFALogin(w http.ResponseWriter, r *http.Request, req MFAVerifyRequest) {
+	ipAddress, userAgent := clientInfo(r)
+
+	tokenPair, codes, err := h.authService.VerifyMFALogin(r.Context(), req.MFAToken, req.Code, ipAddress, userAgent)
+	if err != nil {
+		if writeLockedError(w, err) {
+			return
+		}
+		switch err {
+		case auth.ErrInvalidMFACode:
+			writeError(w, http.StatusUnauthorized, "INVALID_MFA_CODE", "Invalid MFA code")
+		case auth.ErrInvalidToken, auth.ErrInvalidTokenType, auth.ErrUserNotFound:
+			writeError(w, http.StatusUnauthorized, "INVALID_TOKEN", "Invalid mfa_token")
+		case auth.ErrTokenExpired:
+			writeError(w, http.StatusUnauthorized, "TOKEN_EXPIRED", "mfa_token has expired, log in again")
+		case auth.ErrMFANotEnrolled:
+			writeError(w, http.StatusBadRequest, "MFA_NOT_ENROLLED", "Start enrollment before verifying")
+		default:
+			log.Error().Err(err).Msg("MFA login failed")
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Login failed")
+		}
+		return
+	}
+
+	user, err := h.authService.GetUserFromToken(r.Context(), tokenPair.AccessToken)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get user after login")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Login failed")
+		return
+	}
+
+	resp := newLoginResponse(tokenPair, user)
+	resp.RecoveryCodes = codes
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// ResetMFA handles DELETE /api/v1/users/{id}/mfa
+func (h *UserHandler) ResetMFA(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	if err := h.authService.ResetMFA(ctx, id); err != nil {
+		if err == auth.ErrMFANotEnrolled {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "User has no MFA enrollment")
+			return
+		}
+		log.Error().Err(err).Str("id", id).Msg("Failed to reset mfa")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to reset MFA")
+		return
+	}
+
+	h.auditLog(r, "reset_mfa", "user", id, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,276 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/raskell-io/sentinel-hub/internal/auth"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// Role Handlers
+// ============================================
+
+// RoleRequest represents the request body for creating or updating a role.
+type RoleRequest struct {
+	Name        string                 `json:"name"`
+	Description *string                `json:"description,omitempty"`
+	Permissions []store.RolePermission `json:"permissions"`
+}
+
+// ListRolesResponse represents the response for listing roles.
+type ListRolesResponse struct {
+	Roles []store.Role `json:"roles"`
+	Total int          `json:"total"`
+}
+
+// validate checks the role request and writes an error response if invalid.
+func (req *RoleRequest) validate(w http.ResponseWriter) bool {
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "name is required")
+		return false
+	}
+	if len(req.Permissions) == 0 {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "at least one permission is required")
+		return false
+	}
+	if err := auth.ValidateRolePermissions(req.Permissions); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return false
+	}
+	return true
+}
+
+// ListRoles handles GET /api/v1/roles
+func (h *UserHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.store.ListRoles(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list roles")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list roles")
+		return
+	}
+
+	if roles == nil {
+		roles = []store.Role{}
+	}
+
+	writeJSON(w, http.StatusOK, ListRolesResponse{
+		Roles: roles,
+		Total: len(roles),
+	})
+}
+
+// CreateRole handles POST /api/v1/roles
+func (h *UserHandler) CreateRole(w http.ResponseWriter, r *http.Request) {
+	var req RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+		return
+	}
+	if !req.validate(w) {
+		return
+	}
+
+	role := &store.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	}
+
+	if err := h.store.CreateRole(r.Context(), role); err != nil {
+		if err.Error() == "role with this name already exists" {
+			writeError(w, http.StatusConflict, "ALREADY_EXISTS", "Role with this name already exists")
+			return
+		}
+		log.Error().Err(err).Msg("Failed to create role")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create role")
+		return
+	}
+
+	h.auditLog(r, "create", "role", role.ID, role)
+	writeJSON(w, http.StatusCreated, role)
+}
+
+// GetRole handles GET /api/v1/roles/{id}
+func (h *UserHandler) GetRole(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	role, err := h.store.GetRole(r.Context(), id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to get role")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get role")
+		return
+	}
+
+	if role == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Role not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, role)
+}
+
+// UpdateRole handles PUT /api/v1/roles/{id}
+func (h *UserHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	var req RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+		return
+	}
+	if !req.validate(w) {
+		return
+	}
+
+	role := &store.Role{
+		ID:          id,
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	}
+
+	if err := h.store.UpdateRole(ctx, role); err != nil {
+		switch err.Error() {
+		case "role not found":
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Role not found")
+		case "role with this name already exists":
+			writeError(w, http.StatusConflict, "ALREADY_EXISTS", "Role with this name already exists")
+		default:
+			log.Error().Err(err).Str("id", id).Msg("Failed to update role")
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update role")
+		}
+		return
+	}
+
+	updated, err := h.store.GetRole(ctx, id)
+	if err != nil || updated == nil {
+		updated = role
+	}
+
+	h.auditLog(r, "update", "role", id, role)
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// DeleteRole handles DELETE /api/v1/roles/{id}
+func (h *UserHandler) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := h.store.DeleteRole(r.Context(), id); err != nil {
+		if err.Error() == "role not found" {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Role not found")
+			return
+		}
+		log.Error().Err(err).Str("id", id).Msg("Failed to delete role")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete role")
+		return
+	}
+
+	h.auditLog(r, "delete", "role", id, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ============================================
+// Role Binding Handlers
+// ============================================
+
+// BindRoleRequest represents the request body for binding a role to a user.
+type BindRoleRequest struct {
+	RoleID string `json:"role_id"`
+}
+
+// ListUserRoles handles GET /api/v1/users/{id}/roles
+func (h *UserHandler) ListUserRoles(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	roles, err := h.store.ListUserRoles(r.Context(), id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to list user roles")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list user roles")
+		return
+	}
+
+	if roles == nil {
+		roles = []store.Role{}
+	}
+
+	writeJSON(w, http.StatusOK, ListRolesResponse{
+		Roles: roles,
+		Total: len(roles),
+	})
+}
+
+// BindRole handles POST /api/v1/users/{id}/roles
+func (h *UserHandler) BindRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := chi.URLParam(r, "id")
+
+	var req BindRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+		return
+	}
+	if req.RoleID == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "role_id is required")
+		return
+	}
+
+	user, err := h.store.GetUser(ctx, userID)
+	if err != nil {
+		log.Error().Err(err).Str("id", userID).Msg("Failed to get user")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to bind role")
+		return
+	}
+	if user == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "User not found")
+		return
+	}
+
+	role, err := h.store.GetRole(ctx, req.RoleID)
+	if err != nil {
+		log.Error().Err(err).Str("role_id", req.RoleID).Msg("Failed to get role")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to bind role")
+		return
+	}
+	if role == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Role not found")
+		return
+	}
+
+	binding := &store.RoleBinding{UserID: user.ID, RoleID: role.ID}
+	if currentUser := auth.GetUserFromContext(ctx); currentUser != nil {
+		binding.CreatedBy = &currentUser.ID
+	}
+
+	if err := h.store.CreateRoleBinding(ctx, binding); err != nil {
+		log.Error().Err(err).Str("id", userID).Msg("Failed to bind role")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to bind role")
+		return
+	}
+
+	h.auditLog(r, "bind_role", "user", user.ID, map[string]string{"role_id": role.ID, "role": role.Name})
+	writeJSON(w, http.StatusCreated, binding)
+}
+
+// UnbindRole handles DELETE /api/v1/users/{id}/roles/{roleID}
+func (h *UserHandler) UnbindRole(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+	roleID := chi.URLParam(r, "roleID")
+
+	if err := h.store.DeleteRoleBinding(r.Context(), userID, roleID); err != nil {
+		if err.Error() == "role binding not found" {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Role binding not found")
+			return
+		}
+		log.Error().Err(err).Str("id", userID).Msg("Failed to unbind role")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to unbind role")
+		return
+	}
+
+	h.auditLog(r, "unbind_role", "user", userID, map[string]string{"role_id": roleID})
+	w.WriteHeader(http.StatusNoContent)
+}
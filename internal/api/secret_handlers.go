@@ -0,0 +1,182 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/raskell-io/sentinel-hub/internal/auth"
+	"github.com/raskell-io/sentinel-hub/internal/secrets"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// Secret Handlers
+// ============================================
+
+// secretNamePattern keeps secret names simple enough to write unquoted in
+// paths and readable in configs.
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// SetSecretRequest represents the request body for setting a secret.
+type SetSecretRequest struct {
+	Value       string  `json:"value"`
+	Description *string `json:"description,omitempty"`
+}
+
+// ListSecretsResponse represents the response for listing secrets. Only
+// metadata is returned; secret values cannot be read back.
+type ListSecretsResponse struct {
+	Secrets []store.Secret `json:"secrets"`
+	Total   int            `json:"total"`
+}
+
+// checkSecretReferences checks that every secret a config refers to exists
+// and may be used by the caller, writing an error response if not.
+// Templates must name their secrets literally.
+func (h *Handler) checkSecretReferences(w http.ResponseWriter, r *http.Request, isTemplate bool, content string) bool {
+	ctx := r.Context()
+	names := secrets.References(content)
+	if isTemplate {
+		var err error
+		if names, err = secrets.TemplateReferences(content); err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+			return false
+		}
+	}
+	for _, name := range names {
+		if !auth.Authorize(ctx, auth.ScopeSecretsRead, auth.SecretResource(name)) {
+			writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to use secret "+name)
+			return false
+		}
+		secret, err := h.store.GetSecret(ctx, name)
+		if err != nil {
+			log.Error().Err(err).Str("name", name).Msg("Failed to get secret")
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to check secret references")
+			return false
+		}
+		if secret == nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Secret "+name+" does not exist")
+			return false
+		}
+	}
+	return true
+}
+
+// ListSecrets handles GET /api/v1/secrets
+func (h *Handler) ListSecrets(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	all, err := h.store.ListSecrets(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list secrets")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list secrets")
+		return
+	}
+
+	visible := []store.Secret{}
+	for _, secret := range all {
+		if auth.Authorize(ctx, auth.ScopeSecretsRead, auth.SecretResource(secret.Name)) {
+			visible = append(visible, secret)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, ListSecretsResponse{
+		Secrets: visible,
+		Total:   len(visible),
+	})
+}
+
+// GetSecret handles GET /api/v1/secrets/{name}
+func (h *Handler) GetSecret(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	name := chi.URLParam(r, "name")
+
+	secret, err := h.store.GetSecret(ctx, name)
+	if err != nil {
+		log.Error().Err(err).Str("name", name).Msg("Failed to get secret")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get secret")
+		return
+	}
+	if secret == nil || !auth.Authorize(ctx, auth.ScopeSecretsRead, auth.SecretResource(name)) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Secret not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, secret)
+}
+
+// SetSecret handles PUT /api/v1/secrets/{name}
+//
+// It creates the secret or replaces its value. Agents pick up the new value
+// on their next heartbeat, as it changes the hash of configs using it.
+func (h *Handler) SetSecret(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	name := chi.URLParam(r, "name")
+
+	if h.secrets == nil {
+		writeError(w, http.StatusServiceUnavailable, "SECRETS_DISABLED", "Secrets are not configured")
+		return
+	}
+	if !secretNamePattern.MatchString(name) {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "name must start with a letter or digit and contain only letters, digits, '.', '_' and '-'")
+		return
+	}
+	if !auth.Authorize(ctx, auth.ScopeSecretsWrite, auth.SecretResource(name)) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to set this secret")
+		return
+	}
+
+	var req SetSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+		return
+	}
+	if req.Value == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "value is required")
+		return
+	}
+
+	var updatedBy *string
+	if user := auth.GetUserFromContext(ctx); user != nil {
+		updatedBy = &user.ID
+	}
+	secret, err := h.secrets.Set(ctx, name, req.Description, req.Value, updatedBy)
+	if err != nil {
+		log.Error().Err(err).Str("name", name).Msg("Failed to set secret")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to set secret")
+		return
+	}
+
+	h.auditLog(r, "set", "secret", name, nil)
+	writeJSON(w, http.StatusOK, secret)
+}
+
+// DeleteSecret handles DELETE /api/v1/secrets/{name}
+//
+// Configs still referring to the secret fail to deliver until they are
+// updated, so agents keep their current config.
+func (h *Handler) DeleteSecret(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	name := chi.URLParam(r, "name")
+
+	if !auth.Authorize(ctx, auth.ScopeSecretsWrite, auth.SecretResource(name)) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to delete this secret")
+		return
+	}
+
+	if err := h.store.DeleteSecret(ctx, name); err != nil {
+		if err.Error() == "secret not found" {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Secret not found")
+			return
+		}
+		log.Error().Err(err).Str("name", name).Msg("Failed to delete secret")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete secret")
+		return
+	}
+
+	h.auditLog(r, "delete", "secret", name, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/raskell-io/sentinel-hub/internal/auth"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// Session Handlers
+// ============================================
+
+// SessionResponse is a login session, marked if it is the caller's own.
+type SessionResponse struct {
+	store.UserSession
+	Current bool `json:"current"`
+}
+
+// ListSessionsResponse represents the response for listing sessions.
+type ListSessionsResponse struct {
+	Sessions []SessionResponse `json:"sessions"`
+	Total    int               `json:"total"`
+}
+
+// ListSessions handles GET /api/v1/auth/sessions
+func (h *UserHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	currentUser := auth.GetUserFromContext(r.Context())
+	h.listSessions(w, r, currentUser.ID)
+}
+
+// RevokeSession handles DELETE /api/v1/auth/sessions/{id}
+func (h *UserHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	currentUser := auth.GetUserFromContext(r.Context())
+	h.revokeSession(w, r, currentUser.ID, chi.URLParam(r, "id"))
+}
+
+// ListUserSessions handles GET /api/v1/users/{id}/sessions
+func (h *UserHandler) ListUserSessions(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !h.userExists(w, r, id) {
+		return
+	}
+	h.listSessions(w, r, id)
+}
+
+// RevokeUserSession handles DELETE /api/v1/users/{id}/sessions/{sessionID}
+func (h *UserHandler) RevokeUserSession(w http.ResponseWriter, r *http.Request) {
+	h.revokeSession(w, r, chi.URLParam(r, "id"), chi.URLParam(r, "sessionID"))
+}
+
+// RevokeAllUserSessions handles DELETE /api/v1/users/{id}/sessions
+func (h *UserHandler) RevokeAllUserSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+	if !h.userExists(w, r, id) {
+		return
+	}
+
+	if err := h.authService.RevokeAllSessions(ctx, id); err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to revoke sessions")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to revoke sessions")
+		return
+	}
+
+	h.auditLog(r, "revoke_sessions", "user", id, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listSessions writes a user's active sessions.
+func (h *UserHandler) listSessions(w http.ResponseWriter, r *http.Request, userID string) {
+	ctx := r.Context()
+
+	sessions, err := h.authService.ListSessions(ctx, userID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID).Msg("Failed to list sessions")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list sessions")
+		return
+	}
+
+	current := auth.GetSessionIDFromContext(ctx)
+	resp := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		resp[i] = SessionResponse{UserSession: session, Current: session.ID == current}
+	}
+
+	writeJSON(w, http.StatusOK, ListSessionsResponse{
+		Sessions: resp,
+		Total:    len(resp),
+	})
+}
+
+// revokeSession revokes one of a user's sessions.
+func (h *UserHandler) revokeSession(w http.ResponseWriter, r *http.Request, userID, sessionID string) {
+	if err := h.authService.RevokeSession(r.Context(), userID, sessionID); err != nil {
+		if err == auth.ErrSessionNotFound {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Session not found")
+			return
+		}
+		log.Error().Err(err).Str("session_id", sessionID).Msg("Failed to revoke session")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to revoke session")
+		return
+	}
+
+	h.auditLog(r, "revoke", "session", sessionID, map[string]string{"user_id": userID})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// userExists writes a 404 and returns false if the user does not exist.
+func (h *UserHandler) userExists(w http.ResponseWriter, r *http.Request, id string) bool {
+	user, err := h.store.GetUser(r.Context(), id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to get user")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get user")
+		return false
+	}
+	if user == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "User not found")
+		return false
+	}
+	return true
+}
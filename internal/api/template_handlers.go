@@ -0,0 +1,260 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/raskell-io/sentinel-hub/internal/auth"
+	"github.com/raskell-io/sentinel-hub/internal/render"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// Template Handlers
+// ============================================
+
+// variableNamePattern keeps variable names usable as {{ .Vars.name }}.
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SetVariableRequest represents the request body for setting a variable.
+type SetVariableRequest struct {
+	Value string `json:"value"`
+}
+
+// ListVariablesResponse represents the response for listing variables.
+type ListVariablesResponse struct {
+	Variables []store.Variable `json:"variables"`
+	Total     int              `json:"total"`
+}
+
+// RenderConfigResponse is a config version as it would be delivered to an
+// instance.
+type RenderConfigResponse struct {
+	ConfigID   string `json:"config_id"`
+	Version    int    `json:"version"`
+	InstanceID string `json:"instance_id"`
+	IsTemplate bool   `json:"is_template"`
+	Content    string `json:"content"`
+	Hash       string `json:"hash"`
+}
+
+// validateTemplate checks template syntax for versions marked as templates.
+func validateTemplate(w http.ResponseWriter, isTemplate bool, content string) bool {
+	if !isTemplate {
+		return true
+	}
+	if _, err := render.Parse(content); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return false
+	}
+	return true
+}
+
+// RenderConfig handles GET /api/v1/configs/{id}/render?instance_id=X&version=N
+//
+// version defaults to the config's current version.
+func (h *Handler) RenderConfig(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	cfg, err := h.store.GetConfig(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to get config")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get config")
+		return
+	}
+	if cfg == nil || !auth.Authorize(ctx, auth.ScopeConfigsRead, auth.ConfigResource(cfg.Name)) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Config not found")
+		return
+	}
+
+	instanceID := r.URL.Query().Get("instance_id")
+	if instanceID == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "instance_id is required")
+		return
+	}
+	inst, err := h.store.GetInstance(ctx, instanceID)
+	if err != nil {
+		log.Error().Err(err).Str("id", instanceID).Msg("Failed to get instance")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get instance")
+		return
+	}
+	if inst == nil || !auth.Authorize(ctx, auth.ScopeInstancesRead, auth.InstanceResource(inst.Labels)) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Instance not found")
+		return
+	}
+
+	version := cfg.CurrentVersion
+	if v := r.URL.Query().Get("version"); v != "" {
+		if version, err = strconv.Atoi(v); err != nil || version <= 0 {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "version must be a positive version number")
+			return
+		}
+	}
+	ver, err := h.store.GetConfigVersion(ctx, id, version)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Int("version", version).Msg("Failed to get config version")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get config version")
+		return
+	}
+	if ver == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Version not found")
+		return
+	}
+
+	content, hash, err := render.ForInstance(ctx, h.store, inst, ver)
+	if errors.Is(err, render.ErrTemplate) {
+		writeError(w, http.StatusUnprocessableEntity, "TEMPLATE_ERROR", err.Error())
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to render config")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to render config")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, RenderConfigResponse{
+		ConfigID:   id,
+		Version:    ver.Version,
+		InstanceID: inst.ID,
+		IsTemplate: ver.IsTemplate,
+		Content:    content,
+		Hash:       hash,
+	})
+}
+
+// ListVariables handles GET /api/v1/variables
+func (h *Handler) ListVariables(w http.ResponseWriter, r *http.Request) {
+	h.listVariables(w, r, "")
+}
+
+// SetVariable handles PUT /api/v1/variables/{name}
+//
+// Fleet-wide variables reach every instance, so setting them requires
+// instances:write without a label restriction.
+func (h *Handler) SetVariable(w http.ResponseWriter, r *http.Request) {
+	if !auth.Authorize(r.Context(), auth.ScopeInstancesWrite, auth.InstanceResource(nil)) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to set fleet-wide variables")
+		return
+	}
+	h.setVariable(w, r, "")
+}
+
+// DeleteVariable handles DELETE /api/v1/variables/{name}
+func (h *Handler) DeleteVariable(w http.ResponseWriter, r *http.Request) {
+	if !auth.Authorize(r.Context(), auth.ScopeInstancesWrite, auth.InstanceResource(nil)) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to delete fleet-wide variables")
+		return
+	}
+	h.deleteVariable(w, r, "")
+}
+
+// ListInstanceVariables handles GET /api/v1/instances/{id}/variables
+func (h *Handler) ListInstanceVariables(w http.ResponseWriter, r *http.Request) {
+	if inst := h.variableInstance(w, r, auth.ScopeInstancesRead); inst != nil {
+		h.listVariables(w, r, inst.ID)
+	}
+}
+
+// SetInstanceVariable handles PUT /api/v1/instances/{id}/variables/{name}
+func (h *Handler) SetInstanceVariable(w http.ResponseWriter, r *http.Request) {
+	if inst := h.variableInstance(w, r, auth.ScopeInstancesWrite); inst != nil {
+		h.setVariable(w, r, inst.ID)
+	}
+}
+
+// DeleteInstanceVariable handles DELETE /api/v1/instances/{id}/variables/{name}
+func (h *Handler) DeleteInstanceVariable(w http.ResponseWriter, r *http.Request) {
+	if inst := h.variableInstance(w, r, auth.ScopeInstancesWrite); inst != nil {
+		h.deleteVariable(w, r, inst.ID)
+	}
+}
+
+// variableInstance loads the instance whose variables are addressed and
+// checks the permission on it, writing an error response if it fails.
+func (h *Handler) variableInstance(w http.ResponseWriter, r *http.Request, permission string) *store.Instance {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	inst, err := h.store.GetInstance(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to get instance")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get instance")
+		return nil
+	}
+	if inst == nil || !auth.Authorize(ctx, auth.ScopeInstancesRead, auth.InstanceResource(inst.Labels)) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Instance not found")
+		return nil
+	}
+	if !auth.Authorize(ctx, permission, auth.InstanceResource(inst.Labels)) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Not permitted to change this instance's variables")
+		return nil
+	}
+	return inst
+}
+
+func (h *Handler) listVariables(w http.ResponseWriter, r *http.Request, instanceID string) {
+	vars, err := h.store.ListVariables(r.Context(), instanceID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list variables")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list variables")
+		return
+	}
+	if vars == nil {
+		vars = []store.Variable{}
+	}
+
+	writeJSON(w, http.StatusOK, ListVariablesResponse{
+		Variables: vars,
+		Total:     len(vars),
+	})
+}
+
+func (h *Handler) setVariable(w http.ResponseWriter, r *http.Request, instanceID string) {
+	name := chi.URLParam(r, "name")
+	if !variableNamePattern.MatchString(name) {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "name must start with a letter or underscore and contain only letters, digits and underscores")
+		return
+	}
+
+	var req SetVariableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+		return
+	}
+
+	v := &store.Variable{InstanceID: instanceID, Name: name, Value: req.Value}
+	if user := auth.GetUserFromContext(r.Context()); user != nil {
+		v.UpdatedBy = &user.ID
+	}
+	if err := h.store.SetVariable(r.Context(), v); err != nil {
+		log.Error().Err(err).Str("name", name).Msg("Failed to set variable")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to set variable")
+		return
+	}
+
+	h.auditLog(r, "set", "variable", name, map[string]string{"instance_id": instanceID})
+	writeJSON(w, http.StatusOK, v)
+}
+
+func (h *Handler) deleteVariable(w http.ResponseWriter, r *http.Request, instanceID string) {
+	name := chi.URLParam(r, "name")
+
+	if err := h.store.DeleteVariable(r.Context(), instanceID, name); err != nil {
+		if err.Error() == "variable not found" {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Variable not found")
+			return
+		}
+		log.Error().Err(err).Str("name", name).Msg("Failed to delete variable")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete variable")
+		return
+	}
+
+	h.auditLog(r, "delete", "variable", name, map[string]string{"instance_id": instanceID})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/raskell-io/sentinel-hub/internal/auth"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// API Token Handlers
+// ============================================
+
+// CreateAPITokenRequest represents the request body for creating an API token.
+type CreateAPITokenRequest struct {
+	Name      string            `json:"name"`
+	Scopes    []string          `json:"scopes"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	ExpiresIn string            `json:"expires_in,omitempty"` // Go duration, e.g. "720h"
+	UserID    string            `json:"user_id,omitempty"`    // Admin only: issue for a service account
+}
+
+// CreateAPITokenResponse includes the plaintext token, which is only returned once.
+type CreateAPITokenResponse struct {
+	Token    string          `json:"token"`
+	APIToken *store.APIToken `json:"api_token"`
+}
+
+// ListAPITokensResponse represents the response for listing API tokens.
+type ListAPITokensResponse struct {
+	Tokens []store.APIToken `json:"tokens"`
+	Total  int              `json:"total"`
+}
+
+// CreateAPIToken handles POST /api/v1/tokens
+func (h *UserHandler) CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	currentUser := auth.GetUserFromContext(ctx)
+
+	var req CreateAPITokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+		return
+	}
+
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "name is required")
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "expires_in must be a positive duration")
+			return
+		}
+		t := time.Now().UTC().Add(d)
+		expiresAt = &t
+	}
+
+	// Tokens are issued for the caller unless an admin names a service account
+	owner := currentUser
+	if req.UserID != "" && req.UserID != currentUser.ID {
+		if currentUser.Role != store.UserRoleAdmin {
+			writeError(w, http.StatusForbidden, "FORBIDDEN", "Only admins can issue tokens for service accounts")
+			return
+		}
+
+		sa, err := h.store.GetServiceAccount(ctx, req.UserID)
+		if err != nil {
+			log.Error().Err(err).Str("user_id", req.UserID).Msg("Failed to get service account")
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create token")
+			return
+		}
+		if sa == nil {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Service account not found")
+			return
+		}
+
+		owner, err = h.store.GetUser(ctx, req.UserID)
+		if err != nil || owner == nil {
+			log.Error().Err(err).Str("user_id", req.UserID).Msg("Failed to get service account user")
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create token")
+			return
+		}
+	}
+
+	plaintext, token, err := h.authService.CreateAPIToken(ctx, auth.CreateAPITokenRequest{
+		Name:      req.Name,
+		Owner:     owner,
+		Scopes:    req.Scopes,
+		Labels:    req.Labels,
+		ExpiresAt: expiresAt,
+		CreatedBy: currentUser.ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidScope), errors.Is(err, auth.ErrScopeExceedsRole):
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		default:
+			log.Error().Err(err).Msg("Failed to create api token")
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create token")
+		}
+		return
+	}
+
+	h.auditLog(r, "create", "api_token", token.ID, map[string]interface{}{
+		"name":    token.Name,
+		"user_id": token.UserID,
+		"scopes":  token.Scopes,
+	})
+	writeJSON(w, http.StatusCreated, CreateAPITokenResponse{Token: plaintext, APIToken: token})
+}
+
+// ListAPITokens handles GET /api/v1/tokens
+func (h *UserHandler) ListAPITokens(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	currentUser := auth.GetUserFromContext(ctx)
+
+	opts := store.ListAPITokensOptions{
+		UserID:         currentUser.ID,
+		IncludeRevoked: r.URL.Query().Get("include_revoked") == "true",
+	}
+
+	// Admins may list another owner's tokens, or all tokens
+	if currentUser.Role == store.UserRoleAdmin {
+		if r.URL.Query().Get("all") == "true" {
+			opts.UserID = ""
+		} else if userID := r.URL.Query().Get("user_id"); userID != "" {
+			opts.UserID = userID
+		}
+	}
+
+	tokens, err := h.store.ListAPITokens(ctx, opts)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list api tokens")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list tokens")
+		return
+	}
+
+	if tokens == nil {
+		tokens = []store.APIToken{}
+	}
+
+	writeJSON(w, http.StatusOK, ListAPITokensResponse{
+		Tokens: tokens,
+		Total:  len(tokens),
+	})
+}
+
+// RevokeAPIToken handles DELETE /api/v1/tokens/{id}
+func (h *UserHandler) RevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+	currentUser := auth.GetUserFromContext(ctx)
+
+	token, err := h.store.GetAPIToken(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to get api token")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to revoke token")
+		return
+	}
+
+	// Non-admins only see their own tokens
+	if token == nil || (token.UserID != currentUser.ID && currentUser.Role != store.UserRoleAdmin) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Token not found")
+		return
+	}
+
+	if err := h.authService.RevokeAPIToken(ctx, id); err != nil {
+		if err == auth.ErrAPITokenNotFound {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Token not found")
+			return
+		}
+		log.Error().Err(err).Str("id", id).Msg("Failed to revoke api token")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to revoke token")
+		return
+	}
+
+	h.auditLog(r, "revoke", "api_token", id, map[string]string{"user_id": token.UserID})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ============================================
+// Service Account Handlers
+// ============================================
+
+// CreateServiceAccountRequest represents the request body for creating a service account.
+type CreateServiceAccountRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Role        string `json:"role"`
+}
+
+// ListServiceAccountsResponse represents the response for listing service accounts.
+type ListServiceAccountsResponse struct {
+	ServiceAccounts []store.ServiceAccount `json:"service_accounts"`
+	Total           int                    `json:"total"`
+}
+
+// CreateServiceAccount handles POST /api/v1/service-accounts
+func (h *UserHandler) CreateServiceAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	currentUser := auth.GetUserFromContext(ctx)
+
+	var req CreateServiceAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+		return
+	}
+
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "name is required")
+		return
+	}
+
+	// Default role to viewer
+	role := store.UserRoleViewer
+	if req.Role != "" {
+		role = store.UserRole(req.Role)
+		switch role {
+		case store.UserRoleAdmin, store.UserRoleOperator, store.UserRoleViewer:
+			// valid
+		default:
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "invalid role (must be admin, operator, or viewer)")
+			return
+		}
+	}
+
+	sa, err := h.authService.CreateServiceAccount(ctx, req.Name, req.Description, role, currentUser.ID)
+	if err != nil {
+		if err.Error() == "service account with this name already exists" {
+			writeError(w, http.StatusConflict, "ALREADY_EXISTS", "Service account with this name already exists")
+			return
+		}
+		log.Error().Err(err).Msg("Failed to create service account")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create service account")
+		return
+	}
+
+	h.auditLog(r, "create", "service_account", sa.UserID, map[string]string{"name": sa.Name, "role": string(sa.Role)})
+	writeJSON(w, http.StatusCreated, sa)
+}
+
+// ListServiceAccounts handles GET /api/v1/service-accounts
+func (h *UserHandler) ListServiceAccounts(w http.ResponseWriter, r *http.Request) {
+	accounts, err := h.store.ListServiceAccounts(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list service accounts")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list service accounts")
+		return
+	}
+
+	if accounts == nil {
+		accounts = []store.ServiceAccount{}
+	}
+
+	writeJSON(w, http.StatusOK, ListServiceAccountsResponse{
+		ServiceAccounts: accounts,
+		Total:           len(accounts),
+	})
+}
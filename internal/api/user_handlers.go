@@ -6,20 +6,46 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/raskell-io/sentinel-hub/internal/audit"
 	"github.com/raskell-io/sentinel-hub/internal/auth"
 	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/raskell-io/sentinel-hub/internal/webhook"
 	"github.com/rs/zerolog/log"
 )
 
 // UserHandler provides HTTP handlers for user management endpoints.
 type UserHandler struct {
-	store       *store.Store
+	store       store.Store
 	authService *auth.Service
+	audit       *audit.Recorder
+	auditKey    []byte
+	webhooks    *webhook.Dispatcher
 }
 
 // NewUserHandler creates a new UserHandler instance.
-func NewUserHandler(s *store.Store, authService *auth.Service) *UserHandler {
-	return &UserHandler{store: s, authService: authService}
+func NewUserHandler(s store.Store, authService *auth.Service) *UserHandler {
+	return &UserHandler{
+		store:       s,
+		authService: authService,
+		audit:       audit.NewRecorder(s, audit.RecorderConfig{}),
+		webhooks:    webhook.NewDispatcher(s, webhook.Config{}),
+	}
+}
+
+// SetAuditRecorder sets the recorder audit logs are written through.
+func (h *UserHandler) SetAuditRecorder(r *audit.Recorder) {
+	h.audit = r
+}
+
+// SetWebhookDispatcher sets the dispatcher redeliveries are queued on.
+func (h *UserHandler) SetWebhookDispatcher(d *webhook.Dispatcher) {
+	h.webhooks = d
+}
+
+// SetAuditCheckpointKey sets the key audit checkpoint signatures are
+// verified with.
+func (h *UserHandler) SetAuditCheckpointKey(key []byte) {
+	h.auditKey = key
 }
 
 // auditLog creates an audit log entry for user-related operations.
@@ -46,7 +72,7 @@ func (h *UserHandler) auditLog(r *http.Request, action, resourceType, resourceID
 		Details:      detailsJSON,
 	}
 
-	if err := h.store.CreateAuditLog(r.Context(), auditLog); err != nil {
+	if err := h.audit.Record(r.Context(), auditLog); err != nil {
 		log.Warn().Err(err).
 			Str("action", action).
 			Str("resource_type", resourceType).
@@ -316,7 +342,26 @@ func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 
 	var req ResetPasswordRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		// If no body, generate a token
+		// If no body, email the user a reset link when a notifier is configured
+		if h.authService.NotifierConfigured() {
+			if err := h.authService.SendPasswordResetLink(ctx, id); err != nil {
+				if err == auth.ErrUserNotFound {
+					writeError(w, http.StatusNotFound, "NOT_FOUND", "User not found")
+					return
+				}
+				log.Error().Err(err).Str("id", id).Msg("Failed to send reset link")
+				writeError(w, http.StatusBadGateway, "NOTIFY_ERROR", "Failed to send reset link")
+				return
+			}
+
+			h.auditLog(r, "reset_password_link", "user", id, nil)
+			writeJSON(w, http.StatusOK, ResetPasswordResponse{
+				Message: "A password reset link has been sent to the user",
+			})
+			return
+		}
+
+		// Otherwise return a token for the admin to share
 		token, err := h.authService.GeneratePasswordResetToken(ctx, id)
 		if err != nil {
 			if err == auth.ErrUserNotFound {
@@ -367,6 +412,25 @@ func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 	writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "new_password is required")
 }
 
+// UnlockUser handles POST /api/v1/users/{id}/unlock
+func (h *UserHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	if err := h.authService.UnlockUser(ctx, id); err != nil {
+		if err == auth.ErrUserNotFound {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "User not found")
+			return
+		}
+		log.Error().Err(err).Str("id", id).Msg("Failed to unlock user")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to unlock user")
+		return
+	}
+
+	h.auditLog(r, "unlock", "user", id, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // ListAuditLogsResponse represents the response for listing audit logs.
 type ListAuditLogsResponse struct {
 	Logs  []store.AuditLog `json:"logs"`
@@ -384,6 +448,15 @@ func (h *UserHandler) ListAuditLogs(w http.ResponseWriter, r *http.Request) {
 	if userID := r.URL.Query().Get("user_id"); userID != "" {
 		opts.UserID = userID
 	}
+	if actorType := r.URL.Query().Get("actor_type"); actorType != "" {
+		switch store.ActorType(actorType) {
+		case store.ActorTypeUser, store.ActorTypeAgent, store.ActorTypeSystem:
+			opts.ActorType = store.ActorType(actorType)
+		default:
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "invalid actor_type (must be user, agent, or system)")
+			return
+		}
+	}
 	if action := r.URL.Query().Get("action"); action != "" {
 		opts.Action = action
 	}
@@ -410,3 +483,15 @@ func (h *UserHandler) ListAuditLogs(w http.ResponseWriter, r *http.Request) {
 		Total: len(logs),
 	})
 }
+
+// VerifyAuditLogs handles GET /api/v1/audit-logs/verify
+func (h *UserHandler) VerifyAuditLogs(w http.ResponseWriter, r *http.Request) {
+	result, err := audit.Verify(r.Context(), h.store, h.auditKey)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to verify audit logs")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to verify audit logs")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
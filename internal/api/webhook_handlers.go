@@ -0,0 +1,346 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/raskell-io/sentinel-hub/internal/auth"
+	"github.com/raskell-io/sentinel-hub/internal/events"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/raskell-io/sentinel-hub/internal/webhook"
+	"github.com/rs/zerolog/log"
+)
+
+// ============================================
+// Webhook Handlers
+// ============================================
+
+// minWebhookSecretLength is the shortest secret accepted from a client.
+const minWebhookSecretLength = 16
+
+// CreateWebhookRequest represents the request body for creating a webhook.
+type CreateWebhookRequest struct {
+	Name       string   `json:"name"`
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret,omitempty"` // Generated when empty
+	EventTypes []string `json:"event_types"`
+	Enabled    *bool    `json:"enabled,omitempty"` // Defaults to true
+}
+
+// UpdateWebhookRequest represents the request body for updating a webhook.
+type UpdateWebhookRequest struct {
+	Name       *string   `json:"name,omitempty"`
+	URL        *string   `json:"url,omitempty"`
+	Secret     *string   `json:"secret,omitempty"`
+	EventTypes *[]string `json:"event_types,omitempty"`
+	Enabled    *bool     `json:"enabled,omitempty"`
+}
+
+// WebhookResponse is a webhook as returned by the API. The secret is only
+// included when the webhook is created.
+type WebhookResponse struct {
+	store.Webhook
+	Secret string `json:"secret,omitempty"`
+}
+
+// ListWebhooksResponse represents the response for listing webhooks.
+type ListWebhooksResponse struct {
+	Webhooks []store.Webhook `json:"webhooks"`
+	Total    int             `json:"total"`
+}
+
+// ListWebhookDeliveriesResponse represents the response for listing deliveries.
+type ListWebhookDeliveriesResponse struct {
+	Deliveries []store.WebhookDelivery `json:"deliveries"`
+	Total      int                     `json:"total"`
+}
+
+// validateWebhookURL checks that a webhook URL is an absolute HTTP(S) URL.
+func validateWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http:// or https:// URL")
+	}
+	return nil
+}
+
+// validateEventTypes checks that every event type is known.
+func validateEventTypes(eventTypes []string) error {
+	for _, et := range eventTypes {
+		if !events.Type(et).Valid() {
+			return fmt.Errorf("unknown event type %q", et)
+		}
+	}
+	return nil
+}
+
+// ListWebhooks handles GET /api/v1/webhooks
+func (h *UserHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.store.ListWebhooks(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list webhooks")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list webhooks")
+		return
+	}
+
+	if webhooks == nil {
+		webhooks = []store.Webhook{}
+	}
+
+	writeJSON(w, http.StatusOK, ListWebhooksResponse{
+		Webhooks: webhooks,
+		Total:    len(webhooks),
+	})
+}
+
+// CreateWebhook handles POST /api/v1/webhooks
+func (h *UserHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+		return
+	}
+
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "name is required")
+		return
+	}
+	if err := validateWebhookURL(req.URL); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+	if err := validateEventTypes(req.EventTypes); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+	if req.Secret != "" && len(req.Secret) < minWebhookSecretLength {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR",
+			fmt.Sprintf("secret must be at least %d characters", minWebhookSecretLength))
+		return
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		var err error
+		if secret, err = webhook.GenerateSecret(); err != nil {
+			log.Error().Err(err).Msg("Failed to generate webhook secret")
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create webhook")
+			return
+		}
+	}
+
+	wh := &store.Webhook{
+		Name:       req.Name,
+		URL:        req.URL,
+		Secret:     secret,
+		EventTypes: req.EventTypes,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+	}
+	if user := auth.GetUserFromContext(r.Context()); user != nil {
+		wh.CreatedBy = &user.ID
+	}
+
+	if err := h.store.CreateWebhook(r.Context(), wh); err != nil {
+		log.Error().Err(err).Msg("Failed to create webhook")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create webhook")
+		return
+	}
+
+	h.auditLog(r, "create", "webhook", wh.ID, map[string]interface{}{
+		"name":        wh.Name,
+		"url":         wh.URL,
+		"event_types": wh.EventTypes,
+	})
+	writeJSON(w, http.StatusCreated, WebhookResponse{Webhook: *wh, Secret: secret})
+}
+
+// GetWebhook handles GET /api/v1/webhooks/{id}
+func (h *UserHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	wh, err := h.store.GetWebhook(r.Context(), id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to get webhook")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get webhook")
+		return
+	}
+	if wh == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Webhook not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, wh)
+}
+
+// UpdateWebhook handles PUT /api/v1/webhooks/{id}
+func (h *UserHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	wh, err := h.store.GetWebhook(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to get webhook")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update webhook")
+		return
+	}
+	if wh == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Webhook not found")
+		return
+	}
+
+	var req UpdateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON body")
+		return
+	}
+
+	details := map[string]interface{}{}
+	if req.Name != nil {
+		if *req.Name == "" {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "name cannot be empty")
+			return
+		}
+		wh.Name = *req.Name
+		details["name"] = wh.Name
+	}
+	if req.URL != nil {
+		if err := validateWebhookURL(*req.URL); err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+			return
+		}
+		wh.URL = *req.URL
+		details["url"] = wh.URL
+	}
+	if req.Secret != nil {
+		if len(*req.Secret) < minWebhookSecretLength {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR",
+				fmt.Sprintf("secret must be at least %d characters", minWebhookSecretLength))
+			return
+		}
+		wh.Secret = *req.Secret
+		details["secret_rotated"] = true
+	}
+	if req.EventTypes != nil {
+		if err := validateEventTypes(*req.EventTypes); err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+			return
+		}
+		wh.EventTypes = *req.EventTypes
+		details["event_types"] = wh.EventTypes
+	}
+	if req.Enabled != nil {
+		wh.Enabled = *req.Enabled
+		details["enabled"] = wh.Enabled
+	}
+
+	if err := h.store.UpdateWebhook(ctx, wh); err != nil {
+		if err.Error() == "webhook not found" {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Webhook not found")
+			return
+		}
+		log.Error().Err(err).Str("id", id).Msg("Failed to update webhook")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update webhook")
+		return
+	}
+
+	h.auditLog(r, "update", "webhook", id, details)
+	writeJSON(w, http.StatusOK, wh)
+}
+
+// DeleteWebhook handles DELETE /api/v1/webhooks/{id}
+func (h *UserHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := h.store.DeleteWebhook(r.Context(), id); err != nil {
+		if err.Error() == "webhook not found" {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Webhook not found")
+			return
+		}
+		log.Error().Err(err).Str("id", id).Msg("Failed to delete webhook")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to delete webhook")
+		return
+	}
+
+	h.auditLog(r, "delete", "webhook", id, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries handles GET /api/v1/webhooks/{id}/deliveries
+func (h *UserHandler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+
+	wh, err := h.store.GetWebhook(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to get webhook")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list webhook deliveries")
+		return
+	}
+	if wh == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Webhook not found")
+		return
+	}
+
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if n, err := strconv.Atoi(l); err == nil && n > 0 && n <= 1000 {
+			limit = n
+		}
+	}
+
+	deliveries, err := h.store.ListWebhookDeliveries(ctx, id, limit)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to list webhook deliveries")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to list webhook deliveries")
+		return
+	}
+
+	if deliveries == nil {
+		deliveries = []store.WebhookDelivery{}
+	}
+
+	writeJSON(w, http.StatusOK, ListWebhookDeliveriesResponse{
+		Deliveries: deliveries,
+		Total:      len(deliveries),
+	})
+}
+
+// RedeliverWebhookDelivery handles POST /api/v1/webhooks/{id}/deliveries/{deliveryID}/redeliver
+func (h *UserHandler) RedeliverWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := chi.URLParam(r, "id")
+	deliveryID := chi.URLParam(r, "deliveryID")
+
+	original, err := h.store.GetWebhookDelivery(ctx, deliveryID)
+	if err != nil {
+		log.Error().Err(err).Str("id", deliveryID).Msg("Failed to get webhook delivery")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to redeliver webhook")
+		return
+	}
+	if original == nil || original.WebhookID != id {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Webhook delivery not found")
+		return
+	}
+
+	delivery, err := h.webhooks.Redeliver(ctx, deliveryID)
+	if err != nil {
+		if err == webhook.ErrDeliveryNotFound {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Webhook delivery not found")
+			return
+		}
+		log.Error().Err(err).Str("id", deliveryID).Msg("Failed to redeliver webhook")
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to redeliver webhook")
+		return
+	}
+
+	h.auditLog(r, "redeliver", "webhook", id, map[string]string{
+		"delivery_id":   delivery.ID,
+		"redelivery_of": deliveryID,
+		"event_type":    delivery.EventType,
+	})
+	writeJSON(w, http.StatusAccepted, delivery)
+}
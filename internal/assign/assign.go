@@ -0,0 +1,139 @@
+// Package assign resolves desired-state config assignments: which config
+// an instance should run, given the assignments binding configs to label
+// selectors.
+//
+// An assignment matches an instance carrying every label of its selector.
+// Of the matching assignments, the one with the highest priority wins,
+// then the one with the most specific (largest) selector. Assignments tied
+// on both conflict unless they agree on the config and version.
+package assign
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+// Resolution is the config assignments select for an instance.
+type Resolution struct {
+	// Assignment is the winning assignment, or nil if none matches or
+	// the matching ones conflict.
+	Assignment *store.ConfigAssignment `json:"assignment,omitempty"`
+	// ConfigID and ConfigVersion are the version to run; ConfigVersion is
+	// 0 when no usable version could be found.
+	ConfigID      string `json:"config_id,omitempty"`
+	ConfigVersion int    `json:"config_version,omitempty"`
+	// Conflicts are the tied assignments when the match is ambiguous.
+	Conflicts []store.ConfigAssignment `json:"conflicts,omitempty"`
+	// Error explains why the winning assignment has no usable version.
+	Error string `json:"error,omitempty"`
+}
+
+// Matches reports whether an assignment's selector matches labels.
+func Matches(a store.ConfigAssignment, labels map[string]string) bool {
+	for k, v := range a.Selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Match picks the assignment for an instance with the given labels from
+// assignments, which are taken to be ordered oldest first within a
+// priority. It returns nil and the tied assignments on a conflict, and nil
+// and no conflicts if nothing matches.
+func Match(assignments []store.ConfigAssignment, labels map[string]string) (*store.ConfigAssignment, []store.ConfigAssignment) {
+	var matching []store.ConfigAssignment
+	for _, a := range assignments {
+		if Matches(a, labels) {
+			matching = append(matching, a)
+		}
+	}
+	if len(matching) == 0 {
+		return nil, nil
+	}
+
+	sort.SliceStable(matching, func(i, j int) bool {
+		if matching[i].Priority != matching[j].Priority {
+			return matching[i].Priority > matching[j].Priority
+		}
+		return len(matching[i].Selector) > len(matching[j].Selector)
+	})
+
+	top := matching[:1]
+	for _, a := range matching[1:] {
+		if a.Priority != top[0].Priority || len(a.Selector) != len(top[0].Selector) {
+			break
+		}
+		top = append(top, a)
+	}
+
+	winner := top[0]
+	for _, a := range top[1:] {
+		if a.ConfigID != winner.ConfigID || !sameVersion(a.Version, winner.Version) {
+			return nil, top
+		}
+	}
+	return &winner, nil
+}
+
+func sameVersion(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// Resolve finds the config version assignments select for an instance with
+// the given labels. It returns nil if no assignment matches.
+func Resolve(ctx context.Context, s store.Store, labels map[string]string) (*Resolution, error) {
+	assignments, err := s.ListConfigAssignments(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	winner, conflicts := Match(assignments, labels)
+	if conflicts != nil {
+		return &Resolution{Conflicts: conflicts}, nil
+	}
+	if winner == nil {
+		return nil, nil
+	}
+
+	res := &Resolution{Assignment: winner, ConfigID: winner.ConfigID}
+	cfg, err := s.GetConfig(ctx, winner.ConfigID)
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		res.Error = "assigned config does not exist"
+		return res, nil
+	}
+
+	var ver *store.ConfigVersion
+	if winner.Version != nil {
+		ver, err = s.GetConfigVersion(ctx, winner.ConfigID, *winner.Version)
+		if err != nil {
+			return nil, err
+		}
+		if ver == nil {
+			res.Error = fmt.Sprintf("version %d of config %s does not exist", *winner.Version, cfg.Name)
+			return res, nil
+		}
+	} else {
+		ver, err = s.GetLatestApprovedConfigVersion(ctx, winner.ConfigID)
+		if err != nil {
+			return nil, err
+		}
+		if ver == nil {
+			res.Error = fmt.Sprintf("config %s has no approved version", cfg.Name)
+			return res, nil
+		}
+	}
+
+	res.ConfigVersion = ver.Version
+	return res, nil
+}
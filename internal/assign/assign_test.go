@@ -0,0 +1,119 @@
+package assign
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+func setupTestStore(t *testing.T) store.Store {
+	t.Helper()
+
+	s, err := store.New(filepath.Join(t.TempDir(), "hub.db"))
+	if err != nil {
+		t.Fatalf("failed to create test store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func intPtr(v int) *int { return &v }
+
+func TestMatch(t *testing.T) {
+	all := store.ConfigAssignment{ID: "all", ConfigID: "base"}
+	edge := store.ConfigAssignment{ID: "edge", ConfigID: "edge", Selector: map[string]string{"role": "edge"}}
+	euEdge := store.ConfigAssignment{ID: "eu-edge", ConfigID: "eu", Selector: map[string]string{"role": "edge", "region": "eu"}}
+	urgent := store.ConfigAssignment{ID: "urgent", ConfigID: "hotfix", Selector: map[string]string{"region": "eu"}, Priority: 10}
+
+	tests := []struct {
+		name        string
+		assignments []store.ConfigAssignment
+		labels      map[string]string
+		want        string
+		conflicts   int
+	}{
+		{"no match", []store.ConfigAssignment{edge}, map[string]string{"role": "api"}, "", 0},
+		{"empty selector matches all", []store.ConfigAssignment{all, edge}, map[string]string{"role": "api"}, "all", 0},
+		{"most specific wins", []store.ConfigAssignment{all, edge, euEdge}, map[string]string{"role": "edge", "region": "eu"}, "eu-edge", 0},
+		{"priority beats specificity", []store.ConfigAssignment{euEdge, urgent}, map[string]string{"role": "edge", "region": "eu"}, "urgent", 0},
+		{
+			"tie on different configs conflicts",
+			[]store.ConfigAssignment{edge, {ID: "eu", ConfigID: "eu", Selector: map[string]string{"region": "eu"}}},
+			map[string]string{"role": "edge", "region": "eu"}, "", 2,
+		},
+		{
+			"tie on the same version agrees",
+			[]store.ConfigAssignment{edge, {ID: "edge-2", ConfigID: "edge", Selector: map[string]string{"tier": "1"}}},
+			map[string]string{"role": "edge", "tier": "1"}, "edge", 0,
+		},
+		{
+			"tie on different versions conflicts",
+			[]store.ConfigAssignment{edge, {ID: "edge-v2", ConfigID: "edge", Version: intPtr(2), Selector: map[string]string{"tier": "1"}}},
+			map[string]string{"role": "edge", "tier": "1"}, "", 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflicts := Match(tt.assignments, tt.labels)
+			if len(conflicts) != tt.conflicts {
+				t.Errorf("conflicts = %+v, want %d", conflicts, tt.conflicts)
+			}
+			switch {
+			case tt.want == "" && got != nil:
+				t.Errorf("expected no assignment, got %s", got.ID)
+			case tt.want != "" && (got == nil || got.ID != tt.want):
+				t.Errorf("expected assignment %s, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestStore(t)
+
+	cfg := &store.Config{Name: "edge"}
+	if err := s.CreateConfig(ctx, cfg); err != nil {
+		t.Fatalf("CreateConfig failed: %v", err)
+	}
+	for v := 1; v <= 3; v++ {
+		s.CreateConfigVersion(ctx, &store.ConfigVersion{ConfigID: cfg.ID, Version: v, Content: "v"})
+	}
+
+	labels := map[string]string{"role": "edge"}
+	if res, err := Resolve(ctx, s, labels); err != nil || res != nil {
+		t.Fatalf("expected no resolution without assignments, got %+v, %v", res, err)
+	}
+
+	follow := &store.ConfigAssignment{Name: "edge", ConfigID: cfg.ID, Selector: labels}
+	s.CreateConfigAssignment(ctx, follow)
+
+	res, err := Resolve(ctx, s, labels)
+	if err != nil || res == nil || res.ConfigVersion != 0 || res.Error == "" {
+		t.Fatalf("expected an error without approved versions, got %+v, %v", res, err)
+	}
+
+	s.ApproveConfigVersion(ctx, cfg.ID, 2)
+	res, _ = Resolve(ctx, s, labels)
+	if res.ConfigID != cfg.ID || res.ConfigVersion != 2 || res.Error != "" {
+		t.Errorf("expected the latest approved version, got %+v", res)
+	}
+
+	// A pinned assignment at a higher priority wins
+	pinned := &store.ConfigAssignment{Name: "pinned", ConfigID: cfg.ID, Version: intPtr(3), Selector: labels, Priority: 1}
+	s.CreateConfigAssignment(ctx, pinned)
+	res, _ = Resolve(ctx, s, labels)
+	if res.Assignment == nil || res.Assignment.ID != pinned.ID || res.ConfigVersion != 3 {
+		t.Errorf("expected the pinned version, got %+v", res)
+	}
+
+	pinned.Version = intPtr(7)
+	s.UpdateConfigAssignment(ctx, pinned)
+	res, _ = Resolve(ctx, s, labels)
+	if res.ConfigVersion != 0 || res.Error == "" {
+		t.Errorf("expected an error for a missing pinned version, got %+v", res)
+	}
+}
@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultCheckpointInterval is how often checkpoints are written unless
+// configured otherwise.
+const DefaultCheckpointInterval = time.Hour
+
+// SignCheckpoint returns the hex HMAC-SHA256 of a checkpoint's sequence and
+// hash. The key lives outside the database, so database access alone is not
+// enough to forge a checkpoint.
+func SignCheckpoint(key []byte, sequence int64, hash string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("sentinel-hub audit checkpoint\n" + strconv.FormatInt(sequence, 10) + "\n" + hash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyCheckpoint reports whether a checkpoint was signed with key.
+func VerifyCheckpoint(key []byte, cp *store.AuditCheckpoint) bool {
+	expected := SignCheckpoint(key, cp.Sequence, cp.Hash)
+	return hmac.Equal([]byte(expected), []byte(cp.Signature))
+}
+
+// Checkpointer periodically records a signed checkpoint of the chain head.
+type Checkpointer struct {
+	store    store.Store
+	key      []byte
+	interval time.Duration
+}
+
+// NewCheckpointer creates a checkpointer that signs with key.
+func NewCheckpointer(s store.Store, key []byte, interval time.Duration) *Checkpointer {
+	if interval <= 0 {
+		interval = DefaultCheckpointInterval
+	}
+	return &Checkpointer{store: s, key: key, interval: interval}
+}
+
+// Run writes a checkpoint immediately and then once per interval until ctx
+// is cancelled.
+func (c *Checkpointer) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := c.Checkpoint(ctx); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("Failed to write audit checkpoint")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Checkpoint signs the current chain head. It returns nil if the chain is
+// empty or has not advanced since the latest checkpoint.
+func (c *Checkpointer) Checkpoint(ctx context.Context) (*store.AuditCheckpoint, error) {
+	sequence, hash, err := c.store.GetAuditChainHead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if sequence == 0 {
+		return nil, nil
+	}
+
+	latest, err := c.store.GetLatestAuditCheckpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && latest.Sequence >= sequence {
+		return nil, nil
+	}
+
+	cp := &store.AuditCheckpoint{
+		Sequence:  sequence,
+		Hash:      hash,
+		Signature: SignCheckpoint(c.key, sequence, hash),
+	}
+	if err := c.store.CreateAuditCheckpoint(ctx, cp); err != nil {
+		return nil, err
+	}
+
+	log.Debug().Int64("sequence", sequence).Msg("Audit checkpoint written")
+	return cp, nil
+}
@@ -0,0 +1,147 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+// Default rotation settings for FileSink.
+const (
+	DefaultFileMaxSize    = 100 << 20 // 100 MiB
+	DefaultFileMaxBackups = 5
+)
+
+// FileSink appends entries as newline-delimited JSON to a file. When the
+// file would grow past MaxSize it is rotated: path becomes path.1, path.1
+// becomes path.2 and so on, and the oldest backup beyond MaxBackups is
+// removed.
+type FileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewFileSink opens path for appending, creating it if needed. Zero
+// maxSize and maxBackups use the defaults.
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultFileMaxSize
+	}
+	if maxBackups <= 0 {
+		maxBackups = DefaultFileMaxBackups
+	}
+
+	s := &FileSink{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Name implements Sink.
+func (s *FileSink) Name() string {
+	return "file"
+}
+
+// Write implements Sink.
+func (s *FileSink) Write(ctx context.Context, entries []store.AuditLog) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range entries {
+		if err := enc.Encode(&entries[i]); err != nil {
+			return fmt.Errorf("failed to encode audit log: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	if s.size > 0 && s.size+int64(buf.Len()) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(buf.Bytes())
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write audit log file: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit log file: %w", err)
+	}
+
+	return nil
+}
+
+// Close implements Sink.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// open opens the current file and records its size.
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open audit log file: %w", err)
+	}
+
+	s.file = f
+	s.size = info.Size()
+	return nil
+}
+
+// rotate shifts the backups, moves the current file to path.1 and starts
+// a new one.
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("failed to close audit log file: %w", err)
+	}
+	s.file = nil
+
+	os.Remove(s.backupPath(s.maxBackups))
+	for i := s.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(s.backupPath(i), s.backupPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate audit log file: %w", err)
+		}
+	}
+	if err := os.Rename(s.path, s.backupPath(1)); err != nil {
+		return fmt.Errorf("failed to rotate audit log file: %w", err)
+	}
+
+	return s.open()
+}
+
+// backupPath returns the path of the nth backup.
+func (s *FileSink) backupPath(n int) string {
+	return s.path + "." + strconv.Itoa(n)
+}
@@ -0,0 +1,23 @@
+package audit
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	sinkDelivered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hub_audit_sink_delivered_total",
+		Help: "Audit log entries delivered to external sinks, by sink.",
+	}, []string{"sink"})
+
+	sinkErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hub_audit_sink_errors_total",
+		Help: "Failed audit sink delivery attempts, by sink.",
+	}, []string{"sink"})
+
+	sinkDeadLettered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hub_audit_sink_dead_lettered_total",
+		Help: "Audit log entries that could not be delivered to a sink, by sink.",
+	}, []string{"sink"})
+)
@@ -0,0 +1,284 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// Sink delivers audit log entries to an external system such as a SIEM.
+type Sink interface {
+	// Name identifies the sink in logs, metrics and dead letters.
+	Name() string
+	// Write delivers a batch of entries. A failed batch is retried whole,
+	// so delivery is at least once.
+	Write(ctx context.Context, entries []store.AuditLog) error
+	// Close releases the sink's connections and files.
+	Close() error
+}
+
+// RecorderConfig controls how entries are delivered to sinks.
+type RecorderConfig struct {
+	// BufferSize is how many entries may wait for each sink. Entries that
+	// do not fit go straight to the dead-letter file.
+	BufferSize int
+	// BatchSize is the most entries written to a sink at once.
+	BatchSize int
+	// MaxAttempts is how often a batch is tried before it is dead-lettered.
+	MaxAttempts int
+	// RetryBackoff is the delay before the first retry; it doubles with
+	// each further attempt.
+	RetryBackoff time.Duration
+	// DeadLetterFile receives entries that could not be delivered, one JSON
+	// object per line. Without it they are only logged.
+	DeadLetterFile string
+}
+
+// DefaultRecorderConfig returns the default delivery settings.
+func DefaultRecorderConfig() RecorderConfig {
+	return RecorderConfig{
+		BufferSize:     1000,
+		BatchSize:      100,
+		MaxAttempts:    5,
+		RetryBackoff:   time.Second,
+		DeadLetterFile: "audit-dead-letter.jsonl",
+	}
+}
+
+// withDefaults fills unset fields with their defaults.
+func (c RecorderConfig) withDefaults() RecorderConfig {
+	d := DefaultRecorderConfig()
+	if c.BufferSize <= 0 {
+		c.BufferSize = d.BufferSize
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = d.BatchSize
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = d.MaxAttempts
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = d.RetryBackoff
+	}
+	return c
+}
+
+// maxRetryBackoff caps the delay between delivery attempts.
+const maxRetryBackoff = time.Minute
+
+// Recorder is the single entry point for writing audit logs. Entries are
+// written to the store, which stays the source of truth, and then handed
+// to each sink's queue without waiting for delivery.
+type Recorder struct {
+	store  store.Store
+	config RecorderConfig
+
+	workers []*sinkWorker
+	wg      sync.WaitGroup
+	// ctx is cancelled when Close gives up on delivering the queues.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.RWMutex
+	closed bool
+
+	deadMu sync.Mutex
+}
+
+// sinkWorker delivers queued entries to one sink.
+type sinkWorker struct {
+	sink  Sink
+	queue chan store.AuditLog
+}
+
+// NewRecorder creates a recorder that writes to s and streams every entry
+// to the given sinks.
+func NewRecorder(s store.Store, config RecorderConfig, sinks ...Sink) *Recorder {
+	r := &Recorder{
+		store:  s,
+		config: config.withDefaults(),
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+
+	for _, sink := range sinks {
+		w := &sinkWorker{sink: sink, queue: make(chan store.AuditLog, r.config.BufferSize)}
+		r.workers = append(r.workers, w)
+		r.wg.Add(1)
+		go r.run(w)
+	}
+
+	return r
+}
+
+// Record writes an entry to the store and queues it for the sinks.
+func (r *Recorder) Record(ctx context.Context, entry *store.AuditLog) error {
+	if err := r.store.CreateAuditLog(ctx, entry); err != nil {
+		return err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return nil
+	}
+
+	for _, w := range r.workers {
+		select {
+		case w.queue <- *entry:
+		default:
+			r.deadLetter(w.sink, []store.AuditLog{*entry}, errors.New("queue full"))
+		}
+	}
+	return nil
+}
+
+// Close stops accepting entries for the sinks, delivers what is queued
+// until ctx expires, dead-letters the rest and closes the sinks.
+func (r *Recorder) Close(ctx context.Context) error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	for _, w := range r.workers {
+		close(w.queue)
+	}
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// Abandon retries; workers dead-letter their remaining entries
+		r.cancel()
+		<-done
+	}
+	r.cancel()
+
+	var firstErr error
+	for _, w := range r.workers {
+		if err := w.sink.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// run delivers a sink's queue in batches until the queue is closed.
+func (r *Recorder) run(w *sinkWorker) {
+	defer r.wg.Done()
+
+	for entry := range w.queue {
+		batch := []store.AuditLog{entry}
+	fill:
+		for len(batch) < r.config.BatchSize {
+			select {
+			case next, ok := <-w.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, next)
+			default:
+				break fill
+			}
+		}
+
+		r.deliver(w.sink, batch)
+	}
+}
+
+// deliver writes a batch to a sink, retrying with exponential backoff, and
+// dead-letters it if every attempt fails.
+func (r *Recorder) deliver(sink Sink, batch []store.AuditLog) {
+	if r.ctx.Err() != nil {
+		r.deadLetter(sink, batch, errors.New("recorder closed before delivery"))
+		return
+	}
+
+	backoff := r.config.RetryBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(r.ctx, 30*time.Second)
+		err = sink.Write(ctx, batch)
+		cancel()
+		if err == nil {
+			sinkDelivered.WithLabelValues(sink.Name()).Add(float64(len(batch)))
+			return
+		}
+
+		sinkErrors.WithLabelValues(sink.Name()).Inc()
+		log.Warn().Err(err).
+			Str("sink", sink.Name()).
+			Int("entries", len(batch)).
+			Int("attempt", attempt).
+			Msg("Failed to deliver audit logs")
+
+		if attempt >= r.config.MaxAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-r.ctx.Done():
+			r.deadLetter(sink, batch, err)
+			return
+		}
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+
+	r.deadLetter(sink, batch, err)
+}
+
+// deadLetterEntry is a line of the dead-letter file.
+type deadLetterEntry struct {
+	Sink     string         `json:"sink"`
+	Error    string         `json:"error"`
+	FailedAt time.Time      `json:"failed_at"`
+	Entry    store.AuditLog `json:"entry"`
+}
+
+// deadLetter appends undeliverable entries to the dead-letter file.
+func (r *Recorder) deadLetter(sink Sink, entries []store.AuditLog, cause error) {
+	sinkDeadLettered.WithLabelValues(sink.Name()).Add(float64(len(entries)))
+
+	if r.config.DeadLetterFile == "" {
+		log.Error().Err(cause).Str("sink", sink.Name()).Int("entries", len(entries)).
+			Msg("Dropped undeliverable audit logs; no dead-letter file configured")
+		return
+	}
+
+	r.deadMu.Lock()
+	defer r.deadMu.Unlock()
+
+	f, err := os.OpenFile(r.config.DeadLetterFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		log.Error().Err(err).Str("sink", sink.Name()).Int("entries", len(entries)).
+			Msg("Failed to open audit dead-letter file; audit logs dropped")
+		return
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	now := time.Now().UTC()
+	for _, entry := range entries {
+		if err := enc.Encode(deadLetterEntry{Sink: sink.Name(), Error: cause.Error(), FailedAt: now, Entry: entry}); err != nil {
+			log.Error().Err(err).Str("sink", sink.Name()).Msg("Failed to write audit dead letter")
+			return
+		}
+	}
+
+	log.Error().Err(cause).Str("sink", sink.Name()).Int("entries", len(entries)).
+		Str("file", r.config.DeadLetterFile).Msg("Audit logs dead-lettered")
+}
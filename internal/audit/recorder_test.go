@@ -0,0 +1,211 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+// recordingSink collects delivered entries after failing its first
+// failures writes.
+type recordingSink struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	entries  []store.AuditLog
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Write(ctx context.Context, entries []store.AuditLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts++
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("unavailable")
+	}
+	s.entries = append(s.entries, entries...)
+	return nil
+}
+
+func (s *recordingSink) Close() error { return nil }
+
+func TestRecorder_DeliversToSinks(t *testing.T) {
+	ctx := context.Background()
+	s, _ := setupTestChain(t, 0)
+
+	sink := &recordingSink{failures: 1}
+	r := NewRecorder(s, RecorderConfig{RetryBackoff: time.Millisecond}, sink)
+
+	for _, action := range []string{"create", "update"} {
+		if err := r.Record(ctx, &store.AuditLog{Action: action, ResourceType: "config"}); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+	if err := r.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if len(sink.entries) != 2 || sink.entries[0].Action != "create" {
+		t.Fatalf("expected both entries delivered in order, got %+v", sink.entries)
+	}
+	// Sinks receive the chained entry as stored
+	if sink.entries[1].Sequence != 2 || sink.entries[1].Hash == "" {
+		t.Errorf("expected chained entry, got %+v", sink.entries[1])
+	}
+
+	logs, _ := s.ListAuditLogs(ctx, store.ListAuditLogsOptions{})
+	if len(logs) != 2 {
+		t.Errorf("expected 2 entries in the store, got %d", len(logs))
+	}
+}
+
+func TestRecorder_DeadLettersUndeliverable(t *testing.T) {
+	ctx := context.Background()
+	s, _ := setupTestChain(t, 0)
+
+	deadLetters := filepath.Join(t.TempDir(), "dead.jsonl")
+	sink := &recordingSink{failures: 100}
+	r := NewRecorder(s, RecorderConfig{
+		MaxAttempts:    3,
+		RetryBackoff:   time.Millisecond,
+		DeadLetterFile: deadLetters,
+	}, sink)
+
+	if err := r.Record(ctx, &store.AuditLog{Action: "delete", ResourceType: "config"}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if err := r.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if sink.attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", sink.attempts)
+	}
+
+	data, err := os.ReadFile(deadLetters)
+	if err != nil {
+		t.Fatalf("failed to read dead-letter file: %v", err)
+	}
+	var letter deadLetterEntry
+	if err := json.Unmarshal(data, &letter); err != nil {
+		t.Fatalf("invalid dead letter: %v", err)
+	}
+	if letter.Sink != "recording" || letter.Error != "unavailable" || letter.Entry.Action != "delete" {
+		t.Errorf("unexpected dead letter: %+v", letter)
+	}
+}
+
+func TestFileSink_Rotates(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	sink, err := NewFileSink(path, 200, 2)
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+	defer sink.Close()
+
+	for i := 0; i < 5; i++ {
+		entry := store.AuditLog{ID: strings.Repeat("x", 100), Action: "update", ResourceType: "config"}
+		if err := sink.Write(ctx, []store.AuditLog{entry}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("expected %s to exist: %v", filepath.Base(name), err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("expected backups beyond the limit to be removed")
+	}
+}
+
+func TestSyslogSink_FormatsRFC5424(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('}')
+		received <- line
+	}()
+
+	sink, err := NewSyslogSink("tcp://"+ln.Addr().String(), "")
+	if err != nil {
+		t.Fatalf("NewSyslogSink failed: %v", err)
+	}
+	defer sink.Close()
+
+	entry := store.AuditLog{
+		ID:           "entry-1",
+		Timestamp:    time.Date(2026, 1, 2, 3, 4, 5, 6000, time.UTC),
+		Action:       "rollback",
+		ResourceType: "config",
+	}
+	if err := sink.Write(context.Background(), []store.AuditLog{entry}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	msg := <-received
+	length, rest, _ := strings.Cut(msg, " ")
+	if length == "" || !strings.HasPrefix(rest, "<110>1 2026-01-02T03:04:05.000006Z ") {
+		t.Fatalf("unexpected syslog message: %q", msg)
+	}
+	if !strings.Contains(rest, " sentinel-hub ") || !strings.Contains(rest, " rollback - {") {
+		t.Errorf("expected app name, MSGID and JSON body, got %q", rest)
+	}
+}
+
+func TestWebhookSink_Posts(t *testing.T) {
+	var got webhookPayload
+	var auth string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	if _, err := NewWebhookSink("http://siem.example.com", ""); err == nil {
+		t.Error("expected plain HTTP URL to be rejected")
+	}
+
+	sink, err := NewWebhookSink(srv.URL, "secret")
+	if err != nil {
+		t.Fatalf("NewWebhookSink failed: %v", err)
+	}
+	sink.client = srv.Client()
+
+	if err := sink.Write(context.Background(), []store.AuditLog{{ID: "entry-1", Action: "create"}}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if len(got.Entries) != 1 || got.Entries[0].ID != "entry-1" {
+		t.Errorf("unexpected payload: %+v", got)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("expected bearer token, got %q", auth)
+	}
+}
@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+// syslogPriority is facility 13 (log audit) at severity 6 (informational).
+const syslogPriority = 13*8 + 6
+
+// syslogTimestamp is the RFC 5424 timestamp format, which allows at most
+// microsecond precision.
+const syslogTimestamp = "2006-01-02T15:04:05.000000Z07:00"
+
+// SyslogSink sends entries as RFC 5424 messages over UDP or TCP. The
+// message body is the entry as JSON and the MSGID is its action. TCP
+// messages are framed by octet counting (RFC 6587).
+type SyslogSink struct {
+	network  string
+	addr     string
+	hostname string
+	appName  string
+	procID   string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogSink creates a sink for an address such as udp://siem:514 or
+// tcp://siem:601. The connection is opened on first use.
+func NewSyslogSink(rawURL, appName string) (*SyslogSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid syslog address: %w", err)
+	}
+	if u.Scheme != "udp" && u.Scheme != "tcp" {
+		return nil, fmt.Errorf("syslog address must start with udp:// or tcp://, got %q", rawURL)
+	}
+	if u.Port() == "" {
+		return nil, fmt.Errorf("syslog address %q has no port", rawURL)
+	}
+
+	hostname, _ := os.Hostname()
+	if appName == "" {
+		appName = "sentinel-hub"
+	}
+
+	return &SyslogSink{
+		network:  u.Scheme,
+		addr:     u.Host,
+		hostname: syslogField(hostname, 255),
+		appName:  syslogField(appName, 48),
+		procID:   strconv.Itoa(os.Getpid()),
+	}, nil
+}
+
+// Name implements Sink.
+func (s *SyslogSink) Name() string {
+	return "syslog"
+}
+
+// Write implements Sink. A failed connection is dropped and redialed on
+// the next attempt.
+func (s *SyslogSink) Write(ctx context.Context, entries []store.AuditLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, s.network, s.addr)
+		if err != nil {
+			return fmt.Errorf("failed to connect to syslog: %w", err)
+		}
+		s.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+	}
+
+	for i := range entries {
+		msg, err := s.format(&entries[i])
+		if err != nil {
+			return err
+		}
+		if s.network == "tcp" {
+			msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}
+		if _, err := s.conn.Write(msg); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return fmt.Errorf("failed to write to syslog: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Close implements Sink.
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// format renders an entry as an RFC 5424 message without structured data.
+func (s *SyslogSink) format(entry *store.AuditLog) ([]byte, error) {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit log: %w", err)
+	}
+
+	header := fmt.Sprintf("<%d>1 %s %s %s %s %s - ",
+		syslogPriority,
+		entry.Timestamp.UTC().Format(syslogTimestamp),
+		s.hostname,
+		s.appName,
+		s.procID,
+		syslogField(entry.Action, 32),
+	)
+	return append([]byte(header), body...), nil
+}
+
+// syslogField makes a header field valid: printable ASCII without spaces,
+// at most limit characters, and "-" when empty.
+func syslogField(value string, limit int) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+	if len(value) > limit {
+		value = value[:limit]
+	}
+	if value == "" {
+		return "-"
+	}
+	return value
+}
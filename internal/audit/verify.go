@@ -0,0 +1,169 @@
+// Package audit records the hub's audit log, streams it to external sinks,
+// and verifies its hash chain against signed checkpoints.
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+// verifyPageSize is the number of entries read per query while verifying.
+const verifyPageSize = 1000
+
+// Break describes the first point at which the audit chain fails to verify.
+type Break struct {
+	Sequence     int64  `json:"sequence"`
+	EntryID      string `json:"entry_id,omitempty"`
+	CheckpointID string `json:"checkpoint_id,omitempty"`
+	Reason       string `json:"reason"`
+}
+
+// Result is the outcome of verifying the audit chain.
+type Result struct {
+	OK bool `json:"ok"`
+	// Entries is the number of chained entries checked.
+	Entries       int64 `json:"entries"`
+	FirstSequence int64 `json:"first_sequence,omitempty"`
+	LastSequence  int64 `json:"last_sequence,omitempty"`
+	// Checkpoints is the number of checkpoints matched against entries.
+	Checkpoints int `json:"checkpoints"`
+	// SignaturesVerified is false when no checkpoint key was given, in which
+	// case checkpoints are matched but their signatures are not checked.
+	SignaturesVerified bool `json:"signatures_verified"`
+	// LegacyEntries were written before the chain existed and cannot be
+	// verified.
+	LegacyEntries int64  `json:"legacy_entries"`
+	Break         *Break `json:"first_broken_link,omitempty"`
+}
+
+// Verify walks the audit chain and reports the first broken link: an entry
+// whose content no longer matches its hash, a missing or inserted entry, or
+// a checkpoint that does not match the chain. Entries before the first
+// remaining one may have been removed by retention; a checkpoint just before
+// it still pins its link. With a key, checkpoint signatures are checked too.
+func Verify(ctx context.Context, s store.Store, key []byte) (*Result, error) {
+	result := &Result{SignaturesVerified: len(key) > 0}
+
+	// Checkpoints and the head are read before the entries, so entries
+	// appended during verification are simply not covered.
+	checkpoints, err := s.ListAuditCheckpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	headSequence, headHash, err := s.GetAuditChainHead(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bySequence := make(map[int64][]store.AuditCheckpoint)
+	for _, cp := range checkpoints {
+		if len(key) > 0 && !VerifyCheckpoint(key, &cp) {
+			return result.broken(Break{Sequence: cp.Sequence, CheckpointID: cp.ID, Reason: "checkpoint signature is invalid"}), nil
+		}
+		if cp.Sequence > headSequence {
+			return result.broken(Break{Sequence: cp.Sequence, CheckpointID: cp.ID, Reason: "checkpoint is ahead of the chain head; entries were removed from the end"}), nil
+		}
+		bySequence[cp.Sequence] = append(bySequence[cp.Sequence], cp)
+	}
+
+	var first, prev *store.AuditLog
+	for after := int64(0); ; {
+		entries, err := s.ListAuditChain(ctx, after, verifyPageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range entries {
+			entry := &entries[i]
+			if entry.Sequence > headSequence {
+				break
+			}
+
+			if prev == nil {
+				first = entry
+				result.FirstSequence = entry.Sequence
+				if entry.Sequence == 1 && entry.PrevHash != "" {
+					return result.broken(entryBreak(entry, "first entry links to a previous entry")), nil
+				}
+				for _, cp := range bySequence[entry.Sequence-1] {
+					if entry.PrevHash != cp.Hash {
+						return result.broken(entryBreak(entry, "previous hash does not match checkpoint "+cp.ID)), nil
+					}
+					result.Checkpoints++
+				}
+			} else {
+				if entry.Sequence != prev.Sequence+1 {
+					return result.broken(Break{
+						Sequence: prev.Sequence + 1,
+						Reason:   fmt.Sprintf("entries %d to %d are missing", prev.Sequence+1, entry.Sequence-1),
+					}), nil
+				}
+				if entry.PrevHash != prev.Hash {
+					return result.broken(entryBreak(entry, "previous hash does not match the previous entry")), nil
+				}
+			}
+
+			if store.AuditLogHash(entry) != entry.Hash {
+				return result.broken(entryBreak(entry, "content does not match its hash")), nil
+			}
+			for _, cp := range bySequence[entry.Sequence] {
+				if entry.Hash != cp.Hash {
+					return result.broken(entryBreak(entry, "hash does not match checkpoint "+cp.ID)), nil
+				}
+				result.Checkpoints++
+			}
+
+			prev = entry
+			result.Entries++
+			result.LastSequence = entry.Sequence
+		}
+
+		if len(entries) < verifyPageSize || (prev != nil && prev.Sequence >= headSequence) {
+			break
+		}
+		after = entries[len(entries)-1].Sequence
+	}
+
+	if prev != nil {
+		if prev.Sequence != headSequence || prev.Hash != headHash {
+			return result.broken(Break{
+				Sequence: prev.Sequence + 1,
+				Reason:   fmt.Sprintf("chain head is at %d but the last entry is %d; entries were removed from the end", headSequence, prev.Sequence),
+			}), nil
+		}
+
+		// Entries without a hash are only expected from before the chain
+		unchained, err := s.CountUnchainedAuditLogs(ctx, &first.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		if unchained > 0 {
+			return result.broken(Break{
+				Sequence: result.FirstSequence,
+				Reason:   fmt.Sprintf("%d entries without a hash were written after the chain started", unchained),
+			}), nil
+		}
+	}
+
+	legacy, err := s.CountUnchainedAuditLogs(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	result.LegacyEntries = legacy
+	result.OK = true
+	return result, nil
+}
+
+// broken records the break and returns the result.
+func (r *Result) broken(b Break) *Result {
+	r.OK = false
+	r.Break = &b
+	return r
+}
+
+// entryBreak describes a break at an entry.
+func entryBreak(entry *store.AuditLog, reason string) Break {
+	return Break{Sequence: entry.Sequence, EntryID: entry.ID, Reason: reason}
+}
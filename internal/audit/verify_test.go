@@ -0,0 +1,230 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+// setupTestChain creates a store with n chained audit log entries and
+// returns it with a raw connection for tampering.
+func setupTestChain(t *testing.T, n int) (store.Store, *sql.DB) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "hub.db")
+	s, err := store.New(path)
+	if err != nil {
+		t.Fatalf("failed to create test store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	for i := 0; i < n; i++ {
+		if err := s.CreateAuditLog(context.Background(), &store.AuditLog{Action: "update", ResourceType: "config"}); err != nil {
+			t.Fatalf("CreateAuditLog failed: %v", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return s, db
+}
+
+func mustExec(t *testing.T, db *sql.DB, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("failed to tamper with audit log: %v", err)
+	}
+}
+
+func TestVerify_IntactChain(t *testing.T) {
+	ctx := context.Background()
+	s, db := setupTestChain(t, 5)
+
+	// An entry from before the chain existed
+	mustExec(t, db, `INSERT INTO audit_logs (id, timestamp, action, resource_type) VALUES (?, ?, ?, ?)`,
+		"legacy", time.Now().UTC().Add(-time.Hour), "create", "config")
+
+	if _, err := NewCheckpointer(s, testKey, time.Hour).Checkpoint(ctx); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+
+	result, err := Verify(ctx, s, testKey)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !result.OK {
+		t.Fatalf("expected chain to verify, got %+v", result.Break)
+	}
+	if result.Entries != 5 || result.FirstSequence != 1 || result.LastSequence != 5 {
+		t.Errorf("expected entries 1 to 5, got %+v", result)
+	}
+	if result.Checkpoints != 1 || !result.SignaturesVerified {
+		t.Errorf("expected 1 signed checkpoint, got %+v", result)
+	}
+	if result.LegacyEntries != 1 {
+		t.Errorf("expected 1 legacy entry, got %d", result.LegacyEntries)
+	}
+}
+
+func TestVerify_DetectsTampering(t *testing.T) {
+	tests := []struct {
+		name     string
+		tamper   func(t *testing.T, s store.Store, db *sql.DB)
+		sequence int64
+		reason   string
+	}{
+		{
+			name: "modified entry",
+			tamper: func(t *testing.T, s store.Store, db *sql.DB) {
+				mustExec(t, db, `UPDATE audit_logs SET action = 'delete' WHERE sequence = 3`)
+			},
+			sequence: 3,
+			reason:   "content does not match",
+		},
+		{
+			// Only the checkpoint catches a rewrite that keeps the chain consistent
+			name: "rewritten last entry and head",
+			tamper: func(t *testing.T, s store.Store, db *sql.DB) {
+				entries, err := s.ListAuditChain(context.Background(), 4, 1)
+				if err != nil || len(entries) != 1 {
+					t.Fatalf("failed to read entry: %v", err)
+				}
+				entry := entries[0]
+				entry.Action = "delete"
+				hash := store.AuditLogHash(&entry)
+				mustExec(t, db, `UPDATE audit_logs SET action = ?, hash = ? WHERE sequence = 5`, entry.Action, hash)
+				mustExec(t, db, `UPDATE audit_chain SET hash = ?`, hash)
+			},
+			sequence: 5,
+			reason:   "does not match checkpoint",
+		},
+		{
+			name: "deleted entry",
+			tamper: func(t *testing.T, s store.Store, db *sql.DB) {
+				mustExec(t, db, `DELETE FROM audit_logs WHERE sequence = 2`)
+			},
+			sequence: 2,
+			reason:   "missing",
+		},
+		{
+			name: "truncated end",
+			tamper: func(t *testing.T, s store.Store, db *sql.DB) {
+				mustExec(t, db, `DELETE FROM audit_logs WHERE sequence = 5`)
+			},
+			sequence: 5,
+			reason:   "removed from the end",
+		},
+		{
+			name: "inserted entry without hash",
+			tamper: func(t *testing.T, s store.Store, db *sql.DB) {
+				mustExec(t, db, `INSERT INTO audit_logs (id, timestamp, action, resource_type) VALUES (?, ?, ?, ?)`,
+					"forged", time.Now().UTC().Add(time.Hour), "create", "user")
+			},
+			sequence: 1,
+			reason:   "without a hash",
+		},
+		{
+			name: "forged checkpoint",
+			tamper: func(t *testing.T, s store.Store, db *sql.DB) {
+				mustExec(t, db, `UPDATE audit_checkpoints SET hash = 'forged'`)
+			},
+			sequence: 5,
+			reason:   "signature is invalid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, db := setupTestChain(t, 5)
+			if _, err := NewCheckpointer(s, testKey, time.Hour).Checkpoint(ctx); err != nil {
+				t.Fatalf("Checkpoint failed: %v", err)
+			}
+
+			tt.tamper(t, s, db)
+
+			result, err := Verify(ctx, s, testKey)
+			if err != nil {
+				t.Fatalf("Verify failed: %v", err)
+			}
+			if result.OK || result.Break == nil {
+				t.Fatal("expected verification to fail")
+			}
+			if result.Break.Sequence != tt.sequence || !strings.Contains(result.Break.Reason, tt.reason) {
+				t.Errorf("expected break at %d (%s), got %+v", tt.sequence, tt.reason, result.Break)
+			}
+		})
+	}
+}
+
+func TestVerify_AfterRetention(t *testing.T) {
+	ctx := context.Background()
+	s, db := setupTestChain(t, 3)
+	checkpointer := NewCheckpointer(s, testKey, time.Hour)
+	if _, err := checkpointer.Checkpoint(ctx); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+
+	if _, err := s.DeleteAuditLogsUntil(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("DeleteAuditLogsUntil failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.CreateAuditLog(ctx, &store.AuditLog{Action: "update", ResourceType: "config"}); err != nil {
+			t.Fatalf("CreateAuditLog failed: %v", err)
+		}
+	}
+
+	result, err := Verify(ctx, s, testKey)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !result.OK || result.FirstSequence != 4 || result.Checkpoints != 1 {
+		t.Fatalf("expected entries 4 to 5 linked to the checkpoint, got %+v", result)
+	}
+
+	// The checkpoint still pins the first remaining entry's link
+	mustExec(t, db, `UPDATE audit_logs SET prev_hash = 'forged' WHERE sequence = 4`)
+	result, err = Verify(ctx, s, testKey)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if result.OK || result.Break.Sequence != 4 {
+		t.Errorf("expected break at 4, got %+v", result.Break)
+	}
+}
+
+func TestCheckpointer_SkipsUnchangedHead(t *testing.T) {
+	ctx := context.Background()
+
+	s, _ := setupTestChain(t, 0)
+	checkpointer := NewCheckpointer(s, testKey, time.Hour)
+	if cp, err := checkpointer.Checkpoint(ctx); err != nil || cp != nil {
+		t.Fatalf("expected no checkpoint of an empty chain, got %+v, %v", cp, err)
+	}
+
+	if err := s.CreateAuditLog(ctx, &store.AuditLog{Action: "create", ResourceType: "config"}); err != nil {
+		t.Fatalf("CreateAuditLog failed: %v", err)
+	}
+	cp, err := checkpointer.Checkpoint(ctx)
+	if err != nil || cp == nil {
+		t.Fatalf("expected a checkpoint, got %+v, %v", cp, err)
+	}
+	if !VerifyCheckpoint(testKey, cp) || VerifyCheckpoint([]byte("another key of at least 32 bytes"), cp) {
+		t.Error("expected checkpoint to verify only with its key")
+	}
+
+	if cp, err := checkpointer.Checkpoint(ctx); err != nil || cp != nil {
+		t.Errorf("expected no checkpoint of an unchanged head, got %+v, %v", cp, err)
+	}
+}
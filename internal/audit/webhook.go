@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+// WebhookSink posts batches of entries to an HTTPS endpoint as
+// {"entries": [...]}. Any 2xx response counts as delivered.
+type WebhookSink struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// webhookPayload is the body posted by WebhookSink.
+type webhookPayload struct {
+	Entries []store.AuditLog `json:"entries"`
+}
+
+// NewWebhookSink creates a sink posting to an https:// URL. A non-empty
+// token is sent as a bearer token.
+func NewWebhookSink(rawURL, token string) (*WebhookSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("webhook URL must be an https:// URL, got %q", rawURL)
+	}
+
+	return &WebhookSink{
+		url:    rawURL,
+		token:  token,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Name implements Sink.
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+// Write implements Sink.
+func (s *WebhookSink) Write(ctx context.Context, entries []store.AuditLog) error {
+	body, err := json.Marshal(webhookPayload{Entries: entries})
+	if err != nil {
+		return fmt.Errorf("failed to encode audit logs: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "sentinel-hub")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post audit logs: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("audit webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Close implements Sink.
+func (s *WebhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
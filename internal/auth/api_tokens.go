@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// APITokenPrefix marks bearer tokens that are API tokens rather than JWTs.
+const APITokenPrefix = "shub_"
+
+// apiTokenLastUsedInterval limits how often last_used_at is written for a token.
+const apiTokenLastUsedInterval = time.Minute
+
+// serviceAccountEmailDomain is used to give service accounts a unique,
+// non-routable email address in the users table.
+const serviceAccountEmailDomain = "service-accounts.sentinel-hub.invalid"
+
+// API token scopes.
+const (
+	ScopeInstancesRead     = "instances:read"
+	ScopeInstancesWrite    = "instances:write"
+	ScopeInstancesDelete   = "instances:delete"
+	ScopeConfigsRead       = "configs:read"
+	ScopeConfigsWrite      = "configs:write"
+	ScopeConfigsDelete     = "configs:delete"
+	ScopeDeploymentsRead   = "deployments:read"
+	ScopeDeploymentsCreate = "deployments:create"
+	ScopeDeploymentsCancel = "deployments:cancel"
+	ScopeUsersRead         = "users:read"
+	ScopeUsersWrite        = "users:write"
+	ScopeAuditRead         = "audit:read"
+	ScopeWebhooksRead      = "webhooks:read"
+	ScopeWebhooksWrite     = "webhooks:write"
+	ScopeSecretsRead       = "secrets:read"
+	ScopeSecretsWrite      = "secrets:write"
+)
+
+var (
+	// ErrInvalidScope is returned when a requested scope is unknown.
+	ErrInvalidScope = errors.New("invalid scope")
+
+	// ErrScopeExceedsRole is returned when the token owner does not hold a requested scope.
+	ErrScopeExceedsRole = errors.New("scope exceeds owner role")
+
+	// ErrAPITokenRevoked is returned when an API token has been revoked.
+	ErrAPITokenRevoked = errors.New("api token has been revoked")
+
+	// ErrAPITokenNotFound is returned when an API token does not exist.
+	ErrAPITokenNotFound = errors.New("api token not found")
+)
+
+// APITokenContextKey is the key for storing the API token in context.
+const APITokenContextKey contextKey = "api_token"
+
+// GetAPITokenFromContext returns the API token used to authenticate the
+// request, or nil if the request was authenticated with a user JWT.
+func GetAPITokenFromContext(ctx context.Context) *store.APIToken {
+	if token, ok := ctx.Value(APITokenContextKey).(*store.APIToken); ok {
+		return token
+	}
+	return nil
+}
+
+// Scopes returns all known API token scopes.
+func Scopes() []string {
+	return []string{
+		ScopeInstancesRead, ScopeInstancesWrite, ScopeInstancesDelete,
+		ScopeConfigsRead, ScopeConfigsWrite, ScopeConfigsDelete,
+		ScopeDeploymentsRead, ScopeDeploymentsCreate, ScopeDeploymentsCancel,
+		ScopeUsersRead, ScopeUsersWrite,
+		ScopeAuditRead,
+		ScopeWebhooksRead, ScopeWebhooksWrite,
+		ScopeSecretsRead, ScopeSecretsWrite,
+	}
+}
+
+// CreateAPITokenRequest describes a new API token.
+type CreateAPITokenRequest struct {
+	Name      string
+	Owner     *store.User
+	Scopes    []string
+	Labels    map[string]string
+	ExpiresAt *time.Time
+	CreatedBy string
+}
+
+// CreateAPIToken creates a new API token and returns the plaintext token.
+// The plaintext is only available at creation time; only its hash is stored.
+func (s *Service) CreateAPIToken(ctx context.Context, req CreateAPITokenRequest) (string, *store.APIToken, error) {
+	if req.Owner == nil {
+		return "", nil, ErrUserNotFound
+	}
+	if len(req.Scopes) == 0 {
+		return "", nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+
+	// A token can never hold more than its owner
+	policy, err := s.PolicyFor(ctx, req.Owner, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to load owner permissions: %w", err)
+	}
+	known := make(map[string]bool)
+	for _, scope := range Scopes() {
+		known[scope] = true
+	}
+	for _, scope := range req.Scopes {
+		if !known[scope] {
+			return "", nil, fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+		if !policy.Has(scope) {
+			return "", nil, fmt.Errorf("%w: %s", ErrScopeExceedsRole, scope)
+		}
+	}
+
+	random, err := GenerateRandomToken(32)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	plaintext := APITokenPrefix + random
+
+	token := &store.APIToken{
+		Name:        req.Name,
+		UserID:      req.Owner.ID,
+		TokenHash:   HashToken(plaintext),
+		TokenPrefix: plaintext[:len(APITokenPrefix)+8],
+		Scopes:      req.Scopes,
+		Labels:      req.Labels,
+		ExpiresAt:   req.ExpiresAt,
+	}
+	if req.CreatedBy != "" {
+		token.CreatedBy = &req.CreatedBy
+	}
+
+	if err := s.store.CreateAPIToken(ctx, token); err != nil {
+		return "", nil, err
+	}
+
+	log.Info().
+		Str("token_id", token.ID).
+		Str("user_id", token.UserID).
+		Strs("scopes", token.Scopes).
+		Msg("API token created")
+
+	return plaintext, token, nil
+}
+
+// ValidateAPIToken validates a plaintext API token and returns the token and its owner.
+func (s *Service) ValidateAPIToken(ctx context.Context, plaintext string) (*store.APIToken, *store.User, error) {
+	if !strings.HasPrefix(plaintext, APITokenPrefix) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	token, err := s.store.GetAPITokenByHash(ctx, HashToken(plaintext))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get api token: %w", err)
+	}
+	if token == nil {
+		return nil, nil, ErrInvalidToken
+	}
+	if token.RevokedAt != nil {
+		return nil, nil, ErrAPITokenRevoked
+	}
+
+	now := time.Now().UTC()
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return nil, nil, ErrTokenExpired
+	}
+
+	user, err := s.store.GetUser(ctx, token.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, nil, ErrUserNotFound
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= apiTokenLastUsedInterval {
+		if err := s.store.UpdateAPITokenLastUsed(ctx, token.ID, now); err != nil {
+			log.Warn().Err(err).Str("token_id", token.ID).Msg("Failed to update api token last used")
+		} else {
+			token.LastUsedAt = &now
+		}
+	}
+
+	return token, user, nil
+}
+
+// RevokeAPIToken revokes an API token.
+func (s *Service) RevokeAPIToken(ctx context.Context, id string) error {
+	if err := s.store.RevokeAPIToken(ctx, id); err != nil {
+		if err.Error() == "api token not found" {
+			return ErrAPITokenNotFound
+		}
+		return err
+	}
+
+	log.Info().Str("token_id", id).Msg("API token revoked")
+	return nil
+}
+
+// CreateServiceAccount creates a service account user that can own API tokens.
+func (s *Service) CreateServiceAccount(ctx context.Context, name, description string, role store.UserRole, createdBy string) (*store.ServiceAccount, error) {
+	user := &store.User{
+		Email: name + "@" + serviceAccountEmailDomain,
+		Name:  name,
+		Role:  role,
+	}
+
+	sa := &store.ServiceAccount{}
+	if description != "" {
+		sa.Description = &description
+	}
+	if createdBy != "" {
+		sa.CreatedBy = &createdBy
+	}
+
+	if err := s.store.CreateServiceAccount(ctx, user, sa); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("user_id", sa.UserID).
+		Str("name", sa.Name).
+		Str("role", string(sa.Role)).
+		Msg("Service account created")
+
+	return sa, nil
+}
+
+// HasScope reports whether the request may use the given scope. Requests
+// authenticated with a user JWT are not scope-restricted.
+func HasScope(ctx context.Context, scope string) bool {
+	token := GetAPITokenFromContext(ctx)
+	if token == nil {
+		return true
+	}
+	for _, s := range token.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// RestrictLabelSelector narrows a label selector to the labels the request's
+// API token is restricted to. It returns false if the selector conflicts
+// with the restriction.
+func RestrictLabelSelector(ctx context.Context, selector map[string]string) (map[string]string, bool) {
+	token := GetAPITokenFromContext(ctx)
+	if token == nil || len(token.Labels) == 0 {
+		return selector, true
+	}
+
+	result := make(map[string]string, len(selector)+len(token.Labels))
+	for k, v := range selector {
+		result[k] = v
+	}
+	for k, v := range token.Labels {
+		if existing, ok := result[k]; ok && existing != v {
+			return nil, false
+		}
+		result[k] = v
+	}
+	return result, true
+}
@@ -0,0 +1,247 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+func TestService_CreateAPIToken(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	user, _ := svc.CreateUser(ctx, "ci@example.com", "CI", "TestPassword123", store.UserRoleOperator)
+
+	plaintext, token, err := svc.CreateAPIToken(ctx, CreateAPITokenRequest{
+		Name:   "ci-pipeline",
+		Owner:  user,
+		Scopes: []string{ScopeConfigsWrite, ScopeDeploymentsCreate},
+		Labels: map[string]string{"env": "staging"},
+	})
+	if err != nil {
+		t.Fatalf("CreateAPIToken failed: %v", err)
+	}
+
+	if !strings.HasPrefix(plaintext, APITokenPrefix) {
+		t.Errorf("token %q missing prefix %q", plaintext, APITokenPrefix)
+	}
+	if !strings.HasPrefix(plaintext, token.TokenPrefix) {
+		t.Errorf("token prefix %q does not match token", token.TokenPrefix)
+	}
+
+	stored, err := db.GetAPIToken(ctx, token.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("GetAPIToken failed: %v", err)
+	}
+	if stored.TokenHash == plaintext || stored.TokenHash != HashToken(plaintext) {
+		t.Error("token should be stored hashed")
+	}
+	if len(stored.Scopes) != 2 || stored.Labels["env"] != "staging" {
+		t.Errorf("unexpected stored token: %+v", stored)
+	}
+}
+
+func TestService_CreateAPIToken_InvalidScopes(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	viewer, _ := svc.CreateUser(ctx, "viewer@example.com", "Viewer", "TestPassword123", store.UserRoleViewer)
+
+	tests := []struct {
+		name   string
+		scopes []string
+		want   error
+	}{
+		{"no scopes", nil, ErrInvalidScope},
+		{"unknown scope", []string{"everything:*"}, ErrInvalidScope},
+		{"exceeds role", []string{ScopeConfigsWrite}, ErrScopeExceedsRole},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := svc.CreateAPIToken(ctx, CreateAPITokenRequest{Name: "t", Owner: viewer, Scopes: tt.scopes})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("CreateAPIToken error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestService_ValidateAPIToken(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	user, _ := svc.CreateUser(ctx, "ci@example.com", "CI", "TestPassword123", store.UserRoleOperator)
+	plaintext, token, _ := svc.CreateAPIToken(ctx, CreateAPITokenRequest{
+		Name:   "ci",
+		Owner:  user,
+		Scopes: []string{ScopeInstancesRead},
+	})
+
+	got, owner, err := svc.ValidateAPIToken(ctx, plaintext)
+	if err != nil {
+		t.Fatalf("ValidateAPIToken failed: %v", err)
+	}
+	if got.ID != token.ID || owner.ID != user.ID {
+		t.Error("ValidateAPIToken returned the wrong token or owner")
+	}
+
+	stored, _ := db.GetAPIToken(ctx, token.ID)
+	if stored.LastUsedAt == nil {
+		t.Error("last_used_at should be recorded")
+	}
+
+	if _, _, err := svc.ValidateAPIToken(ctx, APITokenPrefix+"unknown"); err != ErrInvalidToken {
+		t.Errorf("unknown token error = %v, want %v", err, ErrInvalidToken)
+	}
+
+	if err := svc.RevokeAPIToken(ctx, token.ID); err != nil {
+		t.Fatalf("RevokeAPIToken failed: %v", err)
+	}
+	if _, _, err := svc.ValidateAPIToken(ctx, plaintext); err != ErrAPITokenRevoked {
+		t.Errorf("revoked token error = %v, want %v", err, ErrAPITokenRevoked)
+	}
+	if err := svc.RevokeAPIToken(ctx, token.ID); err != ErrAPITokenNotFound {
+		t.Errorf("second revoke error = %v, want %v", err, ErrAPITokenNotFound)
+	}
+}
+
+func TestService_ValidateAPIToken_Expired(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	user, _ := svc.CreateUser(ctx, "ci@example.com", "CI", "TestPassword123", store.UserRoleOperator)
+	expired := time.Now().UTC().Add(-time.Minute)
+	plaintext, _, _ := svc.CreateAPIToken(ctx, CreateAPITokenRequest{
+		Name:      "old",
+		Owner:     user,
+		Scopes:    []string{ScopeInstancesRead},
+		ExpiresAt: &expired,
+	})
+
+	if _, _, err := svc.ValidateAPIToken(ctx, plaintext); err != ErrTokenExpired {
+		t.Errorf("ValidateAPIToken error = %v, want %v", err, ErrTokenExpired)
+	}
+}
+
+func TestService_CreateServiceAccount(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	sa, err := svc.CreateServiceAccount(ctx, "github-actions", "CI deploys", store.UserRoleOperator, "")
+	if err != nil {
+		t.Fatalf("CreateServiceAccount failed: %v", err)
+	}
+
+	user, _ := db.GetUser(ctx, sa.UserID)
+	if user == nil || user.PasswordHash != nil {
+		t.Fatalf("service account user should exist without a password: %+v", user)
+	}
+
+	// Service accounts cannot log in
+	if _, err := svc.Login(ctx, user.Email, "", "", ""); err != ErrInvalidCredentials {
+		t.Errorf("Login error = %v, want %v", err, ErrInvalidCredentials)
+	}
+
+	if _, err := svc.CreateServiceAccount(ctx, "github-actions", "", store.UserRoleViewer, ""); err == nil {
+		t.Error("duplicate service account name should fail")
+	}
+}
+
+func TestService_RequireAuth_APIToken(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	user, _ := svc.CreateUser(ctx, "ci@example.com", "CI", "TestPassword123", store.UserRoleOperator)
+	plaintext, _, _ := svc.CreateAPIToken(ctx, CreateAPITokenRequest{
+		Name:   "ci",
+		Owner:  user,
+		Scopes: []string{ScopeInstancesRead},
+	})
+
+	var gotUser *store.User
+	var gotToken *store.APIToken
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser = GetUserFromContext(r.Context())
+		gotToken = GetAPITokenFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name  string
+		scope string
+		want  int
+	}{
+		{"granted scope", ScopeInstancesRead, http.StatusOK},
+		{"missing scope", ScopeConfigsWrite, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := svc.RequireAuth()(svc.RequireScope(tt.scope)(ok))
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+plaintext)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("Status code = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+
+	if gotUser == nil || gotUser.ID != user.ID || gotToken == nil {
+		t.Error("user and token should be set in context")
+	}
+
+	// API tokens are rejected on interactive-only endpoints
+	handler := svc.RequireAuth()(svc.RequireUserSession()(ok))
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+plaintext)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Status code = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestRestrictLabelSelector(t *testing.T) {
+	token := &store.APIToken{Scopes: []string{ScopeInstancesRead}, Labels: map[string]string{"team": "payments"}}
+	ctx := context.WithValue(context.Background(), APITokenContextKey, token)
+
+	got, ok := RestrictLabelSelector(ctx, map[string]string{"env": "prod"})
+	if !ok || got["team"] != "payments" || got["env"] != "prod" {
+		t.Errorf("RestrictLabelSelector = %v, %v", got, ok)
+	}
+
+	if _, ok := RestrictLabelSelector(ctx, map[string]string{"team": "search"}); ok {
+		t.Error("conflicting selector should be rejected")
+	}
+
+	policy := NewPolicy(store.UserRoleOperator, nil)
+	policy.restrictToToken(token)
+	ctx = context.WithValue(ctx, PolicyContextKey, policy)
+
+	if !Authorize(ctx, ScopeInstancesRead, InstanceResource(map[string]string{"team": "payments", "env": "prod"})) {
+		t.Error("matching labels should be allowed")
+	}
+	if Authorize(ctx, ScopeInstancesRead, InstanceResource(map[string]string{"env": "prod"})) {
+		t.Error("non-matching labels should be denied")
+	}
+	if !Authorize(context.Background(), ScopeInstancesRead, InstanceResource(nil)) {
+		t.Error("contexts without a policy should not be restricted")
+	}
+}
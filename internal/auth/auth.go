@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/raskell-io/sentinel-hub/internal/audit"
+	"github.com/raskell-io/sentinel-hub/internal/notify"
 	"github.com/raskell-io/sentinel-hub/internal/store"
 	"github.com/rs/zerolog/log"
 )
@@ -27,29 +29,52 @@ var (
 
 // Config holds authentication configuration.
 type Config struct {
+	// JWTSecret signs tokens with HS256 when the service has no key ring
+	// (see SetKeyRing). With a key ring it is only used to accept HS256
+	// tokens issued before the switch.
 	JWTSecret          string
 	AccessTokenExpiry  time.Duration
 	RefreshTokenExpiry time.Duration
 	BcryptCost         int
+	OIDC               OIDCConfig
+	Lockout            LockoutConfig
+
+	// PasswordResetExpiry is how long a password reset token stays valid.
+	PasswordResetExpiry time.Duration
+	// PasswordResetURL is the web UI page that accepts a reset token in its
+	// "token" query parameter. Reset messages contain the bare token if unset.
+	PasswordResetURL string
+
+	// MFARequiredRoles must complete TOTP enrollment before they can log in.
+	MFARequiredRoles []store.UserRole
+	// MFAIssuer names the hub in authenticator apps.
+	MFAIssuer string
 }
 
 // DefaultConfig returns default auth configuration.
 func DefaultConfig() Config {
 	return Config{
-		AccessTokenExpiry:  15 * time.Minute,
-		RefreshTokenExpiry: 7 * 24 * time.Hour, // 7 days
-		BcryptCost:         DefaultBcryptCost,
+		AccessTokenExpiry:   15 * time.Minute,
+		RefreshTokenExpiry:  7 * 24 * time.Hour, // 7 days
+		BcryptCost:          DefaultBcryptCost,
+		Lockout:             DefaultLockoutConfig(),
+		PasswordResetExpiry: time.Hour,
+		MFAIssuer:           defaultMFAIssuer,
 	}
 }
 
 // Service provides authentication operations.
 type Service struct {
-	store  *store.Store
-	config Config
+	store    store.Store
+	config   Config
+	oidc     *OIDCProvider
+	notifier notify.Notifier
+	keys     *KeyRing
+	audit    *audit.Recorder
 }
 
 // NewService creates a new auth service.
-func NewService(s *store.Store, config Config) *Service {
+func NewService(s store.Store, config Config) *Service {
 	if config.AccessTokenExpiry == 0 {
 		config.AccessTokenExpiry = DefaultConfig().AccessTokenExpiry
 	}
@@ -59,14 +84,36 @@ func NewService(s *store.Store, config Config) *Service {
 	if config.BcryptCost == 0 {
 		config.BcryptCost = DefaultBcryptCost
 	}
+	config.Lockout = config.Lockout.withDefaults()
+	if config.PasswordResetExpiry == 0 {
+		config.PasswordResetExpiry = DefaultConfig().PasswordResetExpiry
+	}
+	if config.MFAIssuer == "" {
+		config.MFAIssuer = defaultMFAIssuer
+	}
 
-	return &Service{
+	svc := &Service{
 		store:  s,
 		config: config,
+		audit:  audit.NewRecorder(s, audit.RecorderConfig{}),
 	}
+	if config.OIDC.Enabled() {
+		svc.oidc = NewOIDCProvider(config.OIDC)
+	}
+
+	return svc
 }
 
-// Login authenticates a user and returns a token pair.
+// SetAuditRecorder sets the recorder audit logs are written through, so
+// that security events also reach the configured audit sinks.
+func (s *Service) SetAuditRecorder(r *audit.Recorder) {
+	s.audit = r
+}
+
+// Login authenticates a user and returns a token pair. Repeated failures
+// for an email or client IP lock further attempts with a *LockedError.
+// Users with MFA, or whose role requires it, get an *MFAChallenge instead
+// of a token pair and finish with VerifyMFALogin.
 func (s *Service) Login(ctx context.Context, email, password, ipAddress, userAgent string) (*TokenPair, error) {
 	// Get user by email
 	user, err := s.store.GetUserByEmail(ctx, email)
@@ -74,16 +121,50 @@ func (s *Service) Login(ctx context.Context, email, password, ipAddress, userAge
 		log.Error().Err(err).Str("email", email).Msg("Failed to get user by email")
 		return nil, ErrInvalidCredentials
 	}
+
+	// Reject locked accounts and addresses before checking the password
+	targets := s.loginThrottleTargets(email, ipAddress, user)
+	if err := s.checkLockout(ctx, targets); err != nil {
+		if errors.Is(err, ErrAccountLocked) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to check login lockout: %w", err)
+	}
+
 	if user == nil {
-		return nil, ErrInvalidCredentials
+		return nil, s.handleLoginFailure(ctx, targets, ipAddress)
 	}
 
 	// Verify password
 	if user.PasswordHash == nil {
-		return nil, ErrInvalidCredentials
+		return nil, s.handleLoginFailure(ctx, targets, ipAddress)
 	}
 	if err := VerifyPassword(*user.PasswordHash, password); err != nil {
-		return nil, ErrInvalidCredentials
+		return nil, s.handleLoginFailure(ctx, targets, ipAddress)
+	}
+
+	// Require a second factor if enrolled or enforced for the role
+	mfaEnabled, err := s.MFAEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mfa status: %w", err)
+	}
+	if mfaEnabled || s.mfaRequired(user) {
+		mfaToken, err := s.generateMFAToken(user)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &MFAChallenge{Token: mfaToken, EnrollmentRequired: !mfaEnabled}
+	}
+
+	return s.completeLogin(ctx, user, ipAddress, userAgent)
+}
+
+// completeLogin issues a token pair once all login factors are verified.
+func (s *Service) completeLogin(ctx context.Context, user *store.User, ipAddress, userAgent string) (*TokenPair, error) {
+	// A successful login resets the account counter; the IP counter is left
+	// to expire so one valid account cannot be used to reset it.
+	if err := s.store.DeleteLoginThrottle(ctx, accountThrottleKey(user.Email)); err != nil {
+		log.Warn().Err(err).Str("user_id", user.ID).Msg("Failed to clear login failures")
 	}
 
 	// Generate token pair
@@ -141,7 +222,10 @@ func (s *Service) Logout(ctx context.Context, refreshToken string) error {
 	return nil
 }
 
-// RefreshTokens exchanges a refresh token for a new token pair.
+// RefreshTokens exchanges a refresh token for a new token pair. The
+// session's refresh token is rotated, so each refresh token can be used
+// once; presenting a superseded token revokes the session and returns
+// ErrRefreshTokenReused.
 func (s *Service) RefreshTokens(ctx context.Context, refreshToken string) (*TokenPair, error) {
 	// Parse the refresh token
 	claims, err := s.parseToken(refreshToken)
@@ -160,7 +244,7 @@ func (s *Service) RefreshTokens(ctx context.Context, refreshToken string) (*Toke
 		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
 	if session == nil {
-		return nil, ErrInvalidToken
+		return nil, s.checkRefreshTokenReuse(ctx, tokenHash)
 	}
 
 	// Check if session is revoked
@@ -182,34 +266,48 @@ func (s *Service) RefreshTokens(ctx context.Context, refreshToken string) (*Toke
 		return nil, ErrUserNotFound
 	}
 
-	// Revoke old session
-	if err := s.store.RevokeUserSession(ctx, session.ID); err != nil {
-		log.Warn().Err(err).Str("session_id", session.ID).Msg("Failed to revoke old session")
+	accessToken, expiresAt, err := s.generateAccessToken(user, session.ID)
+	if err != nil {
+		return nil, err
 	}
-
-	// Create new token pair with a new session
-	tokenPair, err := s.createTokenPair(ctx, user, *session.IPAddress, *session.UserAgent)
+	newRefreshToken, refreshExpiresAt, err := s.generateRefreshToken(user, session.ID)
 	if err != nil {
 		return nil, err
 	}
 
+	// Rotate the session's refresh token
+	if err := s.store.RotateUserSessionToken(ctx, session.ID, tokenHash, HashToken(newRefreshToken), refreshExpiresAt); err != nil {
+		if err.Error() == "session not found" {
+			// A concurrent refresh already used this token
+			return nil, s.checkRefreshTokenReuse(ctx, tokenHash)
+		}
+		return nil, fmt.Errorf("failed to rotate session: %w", err)
+	}
+
 	log.Debug().
 		Str("user_id", user.ID).
+		Str("session_id", session.ID).
 		Msg("Tokens refreshed")
 
-	return tokenPair, nil
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
+		ExpiresAt:    expiresAt,
+		TokenType:    "Bearer",
+	}, nil
 }
 
-// createTokenPair generates both access and refresh tokens.
+// createTokenPair starts a new session and generates its access and refresh tokens.
 func (s *Service) createTokenPair(ctx context.Context, user *store.User, ipAddress, userAgent string) (*TokenPair, error) {
+	sessionID := uuid.New().String()
+
 	// Generate access token
-	accessToken, expiresAt, err := s.generateAccessToken(user)
+	accessToken, expiresAt, err := s.generateAccessToken(user, sessionID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Create session for refresh token
-	sessionID := uuid.New().String()
 	refreshToken, refreshExpiresAt, err := s.generateRefreshToken(user, sessionID)
 	if err != nil {
 		return nil, err
@@ -312,46 +410,31 @@ func (s *Service) UpdatePassword(ctx context.Context, userID, newPassword string
 	return nil
 }
 
-// GeneratePasswordResetToken creates a password reset token for admin use.
-func (s *Service) GeneratePasswordResetToken(ctx context.Context, userID string) (string, error) {
-	// Verify user exists
-	user, err := s.store.GetUser(ctx, userID)
-	if err != nil {
-		return "", fmt.Errorf("failed to get user: %w", err)
-	}
-	if user == nil {
-		return "", ErrUserNotFound
-	}
-
-	// Generate a random token
-	token, err := GenerateRandomToken(32)
-	if err != nil {
-		return "", fmt.Errorf("failed to generate token: %w", err)
-	}
-
-	log.Info().
-		Str("user_id", userID).
-		Msg("Password reset token generated")
-
-	// For now, we return the token directly (admin shares with user)
-	// In a full implementation, this would be stored with expiry
-	return token, nil
-}
-
 // GetUserFromToken validates a token and returns the associated user.
 func (s *Service) GetUserFromToken(ctx context.Context, tokenString string) (*store.User, error) {
+	user, _, err := s.authenticateAccessToken(ctx, tokenString)
+	return user, err
+}
+
+// authenticateAccessToken validates an access token, checks that its session
+// is still active and returns the user along with the token's claims.
+func (s *Service) authenticateAccessToken(ctx context.Context, tokenString string) (*store.User, *Claims, error) {
 	claims, err := s.ValidateAccessToken(tokenString)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	if err := s.checkSession(ctx, claims); err != nil {
+		return nil, nil, err
 	}
 
 	user, err := s.store.GetUser(ctx, claims.UserID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return nil, nil, fmt.Errorf("failed to get user: %w", err)
 	}
 	if user == nil {
-		return nil, ErrUserNotFound
+		return nil, nil, ErrUserNotFound
 	}
 
-	return user, nil
+	return user, claims, nil
 }
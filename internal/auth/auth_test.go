@@ -8,7 +8,7 @@ import (
 	"github.com/raskell-io/sentinel-hub/internal/store"
 )
 
-func setupTestService(t *testing.T) (*Service, *store.Store) {
+func setupTestService(t *testing.T) (*Service, store.Store) {
 	db, err := store.New("sqlite://:memory:")
 	if err != nil {
 		t.Fatalf("Failed to create store: %v", err)
@@ -59,6 +59,9 @@ func TestNewService_DefaultValues(t *testing.T) {
 	if svc.config.BcryptCost == 0 {
 		t.Error("BcryptCost should have default value")
 	}
+	if svc.config.Lockout != DefaultLockoutConfig() {
+		t.Errorf("Lockout = %+v, want defaults", svc.config.Lockout)
+	}
 }
 
 func TestService_CreateUser(t *testing.T) {
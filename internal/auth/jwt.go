@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/raskell-io/sentinel-hub/internal/store"
 )
 
@@ -32,6 +33,10 @@ const (
 
 	// TokenTypeRefresh is a refresh token (long-lived).
 	TokenTypeRefresh TokenType = "refresh"
+
+	// TokenTypeMFA is a short-lived token proving the password step of a
+	// login; it is exchanged for a token pair with a second factor.
+	TokenTypeMFA TokenType = "mfa"
 )
 
 // Claims represents the JWT claims.
@@ -41,7 +46,7 @@ type Claims struct {
 	Email     string    `json:"email"`
 	Role      string    `json:"role"`
 	TokenType TokenType `json:"type"`
-	SessionID string    `json:"sid,omitempty"` // Only for refresh tokens
+	SessionID string    `json:"sid,omitempty"` // Login session of access and refresh tokens
 }
 
 // TokenPair contains both access and refresh tokens.
@@ -52,8 +57,8 @@ type TokenPair struct {
 	TokenType    string    `json:"token_type"`
 }
 
-// generateAccessToken creates a new access token for a user.
-func (s *Service) generateAccessToken(user *store.User) (string, time.Time, error) {
+// generateAccessToken creates a new access token for a user's session.
+func (s *Service) generateAccessToken(user *store.User, sessionID string) (string, time.Time, error) {
 	expiresAt := time.Now().Add(s.config.AccessTokenExpiry)
 
 	claims := Claims{
@@ -67,10 +72,10 @@ func (s *Service) generateAccessToken(user *store.User) (string, time.Time, erro
 		Email:     user.Email,
 		Role:      string(user.Role),
 		TokenType: TokenTypeAccess,
+		SessionID: sessionID,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(s.config.JWTSecret))
+	signedToken, err := s.signToken(claims)
 	if err != nil {
 		return "", time.Time{}, fmt.Errorf("failed to sign access token: %w", err)
 	}
@@ -78,12 +83,14 @@ func (s *Service) generateAccessToken(user *store.User) (string, time.Time, erro
 	return signedToken, expiresAt, nil
 }
 
-// generateRefreshToken creates a new refresh token for a user.
+// generateRefreshToken creates a new refresh token for a user's session.
+// Each token gets a unique ID so that rotated tokens never repeat.
 func (s *Service) generateRefreshToken(user *store.User, sessionID string) (string, time.Time, error) {
 	expiresAt := time.Now().Add(s.config.RefreshTokenExpiry)
 
 	claims := Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
 			Subject:   user.ID,
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
@@ -96,8 +103,7 @@ func (s *Service) generateRefreshToken(user *store.User, sessionID string) (stri
 		SessionID: sessionID,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(s.config.JWTSecret))
+	signedToken, err := s.signToken(claims)
 	if err != nil {
 		return "", time.Time{}, fmt.Errorf("failed to sign refresh token: %w", err)
 	}
@@ -121,13 +127,7 @@ func (s *Service) ValidateAccessToken(tokenString string) (*Claims, error) {
 
 // parseToken parses and validates a JWT token.
 func (s *Service) parseToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// Validate the signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.config.JWTSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.verificationKey)
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -144,6 +144,31 @@ func (s *Service) parseToken(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
+// signToken signs claims with the key ring's active key, or with the
+// shared secret when the service has no key ring.
+func (s *Service) signToken(claims jwt.Claims) (string, error) {
+	if s.keys != nil {
+		return s.keys.sign(claims)
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.config.JWTSecret))
+}
+
+// verificationKey is the jwt.Keyfunc for hub-issued tokens. Tokens with a
+// kid header are checked against the key ring. HS256 tokens are accepted
+// while a shared secret is configured, so tokens issued before switching to
+// a key ring stay valid until they expire.
+func (s *Service) verificationKey(token *jwt.Token) (interface{}, error) {
+	if kid, ok := token.Header["kid"].(string); ok && s.keys != nil {
+		return s.keys.verificationKey(kid, token.Method.Alg())
+	}
+
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok && s.config.JWTSecret != "" {
+		return []byte(s.config.JWTSecret), nil
+	}
+
+	return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+}
+
 // HashToken creates a SHA256 hash of a token for storage.
 func HashToken(token string) string {
 	hash := sha256.Sum256([]byte(token))
@@ -21,7 +21,7 @@ func TestService_generateAccessToken(t *testing.T) {
 		Role:  store.UserRoleAdmin,
 	}
 
-	token, expiresAt, err := s.generateAccessToken(user)
+	token, expiresAt, err := s.generateAccessToken(user, "session-123")
 	if err != nil {
 		t.Fatalf("generateAccessToken failed: %v", err)
 	}
@@ -55,6 +55,10 @@ func TestService_generateAccessToken(t *testing.T) {
 	if claims.TokenType != TokenTypeAccess {
 		t.Errorf("claims.TokenType = %q, want %q", claims.TokenType, TokenTypeAccess)
 	}
+
+	if claims.SessionID != "session-123" {
+		t.Errorf("claims.SessionID = %q, want %q", claims.SessionID, "session-123")
+	}
 }
 
 func TestService_generateRefreshToken(t *testing.T) {
@@ -134,7 +138,7 @@ func TestService_ValidateAccessToken_WrongSecret(t *testing.T) {
 		Role:  store.UserRoleViewer,
 	}
 
-	token, _, err := s1.generateAccessToken(user)
+	token, _, err := s1.generateAccessToken(user, "session-123")
 	if err != nil {
 		t.Fatalf("generateAccessToken failed: %v", err)
 	}
@@ -160,7 +164,7 @@ func TestService_ValidateAccessToken_ExpiredToken(t *testing.T) {
 		Role:  store.UserRoleAdmin,
 	}
 
-	token, _, err := s.generateAccessToken(user)
+	token, _, err := s.generateAccessToken(user, "session-123")
 	if err != nil {
 		t.Fatalf("generateAccessToken failed: %v", err)
 	}
@@ -0,0 +1,439 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// Supported signing algorithms.
+const (
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmRS256 = "RS256"
+)
+
+// rsaKeyBits is the size of generated RSA keys.
+const rsaKeyBits = 2048
+
+// keyReloadInterval limits how often an unknown key ID triggers a reload,
+// so tokens with made-up key IDs cannot hammer the key store.
+const keyReloadInterval = 30 * time.Second
+
+var (
+	// ErrUnsupportedAlgorithm is returned for a signing algorithm other than
+	// EdDSA or RS256.
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+
+	// ErrKeyRingNotConfigured is returned when managing keys on a service
+	// that signs with a shared secret.
+	ErrKeyRingNotConfigured = errors.New("signing key ring not configured")
+)
+
+// KeySource persists signing keys. store.Store and FileKeySource
+// implement it.
+type KeySource interface {
+	ListSigningKeys(ctx context.Context) ([]store.SigningKey, error)
+	CreateSigningKey(ctx context.Context, key *store.SigningKey) error
+	RetireSigningKey(ctx context.Context, id string, expiresAt time.Time) error
+	DeleteSigningKey(ctx context.Context, id string) error
+}
+
+// KeyRingConfig configures signing key generation and rotation.
+type KeyRingConfig struct {
+	// Algorithm for new keys, AlgorithmEdDSA (default) or AlgorithmRS256.
+	Algorithm string
+	// Overlap is how long a retired key keeps verifying tokens. It should
+	// be at least the refresh token lifetime.
+	Overlap time.Duration
+	// RotationInterval rotates the signing key once it is this old.
+	// Zero leaves rotation to RotateSigningKey.
+	RotationInterval time.Duration
+}
+
+// withDefaults fills unset fields with their defaults.
+func (c KeyRingConfig) withDefaults() KeyRingConfig {
+	if c.Algorithm == "" {
+		c.Algorithm = AlgorithmEdDSA
+	}
+	if c.Overlap == 0 {
+		c.Overlap = DefaultConfig().RefreshTokenExpiry
+	}
+	return c
+}
+
+// signingKey is a parsed signing key.
+type signingKey struct {
+	store.SigningKey
+	signer crypto.Signer
+}
+
+// KeyRing holds the hub's token signing keys: the active key signs new
+// tokens, while retired keys verify tokens issued before a rotation until
+// their overlap period ends.
+type KeyRing struct {
+	source KeySource
+	config KeyRingConfig
+
+	rotateMu sync.Mutex
+
+	mu         sync.RWMutex
+	keys       []*signingKey // oldest first
+	active     *signingKey
+	lastReload time.Time
+}
+
+// NewKeyRing loads the keys from source, generating the first key if there
+// is no active one.
+func NewKeyRing(ctx context.Context, source KeySource, config KeyRingConfig) (*KeyRing, error) {
+	config = config.withDefaults()
+	if config.Algorithm != AlgorithmEdDSA && config.Algorithm != AlgorithmRS256 {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, config.Algorithm)
+	}
+
+	k := &KeyRing{source: source, config: config}
+	if err := k.Reload(ctx); err != nil {
+		return nil, err
+	}
+
+	if k.activeKey() == nil {
+		if _, err := k.Rotate(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return k, nil
+}
+
+// Reload reads the keys from the source, picking up rotations made by other
+// hub instances.
+func (k *KeyRing) Reload(ctx context.Context) error {
+	stored, err := k.source.ListSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var keys []*signingKey
+	var active *signingKey
+	for _, sk := range stored {
+		if sk.ExpiresAt != nil && now.After(*sk.ExpiresAt) {
+			continue
+		}
+
+		signer, err := parsePrivateKey(sk.Algorithm, sk.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("invalid signing key %s: %w", sk.ID, err)
+		}
+		key := &signingKey{SigningKey: sk, signer: signer}
+		keys = append(keys, key)
+
+		if sk.RetiredAt == nil && (active == nil || sk.CreatedAt.After(active.CreatedAt)) {
+			active = key
+		}
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.active = active
+	k.lastReload = now
+	k.mu.Unlock()
+
+	return nil
+}
+
+// Rotate generates a new signing key and retires the previous ones. Retired
+// keys verify tokens for the configured overlap; expired keys are deleted.
+func (k *KeyRing) Rotate(ctx context.Context) (*store.SigningKey, error) {
+	k.rotateMu.Lock()
+	defer k.rotateMu.Unlock()
+
+	key, err := generateSigningKey(k.config.Algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	if err := k.source.CreateSigningKey(ctx, key); err != nil {
+		return nil, err
+	}
+
+	stored, err := k.source.ListSigningKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, sk := range stored {
+		switch {
+		case sk.ID == key.ID:
+		case sk.ExpiresAt != nil && now.After(*sk.ExpiresAt):
+			if err := k.source.DeleteSigningKey(ctx, sk.ID); err != nil {
+				return nil, err
+			}
+		case sk.RetiredAt == nil:
+			err := k.source.RetireSigningKey(ctx, sk.ID, now.Add(k.config.Overlap))
+			if err != nil && err.Error() != "signing key not found" {
+				return nil, err
+			}
+		}
+	}
+
+	if err := k.Reload(ctx); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("kid", key.ID).
+		Str("algorithm", key.Algorithm).
+		Msg("Signing key rotated")
+
+	return key, nil
+}
+
+// RotateIfDue reloads the keys and rotates the active key once it is older
+// than the rotation interval. It reports whether a rotation happened.
+func (k *KeyRing) RotateIfDue(ctx context.Context) (bool, error) {
+	if err := k.Reload(ctx); err != nil {
+		return false, err
+	}
+
+	active := k.activeKey()
+	if active != nil && (k.config.RotationInterval <= 0 || time.Since(active.CreatedAt) < k.config.RotationInterval) {
+		return false, nil
+	}
+
+	if _, err := k.Rotate(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Keys returns the keys currently able to verify tokens, oldest first.
+func (k *KeyRing) Keys() []store.SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	keys := make([]store.SigningKey, 0, len(k.keys))
+	for _, key := range k.keys {
+		keys = append(keys, key.SigningKey)
+	}
+	return keys
+}
+
+// JWKS returns the public keys as a JSON Web Key Set (RFC 7517).
+func (k *KeyRing) JWKS() JWKSet {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range k.keys {
+		set.Keys = append(set.Keys, newJWK(key))
+	}
+	return set
+}
+
+// activeKey returns the key that signs new tokens.
+func (k *KeyRing) activeKey() *signingKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// sign signs claims with the active key and sets its ID as the kid header.
+func (k *KeyRing) sign(claims jwt.Claims) (string, error) {
+	key := k.activeKey()
+	if key == nil {
+		return "", errors.New("no active signing key")
+	}
+
+	token := jwt.NewWithClaims(signingMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signer)
+}
+
+// verificationKey returns the public key for a token's kid header. Unknown
+// key IDs trigger a reload, rate limited by keyReloadInterval.
+func (k *KeyRing) verificationKey(kid, alg string) (crypto.PublicKey, error) {
+	key := k.findKey(kid)
+
+	k.mu.RLock()
+	stale := time.Since(k.lastReload) > keyReloadInterval
+	k.mu.RUnlock()
+
+	if key == nil && stale {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := k.Reload(ctx); err != nil {
+			log.Warn().Err(err).Msg("Failed to reload signing keys")
+		}
+
+		key = k.findKey(kid)
+	}
+
+	if key == nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if key.Algorithm != alg {
+		return nil, fmt.Errorf("signing key %q does not use %s", kid, alg)
+	}
+	return key.signer.Public(), nil
+}
+
+// findKey returns the key with the given ID, or nil.
+func (k *KeyRing) findKey(kid string) *signingKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	for _, key := range k.keys {
+		if key.ID == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+// SetKeyRing makes the service sign tokens with the key ring's active key
+// instead of the shared secret.
+func (s *Service) SetKeyRing(keys *KeyRing) {
+	s.keys = keys
+}
+
+// JWKS returns the public keys that verify hub-issued tokens. It is empty
+// when tokens are signed with a shared secret.
+func (s *Service) JWKS() JWKSet {
+	if s.keys == nil {
+		return JWKSet{Keys: []JWK{}}
+	}
+	return s.keys.JWKS()
+}
+
+// SigningKeys lists the keys that currently verify tokens.
+func (s *Service) SigningKeys() ([]store.SigningKey, error) {
+	if s.keys == nil {
+		return nil, ErrKeyRingNotConfigured
+	}
+	return s.keys.Keys(), nil
+}
+
+// RotateSigningKey switches to a new signing key. Tokens signed with the
+// previous key remain valid for the key ring's overlap period.
+func (s *Service) RotateSigningKey(ctx context.Context) (*store.SigningKey, error) {
+	if s.keys == nil {
+		return nil, ErrKeyRingNotConfigured
+	}
+	return s.keys.Rotate(ctx)
+}
+
+// signingMethod returns the JWT signing method for an algorithm.
+func signingMethod(algorithm string) jwt.SigningMethod {
+	if algorithm == AlgorithmRS256 {
+		return jwt.SigningMethodRS256
+	}
+	return jwt.SigningMethodEdDSA
+}
+
+// generateSigningKey creates a new private key for the algorithm.
+func generateSigningKey(algorithm string) (*store.SigningKey, error) {
+	var private crypto.Signer
+	var err error
+	switch algorithm {
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	return &store.SigningKey{
+		ID:         uuid.New().String(),
+		Algorithm:  algorithm,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	}, nil
+}
+
+// parsePrivateKey decodes a PEM-encoded PKCS #8 key and checks that it
+// matches the algorithm.
+func parsePrivateKey(algorithm, data string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("expected a PEM-encoded PKCS #8 private key")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := parsed.(type) {
+	case ed25519.PrivateKey:
+		if algorithm == AlgorithmEdDSA {
+			return key, nil
+		}
+	case *rsa.PrivateKey:
+		if algorithm == AlgorithmRS256 {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %T key for %s", ErrUnsupportedAlgorithm, parsed, algorithm)
+}
+
+// ============================================
+// JSON Web Keys
+// ============================================
+
+// JWK is a public key in JSON Web Key format (RFC 7517, RFC 8037).
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// newJWK returns the public part of a signing key as a JWK.
+func newJWK(key *signingKey) JWK {
+	jwk := JWK{Use: "sig", Kid: key.ID, Alg: key.Algorithm}
+
+	switch public := key.signer.Public().(type) {
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	}
+
+	return jwk
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+// PEM headers holding signing key metadata in key files.
+const (
+	keyHeaderAlgorithm = "Algorithm"
+	keyHeaderCreated   = "Created"
+	keyHeaderRetired   = "Retired"
+	keyHeaderExpires   = "Expires"
+)
+
+// FileKeySource stores signing keys as PEM files in a directory, one
+// "<kid>.pem" file per key with its metadata in PEM headers. It suits
+// deployments that mount keys from a secret manager instead of keeping them
+// in the database.
+type FileKeySource struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileKeySource returns a key source for dir, creating it if needed.
+func NewFileKeySource(dir string) (*FileKeySource, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	return &FileKeySource{dir: dir}, nil
+}
+
+// ListSigningKeys reads all key files, oldest first.
+func (f *FileKeySource) ListSigningKeys(ctx context.Context) ([]store.SigningKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(f.dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+
+	keys := make([]store.SigningKey, 0, len(paths))
+	for _, path := range paths {
+		key, err := f.read(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// CreateSigningKey writes a new key file.
+func (f *FileKeySource) CreateSigningKey(ctx context.Context, key *store.SigningKey) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if key.ID == "" {
+		key.ID = uuid.New().String()
+	}
+	key.CreatedAt = time.Now().UTC()
+
+	return f.write(key)
+}
+
+// RetireSigningKey marks a key file as retired.
+func (f *FileKeySource) RetireSigningKey(ctx context.Context, id string, expiresAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key, err := f.read(f.path(id))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("signing key not found")
+	}
+	if err != nil {
+		return err
+	}
+	if key.RetiredAt != nil {
+		return fmt.Errorf("signing key not found")
+	}
+
+	now := time.Now().UTC()
+	expiresAt = expiresAt.UTC()
+	key.RetiredAt = &now
+	key.ExpiresAt = &expiresAt
+
+	return f.write(key)
+}
+
+// DeleteSigningKey removes a key file.
+func (f *FileKeySource) DeleteSigningKey(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := os.Remove(f.path(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete signing key: %w", err)
+	}
+	return nil
+}
+
+// path returns the file name for a key ID.
+func (f *FileKeySource) path(id string) string {
+	return filepath.Join(f.dir, filepath.Base(id)+".pem")
+}
+
+// read parses a key file.
+func (f *FileKeySource) read(path string) (*store.SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid signing key file %s", path)
+	}
+
+	key := &store.SigningKey{
+		ID:        strings.TrimSuffix(filepath.Base(path), ".pem"),
+		Algorithm: block.Headers[keyHeaderAlgorithm],
+	}
+	if key.CreatedAt, err = time.Parse(time.RFC3339Nano, block.Headers[keyHeaderCreated]); err != nil {
+		return nil, fmt.Errorf("invalid %s header in %s: %w", keyHeaderCreated, path, err)
+	}
+	if key.RetiredAt, err = parseKeyTimeHeader(block.Headers[keyHeaderRetired]); err != nil {
+		return nil, fmt.Errorf("invalid %s header in %s: %w", keyHeaderRetired, path, err)
+	}
+	if key.ExpiresAt, err = parseKeyTimeHeader(block.Headers[keyHeaderExpires]); err != nil {
+		return nil, fmt.Errorf("invalid %s header in %s: %w", keyHeaderExpires, path, err)
+	}
+
+	// The key itself is stored without the metadata headers
+	block.Headers = nil
+	key.PrivateKey = string(pem.EncodeToMemory(block))
+
+	return key, nil
+}
+
+// write stores a key file atomically, readable only by the hub.
+func (f *FileKeySource) write(key *store.SigningKey) error {
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return fmt.Errorf("invalid private key for signing key %s", key.ID)
+	}
+
+	block.Headers = map[string]string{
+		keyHeaderAlgorithm: key.Algorithm,
+		keyHeaderCreated:   key.CreatedAt.Format(time.RFC3339Nano),
+	}
+	if key.RetiredAt != nil {
+		block.Headers[keyHeaderRetired] = key.RetiredAt.Format(time.RFC3339Nano)
+	}
+	if key.ExpiresAt != nil {
+		block.Headers[keyHeaderExpires] = key.ExpiresAt.Format(time.RFC3339Nano)
+	}
+
+	tmp, err := os.CreateTemp(f.dir, ".key-*")
+	if err != nil {
+		return fmt.Errorf("failed to write signing key: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := pem.Encode(tmp, block); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write signing key: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write signing key: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path(key.ID)); err != nil {
+		return fmt.Errorf("failed to write signing key: %w", err)
+	}
+
+	return nil
+}
+
+// parseKeyTimeHeader parses an optional timestamp header.
+func parseKeyTimeHeader(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+func TestKeyRing_SignAndRotate(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	keys, err := NewKeyRing(ctx, db, KeyRingConfig{Overlap: time.Hour})
+	if err != nil {
+		t.Fatalf("NewKeyRing failed: %v", err)
+	}
+	svc.SetKeyRing(keys)
+
+	user := &store.User{ID: "user-123", Email: "test@example.com", Role: store.UserRoleAdmin}
+	before, _, err := svc.generateAccessToken(user, "session-1")
+	if err != nil {
+		t.Fatalf("generateAccessToken failed: %v", err)
+	}
+
+	// Other services verify tokens with the published key
+	jwks := svc.JWKS()
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kty != "OKP" || jwks.Keys[0].Alg != AlgorithmEdDSA {
+		t.Fatalf("unexpected JWKS: %+v", jwks)
+	}
+	public, _ := base64.RawURLEncoding.DecodeString(jwks.Keys[0].X)
+	token, err := jwt.ParseWithClaims(before, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		if token.Header["kid"] != jwks.Keys[0].Kid {
+			t.Errorf("kid = %v, want %s", token.Header["kid"], jwks.Keys[0].Kid)
+		}
+		return ed25519.PublicKey(public), nil
+	}, jwt.WithValidMethods([]string{AlgorithmEdDSA}))
+	if err != nil || !token.Valid {
+		t.Fatalf("verifying with JWKS failed: %v", err)
+	}
+
+	rotated, err := svc.RotateSigningKey(ctx)
+	if err != nil {
+		t.Fatalf("RotateSigningKey failed: %v", err)
+	}
+	after, _, _ := svc.generateAccessToken(user, "session-1")
+	parsed, _, _ := jwt.NewParser().ParseUnverified(after, &Claims{})
+	if parsed.Header["kid"] != rotated.ID {
+		t.Errorf("new tokens signed with %v, want %s", parsed.Header["kid"], rotated.ID)
+	}
+
+	// Tokens signed before the rotation stay valid during the overlap
+	if _, err := svc.ValidateAccessToken(before); err != nil {
+		t.Errorf("token from retired key rejected: %v", err)
+	}
+	if n := len(svc.JWKS().Keys); n != 2 {
+		t.Errorf("JWKS has %d keys during overlap, want 2", n)
+	}
+
+	// A second ring on the same store agrees on the active key
+	other, err := NewKeyRing(ctx, db, KeyRingConfig{})
+	if err != nil {
+		t.Fatalf("NewKeyRing failed: %v", err)
+	}
+	if active := other.activeKey(); active == nil || active.ID != rotated.ID {
+		t.Errorf("second ring active key = %v, want %s", active, rotated.ID)
+	}
+}
+
+func TestKeyRing_OverlapExpiry(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	keys, err := NewKeyRing(ctx, db, KeyRingConfig{Overlap: time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewKeyRing failed: %v", err)
+	}
+	svc.SetKeyRing(keys)
+
+	user := &store.User{ID: "user-123", Email: "test@example.com", Role: store.UserRoleAdmin}
+	token, _, _ := svc.generateAccessToken(user, "")
+
+	if _, err := keys.Rotate(ctx); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// Rotating again prunes the expired key
+	if _, err := keys.Rotate(ctx); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if _, err := svc.ValidateAccessToken(token); err != ErrInvalidToken {
+		t.Errorf("token from expired key = %v, want %v", err, ErrInvalidToken)
+	}
+	stored, _ := db.ListSigningKeys(ctx)
+	if len(stored) != 2 {
+		t.Errorf("got %d stored keys, want 2", len(stored))
+	}
+}
+
+func TestKeyRing_RotateIfDue(t *testing.T) {
+	_, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	manual, _ := NewKeyRing(ctx, db, KeyRingConfig{})
+	if rotated, err := manual.RotateIfDue(ctx); err != nil || rotated {
+		t.Errorf("RotateIfDue without interval = %v, %v", rotated, err)
+	}
+
+	scheduled, _ := NewKeyRing(ctx, db, KeyRingConfig{RotationInterval: time.Nanosecond})
+	if rotated, err := scheduled.RotateIfDue(ctx); err != nil || !rotated {
+		t.Errorf("RotateIfDue with elapsed interval = %v, %v", rotated, err)
+	}
+}
+
+func TestService_LegacySecretTokens(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	user := &store.User{ID: "user-123", Email: "test@example.com", Role: store.UserRoleAdmin}
+	legacy, _, err := svc.generateAccessToken(user, "")
+	if err != nil {
+		t.Fatalf("generateAccessToken failed: %v", err)
+	}
+
+	keys, _ := NewKeyRing(ctx, db, KeyRingConfig{})
+	svc.SetKeyRing(keys)
+
+	if _, err := svc.ValidateAccessToken(legacy); err != nil {
+		t.Errorf("HS256 token rejected while the secret is configured: %v", err)
+	}
+
+	svc.config.JWTSecret = ""
+	if _, err := svc.ValidateAccessToken(legacy); err != ErrInvalidToken {
+		t.Errorf("HS256 token without secret = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestFileKeySource(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	source, err := NewFileKeySource(dir)
+	if err != nil {
+		t.Fatalf("NewFileKeySource failed: %v", err)
+	}
+
+	keys, err := NewKeyRing(ctx, source, KeyRingConfig{Algorithm: AlgorithmRS256})
+	if err != nil {
+		t.Fatalf("NewKeyRing failed: %v", err)
+	}
+	first := keys.activeKey().ID
+
+	if _, err := keys.Rotate(ctx); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+
+	// Keys and their metadata survive a restart
+	reopened, _ := NewFileKeySource(dir)
+	stored, err := reopened.ListSigningKeys(ctx)
+	if err != nil || len(stored) != 2 {
+		t.Fatalf("ListSigningKeys = %v, %v", stored, err)
+	}
+	if stored[0].ID != first || stored[0].RetiredAt == nil || stored[0].ExpiresAt == nil {
+		t.Errorf("first key not retired: %+v", stored[0])
+	}
+	if stored[1].RetiredAt != nil || stored[1].Algorithm != AlgorithmRS256 {
+		t.Errorf("unexpected active key: %+v", stored[1])
+	}
+
+	jwks := keys.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[1].Kty != "RSA" || jwks.Keys[1].E != "AQAB" {
+		t.Errorf("unexpected JWKS: %+v", jwks)
+	}
+
+	if err := reopened.RetireSigningKey(ctx, first, time.Now()); err == nil {
+		t.Error("retiring a retired key should fail")
+	}
+}
+
+func TestNewKeyRing_UnsupportedAlgorithm(t *testing.T) {
+	_, db := setupTestService(t)
+	defer db.Close()
+
+	if _, err := NewKeyRing(context.Background(), db, KeyRingConfig{Algorithm: "HS256"}); err == nil {
+		t.Error("NewKeyRing should reject HS256")
+	}
+}
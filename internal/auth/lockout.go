@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrAccountLocked is returned when a login is rejected because the account
+// or client IP has too many recent failed attempts.
+var ErrAccountLocked = errors.New("too many failed login attempts")
+
+// LockedError reports how long a locked-out login must wait.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrAccountLocked, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockedError) Unwrap() error {
+	return ErrAccountLocked
+}
+
+// LockoutConfig controls failed login throttling. A negative maximum
+// disables the corresponding counter.
+type LockoutConfig struct {
+	// MaxAccountFailures is the number of failures for one email before it is locked.
+	MaxAccountFailures int
+	// MaxIPFailures is the number of failures from one client IP before it is locked.
+	MaxIPFailures int
+	// BaseLockout is the first lockout duration; each further failure doubles it.
+	BaseLockout time.Duration
+	// MaxLockout caps the lockout duration.
+	MaxLockout time.Duration
+	// FailureWindow resets a counter when no failure was seen for this long.
+	FailureWindow time.Duration
+}
+
+// DefaultLockoutConfig returns the default lockout configuration.
+func DefaultLockoutConfig() LockoutConfig {
+	return LockoutConfig{
+		MaxAccountFailures: 5,
+		MaxIPFailures:      20,
+		BaseLockout:        time.Minute,
+		MaxLockout:         time.Hour,
+		FailureWindow:      15 * time.Minute,
+	}
+}
+
+// withDefaults fills unset fields from DefaultLockoutConfig.
+func (c LockoutConfig) withDefaults() LockoutConfig {
+	d := DefaultLockoutConfig()
+	if c.MaxAccountFailures == 0 {
+		c.MaxAccountFailures = d.MaxAccountFailures
+	}
+	if c.MaxIPFailures == 0 {
+		c.MaxIPFailures = d.MaxIPFailures
+	}
+	if c.BaseLockout == 0 {
+		c.BaseLockout = d.BaseLockout
+	}
+	if c.MaxLockout == 0 {
+		c.MaxLockout = d.MaxLockout
+	}
+	if c.FailureWindow == 0 {
+		c.FailureWindow = d.FailureWindow
+	}
+	return c
+}
+
+// lockoutDuration returns how long to lock a key after its failures reach
+// max, doubling for every failure past the threshold.
+func (c LockoutConfig) lockoutDuration(failures, max int) time.Duration {
+	d := c.BaseLockout
+	for i := max; i < failures && d < c.MaxLockout; i++ {
+		d *= 2
+	}
+	if d > c.MaxLockout {
+		d = c.MaxLockout
+	}
+	return d
+}
+
+// accountThrottleKey returns the throttle key for a login email.
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// ipThrottleKey returns the throttle key for a client address, without port.
+func ipThrottleKey(ipAddress string) string {
+	ip := strings.TrimSpace(ipAddress)
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return "ip:" + ip
+}
+
+// throttleTarget is a counter checked and updated on every login attempt.
+type throttleTarget struct {
+	key          string
+	max          int
+	resourceType string
+	resourceID   string
+	userID       *string
+}
+
+// loginThrottleTargets returns the counters that apply to a login attempt.
+func (s *Service) loginThrottleTargets(email, ipAddress string, user *store.User) []throttleTarget {
+	cfg := s.config.Lockout
+	var targets []throttleTarget
+
+	if cfg.MaxAccountFailures > 0 {
+		t := throttleTarget{
+			key:          accountThrottleKey(email),
+			max:          cfg.MaxAccountFailures,
+			resourceType: "user",
+			resourceID:   strings.ToLower(strings.TrimSpace(email)),
+		}
+		if user != nil {
+			t.resourceID = user.ID
+			t.userID = &user.ID
+		}
+		targets = append(targets, t)
+	}
+	if cfg.MaxIPFailures > 0 && ipAddress != "" {
+		key := ipThrottleKey(ipAddress)
+		targets = append(targets, throttleTarget{
+			key:          key,
+			max:          cfg.MaxIPFailures,
+			resourceType: "ip",
+			resourceID:   strings.TrimPrefix(key, "ip:"),
+		})
+	}
+	return targets
+}
+
+// checkLockout returns a LockedError if any counter is currently locked.
+func (s *Service) checkLockout(ctx context.Context, targets []throttleTarget) error {
+	now := time.Now().UTC()
+	var retryAfter time.Duration
+
+	for _, t := range targets {
+		throttle, err := s.store.GetLoginThrottle(ctx, t.key)
+		if err != nil {
+			return err
+		}
+		if throttle == nil || throttle.LockedUntil == nil {
+			continue
+		}
+		if wait := throttle.LockedUntil.Sub(now); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	if retryAfter > 0 {
+		return &LockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// recordLoginFailure increments the counters for a failed login and locks
+// any that reach their threshold. It returns a LockedError if the failure
+// caused a lockout.
+func (s *Service) recordLoginFailure(ctx context.Context, targets []throttleTarget, ipAddress string) error {
+	cfg := s.config.Lockout
+	now := time.Now().UTC()
+	var retryAfter time.Duration
+
+	for _, t := range targets {
+		throttle, err := s.store.GetLoginThrottle(ctx, t.key)
+		if err != nil {
+			return err
+		}
+		if throttle == nil || now.Sub(throttle.LastFailureAt) > cfg.FailureWindow {
+			throttle = &store.LoginThrottle{Key: t.key}
+		}
+
+		throttle.Failures++
+		throttle.LastFailureAt = now
+		throttle.LockedUntil = nil
+
+		if throttle.Failures >= t.max {
+			d := cfg.lockoutDuration(throttle.Failures, t.max)
+			lockedUntil := now.Add(d)
+			throttle.LockedUntil = &lockedUntil
+			if d > retryAfter {
+				retryAfter = d
+			}
+			s.auditLockout(ctx, t, throttle, ipAddress)
+		}
+
+		if err := s.store.UpsertLoginThrottle(ctx, throttle); err != nil {
+			return err
+		}
+	}
+
+	if retryAfter > 0 {
+		return &LockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// auditLockout records a lockout in the audit log.
+func (s *Service) auditLockout(ctx context.Context, t throttleTarget, throttle *store.LoginThrottle, ipAddress string) {
+	details, _ := json.Marshal(map[string]interface{}{
+		"failures":     throttle.Failures,
+		"locked_until": throttle.LockedUntil,
+	})
+
+	entry := &store.AuditLog{
+		UserID:       t.userID,
+		Action:       "lockout",
+		ResourceType: t.resourceType,
+		ResourceID:   &t.resourceID,
+		Details:      details,
+	}
+	if ipAddress != "" {
+		entry.IPAddress = &ipAddress
+	}
+
+	if err := s.audit.Record(ctx, entry); err != nil {
+		log.Warn().Err(err).Str("key", t.key).Msg("Failed to create lockout audit log")
+	}
+
+	log.Warn().
+		Str("key", t.key).
+		Int("failures", throttle.Failures).
+		Time("locked_until", *throttle.LockedUntil).
+		Msg("Login locked out after repeated failures")
+}
+
+// handleLoginFailure records a failed login and returns the error to report:
+// a LockedError if the failure caused a lockout, otherwise ErrInvalidCredentials.
+func (s *Service) handleLoginFailure(ctx context.Context, targets []throttleTarget, ipAddress string) error {
+	err := s.recordLoginFailure(ctx, targets, ipAddress)
+	if errors.Is(err, ErrAccountLocked) {
+		return err
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to record login failure")
+	}
+	return ErrInvalidCredentials
+}
+
+// UnlockUser clears the failed login counter for a user's account.
+func (s *Service) UnlockUser(ctx context.Context, userID string) error {
+	user, err := s.store.GetUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	if err := s.store.DeleteLoginThrottle(ctx, accountThrottleKey(user.Email)); err != nil {
+		return err
+	}
+
+	log.Info().Str("user_id", user.ID).Msg("User unlocked")
+	return nil
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+func TestLockoutConfig_Duration(t *testing.T) {
+	cfg := LockoutConfig{BaseLockout: time.Minute, MaxLockout: 10 * time.Minute}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{5, time.Minute},
+		{6, 2 * time.Minute},
+		{7, 4 * time.Minute},
+		{8, 8 * time.Minute},
+		{9, 10 * time.Minute},
+		{50, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := cfg.lockoutDuration(tt.failures, 5); got != tt.want {
+			t.Errorf("lockoutDuration(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestService_Login_AccountLockout(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	user, _ := svc.CreateUser(ctx, "locked@example.com", "Locked", "TestPassword123", store.UserRoleViewer)
+
+	max := svc.config.Lockout.MaxAccountFailures
+	for i := 1; i < max; i++ {
+		if _, err := svc.Login(ctx, "locked@example.com", "WrongPassword1", "", ""); err != ErrInvalidCredentials {
+			t.Fatalf("attempt %d error = %v, want %v", i, err, ErrInvalidCredentials)
+		}
+	}
+
+	// The attempt that reaches the threshold locks the account
+	_, err := svc.Login(ctx, "locked@example.com", "WrongPassword1", "", "")
+	var locked *LockedError
+	if !errors.As(err, &locked) || locked.RetryAfter != svc.config.Lockout.BaseLockout {
+		t.Fatalf("Login error = %v, want lockout of %v", err, svc.config.Lockout.BaseLockout)
+	}
+
+	// Even the correct password is rejected while locked
+	if _, err := svc.Login(ctx, "LOCKED@example.com", "TestPassword123", "", ""); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("Login while locked error = %v, want %v", err, ErrAccountLocked)
+	}
+
+	logs, _ := db.ListAuditLogs(ctx, store.ListAuditLogsOptions{})
+	if len(logs) != 1 || logs[0].Action != "lockout" || logs[0].UserID == nil || *logs[0].UserID != user.ID {
+		t.Errorf("expected one lockout audit log for the user, got %+v", logs)
+	}
+
+	if err := svc.UnlockUser(ctx, user.ID); err != nil {
+		t.Fatalf("UnlockUser failed: %v", err)
+	}
+	if _, err := svc.Login(ctx, "locked@example.com", "TestPassword123", "", ""); err != nil {
+		t.Errorf("Login after unlock failed: %v", err)
+	}
+
+	if err := svc.UnlockUser(ctx, "nonexistent"); err != ErrUserNotFound {
+		t.Errorf("UnlockUser error = %v, want %v", err, ErrUserNotFound)
+	}
+}
+
+func TestService_Login_ExponentialLockout(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	svc.CreateUser(ctx, "slow@example.com", "Slow", "TestPassword123", store.UserRoleViewer)
+
+	key := accountThrottleKey("slow@example.com")
+	expired := time.Now().UTC().Add(-time.Second)
+	if err := db.UpsertLoginThrottle(ctx, &store.LoginThrottle{
+		Key:           key,
+		Failures:      svc.config.Lockout.MaxAccountFailures,
+		LastFailureAt: time.Now().UTC(),
+		LockedUntil:   &expired,
+	}); err != nil {
+		t.Fatalf("UpsertLoginThrottle failed: %v", err)
+	}
+
+	// A failure after the lock expires doubles the lockout
+	_, err := svc.Login(ctx, "slow@example.com", "WrongPassword1", "", "")
+	var locked *LockedError
+	if !errors.As(err, &locked) || locked.RetryAfter != 2*svc.config.Lockout.BaseLockout {
+		t.Fatalf("Login error = %v, want lockout of %v", err, 2*svc.config.Lockout.BaseLockout)
+	}
+}
+
+func TestService_Login_IPLockout(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	svc.config.Lockout.MaxIPFailures = 3
+	svc.CreateUser(ctx, "victim@example.com", "Victim", "TestPassword123", store.UserRoleViewer)
+
+	// Spraying different accounts from one address trips the IP counter
+	for i, email := range []string{"a@example.com", "b@example.com"} {
+		if _, err := svc.Login(ctx, email, "WrongPassword1", "203.0.113.7:5000", ""); err != ErrInvalidCredentials {
+			t.Fatalf("attempt %d error = %v, want %v", i, err, ErrInvalidCredentials)
+		}
+	}
+	if _, err := svc.Login(ctx, "c@example.com", "WrongPassword1", "203.0.113.7:5001", ""); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("Login error = %v, want %v", err, ErrAccountLocked)
+	}
+
+	if _, err := svc.Login(ctx, "victim@example.com", "TestPassword123", "203.0.113.7:5002", ""); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("Login from locked IP error = %v, want %v", err, ErrAccountLocked)
+	}
+	if _, err := svc.Login(ctx, "victim@example.com", "TestPassword123", "198.51.100.1", ""); err != nil {
+		t.Errorf("Login from another IP failed: %v", err)
+	}
+}
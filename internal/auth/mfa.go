@@ -0,0 +1,328 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// mfaTokenExpiry is how long a user has to present a second factor after
+// entering their password.
+const mfaTokenExpiry = 5 * time.Minute
+
+// recoveryCodeCount is the number of recovery codes issued on enrollment.
+const recoveryCodeCount = 10
+
+// defaultMFAIssuer is shown as the account issuer in authenticator apps.
+const defaultMFAIssuer = "Sentinel Hub"
+
+var (
+	// ErrMFARequired is returned by Login when a second factor is needed.
+	// The error is an *MFAChallenge carrying the token for the second step.
+	ErrMFARequired = errors.New("multi-factor authentication required")
+
+	// ErrInvalidMFACode is returned when a TOTP or recovery code is wrong or reused.
+	ErrInvalidMFACode = errors.New("invalid mfa code")
+
+	// ErrMFANotEnrolled is returned when a user has no (pending) MFA enrollment.
+	ErrMFANotEnrolled = errors.New("mfa not enrolled")
+
+	// ErrMFAAlreadyEnabled is returned when enrolling a user who already has MFA.
+	ErrMFAAlreadyEnabled = errors.New("mfa already enabled")
+)
+
+// MFAChallenge is returned by Login after a correct password when the user
+// must complete a second factor.
+type MFAChallenge struct {
+	// Token identifies the login for VerifyMFALogin and BeginMFAEnrollment.
+	Token string
+	// EnrollmentRequired is set when the user's role requires MFA but the
+	// user has not enrolled yet; they must enroll before logging in.
+	EnrollmentRequired bool
+}
+
+func (c *MFAChallenge) Error() string {
+	return ErrMFARequired.Error()
+}
+
+func (c *MFAChallenge) Unwrap() error {
+	return ErrMFARequired
+}
+
+// MFAEnrollment is a pending TOTP enrollment for the user to add to an
+// authenticator app.
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URL    string `json:"otpauth_url"`
+}
+
+// mfaRequired reports whether the user's role must use MFA.
+func (s *Service) mfaRequired(user *store.User) bool {
+	for _, role := range s.config.MFARequiredRoles {
+		if role == user.Role {
+			return true
+		}
+	}
+	return false
+}
+
+// MFAEnabled reports whether a user has completed MFA enrollment.
+func (s *Service) MFAEnabled(ctx context.Context, userID string) (bool, error) {
+	mfa, err := s.store.GetUserMFA(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return mfa != nil && mfa.EnabledAt != nil, nil
+}
+
+// generateMFAToken creates the short-lived token for the second login step.
+func (s *Service) generateMFAToken(user *store.User) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaTokenExpiry)),
+			Issuer:    "sentinel-hub",
+		},
+		UserID:    user.ID,
+		Email:     user.Email,
+		Role:      string(user.Role),
+		TokenType: TokenTypeMFA,
+	}
+
+	signedToken, err := s.signToken(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign mfa token: %w", err)
+	}
+	return signedToken, nil
+}
+
+// GetUserFromMFAToken validates an MFA token and returns its user.
+func (s *Service) GetUserFromMFAToken(ctx context.Context, tokenString string) (*store.User, error) {
+	claims, err := s.parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != TokenTypeMFA {
+		return nil, ErrInvalidTokenType
+	}
+
+	user, err := s.store.GetUser(ctx, claims.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// BeginMFAEnrollment creates a new TOTP secret for the user. MFA is not
+// enforced until the enrollment is confirmed with a valid code.
+func (s *Service) BeginMFAEnrollment(ctx context.Context, user *store.User) (*MFAEnrollment, error) {
+	existing, err := s.store.GetUserMFA(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.EnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate totp secret: %w", err)
+	}
+
+	if err := s.store.UpsertUserMFA(ctx, &store.UserMFA{UserID: user.ID, Secret: secret}); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("user_id", user.ID).Msg("MFA enrollment started")
+
+	return &MFAEnrollment{
+		Secret: secret,
+		URL:    totpURL(s.config.MFAIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmMFAEnrollment activates a pending enrollment after checking a code
+// from the user's authenticator. It returns the user's recovery codes, which
+// are only available at this point.
+func (s *Service) ConfirmMFAEnrollment(ctx context.Context, user *store.User, code string) ([]string, error) {
+	mfa, err := s.store.GetUserMFA(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	if mfa.EnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	ok, err := s.verifyTOTP(ctx, mfa, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	return s.enableMFA(ctx, user)
+}
+
+// enableMFA activates the user's enrollment with a fresh set of recovery codes.
+func (s *Service) enableMFA(ctx context.Context, user *store.User) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		codes[i] = code
+		hashes[i] = HashToken(normalizeRecoveryCode(code))
+	}
+
+	if err := s.store.EnableUserMFA(ctx, user.ID, hashes); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("user_id", user.ID).Msg("MFA enabled")
+	return codes, nil
+}
+
+// VerifyMFALogin completes a login started by Login, using a TOTP code or a
+// recovery code. If the user's role requires MFA and they enrolled during
+// this login, the enrollment is activated and its recovery codes returned.
+// Failed codes count towards the account lockout.
+func (s *Service) VerifyMFALogin(ctx context.Context, mfaToken, code, ipAddress, userAgent string) (*TokenPair, []string, error) {
+	user, err := s.GetUserFromMFAToken(ctx, mfaToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	targets := s.loginThrottleTargets(user.Email, ipAddress, user)
+	if err := s.checkLockout(ctx, targets); err != nil {
+		if errors.Is(err, ErrAccountLocked) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("failed to check login lockout: %w", err)
+	}
+
+	mfa, err := s.store.GetUserMFA(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if mfa == nil {
+		return nil, nil, ErrMFANotEnrolled
+	}
+
+	var recoveryCodes []string
+	if mfa.EnabledAt == nil {
+		// Only users who are required to use MFA enroll as part of a login
+		if !s.mfaRequired(user) {
+			return nil, nil, ErrMFANotEnrolled
+		}
+		ok, err := s.verifyTOTP(ctx, mfa, code)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			return nil, nil, s.handleMFAFailure(ctx, targets, ipAddress)
+		}
+		if recoveryCodes, err = s.enableMFA(ctx, user); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		ok, err := s.verifyMFACode(ctx, mfa, code)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			return nil, nil, s.handleMFAFailure(ctx, targets, ipAddress)
+		}
+	}
+
+	tokenPair, err := s.completeLogin(ctx, user, ipAddress, userAgent)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tokenPair, recoveryCodes, nil
+}
+
+// handleMFAFailure records a failed second factor like a failed password.
+func (s *Service) handleMFAFailure(ctx context.Context, targets []throttleTarget, ipAddress string) error {
+	if err := s.handleLoginFailure(ctx, targets, ipAddress); err != ErrInvalidCredentials {
+		return err
+	}
+	return ErrInvalidMFACode
+}
+
+// verifyMFACode checks a TOTP code, or else a recovery code, for an enabled enrollment.
+func (s *Service) verifyMFACode(ctx context.Context, mfa *store.UserMFA, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if len(code) == totpDigits {
+		return s.verifyTOTP(ctx, mfa, code)
+	}
+
+	if err := s.store.UseRecoveryCode(ctx, mfa.UserID, HashToken(normalizeRecoveryCode(code))); err != nil {
+		if err.Error() == "recovery code not found" {
+			return false, nil
+		}
+		return false, err
+	}
+
+	log.Info().Str("user_id", mfa.UserID).Msg("MFA recovery code used")
+	return true, nil
+}
+
+// verifyTOTP checks a TOTP code and records its time step so the same code
+// cannot be replayed.
+func (s *Service) verifyTOTP(ctx context.Context, mfa *store.UserMFA, code string) (bool, error) {
+	step, ok := validateTOTP(mfa.Secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return false, nil
+	}
+	return s.store.UseUserMFAStep(ctx, mfa.UserID, step)
+}
+
+// ResetMFA removes a user's MFA enrollment and recovery codes, for example
+// after they lose their device. Users whose role requires MFA enroll again
+// at their next login.
+func (s *Service) ResetMFA(ctx context.Context, userID string) error {
+	if err := s.store.DeleteUserMFA(ctx, userID); err != nil {
+		if err.Error() == "user mfa not found" {
+			return ErrMFANotEnrolled
+		}
+		return err
+	}
+
+	log.Info().Str("user_id", userID).Msg("MFA reset")
+	return nil
+}
+
+// generateRecoveryCode returns a random code formatted as four groups of
+// four characters, e.g. "k3tq-7v2m-xa5p-4hcd".
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+	return raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16], nil
+}
+
+// normalizeRecoveryCode strips formatting so codes can be typed loosely.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+// codeAt returns the TOTP code for the period offset steps from now.
+func codeAt(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	code, err := totpCode(secret, totpStep(time.Now())+offset)
+	if err != nil {
+		t.Fatalf("totpCode failed: %v", err)
+	}
+	return code
+}
+
+// loginChallenge logs in with a password and returns the MFA challenge.
+func loginChallenge(t *testing.T, svc *Service, email string) *MFAChallenge {
+	t.Helper()
+	_, err := svc.Login(context.Background(), email, "TestPassword123", "", "")
+	var challenge *MFAChallenge
+	if !errors.As(err, &challenge) {
+		t.Fatalf("Login error = %v, want MFA challenge", err)
+	}
+	return challenge
+}
+
+func TestService_MFAEnrollmentAndLogin(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	user, _ := svc.CreateUser(ctx, "mfa@example.com", "MFA", "TestPassword123", store.UserRoleAdmin)
+
+	enrollment, err := svc.BeginMFAEnrollment(ctx, user)
+	if err != nil {
+		t.Fatalf("BeginMFAEnrollment failed: %v", err)
+	}
+	u, err := url.Parse(enrollment.URL)
+	if err != nil || u.Scheme != "otpauth" || u.Query().Get("secret") != enrollment.Secret {
+		t.Errorf("unexpected otpauth url %q", enrollment.URL)
+	}
+
+	// Pending enrollments do not affect login
+	if _, err := svc.Login(ctx, "mfa@example.com", "TestPassword123", "", ""); err != nil {
+		t.Fatalf("Login with pending enrollment failed: %v", err)
+	}
+
+	if _, err := svc.ConfirmMFAEnrollment(ctx, user, codeAt(t, enrollment.Secret, 5)); err != ErrInvalidMFACode {
+		t.Errorf("ConfirmMFAEnrollment(wrong code) = %v, want %v", err, ErrInvalidMFACode)
+	}
+	confirmCode := codeAt(t, enrollment.Secret, 0)
+	codes, err := svc.ConfirmMFAEnrollment(ctx, user, confirmCode)
+	if err != nil {
+		t.Fatalf("ConfirmMFAEnrollment failed: %v", err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Errorf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+	if _, err := svc.BeginMFAEnrollment(ctx, user); err != ErrMFAAlreadyEnabled {
+		t.Errorf("BeginMFAEnrollment when enabled = %v, want %v", err, ErrMFAAlreadyEnabled)
+	}
+
+	// Login is now two-step
+	challenge := loginChallenge(t, svc, "mfa@example.com")
+	if challenge.EnrollmentRequired {
+		t.Error("enrolled user should not be asked to enroll")
+	}
+
+	// The code used to confirm enrollment cannot be replayed
+	if _, _, err := svc.VerifyMFALogin(ctx, challenge.Token, confirmCode, "", ""); err != ErrInvalidMFACode {
+		t.Errorf("replayed code error = %v, want %v", err, ErrInvalidMFACode)
+	}
+
+	tokens, recovery, err := svc.VerifyMFALogin(ctx, challenge.Token, codeAt(t, enrollment.Secret, 1), "", "")
+	if err != nil {
+		t.Fatalf("VerifyMFALogin failed: %v", err)
+	}
+	if tokens.AccessToken == "" || recovery != nil {
+		t.Errorf("unexpected login result: %+v, %v", tokens, recovery)
+	}
+
+	// The MFA token is not an access token
+	if _, err := svc.ValidateAccessToken(challenge.Token); err != ErrInvalidTokenType {
+		t.Errorf("ValidateAccessToken(mfa token) = %v, want %v", err, ErrInvalidTokenType)
+	}
+
+	// Recovery codes work once, regardless of formatting
+	if _, _, err := svc.VerifyMFALogin(ctx, challenge.Token, codes[0], "", ""); err != nil {
+		t.Errorf("VerifyMFALogin with recovery code failed: %v", err)
+	}
+	if _, _, err := svc.VerifyMFALogin(ctx, challenge.Token, normalizeRecoveryCode(codes[0]), "", ""); err != ErrInvalidMFACode {
+		t.Errorf("reused recovery code error = %v, want %v", err, ErrInvalidMFACode)
+	}
+}
+
+func TestService_MFARequiredRole(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	svc.config.MFARequiredRoles = []store.UserRole{store.UserRoleAdmin}
+	svc.CreateUser(ctx, "viewer@example.com", "Viewer", "TestPassword123", store.UserRoleViewer)
+	admin, _ := svc.CreateUser(ctx, "admin@example.com", "Admin", "TestPassword123", store.UserRoleAdmin)
+
+	if _, err := svc.Login(ctx, "viewer@example.com", "TestPassword123", "", ""); err != nil {
+		t.Errorf("Login for role without MFA failed: %v", err)
+	}
+
+	challenge := loginChallenge(t, svc, "admin@example.com")
+	if !challenge.EnrollmentRequired {
+		t.Fatal("admin without MFA should be asked to enroll")
+	}
+
+	// Without a pending enrollment the login cannot complete
+	if _, _, err := svc.VerifyMFALogin(ctx, challenge.Token, "123456", "", ""); err != ErrMFANotEnrolled {
+		t.Errorf("VerifyMFALogin error = %v, want %v", err, ErrMFANotEnrolled)
+	}
+
+	// The challenge token identifies the user for enrollment
+	user, err := svc.GetUserFromMFAToken(ctx, challenge.Token)
+	if err != nil || user.ID != admin.ID {
+		t.Fatalf("GetUserFromMFAToken = %v, %v", user, err)
+	}
+	enrollment, err := svc.BeginMFAEnrollment(ctx, user)
+	if err != nil {
+		t.Fatalf("BeginMFAEnrollment failed: %v", err)
+	}
+
+	tokens, codes, err := svc.VerifyMFALogin(ctx, challenge.Token, codeAt(t, enrollment.Secret, 0), "", "")
+	if err != nil {
+		t.Fatalf("VerifyMFALogin failed: %v", err)
+	}
+	if tokens == nil || len(codes) != recoveryCodeCount {
+		t.Errorf("enrollment during login should return tokens and recovery codes")
+	}
+	if enabled, _ := svc.MFAEnabled(ctx, admin.ID); !enabled {
+		t.Error("MFA should be enabled after login")
+	}
+
+	// Admin reset returns the user to the enrollment step
+	if err := svc.ResetMFA(ctx, admin.ID); err != nil {
+		t.Fatalf("ResetMFA failed: %v", err)
+	}
+	if err := svc.ResetMFA(ctx, admin.ID); err != ErrMFANotEnrolled {
+		t.Errorf("second ResetMFA = %v, want %v", err, ErrMFANotEnrolled)
+	}
+	if challenge := loginChallenge(t, svc, "admin@example.com"); !challenge.EnrollmentRequired {
+		t.Error("reset user should be asked to enroll again")
+	}
+}
+
+func TestService_VerifyMFALogin_Lockout(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	user, _ := svc.CreateUser(ctx, "mfa@example.com", "MFA", "TestPassword123", store.UserRoleOperator)
+	enrollment, _ := svc.BeginMFAEnrollment(ctx, user)
+	if _, err := svc.ConfirmMFAEnrollment(ctx, user, codeAt(t, enrollment.Secret, 0)); err != nil {
+		t.Fatalf("ConfirmMFAEnrollment failed: %v", err)
+	}
+
+	challenge := loginChallenge(t, svc, "mfa@example.com")
+
+	var err error
+	for i := 0; i < svc.config.Lockout.MaxAccountFailures; i++ {
+		_, _, err = svc.VerifyMFALogin(ctx, challenge.Token, "not-a-code", "", "")
+	}
+	if !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("VerifyMFALogin error = %v, want %v", err, ErrAccountLocked)
+	}
+
+	if _, _, err := svc.VerifyMFALogin(ctx, challenge.Token, codeAt(t, enrollment.Secret, 1), "", ""); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("VerifyMFALogin while locked = %v, want %v", err, ErrAccountLocked)
+	}
+}
@@ -45,8 +45,43 @@ func (s *Service) RequireAuth() func(http.Handler) http.Handler {
 
 			tokenString := parts[1]
 
+			// API tokens are opaque and looked up by hash
+			if strings.HasPrefix(tokenString, APITokenPrefix) {
+				token, user, err := s.ValidateAPIToken(r.Context(), tokenString)
+				if err != nil {
+					log.Debug().Err(err).Msg("API token validation failed")
+
+					switch err {
+					case ErrTokenExpired:
+						writeAuthError(w, http.StatusUnauthorized, "TOKEN_EXPIRED", "Token has expired")
+					case ErrAPITokenRevoked:
+						writeAuthError(w, http.StatusUnauthorized, "TOKEN_REVOKED", "Token has been revoked")
+					case ErrInvalidToken:
+						writeAuthError(w, http.StatusUnauthorized, "INVALID_TOKEN", "Invalid token")
+					case ErrUserNotFound:
+						writeAuthError(w, http.StatusUnauthorized, "USER_NOT_FOUND", "User not found")
+					default:
+						writeAuthError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Authentication failed")
+					}
+					return
+				}
+
+				policy, err := s.PolicyFor(r.Context(), user, token)
+				if err != nil {
+					log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to load permissions")
+					writeAuthError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to load permissions")
+					return
+				}
+
+				ctx := context.WithValue(r.Context(), UserContextKey, user)
+				ctx = context.WithValue(ctx, APITokenContextKey, token)
+				ctx = context.WithValue(ctx, PolicyContextKey, policy)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
 			// Validate token and get user
-			user, err := s.GetUserFromToken(r.Context(), tokenString)
+			user, claims, err := s.authenticateAccessToken(r.Context(), tokenString)
 			if err != nil {
 				log.Debug().Err(err).Msg("Token validation failed")
 
@@ -55,6 +90,8 @@ func (s *Service) RequireAuth() func(http.Handler) http.Handler {
 					writeAuthError(w, http.StatusUnauthorized, "TOKEN_EXPIRED", "Token has expired")
 				case ErrInvalidToken, ErrInvalidTokenType:
 					writeAuthError(w, http.StatusUnauthorized, "INVALID_TOKEN", "Invalid token")
+				case ErrSessionRevoked, ErrSessionExpired:
+					writeAuthError(w, http.StatusUnauthorized, "SESSION_REVOKED", "Session is no longer active")
 				case ErrUserNotFound:
 					writeAuthError(w, http.StatusUnauthorized, "USER_NOT_FOUND", "User not found")
 				default:
@@ -63,8 +100,17 @@ func (s *Service) RequireAuth() func(http.Handler) http.Handler {
 				return
 			}
 
-			// Add user to context
+			policy, err := s.PolicyFor(r.Context(), user, nil)
+			if err != nil {
+				log.Error().Err(err).Str("user_id", user.ID).Msg("Failed to load permissions")
+				writeAuthError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to load permissions")
+				return
+			}
+
+			// Add user and permissions to context
 			ctx := context.WithValue(r.Context(), UserContextKey, user)
+			ctx = context.WithValue(ctx, PolicyContextKey, policy)
+			ctx = context.WithValue(ctx, SessionContextKey, claims.SessionID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -105,6 +151,41 @@ func (s *Service) RequireRole(allowedRoles ...store.UserRole) func(http.Handler)
 	}
 }
 
+// RequireScope returns middleware that requires API tokens to carry the
+// given scope. Requests authenticated with a user JWT pass through.
+func (s *Service) RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasScope(r.Context(), scope) {
+				token := GetAPITokenFromContext(r.Context())
+				log.Debug().
+					Str("token_id", token.ID).
+					Str("required_scope", scope).
+					Msg("Access denied - missing token scope")
+				writeAuthError(w, http.StatusForbidden, "INSUFFICIENT_SCOPE", "Token is missing scope "+scope)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireUserSession returns middleware that rejects API tokens, for
+// endpoints that must only be reachable from an interactive login.
+func (s *Service) RequireUserSession() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if GetAPITokenFromContext(r.Context()) != nil {
+				writeAuthError(w, http.StatusForbidden, "FORBIDDEN", "API tokens cannot access this endpoint")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // writeAuthError writes an authentication/authorization error response.
 func writeAuthError(w http.ResponseWriter, status int, code, message string) {
 	w.Header().Set("Content-Type", "application/json")
@@ -123,7 +204,9 @@ func roleStrings(roles []store.UserRole) []string {
 }
 
 // CanPerformAction checks if a user can perform an action on a resource.
-// This can be extended for more granular permissions.
+//
+// Deprecated: CanPerformAction only knows the built-in roles. Use Authorize,
+// which also applies custom roles and API token restrictions.
 func CanPerformAction(user *store.User, action, resourceType string) bool {
 	if user == nil {
 		return false
@@ -351,3 +351,46 @@ func TestRoleStrings(t *testing.T) {
 		t.Errorf("strs[1] = %q, want %q", strs[1], "operator")
 	}
 }
+
+func TestRealIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies("10.0.0.0/8, 192.0.2.1")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies failed: %v", err)
+	}
+	if _, err := ParseTrustedProxies("not-an-ip"); err == nil {
+		t.Error("expected error for invalid proxy")
+	}
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded string
+		realIP    string
+		want      string
+	}{
+		{"untrusted peer keeps its address", "203.0.113.7:5000", "198.51.100.1", "198.51.100.2", "203.0.113.7:5000"},
+		{"trusted proxy forwards client", "192.0.2.1:443", "198.51.100.1", "", "198.51.100.1"},
+		{"spoofed hops before the proxy are skipped", "10.1.2.3:443", "1.2.3.4, 198.51.100.1, 10.9.9.9", "", "198.51.100.1"},
+		{"X-Real-IP from trusted proxy", "10.1.2.3:443", "", "198.51.100.3", "198.51.100.3"},
+		{"invalid forwarded address is ignored", "10.1.2.3:443", "garbage", "", "10.1.2.3:443"},
+	}
+
+	for _, tt := range tests {
+		var got string
+		handler := RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.RemoteAddr
+		}))
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if tt.realIP != "" {
+			req.Header.Set("X-Real-IP", tt.realIP)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if got != tt.want {
+			t.Errorf("%s: RemoteAddr = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
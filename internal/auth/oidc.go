@@ -0,0 +1,744 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrOIDCDisabled is returned when OIDC is used but not configured.
+	ErrOIDCDisabled = errors.New("oidc is not configured")
+
+	// ErrOIDCInvalidState is returned when the callback state does not match the login request.
+	ErrOIDCInvalidState = errors.New("invalid or expired oidc state")
+
+	// ErrOIDCInvalidIDToken is returned when the ID token fails verification.
+	ErrOIDCInvalidIDToken = errors.New("invalid oidc id token")
+
+	// ErrOIDCNoRole is returned when no hub role can be derived from the identity's groups.
+	ErrOIDCNoRole = errors.New("oidc identity is not mapped to a hub role")
+
+	// ErrOIDCNotLinked is returned when an identity's email belongs to a hub
+	// user who has not linked the identity to their account.
+	ErrOIDCNotLinked = errors.New("oidc identity is not linked to the existing user")
+
+	// ErrOIDCAlreadyLinked is returned when linking an identity that is
+	// linked to another user.
+	ErrOIDCAlreadyLinked = errors.New("oidc identity is linked to another user")
+)
+
+// oidcStateExpiry bounds how long a user has to complete the IdP login.
+const oidcStateExpiry = 10 * time.Minute
+
+// OIDCConfig holds OpenID Connect single sign-on configuration.
+type OIDCConfig struct {
+	// IssuerURL is the OIDC issuer; discovery is fetched from
+	// {IssuerURL}/.well-known/openid-configuration.
+	IssuerURL string
+
+	// ClientID and ClientSecret identify the hub at the issuer.
+	ClientID     string
+	ClientSecret string
+
+	// RedirectURL is the hub callback URL registered at the issuer
+	// (e.g., https://hub.example.com/api/v1/auth/oidc/callback).
+	RedirectURL string
+
+	// Scopes requested in addition to "openid".
+	Scopes []string
+
+	// GroupsClaim is the ID token claim holding group names (default "groups").
+	GroupsClaim string
+
+	// RoleMapping maps group names to hub roles. When a user is in several
+	// mapped groups, the most privileged role wins.
+	RoleMapping map[string]store.UserRole
+
+	// DefaultRole is assigned when no group maps to a role. If empty,
+	// identities without a mapped group are rejected.
+	DefaultRole store.UserRole
+
+	// PostLoginRedirectURL, if set, is where the browser is sent after a
+	// successful callback, with the token pair in the URL fragment.
+	PostLoginRedirectURL string
+
+	// HTTPClient is used for discovery, JWKS and token requests.
+	HTTPClient *http.Client
+}
+
+// Enabled reports whether OIDC has been configured.
+func (c OIDCConfig) Enabled() bool {
+	return c.IssuerURL != "" && c.ClientID != ""
+}
+
+// OIDCIdentity holds the verified claims of an ID token.
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Groups        []string
+}
+
+// oidcDiscovery is the subset of the provider metadata used by the hub.
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCProvider implements the authorization code flow with PKCE against
+// a single OIDC issuer.
+type OIDCProvider struct {
+	config OIDCConfig
+	client *http.Client
+
+	mu        sync.RWMutex
+	discovery *oidcDiscovery
+	keys      map[string]interface{}
+}
+
+// NewOIDCProvider creates a new OIDC provider. Discovery happens lazily on first use.
+func NewOIDCProvider(config OIDCConfig) *OIDCProvider {
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"email", "profile"}
+	}
+	client := config.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &OIDCProvider{
+		config: config,
+		client: client,
+		keys:   make(map[string]interface{}),
+	}
+}
+
+// Config returns the provider configuration.
+func (p *OIDCProvider) Config() OIDCConfig {
+	return p.config
+}
+
+// getDiscovery fetches and caches the provider metadata.
+func (p *OIDCProvider) getDiscovery(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.RLock()
+	d := p.discovery
+	p.mu.RUnlock()
+	if d != nil {
+		return d, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.config.IssuerURL, "/") + "/.well-known/openid-configuration"
+	var doc oidcDiscovery
+	if err := p.getJSON(ctx, wellKnown, &doc); err != nil {
+		return nil, fmt.Errorf("failed to fetch oidc discovery: %w", err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != strings.TrimSuffix(p.config.IssuerURL, "/") {
+		return nil, fmt.Errorf("oidc issuer mismatch: discovery returned %q", doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc discovery document is incomplete")
+	}
+
+	p.mu.Lock()
+	p.discovery = &doc
+	p.mu.Unlock()
+
+	return &doc, nil
+}
+
+// AuthCodeURL builds the authorization URL for the given state, nonce and PKCE verifier.
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint: %w", err)
+	}
+
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.config.ClientID)
+	q.Set("redirect_uri", p.config.RedirectURL)
+	q.Set("scope", strings.Join(append([]string{"openid"}, p.config.Scopes...), " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", pkceChallenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// Exchange redeems an authorization code and returns the verified identity.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, verifier, nonce string) (*OIDCIdentity, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", verifier)
+	form.Set("client_id", p.config.ClientID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var tokenResp struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if tokenResp.IDToken == "" {
+		return nil, fmt.Errorf("token response has no id_token")
+	}
+
+	return p.VerifyIDToken(ctx, tokenResp.IDToken, nonce)
+}
+
+// VerifyIDToken verifies an ID token's signature, issuer, audience, expiry and nonce.
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*OIDCIdentity, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.Parse(rawIDToken, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.getKey(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		log.Debug().Err(err).Msg("OIDC ID token verification failed")
+		return nil, ErrOIDCInvalidIDToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, ErrOIDCInvalidIDToken
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		log.Debug().Msg("OIDC ID token nonce mismatch")
+		return nil, ErrOIDCInvalidIDToken
+	}
+
+	identity := &OIDCIdentity{}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+	identity.Name, _ = claims["name"].(string)
+	identity.Groups = stringsClaim(claims[p.config.GroupsClaim])
+
+	if identity.Subject == "" {
+		return nil, ErrOIDCInvalidIDToken
+	}
+
+	return identity, nil
+}
+
+// MapRole derives the hub role for a set of groups.
+func (p *OIDCProvider) MapRole(groups []string) (store.UserRole, bool) {
+	best := store.UserRole("")
+	for _, g := range groups {
+		role, ok := p.config.RoleMapping[g]
+		if ok && rolePrivilege(role) > rolePrivilege(best) {
+			best = role
+		}
+	}
+	if best != "" {
+		return best, true
+	}
+	if p.config.DefaultRole != "" {
+		return p.config.DefaultRole, true
+	}
+	return "", false
+}
+
+// rolePrivilege orders roles from least to most privileged.
+func rolePrivilege(role store.UserRole) int {
+	switch role {
+	case store.UserRoleAdmin:
+		return 3
+	case store.UserRoleOperator:
+		return 2
+	case store.UserRoleViewer:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// getKey returns the verification key for a key ID, refreshing the JWKS once on a miss.
+func (p *OIDCProvider) getKey(ctx context.Context, kid string) (interface{}, error) {
+	if key := p.lookupKey(kid); key != nil {
+		return key, nil
+	}
+	if err := p.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+	if key := p.lookupKey(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey finds a cached key. An empty kid matches when exactly one key is known.
+func (p *OIDCProvider) lookupKey(kid string) interface{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key
+		}
+	}
+	return p.keys[kid]
+}
+
+// refreshKeys fetches the provider's JWKS.
+func (p *OIDCProvider) refreshKeys(ctx context.Context) error {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return err
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, d.JWKSURI, &jwks); err != nil {
+		return fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+
+	keys := make(map[string]interface{})
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			log.Warn().Err(err).Str("kid", k.Kid).Msg("Skipping unsupported JWKS key")
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+
+	return nil
+}
+
+// getJSON performs a GET request and decodes the JSON response.
+func (p *OIDCProvider) getJSON(ctx context.Context, rawURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, rawURL)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// jsonWebKey is a public key in JWK format (RFC 7517).
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// publicKey converts the JWK into an *rsa.PublicKey or *ecdsa.PublicKey.
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent: %w", err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %w", err)
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// stringsClaim normalizes a claim that may be a string or a list of strings.
+func stringsClaim(v interface{}) []string {
+	switch c := v.(type) {
+	case string:
+		return []string{c}
+	case []interface{}:
+		result := make([]string, 0, len(c))
+		for _, item := range c {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+// pkceChallenge computes the S256 code challenge for a verifier (RFC 7636).
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// oidcStateClaims carries the login request parameters between the
+// login redirect and the callback, signed so it can live in a cookie.
+type oidcStateClaims struct {
+	jwt.RegisteredClaims
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	// LinkUserID is set when a signed-in user links the identity to their
+	// account rather than logging in.
+	LinkUserID string `json:"link_user_id,omitempty"`
+}
+
+// OIDCEnabled reports whether OIDC single sign-on is configured.
+func (s *Service) OIDCEnabled() bool {
+	return s.oidc != nil
+}
+
+// OIDCProvider returns the configured OIDC provider, or nil.
+func (s *Service) OIDCProvider() *OIDCProvider {
+	return s.oidc
+}
+
+// BeginOIDCLogin starts an authorization code flow. It returns the URL to
+// redirect the browser to and an opaque state token that must be presented
+// again at the callback (normally via a cookie).
+func (s *Service) BeginOIDCLogin(ctx context.Context) (authURL, stateToken string, err error) {
+	return s.beginOIDCFlow(ctx, "")
+}
+
+// BeginOIDCLink starts an authorization code flow that links the identity
+// the user signs in with at the IdP to their hub account. It is completed
+// by CompleteOIDCLogin like a login. Identities are never linked to existing
+// accounts by email alone, since that would hand the account to whoever
+// controls the address at the IdP.
+func (s *Service) BeginOIDCLink(ctx context.Context, user *store.User) (authURL, stateToken string, err error) {
+	return s.beginOIDCFlow(ctx, user.ID)
+}
+
+// beginOIDCFlow starts an authorization code flow, linking the identity to
+// the user with linkUserID if set.
+func (s *Service) beginOIDCFlow(ctx context.Context, linkUserID string) (authURL, stateToken string, err error) {
+	if s.oidc == nil {
+		return "", "", ErrOIDCDisabled
+	}
+
+	state, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate state: %w", err)
+	}
+	nonce, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	// 64 hex characters is within the 43-128 characters allowed by RFC 7636
+	verifier, err := GenerateRandomToken(32)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate pkce verifier: %w", err)
+	}
+
+	authURL, err = s.oidc.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	claims := oidcStateClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(oidcStateExpiry)),
+			Issuer:    "sentinel-hub",
+			Subject:   "oidc-state",
+		},
+		State:      state,
+		Nonce:      nonce,
+		Verifier:   verifier,
+		LinkUserID: linkUserID,
+	}
+	stateToken, err = s.signToken(claims)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to sign oidc state: %w", err)
+	}
+
+	return authURL, stateToken, nil
+}
+
+// CompleteOIDCLogin finishes an authorization code flow: it validates the
+// state, redeems the code, links or provisions the user and issues a token
+// pair. Like Login, users with MFA, or whose role requires it, get an
+// *MFAChallenge instead and finish with VerifyMFALogin.
+func (s *Service) CompleteOIDCLogin(ctx context.Context, stateToken, state, code, ipAddress, userAgent string) (*TokenPair, *store.User, error) {
+	if s.oidc == nil {
+		return nil, nil, ErrOIDCDisabled
+	}
+
+	var claims oidcStateClaims
+	token, err := jwt.ParseWithClaims(stateToken, &claims, s.verificationKey)
+	if err != nil || !token.Valid || claims.Subject != "oidc-state" {
+		return nil, nil, ErrOIDCInvalidState
+	}
+	if state == "" || claims.State != state {
+		return nil, nil, ErrOIDCInvalidState
+	}
+
+	identity, err := s.oidc.Exchange(ctx, code, claims.Verifier, claims.Nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if claims.LinkUserID != "" {
+		if err := s.linkOIDCUser(ctx, claims.LinkUserID, identity); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	user, err := s.provisionOIDCUser(ctx, identity)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The IdP vouches for the first factor only
+	mfaEnabled, err := s.MFAEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get mfa status: %w", err)
+	}
+	if mfaEnabled || s.mfaRequired(user) {
+		mfaToken, err := s.generateMFAToken(user)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, user, &MFAChallenge{Token: mfaToken, EnrollmentRequired: !mfaEnabled}
+	}
+
+	tokenPair, err := s.createTokenPair(ctx, user, ipAddress, userAgent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.store.UpdateUserLastLogin(ctx, user.ID); err != nil {
+		log.Warn().Err(err).Str("user_id", user.ID).Msg("Failed to update last login")
+	}
+
+	log.Info().
+		Str("user_id", user.ID).
+		Str("email", user.Email).
+		Str("role", string(user.Role)).
+		Msg("User logged in via OIDC")
+
+	return tokenPair, user, nil
+}
+
+// linkOIDCUser links an OIDC identity to the user with the given ID.
+func (s *Service) linkOIDCUser(ctx context.Context, userID string, identity *OIDCIdentity) error {
+	linked, err := s.store.GetUserByOIDCSubject(ctx, identity.Subject)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if linked != nil {
+		if linked.ID != userID {
+			return ErrOIDCAlreadyLinked
+		}
+		return nil
+	}
+
+	user, err := s.store.GetUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	user.OIDCSubject = &identity.Subject
+	if err := s.store.UpdateUser(ctx, user); err != nil {
+		return fmt.Errorf("failed to link user: %w", err)
+	}
+
+	log.Info().
+		Str("user_id", user.ID).
+		Str("email", user.Email).
+		Msg("Linked user to OIDC identity")
+	return nil
+}
+
+// provisionOIDCUser finds or creates the hub user for an OIDC identity
+// and keeps its role in sync with the identity's groups.
+func (s *Service) provisionOIDCUser(ctx context.Context, identity *OIDCIdentity) (*store.User, error) {
+	role, ok := s.oidc.MapRole(identity.Groups)
+
+	user, err := s.store.GetUserByOIDCSubject(ctx, identity.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if user == nil {
+		if !ok {
+			return nil, ErrOIDCNoRole
+		}
+		if identity.Email == "" {
+			return nil, fmt.Errorf("%w: email claim is required", ErrOIDCInvalidIDToken)
+		}
+
+		name := identity.Name
+		if name == "" {
+			name = identity.Email
+		}
+		user = &store.User{
+			ID:          uuid.New().String(),
+			Email:       identity.Email,
+			Name:        name,
+			Role:        role,
+			OIDCSubject: &identity.Subject,
+		}
+		if err := s.store.CreateUser(ctx, user); err != nil {
+			// Existing accounts must link the identity themselves
+			if err.Error() == "user with this email already exists" {
+				return nil, ErrOIDCNotLinked
+			}
+			return nil, fmt.Errorf("failed to create user: %w", err)
+		}
+
+		log.Info().
+			Str("user_id", user.ID).
+			Str("email", user.Email).
+			Str("role", string(user.Role)).
+			Msg("User provisioned from OIDC")
+
+		return user, nil
+	}
+
+	// Existing users follow the group mapping when one is configured;
+	// otherwise their role stays under hub administration.
+	if len(s.oidc.config.RoleMapping) > 0 {
+		if !ok {
+			return nil, ErrOIDCNoRole
+		}
+		if user.Role != role {
+			log.Info().
+				Str("user_id", user.ID).
+				Str("old_role", string(user.Role)).
+				Str("new_role", string(role)).
+				Msg("Updating user role from OIDC groups")
+			user.Role = role
+			if err := s.store.UpdateUser(ctx, user); err != nil {
+				return nil, fmt.Errorf("failed to update user role: %w", err)
+			}
+		}
+	}
+
+	return user, nil
+}
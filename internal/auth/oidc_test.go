@@ -0,0 +1,370 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+// stubIssuer is a minimal OIDC provider for tests.
+type stubIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu       sync.Mutex
+	codes    map[string]stubAuthRequest
+	identity map[string]interface{}
+}
+
+type stubAuthRequest struct {
+	nonce     string
+	challenge string
+}
+
+func newStubIssuer(t *testing.T) *stubIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	si := &stubIssuer{
+		key:   key,
+		codes: make(map[string]stubAuthRequest),
+		identity: map[string]interface{}{
+			"sub":            "oidc-user-1",
+			"email":          "sso@example.com",
+			"email_verified": true,
+			"name":           "SSO User",
+			"groups":         []string{"hub-operators"},
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 si.server.URL,
+			"authorization_endpoint": si.server.URL + "/authorize",
+			"token_endpoint":         si.server.URL + "/token",
+			"jwks_uri":               si.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "stub-key",
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		si.mu.Lock()
+		req, ok := si.codes[r.Form.Get("code")]
+		delete(si.codes, r.Form.Get("code"))
+		si.mu.Unlock()
+
+		if !ok || pkceChallenge(r.Form.Get("code_verifier")) != req.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "stub-access-token",
+			"token_type":   "Bearer",
+			"id_token":     si.signIDToken(t, req.nonce),
+		})
+	})
+
+	si.server = httptest.NewServer(mux)
+	t.Cleanup(si.server.Close)
+
+	return si
+}
+
+// authorize simulates the user approving the login and returns the callback state and code.
+func (si *stubIssuer) authorize(t *testing.T, authURL string) (state, code string) {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("invalid auth URL: %v", err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("code_challenge_method = %q, want S256", q.Get("code_challenge_method"))
+	}
+
+	code, _ = GenerateRandomToken(8)
+	si.mu.Lock()
+	si.codes[code] = stubAuthRequest{nonce: q.Get("nonce"), challenge: q.Get("code_challenge")}
+	si.mu.Unlock()
+
+	return q.Get("state"), code
+}
+
+func (si *stubIssuer) signIDToken(t *testing.T, nonce string) string {
+	claims := jwt.MapClaims{
+		"iss":   si.server.URL,
+		"aud":   "hub-client",
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(5 * time.Minute).Unix(),
+		"nonce": nonce,
+	}
+	si.mu.Lock()
+	for k, v := range si.identity {
+		claims[k] = v
+	}
+	si.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "stub-key"
+	signed, err := token.SignedString(si.key)
+	if err != nil {
+		t.Fatalf("failed to sign id token: %v", err)
+	}
+	return signed
+}
+
+func setupOIDCService(t *testing.T, si *stubIssuer, mapping map[string]store.UserRole, defaultRole store.UserRole) (*Service, store.Store) {
+	t.Helper()
+
+	db, err := store.New("sqlite://:memory:")
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	svc := NewService(db, Config{
+		JWTSecret:  "test-secret-key-for-testing-12345",
+		BcryptCost: 4,
+		OIDC: OIDCConfig{
+			IssuerURL:   si.server.URL,
+			ClientID:    "hub-client",
+			RedirectURL: "http://hub.test/api/v1/auth/oidc/callback",
+			RoleMapping: mapping,
+			DefaultRole: defaultRole,
+		},
+	})
+	return svc, db
+}
+
+func TestService_OIDCLogin_ProvisionsUser(t *testing.T) {
+	si := newStubIssuer(t)
+	svc, db := setupOIDCService(t, si, map[string]store.UserRole{
+		"hub-admins":    store.UserRoleAdmin,
+		"hub-operators": store.UserRoleOperator,
+	}, "")
+	ctx := context.Background()
+
+	authURL, stateToken, err := svc.BeginOIDCLogin(ctx)
+	if err != nil {
+		t.Fatalf("BeginOIDCLogin failed: %v", err)
+	}
+	state, code := si.authorize(t, authURL)
+
+	tokens, user, err := svc.CompleteOIDCLogin(ctx, stateToken, state, code, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("CompleteOIDCLogin failed: %v", err)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Error("expected a token pair")
+	}
+	if user.Role != store.UserRoleOperator {
+		t.Errorf("role = %q, want %q", user.Role, store.UserRoleOperator)
+	}
+
+	stored, err := db.GetUserByOIDCSubject(ctx, "oidc-user-1")
+	if err != nil || stored == nil {
+		t.Fatalf("provisioned user not found: %v", err)
+	}
+	if stored.Email != "sso@example.com" || stored.PasswordHash != nil {
+		t.Errorf("unexpected provisioned user: %+v", stored)
+	}
+
+	// Group change on the next login updates the role
+	si.identity["groups"] = []string{"hub-admins", "hub-operators"}
+	authURL, stateToken, _ = svc.BeginOIDCLogin(ctx)
+	state, code = si.authorize(t, authURL)
+	_, user, err = svc.CompleteOIDCLogin(ctx, stateToken, state, code, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("second CompleteOIDCLogin failed: %v", err)
+	}
+	if user.ID != stored.ID {
+		t.Error("second login should reuse the provisioned user")
+	}
+	if user.Role != store.UserRoleAdmin {
+		t.Errorf("role = %q, want %q", user.Role, store.UserRoleAdmin)
+	}
+}
+
+func TestService_OIDCLogin_LinksExistingUserExplicitly(t *testing.T) {
+	si := newStubIssuer(t)
+	svc, db := setupOIDCService(t, si, nil, store.UserRoleViewer)
+	ctx := context.Background()
+
+	existing, err := svc.CreateUser(ctx, "sso@example.com", "Local", "TestPassword123", store.UserRoleAdmin)
+	if err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+
+	// A matching email alone does not grant the account
+	authURL, stateToken, _ := svc.BeginOIDCLogin(ctx)
+	state, code := si.authorize(t, authURL)
+	if _, _, err := svc.CompleteOIDCLogin(ctx, stateToken, state, code, "", ""); err != ErrOIDCNotLinked {
+		t.Fatalf("CompleteOIDCLogin error = %v, want %v", err, ErrOIDCNotLinked)
+	}
+	if linked, _ := db.GetUser(ctx, existing.ID); linked.OIDCSubject != nil {
+		t.Fatal("OIDC subject should not be linked by email")
+	}
+
+	authURL, stateToken, err = svc.BeginOIDCLink(ctx, existing)
+	if err != nil {
+		t.Fatalf("BeginOIDCLink failed: %v", err)
+	}
+	state, code = si.authorize(t, authURL)
+	_, user, err := svc.CompleteOIDCLogin(ctx, stateToken, state, code, "", "")
+	if err != nil {
+		t.Fatalf("CompleteOIDCLogin failed: %v", err)
+	}
+	if user.ID != existing.ID {
+		t.Error("expected the existing user to be linked")
+	}
+	// Without a role mapping the admin-managed role is kept
+	if user.Role != store.UserRoleAdmin {
+		t.Errorf("role = %q, want %q", user.Role, store.UserRoleAdmin)
+	}
+	linked, _ := db.GetUser(ctx, existing.ID)
+	if linked.OIDCSubject == nil || *linked.OIDCSubject != "oidc-user-1" {
+		t.Error("OIDC subject was not linked")
+	}
+
+	// The identity cannot be linked to a second user
+	other, _ := svc.CreateUser(ctx, "other@example.com", "Other", "TestPassword123", store.UserRoleViewer)
+	authURL, stateToken, _ = svc.BeginOIDCLink(ctx, other)
+	state, code = si.authorize(t, authURL)
+	if _, _, err := svc.CompleteOIDCLogin(ctx, stateToken, state, code, "", ""); err != ErrOIDCAlreadyLinked {
+		t.Errorf("CompleteOIDCLogin error = %v, want %v", err, ErrOIDCAlreadyLinked)
+	}
+}
+
+func TestService_OIDCLogin_MFARequired(t *testing.T) {
+	si := newStubIssuer(t)
+	svc, _ := setupOIDCService(t, si, map[string]store.UserRole{"hub-operators": store.UserRoleOperator}, "")
+	svc.config.MFARequiredRoles = []store.UserRole{store.UserRoleOperator}
+	ctx := context.Background()
+
+	authURL, stateToken, _ := svc.BeginOIDCLogin(ctx)
+	state, code := si.authorize(t, authURL)
+	tokens, user, err := svc.CompleteOIDCLogin(ctx, stateToken, state, code, "", "")
+	var challenge *MFAChallenge
+	if !errors.As(err, &challenge) || tokens != nil {
+		t.Fatalf("expected an MFA challenge, got %v, %v", tokens, err)
+	}
+	if !challenge.EnrollmentRequired {
+		t.Error("expected enrollment to be required")
+	}
+	if mfaUser, err := svc.GetUserFromMFAToken(ctx, challenge.Token); err != nil || mfaUser.ID != user.ID {
+		t.Errorf("mfa token is not for the provisioned user: %v", err)
+	}
+}
+
+func TestService_OIDCLogin_UnmappedGroupRejected(t *testing.T) {
+	si := newStubIssuer(t)
+	svc, _ := setupOIDCService(t, si, map[string]store.UserRole{"hub-admins": store.UserRoleAdmin}, "")
+	ctx := context.Background()
+
+	authURL, stateToken, _ := svc.BeginOIDCLogin(ctx)
+	state, code := si.authorize(t, authURL)
+	_, _, err := svc.CompleteOIDCLogin(ctx, stateToken, state, code, "", "")
+	if err != ErrOIDCNoRole {
+		t.Errorf("CompleteOIDCLogin error = %v, want %v", err, ErrOIDCNoRole)
+	}
+}
+
+func TestService_OIDCLogin_StateMismatch(t *testing.T) {
+	si := newStubIssuer(t)
+	svc, _ := setupOIDCService(t, si, nil, store.UserRoleViewer)
+	ctx := context.Background()
+
+	authURL, stateToken, _ := svc.BeginOIDCLogin(ctx)
+	_, code := si.authorize(t, authURL)
+
+	_, _, err := svc.CompleteOIDCLogin(ctx, stateToken, "forged-state", code, "", "")
+	if err != ErrOIDCInvalidState {
+		t.Errorf("CompleteOIDCLogin error = %v, want %v", err, ErrOIDCInvalidState)
+	}
+
+	_, _, err = svc.CompleteOIDCLogin(ctx, "not-a-token", "forged-state", code, "", "")
+	if err != ErrOIDCInvalidState {
+		t.Errorf("CompleteOIDCLogin error = %v, want %v", err, ErrOIDCInvalidState)
+	}
+}
+
+func TestOIDCProvider_VerifyIDToken_WrongNonce(t *testing.T) {
+	si := newStubIssuer(t)
+	p := NewOIDCProvider(OIDCConfig{IssuerURL: si.server.URL, ClientID: "hub-client"})
+
+	_, err := p.VerifyIDToken(context.Background(), si.signIDToken(t, "nonce-a"), "nonce-b")
+	if err != ErrOIDCInvalidIDToken {
+		t.Errorf("VerifyIDToken error = %v, want %v", err, ErrOIDCInvalidIDToken)
+	}
+}
+
+func TestOIDCProvider_MapRole(t *testing.T) {
+	p := NewOIDCProvider(OIDCConfig{
+		RoleMapping: map[string]store.UserRole{
+			"ops":    store.UserRoleOperator,
+			"admins": store.UserRoleAdmin,
+		},
+	})
+
+	tests := []struct {
+		groups []string
+		want   store.UserRole
+		ok     bool
+	}{
+		{[]string{"ops"}, store.UserRoleOperator, true},
+		{[]string{"ops", "admins"}, store.UserRoleAdmin, true},
+		{[]string{"other"}, "", false},
+		{nil, "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := p.MapRole(tt.groups)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("MapRole(%v) = %q, %v; want %q, %v", tt.groups, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestService_BeginOIDCLogin_Disabled(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+
+	if svc.OIDCEnabled() {
+		t.Error("OIDC should be disabled without configuration")
+	}
+	if _, _, err := svc.BeginOIDCLogin(context.Background()); err != ErrOIDCDisabled {
+		t.Errorf("BeginOIDCLogin error = %v, want %v", err, ErrOIDCDisabled)
+	}
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/raskell-io/sentinel-hub/internal/notify"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrInvalidResetToken is returned when a password reset token is unknown,
+	// expired, or already used.
+	ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+	// ErrNotifierNotConfigured is returned when a notification cannot be sent
+	// because no notifier is configured.
+	ErrNotifierNotConfigured = errors.New("notifier not configured")
+)
+
+// SetNotifier configures how password reset links are delivered to users.
+func (s *Service) SetNotifier(n notify.Notifier) {
+	s.notifier = n
+}
+
+// NotifierConfigured reports whether the service can deliver notifications.
+func (s *Service) NotifierConfigured() bool {
+	return s.notifier != nil
+}
+
+// GeneratePasswordResetToken creates a single-use password reset token for a
+// user. Only the token's hash is stored; it expires after PasswordResetExpiry.
+func (s *Service) GeneratePasswordResetToken(ctx context.Context, userID string) (string, error) {
+	// Verify user exists
+	user, err := s.store.GetUser(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return "", ErrUserNotFound
+	}
+
+	return s.createPasswordResetToken(ctx, user)
+}
+
+// createPasswordResetToken stores a new reset token for user and returns its plaintext.
+func (s *Service) createPasswordResetToken(ctx context.Context, user *store.User) (string, error) {
+	token, err := GenerateRandomToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	if err := s.store.CreatePasswordResetToken(ctx, &store.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: HashToken(token),
+		ExpiresAt: time.Now().UTC().Add(s.config.PasswordResetExpiry),
+	}); err != nil {
+		return "", err
+	}
+
+	log.Info().
+		Str("user_id", user.ID).
+		Msg("Password reset token generated")
+
+	return token, nil
+}
+
+// SendPasswordResetLink generates a reset token and delivers it to the user's
+// email address through the configured notifier.
+func (s *Service) SendPasswordResetLink(ctx context.Context, userID string) error {
+	if s.notifier == nil {
+		return ErrNotifierNotConfigured
+	}
+
+	user, err := s.store.GetUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	token, err := s.createPasswordResetToken(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hello %s,\n\nA password reset was requested for your Sentinel Hub account.\n\n", user.Name)
+	if link := s.passwordResetLink(token); link != "" {
+		body += "Open this link to choose a new password:\n\n" + link + "\n\n"
+	} else {
+		body += "Use this token to choose a new password:\n\n" + token + "\n\n"
+	}
+	body += fmt.Sprintf("The link expires in %s and can only be used once.\n", s.config.PasswordResetExpiry)
+
+	if err := s.notifier.Send(ctx, notify.Message{
+		To:      user.Email,
+		Subject: "Reset your Sentinel Hub password",
+		Body:    body,
+	}); err != nil {
+		return fmt.Errorf("failed to send password reset link: %w", err)
+	}
+
+	log.Info().Str("user_id", user.ID).Msg("Password reset link sent")
+	return nil
+}
+
+// passwordResetLink returns the reset URL for a token, or "" if no reset
+// page is configured.
+func (s *Service) passwordResetLink(token string) string {
+	if s.config.PasswordResetURL == "" {
+		return ""
+	}
+	u, err := url.Parse(s.config.PasswordResetURL)
+	if err != nil {
+		log.Warn().Err(err).Msg("Invalid password reset URL")
+		return ""
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// ResetPasswordWithToken redeems a password reset token and sets a new
+// password. Redeeming a token invalidates all other outstanding tokens for
+// the user and clears any login lockout on the account.
+func (s *Service) ResetPasswordWithToken(ctx context.Context, token, newPassword string) error {
+	// Check the password first so a weak password does not burn the token
+	if err := ValidatePasswordStrength(newPassword); err != nil {
+		return err
+	}
+
+	reset, err := s.store.GetPasswordResetTokenByHash(ctx, HashToken(token))
+	if err != nil {
+		return fmt.Errorf("failed to get password reset token: %w", err)
+	}
+	if reset == nil || reset.UsedAt != nil || time.Now().UTC().After(reset.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	if err := s.store.UsePasswordResetToken(ctx, reset.ID); err != nil {
+		if err.Error() == "password reset token not found" {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	if err := s.UpdatePassword(ctx, reset.UserID, newPassword); err != nil {
+		return err
+	}
+
+	if user, err := s.store.GetUser(ctx, reset.UserID); err == nil && user != nil {
+		if err := s.store.DeleteLoginThrottle(ctx, accountThrottleKey(user.Email)); err != nil {
+			log.Warn().Err(err).Str("user_id", user.ID).Msg("Failed to clear login failures after password reset")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raskell-io/sentinel-hub/internal/notify"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+// recordingNotifier captures sent messages.
+type recordingNotifier struct {
+	messages []notify.Message
+}
+
+func (n *recordingNotifier) Send(ctx context.Context, msg notify.Message) error {
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+func TestService_ResetPasswordWithToken(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	user, _ := svc.CreateUser(ctx, "reset@example.com", "Reset", "TestPassword123", store.UserRoleViewer)
+
+	token, err := svc.GeneratePasswordResetToken(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GeneratePasswordResetToken failed: %v", err)
+	}
+
+	// A weak password is rejected without consuming the token
+	if err := svc.ResetPasswordWithToken(ctx, token, "weak"); err != ErrPasswordTooShort {
+		t.Errorf("ResetPasswordWithToken(weak) = %v, want %v", err, ErrPasswordTooShort)
+	}
+
+	if err := svc.ResetPasswordWithToken(ctx, token, "NewPassword456"); err != nil {
+		t.Fatalf("ResetPasswordWithToken failed: %v", err)
+	}
+	if _, err := svc.Login(ctx, "reset@example.com", "NewPassword456", "", ""); err != nil {
+		t.Errorf("Login with new password failed: %v", err)
+	}
+
+	// Tokens are single use
+	if err := svc.ResetPasswordWithToken(ctx, token, "OtherPassword789"); err != ErrInvalidResetToken {
+		t.Errorf("reused token error = %v, want %v", err, ErrInvalidResetToken)
+	}
+	if err := svc.ResetPasswordWithToken(ctx, "unknown", "OtherPassword789"); err != ErrInvalidResetToken {
+		t.Errorf("unknown token error = %v, want %v", err, ErrInvalidResetToken)
+	}
+}
+
+func TestService_ResetPasswordWithToken_Expired(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	user, _ := svc.CreateUser(ctx, "reset@example.com", "Reset", "TestPassword123", store.UserRoleViewer)
+
+	svc.config.PasswordResetExpiry = -time.Minute
+	token, _ := svc.GeneratePasswordResetToken(ctx, user.ID)
+
+	if err := svc.ResetPasswordWithToken(ctx, token, "NewPassword456"); err != ErrInvalidResetToken {
+		t.Errorf("ResetPasswordWithToken error = %v, want %v", err, ErrInvalidResetToken)
+	}
+}
+
+func TestService_SendPasswordResetLink(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	user, _ := svc.CreateUser(ctx, "reset@example.com", "Reset", "TestPassword123", store.UserRoleViewer)
+
+	if err := svc.SendPasswordResetLink(ctx, user.ID); err != ErrNotifierNotConfigured {
+		t.Errorf("SendPasswordResetLink error = %v, want %v", err, ErrNotifierNotConfigured)
+	}
+
+	notifier := &recordingNotifier{}
+	svc.SetNotifier(notifier)
+	svc.config.PasswordResetURL = "https://hub.example.com/reset-password"
+
+	if err := svc.SendPasswordResetLink(ctx, user.ID); err != nil {
+		t.Fatalf("SendPasswordResetLink failed: %v", err)
+	}
+	if len(notifier.messages) != 1 || notifier.messages[0].To != "reset@example.com" {
+		t.Fatalf("unexpected messages: %+v", notifier.messages)
+	}
+
+	// The link carries a token that can be redeemed
+	var link string
+	for _, line := range strings.Split(notifier.messages[0].Body, "\n") {
+		if strings.HasPrefix(line, svc.config.PasswordResetURL) {
+			link = line
+		}
+	}
+	u, err := url.Parse(link)
+	if err != nil || u.Query().Get("token") == "" {
+		t.Fatalf("no reset link in message body: %q", notifier.messages[0].Body)
+	}
+	if err := svc.ResetPasswordWithToken(ctx, u.Query().Get("token"), "NewPassword456"); err != nil {
+		t.Errorf("ResetPasswordWithToken failed: %v", err)
+	}
+
+	if err := svc.SendPasswordResetLink(ctx, "nonexistent"); err != ErrUserNotFound {
+		t.Errorf("SendPasswordResetLink error = %v, want %v", err, ErrUserNotFound)
+	}
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseTrustedProxies parses a comma-separated list of proxy addresses and
+// CIDR ranges, e.g. "10.0.0.0/8,192.0.2.1".
+func ParseTrustedProxies(spec string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// RealIP returns middleware that sets a request's RemoteAddr to the client
+// address forwarded by trusted proxies. X-Forwarded-For and X-Real-IP are
+// only honoured on connections from a trusted proxy, and X-Forwarded-For is
+// read from the right, skipping trusted hops, since clients can prepend any
+// addresses they like. Requests from anywhere else keep the connection's
+// address, so login throttling cannot be evaded by sending the headers.
+func RealIP(trusted []*net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip := forwardedClientIP(r, trusted); ip != "" {
+				r.RemoteAddr = ip
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedClientIP returns the client address proxies forwarded a request
+// for, or "" if the request did not come through a trusted proxy.
+func forwardedClientIP(r *http.Request, trusted []*net.IPNet) string {
+	if !isTrustedProxy(remoteIP(r.RemoteAddr), trusted) {
+		return ""
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				return ""
+			}
+			if i == 0 || !isTrustedProxy(ip, trusted) {
+				return ip.String()
+			}
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// remoteIP parses the IP of a host:port address.
+func remoteIP(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(addr)
+}
+
+// isTrustedProxy reports whether ip is in one of the trusted ranges.
+func isTrustedProxy(ip net.IP, trusted []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,248 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// Permissions share their names with API token scopes, so a token's scopes
+// are simply the subset of its owner's permissions it may use.
+
+// ErrInvalidPermission is returned when a custom role contains an unknown
+// or non-delegable permission.
+var ErrInvalidPermission = errors.New("invalid permission")
+
+// PolicyContextKey is the key for storing the request's policy in context.
+const PolicyContextKey contextKey = "policy"
+
+// builtinPermissions lists the unrestricted permissions of the built-in roles.
+var builtinPermissions = map[store.UserRole][]string{
+	store.UserRoleAdmin: Scopes(),
+	store.UserRoleOperator: {
+		ScopeInstancesRead, ScopeInstancesWrite,
+		ScopeConfigsRead, ScopeConfigsWrite,
+		ScopeDeploymentsRead, ScopeDeploymentsCreate, ScopeDeploymentsCancel,
+		ScopeSecretsRead,
+	},
+	store.UserRoleViewer: {
+		ScopeInstancesRead, ScopeConfigsRead, ScopeDeploymentsRead,
+	},
+}
+
+// rolePermissions are the permissions custom roles may grant. User, audit
+// and webhook administration stays with the built-in admin role.
+var rolePermissions = map[string]bool{
+	ScopeInstancesRead:     true,
+	ScopeInstancesWrite:    true,
+	ScopeInstancesDelete:   true,
+	ScopeConfigsRead:       true,
+	ScopeConfigsWrite:      true,
+	ScopeConfigsDelete:     true,
+	ScopeDeploymentsRead:   true,
+	ScopeDeploymentsCreate: true,
+	ScopeDeploymentsCancel: true,
+	ScopeSecretsRead:       true,
+	ScopeSecretsWrite:      true,
+}
+
+// Resource identifies what an action is performed on, for matching against
+// permission selectors.
+type Resource struct {
+	labels     map[string]string
+	configName string
+	instance   bool
+	config     bool
+}
+
+// InstanceResource returns a resource for an instance with the given labels.
+func InstanceResource(labels map[string]string) Resource {
+	return Resource{labels: labels, instance: true}
+}
+
+// ConfigResource returns a resource for the config with the given name.
+func ConfigResource(name string) Resource {
+	return Resource{configName: name, config: true}
+}
+
+// SecretResource returns a resource for the secret with the given name.
+// Grants match secret names against their config pattern.
+func SecretResource(name string) Resource {
+	return Resource{configName: name, config: true}
+}
+
+// DeploymentResource returns a resource for deploying the named config to an
+// instance with the given labels.
+func DeploymentResource(labels map[string]string, configName string) Resource {
+	return Resource{labels: labels, configName: configName, instance: true, config: true}
+}
+
+// Policy is the effective set of permissions for an authenticated request.
+type Policy struct {
+	grants []store.RolePermission
+
+	// Set when the request uses an API token
+	scopes         map[string]bool
+	instanceLabels map[string]string
+}
+
+// NewPolicy creates a policy for a user's built-in role and custom roles.
+func NewPolicy(role store.UserRole, roles []store.Role) *Policy {
+	p := &Policy{}
+	for _, perm := range builtinPermissions[role] {
+		p.grants = append(p.grants, store.RolePermission{Permission: perm})
+	}
+	for _, r := range roles {
+		p.grants = append(p.grants, r.Permissions...)
+	}
+	return p
+}
+
+// restrictToToken limits the policy to an API token's scopes and labels.
+func (p *Policy) restrictToToken(token *store.APIToken) {
+	p.scopes = make(map[string]bool, len(token.Scopes))
+	for _, scope := range token.Scopes {
+		p.scopes[scope] = true
+	}
+	p.instanceLabels = token.Labels
+}
+
+// Has reports whether the policy grants the permission on any resource.
+func (p *Policy) Has(permission string) bool {
+	if p.scopes != nil && !p.scopes[permission] {
+		return false
+	}
+	for _, g := range p.grants {
+		if g.Permission == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether the policy grants the permission on the resource.
+func (p *Policy) Allows(permission string, res Resource) bool {
+	if p.scopes != nil && !p.scopes[permission] {
+		return false
+	}
+	if res.instance && !matchSelector(p.instanceLabels, res.labels) {
+		return false
+	}
+
+	for _, g := range p.grants {
+		if g.Permission != permission {
+			continue
+		}
+		if res.instance && !matchSelector(g.InstanceSelector, res.labels) {
+			continue
+		}
+		if res.config && g.ConfigPattern != "" {
+			if ok, _ := path.Match(g.ConfigPattern, res.configName); !ok {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// matchSelector reports whether labels contain every key/value in selector.
+func matchSelector(selector, labels map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// PolicyFor loads the effective policy for a user, optionally restricted to
+// an API token.
+func (s *Service) PolicyFor(ctx context.Context, user *store.User, token *store.APIToken) (*Policy, error) {
+	roles, err := s.store.ListUserRoles(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := NewPolicy(user.Role, roles)
+	if token != nil {
+		policy.restrictToToken(token)
+	}
+	return policy, nil
+}
+
+// ValidateRolePermissions checks that a custom role only grants delegable
+// permissions with valid selectors.
+func ValidateRolePermissions(perms []store.RolePermission) error {
+	for _, p := range perms {
+		if !rolePermissions[p.Permission] {
+			return fmt.Errorf("%w: %s", ErrInvalidPermission, p.Permission)
+		}
+		if p.ConfigPattern != "" {
+			if _, err := path.Match(p.ConfigPattern, ""); err != nil {
+				return fmt.Errorf("%w: bad config_pattern %q", ErrInvalidPermission, p.ConfigPattern)
+			}
+		}
+	}
+	return nil
+}
+
+// GetPolicyFromContext retrieves the request's policy from context.
+func GetPolicyFromContext(ctx context.Context) *Policy {
+	if policy, ok := ctx.Value(PolicyContextKey).(*Policy); ok {
+		return policy
+	}
+	return nil
+}
+
+// Authorize reports whether the request may perform the permission on the
+// resource. Contexts without a policy, such as internal calls, are allowed;
+// RequireAuth always sets one for API requests.
+func Authorize(ctx context.Context, permission string, res Resource) bool {
+	policy := GetPolicyFromContext(ctx)
+	if policy == nil {
+		return true
+	}
+	return policy.Allows(permission, res)
+}
+
+// HasPermission reports whether the request holds the permission on at least
+// one resource.
+func HasPermission(ctx context.Context, permission string) bool {
+	policy := GetPolicyFromContext(ctx)
+	if policy == nil {
+		return true
+	}
+	return policy.Has(permission)
+}
+
+// RequirePermission returns middleware that requires the permission on at
+// least one resource. Handlers check the specific resource with Authorize.
+func (s *Service) RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasScope(r.Context(), permission) {
+				writeAuthError(w, http.StatusForbidden, "INSUFFICIENT_SCOPE", "Token is missing scope "+permission)
+				return
+			}
+
+			if !HasPermission(r.Context(), permission) {
+				user := GetUserFromContext(r.Context())
+				event := log.Debug().Str("required_permission", permission)
+				if user != nil {
+					event = event.Str("user_id", user.ID).Str("role", string(user.Role))
+				}
+				event.Msg("Access denied - missing permission")
+				writeAuthError(w, http.StatusForbidden, "FORBIDDEN", "Insufficient permissions")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+var paymentsDeployer = store.Role{
+	Name: "payments-deployer",
+	Permissions: []store.RolePermission{
+		{Permission: ScopeConfigsWrite, ConfigPattern: "payments-*"},
+		{Permission: ScopeDeploymentsCreate, InstanceSelector: map[string]string{"team": "payments"}, ConfigPattern: "payments-*"},
+	},
+}
+
+func TestPolicy_BuiltinRoles(t *testing.T) {
+	tests := []struct {
+		role       store.UserRole
+		permission string
+		want       bool
+	}{
+		{store.UserRoleAdmin, ScopeUsersWrite, true},
+		{store.UserRoleAdmin, ScopeInstancesDelete, true},
+		{store.UserRoleOperator, ScopeDeploymentsCreate, true},
+		{store.UserRoleOperator, ScopeConfigsDelete, false},
+		{store.UserRoleOperator, ScopeAuditRead, false},
+		{store.UserRoleViewer, ScopeConfigsRead, true},
+		{store.UserRoleViewer, ScopeConfigsWrite, false},
+		{"", ScopeConfigsRead, false},
+	}
+
+	for _, tt := range tests {
+		p := NewPolicy(tt.role, nil)
+		if got := p.Has(tt.permission); got != tt.want {
+			t.Errorf("%q Has(%s) = %v, want %v", tt.role, tt.permission, got, tt.want)
+		}
+		if got := p.Allows(tt.permission, InstanceResource(map[string]string{"team": "x"})); got != tt.want {
+			t.Errorf("%q Allows(%s) = %v, want %v", tt.role, tt.permission, got, tt.want)
+		}
+	}
+}
+
+func TestPolicy_Selectors(t *testing.T) {
+	p := NewPolicy(store.UserRoleViewer, []store.Role{paymentsDeployer})
+
+	payments := map[string]string{"team": "payments", "env": "prod"}
+	search := map[string]string{"team": "search", "env": "prod"}
+
+	tests := []struct {
+		name       string
+		permission string
+		res        Resource
+		want       bool
+	}{
+		{"deploy own config to own instance", ScopeDeploymentsCreate, DeploymentResource(payments, "payments-api"), true},
+		{"deploy to other team", ScopeDeploymentsCreate, DeploymentResource(search, "payments-api"), false},
+		{"deploy other config", ScopeDeploymentsCreate, DeploymentResource(payments, "search-api"), false},
+		{"write own config", ScopeConfigsWrite, ConfigResource("payments-api"), true},
+		{"write other config", ScopeConfigsWrite, ConfigResource("search-api"), false},
+		{"read anything via viewer", ScopeInstancesRead, InstanceResource(search), true},
+		{"no grant", ScopeInstancesWrite, InstanceResource(payments), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Allows(tt.permission, tt.res); got != tt.want {
+				t.Errorf("Allows = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if !p.Has(ScopeDeploymentsCreate) {
+		t.Error("Has should report permissions granted on some resources")
+	}
+}
+
+func TestValidateRolePermissions(t *testing.T) {
+	valid := []store.RolePermission{{Permission: ScopeConfigsWrite, ConfigPattern: "team-*"}}
+	if err := ValidateRolePermissions(valid); err != nil {
+		t.Errorf("ValidateRolePermissions(valid) = %v", err)
+	}
+
+	invalid := [][]store.RolePermission{
+		{{Permission: ScopeUsersWrite}},
+		{{Permission: "configs:everything"}},
+		{{Permission: ScopeConfigsRead, ConfigPattern: "[bad"}},
+	}
+	for _, perms := range invalid {
+		if err := ValidateRolePermissions(perms); !errors.Is(err, ErrInvalidPermission) {
+			t.Errorf("ValidateRolePermissions(%v) = %v, want %v", perms, err, ErrInvalidPermission)
+		}
+	}
+}
+
+func TestService_RequirePermission_CustomRole(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	user, _ := svc.CreateUser(ctx, "team@example.com", "Team", "TestPassword123", store.UserRoleViewer)
+	role := paymentsDeployer
+	if err := db.CreateRole(ctx, &role); err != nil {
+		t.Fatalf("CreateRole failed: %v", err)
+	}
+	if err := db.CreateRoleBinding(ctx, &store.RoleBinding{UserID: user.ID, RoleID: role.ID}); err != nil {
+		t.Fatalf("CreateRoleBinding failed: %v", err)
+	}
+
+	tokens, err := svc.Login(ctx, "team@example.com", "TestPassword123", "", "")
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+
+	tests := []struct {
+		permission string
+		want       int
+	}{
+		{ScopeDeploymentsCreate, http.StatusOK},
+		{ScopeConfigsRead, http.StatusOK},
+		{ScopeInstancesWrite, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		handler := svc.RequireAuth()(svc.RequirePermission(tt.permission)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})))
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.permission, rec.Code, tt.want)
+		}
+	}
+
+	// Custom role permissions can be delegated to API tokens
+	if _, _, err := svc.CreateAPIToken(ctx, CreateAPITokenRequest{
+		Name:   "team-ci",
+		Owner:  user,
+		Scopes: []string{ScopeDeploymentsCreate},
+	}); err != nil {
+		t.Errorf("CreateAPIToken failed: %v", err)
+	}
+}
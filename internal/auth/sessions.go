@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrSessionNotFound is returned when a session does not exist, belongs
+	// to another user or is no longer active.
+	ErrSessionNotFound = errors.New("session not found")
+
+	// ErrRefreshTokenReused is returned when a refresh token that was already
+	// exchanged is presented again. The session is revoked, since either the
+	// client or an attacker holds a stolen token.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// SessionContextKey is the key for storing the session ID of the request's
+// access token in context.
+const SessionContextKey contextKey = "session_id"
+
+// GetSessionIDFromContext returns the login session the request was
+// authenticated with, or "" for API tokens.
+func GetSessionIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(SessionContextKey).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// checkSession verifies that the session an access token was issued for has
+// not been revoked, so logging out or revoking a session takes effect before
+// the access token expires. Tokens without a session ID are not checked.
+func (s *Service) checkSession(ctx context.Context, claims *Claims) error {
+	if claims.SessionID == "" {
+		return nil
+	}
+
+	session, err := s.store.GetUserSession(ctx, claims.SessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil || session.UserID != claims.UserID || session.RevokedAt != nil {
+		return ErrSessionRevoked
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return ErrSessionExpired
+	}
+	return nil
+}
+
+// checkRefreshTokenReuse is called for a refresh token that is not the
+// current token of any session. If it is a superseded token of a session,
+// the session is revoked and ErrRefreshTokenReused returned.
+func (s *Service) checkRefreshTokenReuse(ctx context.Context, tokenHash string) error {
+	session, err := s.store.GetUserSessionByPreviousTokenHash(ctx, tokenHash)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return ErrInvalidToken
+	}
+
+	if session.RevokedAt == nil {
+		if err := s.store.RevokeUserSession(ctx, session.ID); err != nil {
+			return fmt.Errorf("failed to revoke session: %w", err)
+		}
+		s.auditRefreshTokenReuse(ctx, session)
+	}
+
+	return ErrRefreshTokenReused
+}
+
+// auditRefreshTokenReuse records a session revoked because of token reuse.
+func (s *Service) auditRefreshTokenReuse(ctx context.Context, session *store.UserSession) {
+	details, _ := json.Marshal(map[string]interface{}{
+		"ip_address": session.IPAddress,
+		"user_agent": session.UserAgent,
+	})
+
+	userID := session.UserID
+	entry := &store.AuditLog{
+		UserID:       &userID,
+		Action:       "refresh_token_reuse",
+		ResourceType: "session",
+		ResourceID:   &session.ID,
+		Details:      details,
+	}
+	if err := s.audit.Record(ctx, entry); err != nil {
+		log.Warn().Err(err).Str("session_id", session.ID).Msg("Failed to create token reuse audit log")
+	}
+
+	log.Warn().
+		Str("user_id", session.UserID).
+		Str("session_id", session.ID).
+		Msg("Refresh token reused, session revoked")
+}
+
+// ListSessions returns a user's active login sessions.
+func (s *Service) ListSessions(ctx context.Context, userID string) ([]store.UserSession, error) {
+	return s.store.ListUserSessions(ctx, userID)
+}
+
+// RevokeSession revokes one of a user's active sessions. Its refresh token
+// stops working immediately and so do its access tokens.
+func (s *Service) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	session, err := s.store.GetUserSession(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil || session.UserID != userID || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return ErrSessionNotFound
+	}
+
+	if err := s.store.RevokeUserSession(ctx, sessionID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	log.Info().
+		Str("user_id", userID).
+		Str("session_id", sessionID).
+		Msg("Session revoked")
+
+	return nil
+}
+
+// RevokeAllSessions revokes every session of a user, signing them out everywhere.
+func (s *Service) RevokeAllSessions(ctx context.Context, userID string) error {
+	if err := s.store.RevokeAllUserSessions(ctx, userID); err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("user_id", userID).
+		Msg("All sessions revoked")
+
+	return nil
+}
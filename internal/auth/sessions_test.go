@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+func TestService_RefreshTokens_ReuseRevokesSession(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	svc.CreateUser(ctx, "reuse@example.com", "Reuse", "TestPassword123", store.UserRoleViewer)
+	first, err := svc.Login(ctx, "reuse@example.com", "TestPassword123", "", "")
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+
+	second, err := svc.RefreshTokens(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshTokens failed: %v", err)
+	}
+	third, err := svc.RefreshTokens(ctx, second.RefreshToken)
+	if err != nil {
+		t.Fatalf("second RefreshTokens failed: %v", err)
+	}
+
+	// Rotation keeps the session
+	firstClaims, _ := svc.ValidateAccessToken(first.AccessToken)
+	thirdClaims, _ := svc.ValidateAccessToken(third.AccessToken)
+	if firstClaims.SessionID == "" || firstClaims.SessionID != thirdClaims.SessionID {
+		t.Errorf("session changed on refresh: %q -> %q", firstClaims.SessionID, thirdClaims.SessionID)
+	}
+
+	// Replaying a superseded token revokes the whole session
+	if _, err := svc.RefreshTokens(ctx, first.RefreshToken); err != ErrRefreshTokenReused {
+		t.Fatalf("RefreshTokens(reused) = %v, want %v", err, ErrRefreshTokenReused)
+	}
+	if _, err := svc.RefreshTokens(ctx, third.RefreshToken); err != ErrSessionRevoked {
+		t.Errorf("RefreshTokens(current) after reuse = %v, want %v", err, ErrSessionRevoked)
+	}
+	if _, err := svc.GetUserFromToken(ctx, third.AccessToken); err != ErrSessionRevoked {
+		t.Errorf("GetUserFromToken after reuse = %v, want %v", err, ErrSessionRevoked)
+	}
+
+	logs, _ := db.ListAuditLogs(ctx, store.ListAuditLogsOptions{Action: "refresh_token_reuse"})
+	if len(logs) != 1 {
+		t.Errorf("got %d reuse audit entries, want 1", len(logs))
+	}
+}
+
+func TestService_RevokeSession(t *testing.T) {
+	svc, db := setupTestService(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	user, _ := svc.CreateUser(ctx, "sessions@example.com", "Sessions", "TestPassword123", store.UserRoleViewer)
+	other, _ := svc.CreateUser(ctx, "other@example.com", "Other", "TestPassword123", store.UserRoleViewer)
+
+	laptop, _ := svc.Login(ctx, "sessions@example.com", "TestPassword123", "10.0.0.1", "laptop")
+	phone, _ := svc.Login(ctx, "sessions@example.com", "TestPassword123", "10.0.0.2", "phone")
+
+	sessions, err := svc.ListSessions(ctx, user.ID)
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("ListSessions = %v, %v", sessions, err)
+	}
+
+	claims, _ := svc.ValidateAccessToken(phone.AccessToken)
+	if err := svc.RevokeSession(ctx, other.ID, claims.SessionID); err != ErrSessionNotFound {
+		t.Errorf("RevokeSession(other user) = %v, want %v", err, ErrSessionNotFound)
+	}
+	if err := svc.RevokeSession(ctx, user.ID, claims.SessionID); err != nil {
+		t.Fatalf("RevokeSession failed: %v", err)
+	}
+	if err := svc.RevokeSession(ctx, user.ID, claims.SessionID); err != ErrSessionNotFound {
+		t.Errorf("second RevokeSession = %v, want %v", err, ErrSessionNotFound)
+	}
+
+	// The revoked session's access token stops working at once
+	handler := svc.RequireAuth()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if GetSessionIDFromContext(r.Context()) == "" {
+			t.Error("session ID missing from context")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	for token, want := range map[string]int{
+		laptop.AccessToken: http.StatusOK,
+		phone.AccessToken:  http.StatusUnauthorized,
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("status = %d, want %d", rr.Code, want)
+		}
+	}
+
+	if sessions, _ := svc.ListSessions(ctx, user.ID); len(sessions) != 1 || *sessions[0].UserAgent != "laptop" {
+		t.Errorf("unexpected sessions after revoke: %+v", sessions)
+	}
+
+	if err := svc.RevokeAllSessions(ctx, user.ID); err != nil {
+		t.Fatalf("RevokeAllSessions failed: %v", err)
+	}
+	if _, err := svc.RefreshTokens(ctx, laptop.RefreshToken); err != ErrSessionRevoked {
+		t.Errorf("RefreshTokens after RevokeAllSessions = %v, want %v", err, ErrSessionRevoked)
+	}
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 test key from RFC 6238, base32 encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode_RFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := totpCode(rfc6238Secret, totpStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("totpCode failed: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("totpCode(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, _ := totpCode(rfc6238Secret, totpStep(now))
+	previous, _ := totpCode(rfc6238Secret, totpStep(now)-1)
+	stale, _ := totpCode(rfc6238Secret, totpStep(now)-3)
+
+	if step, ok := validateTOTP(rfc6238Secret, code, now); !ok || step != totpStep(now) {
+		t.Errorf("current code rejected")
+	}
+	if _, ok := validateTOTP(rfc6238Secret, previous, now); !ok {
+		t.Error("code from the previous period should be accepted")
+	}
+	if _, ok := validateTOTP(rfc6238Secret, stale, now); ok {
+		t.Error("stale code should be rejected")
+	}
+	if _, ok := validateTOTP(rfc6238Secret, "12345", now); ok {
+		t.Error("short code should be rejected")
+	}
+}
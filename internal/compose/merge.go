@@ -0,0 +1,97 @@
+// Package compose builds layered configs: a base config with overlay
+// configs applied to it node by node.
+package compose
+
+import (
+	"fmt"
+
+	"github.com/raskell-io/sentinel-hub/internal/kdl"
+)
+
+// Patch directives are written as type annotations on overlay nodes.
+const (
+	// DirectiveDelete removes the matching base node: (delete)upstream "web"
+	DirectiveDelete = "delete"
+	// DirectiveReplace replaces the matching base node instead of merging
+	// into it, dropping properties and children the overlay does not set.
+	DirectiveReplace = "replace"
+)
+
+// Merge applies an overlay document to a base document and returns the
+// result, leaving both unchanged.
+//
+// Overlay nodes are matched to base nodes as described by kdl.SiblingKeys.
+// A matched node takes the overlay's arguments if it has any, and the
+// overlay's properties override or add to the base's; children are merged
+// the same way. Unmatched overlay nodes are appended.
+func Merge(base, overlay []*kdl.Node) ([]*kdl.Node, error) {
+	return merge("", base, overlay)
+}
+
+func merge(parent string, base, overlay []*kdl.Node) ([]*kdl.Node, error) {
+	baseKeys, overlayKeys := kdl.SiblingKeys(base, overlay)
+
+	result := make([]*kdl.Node, len(base))
+	index := make(map[string]int, len(base))
+	for i, n := range base {
+		result[i] = n.Clone()
+		index[baseKeys[i]] = i
+	}
+	deleted := make(map[int]bool)
+
+	for j, o := range overlay {
+		key := overlayKeys[j]
+		path := key
+		if parent != "" {
+			path = parent + " > " + key
+		}
+
+		i, ok := index[key]
+		switch o.Type {
+		case DirectiveDelete:
+			if !ok {
+				return nil, fmt.Errorf("%s: no node to delete", path)
+			}
+			deleted[i] = true
+		case DirectiveReplace:
+			n := o.Clone()
+			n.Type = ""
+			if ok {
+				result[i] = n
+			} else {
+				result = append(result, n)
+			}
+		default:
+			if !ok {
+				result = append(result, o.Clone())
+				continue
+			}
+			n := result[i]
+			if o.Type != "" {
+				n.Type = o.Type
+			}
+			if len(o.Args) > 0 {
+				n.Args = append([]kdl.Value(nil), o.Args...)
+			}
+			for _, p := range o.Props {
+				n.SetProp(p.Name, p.Value)
+			}
+			children, err := merge(path, n.Children, o.Children)
+			if err != nil {
+				return nil, err
+			}
+			n.Children = children
+		}
+	}
+
+	if len(deleted) == 0 {
+		return result, nil
+	}
+	kept := result[:0]
+	for i, n := range result {
+		if !deleted[i] {
+			kept = append(kept, n)
+		}
+	}
+	return kept, nil
+}
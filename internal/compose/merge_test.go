@@ -0,0 +1,76 @@
+package compose
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/raskell-io/sentinel-hub/internal/kdl"
+)
+
+func mustParse(t *testing.T, src string) []*kdl.Node {
+	t.Helper()
+	nodes, err := kdl.Parse(src)
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", src, err)
+	}
+	return nodes
+}
+
+func TestMerge(t *testing.T) {
+	base := mustParse(t, `
+server {
+    listen "0.0.0.0:8080"
+    workers 2
+}
+upstream "api" weight=1 {
+    target "10.0.0.1:80"
+}
+upstream "web" {
+    target "10.0.0.2:80"
+}
+logging level="info"
+`)
+	overlay := mustParse(t, `
+server {
+    workers 8
+}
+upstream "api" weight=5 timeout=30
+(delete)upstream "web"
+(replace)logging level="warn"
+limits rps=1000
+`)
+
+	merged, err := Merge(base, overlay)
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+
+	want := `server {
+    listen "0.0.0.0:8080"
+    workers 8
+}
+upstream "api" weight=5 timeout=30 {
+    target "10.0.0.1:80"
+}
+logging level="warn"
+limits rps=1000
+`
+	if got := kdl.Format(merged); got != want {
+		t.Errorf("unexpected merge result:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The inputs are left alone
+	if got := kdl.Format(base); !strings.Contains(got, `upstream "web"`) || !strings.Contains(got, "workers 2") {
+		t.Errorf("base was modified:\n%s", got)
+	}
+}
+
+func TestMerge_DeleteMissingNode(t *testing.T) {
+	base := mustParse(t, "server {\n    listen 8080\n}\n")
+	overlay := mustParse(t, "server {\n    (delete)workers\n}\n")
+
+	_, err := Merge(base, overlay)
+	if err == nil || !strings.Contains(err.Error(), "server > workers") {
+		t.Errorf("expected an error naming the missing node, got %v", err)
+	}
+}
@@ -0,0 +1,368 @@
+package compose
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/raskell-io/sentinel-hub/internal/auth"
+	"github.com/raskell-io/sentinel-hub/internal/events"
+	"github.com/raskell-io/sentinel-hub/internal/kdl"
+	"github.com/raskell-io/sentinel-hub/internal/secrets"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// ErrInvalidLayers is returned for layer lists that cannot be used:
+	// missing or deleted configs, the config itself, or a cycle.
+	ErrInvalidLayers = errors.New("invalid config layers")
+
+	// ErrResolve is returned when the layers cannot be combined, such as
+	// when one is not valid KDL.
+	ErrResolve = errors.New("failed to resolve config layers")
+)
+
+// Resolver keeps the versions of composed configs in step with their
+// layers.
+type Resolver struct {
+	store  store.Store
+	events *events.Bus
+}
+
+// NewResolver creates a resolver.
+func NewResolver(s store.Store) *Resolver {
+	return &Resolver{store: s}
+}
+
+// SetEventBus sets the bus config.resolved events are published on.
+func (r *Resolver) SetEventBus(b *events.Bus) {
+	r.events = b
+}
+
+// CheckLayers validates a layer list for a config: every layer must be an
+// existing config other than the config itself, and no layer may be
+// composed from the config, directly or indirectly.
+func (r *Resolver) CheckLayers(ctx context.Context, configID string, layerIDs []string) error {
+	seen := make(map[string]bool, len(layerIDs))
+	for _, id := range layerIDs {
+		if id == configID {
+			return fmt.Errorf("%w: a config cannot be its own layer", ErrInvalidLayers)
+		}
+		if seen[id] {
+			return fmt.Errorf("%w: config %s is listed twice", ErrInvalidLayers, id)
+		}
+		seen[id] = true
+
+		cfg, err := r.store.GetConfig(ctx, id)
+		if err != nil {
+			return err
+		}
+		if cfg == nil {
+			return fmt.Errorf("%w: config %s not found", ErrInvalidLayers, id)
+		}
+	}
+
+	// Walk down from the layers; reaching the config would close a cycle
+	visited := make(map[string]bool)
+	queue := append([]string(nil), layerIDs...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+
+		layers, err := r.store.ListConfigLayers(ctx, id)
+		if err != nil {
+			return err
+		}
+		for _, layer := range layers {
+			if layer == configID {
+				return fmt.Errorf("%w: config %s is already composed from this config", ErrInvalidLayers, id)
+			}
+			queue = append(queue, layer)
+		}
+	}
+	return nil
+}
+
+// Result is the combination of a composed config's layers.
+type Result struct {
+	Content    string
+	Hash       string
+	IsTemplate bool // Set when any layer is a template
+	Layers     []store.ConfigLayer
+	// Summary names the layer versions, e.g. "base v3, prod v2".
+	Summary string
+}
+
+// Build combines the current versions of the given layers, base first,
+// without storing anything.
+func (r *Resolver) Build(ctx context.Context, layerIDs []string) (*Result, error) {
+	var doc []*kdl.Node
+	res := &Result{Layers: make([]store.ConfigLayer, 0, len(layerIDs))}
+	names := make([]string, 0, len(layerIDs))
+
+	for i, id := range layerIDs {
+		layerCfg, err := r.store.GetConfig(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if layerCfg == nil {
+			return nil, fmt.Errorf("%w: layer %s not found", ErrResolve, id)
+		}
+		ver, err := r.store.GetConfigVersion(ctx, id, layerCfg.CurrentVersion)
+		if err != nil {
+			return nil, err
+		}
+		if ver == nil {
+			return nil, fmt.Errorf("%w: %s has no version %d", ErrResolve, layerCfg.Name, layerCfg.CurrentVersion)
+		}
+
+		nodes, err := kdl.Parse(ver.Content)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s v%d: %v", ErrResolve, layerCfg.Name, ver.Version, err)
+		}
+		if i == 0 {
+			doc = nodes
+		} else if doc, err = Merge(doc, nodes); err != nil {
+			return nil, fmt.Errorf("%w: %s v%d: %v", ErrResolve, layerCfg.Name, ver.Version, err)
+		}
+
+		res.IsTemplate = res.IsTemplate || ver.IsTemplate
+		res.Layers = append(res.Layers, store.ConfigLayer{ConfigID: id, Version: ver.Version})
+		names = append(names, fmt.Sprintf("%s v%d", layerCfg.Name, ver.Version))
+	}
+
+	res.Content = kdl.Format(doc)
+	sum := sha256.Sum256([]byte(res.Content))
+	res.Hash = hex.EncodeToString(sum[:])
+	res.Summary = strings.Join(names, ", ")
+	return res, nil
+}
+
+// Resolve combines the current versions of a composed config's layers and
+// stores the result as a new version of the config. It returns nil when
+// the config has no layers or the result is unchanged. Secrets the result
+// refers to that the config did not refer to before must be usable by the
+// caller in ctx, who is recorded as createdBy; otherwise nothing is stored.
+func (r *Resolver) Resolve(ctx context.Context, cfg *store.Config, createdBy *string) (*store.ConfigVersion, error) {
+	layerIDs, err := r.store.ListConfigLayers(ctx, cfg.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(layerIDs) == 0 {
+		return nil, nil
+	}
+
+	res, err := r.Build(ctx, layerIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := r.store.GetLatestConfigVersion(ctx, cfg.ID)
+	if err != nil {
+		return nil, err
+	}
+	next := 1
+	if latest != nil {
+		if latest.ContentHash == res.Hash && latest.IsTemplate == res.IsTemplate {
+			return nil, nil
+		}
+		next = latest.Version + 1
+	}
+	if err := checkNewReferences(ctx, latest, res); err != nil {
+		return nil, err
+	}
+
+	summary := "Resolved from " + res.Summary
+	ver := &store.ConfigVersion{
+		ID:            uuid.New().String(),
+		ConfigID:      cfg.ID,
+		Version:       next,
+		Content:       res.Content,
+		ContentHash:   res.Hash,
+		IsTemplate:    res.IsTemplate,
+		Layers:        res.Layers,
+		ChangeSummary: &summary,
+		CreatedBy:     createdBy,
+	}
+	if err := r.store.CreateConfigVersion(ctx, ver); err != nil {
+		return nil, err
+	}
+
+	cfg.CurrentVersion = ver.Version
+	if err := r.store.UpdateConfig(ctx, cfg); err != nil {
+		return nil, err
+	}
+
+	r.publish(ctx, cfg, ver)
+	return ver, nil
+}
+
+// checkNewReferences checks that the caller in ctx may use the secrets a
+// result refers to that the latest version did not.
+func checkNewReferences(ctx context.Context, latest *store.ConfigVersion, res *Result) error {
+	names, err := references(res.IsTemplate, res.Content)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrResolve, err)
+	}
+	var previous []string
+	if latest != nil {
+		// Previous references were checked when they were added
+		previous, _ = references(latest.IsTemplate, latest.Content)
+	}
+	for _, name := range names {
+		if !slices.Contains(previous, name) && !auth.Authorize(ctx, auth.ScopeSecretsRead, auth.SecretResource(name)) {
+			return fmt.Errorf("%w: not permitted to use secret %s", ErrResolve, name)
+		}
+	}
+	return nil
+}
+
+// references returns the secrets a config version's content refers to.
+func references(isTemplate bool, content string) ([]string, error) {
+	if isTemplate {
+		return secrets.TemplateReferences(content)
+	}
+	return secrets.References(content), nil
+}
+
+// LayerChanged re-resolves every config composed from the given config,
+// directly or through other composed configs, and returns the versions it
+// created. Dependents that fail to resolve keep their current version and
+// are logged; the first such error is returned after the rest are done.
+func (r *Resolver) LayerChanged(ctx context.Context, configID string, createdBy *string) ([]*store.ConfigVersion, error) {
+	var created []*store.ConfigVersion
+	var firstErr error
+
+	// CheckLayers keeps the graph acyclic, so this terminates
+	queue := []string{configID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		dependents, err := r.store.ListConfigDependents(ctx, id)
+		if err != nil {
+			return created, err
+		}
+		for _, depID := range dependents {
+			cfg, err := r.store.GetConfig(ctx, depID)
+			if err != nil || cfg == nil {
+				continue
+			}
+			ver, err := r.Resolve(ctx, cfg, createdBy)
+			if err != nil {
+				log.Warn().Err(err).Str("config_id", depID).Str("layer_id", id).Msg("Failed to resolve composed config")
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			if ver != nil {
+				created = append(created, ver)
+				queue = append(queue, depID)
+			}
+		}
+	}
+
+	return created, firstErr
+}
+
+// Dependent is a config composed from another config, with the instances
+// that still run an older version of it.
+type Dependent struct {
+	ConfigID          string   `json:"config_id"`
+	Name              string   `json:"name"`
+	CurrentVersion    int      `json:"current_version"`
+	NeedsRedeploy     bool     `json:"needs_redeploy"`
+	OutdatedInstances []string `json:"outdated_instances"`
+}
+
+// Dependents returns the configs composed from a config, directly or
+// through other composed configs.
+func (r *Resolver) Dependents(ctx context.Context, configID string) ([]Dependent, error) {
+	var result []Dependent
+	visited := map[string]bool{configID: true}
+
+	queue := []string{configID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		ids, err := r.store.ListConfigDependents(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, depID := range ids {
+			if visited[depID] {
+				continue
+			}
+			visited[depID] = true
+			queue = append(queue, depID)
+
+			cfg, err := r.store.GetConfig(ctx, depID)
+			if err != nil {
+				return nil, err
+			}
+			if cfg == nil {
+				continue
+			}
+			outdated, err := r.outdatedInstances(ctx, cfg)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, Dependent{
+				ConfigID:          cfg.ID,
+				Name:              cfg.Name,
+				CurrentVersion:    cfg.CurrentVersion,
+				NeedsRedeploy:     len(outdated) > 0,
+				OutdatedInstances: outdated,
+			})
+		}
+	}
+	return result, nil
+}
+
+// outdatedInstances returns the instances running an older version of cfg.
+func (r *Resolver) outdatedInstances(ctx context.Context, cfg *store.Config) ([]string, error) {
+	instances, err := r.store.ListInstances(ctx, store.ListInstancesOptions{ConfigID: cfg.ID})
+	if err != nil {
+		return nil, err
+	}
+	outdated := []string{}
+	for _, inst := range instances {
+		if inst.CurrentConfigVersion == nil || *inst.CurrentConfigVersion < cfg.CurrentVersion {
+			outdated = append(outdated, inst.ID)
+		}
+	}
+	return outdated, nil
+}
+
+func (r *Resolver) publish(ctx context.Context, cfg *store.Config, ver *store.ConfigVersion) {
+	if r.events == nil {
+		return
+	}
+	outdated, err := r.outdatedInstances(ctx, cfg)
+	if err != nil {
+		log.Warn().Err(err).Str("config_id", cfg.ID).Msg("Failed to list outdated instances")
+	}
+	layers := make([]events.LayerData, len(ver.Layers))
+	for i, l := range ver.Layers {
+		layers[i] = events.LayerData{ConfigID: l.ConfigID, Version: l.Version}
+	}
+	r.events.Publish(ctx, events.ConfigResolved, events.ConfigResolvedData{
+		ConfigID:          cfg.ID,
+		ConfigName:        cfg.Name,
+		ConfigVersion:     ver.Version,
+		Layers:            layers,
+		OutdatedInstances: outdated,
+	})
+}
@@ -0,0 +1,219 @@
+package compose
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/raskell-io/sentinel-hub/internal/auth"
+	"github.com/raskell-io/sentinel-hub/internal/events"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+// setupTestStore creates a temporary SQLite store for testing.
+func setupTestStore(t *testing.T) store.Store {
+	t.Helper()
+
+	tmpFile, err := os.CreateTemp("", "hub-test-*.db")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	tmpFile.Close()
+
+	s, err := store.New(tmpFile.Name())
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		t.Fatalf("failed to create test store: %v", err)
+	}
+
+	t.Cleanup(func() {
+		s.Close()
+		os.Remove(tmpFile.Name())
+		os.Remove(tmpFile.Name() + "-shm")
+		os.Remove(tmpFile.Name() + "-wal")
+	})
+	return s
+}
+
+// createConfig creates a config with a first version.
+func createConfig(t *testing.T, s store.Store, name, content string) *store.Config {
+	t.Helper()
+	ctx := context.Background()
+
+	cfg := &store.Config{Name: name}
+	if err := s.CreateConfig(ctx, cfg); err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+	if err := s.CreateConfigVersion(ctx, &store.ConfigVersion{ConfigID: cfg.ID, Version: 1, Content: content}); err != nil {
+		t.Fatalf("failed to create config version: %v", err)
+	}
+	return cfg
+}
+
+// updateConfig adds a version to a config as the API does.
+func updateConfig(t *testing.T, s store.Store, cfg *store.Config, content string) {
+	t.Helper()
+	ctx := context.Background()
+
+	cfg.CurrentVersion++
+	if err := s.CreateConfigVersion(ctx, &store.ConfigVersion{ConfigID: cfg.ID, Version: cfg.CurrentVersion, Content: content}); err != nil {
+		t.Fatalf("failed to create config version: %v", err)
+	}
+	if err := s.UpdateConfig(ctx, cfg); err != nil {
+		t.Fatalf("failed to update config: %v", err)
+	}
+}
+
+func TestResolver_ResolvesAndFollowsLayers(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	bus := events.NewBus()
+	var resolved []events.ConfigResolvedData
+	bus.Subscribe(func(_ context.Context, e events.Event) {
+		if data, ok := e.Data.(events.ConfigResolvedData); ok {
+			resolved = append(resolved, data)
+		}
+	})
+	r := NewResolver(s)
+	r.SetEventBus(bus)
+
+	base := createConfig(t, s, "base", "server {\n    listen 8080\n    workers 2\n}\n")
+	prod := createConfig(t, s, "prod", "server {\n    workers 8\n}\n")
+	edge := &store.Config{Name: "edge-prod"}
+	s.CreateConfig(ctx, edge)
+
+	if err := r.CheckLayers(ctx, edge.ID, []string{base.ID, prod.ID}); err != nil {
+		t.Fatalf("CheckLayers failed: %v", err)
+	}
+	s.SetConfigLayers(ctx, edge.ID, []string{base.ID, prod.ID})
+
+	ver, err := r.Resolve(ctx, edge, nil)
+	if err != nil || ver == nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if ver.Version != 1 || ver.Content != "server {\n    listen 8080\n    workers 8\n}\n" {
+		t.Errorf("unexpected resolved version %d:\n%s", ver.Version, ver.Content)
+	}
+	if len(ver.Layers) != 2 || ver.Layers[0].ConfigID != base.ID || ver.Layers[0].Version != 1 {
+		t.Errorf("unexpected layers: %+v", ver.Layers)
+	}
+
+	// Resolving again without changes creates nothing
+	if ver, err := r.Resolve(ctx, edge, nil); err != nil || ver != nil {
+		t.Errorf("expected no new version, got %+v, %v", ver, err)
+	}
+
+	// An instance running v1 needs a redeploy once the base changes
+	v1 := 1
+	inst := &store.Instance{Name: "edge-1", Hostname: "edge-1", Status: store.InstanceStatusOnline,
+		CurrentConfigID: &edge.ID, CurrentConfigVersion: &v1}
+	s.CreateInstance(ctx, inst)
+
+	updateConfig(t, s, base, "server {\n    listen 9090\n    workers 2\n}\n")
+	created, err := r.LayerChanged(ctx, base.ID, nil)
+	if err != nil {
+		t.Fatalf("LayerChanged failed: %v", err)
+	}
+	if len(created) != 1 || created[0].Version != 2 || !strings.Contains(created[0].Content, "listen 9090") {
+		t.Fatalf("expected a new resolved version, got %+v", created)
+	}
+	if created[0].Layers[0].Version != 2 {
+		t.Errorf("expected base v2 in layers, got %+v", created[0].Layers)
+	}
+
+	dependents, err := r.Dependents(ctx, base.ID)
+	if err != nil {
+		t.Fatalf("Dependents failed: %v", err)
+	}
+	if len(dependents) != 1 || !dependents[0].NeedsRedeploy || dependents[0].OutdatedInstances[0] != inst.ID {
+		t.Errorf("expected edge-prod to need a redeploy, got %+v", dependents)
+	}
+
+	if len(resolved) != 2 || len(resolved[1].OutdatedInstances) != 1 {
+		t.Errorf("expected two config.resolved events, got %+v", resolved)
+	}
+}
+
+func TestResolver_CheckLayers(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+	r := NewResolver(s)
+
+	a := createConfig(t, s, "a", "a 1\n")
+	b := createConfig(t, s, "b", "b 1\n")
+	s.SetConfigLayers(ctx, b.ID, []string{a.ID})
+
+	tests := []struct {
+		name   string
+		config string
+		layers []string
+	}{
+		{"self", a.ID, []string{a.ID}},
+		{"duplicate", b.ID, []string{a.ID, a.ID}},
+		{"missing", a.ID, []string{"nonexistent"}},
+		{"cycle", a.ID, []string{b.ID}},
+	}
+	for _, tt := range tests {
+		if err := r.CheckLayers(ctx, tt.config, tt.layers); !errors.Is(err, ErrInvalidLayers) {
+			t.Errorf("%s: expected ErrInvalidLayers, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestResolver_InvalidLayer(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+	r := NewResolver(s)
+
+	base := createConfig(t, s, "base", "server {\n")
+	if _, err := r.Build(ctx, []string{base.ID}); !errors.Is(err, ErrResolve) || !strings.Contains(err.Error(), "base v1") {
+		t.Errorf("expected ErrResolve naming the layer, got %v", err)
+	}
+}
+
+func TestResolver_LayerChanged_SecretReferences(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+	r := NewResolver(s)
+
+	base := createConfig(t, s, "base", "server {\n    listen 8080\n}\n")
+	edge := &store.Config{Name: "edge"}
+	s.CreateConfig(ctx, edge)
+	s.SetConfigLayers(ctx, edge.ID, []string{base.ID})
+	if _, err := r.Resolve(ctx, edge, nil); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+
+	policy := auth.NewPolicy("", []store.Role{{
+		Name: "payments-secrets",
+		Permissions: []store.RolePermission{
+			{Permission: auth.ScopeSecretsRead, ConfigPattern: "payments-*"},
+		},
+	}})
+	policyCtx := context.WithValue(ctx, auth.PolicyContextKey, policy)
+
+	// New references must be usable by whoever changed the layer
+	updateConfig(t, s, base, "server {\n    listen 8080\n    cert secret=\"admin-cert\"\n}\n")
+	if _, err := r.LayerChanged(policyCtx, base.ID, nil); !errors.Is(err, ErrResolve) {
+		t.Fatalf("expected ErrResolve for a secret the caller cannot use, got %v", err)
+	}
+	if ver, _ := s.GetLatestConfigVersion(ctx, edge.ID); ver.Version != 1 {
+		t.Fatalf("expected edge to keep its version, got %d", ver.Version)
+	}
+
+	updateConfig(t, s, base, "server {\n    listen 8080\n    cert secret=\"payments-cert\"\n}\n")
+	created, err := r.LayerChanged(policyCtx, base.ID, nil)
+	if err != nil || len(created) != 1 {
+		t.Fatalf("expected a resolved version, got %+v, %v", created, err)
+	}
+
+	// References already in the config need no permission
+	updateConfig(t, s, base, "server {\n    listen 9090\n    cert secret=\"payments-cert\"\n}\n")
+	noSecrets := context.WithValue(ctx, auth.PolicyContextKey, auth.NewPolicy("", nil))
+	if created, err := r.LayerChanged(noSecrets, base.ID, nil); err != nil || len(created) != 1 {
+		t.Errorf("expected a resolved version, got %+v, %v", created, err)
+	}
+}
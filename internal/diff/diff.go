@@ -0,0 +1,43 @@
+// Package diff compares config contents, both as a unified text diff and
+// structurally, node by node.
+package diff
+
+import (
+	"fmt"
+
+	"github.com/raskell-io/sentinel-hub/internal/kdl"
+)
+
+// ConfigDiff is the difference between two config contents.
+type ConfigDiff struct {
+	Unified string   `json:"unified"`
+	Changes []Change `json:"changes"`
+	// StructuralError explains why there are no structural changes when
+	// either side is not valid KDL.
+	StructuralError string `json:"structural_error,omitempty"`
+}
+
+// Compare diffs two config contents. The names label the sides of the
+// unified diff.
+func Compare(fromName, toName, from, to string) *ConfigDiff {
+	d := &ConfigDiff{
+		Unified: Unified(fromName, toName, from, to),
+		Changes: []Change{},
+	}
+
+	fromNodes, err := kdl.Parse(from)
+	if err != nil {
+		d.StructuralError = fmt.Sprintf("%s: %v", fromName, err)
+		return d
+	}
+	toNodes, err := kdl.Parse(to)
+	if err != nil {
+		d.StructuralError = fmt.Sprintf("%s: %v", toName, err)
+		return d
+	}
+
+	if changes := Structural(fromNodes, toNodes); changes != nil {
+		d.Changes = changes
+	}
+	return d
+}
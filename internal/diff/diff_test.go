@@ -0,0 +1,117 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	from := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	to := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+
+	want := `--- v1
++++ v2
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -9,3 +9,4 @@
+ i
+ j
+ k
++l
+`
+	if got := Unified("v1", "v2", from, to); got != want {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUnified_Edges(t *testing.T) {
+	if got := Unified("a", "b", "same\n", "same\n"); got != "" {
+		t.Errorf("expected no diff for equal texts, got:\n%s", got)
+	}
+	if got := Unified("a", "b", "", ""); got != "" {
+		t.Errorf("expected no diff for empty texts, got:\n%s", got)
+	}
+
+	want := "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+x\n+y\n"
+	if got := Unified("a", "b", "", "x\ny\n"); got != want {
+		t.Errorf("unexpected diff from empty:\n%s\nwant:\n%s", got, want)
+	}
+	want = "--- a\n+++ b\n@@ -1 +0,0 @@\n-x\n"
+	if got := Unified("a", "b", "x", ""); got != want {
+		t.Errorf("unexpected diff to empty:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCompare_Structural(t *testing.T) {
+	from := `
+server {
+    listen 8080
+}
+upstream "api" weight=1 {
+    target "10.0.0.1:80"
+}
+upstream "web" {
+    target "10.0.0.2:80"
+}
+`
+	to := `
+server {
+    listen 9090
+    workers 4
+}
+upstream "api" weight=2 timeout=30 {
+    target "10.0.0.1:80"
+}
+upstream "admin" {
+    target "10.0.0.3:80"
+}
+`
+	d := Compare("v1", "v2", from, to)
+	if d.StructuralError != "" {
+		t.Fatalf("unexpected structural error: %s", d.StructuralError)
+	}
+
+	got := make(map[string]Change)
+	for _, c := range d.Changes {
+		got[string(c.Type)+" "+c.Path+" "+c.Property] = c
+	}
+	want := map[string]Change{
+		"changed server > listen ":      {From: "8080", To: "9090"},
+		"added server > workers ":       {To: "workers 4"},
+		`changed upstream "api" weight`: {From: "1", To: "2"},
+		`added upstream "api" timeout`:  {To: "30"},
+		`removed upstream "web" `:       {},
+		`added upstream "admin" `:       {},
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected %d changes, got %+v", len(want), d.Changes)
+	}
+	for key, w := range want {
+		c, ok := got[key]
+		if !ok {
+			t.Errorf("missing change %q in %+v", key, d.Changes)
+			continue
+		}
+		if w.From != "" && c.From != w.From || w.To != "" && c.To != w.To {
+			t.Errorf("%s: got from %q to %q, want from %q to %q", key, c.From, c.To, w.From, w.To)
+		}
+	}
+	if !strings.Contains(d.Unified, "-    listen 8080\n+    listen 9090\n") {
+		t.Errorf("unexpected unified diff:\n%s", d.Unified)
+	}
+}
+
+func TestCompare_InvalidKDL(t *testing.T) {
+	d := Compare("v1", "v2", "server {\n", "server {}\n")
+	if d.StructuralError == "" || !strings.HasPrefix(d.StructuralError, "v1: ") {
+		t.Errorf("expected structural error for v1, got %q", d.StructuralError)
+	}
+	if d.Unified == "" {
+		t.Error("expected a unified diff despite invalid KDL")
+	}
+}
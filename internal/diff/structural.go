@@ -0,0 +1,125 @@
+package diff
+
+import (
+	"strings"
+
+	"github.com/raskell-io/sentinel-hub/internal/kdl"
+)
+
+// ChangeType is the kind of a structural change.
+type ChangeType string
+
+// Change types.
+const (
+	Added   ChangeType = "added"
+	Removed ChangeType = "removed"
+	Changed ChangeType = "changed"
+)
+
+// Change is a node-level difference between two KDL documents.
+type Change struct {
+	Type ChangeType `json:"type"`
+	// Path locates the node, e.g. `upstream "api" > target`.
+	Path string `json:"path"`
+	// Property is set for changes to a single property. Changes without
+	// one concern the whole node (added or removed) or its arguments.
+	Property string `json:"property,omitempty"`
+	From     string `json:"from,omitempty"`
+	To       string `json:"to,omitempty"`
+}
+
+// Structural compares two parsed KDL documents node by node.
+//
+// Sibling nodes are matched as described by kdl.SiblingKeys.
+func Structural(from, to []*kdl.Node) []Change {
+	var changes []Change
+	compareNodes("", from, to, &changes)
+	return changes
+}
+
+func compareNodes(parent string, from, to []*kdl.Node, changes *[]Change) {
+	fromKeys, toKeys := kdl.SiblingKeys(from, to)
+
+	toIndex := make(map[string]int, len(to))
+	for i, key := range toKeys {
+		toIndex[key] = i
+	}
+	matched := make(map[string]bool, len(from))
+
+	for i, n := range from {
+		key := fromKeys[i]
+		path := joinPath(parent, key)
+		j, ok := toIndex[key]
+		if !ok {
+			*changes = append(*changes, Change{Type: Removed, Path: path, From: n.String()})
+			continue
+		}
+		matched[key] = true
+		compareNode(path, n, to[j], changes)
+	}
+
+	for j, n := range to {
+		if !matched[toKeys[j]] {
+			*changes = append(*changes, Change{Type: Added, Path: joinPath(parent, toKeys[j]), To: n.String()})
+		}
+	}
+}
+
+func compareNode(path string, from, to *kdl.Node, changes *[]Change) {
+	if from.Type != to.Type || !equalValues(from.Args, to.Args) {
+		*changes = append(*changes, Change{
+			Type: Changed,
+			Path: path,
+			From: formatArgs(from),
+			To:   formatArgs(to),
+		})
+	}
+
+	for _, p := range from.Props {
+		v, ok := to.Prop(p.Name)
+		switch {
+		case !ok:
+			*changes = append(*changes, Change{Type: Removed, Path: path, Property: p.Name, From: p.Value.String()})
+		case !p.Value.Equal(v):
+			*changes = append(*changes, Change{Type: Changed, Path: path, Property: p.Name, From: p.Value.String(), To: v.String()})
+		}
+	}
+	for _, p := range to.Props {
+		if _, ok := from.Prop(p.Name); !ok {
+			*changes = append(*changes, Change{Type: Added, Path: path, Property: p.Name, To: p.Value.String()})
+		}
+	}
+
+	compareNodes(path, from.Children, to.Children, changes)
+}
+
+func joinPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + " > " + key
+}
+
+func equalValues(a, b []kdl.Value) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatArgs renders a node's type annotation and arguments.
+func formatArgs(n *kdl.Node) string {
+	parts := make([]string, 0, len(n.Args)+1)
+	if n.Type != "" {
+		parts = append(parts, "("+n.Type+")")
+	}
+	for _, v := range n.Args {
+		parts = append(parts, v.String())
+	}
+	return strings.Join(parts, " ")
+}
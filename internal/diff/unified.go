@@ -0,0 +1,185 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is how many unchanged lines surround each hunk.
+const contextLines = 3
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+// op is one step of an edit script: keeping, deleting or inserting a line.
+type op struct {
+	kind opKind
+	a, b int // Line indexes in a and b before this op
+}
+
+// Unified returns a unified diff of two texts, or "" if they are equal.
+func Unified(fromName, toName, from, to string) string {
+	a, b := splitLines(from), splitLines(to)
+	ops := editScript(a, b)
+
+	var out strings.Builder
+	for _, h := range hunks(ops) {
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		first, last := ops[h[0]], ops[h[1]-1]
+		aEnd, bEnd := last.a, last.b
+		switch last.kind {
+		case opEqual:
+			aEnd, bEnd = aEnd+1, bEnd+1
+		case opDelete:
+			aEnd++
+		case opInsert:
+			bEnd++
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(first.a, aEnd-first.a), hunkRange(first.b, bEnd-first.b))
+
+		for _, o := range ops[h[0]:h[1]] {
+			switch o.kind {
+			case opEqual:
+				out.WriteString(" " + a[o.a] + "\n")
+			case opDelete:
+				out.WriteString("-" + a[o.a] + "\n")
+			case opInsert:
+				out.WriteString("+" + b[o.b] + "\n")
+			}
+		}
+	}
+	return out.String()
+}
+
+// hunkRange formats a hunk's start line and length the way diff -u does.
+func hunkRange(start, length int) string {
+	switch length {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+// splitLines splits text into lines without their line endings.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
+
+// hunks groups changes with their surrounding context and returns each
+// hunk as a half-open range of ops.
+func hunks(ops []op) [][2]int {
+	var result [][2]int
+	for i := 0; i < len(ops); i++ {
+		if ops[i].kind == opEqual {
+			continue
+		}
+
+		start := max(i-contextLines, 0)
+		// Extend past this change until a run of unchanged lines is long
+		// enough to separate it from the next one
+		end, equal := i, 0
+		for end < len(ops) && equal <= 2*contextLines {
+			if ops[end].kind == opEqual {
+				equal++
+			} else {
+				equal = 0
+			}
+			end++
+		}
+		end -= max(equal-contextLines, 0)
+
+		if n := len(result); n > 0 && start <= result[n-1][1] {
+			result[n-1][1] = end
+		} else {
+			result = append(result, [2]int{start, end})
+		}
+		i = end - 1
+	}
+	return result
+}
+
+// editScript returns the shortest edit script turning a into b, using
+// Myers' O(ND) algorithm.
+func editScript(a, b []string) []op {
+	n, m := len(a), len(b)
+	limit := n + m
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, n, m, offset)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack walks the saved Myers frontiers back from the end to build the
+// edit script.
+func backtrack(trace [][]int, n, m, offset int) []op {
+	var ops []op
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, op{kind: opEqual, a: x, b: y})
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, op{kind: opInsert, a: x, b: prevY})
+			} else {
+				ops = append(ops, op{kind: opDelete, a: prevX, b: y})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
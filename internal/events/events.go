@@ -0,0 +1,208 @@
+// Package events publishes deployment and fleet events from the
+// orchestrator and fleet service to subscribers such as outgoing webhooks
+// and live event streams.
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Type identifies the kind of an event.
+type Type string
+
+// Event types.
+const (
+	DeploymentStarted    Type = "deployment.started"
+	DeploymentCompleted  Type = "deployment.completed"
+	DeploymentFailed     Type = "deployment.failed"
+	DeploymentCancelled  Type = "deployment.cancelled"
+	DeploymentRolledBack Type = "deployment.rolled_back"
+	InstanceOnline       Type = "instance.online"
+	InstanceOffline      Type = "instance.offline"
+	InstanceDegraded     Type = "instance.degraded"
+	ConfigResolved       Type = "config.resolved"
+	AssignmentConflict   Type = "assignment.conflict"
+	ConfigDrifted        Type = "config.drifted"
+
+	// Progress events are frequent and only sent to live event streams.
+	DeploymentInstanceStatus Type = "deployment.instance_status"
+	DeploymentProgress       Type = "deployment.progress"
+)
+
+// Types returns the event types webhooks can subscribe to.
+func Types() []Type {
+	return []Type{
+		DeploymentStarted, DeploymentCompleted, DeploymentFailed, DeploymentCancelled, DeploymentRolledBack,
+		InstanceOnline, InstanceOffline, InstanceDegraded,
+		ConfigResolved, AssignmentConflict, ConfigDrifted,
+	}
+}
+
+// Valid reports whether t is an event type webhooks can subscribe to.
+func (t Type) Valid() bool {
+	for _, known := range Types() {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is something that happened in the fleet.
+type Event struct {
+	ID        string      `json:"id"`
+	Type      Type        `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// DeploymentData is the data of deployment events.
+type DeploymentData struct {
+	DeploymentID    string `json:"deployment_id"`
+	ConfigID        string `json:"config_id"`
+	ConfigVersion   int    `json:"config_version"`
+	Strategy        string `json:"strategy"`
+	Status          string `json:"status"`
+	TargetInstances int    `json:"target_instances"`
+	// Reason explains failures, cancellations and rollbacks when known.
+	Reason string `json:"reason,omitempty"`
+}
+
+// InstanceData is the data of instance events.
+type InstanceData struct {
+	InstanceID     string            `json:"instance_id"`
+	Name           string            `json:"name,omitempty"`
+	Hostname       string            `json:"hostname,omitempty"`
+	Status         string            `json:"status"`
+	PreviousStatus string            `json:"previous_status,omitempty"`
+	Reason         string            `json:"reason,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+}
+
+// ConfigResolvedData is the data of config.resolved events, sent when a
+// change to a layer produces a new version of a composed config.
+type ConfigResolvedData struct {
+	ConfigID      string      `json:"config_id"`
+	ConfigName    string      `json:"config_name"`
+	ConfigVersion int         `json:"config_version"`
+	Layers        []LayerData `json:"layers"`
+	// OutdatedInstances run an older version and need a redeploy.
+	OutdatedInstances []string `json:"outdated_instances"`
+}
+
+// LayerData is a layer version a composed config was resolved from.
+type LayerData struct {
+	ConfigID string `json:"config_id"`
+	Version  int    `json:"version"`
+}
+
+// AssignmentConflictData is the data of assignment.conflict events, sent
+// when an instance registers without a config and the assignments
+// matching it disagree.
+type AssignmentConflictData struct {
+	InstanceID    string            `json:"instance_id"`
+	Name          string            `json:"name,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	AssignmentIDs []string          `json:"assignment_ids"`
+}
+
+// ConfigDriftedData is the data of config.drifted events, sent when an
+// instance starts running local changes to its desired config version, and
+// when those changes cannot be adopted. Reason says why not.
+type ConfigDriftedData struct {
+	InstanceID    string            `json:"instance_id"`
+	Name          string            `json:"name,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	ConfigID      string            `json:"config_id"`
+	ConfigVersion int               `json:"config_version"`
+	ExpectedHash  string            `json:"expected_hash"`
+	ReportedHash  string            `json:"reported_hash"`
+	Action        string            `json:"action"`
+	Reason        string            `json:"reason,omitempty"`
+}
+
+// DeploymentInstanceData is the data of deployment.instance_status events,
+// sent when an instance's state within a deployment changes.
+type DeploymentInstanceData struct {
+	DeploymentID  string            `json:"deployment_id"`
+	InstanceID    string            `json:"instance_id"`
+	State         string            `json:"state"`
+	PreviousState string            `json:"previous_state,omitempty"`
+	Message       string            `json:"message,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+}
+
+// DeploymentProgressData is the data of deployment.progress events.
+type DeploymentProgressData struct {
+	DeploymentID       string `json:"deployment_id"`
+	TotalInstances     int    `json:"total_instances"`
+	CompletedInstances int    `json:"completed_instances"`
+	FailedInstances    int    `json:"failed_instances"`
+	CurrentBatch       int    `json:"current_batch"`
+	TotalBatches       int    `json:"total_batches"`
+}
+
+// DeploymentID returns the deployment an event is about, or "" for events
+// that are not about a deployment.
+func (e Event) DeploymentID() string {
+	switch data := e.Data.(type) {
+	case DeploymentData:
+		return data.DeploymentID
+	case DeploymentInstanceData:
+		return data.DeploymentID
+	case DeploymentProgressData:
+		return data.DeploymentID
+	}
+	return ""
+}
+
+// Handler receives published events. Handlers run on the publisher's
+// goroutine and should not block.
+type Handler func(ctx context.Context, e Event)
+
+// Bus fans events out to its subscribers. A nil *Bus discards events, so
+// publishers work without one.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+// NewBus creates an event bus without subscribers.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers a handler for every subsequent event.
+func (b *Bus) Subscribe(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+// Publish stamps an event with an ID and time and hands it to every
+// subscriber.
+func (b *Bus) Publish(ctx context.Context, t Type, data interface{}) {
+	if b == nil {
+		return
+	}
+
+	e := Event{
+		ID:        uuid.New().String(),
+		Type:      t,
+		Timestamp: time.Now().UTC(),
+		Data:      data,
+	}
+
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(ctx, e)
+	}
+}
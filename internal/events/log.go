@@ -0,0 +1,136 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// DefaultLogSize is how many events a Log keeps by default.
+const DefaultLogSize = 1000
+
+// subscriberBuffer is how many events a subscriber may fall behind before it
+// is dropped.
+const subscriberBuffer = 64
+
+// Entry is an event in a Log.
+type Entry struct {
+	// ID identifies the entry across reconnects. It combines the log's
+	// epoch with a sequence number, so IDs from before a hub restart are
+	// recognised as unknown instead of being compared with new ones.
+	ID    string
+	Event Event
+
+	seq uint64
+}
+
+// Log keeps the most recent events in memory and fans them out to live
+// subscribers, so that event streams can resume from a Last-Event-ID.
+type Log struct {
+	mu          sync.Mutex
+	epoch       string
+	entries     []Entry // ring buffer
+	start       int     // index of the oldest entry
+	count       int
+	seq         uint64
+	subscribers map[chan Entry]struct{}
+}
+
+// NewLog creates a log that keeps the last size events.
+func NewLog(size int) *Log {
+	if size <= 0 {
+		size = DefaultLogSize
+	}
+	return &Log{
+		epoch:       uuid.New().String()[:8],
+		entries:     make([]Entry, size),
+		subscribers: make(map[chan Entry]struct{}),
+	}
+}
+
+// Handle appends an event and sends it to every subscriber. Subscribers
+// that have fallen too far behind are dropped; their channel is closed and
+// they can resume from the last entry they received. It implements Handler.
+func (l *Log) Handle(_ context.Context, e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	entry := Entry{ID: fmt.Sprintf("%s-%d", l.epoch, l.seq), Event: e, seq: l.seq}
+
+	if l.count < len(l.entries) {
+		l.entries[(l.start+l.count)%len(l.entries)] = entry
+		l.count++
+	} else {
+		l.entries[l.start] = entry
+		l.start = (l.start + 1) % len(l.entries)
+	}
+
+	for ch := range l.subscribers {
+		select {
+		case ch <- entry:
+		default:
+			delete(l.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns the retained entries after lastID and a channel of the
+// entries that follow. complete is false if entries after lastID may have
+// been missed: lastID is unknown, from before a restart, or older than the
+// log retains. An empty lastID subscribes to new entries only. cancel must
+// be called to release the subscription.
+func (l *Log) Subscribe(lastID string) (backlog []Entry, live <-chan Entry, complete bool, cancel func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	complete = true
+	if lastID != "" {
+		seq, ok := l.parseID(lastID)
+		oldest := l.seq - uint64(l.count) // Sequence just before the oldest entry
+		if !ok || seq < oldest || seq > l.seq {
+			complete = false
+			seq = oldest
+		}
+		for i := 0; i < l.count; i++ {
+			entry := l.entries[(l.start+i)%len(l.entries)]
+			if entry.seq > seq {
+				backlog = append(backlog, entry)
+			}
+		}
+	}
+
+	ch := make(chan Entry, subscriberBuffer)
+	l.subscribers[ch] = struct{}{}
+
+	var once sync.Once
+	cancel = func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if _, ok := l.subscribers[ch]; ok {
+				delete(l.subscribers, ch)
+				close(ch)
+			}
+		})
+	}
+	return backlog, ch, complete, cancel
+}
+
+// parseID returns the sequence number of an entry ID from this log's epoch.
+func (l *Log) parseID(id string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(id, "-")
+	if !ok || epoch != l.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,90 @@
+package events
+
+import (
+	"context"
+	"testing"
+)
+
+func publishN(l *Log, n int) {
+	bus := NewBus()
+	bus.Subscribe(l.Handle)
+	for i := 0; i < n; i++ {
+		bus.Publish(context.Background(), InstanceOnline, InstanceData{InstanceID: "inst-1"})
+	}
+}
+
+func TestLog_SubscribeResumesAfterLastID(t *testing.T) {
+	l := NewLog(10)
+	_, live, _, cancel := l.Subscribe("")
+	defer cancel()
+
+	publishN(l, 3)
+	first := <-live
+
+	backlog, _, complete, cancelResume := l.Subscribe(first.ID)
+	defer cancelResume()
+
+	if !complete {
+		t.Error("expected a complete resume")
+	}
+	if len(backlog) != 2 {
+		t.Fatalf("expected 2 entries after %s, got %d", first.ID, len(backlog))
+	}
+	if backlog[0].ID == first.ID {
+		t.Error("expected the Last-Event-ID entry itself to be skipped")
+	}
+}
+
+func TestLog_SubscribeAfterEviction(t *testing.T) {
+	l := NewLog(2)
+	_, live, _, cancel := l.Subscribe("")
+	defer cancel()
+
+	publishN(l, 5)
+	first := <-live
+
+	backlog, _, complete, cancelResume := l.Subscribe(first.ID)
+	defer cancelResume()
+
+	if complete {
+		t.Error("expected an incomplete resume once the entry was evicted")
+	}
+	if len(backlog) != 2 {
+		t.Errorf("expected the 2 retained entries, got %d", len(backlog))
+	}
+}
+
+func TestLog_SubscribeUnknownID(t *testing.T) {
+	l := NewLog(10)
+	publishN(l, 1)
+
+	for _, id := range []string{"garbage", "deadbeef-1", "0-0"} {
+		backlog, _, complete, cancel := l.Subscribe(id)
+		cancel()
+		if complete || len(backlog) != 1 {
+			t.Errorf("Subscribe(%q): expected incomplete resume with 1 entry, got complete=%v, %d entries", id, complete, len(backlog))
+		}
+	}
+
+	backlog, _, complete, cancel := l.Subscribe("")
+	defer cancel()
+	if !complete || len(backlog) != 0 {
+		t.Errorf("expected a fresh subscription to start empty and complete")
+	}
+}
+
+func TestLog_DropsSlowSubscriber(t *testing.T) {
+	l := NewLog(10)
+	_, live, _, cancel := l.Subscribe("")
+	defer cancel()
+
+	publishN(l, subscriberBuffer+1)
+
+	n := 0
+	for range live {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("expected %d buffered entries before the channel closed, got %d", subscriberBuffer, n)
+	}
+}
@@ -18,7 +18,7 @@ import (
 
 // testEnv holds the test environment for integration tests.
 type testEnv struct {
-	store        *store.Store
+	store        store.Store
 	fleetService *hubgrpc.FleetService
 	orchestrator *Orchestrator
 	cleanup      func()
@@ -48,8 +48,8 @@ func setupIntegrationTest(t *testing.T) *testEnv {
 	o := NewOrchestrator(s, fs)
 
 	// Set up deployment status handler
-	fs.SetDeploymentStatusHandler(func(instanceID, deploymentID string, state pb.DeploymentState, message, errorDetails string) {
-		o.ReportInstanceStatus(instanceID, deploymentID, state, message, errorDetails)
+	fs.SetDeploymentStatusHandler(func(ctx context.Context, instanceID, deploymentID string, state pb.DeploymentState, message, errorDetails string) {
+		o.ReportInstanceStatus(ctx, instanceID, deploymentID, state, message, errorDetails)
 	})
 
 	env := &testEnv{
@@ -194,6 +194,11 @@ func TestIntegration_CreateDeployment_SingleInstance(t *testing.T) {
 			continue
 		}
 		if status.Deployment.Status == store.DeploymentStatusCompleted {
+			// The deployed version is approved for assignments
+			approved, _ := env.store.GetLatestApprovedConfigVersion(ctx, cfg.ID)
+			if approved == nil || approved.Version != dep.ConfigVersion {
+				t.Errorf("expected version %d to be approved, got %+v", dep.ConfigVersion, approved)
+			}
 			return // Success
 		}
 		if status.Deployment.Status == store.DeploymentStatusFailed {
@@ -2,11 +2,14 @@ package fleet
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/raskell-io/sentinel-hub/internal/audit"
+	"github.com/raskell-io/sentinel-hub/internal/events"
 	hubgrpc "github.com/raskell-io/sentinel-hub/internal/grpc"
 	"github.com/raskell-io/sentinel-hub/internal/store"
 	pb "github.com/raskell-io/sentinel-hub/pkg/hubpb"
@@ -15,8 +18,10 @@ import (
 
 // Orchestrator manages deployment operations across the fleet.
 type Orchestrator struct {
-	store        *store.Store
+	store        store.Store
 	fleetService *hubgrpc.FleetService
+	audit        *audit.Recorder
+	events       *events.Bus
 
 	// Active deployments
 	deployments   map[string]*DeploymentRunner
@@ -34,12 +39,13 @@ type Orchestrator struct {
 }
 
 // NewOrchestrator creates a new deployment orchestrator.
-func NewOrchestrator(s *store.Store, fs *hubgrpc.FleetService) *Orchestrator {
+func NewOrchestrator(s store.Store, fs *hubgrpc.FleetService) *Orchestrator {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &Orchestrator{
 		store:              s,
 		fleetService:       fs,
+		audit:              audit.NewRecorder(s, audit.RecorderConfig{}),
 		deployments:        make(map[string]*DeploymentRunner),
 		defaultTimeout:     10 * time.Minute,
 		healthCheckRetries: 3,
@@ -49,6 +55,16 @@ func NewOrchestrator(s *store.Store, fs *hubgrpc.FleetService) *Orchestrator {
 	}
 }
 
+// SetAuditRecorder sets the recorder audit logs are written through.
+func (o *Orchestrator) SetAuditRecorder(r *audit.Recorder) {
+	o.audit = r
+}
+
+// SetEventBus sets the bus deployment events are published on.
+func (o *Orchestrator) SetEventBus(b *events.Bus) {
+	o.events = b
+}
+
 // Start starts the orchestrator background processes.
 func (o *Orchestrator) Start() error {
 	log.Info().Msg("Starting deployment orchestrator")
@@ -58,13 +74,6 @@ func (o *Orchestrator) Start() error {
 		log.Error().Err(err).Msg("Failed to recover orphaned deployments")
 	}
 
-	// Start cleanup routine
-	o.wg.Add(1)
-	go func() {
-		defer o.wg.Done()
-		o.cleanupRoutine()
-	}()
-
 	return nil
 }
 
@@ -104,38 +113,11 @@ func (o *Orchestrator) CreateDeployment(ctx context.Context, req CreateDeploymen
 		Int("target_count", len(req.TargetInstances)).
 		Msg("Creating deployment")
 
-	// Validate config exists
-	cfg, err := o.store.GetConfig(ctx, req.ConfigID)
+	_, ver, targetIDs, err := o.prepareDeployment(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get config: %w", err)
-	}
-	if cfg == nil {
-		return nil, fmt.Errorf("config not found: %s", req.ConfigID)
-	}
-
-	// Use current version if not specified
-	configVersion := req.ConfigVersion
-	if configVersion == 0 {
-		configVersion = cfg.CurrentVersion
-	}
-
-	// Validate config version exists
-	ver, err := o.store.GetConfigVersion(ctx, req.ConfigID, configVersion)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get config version: %w", err)
-	}
-	if ver == nil {
-		return nil, fmt.Errorf("config version %d not found", configVersion)
-	}
-
-	// Resolve target instances
-	targetIDs, err := o.resolveTargets(ctx, req.TargetInstances, req.TargetLabels)
-	if err != nil {
-		return nil, fmt.Errorf("failed to resolve targets: %w", err)
-	}
-	if len(targetIDs) == 0 {
-		return nil, fmt.Errorf("no target instances found")
+		return nil, err
 	}
+	configVersion := ver.Version
 
 	// Set defaults
 	strategy := req.Strategy
@@ -181,6 +163,52 @@ func (o *Orchestrator) CreateDeployment(ctx context.Context, req CreateDeploymen
 	return dep, nil
 }
 
+// prepareDeployment validates a deployment request and resolves its config
+// version and target instances.
+func (o *Orchestrator) prepareDeployment(ctx context.Context, req CreateDeploymentRequest) (*store.Config, *store.ConfigVersion, []string, error) {
+	// Validate config exists
+	cfg, err := o.store.GetConfig(ctx, req.ConfigID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get config: %w", err)
+	}
+	if cfg == nil {
+		return nil, nil, nil, fmt.Errorf("config not found: %s", req.ConfigID)
+	}
+
+	// Use current version if not specified
+	configVersion := req.ConfigVersion
+	if configVersion == 0 {
+		configVersion = cfg.CurrentVersion
+	}
+
+	// Validate config version exists
+	ver, err := o.store.GetConfigVersion(ctx, req.ConfigID, configVersion)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get config version: %w", err)
+	}
+	if ver == nil {
+		return nil, nil, nil, fmt.Errorf("config version %d not found", configVersion)
+	}
+
+	// Resolve target instances
+	targetIDs, err := o.resolveTargets(ctx, req.TargetInstances, req.TargetLabels)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to resolve targets: %w", err)
+	}
+	if len(targetIDs) == 0 {
+		return nil, nil, nil, fmt.Errorf("no target instances found")
+	}
+
+	// Check the caller may deploy this config to every target
+	if req.Authorize != nil {
+		if err := o.authorizeTargets(ctx, targetIDs, cfg, req.Authorize); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	return cfg, ver, targetIDs, nil
+}
+
 // CreateDeploymentRequest holds parameters for creating a deployment.
 type CreateDeploymentRequest struct {
 	ConfigID        string
@@ -190,8 +218,14 @@ type CreateDeploymentRequest struct {
 	Strategy        store.DeploymentStrategy
 	BatchSize       int
 	CreatedBy       *string
+
+	// Authorize, if set, must approve every resolved target instance.
+	Authorize func(inst *store.Instance, cfg *store.Config) bool
 }
 
+// ErrTargetNotPermitted is returned when the caller may not deploy to a target instance.
+var ErrTargetNotPermitted = errors.New("not permitted to deploy to target instance")
+
 // CancelDeployment cancels an in-progress deployment.
 func (o *Orchestrator) CancelDeployment(ctx context.Context, deploymentID string) error {
 	o.deploymentsMu.RLock()
@@ -218,6 +252,7 @@ func (o *Orchestrator) CancelDeployment(ctx context.Context, deploymentID string
 		if err := o.store.UpdateDeployment(ctx, dep); err != nil {
 			return fmt.Errorf("failed to update deployment: %w", err)
 		}
+		o.events.Publish(ctx, events.DeploymentCancelled, deploymentEventData(dep))
 	}
 
 	log.Info().Str("deployment_id", deploymentID).Msg("Deployment cancelled")
@@ -323,6 +358,23 @@ func (o *Orchestrator) resolveTargets(ctx context.Context, instanceIDs []string,
 	return result, nil
 }
 
+// authorizeTargets checks every target instance against the authorizer.
+func (o *Orchestrator) authorizeTargets(ctx context.Context, targetIDs []string, cfg *store.Config, authorize func(*store.Instance, *store.Config) bool) error {
+	for _, id := range targetIDs {
+		inst, err := o.store.GetInstance(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to get instance %s: %w", id, err)
+		}
+		if inst == nil {
+			return fmt.Errorf("instance not found: %s", id)
+		}
+		if !authorize(inst, cfg) {
+			return fmt.Errorf("%w: %s", ErrTargetNotPermitted, inst.Name)
+		}
+	}
+	return nil
+}
+
 // matchLabels checks if instance labels match the selector.
 func matchLabels(instanceLabels, selector map[string]string) bool {
 	if len(selector) == 0 {
@@ -360,6 +412,7 @@ func (o *Orchestrator) runDeployment(deploymentID string) {
 		ConfigVersion:      ver,
 		Store:              o.store,
 		FleetService:       o.fleetService,
+		Events:             o.events,
 		Timeout:            o.defaultTimeout,
 		HealthCheckRetries: o.healthCheckRetries,
 		HealthCheckDelay:   o.healthCheckDelay,
@@ -387,9 +440,15 @@ func (o *Orchestrator) failDeployment(ctx context.Context, dep *store.Deployment
 	now := time.Now().UTC()
 	dep.Status = store.DeploymentStatusFailed
 	dep.CompletedAt = &now
+	if dep.Progress == nil {
+		dep.Progress = &store.DeploymentProgress{}
+	}
+	dep.Progress.FailureReason = reason
 	if err := o.store.UpdateDeployment(ctx, dep); err != nil {
 		log.Error().Err(err).Str("deployment_id", dep.ID).Msg("Failed to update deployment status")
+		return
 	}
+	o.events.Publish(ctx, events.DeploymentFailed, deploymentEventData(dep))
 }
 
 // RecoverOrphanedDeployments marks any orphaned deployments as failed.
@@ -432,6 +491,7 @@ func (o *Orchestrator) RecoverOrphanedDeployments(ctx context.Context) error {
 					Msg("Failed to update orphaned deployment")
 				continue
 			}
+			o.events.Publish(ctx, events.DeploymentFailed, deploymentEventData(&dep))
 
 			// Mark all pending/in-progress instances as failed
 			instances, err := o.store.ListDeploymentInstances(ctx, dep.ID)
@@ -472,24 +532,10 @@ func (o *Orchestrator) RecoverOrphanedDeployments(ctx context.Context) error {
 	return nil
 }
 
-// cleanupRoutine periodically cleans up old deployments.
-func (o *Orchestrator) cleanupRoutine() {
-	ticker := time.NewTicker(1 * time.Hour)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-o.ctx.Done():
-			return
-		case <-ticker.C:
-			// Could clean up old deployment records here
-			log.Debug().Msg("Deployment cleanup tick")
-		}
-	}
-}
-
-// ReportInstanceStatus handles status reports from agents.
-func (o *Orchestrator) ReportInstanceStatus(instanceID, deploymentID string, state pb.DeploymentState, message, errorDetails string) {
+// ReportInstanceStatus handles status reports from agents. Reports that
+// change an instance's state are recorded in the audit log as actions of
+// the reporting agent; repeated reports only renew the lease.
+func (o *Orchestrator) ReportInstanceStatus(ctx context.Context, instanceID, deploymentID string, state pb.DeploymentState, message, errorDetails string) {
 	o.deploymentsMu.RLock()
 	runner, exists := o.deployments[deploymentID]
 	o.deploymentsMu.RUnlock()
@@ -502,5 +548,28 @@ func (o *Orchestrator) ReportInstanceStatus(instanceID, deploymentID string, sta
 		return
 	}
 
+	previous, known := runner.instanceState(instanceID)
 	runner.ReportInstanceStatus(instanceID, state, message, errorDetails)
+	if !known || previous == state {
+		return
+	}
+
+	details := map[string]string{
+		"instance_id":    instanceID,
+		"previous_state": previous.String(),
+		"state":          state.String(),
+	}
+	if message != "" {
+		details["message"] = message
+	}
+	if errorDetails != "" {
+		details["error"] = errorDetails
+	}
+	entry := hubgrpc.AgentAuditLog(ctx, instanceID, "instance_status", "deployment", deploymentID, details)
+	if err := o.audit.Record(ctx, entry); err != nil {
+		log.Warn().Err(err).
+			Str("deployment_id", deploymentID).
+			Str("instance_id", instanceID).
+			Msg("Failed to create audit log")
+	}
 }
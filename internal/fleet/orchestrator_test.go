@@ -2,15 +2,19 @@ package fleet
 
 import (
 	"context"
+	"errors"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/raskell-io/sentinel-hub/internal/render"
 	"github.com/raskell-io/sentinel-hub/internal/store"
+	pb "github.com/raskell-io/sentinel-hub/pkg/hubpb"
 )
 
 // setupTestStore creates a temporary SQLite store for testing.
-func setupTestStore(t *testing.T) *store.Store {
+func setupTestStore(t *testing.T) store.Store {
 	t.Helper()
 
 	// Create a temp file for the test database
@@ -36,7 +40,7 @@ func setupTestStore(t *testing.T) *store.Store {
 }
 
 // createTestConfig creates a config with a version for testing.
-func createTestConfig(t *testing.T, s *store.Store, name, content string) (*store.Config, *store.ConfigVersion) {
+func createTestConfig(t *testing.T, s store.Store, name, content string) (*store.Config, *store.ConfigVersion) {
 	t.Helper()
 	ctx := context.Background()
 
@@ -61,7 +65,7 @@ func createTestConfig(t *testing.T, s *store.Store, name, content string) (*stor
 }
 
 // createTestInstance creates an instance for testing.
-func createTestInstance(t *testing.T, s *store.Store, name string, labels map[string]string) *store.Instance {
+func createTestInstance(t *testing.T, s store.Store, name string, labels map[string]string) *store.Instance {
 	t.Helper()
 	ctx := context.Background()
 
@@ -259,6 +263,48 @@ func TestOrchestrator_CreateDeployment_NoTargets(t *testing.T) {
 	}
 }
 
+func TestOrchestrator_CreateDeployment_TargetNotPermitted(t *testing.T) {
+	s := setupTestStore(t)
+	o := NewOrchestrator(s, nil)
+	t.Cleanup(func() { o.Stop() })
+
+	ctx := context.Background()
+	cfg, _ := createTestConfig(t, s, "test-config", "content")
+	createTestInstance(t, s, "payments-1", map[string]string{"team": "payments", "env": "prod"})
+	createTestInstance(t, s, "search-1", map[string]string{"team": "search", "env": "prod"})
+
+	onlyPayments := func(inst *store.Instance, _ *store.Config) bool {
+		return inst.Labels["team"] == "payments"
+	}
+
+	// A selector that reaches another team's instance is rejected outright
+	_, err := o.CreateDeployment(ctx, CreateDeploymentRequest{
+		ConfigID:     cfg.ID,
+		TargetLabels: map[string]string{"env": "prod"},
+		Authorize:    onlyPayments,
+	})
+	if !errors.Is(err, ErrTargetNotPermitted) {
+		t.Fatalf("error = %v, want %v", err, ErrTargetNotPermitted)
+	}
+
+	deps, _ := s.ListDeployments(ctx, store.ListDeploymentsOptions{})
+	if len(deps) != 0 {
+		t.Errorf("no deployment should be created, got %d", len(deps))
+	}
+
+	dep, err := o.CreateDeployment(ctx, CreateDeploymentRequest{
+		ConfigID:     cfg.ID,
+		TargetLabels: map[string]string{"team": "payments"},
+		Authorize:    onlyPayments,
+	})
+	if err != nil {
+		t.Fatalf("CreateDeployment failed: %v", err)
+	}
+	if len(dep.TargetInstances) != 1 {
+		t.Errorf("TargetInstances = %d, want 1", len(dep.TargetInstances))
+	}
+}
+
 func TestOrchestrator_CreateDeployment_DefaultsApplied(t *testing.T) {
 	s := setupTestStore(t)
 	o := NewOrchestrator(s, nil)
@@ -583,6 +629,40 @@ func TestOrchestrator_CancelDeployment_AlreadyCompleted(t *testing.T) {
 	}
 }
 
+func TestOrchestrator_ReportInstanceStatus_AuditsStateChanges(t *testing.T) {
+	s := setupTestStore(t)
+	o := NewOrchestrator(s, nil)
+	ctx := context.Background()
+
+	o.deployments["dep-1"] = NewDeploymentRunner(DeploymentRunnerConfig{
+		Deployment:    &store.Deployment{ID: "dep-1", TargetInstances: []string{"inst-1"}},
+		ConfigVersion: &store.ConfigVersion{Version: 1},
+	})
+
+	// Repeated reports renew the lease without a new entry
+	o.ReportInstanceStatus(ctx, "inst-1", "dep-1", pb.DeploymentState_DEPLOYMENT_STATE_IN_PROGRESS, "starting", "")
+	o.ReportInstanceStatus(ctx, "inst-1", "dep-1", pb.DeploymentState_DEPLOYMENT_STATE_IN_PROGRESS, "still working", "")
+	o.ReportInstanceStatus(ctx, "inst-1", "dep-1", pb.DeploymentState_DEPLOYMENT_STATE_FAILED, "failed", "bad config")
+	o.ReportInstanceStatus(ctx, "inst-2", "dep-1", pb.DeploymentState_DEPLOYMENT_STATE_COMPLETED, "done", "")
+
+	logs, err := s.ListAuditLogs(ctx, store.ListAuditLogsOptions{ActorType: store.ActorTypeAgent, OldestFirst: true})
+	if err != nil {
+		t.Fatalf("ListAuditLogs failed: %v", err)
+	}
+	if len(logs) != 2 {
+		t.Fatalf("expected 2 audit entries, got %d", len(logs))
+	}
+
+	failed := logs[1]
+	if failed.ActorID == nil || *failed.ActorID != "inst-1" || failed.ResourceID == nil || *failed.ResourceID != "dep-1" {
+		t.Errorf("expected entry by inst-1 on dep-1, got %+v", failed)
+	}
+	if !strings.Contains(string(failed.Details), `"state":"DEPLOYMENT_STATE_FAILED"`) ||
+		!strings.Contains(string(failed.Details), `"error":"bad config"`) {
+		t.Errorf("unexpected details: %s", failed.Details)
+	}
+}
+
 func TestOrchestrator_StartStop(t *testing.T) {
 	s := setupTestStore(t)
 	o := NewOrchestrator(s, nil)
@@ -869,3 +949,123 @@ func TestOrchestrator_RecoverOrphanedDeployments_PreservesCompletedInstances(t *
 		t.Errorf("Instance 2 Status = %q, want %q", recovered2.Status, store.DeploymentInstanceStatusFailed)
 	}
 }
+
+func TestOrchestrator_PreviewDeployment(t *testing.T) {
+	s := setupTestStore(t)
+	o := NewOrchestrator(s, nil)
+	t.Cleanup(func() { o.Stop() })
+
+	ctx := context.Background()
+	cfg, _ := createTestConfig(t, s, "proxy", "server {\n    listen 8080\n}\n")
+	if err := s.CreateConfigVersion(ctx, &store.ConfigVersion{
+		ConfigID: cfg.ID, Version: 2, Content: "server {\n    listen 9090\n}\n", ContentHash: "def456",
+	}); err != nil {
+		t.Fatalf("failed to create config version: %v", err)
+	}
+
+	onV1 := createTestInstance(t, s, "on-v1", map[string]string{"env": "prod"})
+	onV2 := createTestInstance(t, s, "on-v2", map[string]string{"env": "prod"})
+	fresh := createTestInstance(t, s, "fresh", map[string]string{"env": "prod"})
+	for inst, version := range map[*store.Instance]int{onV1: 1, onV2: 2} {
+		inst.CurrentConfigID = &cfg.ID
+		inst.CurrentConfigVersion = &version
+		if err := s.UpdateInstance(ctx, inst); err != nil {
+			t.Fatalf("failed to update instance: %v", err)
+		}
+	}
+
+	preview, err := o.PreviewDeployment(ctx, CreateDeploymentRequest{
+		ConfigID:      cfg.ID,
+		ConfigVersion: 2,
+		TargetLabels:  map[string]string{"env": "prod"},
+	})
+	if err != nil {
+		t.Fatalf("PreviewDeployment failed: %v", err)
+	}
+	if len(preview.Diffs) != 3 {
+		t.Fatalf("Diffs = %d, want 3", len(preview.Diffs))
+	}
+
+	// Sorted by current config: the unconfigured instance comes first
+	none, v1, v2 := preview.Diffs[0], preview.Diffs[1], preview.Diffs[2]
+	if none.Instances[0] != fresh.ID || none.Diff == nil || !strings.HasPrefix(none.Diff.Unified, "--- (none)\n") {
+		t.Errorf("unexpected diff for unconfigured instance: %+v", none)
+	}
+	if v1.Instances[0] != onV1.ID || v1.UpToDate || v1.Diff == nil || len(v1.Diff.Changes) != 1 {
+		t.Errorf("unexpected diff for v1 instance: %+v", v1)
+	}
+	if v2.Instances[0] != onV2.ID || !v2.UpToDate || v2.Diff != nil {
+		t.Errorf("expected v2 instance to be up to date: %+v", v2)
+	}
+
+	deps, _ := s.ListDeployments(ctx, store.ListDeploymentsOptions{})
+	if len(deps) != 0 {
+		t.Errorf("preview should not create a deployment, got %d", len(deps))
+	}
+}
+
+func TestOrchestrator_PreviewDeployment_Template(t *testing.T) {
+	s := setupTestStore(t)
+	o := NewOrchestrator(s, nil)
+	t.Cleanup(func() { o.Stop() })
+
+	ctx := context.Background()
+	cfg := &store.Config{Name: "proxy"}
+	if err := s.CreateConfig(ctx, cfg); err != nil {
+		t.Fatalf("failed to create config: %v", err)
+	}
+	for i, content := range []string{
+		"server {\n    listen \"{{ .Vars.listen }}\"\n}\n",
+		"server {\n    listen \"{{ .Vars.listen }}\"\n    name \"{{ .Hostname }}\"\n}\n",
+	} {
+		if err := s.CreateConfigVersion(ctx, &store.ConfigVersion{
+			ConfigID: cfg.ID, Version: i + 1, Content: content, ContentHash: render.Hash(content), IsTemplate: true,
+		}); err != nil {
+			t.Fatalf("failed to create config version: %v", err)
+		}
+	}
+
+	a := createTestInstance(t, s, "a", map[string]string{"env": "prod"})
+	b := createTestInstance(t, s, "b", map[string]string{"env": "prod"})
+	unset := createTestInstance(t, s, "unset", map[string]string{"env": "prod"})
+	version := 1
+	for _, inst := range []*store.Instance{a, b, unset} {
+		inst.CurrentConfigID = &cfg.ID
+		inst.CurrentConfigVersion = &version
+		if err := s.UpdateInstance(ctx, inst); err != nil {
+			t.Fatalf("failed to update instance: %v", err)
+		}
+	}
+	s.SetVariable(ctx, &store.Variable{InstanceID: a.ID, Name: "listen", Value: "10.0.0.1:8080"})
+	s.SetVariable(ctx, &store.Variable{InstanceID: b.ID, Name: "listen", Value: "10.0.0.2:8080"})
+
+	preview, err := o.PreviewDeployment(ctx, CreateDeploymentRequest{
+		ConfigID:      cfg.ID,
+		ConfigVersion: 2,
+		TargetLabels:  map[string]string{"env": "prod"},
+	})
+	if err != nil {
+		t.Fatalf("PreviewDeployment failed: %v", err)
+	}
+	if len(preview.Diffs) != 3 {
+		t.Fatalf("Diffs = %d, want one per rendering, got %+v", len(preview.Diffs), preview.Diffs)
+	}
+
+	// Each instance sees the change as rendered for it
+	for _, pd := range preview.Diffs {
+		switch pd.Instances[0] {
+		case unset.ID:
+			if pd.RenderError == "" || pd.Diff != nil {
+				t.Errorf("expected a render error for the instance without the variable: %+v", pd)
+			}
+		case a.ID, b.ID:
+			host := "a.local"
+			if pd.Instances[0] == b.ID {
+				host = "b.local"
+			}
+			if pd.Diff == nil || strings.Contains(pd.Diff.Unified, "{{") || !strings.Contains(pd.Diff.Unified, `+    name "`+host+`"`) {
+				t.Errorf("expected the rendered change for %s: %+v", host, pd.Diff)
+			}
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package fleet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/raskell-io/sentinel-hub/internal/diff"
+	"github.com/raskell-io/sentinel-hub/internal/render"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+// DeploymentPreview describes what a deployment would change, without
+// creating it.
+type DeploymentPreview struct {
+	ConfigID        string   `json:"config_id"`
+	ConfigVersion   int      `json:"config_version"`
+	TargetInstances []string `json:"target_instances"`
+	// Diffs groups the targets by the config they currently run.
+	Diffs []PreviewDiff `json:"diffs"`
+}
+
+// PreviewDiff is the change a deployment makes on instances that currently
+// run the same config version. Templates are compared as rendered for each
+// instance, so instances whose rendered content differs are told apart.
+type PreviewDiff struct {
+	Instances []string `json:"instances"`
+	// FromConfigID and FromVersion are empty for instances without a
+	// config known to the hub; their diff is against an empty config.
+	FromConfigID string           `json:"from_config_id,omitempty"`
+	FromVersion  int              `json:"from_version,omitempty"`
+	UpToDate     bool             `json:"up_to_date"`
+	Diff         *diff.ConfigDiff `json:"diff,omitempty"`
+	// RenderError is set when a template cannot be rendered for the
+	// instances, so the deployment would fail on them.
+	RenderError string `json:"render_error,omitempty"`
+}
+
+// PreviewDeployment validates a deployment request like CreateDeployment
+// and returns the diff each target would see.
+func (o *Orchestrator) PreviewDeployment(ctx context.Context, req CreateDeploymentRequest) (*DeploymentPreview, error) {
+	cfg, ver, targetIDs, err := o.prepareDeployment(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Targets are grouped by the version they run and by what they would
+	// see of it and of the target version
+	type group struct {
+		configID  string
+		version   int
+		from, to  string // Hashes of the content rendered for the targets
+		renderErr string
+	}
+	type contents struct {
+		from, to string
+	}
+	type version struct {
+		configID string
+		version  int
+	}
+	groups := make(map[group][]string)
+	content := make(map[group]contents)
+	versions := make(map[version]*store.ConfigVersion)
+	for _, id := range targetIDs {
+		inst, err := o.store.GetInstance(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get instance: %w", err)
+		}
+		var key group
+		key.configID, key.version, _ = instanceConfig(inst)
+		if key.configID == cfg.ID && key.version == ver.Version {
+			groups[key] = append(groups[key], id)
+			continue
+		}
+
+		var fromVer *store.ConfigVersion
+		if key.configID != "" {
+			vkey := version{key.configID, key.version}
+			var ok bool
+			if fromVer, ok = versions[vkey]; !ok {
+				if fromVer, err = o.store.GetConfigVersion(ctx, key.configID, key.version); err != nil {
+					return nil, fmt.Errorf("failed to get config version: %w", err)
+				}
+				versions[vkey] = fromVer
+			}
+		}
+
+		from, err := previewContent(ctx, o.store, inst, fromVer)
+		if err == nil {
+			var to string
+			if to, err = previewContent(ctx, o.store, inst, ver); err == nil {
+				key.from, key.to = render.Hash(from), render.Hash(to)
+				content[key] = contents{from: from, to: to}
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, render.ErrTemplate) {
+				return nil, fmt.Errorf("failed to render config: %w", err)
+			}
+			key.renderErr = err.Error()
+		}
+		groups[key] = append(groups[key], id)
+	}
+
+	preview := &DeploymentPreview{
+		ConfigID:        cfg.ID,
+		ConfigVersion:   ver.Version,
+		TargetInstances: targetIDs,
+		Diffs:           make([]PreviewDiff, 0, len(groups)),
+	}
+	toName := fmt.Sprintf("%s v%d", cfg.Name, ver.Version)
+
+	for key, instances := range groups {
+		pd := PreviewDiff{Instances: instances, FromConfigID: key.configID, FromVersion: key.version}
+		if key.configID == cfg.ID && key.version == ver.Version {
+			pd.UpToDate = true
+			preview.Diffs = append(preview.Diffs, pd)
+			continue
+		}
+		if key.renderErr != "" {
+			pd.RenderError = key.renderErr
+			preview.Diffs = append(preview.Diffs, pd)
+			continue
+		}
+
+		fromName := "(none)"
+		if key.configID != "" {
+			fromName = fmt.Sprintf("%s v%d", key.configID, key.version)
+			if key.configID == cfg.ID {
+				fromName = fmt.Sprintf("%s v%d", cfg.Name, key.version)
+			} else if fromCfg, err := o.store.GetConfig(ctx, key.configID); err == nil && fromCfg != nil {
+				fromName = fmt.Sprintf("%s v%d", fromCfg.Name, key.version)
+			}
+			if versions[version{key.configID, key.version}] == nil {
+				fromName += " (deleted)"
+			}
+		}
+
+		c := content[key]
+		pd.Diff = diff.Compare(fromName, toName, c.from, c.to)
+		preview.Diffs = append(preview.Diffs, pd)
+	}
+
+	sort.Slice(preview.Diffs, func(i, j int) bool {
+		a, b := preview.Diffs[i], preview.Diffs[j]
+		if a.FromConfigID != b.FromConfigID {
+			return a.FromConfigID < b.FromConfigID
+		}
+		if a.FromVersion != b.FromVersion {
+			return a.FromVersion < b.FromVersion
+		}
+		return a.Instances[0] < b.Instances[0]
+	})
+	return preview, nil
+}
+
+// previewContent returns a version's content as it would be delivered to
+// an instance, without resolving secrets, or "" for no version.
+func previewContent(ctx context.Context, s store.Store, inst *store.Instance, ver *store.ConfigVersion) (string, error) {
+	if ver == nil {
+		return "", nil
+	}
+	if inst == nil {
+		return ver.Content, nil
+	}
+	content, _, err := render.ForInstance(ctx, s, inst, ver)
+	return content, err
+}
+
+// instanceConfig returns the config an instance currently runs, if known.
+func instanceConfig(inst *store.Instance) (string, int, bool) {
+	if inst == nil || inst.CurrentConfigID == nil || inst.CurrentConfigVersion == nil {
+		return "", 0, false
+	}
+	return *inst.CurrentConfigID, *inst.CurrentConfigVersion, true
+}
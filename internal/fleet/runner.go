@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/raskell-io/sentinel-hub/internal/events"
 	hubgrpc "github.com/raskell-io/sentinel-hub/internal/grpc"
 	"github.com/raskell-io/sentinel-hub/internal/store"
 	pb "github.com/raskell-io/sentinel-hub/pkg/hubpb"
@@ -16,8 +17,9 @@ import (
 type DeploymentRunner struct {
 	deployment    *store.Deployment
 	configVersion *store.ConfigVersion
-	store         *store.Store
+	store         store.Store
 	fleetService  *hubgrpc.FleetService
+	events        *events.Bus
 
 	// Configuration
 	timeout            time.Duration
@@ -48,8 +50,9 @@ type instanceResult struct {
 type DeploymentRunnerConfig struct {
 	Deployment         *store.Deployment
 	ConfigVersion      *store.ConfigVersion
-	Store              *store.Store
+	Store              store.Store
 	FleetService       *hubgrpc.FleetService
+	Events             *events.Bus // Receives deployment lifecycle events; may be nil
 	Timeout            time.Duration
 	InstanceTimeout    time.Duration // Timeout for individual instance deployment
 	LeaseTimeout       time.Duration // How long before a lease is considered stale
@@ -76,6 +79,7 @@ func NewDeploymentRunner(cfg DeploymentRunnerConfig) *DeploymentRunner {
 		configVersion:      cfg.ConfigVersion,
 		store:              cfg.Store,
 		fleetService:       cfg.FleetService,
+		events:             cfg.Events,
 		timeout:            cfg.Timeout,
 		instanceTimeout:    instanceTimeout,
 		leaseTimeout:       leaseTimeout,
@@ -147,6 +151,11 @@ func (r *DeploymentRunner) Run(parentCtx context.Context) error {
 	// Check if all instances succeeded
 	if r.allSucceeded() {
 		log.Info().Str("deployment_id", r.deployment.ID).Msg("Deployment completed successfully")
+		// A version that deployed cleanly becomes the one assignments
+		// following this config hand to new instances
+		if err := r.store.ApproveConfigVersion(ctx, r.deployment.ConfigID, r.deployment.ConfigVersion); err != nil {
+			log.Warn().Err(err).Str("deployment_id", r.deployment.ID).Msg("Failed to approve config version")
+		}
 		r.updateStatus(ctx, store.DeploymentStatusCompleted)
 	} else {
 		log.Warn().Str("deployment_id", r.deployment.ID).Msg("Deployment completed with failures")
@@ -531,6 +540,7 @@ func (r *DeploymentRunner) ReportInstanceStatus(instanceID string, state pb.Depl
 		return
 	}
 
+	previous := result.Status
 	result.Status = state
 	result.LastStatusAt = &now // Update lease
 	if state == pb.DeploymentState_DEPLOYMENT_STATE_COMPLETED ||
@@ -545,6 +555,9 @@ func (r *DeploymentRunner) ReportInstanceStatus(instanceID string, state pb.Depl
 
 	// Persist to database
 	r.persistInstanceStatus(context.Background(), instanceID)
+	if state != previous {
+		r.publishInstanceState(context.Background(), instanceID, previous, state, message, errorDetails)
+	}
 
 	log.Debug().
 		Str("deployment_id", r.deployment.ID).
@@ -554,6 +567,42 @@ func (r *DeploymentRunner) ReportInstanceStatus(instanceID string, state pb.Depl
 		Msg("Instance status updated")
 }
 
+// publishInstanceState publishes an instance's state change within the
+// deployment to live event streams.
+func (r *DeploymentRunner) publishInstanceState(ctx context.Context, instanceID string, previous, state pb.DeploymentState, message, errorDetails string) {
+	if r.events == nil {
+		return
+	}
+
+	data := events.DeploymentInstanceData{
+		DeploymentID:  r.deployment.ID,
+		InstanceID:    instanceID,
+		State:         string(storeInstanceStatus(state)),
+		PreviousState: string(storeInstanceStatus(previous)),
+		Message:       message,
+		Error:         errorDetails,
+	}
+	// Labels let streams hide instances the subscriber may not see
+	if r.store != nil {
+		if inst, err := r.store.GetInstance(ctx, instanceID); err == nil && inst != nil {
+			data.Labels = inst.Labels
+		}
+	}
+	r.events.Publish(ctx, events.DeploymentInstanceStatus, data)
+}
+
+// instanceState returns an instance's current state and whether the
+// instance is part of this deployment.
+func (r *DeploymentRunner) instanceState(instanceID string) (pb.DeploymentState, bool) {
+	r.instanceResultsMu.RLock()
+	defer r.instanceResultsMu.RUnlock()
+	result, ok := r.instanceResults[instanceID]
+	if !ok {
+		return pb.DeploymentState_DEPLOYMENT_STATE_UNKNOWN, false
+	}
+	return result.Status, true
+}
+
 // GetInstanceResults returns the current instance results.
 func (r *DeploymentRunner) GetInstanceResults() map[string]InstanceDeploymentResult {
 	r.instanceResultsMu.RLock()
@@ -598,27 +647,10 @@ func (r *DeploymentRunner) persistInstanceStatus(ctx context.Context, instanceID
 	errorMsg := result.ErrorMessage
 	r.instanceResultsMu.RUnlock()
 
-	// Map protobuf state to store status
-	var storeStatus store.DeploymentInstanceStatus
-	switch status {
-	case pb.DeploymentState_DEPLOYMENT_STATE_PENDING:
-		storeStatus = store.DeploymentInstanceStatusPending
-	case pb.DeploymentState_DEPLOYMENT_STATE_IN_PROGRESS:
-		storeStatus = store.DeploymentInstanceStatusInProgress
-	case pb.DeploymentState_DEPLOYMENT_STATE_COMPLETED:
-		storeStatus = store.DeploymentInstanceStatusCompleted
-	case pb.DeploymentState_DEPLOYMENT_STATE_FAILED:
-		storeStatus = store.DeploymentInstanceStatusFailed
-	case pb.DeploymentState_DEPLOYMENT_STATE_ROLLED_BACK:
-		storeStatus = store.DeploymentInstanceStatusRolledBack
-	default:
-		storeStatus = store.DeploymentInstanceStatusPending
-	}
-
 	di := &store.DeploymentInstance{
 		DeploymentID: r.deployment.ID,
 		InstanceID:   instanceID,
-		Status:       storeStatus,
+		Status:       storeInstanceStatus(status),
 		StartedAt:    startedAt,
 		CompletedAt:  completedAt,
 		LastStatusAt: lastStatusAt,
@@ -635,6 +667,23 @@ func (r *DeploymentRunner) persistInstanceStatus(ctx context.Context, instanceID
 	}
 }
 
+// storeInstanceStatus maps a protobuf deployment state to the stored
+// per-instance status.
+func storeInstanceStatus(state pb.DeploymentState) store.DeploymentInstanceStatus {
+	switch state {
+	case pb.DeploymentState_DEPLOYMENT_STATE_IN_PROGRESS:
+		return store.DeploymentInstanceStatusInProgress
+	case pb.DeploymentState_DEPLOYMENT_STATE_COMPLETED:
+		return store.DeploymentInstanceStatusCompleted
+	case pb.DeploymentState_DEPLOYMENT_STATE_FAILED:
+		return store.DeploymentInstanceStatusFailed
+	case pb.DeploymentState_DEPLOYMENT_STATE_ROLLED_BACK:
+		return store.DeploymentInstanceStatusRolledBack
+	default:
+		return store.DeploymentInstanceStatusPending
+	}
+}
+
 // allSucceeded returns true if all instances completed successfully.
 func (r *DeploymentRunner) allSucceeded() bool {
 	r.instanceResultsMu.RLock()
@@ -721,6 +770,10 @@ func (r *DeploymentRunner) rollbackDeployedInstances(ctx context.Context) {
 		Int("instance_count", len(deployedInstances)).
 		Int("rollback_version", prevVersion).
 		Msg("Rollback initiated")
+
+	data := deploymentEventData(r.deployment)
+	data.Reason = fmt.Sprintf("rolling back %d instances to version %d", len(deployedInstances), prevVersion)
+	r.events.Publish(ctx, events.DeploymentRolledBack, data)
 }
 
 // updateStatus updates the deployment status in the database.
@@ -735,7 +788,38 @@ func (r *DeploymentRunner) updateStatus(ctx context.Context, status store.Deploy
 		r.deployment.CompletedAt = &now
 	}
 
-	return r.store.UpdateDeployment(ctx, r.deployment)
+	if err := r.store.UpdateDeployment(ctx, r.deployment); err != nil {
+		return err
+	}
+
+	if eventType, ok := deploymentEventTypes[status]; ok {
+		r.events.Publish(ctx, eventType, deploymentEventData(r.deployment))
+	}
+	return nil
+}
+
+// deploymentEventTypes maps deployment statuses to the events announcing them.
+var deploymentEventTypes = map[store.DeploymentStatus]events.Type{
+	store.DeploymentStatusInProgress: events.DeploymentStarted,
+	store.DeploymentStatusCompleted:  events.DeploymentCompleted,
+	store.DeploymentStatusFailed:     events.DeploymentFailed,
+	store.DeploymentStatusCancelled:  events.DeploymentCancelled,
+}
+
+// deploymentEventData describes a deployment in event payloads.
+func deploymentEventData(dep *store.Deployment) events.DeploymentData {
+	data := events.DeploymentData{
+		DeploymentID:    dep.ID,
+		ConfigID:        dep.ConfigID,
+		ConfigVersion:   dep.ConfigVersion,
+		Strategy:        string(dep.Strategy),
+		Status:          string(dep.Status),
+		TargetInstances: len(dep.TargetInstances),
+	}
+	if dep.Progress != nil {
+		data.Reason = dep.Progress.FailureReason
+	}
+	return data
 }
 
 // updateProgress updates the deployment progress.
@@ -762,4 +846,13 @@ func (r *DeploymentRunner) updateProgress(ctx context.Context, currentBatch, tot
 	}
 
 	r.store.UpdateDeployment(ctx, r.deployment)
+
+	r.events.Publish(ctx, events.DeploymentProgress, events.DeploymentProgressData{
+		DeploymentID:       r.deployment.ID,
+		TotalInstances:     r.deployment.Progress.TotalInstances,
+		CompletedInstances: completed,
+		FailedInstances:    failed,
+		CurrentBatch:       currentBatch,
+		TotalBatches:       totalBatches,
+	})
 }
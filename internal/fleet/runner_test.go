@@ -1,9 +1,11 @@
 package fleet
 
 import (
+	"context"
 	"testing"
 	"time"
 
+	"github.com/raskell-io/sentinel-hub/internal/events"
 	"github.com/raskell-io/sentinel-hub/internal/store"
 	pb "github.com/raskell-io/sentinel-hub/pkg/hubpb"
 )
@@ -288,6 +290,39 @@ func TestDeploymentRunner_ReportInstanceStatus(t *testing.T) {
 	runner.ReportInstanceStatus("nonexistent", pb.DeploymentState_DEPLOYMENT_STATE_COMPLETED, "done", "")
 }
 
+func TestDeploymentRunner_ReportInstanceStatus_PublishesTransitions(t *testing.T) {
+	bus := events.NewBus()
+	var published []events.DeploymentInstanceData
+	bus.Subscribe(func(ctx context.Context, e events.Event) {
+		if e.Type == events.DeploymentInstanceStatus {
+			published = append(published, e.Data.(events.DeploymentInstanceData))
+		}
+	})
+
+	runner := NewDeploymentRunner(DeploymentRunnerConfig{
+		Deployment: &store.Deployment{
+			ID:              "dep-1",
+			TargetInstances: []string{"inst-1"},
+		},
+		ConfigVersion: &store.ConfigVersion{Version: 1},
+		Events:        bus,
+	})
+
+	runner.ReportInstanceStatus("inst-1", pb.DeploymentState_DEPLOYMENT_STATE_IN_PROGRESS, "applying", "")
+	runner.ReportInstanceStatus("inst-1", pb.DeploymentState_DEPLOYMENT_STATE_IN_PROGRESS, "still applying", "")
+	runner.ReportInstanceStatus("inst-1", pb.DeploymentState_DEPLOYMENT_STATE_FAILED, "error", "validation failed")
+
+	if len(published) != 2 {
+		t.Fatalf("expected 2 transitions, got %d: %+v", len(published), published)
+	}
+	if got := published[0]; got.PreviousState != "pending" || got.State != "in_progress" || got.DeploymentID != "dep-1" {
+		t.Errorf("unexpected first transition: %+v", got)
+	}
+	if got := published[1]; got.PreviousState != "in_progress" || got.State != "failed" || got.Error != "validation failed" {
+		t.Errorf("unexpected second transition: %+v", got)
+	}
+}
+
 func TestDeploymentRunner_SetInstanceError(t *testing.T) {
 	runner := NewDeploymentRunner(DeploymentRunnerConfig{
 		Deployment: &store.Deployment{
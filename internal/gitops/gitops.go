@@ -0,0 +1,457 @@
+// Package gitops keeps configs in step with files kept in a Git repository
+// or a plain directory.
+//
+// Every .kdl file under the synced directory is a config named by its path
+// without the extension, so prod/edge.kdl is the config prod/edge. A file
+// whose content differs from its config's current version becomes a new
+// version, recording the commit that last changed the file and its author;
+// configs missing from the hub are created. The files are the source of
+// truth: changes made to a synced config through the API are replaced at
+// the next sync. Configs whose file is removed are left as they are.
+package gitops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/raskell-io/sentinel-hub/internal/audit"
+	"github.com/raskell-io/sentinel-hub/internal/compose"
+	"github.com/raskell-io/sentinel-hub/internal/fleet"
+	"github.com/raskell-io/sentinel-hub/internal/render"
+	"github.com/raskell-io/sentinel-hub/internal/secrets"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// Config controls what is synced and what happens to new versions.
+type Config struct {
+	// Path is a Git working tree, a bare repository or a plain directory.
+	Path string
+	// Branch is the branch or other revision of a repository to sync.
+	// Defaults to HEAD.
+	Branch string
+	// Dir is the directory within Path holding the config files. Defaults
+	// to the root.
+	Dir string
+	// Interval between syncs.
+	Interval time.Duration
+	// DeployStrategy, if set, deploys each new version to the instances
+	// running its config with this strategy.
+	DeployStrategy store.DeploymentStrategy
+	// DeployBatchSize is the batch size of those deployments.
+	DeployBatchSize int
+}
+
+// DefaultConfig returns a config that syncs HEAD every minute without
+// deploying.
+func DefaultConfig() Config {
+	return Config{Interval: time.Minute}
+}
+
+// Status describes the last sync.
+type Status struct {
+	Path       string       `json:"path"`
+	Branch     string       `json:"branch,omitempty"`
+	Dir        string       `json:"dir,omitempty"`
+	Revision   string       `json:"revision,omitempty"` // Commit SHA, or content hash for directories
+	LastSyncAt *time.Time   `json:"last_sync_at,omitempty"`
+	Error      string       `json:"error,omitempty"` // Set when the source could not be read
+	Files      []FileStatus `json:"files"`
+}
+
+// FileStatus describes the last sync of one file.
+type FileStatus struct {
+	Path          string   `json:"path"`
+	Config        string   `json:"config"`
+	ConfigID      string   `json:"config_id,omitempty"`
+	Version       int      `json:"version,omitempty"` // Version matching the file
+	Changed       bool     `json:"changed"`           // Whether the sync created that version
+	Commit        string   `json:"commit,omitempty"`
+	Author        string   `json:"author,omitempty"`
+	DeploymentIDs []string `json:"deployment_ids,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// Deployer starts deployments; *fleet.Orchestrator implements it.
+type Deployer interface {
+	CreateDeployment(ctx context.Context, req fleet.CreateDeploymentRequest) (*store.Deployment, error)
+}
+
+// Syncer applies the files of a source to the store.
+type Syncer struct {
+	store    store.Store
+	config   Config
+	source   source
+	resolver *compose.Resolver
+	audit    *audit.Recorder
+	deployer Deployer
+	now      func() time.Time
+
+	syncMu   sync.Mutex // Serializes syncs
+	statusMu sync.RWMutex
+	status   Status
+}
+
+// NewSyncer creates a syncer. Whether Path is a Git repository is decided
+// here, so it must exist by then.
+func NewSyncer(s store.Store, config Config) *Syncer {
+	if config.Interval <= 0 {
+		config.Interval = DefaultConfig().Interval
+	}
+	return &Syncer{
+		store:    s,
+		config:   config,
+		source:   newSource(config),
+		resolver: compose.NewResolver(s),
+		audit:    audit.NewRecorder(s, audit.RecorderConfig{}),
+		now:      time.Now,
+		status: Status{
+			Path:   config.Path,
+			Branch: config.Branch,
+			Dir:    config.Dir,
+			Files:  []FileStatus{},
+		},
+	}
+}
+
+// SetConfigResolver sets the resolver that keeps layered configs up to
+// date, so that it publishes to the configured event bus.
+func (s *Syncer) SetConfigResolver(r *compose.Resolver) {
+	s.resolver = r
+}
+
+// SetAuditRecorder sets the recorder audit logs are written through.
+func (s *Syncer) SetAuditRecorder(r *audit.Recorder) {
+	s.audit = r
+}
+
+// SetDeployer sets what new versions are deployed with. Without one, or
+// without a deploy strategy, new versions are not deployed.
+func (s *Syncer) SetDeployer(d Deployer) {
+	s.deployer = d
+}
+
+// Status returns the status of the last sync.
+func (s *Syncer) Status() Status {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+	return s.status
+}
+
+// Run syncs immediately and then once per interval until ctx is cancelled.
+func (s *Syncer) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Sync(ctx); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Str("path", s.config.Path).Msg("GitOps sync failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync applies the current files of the source once. Files that fail do
+// not stop the others; their errors are joined.
+func (s *Syncer) Sync(ctx context.Context) (Status, error) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	now := s.now().UTC()
+	status := Status{
+		Path:       s.config.Path,
+		Branch:     s.config.Branch,
+		Dir:        s.config.Dir,
+		LastSyncAt: &now,
+		Files:      []FileStatus{},
+	}
+
+	err := s.sync(ctx, &status)
+	if err != nil {
+		syncsTotal.WithLabelValues("error").Inc()
+	} else {
+		syncsTotal.WithLabelValues("ok").Inc()
+	}
+	lastSync.Set(float64(now.Unix()))
+
+	s.statusMu.Lock()
+	s.status = status
+	s.statusMu.Unlock()
+	return status, err
+}
+
+// sync fills in status from one pass over the source.
+func (s *Syncer) sync(ctx context.Context, status *Status) error {
+	snap, err := s.source.read(ctx)
+	if err != nil {
+		status.Error = err.Error()
+		return err
+	}
+	status.Revision = snap.revision
+
+	configs, err := s.store.ListConfigs(ctx, store.ListConfigsOptions{})
+	if err != nil {
+		status.Error = err.Error()
+		return fmt.Errorf("failed to list configs: %w", err)
+	}
+	byName := make(map[string]*store.Config, len(configs))
+	for i := range configs {
+		byName[configs[i].Name] = &configs[i]
+	}
+
+	var errs []error
+	changed := 0
+	for _, f := range snap.files {
+		fs := s.syncFile(ctx, snap.revision, f, byName[configName(f.path)])
+		if fs.Error != "" {
+			errs = append(errs, fmt.Errorf("%s: %s", fs.Path, fs.Error))
+		}
+		if fs.Changed {
+			changed++
+		}
+		status.Files = append(status.Files, fs)
+	}
+
+	log.Info().
+		Str("path", s.config.Path).
+		Str("revision", snap.revision).
+		Int("files", len(snap.files)).
+		Int("changed", changed).
+		Int("errors", len(errs)).
+		Msg("GitOps sync completed")
+
+	return errors.Join(errs...)
+}
+
+// configName returns the name of the config kept in a file.
+func configName(path string) string {
+	return strings.TrimSuffix(path, configExt)
+}
+
+// syncFile makes the file's content the current version of its config,
+// creating the config if cfg is nil.
+func (s *Syncer) syncFile(ctx context.Context, revision string, f file, cfg *store.Config) FileStatus {
+	fs := FileStatus{Path: f.path, Config: configName(f.path)}
+	fail := func(msg string) FileStatus {
+		fs.Error = msg
+		return fs
+	}
+
+	hash := render.Hash(f.content)
+	isTemplate := false
+	if cfg != nil {
+		fs.ConfigID = cfg.ID
+
+		layers, err := s.store.ListConfigLayers(ctx, cfg.ID)
+		if err != nil {
+			return fail("failed to list config layers: " + err.Error())
+		}
+		if len(layers) > 0 {
+			return fail("config is composed from layers")
+		}
+
+		current, err := s.store.GetConfigVersion(ctx, cfg.ID, cfg.CurrentVersion)
+		if err != nil {
+			return fail("failed to get current version: " + err.Error())
+		}
+		if current != nil {
+			if current.ContentHash == hash {
+				fs.Version = current.Version
+				return fs
+			}
+			isTemplate = current.IsTemplate
+		}
+	}
+
+	if f.content == "" {
+		return fail("file is empty")
+	}
+	names := secrets.References(f.content)
+	if isTemplate {
+		if _, err := render.Parse(f.content); err != nil {
+			return fail(err.Error())
+		}
+		var err error
+		if names, err = secrets.TemplateReferences(f.content); err != nil {
+			return fail(err.Error())
+		}
+	}
+	for _, name := range names {
+		secret, err := s.store.GetSecret(ctx, name)
+		if err != nil {
+			return fail("failed to get secret " + name + ": " + err.Error())
+		}
+		if secret == nil {
+			return fail("secret " + name + " does not exist")
+		}
+	}
+
+	c, err := s.source.lastCommit(ctx, revision, f.path)
+	if err != nil {
+		return fail(err.Error())
+	}
+	summary := "Synced from " + f.path
+	var createdBy *string
+	if c != nil {
+		summary = fmt.Sprintf("Synced from commit %s: %s", shortSHA(c.sha), c.subject)
+		createdBy = &c.author
+		fs.Commit = c.sha
+		fs.Author = c.author
+	}
+
+	created := cfg == nil
+	if created {
+		cfg = &store.Config{
+			ID:        uuid.New().String(),
+			Name:      fs.Config,
+			CreatedBy: createdBy,
+		}
+		if err := s.store.CreateConfig(ctx, cfg); err != nil {
+			return fail("failed to create config: " + err.Error())
+		}
+		fs.ConfigID = cfg.ID
+	}
+
+	ver := &store.ConfigVersion{
+		ID:            uuid.New().String(),
+		ConfigID:      cfg.ID,
+		Version:       cfg.CurrentVersion + 1,
+		Content:       f.content,
+		ContentHash:   hash,
+		IsTemplate:    isTemplate,
+		ChangeSummary: &summary,
+		CreatedBy:     createdBy,
+	}
+	if created {
+		ver.Version = 1
+	}
+	if err := s.store.CreateConfigVersion(ctx, ver); err != nil {
+		return fail("failed to create config version: " + err.Error())
+	}
+	if !created {
+		cfg.CurrentVersion = ver.Version
+		if err := s.store.UpdateConfig(ctx, cfg); err != nil {
+			return fail("failed to update config: " + err.Error())
+		}
+	}
+	fs.Version = ver.Version
+	fs.Changed = true
+	versionsCreated.Inc()
+
+	log.Info().
+		Str("config_id", cfg.ID).
+		Str("name", cfg.Name).
+		Int("version", ver.Version).
+		Str("commit", fs.Commit).
+		Msg("Synced config version")
+	s.auditLog(ctx, cfg, ver, fs)
+
+	versions := []*store.ConfigVersion{ver}
+	dependents, err := s.resolver.LayerChanged(ctx, cfg.ID, createdBy)
+	if err != nil {
+		log.Warn().Err(err).Str("config_id", cfg.ID).Msg("Some composed configs could not be resolved")
+	}
+	versions = append(versions, dependents...)
+
+	for _, v := range versions {
+		id, err := s.deploy(ctx, v)
+		if err != nil {
+			// The version stays; it can still be deployed by hand
+			return fail(fmt.Sprintf("failed to deploy version %d of config %s: %v", v.Version, v.ConfigID, err))
+		}
+		if id != "" {
+			fs.DeploymentIDs = append(fs.DeploymentIDs, id)
+		}
+	}
+	return fs
+}
+
+// deploy starts a deployment of a version to the instances running its
+// config, if deploying is configured. It returns the deployment ID, or ""
+// when nothing was deployed.
+func (s *Syncer) deploy(ctx context.Context, ver *store.ConfigVersion) (string, error) {
+	if s.deployer == nil || s.config.DeployStrategy == "" {
+		return "", nil
+	}
+
+	instances, err := s.store.ListInstances(ctx, store.ListInstancesOptions{ConfigID: ver.ConfigID})
+	if err != nil {
+		return "", err
+	}
+	if len(instances) == 0 {
+		return "", nil
+	}
+	targets := make([]string, len(instances))
+	for i, inst := range instances {
+		targets[i] = inst.ID
+	}
+
+	dep, err := s.deployer.CreateDeployment(ctx, fleet.CreateDeploymentRequest{
+		ConfigID:        ver.ConfigID,
+		ConfigVersion:   ver.Version,
+		TargetInstances: targets,
+		Strategy:        s.config.DeployStrategy,
+		BatchSize:       s.config.DeployBatchSize,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	s.record(ctx, &store.AuditLog{
+		Action:       "create",
+		ResourceType: "deployment",
+		ResourceID:   &dep.ID,
+	}, map[string]interface{}{
+		"config_id":        ver.ConfigID,
+		"config_version":   ver.Version,
+		"strategy":         string(dep.Strategy),
+		"target_instances": len(dep.TargetInstances),
+	})
+	return dep.ID, nil
+}
+
+// auditLog records a synced version.
+func (s *Syncer) auditLog(ctx context.Context, cfg *store.Config, ver *store.ConfigVersion, fs FileStatus) {
+	s.record(ctx, &store.AuditLog{
+		Action:       "sync",
+		ResourceType: "config",
+		ResourceID:   &cfg.ID,
+	}, map[string]interface{}{
+		"name":    cfg.Name,
+		"version": ver.Version,
+		"path":    fs.Path,
+		"commit":  fs.Commit,
+		"author":  fs.Author,
+	})
+}
+
+// record writes an audit log entry for an action taken by the syncer.
+func (s *Syncer) record(ctx context.Context, entry *store.AuditLog, details interface{}) {
+	entry.ID = uuid.New().String()
+	entry.ActorType = store.ActorTypeSystem
+	if b, err := json.Marshal(details); err == nil {
+		entry.Details = b
+	}
+	if err := s.audit.Record(ctx, entry); err != nil {
+		log.Warn().Err(err).Str("action", entry.Action).Msg("Failed to create audit log")
+	}
+}
+
+// shortSHA abbreviates a commit SHA for display.
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
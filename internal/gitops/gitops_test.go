@@ -0,0 +1,371 @@
+package gitops
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/raskell-io/sentinel-hub/internal/fleet"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+func setupTestStore(t *testing.T) store.Store {
+	t.Helper()
+
+	s, err := store.New(filepath.Join(t.TempDir(), "hub.db"))
+	if err != nil {
+		t.Fatalf("failed to create test store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// runGit runs a git command in dir, failing the test on error.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Ada Ops", "GIT_AUTHOR_EMAIL=ada@example.com",
+		"GIT_COMMITTER_NAME=Ada Ops", "GIT_COMMITTER_EMAIL=ada@example.com",
+		"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// writeFile writes a file below dir, creating its directories.
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+}
+
+// commitFiles writes files to a working tree and commits them.
+func commitFiles(t *testing.T, repo, message string, files map[string]string) string {
+	t.Helper()
+
+	for name, content := range files {
+		writeFile(t, repo, name, content)
+	}
+	runGit(t, repo, "add", "-A")
+	runGit(t, repo, "commit", "-q", "-m", message)
+	return runGit(t, repo, "rev-parse", "HEAD")
+}
+
+// newRepo creates a Git working tree in a temp dir.
+func newRepo(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repo := t.TempDir()
+	runGit(t, repo, "init", "-q", "-b", "main")
+	return repo
+}
+
+// configByName returns the config with a name, failing if it is missing.
+func configByName(t *testing.T, s store.Store, name string) *store.Config {
+	t.Helper()
+
+	configs, err := s.ListConfigs(context.Background(), store.ListConfigsOptions{})
+	if err != nil {
+		t.Fatalf("ListConfigs failed: %v", err)
+	}
+	for i := range configs {
+		if configs[i].Name == name {
+			return &configs[i]
+		}
+	}
+	t.Fatalf("config %s not found", name)
+	return nil
+}
+
+func TestSyncer_Sync_GitRepo(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestStore(t)
+	repo := newRepo(t)
+
+	first := commitFiles(t, repo, "Add edge configs", map[string]string{
+		"configs/edge.kdl":     "server { listen \"0.0.0.0:80\" }\n",
+		"configs/prod/api.kdl": "server { listen \"0.0.0.0:8080\" }\n",
+		"configs/README.md":    "Not a config\n",
+		"other/ignored.kdl":    "outside the synced directory\n",
+	})
+
+	cfg := DefaultConfig()
+	cfg.Path = repo
+	cfg.Dir = "configs"
+	syncer := NewSyncer(s, cfg)
+
+	status, err := syncer.Sync(ctx)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if status.Revision != first || len(status.Files) != 2 {
+		t.Fatalf("expected 2 files at %s, got %+v", first, status)
+	}
+
+	edge := configByName(t, s, "edge")
+	ver, err := s.GetConfigVersion(ctx, edge.ID, 1)
+	if err != nil || ver == nil {
+		t.Fatalf("expected version 1 of edge: %v", err)
+	}
+	if ver.CreatedBy == nil || *ver.CreatedBy != "Ada Ops <ada@example.com>" {
+		t.Errorf("expected commit author as creator, got %v", ver.CreatedBy)
+	}
+	if ver.ChangeSummary == nil || *ver.ChangeSummary != "Synced from commit "+first[:12]+": Add edge configs" {
+		t.Errorf("unexpected change summary %v", ver.ChangeSummary)
+	}
+	configByName(t, s, "prod/api")
+
+	// Unchanged files create no versions
+	status, err = syncer.Sync(ctx)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	for _, f := range status.Files {
+		if f.Changed || f.Version != 1 {
+			t.Errorf("expected %s unchanged at version 1, got %+v", f.Path, f)
+		}
+	}
+
+	// Uncommitted changes are not synced; committed ones are
+	writeFile(t, repo, "configs/edge.kdl", "server { listen \"0.0.0.0:81\" }\n")
+	if _, err := syncer.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if edge = configByName(t, s, "edge"); edge.CurrentVersion != 1 {
+		t.Fatalf("expected uncommitted change to be ignored, got version %d", edge.CurrentVersion)
+	}
+	second := commitFiles(t, repo, "Move edge to port 81", nil)
+	status, err = syncer.Sync(ctx)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if edge = configByName(t, s, "edge"); edge.CurrentVersion != 2 {
+		t.Fatalf("expected version 2 of edge, got %d", edge.CurrentVersion)
+	}
+	if status.Files[0].Commit != second || !status.Files[0].Changed {
+		t.Errorf("expected edge.kdl changed in %s, got %+v", second, status.Files[0])
+	}
+	// api.kdl keeps the commit that last changed it
+	if api := configByName(t, s, "prod/api"); api.CurrentVersion != 1 {
+		t.Errorf("expected prod/api unchanged, got version %d", api.CurrentVersion)
+	}
+
+	// Changes made through the API are replaced by the file
+	edge.CurrentVersion = 3
+	if err := s.CreateConfigVersion(ctx, &store.ConfigVersion{ConfigID: edge.ID, Version: 3, Content: "edited", ContentHash: "edited"}); err != nil {
+		t.Fatalf("CreateConfigVersion failed: %v", err)
+	}
+	if err := s.UpdateConfig(ctx, edge); err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+	if _, err := syncer.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	ver, _ = s.GetLatestConfigVersion(ctx, edge.ID)
+	if ver == nil || ver.Version != 4 || !strings.Contains(ver.Content, ":81") {
+		t.Errorf("expected the file restored as version 4, got %+v", ver)
+	}
+}
+
+func TestSyncer_Sync_BareRepoBranch(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestStore(t)
+	repo := newRepo(t)
+
+	commitFiles(t, repo, "Add edge", map[string]string{"edge.kdl": "v1\n"})
+	runGit(t, repo, "checkout", "-q", "-b", "staging")
+	staging := commitFiles(t, repo, "Try v2", map[string]string{"edge.kdl": "v2\n"})
+
+	bare := filepath.Join(t.TempDir(), "configs.git")
+	runGit(t, repo, "clone", "-q", "--bare", repo, bare)
+
+	cfg := DefaultConfig()
+	cfg.Path = bare
+	cfg.Branch = "main"
+	status, err := NewSyncer(s, cfg).Sync(ctx)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	edge := configByName(t, s, "edge")
+	ver, _ := s.GetConfigVersion(ctx, edge.ID, edge.CurrentVersion)
+	if ver == nil || ver.Content != "v1\n" {
+		t.Errorf("expected main's content, got %+v", ver)
+	}
+
+	cfg.Branch = "staging"
+	status, err = NewSyncer(s, cfg).Sync(ctx)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if status.Revision != staging || status.Files[0].Version != 2 {
+		t.Errorf("expected staging synced as version 2, got %+v", status)
+	}
+
+	// A missing branch is reported in the status
+	cfg.Branch = "missing"
+	syncer := NewSyncer(s, cfg)
+	if _, err := syncer.Sync(ctx); err == nil {
+		t.Fatal("expected error for missing branch")
+	}
+	if syncer.Status().Error == "" || syncer.Status().LastSyncAt == nil {
+		t.Errorf("expected error in status, got %+v", syncer.Status())
+	}
+}
+
+func TestSyncer_Sync_Directory(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestStore(t)
+	dir := t.TempDir()
+	writeFile(t, dir, "edge.kdl", "v1\n")
+	writeFile(t, dir, ".hidden/skip.kdl", "hidden\n")
+
+	cfg := DefaultConfig()
+	cfg.Path = dir
+	syncer := NewSyncer(s, cfg)
+
+	status, err := syncer.Sync(ctx)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(status.Files) != 1 || status.Revision == "" {
+		t.Fatalf("expected 1 file, got %+v", status)
+	}
+
+	edge := configByName(t, s, "edge")
+	ver, _ := s.GetConfigVersion(ctx, edge.ID, 1)
+	if ver == nil || ver.CreatedBy != nil || *ver.ChangeSummary != "Synced from edge.kdl" {
+		t.Errorf("unexpected version %+v", ver)
+	}
+
+	writeFile(t, dir, "edge.kdl", "v2\n")
+	next, err := syncer.Sync(ctx)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if next.Revision == status.Revision || next.Files[0].Version != 2 {
+		t.Errorf("expected new revision and version 2, got %+v", next)
+	}
+}
+
+func TestSyncer_Sync_FileErrors(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestStore(t)
+	dir := t.TempDir()
+
+	base := &store.Config{Name: "base"}
+	if err := s.CreateConfig(ctx, base); err != nil {
+		t.Fatalf("CreateConfig failed: %v", err)
+	}
+	composed := &store.Config{Name: "composed"}
+	if err := s.CreateConfig(ctx, composed); err != nil {
+		t.Fatalf("CreateConfig failed: %v", err)
+	}
+	if err := s.SetConfigLayers(ctx, composed.ID, []string{base.ID}); err != nil {
+		t.Fatalf("SetConfigLayers failed: %v", err)
+	}
+
+	writeFile(t, dir, "composed.kdl", "server {}\n")
+	writeFile(t, dir, "secret.kdl", "tls { cert secret=\"missing\"; }\n")
+	writeFile(t, dir, "ok.kdl", "server {}\n")
+
+	cfg := DefaultConfig()
+	cfg.Path = dir
+	status, err := NewSyncer(s, cfg).Sync(ctx)
+	if err == nil {
+		t.Fatal("expected sync errors")
+	}
+
+	errs := map[string]string{}
+	for _, f := range status.Files {
+		errs[f.Path] = f.Error
+	}
+	if errs["composed.kdl"] != "config is composed from layers" {
+		t.Errorf("unexpected error for composed.kdl: %q", errs["composed.kdl"])
+	}
+	if errs["secret.kdl"] != "secret missing does not exist" {
+		t.Errorf("unexpected error for secret.kdl: %q", errs["secret.kdl"])
+	}
+	// Other files are still synced
+	if errs["ok.kdl"] != "" {
+		t.Errorf("unexpected error for ok.kdl: %q", errs["ok.kdl"])
+	}
+	configByName(t, s, "ok")
+}
+
+// fakeDeployer records deployment requests.
+type fakeDeployer struct {
+	requests []fleet.CreateDeploymentRequest
+}
+
+func (d *fakeDeployer) CreateDeployment(ctx context.Context, req fleet.CreateDeploymentRequest) (*store.Deployment, error) {
+	d.requests = append(d.requests, req)
+	return &store.Deployment{
+		ID:              "dep-" + req.ConfigID,
+		Strategy:        req.Strategy,
+		TargetInstances: req.TargetInstances,
+	}, nil
+}
+
+func TestSyncer_Sync_Deploys(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestStore(t)
+	dir := t.TempDir()
+	writeFile(t, dir, "edge.kdl", "v1\n")
+
+	cfg := DefaultConfig()
+	cfg.Path = dir
+	cfg.DeployStrategy = store.DeploymentStrategyRolling
+	cfg.DeployBatchSize = 2
+	syncer := NewSyncer(s, cfg)
+	deployer := &fakeDeployer{}
+	syncer.SetDeployer(deployer)
+
+	// New configs run nowhere yet
+	if _, err := syncer.Sync(ctx); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(deployer.requests) != 0 {
+		t.Fatalf("expected no deployment, got %+v", deployer.requests)
+	}
+
+	edge := configByName(t, s, "edge")
+	version := 1
+	inst := &store.Instance{Name: "edge-1", Hostname: "edge-1", Status: store.InstanceStatusOnline, CurrentConfigID: &edge.ID, CurrentConfigVersion: &version}
+	if err := s.CreateInstance(ctx, inst); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+
+	writeFile(t, dir, "edge.kdl", "v2\n")
+	status, err := syncer.Sync(ctx)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(deployer.requests) != 1 {
+		t.Fatalf("expected 1 deployment, got %+v", deployer.requests)
+	}
+	req := deployer.requests[0]
+	if req.ConfigVersion != 2 || req.Strategy != store.DeploymentStrategyRolling || req.BatchSize != 2 ||
+		len(req.TargetInstances) != 1 || req.TargetInstances[0] != inst.ID {
+		t.Errorf("unexpected deployment request %+v", req)
+	}
+	if ids := status.Files[0].DeploymentIDs; len(ids) != 1 || ids[0] != "dep-"+edge.ID {
+		t.Errorf("expected deployment in status, got %v", ids)
+	}
+}
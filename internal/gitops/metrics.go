@@ -0,0 +1,23 @@
+package gitops
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	syncsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hub_gitops_syncs_total",
+		Help: "GitOps syncs, by result (ok or error).",
+	}, []string{"result"})
+
+	versionsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "hub_gitops_versions_created_total",
+		Help: "Config versions created from synced files.",
+	})
+
+	lastSync = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "hub_gitops_last_sync_timestamp_seconds",
+		Help: "Unix time of the last GitOps sync.",
+	})
+)
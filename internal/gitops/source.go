@@ -0,0 +1,203 @@
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// configExt is the extension of the files that are synced as configs.
+const configExt = ".kdl"
+
+// file is a config file read from a source.
+type file struct {
+	path    string // Relative to the source root, slash-separated
+	content string
+}
+
+// snapshot is the config files of a source at one revision.
+type snapshot struct {
+	revision string
+	files    []file // Sorted by path
+}
+
+// commit identifies the commit that last changed a file.
+type commit struct {
+	sha     string
+	author  string // "Name <email>"
+	subject string
+}
+
+// source reads config files from where they are kept.
+type source interface {
+	read(ctx context.Context) (*snapshot, error)
+	// lastCommit returns the commit that last changed a file up to
+	// revision, or nil if the source has no history.
+	lastCommit(ctx context.Context, revision, path string) (*commit, error)
+}
+
+// newSource returns a Git source for repositories, bare or not, and a
+// directory source for anything else.
+func newSource(cfg Config) source {
+	if isGitRepo(cfg.Path) {
+		branch := cfg.Branch
+		if branch == "" {
+			branch = "HEAD"
+		}
+		return &gitSource{repo: cfg.Path, branch: branch, dir: cleanDir(cfg.Dir)}
+	}
+	return &dirSource{root: filepath.Join(cfg.Path, cleanDir(cfg.Dir))}
+}
+
+// isGitRepo reports whether dir is a Git working tree or bare repository.
+func isGitRepo(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		return true
+	}
+	head, err := os.Stat(filepath.Join(dir, "HEAD"))
+	if err != nil || head.IsDir() {
+		return false
+	}
+	objects, err := os.Stat(filepath.Join(dir, "objects"))
+	return err == nil && objects.IsDir()
+}
+
+// cleanDir normalizes a subdirectory of a source to a slash-separated path
+// without leading or trailing slashes, "" for the root.
+func cleanDir(dir string) string {
+	return strings.Trim(path.Clean("/"+filepath.ToSlash(dir)), "/")
+}
+
+// gitSource reads the config files committed to a branch of a Git
+// repository. Uncommitted changes in a working tree are ignored.
+type gitSource struct {
+	repo   string
+	branch string
+	dir    string
+}
+
+func (g *gitSource) read(ctx context.Context) (*snapshot, error) {
+	out, err := g.git(ctx, "rev-parse", "--verify", "--quiet", g.branch+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", g.branch, err)
+	}
+	snap := &snapshot{revision: strings.TrimSpace(string(out))}
+
+	args := []string{"ls-tree", "-r", "-z", "--full-name", "--name-only", snap.revision}
+	if g.dir != "" {
+		args = append(args, "--", g.dir)
+	}
+	out, err = g.git(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	for _, name := range strings.Split(string(out), "\x00") {
+		if !strings.HasSuffix(name, configExt) {
+			continue
+		}
+		content, err := g.git(ctx, "cat-file", "blob", snap.revision+":"+name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		rel := name
+		if g.dir != "" {
+			rel = strings.TrimPrefix(name, g.dir+"/")
+		}
+		snap.files = append(snap.files, file{path: rel, content: string(content)})
+	}
+
+	sort.Slice(snap.files, func(i, j int) bool { return snap.files[i].path < snap.files[j].path })
+	return snap, nil
+}
+
+func (g *gitSource) lastCommit(ctx context.Context, revision, name string) (*commit, error) {
+	if g.dir != "" {
+		name = g.dir + "/" + name
+	}
+	out, err := g.git(ctx, "log", "-1", "--format=%H%x00%an <%ae>%x00%s", revision, "--", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find commit of %s: %w", name, err)
+	}
+	fields := strings.SplitN(strings.TrimRight(string(out), "\n"), "\x00", 3)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("failed to find commit of %s", name)
+	}
+	return &commit{sha: fields[0], author: fields[1], subject: fields[2]}, nil
+}
+
+// git runs a git command in the repository and returns its output.
+func (g *gitSource) git(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", g.repo}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// dirSource reads the config files in a directory tree. Its revision is a
+// hash of the files, and it has no history.
+type dirSource struct {
+	root string
+}
+
+func (d *dirSource) read(ctx context.Context) (*snapshot, error) {
+	snap := &snapshot{}
+	err := filepath.WalkDir(d.root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip hidden files and directories such as .git
+		if p != d.root && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || !strings.HasSuffix(p, configExt) {
+			return nil
+		}
+
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(d.root, p)
+		if err != nil {
+			return err
+		}
+		snap.files = append(snap.files, file{path: filepath.ToSlash(rel), content: string(content)})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", d.root, err)
+	}
+
+	sort.Slice(snap.files, func(i, j int) bool { return snap.files[i].path < snap.files[j].path })
+	h := sha256.New()
+	for _, f := range snap.files {
+		fmt.Fprintf(h, "%s\x00%d\x00%s", f.path, len(f.content), f.content)
+	}
+	snap.revision = hex.EncodeToString(h.Sum(nil))
+	return snap, nil
+}
+
+func (d *dirSource) lastCommit(ctx context.Context, revision, path string) (*commit, error) {
+	return nil, nil
+}
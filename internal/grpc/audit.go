@@ -0,0 +1,74 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+
+	"github.com/google/uuid"
+	"github.com/raskell-io/sentinel-hub/internal/auth"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// AgentAuditLog builds an audit log entry for an action taken by the agent
+// making the call in ctx. The agent is identified by its instance ID and,
+// over mTLS, by its SPIFFE ID or client certificate serial.
+func AgentAuditLog(ctx context.Context, instanceID, action, resourceType, resourceID string, details interface{}) *store.AuditLog {
+	entry := &store.AuditLog{
+		ID:           uuid.New().String(),
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   &resourceID,
+		ActorType:    store.ActorTypeAgent,
+		ActorID:      &instanceID,
+	}
+
+	if details != nil {
+		if b, err := json.Marshal(details); err == nil {
+			entry.Details = b
+		}
+	}
+
+	if identity, ok := auth.SPIFFEIdentityFromContext(ctx); ok && identity != nil {
+		if identity.SPIFFEID != "" {
+			entry.ActorSPIFFEID = &identity.SPIFFEID
+		}
+		if identity.SerialNumber != "" {
+			entry.ActorCertSerial = &identity.SerialNumber
+		}
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		if p.Addr != nil {
+			host, _, err := net.SplitHostPort(p.Addr.String())
+			if err != nil {
+				host = p.Addr.String()
+			}
+			entry.IPAddress = &host
+		}
+		// Client certificates are accepted without SPIFFE authentication too
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && entry.ActorCertSerial == nil &&
+			len(tlsInfo.State.PeerCertificates) > 0 {
+			serial := tlsInfo.State.PeerCertificates[0].SerialNumber.String()
+			entry.ActorCertSerial = &serial
+		}
+	}
+
+	return entry
+}
+
+// auditLog records an action taken by an agent. Failures are logged and
+// do not fail the call.
+func (s *FleetService) auditLog(ctx context.Context, instanceID, action, resourceType, resourceID string, details interface{}) {
+	if err := s.audit.Record(ctx, AgentAuditLog(ctx, instanceID, action, resourceType, resourceID, details)); err != nil {
+		log.Warn().Err(err).
+			Str("instance_id", instanceID).
+			Str("action", action).
+			Str("resource_type", resourceType).
+			Str("resource_id", resourceID).
+			Msg("Failed to create audit log")
+	}
+}
@@ -0,0 +1,299 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/raskell-io/sentinel-hub/internal/events"
+	"github.com/raskell-io/sentinel-hub/internal/kdl"
+	"github.com/raskell-io/sentinel-hub/internal/render"
+	"github.com/raskell-io/sentinel-hub/internal/secrets"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	pb "github.com/raskell-io/sentinel-hub/pkg/hubpb"
+	"github.com/rs/zerolog/log"
+)
+
+// isLocalChange reports whether the hash an agent reports for the desired
+// version is neither that version's nor the one the hub last sent it. A
+// hash matching what was sent means the desired content changed on the hub,
+// as when a secret is rotated, and the agent only needs to refetch.
+func isLocalChange(inst *store.Instance, reportedHash string) bool {
+	return reportedHash != "" && inst.DeliveredConfigHash != nil && reportedHash != *inst.DeliveredConfigHash
+}
+
+// driftAction returns the remediation for drift on an instance with the
+// given labels: that of the matching policy with the highest priority, then
+// the most labels in its selector. Without one, drift is only reported.
+func (s *FleetService) driftAction(ctx context.Context, labels map[string]string) store.DriftAction {
+	policies, err := s.store.ListDriftPolicies(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list drift policies")
+		return store.DriftActionAlert
+	}
+
+	var best *store.DriftPolicy
+	for i := range policies {
+		p := &policies[i]
+		if !selectorMatches(p.Selector, labels) {
+			continue
+		}
+		// Policies are listed by priority, oldest first within one
+		if best == nil || (p.Priority == best.Priority && len(p.Selector) > len(best.Selector)) {
+			best = p
+		}
+	}
+	if best == nil {
+		return store.DriftActionAlert
+	}
+	return best.Action
+}
+
+// selectorMatches reports whether labels carry every label of selector.
+func selectorMatches(selector, labels map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// handleDrift records that an instance runs local changes to its desired
+// version and applies its drift policy. It reports whether the agent should
+// refetch the desired version and returns any actions to send it. The
+// instance's drift is published when first detected.
+func (s *FleetService) handleDrift(ctx context.Context, inst *store.Instance, desired *store.ConfigVersion, expectedHash string, req *pb.HeartbeatRequest) (bool, []*pb.PendingAction) {
+	action := s.driftAction(ctx, inst.Labels)
+
+	adoptErr := ""
+	if action == store.DriftActionAdopt {
+		adoptErr = s.adoptable(ctx, desired)
+	}
+
+	if inst.DriftedAt == nil {
+		now := time.Now().UTC()
+		inst.DriftedAt = &now
+		if err := s.store.SetInstanceDriftedAt(ctx, inst.ID, inst.DriftedAt); err != nil {
+			log.Error().Err(err).Str("instance_id", inst.ID).Msg("Failed to record config drift")
+		}
+
+		driftDetected.WithLabelValues(string(action)).Inc()
+		log.Warn().
+			Str("instance_id", inst.ID).
+			Str("config_id", desired.ConfigID).
+			Int("version", desired.Version).
+			Str("action", string(action)).
+			Msg("Instance runs local changes to its config")
+		if adoptErr != "" {
+			log.Warn().Str("instance_id", inst.ID).Str("reason", adoptErr).Msg("Local config changes cannot be adopted")
+		}
+		s.events.Publish(ctx, events.ConfigDrifted, events.ConfigDriftedData{
+			InstanceID:    inst.ID,
+			Name:          inst.Name,
+			Labels:        inst.Labels,
+			ConfigID:      desired.ConfigID,
+			ConfigVersion: desired.Version,
+			ExpectedHash:  expectedHash,
+			ReportedHash:  req.CurrentConfigHash,
+			Action:        string(action),
+			Reason:        adoptErr,
+		})
+	}
+
+	switch {
+	case action == store.DriftActionRedeploy:
+		return true, nil
+	case action == store.DriftActionAdopt && adoptErr == "":
+		// Content that was already rejected is not asked for again
+		if s.adoptionRejected(inst.ID, req.CurrentConfigHash) {
+			return false, nil
+		}
+		// The agent sends its content once asked; content that changed
+		// again in between is asked for anew
+		if req.CurrentConfigContent == "" || render.Hash(req.CurrentConfigContent) != req.CurrentConfigHash {
+			return false, []*pb.PendingAction{{
+				Type:     pb.ActionType_ACTION_TYPE_REPORT_CONFIG,
+				ActionId: uuid.New().String(),
+			}}
+		}
+		if reason := adoptableContent(req.CurrentConfigContent); reason != "" {
+			s.rejectAdoption(ctx, inst, desired, expectedHash, req.CurrentConfigHash, reason)
+			return false, nil
+		}
+		s.adoptConfig(ctx, inst, desired, req.CurrentConfigContent, req.CurrentConfigHash)
+	}
+	return false, nil
+}
+
+// clearDrift records that an instance no longer runs local changes.
+func (s *FleetService) clearDrift(ctx context.Context, inst *store.Instance) {
+	if inst.DriftedAt == nil {
+		return
+	}
+
+	s.adoptionsMu.Lock()
+	delete(s.rejectedAdoptions, inst.ID)
+	s.adoptionsMu.Unlock()
+
+	inst.DriftedAt = nil
+	if err := s.store.SetInstanceDriftedAt(ctx, inst.ID, nil); err != nil {
+		log.Error().Err(err).Str("instance_id", inst.ID).Msg("Failed to clear config drift")
+		return
+	}
+	log.Info().Str("instance_id", inst.ID).Msg("Instance no longer runs local changes to its config")
+}
+
+// adoptable returns why local changes to a version cannot become a new
+// version, or "" if they can. Agents run templates rendered and secrets
+// resolved, so adopting those would lose the template or store secret
+// values; composed configs only change through their layers.
+func (s *FleetService) adoptable(ctx context.Context, ver *store.ConfigVersion) string {
+	if ver.IsTemplate {
+		return "config is a template"
+	}
+	if len(secrets.References(ver.Content)) > 0 {
+		return "config refers to secrets"
+	}
+	layers, err := s.store.ListConfigLayers(ctx, ver.ConfigID)
+	if err != nil {
+		log.Error().Err(err).Str("config_id", ver.ConfigID).Msg("Failed to list config layers")
+		return "config layers could not be listed"
+	}
+	if len(layers) > 0 {
+		return "config is composed from layers"
+	}
+	return ""
+}
+
+// adoptableContent returns why content reported by an agent cannot become a
+// new version, or "" if it can. Agents are not trusted with what they
+// report: it must be a valid config, and it must not refer to secrets, which
+// would have the hub resolve secrets no user granted the config.
+func adoptableContent(content string) string {
+	if _, err := kdl.Parse(content); err != nil {
+		return "reported config is not valid KDL: " + err.Error()
+	}
+	if len(secrets.References(content)) > 0 {
+		return "reported config refers to secrets"
+	}
+	return ""
+}
+
+// rejectAdoption reports that an instance's local content cannot be
+// adopted and remembers its hash, so the content is not asked for again
+// until it changes.
+func (s *FleetService) rejectAdoption(ctx context.Context, inst *store.Instance, desired *store.ConfigVersion, expectedHash, reportedHash, reason string) {
+	s.adoptionsMu.Lock()
+	s.rejectedAdoptions[inst.ID] = reportedHash
+	s.adoptionsMu.Unlock()
+
+	log.Warn().
+		Str("instance_id", inst.ID).
+		Str("config_id", desired.ConfigID).
+		Str("reason", reason).
+		Msg("Local config changes cannot be adopted")
+	s.events.Publish(ctx, events.ConfigDrifted, events.ConfigDriftedData{
+		InstanceID:    inst.ID,
+		Name:          inst.Name,
+		Labels:        inst.Labels,
+		ConfigID:      desired.ConfigID,
+		ConfigVersion: desired.Version,
+		ExpectedHash:  expectedHash,
+		ReportedHash:  reportedHash,
+		Action:        string(store.DriftActionAdopt),
+		Reason:        reason,
+	})
+}
+
+// adoptionRejected reports whether an instance's local content with the
+// given hash was already rejected.
+func (s *FleetService) adoptionRejected(instanceID, hash string) bool {
+	s.adoptionsMu.Lock()
+	defer s.adoptionsMu.Unlock()
+	return s.rejectedAdoptions[instanceID] == hash
+}
+
+// adoptConfig saves an instance's local content as a new version of its
+// config and makes it the instance's desired version. Other instances keep
+// theirs until it is deployed, unless they follow the config.
+func (s *FleetService) adoptConfig(ctx context.Context, inst *store.Instance, desired *store.ConfigVersion, content, hash string) {
+	cfg, err := s.store.GetConfig(ctx, desired.ConfigID)
+	if err != nil || cfg == nil {
+		log.Error().Err(err).Str("config_id", desired.ConfigID).Msg("Failed to get config to adopt local changes")
+		return
+	}
+
+	summary := fmt.Sprintf("Adopted local changes from instance %s", inst.Name)
+	ver := &store.ConfigVersion{
+		ID:            uuid.New().String(),
+		ConfigID:      cfg.ID,
+		Version:       cfg.CurrentVersion + 1,
+		Content:       content,
+		ContentHash:   hash,
+		ChangeSummary: &summary,
+	}
+	if err := s.store.CreateConfigVersion(ctx, ver); err != nil {
+		log.Error().Err(err).Str("config_id", cfg.ID).Msg("Failed to create config version")
+		return
+	}
+	cfg.CurrentVersion = ver.Version
+	if err := s.store.UpdateConfig(ctx, cfg); err != nil {
+		log.Error().Err(err).Str("config_id", cfg.ID).Msg("Failed to update config")
+		return
+	}
+
+	// The instance only moves to the adopted version if nothing, such as a
+	// deployment, changed its version since it was read
+	advanced, err := s.store.AdvanceInstanceConfigVersion(ctx, inst.ID, cfg.ID, inst.CurrentConfigVersion, ver.Version)
+	if err != nil {
+		log.Error().Err(err).Str("instance_id", inst.ID).Msg("Failed to update instance")
+		return
+	}
+	if advanced {
+		inst.CurrentConfigVersion = &ver.Version
+		inst.DeliveredConfigHash = &hash
+		inst.DriftedAt = nil
+		if err := s.store.SetInstanceDeliveredHash(ctx, inst.ID, hash); err != nil {
+			log.Error().Err(err).Str("instance_id", inst.ID).Msg("Failed to record delivered config hash")
+		}
+		if err := s.store.SetInstanceDriftedAt(ctx, inst.ID, nil); err != nil {
+			log.Error().Err(err).Str("instance_id", inst.ID).Msg("Failed to clear config drift")
+		}
+	} else {
+		log.Warn().
+			Str("instance_id", inst.ID).
+			Str("config_id", cfg.ID).
+			Int("version", ver.Version).
+			Msg("Instance config changed while adopting local changes, adopted version left for deployment")
+	}
+	if _, err := s.resolver.LayerChanged(ctx, cfg.ID, nil); err != nil {
+		log.Warn().Err(err).Str("config_id", cfg.ID).Msg("Some composed configs could not be resolved")
+	}
+
+	log.Info().
+		Str("instance_id", inst.ID).
+		Str("config_id", cfg.ID).
+		Int("version", ver.Version).
+		Msg("Adopted local config changes as a new version")
+	s.auditLog(ctx, inst.ID, "adopt", "config", cfg.ID, map[string]interface{}{
+		"name":        cfg.Name,
+		"version":     ver.Version,
+		"instance_id": inst.ID,
+	})
+}
+
+// recordDelivered remembers the hash of the content last sent to an
+// instance. Failures are logged; they only make drift go unnoticed until
+// the next fetch.
+func (s *FleetService) recordDelivered(ctx context.Context, inst *store.Instance, hash string) {
+	if inst.DeliveredConfigHash != nil && *inst.DeliveredConfigHash == hash {
+		return
+	}
+
+	inst.DeliveredConfigHash = &hash
+	if err := s.store.SetInstanceDeliveredHash(ctx, inst.ID, hash); err != nil {
+		log.Error().Err(err).Str("instance_id", inst.ID).Msg("Failed to record delivered config hash")
+	}
+}
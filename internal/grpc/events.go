@@ -0,0 +1,131 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/raskell-io/sentinel-hub/internal/assign"
+	"github.com/raskell-io/sentinel-hub/internal/events"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// instanceEventType returns the event announcing a change of instance
+// status, if the change is worth one. Deploying is routine, and an instance
+// only comes online when it was not reachable before.
+func instanceEventType(previous, current store.InstanceStatus) (events.Type, bool) {
+	if previous == current {
+		return "", false
+	}
+
+	switch current {
+	case store.InstanceStatusOffline:
+		return events.InstanceOffline, true
+	case store.InstanceStatusDegraded:
+		return events.InstanceDegraded, true
+	case store.InstanceStatusOnline:
+		switch previous {
+		case "", store.InstanceStatusUnknown, store.InstanceStatusOffline, store.InstanceStatusDegraded:
+			return events.InstanceOnline, true
+		}
+	}
+	return "", false
+}
+
+// publishInstanceStatus publishes an event if an instance's status changed
+// from previous to current.
+func (s *FleetService) publishInstanceStatus(ctx context.Context, inst *store.Instance, previous, current store.InstanceStatus, reason string) {
+	eventType, ok := instanceEventType(previous, current)
+	if !ok {
+		return
+	}
+
+	s.events.Publish(ctx, eventType, events.InstanceData{
+		InstanceID:     inst.ID,
+		Name:           inst.Name,
+		Hostname:       inst.Hostname,
+		Status:         string(current),
+		PreviousStatus: string(previous),
+		Reason:         reason,
+		Labels:         inst.Labels,
+	})
+}
+
+// setInstanceStatus updates an instance's status and publishes the change.
+// Failures are logged; status updates never fail the agent's call.
+func (s *FleetService) setInstanceStatus(ctx context.Context, instanceID string, current store.InstanceStatus, reason string) {
+	inst, err := s.store.GetInstance(ctx, instanceID)
+	if err != nil {
+		log.Error().Err(err).Str("instance_id", instanceID).Msg("Failed to get instance")
+		return
+	}
+	if inst == nil {
+		return
+	}
+
+	if err := s.store.UpdateInstanceStatus(ctx, instanceID, current); err != nil {
+		log.Error().Err(err).Str("instance_id", instanceID).Msg("Failed to update instance status")
+		return
+	}
+	s.publishInstanceStatus(ctx, inst, inst.Status, current, reason)
+}
+
+// applyAssignment gives an instance without a config the one config
+// assignments select for it, reporting whether it did. Conflicting
+// assignments are published as an event; other failures are logged and
+// leave the instance unconfigured.
+func (s *FleetService) applyAssignment(ctx context.Context, inst *store.Instance) bool {
+	res, err := assign.Resolve(ctx, s.store, inst.Labels)
+	if err != nil {
+		log.Error().Err(err).Str("instance_id", inst.ID).Msg("Failed to resolve config assignments")
+		return false
+	}
+	if res == nil {
+		return false
+	}
+
+	if len(res.Conflicts) > 0 {
+		ids := make([]string, len(res.Conflicts))
+		for i, a := range res.Conflicts {
+			ids[i] = a.ID
+		}
+		log.Warn().
+			Str("instance_id", inst.ID).
+			Strs("assignments", ids).
+			Msg("Conflicting config assignments, instance left unconfigured")
+		s.events.Publish(ctx, events.AssignmentConflict, events.AssignmentConflictData{
+			InstanceID:    inst.ID,
+			Name:          inst.Name,
+			Labels:        inst.Labels,
+			AssignmentIDs: ids,
+		})
+		return false
+	}
+	if res.Error != "" {
+		log.Warn().
+			Str("instance_id", inst.ID).
+			Str("assignment_id", res.Assignment.ID).
+			Str("error", res.Error).
+			Msg("Config assignment has no usable version")
+		return false
+	}
+
+	inst.CurrentConfigID = &res.ConfigID
+	inst.CurrentConfigVersion = &res.ConfigVersion
+	if err := s.store.UpdateInstance(ctx, inst); err != nil {
+		log.Error().Err(err).Str("instance_id", inst.ID).Msg("Failed to assign config")
+		return false
+	}
+
+	log.Info().
+		Str("instance_id", inst.ID).
+		Str("assignment_id", res.Assignment.ID).
+		Str("config_id", res.ConfigID).
+		Int("config_version", res.ConfigVersion).
+		Msg("Config assigned to instance")
+	s.auditLog(ctx, inst.ID, "assign", "instance", inst.ID, map[string]interface{}{
+		"assignment_id":  res.Assignment.ID,
+		"config_id":      res.ConfigID,
+		"config_version": res.ConfigVersion,
+	})
+	return true
+}
@@ -5,11 +5,18 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/raskell-io/sentinel-hub/internal/audit"
+	"github.com/raskell-io/sentinel-hub/internal/compose"
+	"github.com/raskell-io/sentinel-hub/internal/events"
+	"github.com/raskell-io/sentinel-hub/internal/render"
+	"github.com/raskell-io/sentinel-hub/internal/secrets"
 	"github.com/raskell-io/sentinel-hub/internal/store"
 	pb "github.com/raskell-io/sentinel-hub/pkg/hubpb"
 	"github.com/rs/zerolog/log"
@@ -19,13 +26,18 @@ import (
 )
 
 // DeploymentStatusHandler is called when an agent reports deployment status.
-type DeploymentStatusHandler func(instanceID, deploymentID string, state pb.DeploymentState, message, errorDetails string)
+// ctx carries the agent's identity for audit logging.
+type DeploymentStatusHandler func(ctx context.Context, instanceID, deploymentID string, state pb.DeploymentState, message, errorDetails string)
 
 // FleetService implements the gRPC FleetService for agent communication.
 type FleetService struct {
 	pb.UnimplementedFleetServiceServer
 
-	store *store.Store
+	store    store.Store
+	audit    *audit.Recorder
+	events   *events.Bus
+	secrets  *secrets.Keeper
+	resolver *compose.Resolver
 
 	// Active subscriptions (instance_id -> channel)
 	subscribers   map[string]chan *pb.Event
@@ -35,6 +47,10 @@ type FleetService struct {
 	sessions   map[string]string
 	sessionsMu sync.RWMutex
 
+	// Local content that drift policies could not adopt (instance_id -> hash)
+	rejectedAdoptions map[string]string
+	adoptionsMu       sync.Mutex
+
 	// Configuration
 	heartbeatInterval time.Duration
 	sessionTTL        time.Duration
@@ -45,16 +61,41 @@ type FleetService struct {
 }
 
 // NewFleetService creates a new FleetService instance.
-func NewFleetService(s *store.Store) *FleetService {
+func NewFleetService(s store.Store) *FleetService {
 	return &FleetService{
 		store:             s,
+		audit:             audit.NewRecorder(s, audit.RecorderConfig{}),
+		resolver:          compose.NewResolver(s),
 		subscribers:       make(map[string]chan *pb.Event),
 		sessions:          make(map[string]string),
+		rejectedAdoptions: make(map[string]string),
 		heartbeatInterval: 30 * time.Second,
 		sessionTTL:        24 * time.Hour,
 	}
 }
 
+// SetAuditRecorder sets the recorder audit logs are written through.
+func (s *FleetService) SetAuditRecorder(r *audit.Recorder) {
+	s.audit = r
+}
+
+// SetEventBus sets the bus instance status events are published on.
+func (s *FleetService) SetEventBus(b *events.Bus) {
+	s.events = b
+}
+
+// SetSecretKeeper sets the keeper secret references in configs are
+// resolved with. Without one, configs with references are not delivered.
+func (s *FleetService) SetSecretKeeper(k *secrets.Keeper) {
+	s.secrets = k
+}
+
+// SetConfigResolver sets the resolver that keeps layered configs up to
+// date when local changes are adopted as a new version.
+func (s *FleetService) SetConfigResolver(r *compose.Resolver) {
+	s.resolver = r
+}
+
 // generateToken creates a secure random token.
 func generateToken() (string, error) {
 	bytes := make([]byte, 32)
@@ -109,6 +150,7 @@ func (s *FleetService) Register(ctx context.Context, req *pb.RegisterRequest) (*
 	now := time.Now().UTC()
 	if existing != nil {
 		// Update existing instance
+		previous := existing.Status
 		existing.Hostname = req.Hostname
 		existing.AgentVersion = req.AgentVersion
 		existing.SentinelVersion = req.SentinelVersion
@@ -121,6 +163,7 @@ func (s *FleetService) Register(ctx context.Context, req *pb.RegisterRequest) (*
 			log.Error().Err(err).Str("instance_id", req.InstanceId).Msg("Failed to update instance")
 			return nil, status.Error(codes.Internal, "failed to update instance")
 		}
+		s.publishInstanceStatus(ctx, existing, previous, existing.Status, "registered")
 	} else {
 		// Create new instance
 		inst := &store.Instance{
@@ -139,6 +182,7 @@ func (s *FleetService) Register(ctx context.Context, req *pb.RegisterRequest) (*
 			log.Error().Err(err).Str("instance_id", req.InstanceId).Msg("Failed to create instance")
 			return nil, status.Error(codes.Internal, "failed to create instance")
 		}
+		s.publishInstanceStatus(ctx, inst, "", inst.Status, "registered")
 	}
 
 	// Generate session token
@@ -153,14 +197,29 @@ func (s *FleetService) Register(ctx context.Context, req *pb.RegisterRequest) (*
 	s.sessions[hashToken(token)] = req.InstanceId
 	s.sessionsMu.Unlock()
 
-	// Get latest config if any is assigned
+	s.auditLog(ctx, req.InstanceId, "register", "instance", req.InstanceId, map[string]string{
+		"instance_name":    req.InstanceName,
+		"hostname":         req.Hostname,
+		"agent_version":    req.AgentVersion,
+		"sentinel_version": req.SentinelVersion,
+	})
+
+	// Get the desired config if any is assigned. Instances without one get
+	// the config assignments select for them, which they fetch on their
+	// first heartbeat.
 	var configVersion, configHash string
 	inst, _ := s.store.GetInstance(ctx, req.InstanceId)
-	if inst != nil && inst.CurrentConfigID != nil {
-		ver, err := s.store.GetLatestConfigVersion(ctx, *inst.CurrentConfigID)
+	assigned := false
+	if inst != nil && inst.CurrentConfigID == nil {
+		assigned = s.applyAssignment(ctx, inst)
+	}
+	if inst != nil && inst.CurrentConfigID != nil && !assigned {
+		ver, _, err := s.desiredConfigVersion(ctx, inst)
 		if err == nil && ver != nil {
 			configVersion = fmt.Sprintf("%d", ver.Version)
-			configHash = ver.ContentHash
+			if _, hash, err := s.renderConfig(ctx, inst, ver); err == nil {
+				configHash = hash
+			}
 		}
 	}
 
@@ -202,6 +261,7 @@ func (s *FleetService) Heartbeat(ctx context.Context, req *pb.HeartbeatRequest)
 
 	// Update instance status
 	now := time.Now().UTC()
+	previous := inst.Status
 	inst.LastSeenAt = &now
 
 	// Map proto status to store status
@@ -213,25 +273,73 @@ func (s *FleetService) Heartbeat(ctx context.Context, req *pb.HeartbeatRequest)
 	case pb.InstanceState_INSTANCE_STATE_UNHEALTHY:
 		inst.Status = store.InstanceStatusOffline
 	}
+	if req.CurrentConfigHash != "" {
+		inst.ReportedConfigHash = &req.CurrentConfigHash
+	}
 
-	if err := s.store.UpdateInstance(ctx, inst); err != nil {
+	// Only the heartbeat's own fields are written, so a config version set
+	// concurrently, such as by a deployment, is not overwritten
+	if err := s.store.UpdateInstanceHeartbeat(ctx, inst.ID, inst.Status, inst.ReportedConfigHash); err != nil {
 		log.Error().Err(err).Str("instance_id", req.InstanceId).Msg("Failed to update instance")
 		return nil, status.Error(codes.Internal, "failed to update instance")
 	}
+	s.publishInstanceStatus(ctx, inst, previous, inst.Status, "heartbeat")
 
 	// Check if config update is available
 	var configUpdateAvailable bool
 	var latestConfigVersion string
 	var actions []*pb.PendingAction
 
-	if inst.CurrentConfigID != nil {
-		latestVer, err := s.store.GetLatestConfigVersion(ctx, *inst.CurrentConfigID)
-		if err == nil && latestVer != nil {
-			latestConfigVersion = fmt.Sprintf("%d", latestVer.Version)
+	// Reconcile against the version the instance should run rather than the
+	// newest one, so new versions only roll out through deployments. While
+	// a deployment is changing the instance's config, it is left alone.
+	if inst.CurrentConfigID != nil && !s.inActiveDeployment(ctx, inst.ID) {
+		desired, following, err := s.desiredConfigVersion(ctx, inst)
+		if err != nil {
+			log.Error().Err(err).Str("instance_id", req.InstanceId).Msg("Failed to get desired config version")
+		}
+		if err == nil && desired != nil {
+			latestConfigVersion = fmt.Sprintf("%d", desired.Version)
+
+			// Compare with agent's current version. Templates are compared
+			// by the hash of the content rendered for this instance; if it
+			// cannot be rendered, fetching would fail too, so only the
+			// version is compared.
+			hashMismatch := false
+			expectedHash := ""
+			if _, hash, err := s.renderConfig(ctx, inst, desired); err == nil {
+				expectedHash = hash
+				hashMismatch = req.CurrentConfigHash != hash
+			}
 
-			// Compare with agent's current version
-			if req.CurrentConfigVersion != latestConfigVersion ||
-				req.CurrentConfigHash != latestVer.ContentHash {
+			// Local changes to the desired version are left to the
+			// instance's drift policy rather than overwritten
+			refetch := hashMismatch
+			if req.CurrentConfigVersion == latestConfigVersion && hashMismatch && isLocalChange(inst, req.CurrentConfigHash) {
+				var driftActions []*pb.PendingAction
+				refetch, driftActions = s.handleDrift(ctx, inst, desired, expectedHash, req)
+				actions = append(actions, driftActions...)
+			} else {
+				s.clearDrift(ctx, inst)
+			}
+			if expectedHash != "" && !hashMismatch {
+				// The agent runs what the hub would send, such as after
+				// a deployment
+				s.recordDelivered(ctx, inst, expectedHash)
+			}
+
+			if req.CurrentConfigVersion == latestConfigVersion && !hashMismatch &&
+				(inst.CurrentConfigVersion == nil || (following && *inst.CurrentConfigVersion != desired.Version)) {
+				// Record the version the instance caught up to, unless the
+				// version was changed since the instance was read
+				advanced, err := s.store.AdvanceInstanceConfigVersion(ctx, inst.ID, *inst.CurrentConfigID, inst.CurrentConfigVersion, desired.Version)
+				if err != nil {
+					log.Error().Err(err).Str("instance_id", req.InstanceId).Msg("Failed to update instance")
+				} else if advanced {
+					inst.CurrentConfigVersion = &desired.Version
+				}
+			}
+			if req.CurrentConfigVersion != latestConfigVersion || refetch {
 				configUpdateAvailable = true
 				actions = append(actions, &pb.PendingAction{
 					Type:     pb.ActionType_ACTION_TYPE_FETCH_CONFIG,
@@ -274,9 +382,11 @@ func (s *FleetService) Deregister(ctx context.Context, req *pb.DeregisterRequest
 		Msg("Agent deregistration request")
 
 	// Update instance status to offline
-	if err := s.store.UpdateInstanceStatus(ctx, req.InstanceId, store.InstanceStatusOffline); err != nil {
-		log.Error().Err(err).Str("instance_id", req.InstanceId).Msg("Failed to update instance status")
+	reason := "deregistered"
+	if req.Reason != "" {
+		reason += ": " + req.Reason
 	}
+	s.setInstanceStatus(ctx, req.InstanceId, store.InstanceStatusOffline, reason)
 
 	// Remove session
 	s.sessionsMu.Lock()
@@ -291,6 +401,8 @@ func (s *FleetService) Deregister(ctx context.Context, req *pb.DeregisterRequest
 	}
 	s.subscribersMu.Unlock()
 
+	s.auditLog(ctx, req.InstanceId, "deregister", "instance", req.InstanceId, map[string]string{"reason": req.Reason})
+
 	return &pb.DeregisterResponse{Acknowledged: true}, nil
 }
 
@@ -327,8 +439,8 @@ func (s *FleetService) GetConfig(ctx context.Context, req *pb.GetConfigRequest)
 		fmt.Sscanf(req.Version, "%d", &versionNum)
 		ver, err = s.store.GetConfigVersion(ctx, *inst.CurrentConfigID, versionNum)
 	} else {
-		// Latest version
-		ver, err = s.store.GetLatestConfigVersion(ctx, *inst.CurrentConfigID)
+		// The version the instance should run
+		ver, _, err = s.desiredConfigVersion(ctx, inst)
 	}
 
 	if err != nil {
@@ -338,16 +450,23 @@ func (s *FleetService) GetConfig(ctx context.Context, req *pb.GetConfigRequest)
 		return nil, status.Error(codes.NotFound, "configuration version not found")
 	}
 
+	content, hash, err := s.renderConfig(ctx, inst, ver)
+	if err != nil {
+		return nil, err
+	}
+	s.recordDelivered(ctx, inst, hash)
+
 	log.Info().
 		Str("instance_id", req.InstanceId).
 		Str("config_id", *inst.CurrentConfigID).
 		Int("version", ver.Version).
+		Bool("template", ver.IsTemplate).
 		Msg("Config fetched by agent")
 
 	return &pb.GetConfigResponse{
 		Version:   fmt.Sprintf("%d", ver.Version),
-		Hash:      ver.ContentHash,
-		Content:   ver.Content,
+		Hash:      hash,
+		Content:   content,
 		CreatedAt: timestamppb.New(ver.CreatedAt),
 	}, nil
 }
@@ -355,11 +474,29 @@ func (s *FleetService) GetConfig(ctx context.Context, req *pb.GetConfigRequest)
 // GetConfigVersion returns a specific configuration version.
 func (s *FleetService) GetConfigVersion(ctx context.Context, req *pb.GetConfigVersionRequest) (*pb.GetConfigVersionResponse, error) {
 	// Validate token
-	_, err := s.validateToken(req.Token)
+	instanceID, err := s.validateToken(req.Token)
 	if err != nil {
 		return nil, err
 	}
 
+	inst, err := s.store.GetInstance(ctx, instanceID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to get instance")
+	}
+	if inst == nil {
+		return nil, status.Error(codes.NotFound, "instance not found")
+	}
+
+	// Versions are rendered with the instance's secrets resolved, so an
+	// agent only gets configs it runs or is being deployed
+	allowed, err := s.mayFetchConfig(ctx, inst, req.ConfigId)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to check configuration access")
+	}
+	if !allowed {
+		return nil, status.Error(codes.PermissionDenied, "configuration is not assigned to this instance")
+	}
+
 	ver, err := s.store.GetConfigVersion(ctx, req.ConfigId, int(req.VersionNumber))
 	if err != nil {
 		return nil, status.Error(codes.Internal, "failed to get configuration version")
@@ -368,6 +505,19 @@ func (s *FleetService) GetConfigVersion(ctx context.Context, req *pb.GetConfigVe
 		return nil, status.Error(codes.NotFound, "configuration version not found")
 	}
 
+	content, hash := ver.Content, ver.ContentHash
+	if ver.IsTemplate || len(secrets.References(ver.Content)) > 0 {
+		if content, hash, err = s.renderConfig(ctx, inst, ver); err != nil {
+			return nil, err
+		}
+	}
+	// Only content of the desired version tells local changes to it apart
+	// from content the hub sent; other versions, such as one a deployment
+	// is rolling out, are recorded once the agent reports running them
+	if s.isDesiredVersion(ctx, inst, ver) {
+		s.recordDelivered(ctx, inst, hash)
+	}
+
 	var changeSummary string
 	if ver.ChangeSummary != nil {
 		changeSummary = *ver.ChangeSummary
@@ -376,13 +526,164 @@ func (s *FleetService) GetConfigVersion(ctx context.Context, req *pb.GetConfigVe
 	return &pb.GetConfigVersionResponse{
 		ConfigId:      ver.ConfigID,
 		VersionNumber: int32(ver.Version),
-		Hash:          ver.ContentHash,
-		Content:       ver.Content,
+		Hash:          hash,
+		Content:       content,
 		ChangeSummary: changeSummary,
 		CreatedAt:     timestamppb.New(ver.CreatedAt),
 	}, nil
 }
 
+// mayFetchConfig reports whether an instance may fetch versions of a
+// config: the one it runs, or one an unfinished deployment targets it with,
+// since deployments only record the new config once the instance is done.
+func (s *FleetService) mayFetchConfig(ctx context.Context, inst *store.Instance, configID string) (bool, error) {
+	if inst.CurrentConfigID != nil && *inst.CurrentConfigID == configID {
+		return true, nil
+	}
+
+	for _, st := range []store.DeploymentStatus{store.DeploymentStatusPending, store.DeploymentStatusInProgress} {
+		deployments, err := s.store.ListDeployments(ctx, store.ListDeploymentsOptions{Status: st})
+		if err != nil {
+			return false, err
+		}
+		for _, dep := range deployments {
+			if dep.ConfigID != configID {
+				continue
+			}
+			for _, id := range dep.TargetInstances {
+				if id == inst.ID {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+// renderConfig returns a config version as delivered to an instance,
+// rendering templates with the instance's data and resolving secret
+// references. The hash covers the resolved content, so agents refetch when
+// a referenced secret changes.
+func (s *FleetService) renderConfig(ctx context.Context, inst *store.Instance, ver *store.ConfigVersion) (string, string, error) {
+	content, hash, err := render.ForInstance(ctx, s.store, inst, ver)
+	if errors.Is(err, render.ErrTemplate) {
+		log.Warn().Err(err).
+			Str("instance_id", inst.ID).
+			Str("config_id", ver.ConfigID).
+			Int("version", ver.Version).
+			Msg("Failed to render config template")
+		return "", "", status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if err != nil {
+		log.Error().Err(err).Str("instance_id", inst.ID).Msg("Failed to load template variables")
+		return "", "", status.Error(codes.Internal, "failed to render configuration")
+	}
+
+	// A template must not refer to secrets it did not name before rendering,
+	// such as through a variable: only those names were checked when the
+	// version was created.
+	if ver.IsTemplate {
+		named, err := secrets.TemplateReferences(ver.Content)
+		if err == nil {
+			for _, name := range secrets.References(content) {
+				if !slices.Contains(named, name) {
+					err = fmt.Errorf("%w: secret %s is not named in the template", secrets.ErrReference, name)
+					break
+				}
+			}
+		}
+		if err != nil {
+			log.Warn().Err(err).
+				Str("instance_id", inst.ID).
+				Str("config_id", ver.ConfigID).
+				Int("version", ver.Version).
+				Msg("Refused config template secret reference")
+			return "", "", status.Error(codes.FailedPrecondition, err.Error())
+		}
+	}
+
+	if s.secrets == nil {
+		if len(secrets.References(content)) > 0 {
+			return "", "", status.Error(codes.FailedPrecondition, "configuration refers to secrets, but secrets are not configured")
+		}
+		return content, hash, nil
+	}
+	resolved, err := s.secrets.Resolve(ctx, content)
+	if err != nil {
+		// The error names the secret, never its value
+		log.Warn().Err(err).
+			Str("instance_id", inst.ID).
+			Str("config_id", ver.ConfigID).
+			Int("version", ver.Version).
+			Msg("Failed to resolve config secrets")
+		if errors.Is(err, secrets.ErrReference) {
+			return "", "", status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return "", "", status.Error(codes.Internal, "failed to resolve configuration secrets")
+	}
+	if resolved != content {
+		return resolved, render.Hash(resolved), nil
+	}
+	return content, hash, nil
+}
+
+// desiredConfigVersion returns the version of its config an instance
+// should run: the version its last deployment or assignment recorded, or
+// the config's latest version if the config or the instance follows it,
+// which following reports. Instances with no recorded version get the
+// latest version too.
+func (s *FleetService) desiredConfigVersion(ctx context.Context, inst *store.Instance) (ver *store.ConfigVersion, following bool, err error) {
+	if inst.CurrentConfigID == nil {
+		return nil, false, nil
+	}
+
+	following = inst.AutoFollow
+	if !following {
+		cfg, err := s.store.GetConfig(ctx, *inst.CurrentConfigID)
+		if err != nil {
+			return nil, false, err
+		}
+		following = cfg != nil && cfg.AutoFollow
+	}
+
+	if following || inst.CurrentConfigVersion == nil {
+		ver, err = s.store.GetLatestConfigVersion(ctx, *inst.CurrentConfigID)
+	} else {
+		ver, err = s.store.GetConfigVersion(ctx, *inst.CurrentConfigID, *inst.CurrentConfigVersion)
+	}
+	return ver, following, err
+}
+
+// isDesiredVersion reports whether ver is the version of its config an
+// instance should run.
+func (s *FleetService) isDesiredVersion(ctx context.Context, inst *store.Instance, ver *store.ConfigVersion) bool {
+	desired, _, err := s.desiredConfigVersion(ctx, inst)
+	if err != nil {
+		log.Error().Err(err).Str("instance_id", inst.ID).Msg("Failed to get desired config version")
+		return false
+	}
+	return desired != nil && desired.ConfigID == ver.ConfigID && desired.Version == ver.Version
+}
+
+// inActiveDeployment reports whether a running deployment targets an
+// instance. Its agent may already run the deployment's version before the
+// instance's desired version is updated.
+func (s *FleetService) inActiveDeployment(ctx context.Context, instanceID string) bool {
+	deps, err := s.store.ListDeployments(ctx, store.ListDeploymentsOptions{Status: store.DeploymentStatusInProgress})
+	if err != nil {
+		log.Error().Err(err).Str("instance_id", instanceID).Msg("Failed to list active deployments")
+		return true
+	}
+	for _, dep := range deps {
+		for _, id := range dep.TargetInstances {
+			if id == instanceID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // Subscribe creates a server-streaming connection for push events.
 func (s *FleetService) Subscribe(req *pb.SubscribeRequest, stream pb.FleetService_SubscribeServer) error {
 	// Validate token
@@ -487,8 +788,15 @@ func (s *FleetService) AckDeployment(ctx context.Context, req *pb.AckDeploymentR
 	var instruction string
 	if req.Accepted {
 		instruction = "proceed with deployment"
+		s.auditLog(ctx, req.InstanceId, "ack", "deployment", req.DeploymentId, map[string]string{
+			"instance_id": req.InstanceId,
+		})
 	} else {
 		instruction = "deployment rejected by agent"
+		s.auditLog(ctx, req.InstanceId, "reject", "deployment", req.DeploymentId, map[string]string{
+			"instance_id": req.InstanceId,
+			"reason":      req.RejectionReason,
+		})
 	}
 
 	return &pb.AckDeploymentResponse{
@@ -517,15 +825,16 @@ func (s *FleetService) ReportDeploymentStatus(ctx context.Context, req *pb.Deplo
 		Msg("Deployment status report received")
 
 	// Update instance status based on deployment state
+	reason := "deployment " + req.DeploymentId
 	switch req.State {
 	case pb.DeploymentState_DEPLOYMENT_STATE_IN_PROGRESS:
-		s.store.UpdateInstanceStatus(ctx, req.InstanceId, store.InstanceStatusDeploying)
+		s.setInstanceStatus(ctx, req.InstanceId, store.InstanceStatusDeploying, reason)
 	case pb.DeploymentState_DEPLOYMENT_STATE_COMPLETED:
-		s.store.UpdateInstanceStatus(ctx, req.InstanceId, store.InstanceStatusOnline)
+		s.setInstanceStatus(ctx, req.InstanceId, store.InstanceStatusOnline, reason)
 	case pb.DeploymentState_DEPLOYMENT_STATE_FAILED:
-		s.store.UpdateInstanceStatus(ctx, req.InstanceId, store.InstanceStatusDegraded)
+		s.setInstanceStatus(ctx, req.InstanceId, store.InstanceStatusDegraded, reason)
 	case pb.DeploymentState_DEPLOYMENT_STATE_ROLLED_BACK:
-		s.store.UpdateInstanceStatus(ctx, req.InstanceId, store.InstanceStatusOnline)
+		s.setInstanceStatus(ctx, req.InstanceId, store.InstanceStatusOnline, reason)
 	}
 
 	// Notify orchestrator of status update
@@ -534,7 +843,7 @@ func (s *FleetService) ReportDeploymentStatus(ctx context.Context, req *pb.Deplo
 	s.deploymentStatusMu.RUnlock()
 
 	if handler != nil {
-		handler(req.InstanceId, req.DeploymentId, req.State, req.Message, req.ErrorDetails)
+		handler(ctx, req.InstanceId, req.DeploymentId, req.State, req.Message, req.ErrorDetails)
 	}
 
 	return &pb.DeploymentStatusResponse{
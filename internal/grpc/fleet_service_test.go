@@ -2,16 +2,25 @@ package grpc
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/raskell-io/sentinel-hub/internal/auth"
+	"github.com/raskell-io/sentinel-hub/internal/events"
+	"github.com/raskell-io/sentinel-hub/internal/render"
+	"github.com/raskell-io/sentinel-hub/internal/secrets"
 	"github.com/raskell-io/sentinel-hub/internal/store"
 	pb "github.com/raskell-io/sentinel-hub/pkg/hubpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // setupTestStore creates a temporary SQLite store for testing.
-func setupTestStore(t *testing.T) *store.Store {
+func setupTestStore(t *testing.T) store.Store {
 	t.Helper()
 
 	tmpFile, err := os.CreateTemp("", "hub-test-*.db")
@@ -223,6 +232,68 @@ func TestFleetService_Register_ExistingInstance(t *testing.T) {
 	}
 }
 
+func TestFleetService_Register_Assignments(t *testing.T) {
+	s := setupTestStore(t)
+	fs := NewFleetService(s)
+	bus := events.NewBus()
+	var conflicts []events.AssignmentConflictData
+	bus.Subscribe(func(ctx context.Context, e events.Event) {
+		if data, ok := e.Data.(events.AssignmentConflictData); ok {
+			conflicts = append(conflicts, data)
+		}
+	})
+	fs.SetEventBus(bus)
+
+	ctx := context.Background()
+	prod := &store.Config{Name: "prod"}
+	s.CreateConfig(ctx, prod)
+	for v := 1; v <= 2; v++ {
+		s.CreateConfigVersion(ctx, &store.ConfigVersion{ConfigID: prod.ID, Version: v, Content: "server {}"})
+	}
+	s.ApproveConfigVersion(ctx, prod.ID, 1)
+	s.CreateConfigAssignment(ctx, &store.ConfigAssignment{Name: "prod", ConfigID: prod.ID, Selector: map[string]string{"env": "prod"}})
+
+	resp, err := fs.Register(ctx, &pb.RegisterRequest{InstanceId: "inst-1", InstanceName: "auto-1", Labels: map[string]string{"env": "prod"}})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	// The agent has yet to fetch its config, so none is reported as running
+	if resp.ConfigVersion != "" || resp.ConfigHash != "" {
+		t.Errorf("expected no running config, got %q %q", resp.ConfigVersion, resp.ConfigHash)
+	}
+	inst, _ := s.GetInstance(ctx, "inst-1")
+	if inst.CurrentConfigID == nil || *inst.CurrentConfigID != prod.ID || *inst.CurrentConfigVersion != 1 {
+		t.Errorf("expected the latest approved version of prod, got %v %v", inst.CurrentConfigID, inst.CurrentConfigVersion)
+	}
+
+	// A conflicting assignment leaves new instances unconfigured
+	other := &store.Config{Name: "other"}
+	s.CreateConfig(ctx, other)
+	s.CreateConfigVersion(ctx, &store.ConfigVersion{ConfigID: other.ID, Version: 1, Content: "server {}"})
+	version := 1
+	s.CreateConfigAssignment(ctx, &store.ConfigAssignment{Name: "region", ConfigID: other.ID, Version: &version, Selector: map[string]string{"region": "eu"}})
+
+	if _, err := fs.Register(ctx, &pb.RegisterRequest{InstanceId: "inst-2", InstanceName: "auto-2", Labels: map[string]string{"env": "prod", "region": "eu"}}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	inst, _ = s.GetInstance(ctx, "inst-2")
+	if inst.CurrentConfigID != nil {
+		t.Errorf("expected no config on conflict, got %v", *inst.CurrentConfigID)
+	}
+	if len(conflicts) != 1 || conflicts[0].InstanceID != "inst-2" || len(conflicts[0].AssignmentIDs) != 2 {
+		t.Errorf("expected one assignment.conflict event, got %+v", conflicts)
+	}
+
+	// Instances that already have a config keep it
+	if _, err := fs.Register(ctx, &pb.RegisterRequest{InstanceId: "inst-1", InstanceName: "auto-1", Labels: map[string]string{"env": "prod", "region": "eu"}}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	inst, _ = s.GetInstance(ctx, "inst-1")
+	if *inst.CurrentConfigID != prod.ID {
+		t.Errorf("expected inst-1 to keep its config, got %v", *inst.CurrentConfigID)
+	}
+}
+
 func TestFleetService_Register_ValidationErrors(t *testing.T) {
 	s := setupTestStore(t)
 	fs := NewFleetService(s)
@@ -285,6 +356,451 @@ func TestFleetService_Heartbeat(t *testing.T) {
 	}
 }
 
+func TestFleetService_Heartbeat_DesiredVersion(t *testing.T) {
+	s := setupTestStore(t)
+	fs := NewFleetService(s)
+	ctx := context.Background()
+
+	cfg := &store.Config{Name: "edge"}
+	s.CreateConfig(ctx, cfg)
+	for v := 1; v <= 2; v++ {
+		s.CreateConfigVersion(ctx, &store.ConfigVersion{ConfigID: cfg.ID, Version: v, Content: fmt.Sprintf("server { v %d; }", v)})
+	}
+	v1, _ := s.GetConfigVersion(ctx, cfg.ID, 1)
+	v2, _ := s.GetConfigVersion(ctx, cfg.ID, 2)
+
+	regResp, _ := fs.Register(ctx, &pb.RegisterRequest{InstanceId: "inst-1", InstanceName: "inst-1"})
+	inst, _ := s.GetInstance(ctx, "inst-1")
+	version := 1
+	inst.CurrentConfigID, inst.CurrentConfigVersion = &cfg.ID, &version
+	s.UpdateInstance(ctx, inst)
+
+	heartbeat := func(ver *store.ConfigVersion) *pb.HeartbeatResponse {
+		t.Helper()
+		resp, err := fs.Heartbeat(ctx, &pb.HeartbeatRequest{
+			InstanceId:           "inst-1",
+			Token:                regResp.Token,
+			Status:               &pb.InstanceStatus{State: pb.InstanceState_INSTANCE_STATE_HEALTHY},
+			CurrentConfigVersion: fmt.Sprintf("%d", ver.Version),
+			CurrentConfigHash:    ver.ContentHash,
+		})
+		if err != nil {
+			t.Fatalf("Heartbeat failed: %v", err)
+		}
+		return resp
+	}
+
+	// A newer version is not pushed outside a deployment
+	if resp := heartbeat(v1); resp.ConfigUpdateAvailable || resp.LatestConfigVersion != "1" {
+		t.Errorf("expected no update at the desired version, got %+v", resp)
+	}
+	// An agent on another version is brought back to the desired one
+	if resp := heartbeat(v2); !resp.ConfigUpdateAvailable || resp.LatestConfigVersion != "1" {
+		t.Errorf("expected an update back to version 1, got %+v", resp)
+	}
+
+	// Nothing is reconciled while a deployment targets the instance
+	dep := &store.Deployment{ConfigID: cfg.ID, ConfigVersion: 2, TargetInstances: []string{"inst-1"}, Strategy: store.DeploymentStrategyAllAtOnce, Status: store.DeploymentStatusInProgress}
+	s.CreateDeployment(ctx, dep)
+	if resp := heartbeat(v2); resp.ConfigUpdateAvailable {
+		t.Errorf("expected no update during a deployment, got %+v", resp)
+	}
+	dep.Status = store.DeploymentStatusCancelled
+	s.UpdateDeployment(ctx, dep)
+
+	// Configs that opt in are followed to their latest version
+	cfg.AutoFollow = true
+	s.UpdateConfig(ctx, cfg)
+	if resp := heartbeat(v1); !resp.ConfigUpdateAvailable || resp.LatestConfigVersion != "2" {
+		t.Errorf("expected an update to the latest version, got %+v", resp)
+	}
+	heartbeat(v2)
+	inst, _ = s.GetInstance(ctx, "inst-1")
+	if *inst.CurrentConfigVersion != 2 {
+		t.Errorf("expected the followed version to be recorded, got %d", *inst.CurrentConfigVersion)
+	}
+
+	// So are instances that opt in
+	s.CreateConfigVersion(ctx, &store.ConfigVersion{ConfigID: cfg.ID, Version: 3, Content: "server { v 3; }"})
+	cfg.AutoFollow = false
+	s.UpdateConfig(ctx, cfg)
+	if resp := heartbeat(v2); resp.ConfigUpdateAvailable {
+		t.Errorf("expected no update after opting out, got %+v", resp)
+	}
+	inst.AutoFollow = true
+	s.UpdateInstance(ctx, inst)
+	if resp := heartbeat(v2); !resp.ConfigUpdateAvailable || resp.LatestConfigVersion != "3" {
+		t.Errorf("expected an update for a following instance, got %+v", resp)
+	}
+}
+
+// racingStore runs a write just before the next heartbeat or config version
+// advance is recorded, as if another writer changed the instance while the
+// heartbeat was processed.
+type racingStore struct {
+	store.Store
+	beforeHeartbeat func()
+	beforeAdvance   func()
+}
+
+func (r *racingStore) UpdateInstanceHeartbeat(ctx context.Context, id string, status store.InstanceStatus, reportedConfigHash *string) error {
+	if r.beforeHeartbeat != nil {
+		r.beforeHeartbeat()
+		r.beforeHeartbeat = nil
+	}
+	return r.Store.UpdateInstanceHeartbeat(ctx, id, status, reportedConfigHash)
+}
+
+func (r *racingStore) AdvanceInstanceConfigVersion(ctx context.Context, id, configID string, from *int, to int) (bool, error) {
+	if r.beforeAdvance != nil {
+		r.beforeAdvance()
+		r.beforeAdvance = nil
+	}
+	return r.Store.AdvanceInstanceConfigVersion(ctx, id, configID, from, to)
+}
+
+func TestFleetService_Heartbeat_ConcurrentVersionChange(t *testing.T) {
+	s := setupTestStore(t)
+	rs := &racingStore{Store: s}
+	fs := NewFleetService(rs)
+	ctx := context.Background()
+
+	cfg := &store.Config{Name: "edge"}
+	s.CreateConfig(ctx, cfg)
+	for v := 1; v <= 3; v++ {
+		content := fmt.Sprintf("server { v %d; }", v)
+		s.CreateConfigVersion(ctx, &store.ConfigVersion{ConfigID: cfg.ID, Version: v, Content: content, ContentHash: render.Hash(content)})
+	}
+	v1, _ := s.GetConfigVersion(ctx, cfg.ID, 1)
+	v3, _ := s.GetConfigVersion(ctx, cfg.ID, 3)
+
+	regResp, _ := fs.Register(ctx, &pb.RegisterRequest{InstanceId: "inst-1", InstanceName: "inst-1"})
+	inst, _ := s.GetInstance(ctx, "inst-1")
+	version := 1
+	inst.CurrentConfigID, inst.CurrentConfigVersion = &cfg.ID, &version
+	s.UpdateInstance(ctx, inst)
+
+	setVersion := func(v int) func() {
+		return func() {
+			inst, _ := s.GetInstance(ctx, "inst-1")
+			inst.CurrentConfigVersion = &v
+			s.UpdateInstance(ctx, inst)
+		}
+	}
+	heartbeat := func(ver *store.ConfigVersion) *pb.HeartbeatResponse {
+		t.Helper()
+		resp, err := fs.Heartbeat(ctx, &pb.HeartbeatRequest{
+			InstanceId:           "inst-1",
+			Token:                regResp.Token,
+			Status:               &pb.InstanceStatus{State: pb.InstanceState_INSTANCE_STATE_HEALTHY},
+			CurrentConfigVersion: fmt.Sprintf("%d", ver.Version),
+			CurrentConfigHash:    ver.ContentHash,
+		})
+		if err != nil {
+			t.Fatalf("Heartbeat failed: %v", err)
+		}
+		return resp
+	}
+	recorded := func() int {
+		t.Helper()
+		inst, _ := s.GetInstance(ctx, "inst-1")
+		return *inst.CurrentConfigVersion
+	}
+
+	// A version set during the heartbeat, such as by a deployment, is kept
+	rs.beforeHeartbeat = setVersion(2)
+	heartbeat(v1)
+	if got := recorded(); got != 2 {
+		t.Fatalf("expected the concurrently set version to be kept, got %d", got)
+	}
+	if resp := heartbeat(v1); !resp.ConfigUpdateAvailable || resp.LatestConfigVersion != "2" {
+		t.Errorf("expected an update to version 2, got %+v", resp)
+	}
+
+	// A following instance only moves forward from the version it was read at
+	cfg.AutoFollow = true
+	s.UpdateConfig(ctx, cfg)
+	setVersion(1)()
+	rs.beforeAdvance = setVersion(2)
+	heartbeat(v3)
+	if got := recorded(); got != 2 {
+		t.Errorf("expected the concurrently set version to be kept, got %d", got)
+	}
+	heartbeat(v3)
+	if got := recorded(); got != 3 {
+		t.Errorf("expected the followed version to be recorded, got %d", got)
+	}
+}
+
+func TestFleetService_Heartbeat_Drift(t *testing.T) {
+	s := setupTestStore(t)
+	fs := NewFleetService(s)
+	bus := events.NewBus()
+	var drifts []events.ConfigDriftedData
+	bus.Subscribe(func(ctx context.Context, e events.Event) {
+		if data, ok := e.Data.(events.ConfigDriftedData); ok {
+			drifts = append(drifts, data)
+		}
+	})
+	fs.SetEventBus(bus)
+	ctx := context.Background()
+
+	cfg := &store.Config{Name: "edge"}
+	s.CreateConfig(ctx, cfg)
+	s.CreateConfigVersion(ctx, &store.ConfigVersion{ConfigID: cfg.ID, Version: 1, Content: "server { v 1; }"})
+	cfg.CurrentVersion = 1
+	s.UpdateConfig(ctx, cfg)
+
+	regResp, _ := fs.Register(ctx, &pb.RegisterRequest{InstanceId: "inst-1", InstanceName: "edge-1", Labels: map[string]string{"env": "prod"}})
+	inst, _ := s.GetInstance(ctx, "inst-1")
+	version := 1
+	inst.CurrentConfigID, inst.CurrentConfigVersion = &cfg.ID, &version
+	s.UpdateInstance(ctx, inst)
+
+	// Fetching records what the hub delivered
+	delivered, err := fs.GetConfig(ctx, &pb.GetConfigRequest{InstanceId: "inst-1", Token: regResp.Token})
+	if err != nil {
+		t.Fatalf("GetConfig failed: %v", err)
+	}
+
+	edited := "server { v 1; debug; }"
+	heartbeat := func(version, hash, content string) *pb.HeartbeatResponse {
+		t.Helper()
+		resp, err := fs.Heartbeat(ctx, &pb.HeartbeatRequest{
+			InstanceId:           "inst-1",
+			Token:                regResp.Token,
+			Status:               &pb.InstanceStatus{State: pb.InstanceState_INSTANCE_STATE_HEALTHY},
+			CurrentConfigVersion: version,
+			CurrentConfigHash:    hash,
+			CurrentConfigContent: content,
+		})
+		if err != nil {
+			t.Fatalf("Heartbeat failed: %v", err)
+		}
+		return resp
+	}
+	driftedAt := func() *time.Time {
+		inst, _ := s.GetInstance(ctx, "inst-1")
+		return inst.DriftedAt
+	}
+
+	if resp := heartbeat("1", delivered.Hash, ""); resp.ConfigUpdateAvailable || driftedAt() != nil {
+		t.Fatalf("expected no drift for the delivered content, got %+v", resp)
+	}
+
+	// Without a policy, drift is only reported, once
+	for i := 0; i < 2; i++ {
+		if resp := heartbeat("1", render.Hash(edited), ""); resp.ConfigUpdateAvailable || len(resp.Actions) != 0 {
+			t.Errorf("expected drift to be left alone, got %+v", resp)
+		}
+	}
+	if driftedAt() == nil || len(drifts) != 1 || drifts[0].Action != "alert" || drifts[0].ReportedHash != render.Hash(edited) {
+		t.Fatalf("expected one alert for the drift, got %+v", drifts)
+	}
+	inst, _ = s.GetInstance(ctx, "inst-1")
+	if inst.ReportedConfigHash == nil || *inst.ReportedConfigHash != render.Hash(edited) {
+		t.Errorf("expected the reported hash to be recorded, got %v", inst.ReportedConfigHash)
+	}
+
+	// Restoring the content clears the drift
+	heartbeat("1", delivered.Hash, "")
+	if driftedAt() != nil {
+		t.Error("expected drift to be cleared")
+	}
+
+	policy := &store.DriftPolicy{Name: "prod", Selector: map[string]string{"env": "prod"}, Action: store.DriftActionRedeploy}
+	s.CreateDriftPolicy(ctx, policy)
+	if resp := heartbeat("1", render.Hash(edited), ""); !resp.ConfigUpdateAvailable || resp.LatestConfigVersion != "1" {
+		t.Errorf("expected the desired version to be redeployed, got %+v", resp)
+	}
+
+	// Content the hub delivered is refetched rather than treated as drift
+	policy.Action = store.DriftActionAlert
+	s.UpdateDriftPolicy(ctx, policy)
+	inst, _ = s.GetInstance(ctx, "inst-1")
+	stale := render.Hash(edited)
+	inst.DeliveredConfigHash = &stale
+	s.UpdateInstance(ctx, inst)
+	if resp := heartbeat("1", stale, ""); !resp.ConfigUpdateAvailable || driftedAt() != nil {
+		t.Errorf("expected a refetch of changed hub content, got %+v", resp)
+	}
+	fs.GetConfig(ctx, &pb.GetConfigRequest{InstanceId: "inst-1", Token: regResp.Token})
+
+	// Adopting asks for the content, then saves it as a new version
+	policy.Action = store.DriftActionAdopt
+	s.UpdateDriftPolicy(ctx, policy)
+	resp := heartbeat("1", render.Hash(edited), "")
+	if resp.ConfigUpdateAvailable || len(resp.Actions) != 1 || resp.Actions[0].Type != pb.ActionType_ACTION_TYPE_REPORT_CONFIG {
+		t.Fatalf("expected the content to be requested, got %+v", resp)
+	}
+	if resp := heartbeat("1", render.Hash(edited), "server {}"); len(resp.Actions) != 1 {
+		t.Errorf("expected content not matching the hash to be requested again, got %+v", resp)
+	}
+	heartbeat("1", render.Hash(edited), edited)
+
+	ver, _ := s.GetLatestConfigVersion(ctx, cfg.ID)
+	if ver.Version != 2 || ver.Content != edited || ver.ChangeSummary == nil || *ver.ChangeSummary != "Adopted local changes from instance edge-1" {
+		t.Fatalf("expected the local content adopted as version 2, got %+v", ver)
+	}
+	inst, _ = s.GetInstance(ctx, "inst-1")
+	if *inst.CurrentConfigVersion != 2 || inst.DriftedAt != nil {
+		t.Errorf("expected the instance to want the adopted version, got %+v", inst)
+	}
+	if resp := heartbeat("1", render.Hash(edited), ""); !resp.ConfigUpdateAvailable || resp.LatestConfigVersion != "2" {
+		t.Errorf("expected the agent to move to the adopted version, got %+v", resp)
+	}
+}
+
+func TestFleetService_Heartbeat_DriftConcurrentVersionChange(t *testing.T) {
+	s := setupTestStore(t)
+	rs := &racingStore{Store: s}
+	fs := NewFleetService(rs)
+	ctx := context.Background()
+
+	cfg := &store.Config{Name: "edge"}
+	s.CreateConfig(ctx, cfg)
+	for v := 1; v <= 2; v++ {
+		s.CreateConfigVersion(ctx, &store.ConfigVersion{ConfigID: cfg.ID, Version: v, Content: fmt.Sprintf("server { v %d; }", v)})
+	}
+	cfg.CurrentVersion = 2
+	s.UpdateConfig(ctx, cfg)
+	s.CreateDriftPolicy(ctx, &store.DriftPolicy{Name: "all", Action: store.DriftActionAdopt})
+
+	regResp, _ := fs.Register(ctx, &pb.RegisterRequest{InstanceId: "inst-1", InstanceName: "edge-1"})
+	inst, _ := s.GetInstance(ctx, "inst-1")
+	version := 1
+	inst.CurrentConfigID, inst.CurrentConfigVersion = &cfg.ID, &version
+	s.UpdateInstance(ctx, inst)
+	fs.GetConfig(ctx, &pb.GetConfigRequest{InstanceId: "inst-1", Token: regResp.Token})
+
+	// A deployment moves the instance on while its local changes are adopted
+	rs.beforeHeartbeat = func() {
+		inst, _ := s.GetInstance(ctx, "inst-1")
+		version := 2
+		inst.CurrentConfigVersion = &version
+		s.UpdateInstance(ctx, inst)
+	}
+	edited := "server { v 1; debug; }"
+	if _, err := fs.Heartbeat(ctx, &pb.HeartbeatRequest{
+		InstanceId:           "inst-1",
+		Token:                regResp.Token,
+		Status:               &pb.InstanceStatus{State: pb.InstanceState_INSTANCE_STATE_HEALTHY},
+		CurrentConfigVersion: "1",
+		CurrentConfigHash:    render.Hash(edited),
+		CurrentConfigContent: edited,
+	}); err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+
+	if ver, _ := s.GetLatestConfigVersion(ctx, cfg.ID); ver.Version != 3 || ver.Content != edited {
+		t.Fatalf("expected the local content adopted as version 3, got %+v", ver)
+	}
+	inst, _ = s.GetInstance(ctx, "inst-1")
+	if *inst.CurrentConfigVersion != 2 {
+		t.Errorf("expected the deployed version to be kept, got %d", *inst.CurrentConfigVersion)
+	}
+	if inst.DriftedAt == nil {
+		t.Error("expected the drift to be recorded")
+	}
+}
+
+func TestFleetService_Heartbeat_DriftAdoptRejected(t *testing.T) {
+	s := setupTestStore(t)
+	fs := NewFleetService(s)
+	bus := events.NewBus()
+	var drifts []events.ConfigDriftedData
+	bus.Subscribe(func(ctx context.Context, e events.Event) {
+		if data, ok := e.Data.(events.ConfigDriftedData); ok {
+			drifts = append(drifts, data)
+		}
+	})
+	fs.SetEventBus(bus)
+	ctx := context.Background()
+
+	key, err := secrets.LoadMasterKey(filepath.Join(t.TempDir(), "master.key"))
+	if err != nil {
+		t.Fatalf("LoadMasterKey failed: %v", err)
+	}
+	keeper, err := secrets.NewKeeper(s, key)
+	if err != nil {
+		t.Fatalf("NewKeeper failed: %v", err)
+	}
+	fs.SetSecretKeeper(keeper)
+	keeper.Set(ctx, "x", nil, "TOPSECRET", nil)
+
+	cfg := &store.Config{Name: "edge"}
+	s.CreateConfig(ctx, cfg)
+	s.CreateConfigVersion(ctx, &store.ConfigVersion{ConfigID: cfg.ID, Version: 1, Content: "server { v 1; }"})
+	cfg.CurrentVersion = 1
+	s.UpdateConfig(ctx, cfg)
+	s.CreateDriftPolicy(ctx, &store.DriftPolicy{Name: "all", Action: store.DriftActionAdopt})
+
+	regResp, _ := fs.Register(ctx, &pb.RegisterRequest{InstanceId: "inst-1", InstanceName: "edge-1"})
+	inst, _ := s.GetInstance(ctx, "inst-1")
+	version := 1
+	inst.CurrentConfigID, inst.CurrentConfigVersion = &cfg.ID, &version
+	s.UpdateInstance(ctx, inst)
+	delivered, err := fs.GetConfig(ctx, &pb.GetConfigRequest{InstanceId: "inst-1", Token: regResp.Token})
+	if err != nil {
+		t.Fatalf("GetConfig failed: %v", err)
+	}
+
+	heartbeat := func(hash, content string) *pb.HeartbeatResponse {
+		t.Helper()
+		resp, err := fs.Heartbeat(ctx, &pb.HeartbeatRequest{
+			InstanceId:           "inst-1",
+			Token:                regResp.Token,
+			Status:               &pb.InstanceStatus{State: pb.InstanceState_INSTANCE_STATE_HEALTHY},
+			CurrentConfigVersion: "1",
+			CurrentConfigHash:    hash,
+			CurrentConfigContent: content,
+		})
+		if err != nil {
+			t.Fatalf("Heartbeat failed: %v", err)
+		}
+		return resp
+	}
+
+	for _, tc := range []struct {
+		content string
+		reason  string
+	}{
+		{`server { key secret="x"; }`, "reported config refers to secrets"},
+		{`server {`, "reported config is not valid KDL"},
+	} {
+		hash := render.Hash(tc.content)
+		if resp := heartbeat(hash, ""); len(resp.Actions) != 1 || resp.Actions[0].Type != pb.ActionType_ACTION_TYPE_REPORT_CONFIG {
+			t.Fatalf("%s: expected the content to be requested, got %+v", tc.content, resp)
+		}
+		drifts = nil
+		heartbeat(hash, tc.content)
+		if len(drifts) != 1 || drifts[0].Action != "adopt" || !strings.HasPrefix(drifts[0].Reason, tc.reason) {
+			t.Errorf("%s: expected the rejection to be reported, got %+v", tc.content, drifts)
+		}
+		ver, _ := s.GetLatestConfigVersion(ctx, cfg.ID)
+		if ver.Version != 1 {
+			t.Fatalf("%s: expected no version to be adopted, got version %d", tc.content, ver.Version)
+		}
+
+		// Rejected content is not asked for again
+		if resp := heartbeat(hash, ""); len(resp.Actions) != 0 || len(drifts) != 1 {
+			t.Errorf("%s: expected the rejected content to be left alone, got %+v", tc.content, resp)
+		}
+	}
+
+	inst, _ = s.GetInstance(ctx, "inst-1")
+	if *inst.CurrentConfigVersion != 1 || *inst.DeliveredConfigHash != delivered.Hash {
+		t.Errorf("expected the instance to keep its version, got %+v", inst)
+	}
+	resp, err := fs.GetConfig(ctx, &pb.GetConfigRequest{InstanceId: "inst-1", Token: regResp.Token})
+	if err != nil {
+		t.Fatalf("GetConfig failed: %v", err)
+	}
+	if strings.Contains(resp.Content, "TOPSECRET") {
+		t.Errorf("GetConfig returned the secret value: %q", resp.Content)
+	}
+}
+
 func TestFleetService_Heartbeat_InvalidToken(t *testing.T) {
 	s := setupTestStore(t)
 	fs := NewFleetService(s)
@@ -507,6 +1023,219 @@ func TestFleetService_GetConfig_NoConfigAssigned(t *testing.T) {
 	}
 }
 
+func TestFleetService_GetConfig_Template(t *testing.T) {
+	s := setupTestStore(t)
+	fs := NewFleetService(s)
+
+	ctx := context.Background()
+
+	cfg := &store.Config{Name: "test-config"}
+	if err := s.CreateConfig(ctx, cfg); err != nil {
+		t.Fatalf("CreateConfig failed: %v", err)
+	}
+	ver := &store.ConfigVersion{
+		ConfigID:    cfg.ID,
+		Version:     1,
+		Content:     `server { listen "{{ .Vars.listen }}"; name "{{ .Hostname }}"; }`,
+		ContentHash: "template-hash",
+		IsTemplate:  true,
+	}
+	if err := s.CreateConfigVersion(ctx, ver); err != nil {
+		t.Fatalf("CreateConfigVersion failed: %v", err)
+	}
+
+	inst := &store.Instance{ID: "inst-1", Name: "test", Hostname: "edge-1.local", CurrentConfigID: &cfg.ID}
+	if err := s.CreateInstance(ctx, inst); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+
+	token := "test-token"
+	fs.sessions[hashToken(token)] = "inst-1"
+
+	// The template refers to a variable that is not set yet
+	_, err := fs.GetConfig(ctx, &pb.GetConfigRequest{InstanceId: "inst-1", Token: token})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition for an unset variable, got %v", err)
+	}
+
+	s.SetVariable(ctx, &store.Variable{Name: "listen", Value: "0.0.0.0:8080"})
+	s.SetVariable(ctx, &store.Variable{InstanceID: "inst-1", Name: "listen", Value: "10.0.0.5:8080"})
+
+	resp, err := fs.GetConfig(ctx, &pb.GetConfigRequest{InstanceId: "inst-1", Token: token})
+	if err != nil {
+		t.Fatalf("GetConfig failed: %v", err)
+	}
+	want := `server { listen "10.0.0.5:8080"; name "edge-1.local"; }`
+	if resp.Content != want {
+		t.Errorf("Content = %q, want %q", resp.Content, want)
+	}
+	if resp.Hash != render.Hash(want) {
+		t.Errorf("Hash = %q, want the hash of the rendered content", resp.Hash)
+	}
+
+	// GetConfigVersion renders for the calling instance too
+	verResp, err := fs.GetConfigVersion(ctx, &pb.GetConfigVersionRequest{
+		InstanceId: "inst-1", Token: token, ConfigId: cfg.ID, VersionNumber: 1,
+	})
+	if err != nil {
+		t.Fatalf("GetConfigVersion failed: %v", err)
+	}
+	if verResp.Content != want || verResp.Hash != resp.Hash {
+		t.Errorf("GetConfigVersion returned %q (%s), want the rendered content", verResp.Content, verResp.Hash)
+	}
+
+	// A heartbeat reporting the rendered hash needs no update
+	hb, err := fs.Heartbeat(ctx, &pb.HeartbeatRequest{
+		InstanceId:           "inst-1",
+		Token:                token,
+		Status:               &pb.InstanceStatus{State: pb.InstanceState_INSTANCE_STATE_HEALTHY},
+		CurrentConfigVersion: "1",
+		CurrentConfigHash:    resp.Hash,
+	})
+	if err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+	if hb.ConfigUpdateAvailable {
+		t.Error("ConfigUpdateAvailable should be false when the rendered hash matches")
+	}
+}
+
+func TestFleetService_GetConfig_Secrets(t *testing.T) {
+	s := setupTestStore(t)
+	fs := NewFleetService(s)
+
+	ctx := context.Background()
+
+	cfg := &store.Config{Name: "test-config"}
+	if err := s.CreateConfig(ctx, cfg); err != nil {
+		t.Fatalf("CreateConfig failed: %v", err)
+	}
+	content := `upstream "api" { tls { cert secret="api-cert"; }; }`
+	ver := &store.ConfigVersion{ConfigID: cfg.ID, Version: 1, Content: content, ContentHash: render.Hash(content)}
+	if err := s.CreateConfigVersion(ctx, ver); err != nil {
+		t.Fatalf("CreateConfigVersion failed: %v", err)
+	}
+
+	inst := &store.Instance{ID: "inst-1", Name: "test", Hostname: "edge-1.local", CurrentConfigID: &cfg.ID}
+	if err := s.CreateInstance(ctx, inst); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	token := "test-token"
+	fs.sessions[hashToken(token)] = "inst-1"
+
+	// References are never delivered unresolved
+	_, err := fs.GetConfig(ctx, &pb.GetConfigRequest{InstanceId: "inst-1", Token: token})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition without a secret keeper, got %v", err)
+	}
+
+	key, err := secrets.LoadMasterKey(filepath.Join(t.TempDir(), "master.key"))
+	if err != nil {
+		t.Fatalf("LoadMasterKey failed: %v", err)
+	}
+	keeper, err := secrets.NewKeeper(s, key)
+	if err != nil {
+		t.Fatalf("NewKeeper failed: %v", err)
+	}
+	fs.SetSecretKeeper(keeper)
+
+	_, err = fs.GetConfig(ctx, &pb.GetConfigRequest{InstanceId: "inst-1", Token: token})
+	if status.Code(err) != codes.FailedPrecondition || strings.Contains(err.Error(), "CERT") {
+		t.Fatalf("expected FailedPrecondition for a missing secret, got %v", err)
+	}
+
+	keeper.Set(ctx, "api-cert", nil, "CERT-1", nil)
+	resp, err := fs.GetConfig(ctx, &pb.GetConfigRequest{InstanceId: "inst-1", Token: token})
+	if err != nil {
+		t.Fatalf("GetConfig failed: %v", err)
+	}
+	if !strings.Contains(resp.Content, `cert "CERT-1"`) || strings.Contains(resp.Content, "secret=") {
+		t.Errorf("secret not resolved: %q", resp.Content)
+	}
+	if resp.Hash != render.Hash(resp.Content) {
+		t.Error("Hash should cover the resolved content")
+	}
+
+	// Rotating the secret changes the hash, so agents refetch
+	keeper.Set(ctx, "api-cert", nil, "CERT-2", nil)
+	hb, err := fs.Heartbeat(ctx, &pb.HeartbeatRequest{
+		InstanceId:           "inst-1",
+		Token:                token,
+		Status:               &pb.InstanceStatus{State: pb.InstanceState_INSTANCE_STATE_HEALTHY},
+		CurrentConfigVersion: "1",
+		CurrentConfigHash:    resp.Hash,
+	})
+	if err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+	if !hb.ConfigUpdateAvailable {
+		t.Error("ConfigUpdateAvailable should be true after the secret changed")
+	}
+}
+
+func TestFleetService_GetConfig_TemplateSecretNames(t *testing.T) {
+	s := setupTestStore(t)
+	fs := NewFleetService(s)
+
+	ctx := context.Background()
+
+	key, err := secrets.LoadMasterKey(filepath.Join(t.TempDir(), "master.key"))
+	if err != nil {
+		t.Fatalf("LoadMasterKey failed: %v", err)
+	}
+	keeper, err := secrets.NewKeeper(s, key)
+	if err != nil {
+		t.Fatalf("NewKeeper failed: %v", err)
+	}
+	fs.SetSecretKeeper(keeper)
+	keeper.Set(ctx, "api-cert", nil, "CERT", nil)
+	keeper.Set(ctx, "admin-cert", nil, "ADMIN-CERT", nil)
+
+	cfg := &store.Config{Name: "test-config"}
+	if err := s.CreateConfig(ctx, cfg); err != nil {
+		t.Fatalf("CreateConfig failed: %v", err)
+	}
+	inst := &store.Instance{ID: "inst-1", Name: "test", Hostname: "edge-1.local", CurrentConfigID: &cfg.ID}
+	if err := s.CreateInstance(ctx, inst); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	token := "test-token"
+	fs.sessions[hashToken(token)] = "inst-1"
+
+	// Secrets named by the template resolve; a variable cannot add others
+	content := `tls { cert secret="api-cert"; {{ .Vars.extra }} }`
+	ver := &store.ConfigVersion{ConfigID: cfg.ID, Version: 1, Content: content, ContentHash: render.Hash(content), IsTemplate: true}
+	if err := s.CreateConfigVersion(ctx, ver); err != nil {
+		t.Fatalf("CreateConfigVersion failed: %v", err)
+	}
+	s.SetVariable(ctx, &store.Variable{Name: "extra", Value: ""})
+	resp, err := fs.GetConfig(ctx, &pb.GetConfigRequest{InstanceId: "inst-1", Token: token})
+	if err != nil {
+		t.Fatalf("GetConfig failed: %v", err)
+	}
+	if !strings.Contains(resp.Content, `cert "CERT"`) {
+		t.Errorf("secret not resolved: %q", resp.Content)
+	}
+
+	s.SetVariable(ctx, &store.Variable{Name: "extra", Value: `key secret="admin-cert";`})
+	_, err = fs.GetConfig(ctx, &pb.GetConfigRequest{InstanceId: "inst-1", Token: token})
+	if status.Code(err) != codes.FailedPrecondition || strings.Contains(err.Error(), "ADMIN-CERT") {
+		t.Fatalf("expected FailedPrecondition for a secret added by a variable, got %v", err)
+	}
+
+	// Nor can the template choose a secret name when rendered
+	content = `tls { cert secret="{{ .Vars.cert }}"; }`
+	ver = &store.ConfigVersion{ConfigID: cfg.ID, Version: 2, Content: content, ContentHash: render.Hash(content), IsTemplate: true}
+	if err := s.CreateConfigVersion(ctx, ver); err != nil {
+		t.Fatalf("CreateConfigVersion failed: %v", err)
+	}
+	s.SetVariable(ctx, &store.Variable{Name: "cert", Value: "admin-cert"})
+	_, err = fs.GetConfig(ctx, &pb.GetConfigRequest{InstanceId: "inst-1", Token: token})
+	if status.Code(err) != codes.FailedPrecondition || strings.Contains(err.Error(), "ADMIN-CERT") {
+		t.Fatalf("expected FailedPrecondition for a templated secret name, got %v", err)
+	}
+}
+
 func TestFleetService_GetConfigVersion(t *testing.T) {
 	s := setupTestStore(t)
 	fs := NewFleetService(s)
@@ -529,11 +1258,15 @@ func TestFleetService_GetConfigVersion(t *testing.T) {
 		t.Fatalf("CreateConfigVersion failed: %v", err)
 	}
 
+	inst := &store.Instance{Name: "inst-1", Hostname: "inst-1", CurrentConfigID: &cfg.ID}
+	if err := s.CreateInstance(ctx, inst); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
 	token := "test-token"
-	fs.sessions[hashToken(token)] = "inst-1"
+	fs.sessions[hashToken(token)] = inst.ID
 
 	resp, err := fs.GetConfigVersion(ctx, &pb.GetConfigVersionRequest{
-		InstanceId:    "inst-1",
+		InstanceId:    inst.ID,
 		Token:         token,
 		ConfigId:      cfg.ID,
 		VersionNumber: 1,
@@ -553,6 +1286,118 @@ func TestFleetService_GetConfigVersion(t *testing.T) {
 	}
 }
 
+func TestFleetService_GetConfigVersion_NotAssigned(t *testing.T) {
+	s := setupTestStore(t)
+	fs := NewFleetService(s)
+	ctx := context.Background()
+
+	mine := &store.Config{Name: "mine"}
+	other := &store.Config{Name: "other"}
+	for _, cfg := range []*store.Config{mine, other} {
+		if err := s.CreateConfig(ctx, cfg); err != nil {
+			t.Fatalf("CreateConfig failed: %v", err)
+		}
+		if err := s.CreateConfigVersion(ctx, &store.ConfigVersion{ConfigID: cfg.ID, Version: 1, Content: `tls { cert secret="api-key"; }`}); err != nil {
+			t.Fatalf("CreateConfigVersion failed: %v", err)
+		}
+	}
+
+	inst := &store.Instance{Name: "edge-1", Hostname: "edge-1", CurrentConfigID: &mine.ID}
+	if err := s.CreateInstance(ctx, inst); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	token := "test-token"
+	fs.sessions[hashToken(token)] = inst.ID
+
+	req := &pb.GetConfigVersionRequest{InstanceId: inst.ID, Token: token, ConfigId: other.ID, VersionNumber: 1}
+	if _, err := fs.GetConfigVersion(ctx, req); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied for another config, got %v", err)
+	}
+
+	// A deployment of the config to the instance allows fetching it
+	dep := &store.Deployment{
+		ConfigID:        other.ID,
+		ConfigVersion:   1,
+		TargetInstances: []string{inst.ID},
+		Strategy:        store.DeploymentStrategyAllAtOnce,
+		Status:          store.DeploymentStatusInProgress,
+	}
+	if err := s.CreateDeployment(ctx, dep); err != nil {
+		t.Fatalf("CreateDeployment failed: %v", err)
+	}
+	if _, err := fs.GetConfigVersion(ctx, req); status.Code(err) == codes.PermissionDenied {
+		t.Errorf("expected the deployment target to fetch the config, got %v", err)
+	}
+
+	// Tokens without an instance get nothing
+	fs.sessions[hashToken("orphan")] = "deleted-instance"
+	req = &pb.GetConfigVersionRequest{InstanceId: "deleted-instance", Token: "orphan", ConfigId: mine.ID, VersionNumber: 1}
+	if _, err := fs.GetConfigVersion(ctx, req); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound without an instance, got %v", err)
+	}
+}
+
+func TestFleetService_GetConfigVersion_DeliveredHash(t *testing.T) {
+	s := setupTestStore(t)
+	fs := NewFleetService(s)
+	ctx := context.Background()
+
+	cfg := &store.Config{Name: "edge"}
+	s.CreateConfig(ctx, cfg)
+	for v, content := range []string{"server { v 1; }", "server { v 2; }"} {
+		s.CreateConfigVersion(ctx, &store.ConfigVersion{ConfigID: cfg.ID, Version: v + 1, Content: content, ContentHash: render.Hash(content)})
+	}
+
+	regResp, _ := fs.Register(ctx, &pb.RegisterRequest{InstanceId: "inst-1", InstanceName: "edge-1"})
+	inst, _ := s.GetInstance(ctx, "inst-1")
+	version := 1
+	inst.CurrentConfigID, inst.CurrentConfigVersion = &cfg.ID, &version
+	s.UpdateInstance(ctx, inst)
+	delivered := func() string {
+		inst, _ := s.GetInstance(ctx, "inst-1")
+		if inst.DeliveredConfigHash == nil {
+			return ""
+		}
+		return *inst.DeliveredConfigHash
+	}
+
+	// Fetching another version, as a deployment does, records nothing
+	fetch := func(version int32) {
+		t.Helper()
+		if _, err := fs.GetConfigVersion(ctx, &pb.GetConfigVersionRequest{
+			InstanceId: "inst-1", Token: regResp.Token, ConfigId: cfg.ID, VersionNumber: version,
+		}); err != nil {
+			t.Fatalf("GetConfigVersion failed: %v", err)
+		}
+	}
+	fetch(2)
+	if got := delivered(); got != "" {
+		t.Errorf("expected no delivered hash for a version other than the desired one, got %q", got)
+	}
+	fetch(1)
+	if got := delivered(); got != render.Hash("server { v 1; }") {
+		t.Errorf("delivered hash = %q, want that of the desired version", got)
+	}
+
+	// Once the instance should run the other version, reporting it records it
+	version = 2
+	inst, _ = s.GetInstance(ctx, "inst-1")
+	inst.CurrentConfigVersion = &version
+	s.UpdateInstance(ctx, inst)
+	if _, err := fs.Heartbeat(ctx, &pb.HeartbeatRequest{
+		InstanceId:           "inst-1",
+		Token:                regResp.Token,
+		Status:               &pb.InstanceStatus{State: pb.InstanceState_INSTANCE_STATE_HEALTHY},
+		CurrentConfigVersion: "2",
+		CurrentConfigHash:    render.Hash("server { v 2; }"),
+	}); err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+	if got := delivered(); got != render.Hash("server { v 2; }") {
+		t.Errorf("delivered hash = %q, want that of the reported desired version", got)
+	}
+}
+
 func TestFleetService_GetConfigVersion_NotFound(t *testing.T) {
 	s := setupTestStore(t)
 	fs := NewFleetService(s)
@@ -640,7 +1485,7 @@ func TestFleetService_ReportDeploymentStatus(t *testing.T) {
 	var handlerInstanceID, handlerDeploymentID string
 	var handlerState pb.DeploymentState
 
-	fs.SetDeploymentStatusHandler(func(instanceID, deploymentID string, state pb.DeploymentState, message, errorDetails string) {
+	fs.SetDeploymentStatusHandler(func(ctx context.Context, instanceID, deploymentID string, state pb.DeploymentState, message, errorDetails string) {
 		handlerCalled = true
 		handlerInstanceID = instanceID
 		handlerDeploymentID = deploymentID
@@ -943,7 +1788,7 @@ func TestFleetService_SetDeploymentStatusHandler(t *testing.T) {
 		t.Error("handler should be nil initially")
 	}
 
-	handler := func(instanceID, deploymentID string, state pb.DeploymentState, message, errorDetails string) {
+	handler := func(ctx context.Context, instanceID, deploymentID string, state pb.DeploymentState, message, errorDetails string) {
 		// Handler implementation
 	}
 
@@ -954,6 +1799,106 @@ func TestFleetService_SetDeploymentStatusHandler(t *testing.T) {
 	}
 }
 
+func TestFleetService_AuditsAgentActions(t *testing.T) {
+	s := setupTestStore(t)
+	fs := NewFleetService(s)
+
+	ctx := auth.ContextWithSPIFFEIdentity(context.Background(), &auth.SPIFFEIdentity{
+		SPIFFEID:     "spiffe://example.org/agent/inst-1",
+		SerialNumber: "1234",
+	})
+
+	regResp, err := fs.Register(ctx, &pb.RegisterRequest{
+		InstanceId:   "inst-1",
+		InstanceName: "test-instance",
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	for _, accepted := range []bool{true, false} {
+		_, err := fs.AckDeployment(ctx, &pb.AckDeploymentRequest{
+			InstanceId:      "inst-1",
+			Token:           regResp.Token,
+			DeploymentId:    "dep-1",
+			Accepted:        accepted,
+			RejectionReason: "busy",
+		})
+		if err != nil {
+			t.Fatalf("AckDeployment failed: %v", err)
+		}
+	}
+	if _, err := fs.Deregister(ctx, &pb.DeregisterRequest{InstanceId: "inst-1", Token: regResp.Token}); err != nil {
+		t.Fatalf("Deregister failed: %v", err)
+	}
+
+	logs, err := s.ListAuditLogs(ctx, store.ListAuditLogsOptions{ActorType: store.ActorTypeAgent, OldestFirst: true})
+	if err != nil {
+		t.Fatalf("ListAuditLogs failed: %v", err)
+	}
+
+	var actions []string
+	for _, entry := range logs {
+		actions = append(actions, entry.Action)
+		if entry.ActorID == nil || *entry.ActorID != "inst-1" {
+			t.Errorf("%s: expected actor inst-1, got %v", entry.Action, entry.ActorID)
+		}
+		if entry.ActorSPIFFEID == nil || *entry.ActorSPIFFEID != "spiffe://example.org/agent/inst-1" {
+			t.Errorf("%s: expected SPIFFE ID, got %v", entry.Action, entry.ActorSPIFFEID)
+		}
+		if entry.ActorCertSerial == nil || *entry.ActorCertSerial != "1234" {
+			t.Errorf("%s: expected cert serial, got %v", entry.Action, entry.ActorCertSerial)
+		}
+	}
+	if got := strings.Join(actions, ","); got != "register,ack,reject,deregister" {
+		t.Errorf("actions = %s, want register,ack,reject,deregister", got)
+	}
+	if len(logs) == 4 && !strings.Contains(string(logs[2].Details), `"reason":"busy"`) {
+		t.Errorf("expected rejection reason in details, got %s", logs[2].Details)
+	}
+}
+
+func TestFleetService_PublishesInstanceEvents(t *testing.T) {
+	s := setupTestStore(t)
+	fs := NewFleetService(s)
+	bus := events.NewBus()
+	fs.SetEventBus(bus)
+
+	var got []string
+	bus.Subscribe(func(ctx context.Context, e events.Event) {
+		data := e.Data.(events.InstanceData)
+		got = append(got, string(e.Type)+":"+data.PreviousStatus)
+	})
+
+	ctx := context.Background()
+	regResp, err := fs.Register(ctx, &pb.RegisterRequest{InstanceId: "inst-1", InstanceName: "test-instance"})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	// Healthy heartbeats from an online instance are not events
+	for _, state := range []pb.InstanceState{pb.InstanceState_INSTANCE_STATE_HEALTHY, pb.InstanceState_INSTANCE_STATE_DEGRADED} {
+		_, err := fs.Heartbeat(ctx, &pb.HeartbeatRequest{
+			InstanceId: "inst-1",
+			Token:      regResp.Token,
+			Status:     &pb.InstanceStatus{State: state},
+		})
+		if err != nil {
+			t.Fatalf("Heartbeat failed: %v", err)
+		}
+	}
+	if _, err := fs.Deregister(ctx, &pb.DeregisterRequest{InstanceId: "inst-1", Token: regResp.Token}); err != nil {
+		t.Fatalf("Deregister failed: %v", err)
+	}
+	regResp, err = fs.Register(ctx, &pb.RegisterRequest{InstanceId: "inst-1", InstanceName: "test-instance"})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	want := "instance.online:,instance.degraded:online,instance.offline:degraded,instance.online:offline"
+	if strings.Join(got, ",") != want {
+		t.Errorf("events = %s, want %s", strings.Join(got, ","), want)
+	}
+}
+
 func TestFleetService_ConcurrentAccess(t *testing.T) {
 	s := setupTestStore(t)
 	fs := NewFleetService(s)
@@ -660,7 +660,7 @@ func TestFleetService_RemoveSubscriber_NotExists(t *testing.T) {
 // Integration Tests with bufconn
 // ============================================
 
-func setupBufconnServer(t *testing.T, s *store.Store) (*bufconn.Listener, *Server) {
+func setupBufconnServer(t *testing.T, s store.Store) (*bufconn.Listener, *Server) {
 	lis := bufconn.Listen(bufSize)
 
 	server := NewServer(s, 0) // Port doesn't matter for bufconn
@@ -0,0 +1,32 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+var driftDetected = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "hub_config_drift_detected_total",
+	Help: "Instances found running local changes to their config, by remediation action.",
+}, []string{"action"})
+
+// NewDriftedInstancesGauge returns a gauge of the instances running local
+// changes to their config, read from the store on every scrape.
+func NewDriftedInstancesGauge(s store.Store) prometheus.GaugeFunc {
+	drifted := true
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "hub_instances_drifted",
+		Help: "Instances running local changes to their desired config version.",
+	}, func() float64 {
+		instances, err := s.ListInstances(context.Background(), store.ListInstancesOptions{Drifted: &drifted})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to count drifted instances")
+			return 0
+		}
+		return float64(len(instances))
+	})
+}
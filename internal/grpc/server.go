@@ -51,7 +51,7 @@ func WithSPIFFE(authenticator *auth.SPIFFEAuthenticator) ServerOption {
 }
 
 // NewServer creates a new gRPC server.
-func NewServer(s *store.Store, port int, opts ...ServerOption) *Server {
+func NewServer(s store.Store, port int, opts ...ServerOption) *Server {
 	// Apply options
 	cfg := &serverConfig{}
 	for _, opt := range opts {
@@ -0,0 +1,123 @@
+package kdl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// indent is the indentation of each level of children.
+const indent = "    "
+
+// Format renders nodes as a KDL document, one node per line with children
+// indented by four spaces. Comments and original formatting are not kept.
+func Format(nodes []*Node) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		writeNode(&b, n, 0)
+	}
+	return b.String()
+}
+
+// String renders a single node and its children.
+func (n *Node) String() string {
+	var b strings.Builder
+	writeNode(&b, n, 0)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Header renders a node's name, arguments and properties without its
+// children.
+func (n *Node) Header() string {
+	var b strings.Builder
+	if n.Type != "" {
+		fmt.Fprintf(&b, "(%s)", formatIdentifier(n.Type))
+	}
+	b.WriteString(formatIdentifier(n.Name))
+	for _, arg := range n.Args {
+		b.WriteByte(' ')
+		b.WriteString(arg.String())
+	}
+	for _, prop := range n.Props {
+		fmt.Fprintf(&b, " %s=%s", formatIdentifier(prop.Name), prop.Value.String())
+	}
+	return b.String()
+}
+
+func writeNode(b *strings.Builder, n *Node, depth int) {
+	prefix := strings.Repeat(indent, depth)
+	b.WriteString(prefix)
+	b.WriteString(n.Header())
+	if len(n.Children) == 0 {
+		b.WriteByte('\n')
+		return
+	}
+	b.WriteString(" {\n")
+	for _, child := range n.Children {
+		writeNode(b, child, depth+1)
+	}
+	b.WriteString(prefix)
+	b.WriteString("}\n")
+}
+
+// String renders the value as a KDL literal.
+func (v Value) String() string {
+	var s string
+	if v.Kind == KindString {
+		s = Quote(v.Str)
+	} else {
+		s = v.Raw
+	}
+	if v.Type != "" {
+		s = "(" + formatIdentifier(v.Type) + ")" + s
+	}
+	return s
+}
+
+// Quote returns s as a quoted KDL string.
+func Quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, `\u{%x}`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// formatIdentifier returns a name bare if it is a valid identifier and
+// quoted otherwise.
+func formatIdentifier(name string) string {
+	if name == "" || strings.ContainsFunc(name, func(r rune) bool { return !isIdentChar(r) }) {
+		return Quote(name)
+	}
+	switch name {
+	case "true", "false", "null", "inf", "-inf", "nan":
+		return Quote(name)
+	}
+	p := &parser{src: []rune(name)}
+	if p.isNumberStart() || name[0] == '.' && len(name) > 1 && name[1] >= '0' && name[1] <= '9' || p.isRawStringStart() {
+		return Quote(name)
+	}
+	return name
+}
@@ -0,0 +1,118 @@
+// Package kdl parses and formats KDL documents, the format Sentinel
+// configs are written in. It accepts both KDL 1 and the common KDL 2
+// syntax (#true, #"raw"#, bare identifier values) and keeps each value's
+// literal, so that formatting a parsed document preserves how numbers and
+// keywords were written.
+package kdl
+
+import (
+	"strings"
+)
+
+// ValueKind is the type of a KDL value.
+type ValueKind int
+
+// Value kinds.
+const (
+	KindString ValueKind = iota
+	KindNumber
+	KindBool
+	KindNull
+)
+
+// Value is an argument or property value.
+type Value struct {
+	Kind ValueKind
+	// Type is the value's type annotation, e.g. "u16" in (u16)8080.
+	Type string
+	// Str holds the contents of a string.
+	Str string
+	// Raw is the literal of a number, boolean or null as written,
+	// e.g. "0x1F", "#true" or "null".
+	Raw string
+}
+
+// String returns a string value.
+func String(s string) Value {
+	return Value{Kind: KindString, Str: s}
+}
+
+// Bool returns true for boolean true values.
+func (v Value) Bool() bool {
+	return v.Kind == KindBool && strings.TrimPrefix(v.Raw, "#") == "true"
+}
+
+// Text returns the value as plain text: a string's contents, or the
+// literal of any other value.
+func (v Value) Text() string {
+	if v.Kind == KindString {
+		return v.Str
+	}
+	return v.Raw
+}
+
+// Equal reports whether two values are the same, ignoring how numbers and
+// keywords were spelled (e.g. 1_000 and 1000, or true and #true).
+func (v Value) Equal(o Value) bool {
+	if v.Kind != o.Kind || v.Type != o.Type {
+		return false
+	}
+	switch v.Kind {
+	case KindString:
+		return v.Str == o.Str
+	case KindNumber:
+		return strings.ReplaceAll(v.Raw, "_", "") == strings.ReplaceAll(o.Raw, "_", "")
+	case KindBool:
+		return v.Bool() == o.Bool()
+	default:
+		return true
+	}
+}
+
+// Prop is a node property.
+type Prop struct {
+	Name  string
+	Value Value
+}
+
+// Node is a KDL node.
+type Node struct {
+	// Type is the node's type annotation, if any.
+	Type     string
+	Name     string
+	Args     []Value
+	Props    []Prop // In source order; names are unique
+	Children []*Node
+}
+
+// Prop returns the value of the named property.
+func (n *Node) Prop(name string) (Value, bool) {
+	for _, p := range n.Props {
+		if p.Name == name {
+			return p.Value, true
+		}
+	}
+	return Value{}, false
+}
+
+// SetProp sets a property, replacing an existing one in place.
+func (n *Node) SetProp(name string, v Value) {
+	for i := range n.Props {
+		if n.Props[i].Name == name {
+			n.Props[i].Value = v
+			return
+		}
+	}
+	n.Props = append(n.Props, Prop{Name: name, Value: v})
+}
+
+// Clone returns a deep copy of the node.
+func (n *Node) Clone() *Node {
+	c := &Node{Type: n.Type, Name: n.Name}
+	c.Args = append([]Value(nil), n.Args...)
+	c.Props = append([]Prop(nil), n.Props...)
+	for _, child := range n.Children {
+		c.Children = append(c.Children, child.Clone())
+	}
+	return c
+}
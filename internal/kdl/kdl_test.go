@@ -0,0 +1,149 @@
+package kdl
+
+import (
+	"errors"
+	"testing"
+)
+
+const sample = `// Sentinel config
+server {
+    listen 8080
+    workers 4 // trailing comment
+}
+
+/* upstreams */
+upstream "api" weight=10 {
+    target "10.0.0.1:80"; target "10.0.0.2:80"
+    health-check path="/healthz" interval=(duration)"5s" enabled=#true
+}
+
+/-upstream "old" {
+    target "10.0.0.9:80"
+}
+
+route "/api/*" upstream=api \
+    timeout=30 /-retries=3
+`
+
+func TestParse(t *testing.T) {
+	nodes, err := Parse(sample)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(nodes) != 3 {
+		t.Fatalf("expected 3 nodes, got %d", len(nodes))
+	}
+
+	server := nodes[0]
+	if server.Name != "server" || len(server.Children) != 2 {
+		t.Fatalf("unexpected server node: %+v", server)
+	}
+	if listen := server.Children[0]; listen.Name != "listen" || listen.Args[0].Kind != KindNumber || listen.Args[0].Raw != "8080" {
+		t.Errorf("unexpected listen node: %+v", listen)
+	}
+
+	upstream := nodes[1]
+	if upstream.Args[0].Str != "api" {
+		t.Errorf("expected upstream argument api, got %+v", upstream.Args)
+	}
+	if weight, ok := upstream.Prop("weight"); !ok || weight.Raw != "10" {
+		t.Errorf("expected weight=10, got %+v", weight)
+	}
+	if len(upstream.Children) != 3 {
+		t.Fatalf("expected 3 upstream children, got %d", len(upstream.Children))
+	}
+	hc := upstream.Children[2]
+	if interval, _ := hc.Prop("interval"); interval.Type != "duration" || interval.Str != "5s" {
+		t.Errorf("expected typed interval, got %+v", interval)
+	}
+	if enabled, _ := hc.Prop("enabled"); !enabled.Bool() {
+		t.Errorf("expected enabled=#true, got %+v", enabled)
+	}
+
+	route := nodes[2]
+	if route.Args[0].Str != "/api/*" {
+		t.Errorf("unexpected route argument: %+v", route.Args)
+	}
+	if upstream, _ := route.Prop("upstream"); upstream.Kind != KindString || upstream.Str != "api" {
+		t.Errorf("expected bare identifier value as string, got %+v", upstream)
+	}
+	if _, ok := route.Prop("retries"); ok {
+		t.Error("expected slashdashed property to be dropped")
+	}
+	if _, ok := route.Prop("timeout"); !ok {
+		t.Error("expected property after line continuation")
+	}
+}
+
+func TestParse_Strings(t *testing.T) {
+	nodes, err := Parse(`s "a\"b\n\u{41}" r#"raw "quoted""# #"v2 raw"#`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []string{"a\"b\nA", `raw "quoted"`, "v2 raw"}
+	for i, w := range want {
+		if got := nodes[0].Args[i].Str; got != w {
+			t.Errorf("arg %d = %q, want %q", i, got, w)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []string{
+		`server {`,
+		`}`,
+		`node "unterminated`,
+		`node 12abc`,
+		`node /* open`,
+		`node { child } extra`,
+	}
+	for _, src := range tests {
+		_, err := Parse(src)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("Parse(%q): expected ParseError, got %v", src, err)
+		}
+	}
+}
+
+func TestFormat_RoundTrip(t *testing.T) {
+	nodes, err := Parse(sample)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	formatted := Format(nodes)
+
+	reparsed, err := Parse(formatted)
+	if err != nil {
+		t.Fatalf("Parse(Format()) failed: %v\n%s", err, formatted)
+	}
+	if again := Format(reparsed); again != formatted {
+		t.Errorf("formatting is not stable:\n%s\n---\n%s", formatted, again)
+	}
+
+	want := "upstream \"api\" weight=10 {\n" +
+		"    target \"10.0.0.1:80\"\n" +
+		"    target \"10.0.0.2:80\"\n" +
+		"    health-check path=\"/healthz\" interval=(duration)\"5s\" enabled=#true\n" +
+		"}"
+	if got := nodes[1].String(); got != want {
+		t.Errorf("unexpected formatting:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestValue_Equal(t *testing.T) {
+	tests := []struct {
+		a, b Value
+		want bool
+	}{
+		{Value{Kind: KindNumber, Raw: "1_000"}, Value{Kind: KindNumber, Raw: "1000"}, true},
+		{Value{Kind: KindBool, Raw: "true"}, Value{Kind: KindBool, Raw: "#true"}, true},
+		{String("1000"), Value{Kind: KindNumber, Raw: "1000"}, false},
+		{String("a"), String("b"), false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Equal(tt.b); got != tt.want {
+			t.Errorf("%v.Equal(%v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
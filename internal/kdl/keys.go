@@ -0,0 +1,47 @@
+package kdl
+
+import "fmt"
+
+// SiblingKeys identifies the nodes of two sibling lists so that nodes with
+// the same key correspond to each other, as when diffing or merging them.
+//
+// Nodes are keyed by name. Where a name repeats among the siblings of
+// either list, as with several `route` nodes, they are keyed by name and
+// first argument instead, and then numbered in order.
+func SiblingKeys(a, b []*Node) (aKeys, bKeys []string) {
+	repeated := repeatedNames(a, b)
+	return nodeKeys(a, repeated), nodeKeys(b, repeated)
+}
+
+// repeatedNames returns the node names that occur more than once among the
+// siblings of either list.
+func repeatedNames(a, b []*Node) map[string]bool {
+	repeated := make(map[string]bool)
+	for _, nodes := range [][]*Node{a, b} {
+		seen := make(map[string]bool, len(nodes))
+		for _, n := range nodes {
+			if seen[n.Name] {
+				repeated[n.Name] = true
+			}
+			seen[n.Name] = true
+		}
+	}
+	return repeated
+}
+
+func nodeKeys(nodes []*Node, repeated map[string]bool) []string {
+	keys := make([]string, len(nodes))
+	count := make(map[string]int, len(nodes))
+	for i, n := range nodes {
+		key := n.Name
+		if repeated[n.Name] && len(n.Args) > 0 {
+			key += " " + n.Args[0].String()
+		}
+		count[key]++
+		if count[key] > 1 {
+			key += fmt.Sprintf(" [%d]", count[key])
+		}
+		keys[i] = key
+	}
+	return keys
+}
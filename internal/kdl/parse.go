@@ -0,0 +1,558 @@
+package kdl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseError describes invalid KDL and where it was found.
+type ParseError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// Parse parses a KDL document into its top-level nodes.
+func Parse(src string) ([]*Node, error) {
+	p := &parser{src: []rune(strings.TrimPrefix(src, "\uFEFF")), line: 1, col: 1}
+	return p.nodes(false)
+}
+
+type parser struct {
+	src       []rune
+	pos       int
+	line, col int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &ParseError{Line: p.line, Column: p.col, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) peek() rune {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) peekAt(offset int) rune {
+	if p.pos+offset >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos+offset]
+}
+
+func (p *parser) hasPrefix(s string) bool {
+	for i, r := range []rune(s) {
+		if p.peekAt(i) != r {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *parser) next() rune {
+	r := p.src[p.pos]
+	p.pos++
+	if r == '\n' {
+		p.line++
+		p.col = 1
+	} else {
+		p.col++
+	}
+	return r
+}
+
+func (p *parser) skip(n int) {
+	for i := 0; i < n && !p.eof(); i++ {
+		p.next()
+	}
+}
+
+func isNewline(r rune) bool {
+	switch r {
+	case '\n', '\r', '\u0085', '\u000C', '\u2028', '\u2029':
+		return true
+	}
+	return false
+}
+
+func isSpace(r rune) bool {
+	return !isNewline(r) && unicode.IsSpace(r) || r == '\uFEFF'
+}
+
+// isIdentChar reports whether r may appear in a bare identifier.
+func isIdentChar(r rune) bool {
+	if unicode.IsSpace(r) || r == 0 {
+		return false
+	}
+	return !strings.ContainsRune(`\/(){}[]<>;=,"#`, r)
+}
+
+// skipLineComment skips a // comment up to, but not including, the newline.
+func (p *parser) skipLineComment() {
+	for !p.eof() && !isNewline(p.peek()) {
+		p.next()
+	}
+}
+
+// skipBlockComment skips a possibly nested /* */ comment.
+func (p *parser) skipBlockComment() error {
+	p.skip(2)
+	depth := 1
+	for depth > 0 {
+		switch {
+		case p.eof():
+			return p.errorf("unterminated block comment")
+		case p.hasPrefix("/*"):
+			p.skip(2)
+			depth++
+		case p.hasPrefix("*/"):
+			p.skip(2)
+			depth--
+		default:
+			p.next()
+		}
+	}
+	return nil
+}
+
+// skipLineSpace skips whitespace, newlines, semicolons and comments
+// between nodes.
+func (p *parser) skipLineSpace() error {
+	for !p.eof() {
+		r := p.peek()
+		switch {
+		case isSpace(r) || isNewline(r) || r == ';':
+			p.next()
+		case p.hasPrefix("//"):
+			p.skipLineComment()
+		case p.hasPrefix("/*"):
+			if err := p.skipBlockComment(); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// skipNodeSpace skips whitespace, block comments and line continuations
+// within a node.
+func (p *parser) skipNodeSpace() error {
+	for !p.eof() {
+		r := p.peek()
+		switch {
+		case isSpace(r):
+			p.next()
+		case p.hasPrefix("/*"):
+			if err := p.skipBlockComment(); err != nil {
+				return err
+			}
+		case r == '\\':
+			// Line continuation: \ [space] [// comment] newline
+			p.next()
+			for !p.eof() && isSpace(p.peek()) {
+				p.next()
+			}
+			if p.hasPrefix("//") {
+				p.skipLineComment()
+			}
+			if p.eof() {
+				return nil
+			}
+			if !isNewline(p.peek()) {
+				return p.errorf("expected newline after line continuation")
+			}
+			p.next()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// nodes parses nodes until the end of input or, in a children block, the
+// closing brace.
+func (p *parser) nodes(inBlock bool) ([]*Node, error) {
+	var nodes []*Node
+	for {
+		if err := p.skipLineSpace(); err != nil {
+			return nil, err
+		}
+		if p.eof() {
+			if inBlock {
+				return nil, p.errorf("unterminated children block")
+			}
+			return nodes, nil
+		}
+		if p.peek() == '}' {
+			if !inBlock {
+				return nil, p.errorf("unexpected '}'")
+			}
+			p.next()
+			return nodes, nil
+		}
+
+		discard := false
+		if p.hasPrefix("/-") {
+			p.skip(2)
+			if err := p.skipLineSpace(); err != nil {
+				return nil, err
+			}
+			discard = true
+		}
+
+		node, err := p.node()
+		if err != nil {
+			return nil, err
+		}
+		if !discard {
+			nodes = append(nodes, node)
+		}
+	}
+}
+
+// node parses a single node up to its terminator.
+func (p *parser) node() (*Node, error) {
+	n := &Node{}
+
+	typ, err := p.typeAnnotation()
+	if err != nil {
+		return nil, err
+	}
+	n.Type = typ
+
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	n.Name = name
+
+	for {
+		if err := p.skipNodeSpace(); err != nil {
+			return nil, err
+		}
+		if p.eof() {
+			return n, nil
+		}
+
+		r := p.peek()
+		switch {
+		case isNewline(r) || r == ';':
+			p.next()
+			return n, nil
+		case r == '}':
+			return n, nil
+		case p.hasPrefix("//"):
+			p.skipLineComment()
+			return n, nil
+		case r == '{':
+			p.next()
+			children, err := p.nodes(true)
+			if err != nil {
+				return nil, err
+			}
+			n.Children = append(n.Children, children...)
+		case p.hasPrefix("/-"):
+			p.skip(2)
+			if err := p.skipNodeSpace(); err != nil {
+				return nil, err
+			}
+			if p.peek() == '{' {
+				p.next()
+				if _, err := p.nodes(true); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if _, _, _, err := p.entry(); err != nil {
+				return nil, err
+			}
+		default:
+			if len(n.Children) > 0 {
+				return nil, p.errorf("unexpected entry after children block")
+			}
+			propName, v, isProp, err := p.entry()
+			if err != nil {
+				return nil, err
+			}
+			if isProp {
+				n.SetProp(propName, v)
+			} else {
+				n.Args = append(n.Args, v)
+			}
+		}
+	}
+}
+
+// typeAnnotation parses an optional (type) annotation.
+func (p *parser) typeAnnotation() (string, error) {
+	if p.peek() != '(' {
+		return "", nil
+	}
+	p.next()
+	typ, err := p.name()
+	if err != nil {
+		return "", err
+	}
+	if p.peek() != ')' {
+		return "", p.errorf("expected ')' after type annotation")
+	}
+	p.next()
+	return typ, nil
+}
+
+// name parses a node name, property name or type: a bare identifier or a
+// string.
+func (p *parser) name() (string, error) {
+	r := p.peek()
+	if r == '"' || p.isRawStringStart() {
+		v, err := p.value()
+		if err != nil {
+			return "", err
+		}
+		return v.Str, nil
+	}
+	ident := p.identifier()
+	if ident == "" {
+		return "", p.errorf("expected identifier, found %q", string(r))
+	}
+	return ident, nil
+}
+
+func (p *parser) identifier() string {
+	start := p.pos
+	for !p.eof() && isIdentChar(p.peek()) {
+		p.next()
+	}
+	return string(p.src[start:p.pos])
+}
+
+// entry parses an argument or a name=value property.
+func (p *parser) entry() (name string, v Value, isProp bool, err error) {
+	typ, err := p.typeAnnotation()
+	if err != nil {
+		return "", Value{}, false, err
+	}
+
+	// A property name is an identifier or string followed by '='
+	start, line, col := p.pos, p.line, p.col
+	if typ == "" && (p.peek() == '"' || p.isRawStringStart() || (isIdentChar(p.peek()) && !p.isNumberStart())) {
+		if key, err := p.name(); err == nil && p.peek() == '=' {
+			p.next()
+			v, err := p.typedValue()
+			return key, v, true, err
+		}
+		p.pos, p.line, p.col = start, line, col
+	}
+
+	v, err = p.value()
+	v.Type = typ
+	return "", v, false, err
+}
+
+// typedValue parses a value with an optional type annotation.
+func (p *parser) typedValue() (Value, error) {
+	typ, err := p.typeAnnotation()
+	if err != nil {
+		return Value{}, err
+	}
+	v, err := p.value()
+	v.Type = typ
+	return v, err
+}
+
+func (p *parser) isNumberStart() bool {
+	r := p.peek()
+	if r >= '0' && r <= '9' {
+		return true
+	}
+	if r == '+' || r == '-' {
+		next := p.peekAt(1)
+		return next >= '0' && next <= '9'
+	}
+	return false
+}
+
+func (p *parser) isRawStringStart() bool {
+	i := 0
+	if p.peek() == 'r' {
+		i = 1
+	}
+	for p.peekAt(i) == '#' {
+		i++
+	}
+	// KDL 1 raw strings start with r, KDL 2 ones with at least one #
+	return p.peekAt(i) == '"' && (p.peek() == 'r' || i > 0)
+}
+
+// value parses a string, number, keyword or bare identifier value.
+func (p *parser) value() (Value, error) {
+	switch r := p.peek(); {
+	case p.isRawStringStart():
+		return p.rawString()
+	case r == '"':
+		return p.quotedString()
+	case r == '#':
+		word := "#" + func() string { p.next(); return p.identifier() }()
+		switch word {
+		case "#true", "#false":
+			return Value{Kind: KindBool, Raw: word}, nil
+		case "#null":
+			return Value{Kind: KindNull, Raw: word}, nil
+		case "#inf", "#-inf", "#nan":
+			return Value{Kind: KindNumber, Raw: word}, nil
+		}
+		return Value{}, p.errorf("unknown keyword %q", word)
+	case p.isNumberStart():
+		lit := p.identifier()
+		if !validNumber(lit) {
+			return Value{}, p.errorf("invalid number %q", lit)
+		}
+		return Value{Kind: KindNumber, Raw: lit}, nil
+	}
+
+	word := p.identifier()
+	switch word {
+	case "":
+		return Value{}, p.errorf("expected value, found %q", string(p.peek()))
+	case "true", "false":
+		return Value{Kind: KindBool, Raw: word}, nil
+	case "null":
+		return Value{Kind: KindNull, Raw: word}, nil
+	}
+	// KDL 2 bare identifiers are strings
+	return String(word), nil
+}
+
+// validNumber checks a decimal, hex, octal or binary literal.
+func validNumber(lit string) bool {
+	s := strings.TrimLeft(lit, "+-")
+	if len(s) != len(lit) && len(lit)-len(s) > 1 {
+		return false
+	}
+	s = strings.ReplaceAll(s, "_", "")
+	for _, prefix := range []struct {
+		p    string
+		base int
+	}{{"0x", 16}, {"0o", 8}, {"0b", 2}} {
+		if strings.HasPrefix(s, prefix.p) {
+			_, err := strconv.ParseUint(s[2:], prefix.base, 64)
+			return err == nil || strings.Contains(err.Error(), "out of range")
+		}
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil || strings.Contains(err.Error(), "out of range")
+}
+
+// quotedString parses a "..." string with escapes.
+func (p *parser) quotedString() (Value, error) {
+	p.next() // opening quote
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return Value{}, p.errorf("unterminated string")
+		}
+		r := p.next()
+		switch r {
+		case '"':
+			return String(b.String()), nil
+		case '\\':
+			if err := p.escape(&b); err != nil {
+				return Value{}, err
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+func (p *parser) escape(b *strings.Builder) error {
+	if p.eof() {
+		return p.errorf("unterminated escape")
+	}
+	r := p.next()
+	switch r {
+	case 'n':
+		b.WriteRune('\n')
+	case 'r':
+		b.WriteRune('\r')
+	case 't':
+		b.WriteRune('\t')
+	case 'b':
+		b.WriteRune('\b')
+	case 'f':
+		b.WriteRune('\f')
+	case 's':
+		b.WriteRune(' ')
+	case '\\', '"', '/':
+		b.WriteRune(r)
+	case 'u':
+		if p.peek() != '{' {
+			return p.errorf(`expected '{' after \u`)
+		}
+		p.next()
+		start := p.pos
+		for !p.eof() && p.peek() != '}' {
+			p.next()
+		}
+		if p.eof() {
+			return p.errorf("unterminated unicode escape")
+		}
+		code, err := strconv.ParseUint(string(p.src[start:p.pos]), 16, 32)
+		if err != nil || code > unicode.MaxRune {
+			return p.errorf("invalid unicode escape")
+		}
+		p.next()
+		b.WriteRune(rune(code))
+	default:
+		if unicode.IsSpace(r) {
+			// KDL 2 whitespace escape: drop all following whitespace
+			for !p.eof() && unicode.IsSpace(p.peek()) {
+				p.next()
+			}
+			return nil
+		}
+		return p.errorf("invalid escape \\%c", r)
+	}
+	return nil
+}
+
+// rawString parses r#"..."# (KDL 1) or #"..."# (KDL 2).
+func (p *parser) rawString() (Value, error) {
+	if p.peek() == 'r' {
+		p.next()
+	}
+	hashes := 0
+	for p.peek() == '#' {
+		p.next()
+		hashes++
+	}
+	p.next() // opening quote
+
+	closing := `"` + strings.Repeat("#", hashes)
+	start := p.pos
+	for {
+		if p.eof() {
+			return Value{}, p.errorf("unterminated raw string")
+		}
+		if p.hasPrefix(closing) {
+			s := string(p.src[start:p.pos])
+			p.skip(len(closing))
+			return String(s), nil
+		}
+		p.next()
+	}
+}
@@ -0,0 +1,78 @@
+// Package notify delivers user-facing notifications such as password reset links.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Message is a notification addressed to a single recipient.
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Notifier delivers messages to users.
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogNotifier writes messages to the application log. It is meant for
+// development, where no mail server is available.
+type LogNotifier struct{}
+
+// Send logs the message.
+func (LogNotifier) Send(ctx context.Context, msg Message) error {
+	log.Info().
+		Str("to", msg.To).
+		Str("subject", msg.Subject).
+		Str("body", msg.Body).
+		Msg("Notification")
+	return nil
+}
+
+// FileNotifier appends messages as JSON lines to a local file.
+type FileNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileNotifier creates a notifier that appends to the file at path.
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+// fileEntry is one line in a FileNotifier's output.
+type fileEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Message
+}
+
+// Send appends the message to the file.
+func (n *FileNotifier) Send(ctx context.Context, msg Message) error {
+	line, err := json.Marshal(fileEntry{Timestamp: time.Now().UTC(), Message: msg})
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open notification file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write notification: %w", err)
+	}
+	return nil
+}
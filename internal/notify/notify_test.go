@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileNotifier_Send(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.jsonl")
+	n := NewFileNotifier(path)
+	ctx := context.Background()
+
+	for _, to := range []string{"a@example.com", "b@example.com"} {
+		if err := n.Send(ctx, Message{To: to, Subject: "Hello", Body: "line one\nline two"}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+
+	var got []Message
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var msg Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		got = append(got, msg)
+	}
+
+	if len(got) != 2 || got[1].To != "b@example.com" || got[0].Body != "line one\nline two" {
+		t.Errorf("unexpected messages: %+v", got)
+	}
+}
+
+func TestSMTPNotifier_Send(t *testing.T) {
+	if _, err := NewSMTPNotifier(SMTPConfig{Addr: "localhost", From: "hub@example.com"}); err == nil {
+		t.Error("address without port should be rejected")
+	}
+	if _, err := NewSMTPNotifier(SMTPConfig{Addr: "localhost:25"}); err == nil {
+		t.Error("missing sender should be rejected")
+	}
+
+	n, err := NewSMTPNotifier(SMTPConfig{Addr: "mail.example.com:587", Username: "hub", Password: "secret", From: "hub@example.com"})
+	if err != nil {
+		t.Fatalf("NewSMTPNotifier failed: %v", err)
+	}
+
+	var gotAddr string
+	var gotAuth smtp.Auth
+	var gotTo []string
+	var gotMsg []byte
+	n.send = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotAuth, gotTo, gotMsg = addr, a, to, msg
+		return nil
+	}
+
+	err = n.Send(context.Background(), Message{
+		To:      "user@example.com",
+		Subject: "Reset\r\nBcc: attacker@example.com",
+		Body:    "hello\nworld",
+	})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	if gotAddr != "mail.example.com:587" || gotAuth == nil || len(gotTo) != 1 || gotTo[0] != "user@example.com" {
+		t.Errorf("unexpected delivery: addr=%s auth=%v to=%v", gotAddr, gotAuth, gotTo)
+	}
+
+	headers, body, _ := strings.Cut(string(gotMsg), "\r\n\r\n")
+	if strings.Contains(headers, "\r\nBcc:") {
+		t.Errorf("header injection not prevented:\n%s", headers)
+	}
+	if body != "hello\r\nworld" {
+		t.Errorf("body = %q", body)
+	}
+}
@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPConfig holds SMTP delivery configuration.
+type SMTPConfig struct {
+	// Addr is the mail server address as host:port.
+	Addr string
+	// Username and Password enable PLAIN authentication when set.
+	Username string
+	Password string
+	// From is the sender address.
+	From string
+}
+
+// SMTPNotifier sends messages as plain-text email.
+type SMTPNotifier struct {
+	config SMTPConfig
+	send   func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTPNotifier creates a notifier that delivers through an SMTP server.
+func NewSMTPNotifier(config SMTPConfig) (*SMTPNotifier, error) {
+	if config.Addr == "" {
+		return nil, fmt.Errorf("smtp address is required")
+	}
+	if _, _, err := net.SplitHostPort(config.Addr); err != nil {
+		return nil, fmt.Errorf("invalid smtp address %q: %w", config.Addr, err)
+	}
+	if config.From == "" {
+		return nil, fmt.Errorf("smtp sender address is required")
+	}
+	return &SMTPNotifier{config: config, send: smtp.SendMail}, nil
+}
+
+// Send delivers the message by email. smtp.SendMail upgrades to TLS when the
+// server supports STARTTLS.
+func (n *SMTPNotifier) Send(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+	if n.config.Username != "" {
+		host, _, _ := net.SplitHostPort(n.config.Addr)
+		auth = smtp.PlainAuth("", n.config.Username, n.config.Password, host)
+	}
+
+	if err := n.send(n.config.Addr, auth, n.config.From, []string{msg.To}, n.format(msg)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// format renders the message as an RFC 5322 email.
+func (n *SMTPNotifier) format(msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", headerValue(n.config.From))
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// headerValue strips line breaks so values cannot inject extra headers.
+func headerValue(v string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(v)
+}
@@ -0,0 +1,128 @@
+// Package render renders config templates for individual instances.
+//
+// Templates use Go's text/template syntax and see the instance's ID, name,
+// hostname and labels along with hub-managed variables:
+//
+//	server {
+//	    listen "{{ .Vars.listen }}"
+//	    name {{ quote .Name }}
+//	}
+//	upstream "api" {
+//	    target "{{ index .Vars (printf "upstream_%s" .Labels.region) }}"
+//	}
+//
+// Referring to a missing label or variable with dot syntax is an error, so a
+// template never renders with a silently empty value. Use index and default
+// for optional values: {{ index .Labels "zone" | default "a" }}.
+package render
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"text/template"
+
+	"github.com/raskell-io/sentinel-hub/internal/kdl"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+// ErrTemplate is returned for templates that do not parse or render, as
+// opposed to failures loading their variables.
+var ErrTemplate = errors.New("config template")
+
+// Data is what a template is rendered with.
+type Data struct {
+	ID       string
+	Name     string
+	Hostname string
+	Labels   map[string]string
+	// Vars holds fleet-wide variables overridden by the instance's own.
+	Vars map[string]string
+}
+
+// NewData builds template data for an instance. Later variable lists take
+// precedence, so pass fleet-wide variables before instance variables.
+func NewData(inst *store.Instance, vars ...[]store.Variable) Data {
+	d := Data{
+		ID:       inst.ID,
+		Name:     inst.Name,
+		Hostname: inst.Hostname,
+		Labels:   make(map[string]string, len(inst.Labels)),
+		Vars:     make(map[string]string),
+	}
+	for k, v := range inst.Labels {
+		d.Labels[k] = v
+	}
+	for _, list := range vars {
+		for _, v := range list {
+			d.Vars[v.Name] = v.Value
+		}
+	}
+	return d
+}
+
+var funcs = template.FuncMap{
+	// quote renders a string as a quoted KDL string.
+	"quote": kdl.Quote,
+	// default returns def when value is empty.
+	"default": func(def, value string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	},
+}
+
+// Parse parses a template, reporting syntax errors before it is stored.
+func Parse(content string) (*template.Template, error) {
+	t, err := template.New("config").Funcs(funcs).Option("missingkey=error").Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTemplate, err)
+	}
+	return t, nil
+}
+
+// Render renders a template with the given data.
+func Render(content string, data Data) (string, error) {
+	t, err := Parse(content)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrTemplate, err)
+	}
+	return buf.String(), nil
+}
+
+// Hash returns the content hash agents report for the given content.
+func Hash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// ForInstance returns the content and hash of a config version as delivered
+// to an instance. Versions that are not templates are returned as stored.
+func ForInstance(ctx context.Context, s store.Store, inst *store.Instance, ver *store.ConfigVersion) (content, hash string, err error) {
+	if !ver.IsTemplate {
+		return ver.Content, ver.ContentHash, nil
+	}
+
+	fleetVars, err := s.ListVariables(ctx, "")
+	if err != nil {
+		return "", "", err
+	}
+	instanceVars, err := s.ListVariables(ctx, inst.ID)
+	if err != nil {
+		return "", "", err
+	}
+
+	content, err = Render(ver.Content, NewData(inst, fleetVars, instanceVars))
+	if err != nil {
+		return "", "", err
+	}
+	return content, Hash(content), nil
+}
@@ -0,0 +1,60 @@
+package render
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+func TestRender(t *testing.T) {
+	inst := &store.Instance{
+		ID:       "inst-1",
+		Name:     "edge-1",
+		Hostname: "edge-1.eu.example.com",
+		Labels:   map[string]string{"region": "eu"},
+	}
+	data := NewData(inst,
+		[]store.Variable{{Name: "listen", Value: "0.0.0.0:8080"}, {Name: "upstream_eu", Value: "10.0.0.1:80"}},
+		[]store.Variable{{Name: "listen", Value: "10.1.2.3:8080"}},
+	)
+
+	content := `server {
+    listen "{{ .Vars.listen }}"
+    name {{ quote .Hostname }}
+    zone "{{ index .Labels "zone" | default "a" }}"
+}
+upstream "api" {
+    target "{{ index .Vars (printf "upstream_%s" .Labels.region) }}"
+}
+`
+	want := `server {
+    listen "10.1.2.3:8080"
+    name "edge-1.eu.example.com"
+    zone "a"
+}
+upstream "api" {
+    target "10.0.0.1:80"
+}
+`
+	got, err := Render(content, data)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRender_Errors(t *testing.T) {
+	data := NewData(&store.Instance{ID: "inst-1"})
+
+	if _, err := Render("listen {{ .Vars.listen", data); !errors.Is(err, ErrTemplate) {
+		t.Errorf("expected ErrTemplate for a syntax error, got %v", err)
+	}
+	_, err := Render(`listen "{{ .Vars.listen }}"`, data)
+	if !errors.Is(err, ErrTemplate) || !strings.Contains(err.Error(), "listen") {
+		t.Errorf("expected ErrTemplate naming the missing variable, got %v", err)
+	}
+}
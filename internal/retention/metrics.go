@@ -0,0 +1,34 @@
+package retention
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	deletedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hub_retention_deleted_total",
+		Help: "Records deleted by retention sweeps, by kind.",
+	}, []string{"kind"})
+
+	archivedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "hub_retention_archived_audit_logs_total",
+		Help: "Audit logs archived to file before deletion.",
+	})
+
+	sweepErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hub_retention_errors_total",
+		Help: "Failed retention sweep steps, by kind.",
+	}, []string{"kind"})
+
+	sweepDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "hub_retention_sweep_duration_seconds",
+		Help:    "Duration of retention sweeps.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 4, 8),
+	})
+
+	lastSweep = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "hub_retention_last_sweep_timestamp_seconds",
+		Help: "Unix time of the last retention sweep.",
+	})
+)
@@ -0,0 +1,231 @@
+// Package retention periodically deletes data the hub no longer needs:
+// finished deployments, old audit logs, expired sessions and unused config
+// versions.
+package retention
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// auditArchivePageSize is the number of audit logs read per query while archiving.
+const auditArchivePageSize = 1000
+
+// Config controls what a sweep deletes. A zero retention keeps that kind of
+// data forever; expired sessions and reset tokens are always deleted.
+type Config struct {
+	// Interval between sweeps.
+	Interval time.Duration
+	// Deployments is how long finished deployments are kept.
+	Deployments time.Duration
+	// AuditLogs is how long audit logs are kept.
+	AuditLogs time.Duration
+	// AuditArchiveDir, if set, receives a gzipped JSONL file of the audit
+	// logs before they are deleted.
+	AuditArchiveDir string
+	// ConfigVersions is how long unused config versions are kept.
+	ConfigVersions time.Duration
+	// ConfigVersionsKeep is how many of each config's newest versions are
+	// kept regardless of age.
+	ConfigVersionsKeep int
+}
+
+// DefaultConfig returns a config that only cleans up expired credentials.
+func DefaultConfig() Config {
+	return Config{
+		Interval:           time.Hour,
+		ConfigVersionsKeep: 10,
+	}
+}
+
+// Result counts what a sweep removed.
+type Result struct {
+	Deployments         int64         `json:"deployments"`
+	AuditLogs           int64         `json:"audit_logs"`
+	ArchivedAuditLogs   int64         `json:"archived_audit_logs"`
+	UserSessions        int64         `json:"user_sessions"`
+	AgentSessions       int64         `json:"agent_sessions"`
+	PasswordResetTokens int64         `json:"password_reset_tokens"`
+	ConfigVersions      int64         `json:"config_versions"`
+	Duration            time.Duration `json:"duration"`
+}
+
+// Sweeper applies a retention config to the store.
+type Sweeper struct {
+	store  store.Store
+	config Config
+	now    func() time.Time
+}
+
+// NewSweeper creates a sweeper.
+func NewSweeper(s store.Store, config Config) *Sweeper {
+	if config.Interval <= 0 {
+		config.Interval = DefaultConfig().Interval
+	}
+	return &Sweeper{store: s, config: config, now: time.Now}
+}
+
+// Run sweeps immediately and then once per interval until ctx is cancelled.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Sweep(ctx); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("Retention sweep failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep runs every cleanup step once. A failing step does not stop the
+// others; their errors are joined.
+func (s *Sweeper) Sweep(ctx context.Context) (*Result, error) {
+	start := time.Now()
+	now := s.now().UTC()
+	result := &Result{}
+	var errs []error
+
+	step := func(kind string, dst *int64, fn func() (int64, error)) {
+		n, err := fn()
+		if err != nil {
+			sweepErrors.WithLabelValues(kind).Inc()
+			errs = append(errs, fmt.Errorf("%s: %w", kind, err))
+			return
+		}
+		*dst = n
+		deletedTotal.WithLabelValues(kind).Add(float64(n))
+	}
+
+	if s.config.Deployments > 0 {
+		step("deployments", &result.Deployments, func() (int64, error) {
+			return s.store.DeleteDeploymentsBefore(ctx, now.Add(-s.config.Deployments))
+		})
+	}
+
+	if s.config.AuditLogs > 0 {
+		step("audit_logs", &result.AuditLogs, func() (int64, error) {
+			until := now.Add(-s.config.AuditLogs)
+			if s.config.AuditArchiveDir != "" {
+				archived, err := s.archiveAuditLogs(ctx, until)
+				if err != nil {
+					return 0, err
+				}
+				result.ArchivedAuditLogs = archived
+				archivedTotal.Add(float64(archived))
+			}
+			return s.store.DeleteAuditLogsUntil(ctx, until)
+		})
+	}
+
+	step("user_sessions", &result.UserSessions, func() (int64, error) {
+		return s.store.CleanupExpiredSessions(ctx)
+	})
+	step("agent_sessions", &result.AgentSessions, func() (int64, error) {
+		return s.store.CleanupExpiredAgentSessions(ctx)
+	})
+	step("password_reset_tokens", &result.PasswordResetTokens, func() (int64, error) {
+		return s.store.CleanupExpiredPasswordResetTokens(ctx)
+	})
+
+	// After deployments, so versions only they referenced can go too
+	if s.config.ConfigVersions > 0 {
+		step("config_versions", &result.ConfigVersions, func() (int64, error) {
+			return s.store.DeleteUnusedConfigVersions(ctx, now.Add(-s.config.ConfigVersions), s.config.ConfigVersionsKeep)
+		})
+	}
+
+	result.Duration = time.Since(start)
+	sweepDuration.Observe(result.Duration.Seconds())
+	lastSweep.Set(float64(now.Unix()))
+
+	log.Info().
+		Int64("deployments", result.Deployments).
+		Int64("audit_logs", result.AuditLogs).
+		Int64("archived_audit_logs", result.ArchivedAuditLogs).
+		Int64("user_sessions", result.UserSessions).
+		Int64("agent_sessions", result.AgentSessions).
+		Int64("password_reset_tokens", result.PasswordResetTokens).
+		Int64("config_versions", result.ConfigVersions).
+		Dur("duration", result.Duration).
+		Int("errors", len(errs)).
+		Msg("Retention sweep completed")
+
+	return result, errors.Join(errs...)
+}
+
+// archiveAuditLogs writes the audit logs up to until, oldest first, to a new
+// gzipped JSONL file and returns how many it wrote.
+func (s *Sweeper) archiveAuditLogs(ctx context.Context, until time.Time) (int64, error) {
+	if err := os.MkdirAll(s.config.AuditArchiveDir, 0700); err != nil {
+		return 0, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.config.AuditArchiveDir, ".audit-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	gz := gzip.NewWriter(tmp)
+	enc := json.NewEncoder(gz)
+
+	var written int64
+	for offset := 0; ; offset += auditArchivePageSize {
+		logs, err := s.store.ListAuditLogs(ctx, store.ListAuditLogsOptions{
+			Until:       &until,
+			OldestFirst: true,
+			Limit:       auditArchivePageSize,
+			Offset:      offset,
+		})
+		if err != nil {
+			return 0, err
+		}
+		for _, entry := range logs {
+			if err := enc.Encode(entry); err != nil {
+				return 0, fmt.Errorf("failed to write archive: %w", err)
+			}
+			written++
+		}
+		if len(logs) < auditArchivePageSize {
+			break
+		}
+	}
+
+	// Nothing to delete, so no archive either
+	if written == 0 {
+		return 0, nil
+	}
+
+	if err := gz.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return 0, fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	name := fmt.Sprintf("audit-%s.jsonl.gz", until.Format("20060102T150405Z"))
+	if err := os.Rename(tmp.Name(), filepath.Join(s.config.AuditArchiveDir, name)); err != nil {
+		return 0, fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	return written, nil
+}
@@ -0,0 +1,117 @@
+package retention
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+func setupTestStore(t *testing.T) store.Store {
+	t.Helper()
+
+	s, err := store.New(filepath.Join(t.TempDir(), "hub.db"))
+	if err != nil {
+		t.Fatalf("failed to create test store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSweeper_Sweep(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestStore(t)
+
+	user := &store.User{Email: "ops@example.com", Name: "Ops", Role: store.UserRoleAdmin}
+	if err := s.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+	expired := &store.UserSession{UserID: user.ID, RefreshTokenHash: "expired", ExpiresAt: time.Now().Add(-time.Hour)}
+	if err := s.CreateUserSession(ctx, expired); err != nil {
+		t.Fatalf("CreateUserSession failed: %v", err)
+	}
+	active := &store.UserSession{UserID: user.ID, RefreshTokenHash: "active", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := s.CreateUserSession(ctx, active); err != nil {
+		t.Fatalf("CreateUserSession failed: %v", err)
+	}
+
+	for _, action := range []string{"create", "update"} {
+		if err := s.CreateAuditLog(ctx, &store.AuditLog{Action: action, ResourceType: "config"}); err != nil {
+			t.Fatalf("CreateAuditLog failed: %v", err)
+		}
+	}
+
+	archiveDir := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.AuditLogs = 24 * time.Hour
+	cfg.AuditArchiveDir = archiveDir
+	sweeper := NewSweeper(s, cfg)
+	// Sweep two days from now, when the audit logs are past retention
+	sweeper.now = func() time.Time { return time.Now().Add(48 * time.Hour) }
+
+	result, err := sweeper.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+	if result.UserSessions != 1 {
+		t.Errorf("expected 1 expired session deleted, got %d", result.UserSessions)
+	}
+	if result.AuditLogs != 2 || result.ArchivedAuditLogs != 2 {
+		t.Errorf("expected 2 audit logs archived and deleted, got %+v", result)
+	}
+
+	if session, _ := s.GetUserSession(ctx, active.ID); session == nil {
+		t.Error("expected active session to be kept")
+	}
+
+	// The archive holds the deleted logs, oldest first
+	files, _ := filepath.Glob(filepath.Join(archiveDir, "audit-*.jsonl.gz"))
+	if len(files) != 1 {
+		t.Fatalf("expected 1 archive file, got %v", files)
+	}
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+
+	var actions []string
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var entry store.AuditLog
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid archive line: %v", err)
+		}
+		actions = append(actions, entry.Action)
+	}
+	if len(actions) != 2 || actions[0] != "create" {
+		t.Errorf("expected archived actions [create update], got %v", actions)
+	}
+}
+
+func TestSweeper_Sweep_DisabledByDefault(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestStore(t)
+
+	if err := s.CreateAuditLog(ctx, &store.AuditLog{Action: "create", ResourceType: "config"}); err != nil {
+		t.Fatalf("CreateAuditLog failed: %v", err)
+	}
+
+	result, err := NewSweeper(s, DefaultConfig()).Sweep(ctx)
+	if err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+	if result.AuditLogs != 0 || result.Deployments != 0 || result.ConfigVersions != 0 {
+		t.Errorf("expected nothing deleted by default, got %+v", result)
+	}
+}
@@ -0,0 +1,329 @@
+// Package secrets stores encrypted values that configs refer to by name
+// and resolves those references when an agent fetches its config:
+//
+//	upstream "api" {
+//	    tls {
+//	        cert secret="api-cert"
+//	        key secret="api-key"
+//	    }
+//	}
+//
+// Each value is encrypted with AES-256-GCM under its own random data key,
+// and the data key is encrypted under the hub's master key (envelope
+// encryption). The master key lives in a local file, never in the database.
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/raskell-io/sentinel-hub/internal/kdl"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+// MasterKeySize is the size of the master key and of each data key.
+const MasterKeySize = 32
+
+// ReferenceProperty is the node property that refers to a secret.
+const ReferenceProperty = "secret"
+
+var (
+	// ErrNotFound is returned for secrets that do not exist.
+	ErrNotFound = errors.New("secret not found")
+
+	// ErrReference is returned when a config's secret references cannot be
+	// resolved, such as when a referenced secret does not exist.
+	ErrReference = errors.New("unresolved secret reference")
+)
+
+// LoadMasterKey reads a base64-encoded master key from path. If the file
+// does not exist, a new key is generated and written there, readable only
+// by the hub.
+func LoadMasterKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return generateMasterKey(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets master key: %w", err)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != MasterKeySize {
+		return nil, fmt.Errorf("secrets master key in %s must be %d base64-encoded bytes", path, MasterKeySize)
+	}
+	return key, nil
+}
+
+func generateMasterKey(path string) ([]byte, error) {
+	key := make([]byte, MasterKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate secrets master key: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create secrets master key directory: %w", err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(key) + "\n"
+	// O_EXCL so that two hubs starting at once cannot overwrite each other's key
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write secrets master key: %w", err)
+	}
+	if _, err := f.WriteString(encoded); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write secrets master key: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write secrets master key: %w", err)
+	}
+	return key, nil
+}
+
+// Keeper encrypts, stores and resolves secrets.
+type Keeper struct {
+	store store.Store
+	key   cipher.AEAD
+	keyID string
+}
+
+// NewKeeper creates a keeper that encrypts data keys with masterKey.
+func NewKeeper(s store.Store, masterKey []byte) (*Keeper, error) {
+	if len(masterKey) != MasterKeySize {
+		return nil, fmt.Errorf("secrets master key must be %d bytes", MasterKeySize)
+	}
+	aead, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(masterKey)
+	return &Keeper{store: s, key: aead, keyID: hex.EncodeToString(sum[:8])}, nil
+}
+
+// Set encrypts a value under a new data key and stores it, replacing the
+// secret's previous value and description.
+func (k *Keeper) Set(ctx context.Context, name string, description *string, value string, updatedBy *string) (*store.Secret, error) {
+	dataKey := make([]byte, MasterKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// The name is authenticated with both layers, so encrypted values
+	// cannot be swapped between secrets in the database
+	ciphertext, err := seal(aead, []byte(value), name)
+	if err != nil {
+		return nil, err
+	}
+	encryptedKey, err := seal(k.key, dataKey, name)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := &store.Secret{
+		Name:         name,
+		Description:  description,
+		Ciphertext:   ciphertext,
+		EncryptedKey: encryptedKey,
+		KeyID:        k.keyID,
+		UpdatedBy:    updatedBy,
+	}
+	if err := k.store.SetSecret(ctx, secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// Value returns the decrypted value of a secret.
+func (k *Keeper) Value(ctx context.Context, name string) (string, error) {
+	secret, err := k.store.GetSecret(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if secret == nil {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if secret.KeyID != k.keyID {
+		return "", fmt.Errorf("secret %s is encrypted with a different master key (%s)", name, secret.KeyID)
+	}
+
+	dataKey, err := open(k.key, secret.EncryptedKey, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt data key of secret %s: %w", name, err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	value, err := open(aead, secret.Ciphertext, name)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret %s: %w", name, err)
+	}
+	return string(value), nil
+}
+
+// Resolve replaces each secret="name" property in a config with the
+// secret's value as an argument of the same node. Configs without
+// references are returned unchanged; others are reformatted.
+func (k *Keeper) Resolve(ctx context.Context, content string) (string, error) {
+	if !strings.Contains(content, ReferenceProperty) {
+		return content, nil
+	}
+	nodes, err := kdl.Parse(content)
+	if err != nil {
+		// Configs that are not valid KDL cannot hold references we can find
+		return content, nil
+	}
+
+	values := make(map[string]string)
+	found, err := k.resolve(ctx, nodes, values)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return content, nil
+	}
+	return kdl.Format(nodes), nil
+}
+
+func (k *Keeper) resolve(ctx context.Context, nodes []*kdl.Node, values map[string]string) (bool, error) {
+	found := false
+	for _, n := range nodes {
+		props := n.Props[:0]
+		for _, p := range n.Props {
+			if p.Name != ReferenceProperty || p.Value.Kind != kdl.KindString {
+				props = append(props, p)
+				continue
+			}
+			name := p.Value.Str
+			value, ok := values[name]
+			if !ok {
+				v, err := k.Value(ctx, name)
+				if errors.Is(err, ErrNotFound) {
+					return false, fmt.Errorf("%w: %s does not exist", ErrReference, name)
+				}
+				if err != nil {
+					return false, fmt.Errorf("%w: %v", ErrReference, err)
+				}
+				values[name], value = v, v
+			}
+			n.Args = append(n.Args, kdl.String(value))
+			found = true
+		}
+		n.Props = props
+
+		childFound, err := k.resolve(ctx, n.Children, values)
+		if err != nil {
+			return false, err
+		}
+		found = found || childFound
+	}
+	return found, nil
+}
+
+// References returns the names of the secrets a config refers to, in order
+// of first use. Configs that are not valid KDL have none.
+func References(content string) []string {
+	if !strings.Contains(content, ReferenceProperty) {
+		return nil
+	}
+	nodes, err := kdl.Parse(content)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	var walk func([]*kdl.Node)
+	walk = func(nodes []*kdl.Node) {
+		for _, n := range nodes {
+			if v, ok := n.Prop(ReferenceProperty); ok && v.Kind == kdl.KindString && !seen[v.Str] {
+				seen[v.Str] = true
+				names = append(names, v.Str)
+			}
+			walk(n.Children)
+		}
+	}
+	walk(nodes)
+	return names
+}
+
+// templateReference matches a secret property in raw template text, with
+// its value quoted or not.
+var templateReference = regexp.MustCompile(`(?:^|[^A-Za-z0-9_.-])` + ReferenceProperty + `=("(?:[^"\\]|\\.)*"|[^\s;}]+)`)
+
+// TemplateReferences returns the names of the secrets a config template
+// refers to, read from the template text since templates need not be valid
+// KDL before rendering. A secret name must be written out literally: names
+// chosen by template expressions are only known at render time, after the
+// references were checked, and are rejected with ErrReference.
+func TemplateReferences(content string) ([]string, error) {
+	names := References(content)
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		seen[name] = true
+	}
+	for _, m := range templateReference.FindAllStringSubmatch(content, -1) {
+		value := m[1]
+		if strings.Contains(value, "{{") {
+			return nil, fmt.Errorf("%w: secret names cannot be template expressions", ErrReference)
+		}
+		if !strings.HasPrefix(value, `"`) {
+			continue
+		}
+		name, err := strconv.Unquote(value)
+		if err != nil {
+			name = strings.Trim(value, `"`)
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return aead, nil
+}
+
+// seal encrypts plaintext with a random nonce, which is prepended to the
+// result.
+func seal(aead cipher.AEAD, plaintext []byte, name string) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, []byte(name)), nil
+}
+
+func open(aead cipher.AEAD, ciphertext []byte, name string) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(name))
+}
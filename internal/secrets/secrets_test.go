@@ -0,0 +1,179 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+func setupTestStore(t *testing.T) store.Store {
+	t.Helper()
+
+	s, err := store.New(filepath.Join(t.TempDir(), "hub.db"))
+	if err != nil {
+		t.Fatalf("failed to create test store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func setupTestKeeper(t *testing.T, s store.Store) *Keeper {
+	t.Helper()
+
+	key, err := LoadMasterKey(filepath.Join(t.TempDir(), "master.key"))
+	if err != nil {
+		t.Fatalf("LoadMasterKey failed: %v", err)
+	}
+	k, err := NewKeeper(s, key)
+	if err != nil {
+		t.Fatalf("NewKeeper failed: %v", err)
+	}
+	return k
+}
+
+func TestLoadMasterKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "master.key")
+
+	key, err := LoadMasterKey(path)
+	if err != nil {
+		t.Fatalf("LoadMasterKey failed: %v", err)
+	}
+	if len(key) != MasterKeySize {
+		t.Fatalf("key size = %d, want %d", len(key), MasterKeySize)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("key file not written: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("key file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	again, err := LoadMasterKey(path)
+	if err != nil || !bytes.Equal(again, key) {
+		t.Errorf("reloading the key file gave a different key: %v", err)
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.key")
+	os.WriteFile(bad, []byte("too short"), 0600)
+	if _, err := LoadMasterKey(bad); err == nil {
+		t.Error("expected error for an invalid key file")
+	}
+}
+
+func TestKeeper_SetAndValue(t *testing.T) {
+	s := setupTestStore(t)
+	k := setupTestKeeper(t, s)
+	ctx := context.Background()
+
+	pem := "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+	if _, err := k.Set(ctx, "api-cert", nil, pem, nil); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	// Nothing readable is stored
+	stored, _ := s.GetSecret(ctx, "api-cert")
+	if bytes.Contains(stored.Ciphertext, []byte("MIIB")) || bytes.Contains(stored.EncryptedKey, []byte("MIIB")) {
+		t.Error("secret value stored in plaintext")
+	}
+
+	got, err := k.Value(ctx, "api-cert")
+	if err != nil || got != pem {
+		t.Fatalf("Value = %q, %v", got, err)
+	}
+
+	// Ciphertexts are bound to their secret's name
+	stored.Name = "other"
+	s.SetSecret(ctx, stored)
+	if _, err := k.Value(ctx, "other"); err == nil {
+		t.Error("expected a moved ciphertext not to decrypt")
+	}
+
+	if _, err := k.Value(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	// Another master key cannot read the secret
+	other := setupTestKeeper(t, s)
+	if _, err := other.Value(ctx, "api-cert"); err == nil || !strings.Contains(err.Error(), "different master key") {
+		t.Errorf("expected master key mismatch, got %v", err)
+	}
+}
+
+func TestKeeper_Resolve(t *testing.T) {
+	s := setupTestStore(t)
+	k := setupTestKeeper(t, s)
+	ctx := context.Background()
+
+	k.Set(ctx, "api-cert", nil, "CERT\nDATA", nil)
+	k.Set(ctx, "api-key", nil, `k"ey`, nil)
+
+	content := `upstream "api" {
+  tls {
+    cert secret="api-cert"
+    key secret="api-key" format="pem"
+  }
+}
+`
+	want := `upstream "api" {
+    tls {
+        cert "CERT\nDATA"
+        key "k\"ey" format="pem"
+    }
+}
+`
+	got, err := k.Resolve(ctx, content)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("Resolve =\n%s\nwant\n%s", got, want)
+	}
+
+	// Configs without references are delivered byte for byte
+	plain := "server  {\n  listen \"0.0.0.0:80\"\n}\n"
+	if got, _ := k.Resolve(ctx, plain); got != plain {
+		t.Errorf("config without references changed: %q", got)
+	}
+
+	if _, err := k.Resolve(ctx, `tls { cert secret="nope"; }`); !errors.Is(err, ErrReference) {
+		t.Errorf("expected ErrReference, got %v", err)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	content := `
+upstream "api" { tls { cert secret="api-cert"; key secret="api-key"; } }
+upstream "web" { tls { cert secret="api-cert"; } }
+`
+	got := References(content)
+	if len(got) != 2 || got[0] != "api-cert" || got[1] != "api-key" {
+		t.Errorf("References = %v", got)
+	}
+	if got := References("server {"); got != nil {
+		t.Errorf("expected no references in invalid KDL, got %v", got)
+	}
+}
+
+func TestTemplateReferences(t *testing.T) {
+	content := `upstream "api" { tls { {{ if .Vars.tls }}cert secret="api-cert"; key secret="api-key";{{ end }} } }`
+	got, err := TemplateReferences(content)
+	if err != nil || len(got) != 2 || got[0] != "api-cert" || got[1] != "api-key" {
+		t.Errorf("TemplateReferences = %v, %v", got, err)
+	}
+
+	for _, content := range []string{
+		`tls { cert secret="{{ .Instance.Labels.team }}-cert"; }`,
+		`tls { cert secret={{ printf "%q" .Vars.cert }}; }`,
+	} {
+		if _, err := TemplateReferences(content); !errors.Is(err, ErrReference) {
+			t.Errorf("TemplateReferences(%q) = %v, want ErrReference", content, err)
+		}
+	}
+}
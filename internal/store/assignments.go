@@ -0,0 +1,201 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ============================================
+// Config Assignment Operations
+// ============================================
+
+// ApproveConfigVersion marks a config version as approved. Versions that
+// are already approved keep their original approval time.
+func (s *sqlStore) ApproveConfigVersion(ctx context.Context, configID string, version int) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE config_versions SET approved_at = COALESCE(approved_at, ?)
+		WHERE config_id = ? AND version = ?
+	`, time.Now().UTC(), configID, version)
+	if err != nil {
+		return fmt.Errorf("failed to approve config version: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("config version not found")
+	}
+
+	return nil
+}
+
+// GetLatestApprovedConfigVersion retrieves the newest approved version of a
+// config, or nil if none is approved.
+func (s *sqlStore) GetLatestApprovedConfigVersion(ctx context.Context, configID string) (*ConfigVersion, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT `+configVersionColumns+`
+		FROM config_versions WHERE config_id = ? AND approved_at IS NOT NULL
+		ORDER BY version DESC LIMIT 1
+	`, configID)
+	ver, err := scanConfigVersion(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get approved config version: %w", err)
+	}
+
+	return ver, nil
+}
+
+// configAssignmentColumns are the columns read by scanConfigAssignment.
+const configAssignmentColumns = `id, name, config_id, version, selector, priority, created_by, created_at, updated_at`
+
+// scanConfigAssignment scans a single config_assignments row.
+func scanConfigAssignment(scan func(dest ...interface{}) error) (*ConfigAssignment, error) {
+	var a ConfigAssignment
+	var version sql.NullInt64
+	var selectorJSON string
+	var createdBy sql.NullString
+
+	err := scan(&a.ID, &a.Name, &a.ConfigID, &version, &selectorJSON, &a.Priority,
+		&createdBy, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	a.Version = IntPtr(version)
+	a.CreatedBy = StringPtr(createdBy)
+	if err := json.Unmarshal([]byte(selectorJSON), &a.Selector); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal selector: %w", err)
+	}
+	return &a, nil
+}
+
+// CreateConfigAssignment creates a new config assignment.
+func (s *sqlStore) CreateConfigAssignment(ctx context.Context, a *ConfigAssignment) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	if a.Selector == nil {
+		a.Selector = map[string]string{}
+	}
+	a.CreatedAt = time.Now().UTC()
+	a.UpdatedAt = a.CreatedAt
+
+	selectorJSON, err := json.Marshal(a.Selector)
+	if err != nil {
+		return fmt.Errorf("failed to marshal selector: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO config_assignments (id, name, config_id, version, selector, priority, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, a.ID, a.Name, a.ConfigID, NullInt(a.Version), string(selectorJSON), a.Priority,
+		NullString(a.CreatedBy), a.CreatedAt, a.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("config assignment with this name already exists")
+		}
+		return fmt.Errorf("failed to insert config assignment: %w", err)
+	}
+
+	return nil
+}
+
+// GetConfigAssignment retrieves a config assignment by ID.
+func (s *sqlStore) GetConfigAssignment(ctx context.Context, id string) (*ConfigAssignment, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+configAssignmentColumns+` FROM config_assignments WHERE id = ?`, id)
+	a, err := scanConfigAssignment(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config assignment: %w", err)
+	}
+	return a, nil
+}
+
+// ListConfigAssignments retrieves all config assignments, highest priority
+// first and oldest first within a priority.
+func (s *sqlStore) ListConfigAssignments(ctx context.Context) ([]ConfigAssignment, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+configAssignmentColumns+` FROM config_assignments
+		ORDER BY priority DESC, created_at ASC, id ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config assignments: %w", err)
+	}
+	defer rows.Close()
+
+	var assignments []ConfigAssignment
+	for rows.Next() {
+		a, err := scanConfigAssignment(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan config assignment: %w", err)
+		}
+		assignments = append(assignments, *a)
+	}
+
+	return assignments, rows.Err()
+}
+
+// UpdateConfigAssignment updates a config assignment.
+func (s *sqlStore) UpdateConfigAssignment(ctx context.Context, a *ConfigAssignment) error {
+	if a.Selector == nil {
+		a.Selector = map[string]string{}
+	}
+	a.UpdatedAt = time.Now().UTC()
+
+	selectorJSON, err := json.Marshal(a.Selector)
+	if err != nil {
+		return fmt.Errorf("failed to marshal selector: %w", err)
+	}
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE config_assignments
+		SET name = ?, config_id = ?, version = ?, selector = ?, priority = ?, updated_at = ?
+		WHERE id = ?
+	`, a.Name, a.ConfigID, NullInt(a.Version), string(selectorJSON), a.Priority, a.UpdatedAt, a.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("config assignment with this name already exists")
+		}
+		return fmt.Errorf("failed to update config assignment: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("config assignment not found")
+	}
+
+	return nil
+}
+
+// DeleteConfigAssignment deletes a config assignment.
+func (s *sqlStore) DeleteConfigAssignment(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM config_assignments WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete config assignment: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("config assignment not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// auditLogContent is the hashed form of an audit log entry. Fields are
+// encoded in a fixed order; optional fields are omitted when unset, so
+// fields added later do not change the hashes of existing entries.
+type auditLogContent struct {
+	Sequence     int64   `json:"sequence"`
+	PrevHash     string  `json:"prev_hash"`
+	ID           string  `json:"id"`
+	Timestamp    string  `json:"timestamp"`
+	UserID       *string `json:"user_id,omitempty"`
+	Action       string  `json:"action"`
+	ResourceType string  `json:"resource_type"`
+	ResourceID   *string `json:"resource_id,omitempty"`
+	Details      string  `json:"details,omitempty"`
+	IPAddress    *string `json:"ip_address,omitempty"`
+
+	ActorType       string  `json:"actor_type,omitempty"`
+	ActorID         *string `json:"actor_id,omitempty"`
+	ActorSPIFFEID   *string `json:"actor_spiffe_id,omitempty"`
+	ActorCertSerial *string `json:"actor_cert_serial,omitempty"`
+}
+
+// AuditLogHash returns the hex SHA-256 hash of an entry's content, sequence
+// and previous hash. It ignores log.Hash.
+func AuditLogHash(log *AuditLog) string {
+	content, _ := json.Marshal(auditLogContent{
+		Sequence:     log.Sequence,
+		PrevHash:     log.PrevHash,
+		ID:           log.ID,
+		Timestamp:    log.Timestamp.UTC().Format(time.RFC3339Nano),
+		UserID:       log.UserID,
+		Action:       log.Action,
+		ResourceType: log.ResourceType,
+		ResourceID:   log.ResourceID,
+		Details:      string(log.Details),
+		IPAddress:    log.IPAddress,
+
+		ActorType:       string(log.ActorType),
+		ActorID:         log.ActorID,
+		ActorSPIFFEID:   log.ActorSPIFFEID,
+		ActorCertSerial: log.ActorCertSerial,
+	})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// ============================================
+// Audit Chain Operations
+// ============================================
+
+// ListAuditChain returns chained audit logs after the given sequence, in
+// chain order.
+func (s *sqlStore) ListAuditChain(ctx context.Context, afterSequence int64, limit int) ([]AuditLog, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+auditLogColumns+`
+		FROM audit_logs
+		WHERE sequence > ?
+		ORDER BY sequence ASC
+		LIMIT ?
+	`, afterSequence, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit chain: %w", err)
+	}
+	defer rows.Close()
+
+	return scanAuditLogs(rows)
+}
+
+// CountUnchainedAuditLogs counts audit logs without a hash, optionally only
+// those written since the given time.
+func (s *sqlStore) CountUnchainedAuditLogs(ctx context.Context, since *time.Time) (int64, error) {
+	query := `SELECT COUNT(*) FROM audit_logs WHERE sequence IS NULL`
+	args := []interface{}{}
+	if since != nil {
+		query += " AND timestamp >= ?"
+		args = append(args, *since)
+	}
+
+	var count int64
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count unchained audit logs: %w", err)
+	}
+	return count, nil
+}
+
+// GetAuditChainHead returns the sequence and hash of the newest chained
+// entry, even if retention has since deleted it. Both are zero before the
+// first entry.
+func (s *sqlStore) GetAuditChainHead(ctx context.Context) (int64, string, error) {
+	var sequence int64
+	var hash string
+	err := s.db.QueryRowContext(ctx, `SELECT sequence, hash FROM audit_chain WHERE id = 1`).Scan(&sequence, &hash)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get audit chain head: %w", err)
+	}
+	return sequence, hash, nil
+}
+
+// CreateAuditCheckpoint records a signed checkpoint.
+func (s *sqlStore) CreateAuditCheckpoint(ctx context.Context, cp *AuditCheckpoint) error {
+	if cp.ID == "" {
+		cp.ID = uuid.New().String()
+	}
+	cp.CreatedAt = time.Now().UTC()
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO audit_checkpoints (id, sequence, hash, signature, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, cp.ID, cp.Sequence, cp.Hash, cp.Signature, cp.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create audit checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// GetLatestAuditCheckpoint returns the checkpoint with the highest sequence.
+func (s *sqlStore) GetLatestAuditCheckpoint(ctx context.Context) (*AuditCheckpoint, error) {
+	var cp AuditCheckpoint
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, sequence, hash, signature, created_at
+		FROM audit_checkpoints
+		ORDER BY sequence DESC, created_at DESC
+		LIMIT 1
+	`).Scan(&cp.ID, &cp.Sequence, &cp.Hash, &cp.Signature, &cp.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit checkpoint: %w", err)
+	}
+
+	return &cp, nil
+}
+
+// ListAuditCheckpoints returns all checkpoints in chain order.
+func (s *sqlStore) ListAuditCheckpoints(ctx context.Context) ([]AuditCheckpoint, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, sequence, hash, signature, created_at
+		FROM audit_checkpoints
+		ORDER BY sequence ASC, created_at ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit checkpoints: %w", err)
+	}
+	defer rows.Close()
+
+	var checkpoints []AuditCheckpoint
+	for rows.Next() {
+		var cp AuditCheckpoint
+		if err := rows.Scan(&cp.ID, &cp.Sequence, &cp.Hash, &cp.Signature, &cp.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan audit checkpoint: %w", err)
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+
+	return checkpoints, rows.Err()
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// ErrBackupUnsupported is returned by Backup and Restore for databases other
+// than SQLite, which have their own tooling such as pg_dump.
+var ErrBackupUnsupported = errors.New("backup and restore are only supported for SQLite databases")
+
+// Backup writes a consistent copy of a SQLite database to path using
+// VACUUM INTO. It is safe to run while the hub is serving requests.
+func Backup(ctx context.Context, databaseURL, path string) error {
+	d, _ := parseDatabaseURL(databaseURL)
+	if d != sqliteDialect {
+		return ErrBackupUnsupported
+	}
+
+	// Opening a missing file would create an empty database
+	if _, err := os.Stat(sqlitePath(databaseURL)); err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup file %s already exists", path)
+	}
+
+	db, err := open(databaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, `VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	return nil
+}
+
+// Restore replaces a SQLite database with a backup written by Backup. The hub
+// must be stopped; pending migrations are applied when it next starts.
+func Restore(ctx context.Context, backupPath, databaseURL string) error {
+	d, _ := parseDatabaseURL(databaseURL)
+	if d != sqliteDialect {
+		return ErrBackupUnsupported
+	}
+
+	if err := verifyBackup(ctx, backupPath); err != nil {
+		return err
+	}
+
+	dbPath := sqlitePath(databaseURL)
+	if err := copyFile(backupPath, dbPath); err != nil {
+		return fmt.Errorf("failed to restore backup: %w", err)
+	}
+
+	// Stale journal files would be replayed over the restored database
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", dbPath+suffix, err)
+		}
+	}
+
+	return nil
+}
+
+// readOnlyDSN returns a DSN that opens the SQLite database at path without
+// ever writing to it. SQLite only reads URI parameters from file: URIs.
+func readOnlyDSN(path string) string {
+	u := url.URL{Scheme: "file", Path: path, RawQuery: "mode=ro&immutable=1"}
+	return u.String()
+}
+
+// verifyBackup checks that path is an intact hub database.
+func verifyBackup(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+
+	db, err := sql.Open(sqliteDialect.driverName, readOnlyDSN(path))
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRowContext(ctx, `PRAGMA integrity_check`).Scan(&result); err != nil {
+		return fmt.Errorf("failed to check backup: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("backup failed integrity check: %s", result)
+	}
+
+	var migrations int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations`).Scan(&migrations); err != nil {
+		return fmt.Errorf("backup is not a hub database: %w", err)
+	}
+
+	return nil
+}
+
+// copyFile replaces dst with a copy of src, atomically.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), dst)
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	dbURL := "sqlite://" + filepath.Join(dir, "hub.db")
+	backupPath := filepath.Join(dir, "hub-backup.db")
+
+	s, err := New(dbURL)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := s.CreateInstance(ctx, &Instance{ID: "before", Name: "before", Status: InstanceStatusOnline}); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+
+	// Back up while the store is open
+	if err := Backup(ctx, dbURL, backupPath); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if err := Backup(ctx, dbURL, backupPath); err == nil {
+		t.Error("expected Backup to refuse an existing file")
+	}
+
+	if err := s.CreateInstance(ctx, &Instance{ID: "after", Name: "after", Status: InstanceStatusOnline}); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	s.Close()
+
+	if err := Restore(ctx, backupPath, dbURL); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	s, err = New(dbURL)
+	if err != nil {
+		t.Fatalf("New after restore failed: %v", err)
+	}
+	defer s.Close()
+
+	if inst, _ := s.GetInstance(ctx, "before"); inst == nil {
+		t.Error("expected instance from before the backup")
+	}
+	if inst, _ := s.GetInstance(ctx, "after"); inst != nil {
+		t.Error("expected instance created after the backup to be gone")
+	}
+}
+
+func TestVerifyBackup_ReadOnly(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	dbURL := "sqlite://" + filepath.Join(dir, "hub.db")
+	backupPath := filepath.Join(dir, "hub backup?.db")
+
+	s, err := New(dbURL)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer s.Close()
+	if err := Backup(ctx, dbURL, backupPath); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	// The backup is opened by name, however it is spelled, and never written
+	db, err := sql.Open(sqliteDialect.driverName, readOnlyDSN(backupPath))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, `DELETE FROM instances`); err == nil {
+		t.Error("expected writes to the backup to fail")
+	}
+	if err := verifyBackup(ctx, backupPath); err != nil {
+		t.Errorf("verifyBackup failed: %v", err)
+	}
+}
+
+func TestRestore_RejectsInvalidBackup(t *testing.T) {
+	dir := t.TempDir()
+	bogus := filepath.Join(dir, "bogus.db")
+	if err := os.WriteFile(bogus, []byte("not a database"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	dbPath := filepath.Join(dir, "hub.db")
+	if err := Restore(context.Background(), bogus, dbPath); err == nil {
+		t.Error("expected Restore to reject an invalid backup")
+	}
+	if _, err := os.Stat(dbPath); !os.IsNotExist(err) {
+		t.Error("expected database not to be touched")
+	}
+}
+
+func TestBackup_Postgres(t *testing.T) {
+	err := Backup(context.Background(), "postgres://localhost/hub", "backup.db")
+	if !errors.Is(err, ErrBackupUnsupported) {
+		t.Errorf("expected ErrBackupUnsupported, got %v", err)
+	}
+}
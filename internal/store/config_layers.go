@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// ============================================
+// Config Layer Operations
+// ============================================
+
+// SetConfigLayers replaces the layers of a composed config: the base config
+// first, then overlays in the order they apply. An empty list makes the
+// config a plain one again.
+func (s *sqlStore) SetConfigLayers(ctx context.Context, configID string, layerConfigIDs []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM config_layers WHERE config_id = ?`, configID); err != nil {
+		return fmt.Errorf("failed to clear config layers: %w", err)
+	}
+	for i, layerID := range layerConfigIDs {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO config_layers (config_id, position, layer_config_id) VALUES (?, ?, ?)
+		`, configID, i, layerID)
+		if err != nil {
+			return fmt.Errorf("failed to insert config layer: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit config layers: %w", err)
+	}
+	return nil
+}
+
+// ListConfigLayers retrieves the layer config IDs of a composed config in
+// order, or none for a plain config.
+func (s *sqlStore) ListConfigLayers(ctx context.Context, configID string) ([]string, error) {
+	return s.queryConfigIDs(ctx, `
+		SELECT layer_config_id FROM config_layers WHERE config_id = ? ORDER BY position ASC
+	`, configID)
+}
+
+// ListConfigDependents retrieves the IDs of the configs that use a config
+// as one of their layers.
+func (s *sqlStore) ListConfigDependents(ctx context.Context, layerConfigID string) ([]string, error) {
+	return s.queryConfigIDs(ctx, `
+		SELECT DISTINCT l.config_id FROM config_layers l
+		JOIN configs c ON c.id = l.config_id
+		WHERE l.layer_config_id = ? AND c.deleted_at IS NULL
+		ORDER BY l.config_id ASC
+	`, layerConfigID)
+}
+
+func (s *sqlStore) queryConfigIDs(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config layers: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan config layer: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
@@ -0,0 +1,174 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// dialect captures what differs between the supported databases. Store
+// queries are written once, with ? placeholders, and rebound per dialect.
+type dialect struct {
+	name       string
+	driverName string
+	// numberedPlaceholders rewrites ? placeholders to $1, $2, ...
+	numberedPlaceholders bool
+	// migrationsDir holds the dialect's migration files.
+	migrationsDir string
+	// migrationsTable creates the table recording applied migrations.
+	migrationsTable string
+	// migrationLock and migrationUnlock serialize migrations between hub
+	// instances starting at the same time.
+	migrationLock   string
+	migrationUnlock string
+	// configure sets up the connection pool.
+	configure func(db *sql.DB)
+}
+
+var sqliteDialect = &dialect{
+	name:          "sqlite",
+	driverName:    "sqlite3",
+	migrationsDir: "migrations",
+	migrationsTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at DATETIME NOT NULL
+		)`,
+	configure: func(db *sql.DB) {
+		db.SetMaxOpenConns(1) // SQLite only supports one writer
+		db.SetMaxIdleConns(1)
+		db.SetConnMaxLifetime(time.Hour)
+	},
+}
+
+// postgresMigrationLockID is an arbitrary key for pg_advisory_lock.
+const postgresMigrationLockID = 7305416402
+
+var postgresDialect = &dialect{
+	name:                 "postgres",
+	driverName:           "pgx",
+	numberedPlaceholders: true,
+	migrationLock:        "SELECT pg_advisory_lock(" + strconv.Itoa(postgresMigrationLockID) + ")",
+	migrationUnlock:      "SELECT pg_advisory_unlock(" + strconv.Itoa(postgresMigrationLockID) + ")",
+	migrationsDir:        "migrations/postgres",
+	migrationsTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL
+		)`,
+	configure: func(db *sql.DB) {
+		db.SetMaxOpenConns(25)
+		db.SetMaxIdleConns(5)
+		db.SetConnMaxLifetime(time.Hour)
+	},
+}
+
+// parseDatabaseURL picks the dialect for a database URL and returns the
+// data source name for its driver. URLs without a scheme are SQLite paths.
+func parseDatabaseURL(databaseURL string) (*dialect, string) {
+	if strings.HasPrefix(databaseURL, "postgres://") || strings.HasPrefix(databaseURL, "postgresql://") {
+		return postgresDialect, databaseURL
+	}
+
+	return sqliteDialect, sqlitePath(databaseURL) + "?_foreign_keys=on&_journal_mode=WAL"
+}
+
+// sqlitePath returns the file path of a SQLite database URL.
+func sqlitePath(databaseURL string) string {
+	return strings.TrimPrefix(databaseURL, "sqlite://")
+}
+
+// rebind rewrites ? placeholders for the dialect, leaving quoted strings alone.
+func (d *dialect) rebind(query string) string {
+	if !d.numberedPlaceholders || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	inString := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'':
+			inString = !inString
+		case c == '?' && !inString:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505"
+	}
+	return strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// sqlDB is a database handle that rebinds queries for its dialect.
+type sqlDB struct {
+	*sql.DB
+	dialect *dialect
+}
+
+func (db *sqlDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.ExecContext(ctx, db.dialect.rebind(query), args...)
+}
+
+func (db *sqlDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.QueryContext(ctx, db.dialect.rebind(query), args...)
+}
+
+func (db *sqlDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRowContext(ctx, db.dialect.rebind(query), args...)
+}
+
+func (db *sqlDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sqlTx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{Tx: tx, dialect: db.dialect}, nil
+}
+
+// sqlTx is a transaction that rebinds queries for its dialect.
+type sqlTx struct {
+	*sql.Tx
+	dialect *dialect
+}
+
+func (tx *sqlTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.ExecContext(ctx, tx.dialect.rebind(query), args...)
+}
+
+func (tx *sqlTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.QueryContext(ctx, tx.dialect.rebind(query), args...)
+}
+
+func (tx *sqlTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRowContext(ctx, tx.dialect.rebind(query), args...)
+}
+
+// execer runs statements on a sqlDB or within a sqlTx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
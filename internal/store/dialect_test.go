@@ -0,0 +1,82 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestParseDatabaseURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		dialect *dialect
+		dsn     string
+	}{
+		{"sqlite://hub.db", sqliteDialect, "hub.db?_foreign_keys=on&_journal_mode=WAL"},
+		{"/data/hub.db", sqliteDialect, "/data/hub.db?_foreign_keys=on&_journal_mode=WAL"},
+		{"postgres://hub@localhost/hub", postgresDialect, "postgres://hub@localhost/hub"},
+		{"postgresql://hub@localhost/hub", postgresDialect, "postgresql://hub@localhost/hub"},
+	}
+
+	for _, tt := range tests {
+		d, dsn := parseDatabaseURL(tt.url)
+		if d != tt.dialect {
+			t.Errorf("parseDatabaseURL(%q) dialect = %s, want %s", tt.url, d.name, tt.dialect.name)
+		}
+		if dsn != tt.dsn {
+			t.Errorf("parseDatabaseURL(%q) dsn = %q, want %q", tt.url, dsn, tt.dsn)
+		}
+	}
+}
+
+func TestDialect_Rebind(t *testing.T) {
+	query := "SELECT * FROM t WHERE a = ? AND b = '?' AND c IN (?, ?)"
+
+	if got := sqliteDialect.rebind(query); got != query {
+		t.Errorf("sqlite rebind = %q, want unchanged", got)
+	}
+
+	want := "SELECT * FROM t WHERE a = $1 AND b = '?' AND c IN ($2, $3)"
+	if got := postgresDialect.rebind(query); got != want {
+		t.Errorf("postgres rebind = %q, want %q", got, want)
+	}
+}
+
+func TestDialect_Migrations(t *testing.T) {
+	sqlite, err := loadMigrations(sqliteDialect)
+	if err != nil {
+		t.Fatalf("loadMigrations(sqlite) failed: %v", err)
+	}
+	postgres, err := loadMigrations(postgresDialect)
+	if err != nil {
+		t.Fatalf("loadMigrations(postgres) failed: %v", err)
+	}
+
+	if len(sqlite) != len(postgres) {
+		t.Fatalf("sqlite has %d migrations, postgres has %d", len(sqlite), len(postgres))
+	}
+	for i, m := range sqlite {
+		if pg := postgres[i]; pg.name != m.name {
+			t.Errorf("migration %d: sqlite %s, postgres %s", i, m.name, pg.name)
+		}
+		if m.down == "" || postgres[i].down == "" {
+			t.Errorf("migration %s has no down migration", m.name)
+		}
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	if !isUniqueViolation(errors.New("UNIQUE constraint failed: users.email")) {
+		t.Error("expected sqlite unique error to match")
+	}
+	if !isUniqueViolation(&pgconn.PgError{Code: "23505"}) {
+		t.Error("expected postgres unique error to match")
+	}
+	if isUniqueViolation(&pgconn.PgError{Code: "23503"}) {
+		t.Error("expected postgres foreign key error not to match")
+	}
+	if isUniqueViolation(errors.New("connection refused")) {
+		t.Error("expected other error not to match")
+	}
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ============================================
+// Drift Policy Operations
+// ============================================
+
+// driftPolicyColumns are the columns read by scanDriftPolicy.
+const driftPolicyColumns = `id, name, selector, action, priority, created_by, created_at, updated_at`
+
+// scanDriftPolicy scans a single drift_policies row.
+func scanDriftPolicy(scan func(dest ...interface{}) error) (*DriftPolicy, error) {
+	var p DriftPolicy
+	var selectorJSON string
+	var createdBy sql.NullString
+
+	err := scan(&p.ID, &p.Name, &selectorJSON, &p.Action, &p.Priority,
+		&createdBy, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	p.CreatedBy = StringPtr(createdBy)
+	if err := json.Unmarshal([]byte(selectorJSON), &p.Selector); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal selector: %w", err)
+	}
+	return &p, nil
+}
+
+// CreateDriftPolicy creates a new drift policy.
+func (s *sqlStore) CreateDriftPolicy(ctx context.Context, p *DriftPolicy) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	if p.Selector == nil {
+		p.Selector = map[string]string{}
+	}
+	p.CreatedAt = time.Now().UTC()
+	p.UpdatedAt = p.CreatedAt
+
+	selectorJSON, err := json.Marshal(p.Selector)
+	if err != nil {
+		return fmt.Errorf("failed to marshal selector: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO drift_policies (id, name, selector, action, priority, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, p.ID, p.Name, string(selectorJSON), p.Action, p.Priority,
+		NullString(p.CreatedBy), p.CreatedAt, p.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("drift policy with this name already exists")
+		}
+		return fmt.Errorf("failed to insert drift policy: %w", err)
+	}
+
+	return nil
+}
+
+// GetDriftPolicy retrieves a drift policy by ID.
+func (s *sqlStore) GetDriftPolicy(ctx context.Context, id string) (*DriftPolicy, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+driftPolicyColumns+` FROM drift_policies WHERE id = ?`, id)
+	p, err := scanDriftPolicy(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get drift policy: %w", err)
+	}
+	return p, nil
+}
+
+// ListDriftPolicies retrieves all drift policies, highest priority first
+// and oldest first within a priority.
+func (s *sqlStore) ListDriftPolicies(ctx context.Context) ([]DriftPolicy, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+driftPolicyColumns+` FROM drift_policies
+		ORDER BY priority DESC, created_at ASC, id ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list drift policies: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []DriftPolicy
+	for rows.Next() {
+		p, err := scanDriftPolicy(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan drift policy: %w", err)
+		}
+		policies = append(policies, *p)
+	}
+
+	return policies, rows.Err()
+}
+
+// UpdateDriftPolicy updates a drift policy.
+func (s *sqlStore) UpdateDriftPolicy(ctx context.Context, p *DriftPolicy) error {
+	if p.Selector == nil {
+		p.Selector = map[string]string{}
+	}
+	p.UpdatedAt = time.Now().UTC()
+
+	selectorJSON, err := json.Marshal(p.Selector)
+	if err != nil {
+		return fmt.Errorf("failed to marshal selector: %w", err)
+	}
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE drift_policies
+		SET name = ?, selector = ?, action = ?, priority = ?, updated_at = ?
+		WHERE id = ?
+	`, p.Name, string(selectorJSON), p.Action, p.Priority, p.UpdatedAt, p.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("drift policy with this name already exists")
+		}
+		return fmt.Errorf("failed to update drift policy: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("drift policy not found")
+	}
+
+	return nil
+}
+
+// DeleteDriftPolicy deletes a drift policy.
+func (s *sqlStore) DeleteDriftPolicy(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM drift_policies WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete drift policy: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("drift policy not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SnapshotFormatVersion is the format version written by ExportSnapshot.
+const SnapshotFormatVersion = 1
+
+// Snapshot is a portable export of the hub's data that can be imported into
+// another hub, on either database backend. It carries no secrets: passwords,
+// MFA enrollments, sessions and API tokens are left out, so imported users
+// sign in through OIDC or reset their password.
+type Snapshot struct {
+	FormatVersion int                  `json:"format_version"`
+	ExportedAt    time.Time            `json:"exported_at"`
+	Users         []User               `json:"users"`
+	Configs       []ConfigSnapshot     `json:"configs"`
+	Instances     []Instance           `json:"instances"`
+	Deployments   []DeploymentSnapshot `json:"deployments"`
+}
+
+// ConfigSnapshot is a config with all of its versions.
+type ConfigSnapshot struct {
+	Config
+	Versions []ConfigVersion `json:"versions"`
+}
+
+// DeploymentSnapshot is a deployment with its per-instance history.
+type DeploymentSnapshot struct {
+	Deployment
+	Instances []DeploymentInstance `json:"instances"`
+}
+
+// ImportResult counts the records written by ImportSnapshot.
+type ImportResult struct {
+	Users          int `json:"users"`
+	SkippedUsers   int `json:"skipped_users"`
+	Configs        int `json:"configs"`
+	ConfigVersions int `json:"config_versions"`
+	Instances      int `json:"instances"`
+	Deployments    int `json:"deployments"`
+}
+
+// ============================================
+// Export Operations
+// ============================================
+
+// ExportSnapshot reads users, configs with all versions (including deleted
+// configs), instances and deployment history.
+func (s *sqlStore) ExportSnapshot(ctx context.Context) (*Snapshot, error) {
+	snap := &Snapshot{
+		FormatVersion: SnapshotFormatVersion,
+		ExportedAt:    time.Now().UTC(),
+		Users:         []User{},
+		Configs:       []ConfigSnapshot{},
+		Instances:     []Instance{},
+		Deployments:   []DeploymentSnapshot{},
+	}
+
+	users, err := s.ListUsers(ctx, ListUsersOptions{})
+	if err != nil {
+		return nil, err
+	}
+	snap.Users = append(snap.Users, users...)
+
+	configs, err := s.ListConfigs(ctx, ListConfigsOptions{IncludeDeleted: true})
+	if err != nil {
+		return nil, err
+	}
+	for _, cfg := range configs {
+		versions, err := s.ListConfigVersions(ctx, cfg.ID)
+		if err != nil {
+			return nil, err
+		}
+		snap.Configs = append(snap.Configs, ConfigSnapshot{Config: cfg, Versions: versions})
+	}
+
+	instances, err := s.ListInstances(ctx, ListInstancesOptions{})
+	if err != nil {
+		return nil, err
+	}
+	snap.Instances = append(snap.Instances, instances...)
+
+	deployments, err := s.ListDeployments(ctx, ListDeploymentsOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, dep := range deployments {
+		dis, err := s.ListDeploymentInstances(ctx, dep.ID)
+		if err != nil {
+			return nil, err
+		}
+		ds := DeploymentSnapshot{Deployment: dep, Instances: make([]DeploymentInstance, len(dis))}
+		for i, di := range dis {
+			ds.Instances[i] = *di
+		}
+		snap.Deployments = append(snap.Deployments, ds)
+	}
+
+	return snap, nil
+}
+
+// ImportSnapshot writes a snapshot in one transaction, keeping IDs and
+// timestamps. The database must not have configs, instances or deployments
+// yet; users that already exist, such as a seeded admin, are kept and the
+// snapshot's copy is skipped.
+func (s *sqlStore) ImportSnapshot(ctx context.Context, snap *Snapshot) (*ImportResult, error) {
+	if snap.FormatVersion != SnapshotFormatVersion {
+		return nil, fmt.Errorf("unsupported snapshot format version %d", snap.FormatVersion)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var existing int
+	err = tx.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) FROM configs) + (SELECT COUNT(*) FROM instances) + (SELECT COUNT(*) FROM deployments)
+	`).Scan(&existing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for existing data: %w", err)
+	}
+	if existing > 0 {
+		return nil, fmt.Errorf("database already has configs, instances or deployments")
+	}
+
+	result := &ImportResult{}
+
+	for i := range snap.Users {
+		user := &snap.Users[i]
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO users (id, email, name, role, oidc_subject, created_at, last_login_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT DO NOTHING
+		`,
+			user.ID, user.Email, user.Name, user.Role, NullString(user.OIDCSubject),
+			user.CreatedAt, NullTime(user.LastLoginAt),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to import user %s: %w", user.Email, err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			result.SkippedUsers++
+			continue
+		}
+		result.Users++
+	}
+
+	for i := range snap.Configs {
+		cfg := &snap.Configs[i]
+		if err := insertConfig(ctx, tx, &cfg.Config); err != nil {
+			return nil, fmt.Errorf("failed to import config %s: %w", cfg.Name, err)
+		}
+		result.Configs++
+
+		for j := range cfg.Versions {
+			if err := insertConfigVersion(ctx, tx, &cfg.Versions[j]); err != nil {
+				return nil, fmt.Errorf("failed to import config %s version %d: %w", cfg.Name, cfg.Versions[j].Version, err)
+			}
+			result.ConfigVersions++
+		}
+	}
+
+	for i := range snap.Instances {
+		if err := insertInstance(ctx, tx, &snap.Instances[i]); err != nil {
+			return nil, fmt.Errorf("failed to import instance %s: %w", snap.Instances[i].Name, err)
+		}
+		result.Instances++
+	}
+
+	for i := range snap.Deployments {
+		dep := &snap.Deployments[i]
+		if err := insertDeployment(ctx, tx, &dep.Deployment); err != nil {
+			return nil, fmt.Errorf("failed to import deployment %s: %w", dep.ID, err)
+		}
+		for j := range dep.Instances {
+			if err := insertDeploymentInstance(ctx, tx, &dep.Instances[j]); err != nil {
+				return nil, fmt.Errorf("failed to import deployment %s: %w", dep.ID, err)
+			}
+		}
+		result.Deployments++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,164 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+// seedSnapshotData creates one of each exported record.
+func seedSnapshotData(t *testing.T, s *sqlStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	hash := "secret-hash"
+	if err := s.CreateUser(ctx, &User{ID: "user-1", Email: "ops@example.com", Name: "Ops", Role: "admin", PasswordHash: &hash}); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+
+	createTestConfigWithVersion(t, s, "config-1", 1)
+	if err := s.CreateConfigVersion(ctx, &ConfigVersion{ConfigID: "config-1", Version: 2, Content: "v2"}); err != nil {
+		t.Fatalf("CreateConfigVersion failed: %v", err)
+	}
+	createTestConfigWithVersion(t, s, "config-deleted", 1)
+	if err := s.DeleteConfig(ctx, "config-deleted"); err != nil {
+		t.Fatalf("DeleteConfig failed: %v", err)
+	}
+
+	configID := "config-1"
+	version := 2
+	inst := &Instance{
+		ID: "inst-1", Name: "edge-1", Hostname: "edge-1.local", Status: InstanceStatusOnline,
+		CurrentConfigID: &configID, CurrentConfigVersion: &version,
+		Labels: map[string]string{"env": "prod"},
+	}
+	if err := s.CreateInstance(ctx, inst); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+
+	dep := &Deployment{
+		ID: "dep-1", ConfigID: "config-1", ConfigVersion: 2, TargetInstances: []string{"inst-1"},
+		Strategy: DeploymentStrategyRolling, BatchSize: 1, Status: DeploymentStatusCompleted,
+		Progress: &DeploymentProgress{TotalInstances: 1, CompletedInstances: 1},
+	}
+	if err := s.CreateDeployment(ctx, dep); err != nil {
+		t.Fatalf("CreateDeployment failed: %v", err)
+	}
+	if err := s.CreateDeploymentInstance(ctx, &DeploymentInstance{DeploymentID: "dep-1", InstanceID: "inst-1", Status: DeploymentInstanceStatusCompleted}); err != nil {
+		t.Fatalf("CreateDeploymentInstance failed: %v", err)
+	}
+}
+
+func TestStore_ExportImportSnapshot(t *testing.T) {
+	ctx := context.Background()
+	src := setupTestStore(t)
+	seedSnapshotData(t, src)
+
+	snap, err := src.ExportSnapshot(ctx)
+	if err != nil {
+		t.Fatalf("ExportSnapshot failed: %v", err)
+	}
+	if len(snap.Users) != 1 || len(snap.Configs) != 2 || len(snap.Instances) != 1 || len(snap.Deployments) != 1 {
+		t.Fatalf("unexpected snapshot contents: %d users, %d configs, %d instances, %d deployments",
+			len(snap.Users), len(snap.Configs), len(snap.Instances), len(snap.Deployments))
+	}
+
+	// Round trip through JSON as the export command does
+	data, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatalf("failed to marshal snapshot: %v", err)
+	}
+	var decoded Snapshot
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal snapshot: %v", err)
+	}
+
+	dst := setupTestStore(t)
+	result, err := dst.ImportSnapshot(ctx, &decoded)
+	if err != nil {
+		t.Fatalf("ImportSnapshot failed: %v", err)
+	}
+	if result.Users != 1 || result.Configs != 2 || result.ConfigVersions != 3 || result.Instances != 1 || result.Deployments != 1 {
+		t.Errorf("unexpected import result: %+v", result)
+	}
+
+	user, _ := dst.GetUser(ctx, "user-1")
+	if user == nil {
+		t.Fatal("expected user to be imported")
+	}
+	if user.PasswordHash != nil {
+		t.Error("expected password hash not to be exported")
+	}
+
+	versions, _ := dst.ListConfigVersions(ctx, "config-1")
+	if len(versions) != 2 {
+		t.Errorf("expected 2 config versions, got %d", len(versions))
+	}
+
+	deleted, _ := dst.GetConfig(ctx, "config-deleted")
+	if deleted != nil && deleted.DeletedAt == nil {
+		t.Error("expected deleted config to stay deleted")
+	}
+
+	inst, _ := dst.GetInstance(ctx, "inst-1")
+	if inst == nil || inst.Labels["env"] != "prod" || inst.CreatedAt.Unix() != snap.Instances[0].CreatedAt.Unix() {
+		t.Errorf("instance not imported as exported: %+v", inst)
+	}
+
+	dis, _ := dst.ListDeploymentInstances(ctx, "dep-1")
+	if len(dis) != 1 || dis[0].Status != DeploymentInstanceStatusCompleted {
+		t.Errorf("deployment history not imported: %+v", dis)
+	}
+}
+
+func TestStore_ImportSnapshot_RequiresEmptyDatabase(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestStore(t)
+	seedSnapshotData(t, s)
+
+	snap, err := s.ExportSnapshot(ctx)
+	if err != nil {
+		t.Fatalf("ExportSnapshot failed: %v", err)
+	}
+
+	if _, err := s.ImportSnapshot(ctx, snap); err == nil {
+		t.Error("expected import into a populated database to fail")
+	}
+}
+
+func TestStore_ImportSnapshot_SkipsExistingUsers(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestStore(t)
+
+	if err := s.CreateUser(ctx, &User{Email: "ops@example.com", Name: "Seeded", Role: "admin"}); err != nil {
+		t.Fatalf("CreateUser failed: %v", err)
+	}
+
+	snap := &Snapshot{
+		FormatVersion: SnapshotFormatVersion,
+		Users: []User{
+			{ID: "user-1", Email: "ops@example.com", Name: "Ops", Role: "admin"},
+			{ID: "user-2", Email: "dev@example.com", Name: "Dev", Role: "viewer"},
+		},
+	}
+	result, err := s.ImportSnapshot(ctx, snap)
+	if err != nil {
+		t.Fatalf("ImportSnapshot failed: %v", err)
+	}
+	if result.Users != 1 || result.SkippedUsers != 1 {
+		t.Errorf("expected 1 imported and 1 skipped user, got %+v", result)
+	}
+
+	user, _ := s.GetUserByEmail(ctx, "ops@example.com")
+	if user == nil || user.Name != "Seeded" {
+		t.Errorf("expected existing user to be kept, got %+v", user)
+	}
+}
+
+func TestStore_ImportSnapshot_UnsupportedFormat(t *testing.T) {
+	s := setupTestStore(t)
+
+	if _, err := s.ImportSnapshot(context.Background(), &Snapshot{FormatVersion: 99}); err == nil {
+		t.Error("expected unsupported format version to fail")
+	}
+}
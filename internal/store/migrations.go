@@ -0,0 +1,314 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+// migrationFileName matches "<version>_<name>.sql" and "<version>_<name>.down.sql".
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+?)(\.down)?\.sql$`)
+
+// migration is a versioned schema change and the SQL that reverts it.
+type migration struct {
+	version  int
+	name     string
+	up       string
+	down     string
+	checksum string
+}
+
+// MigrationStatus describes a migration known to the hub or recorded in the
+// database.
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	// Modified is set when the migration file changed after it was applied.
+	Modified bool `json:"modified,omitempty"`
+	// Unknown is set when the database has a migration this hub does not ship.
+	Unknown bool `json:"unknown,omitempty"`
+}
+
+// appliedMigration is a row of schema_migrations.
+type appliedMigration struct {
+	version   int
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// loadMigrations reads a dialect's migration files, ordered by version.
+func loadMigrations(d *dialect) ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, d.migrationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		data, err := migrationFiles.ReadFile(path.Join(d.migrationsDir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &migration{version: version, name: match[1] + "_" + match[2]}
+			byVersion[version] = m
+		} else if m.name != match[1]+"_"+match[2] {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", m.name, entry.Name(), version)
+		}
+
+		if match[3] != "" {
+			m.down = string(data)
+		} else {
+			sum := sha256.Sum256(data)
+			m.up = string(data)
+			m.checksum = hex.EncodeToString(sum[:])
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %s has no up migration", m.name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	return migrations, nil
+}
+
+// ============================================
+// Migrator
+// ============================================
+
+// Migrator applies and reverts schema migrations. Each migration runs in its
+// own transaction and is recorded in schema_migrations with a checksum of its
+// file, so it is applied exactly once and later edits are detected.
+type Migrator struct {
+	db *sqlDB
+}
+
+// NewMigrator connects to the database without applying any migrations.
+func NewMigrator(databaseURL string) (*Migrator, error) {
+	db, err := open(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db}, nil
+}
+
+// Close closes the database connection.
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+// Status lists every migration shipped with the hub or recorded in the
+// database, ordered by version.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn, migrations []migration, applied map[int]appliedMigration) error {
+		for _, mig := range migrations {
+			status := MigrationStatus{Version: mig.version, Name: mig.name}
+			if a, ok := applied[mig.version]; ok {
+				appliedAt := a.appliedAt
+				status.AppliedAt = &appliedAt
+				status.Modified = a.checksum != mig.checksum
+				delete(applied, mig.version)
+			}
+			statuses = append(statuses, status)
+		}
+		for _, a := range applied {
+			appliedAt := a.appliedAt
+			statuses = append(statuses, MigrationStatus{
+				Version:   a.version,
+				Name:      a.name,
+				AppliedAt: &appliedAt,
+				Unknown:   true,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// Up applies pending migrations up to and including version target, or all
+// of them if target is 0. It returns the migrations it applied.
+func (m *Migrator) Up(ctx context.Context, target int) ([]MigrationStatus, error) {
+	var done []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn, migrations []migration, applied map[int]appliedMigration) error {
+		if err := verifyMigrations(migrations, applied); err != nil {
+			return err
+		}
+
+		for _, mig := range migrations {
+			if target > 0 && mig.version > target {
+				break
+			}
+			if _, ok := applied[mig.version]; ok {
+				continue
+			}
+
+			now := time.Now().UTC()
+			err := m.inTx(ctx, conn, mig.name, mig.up,
+				"INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)",
+				mig.version, mig.name, mig.checksum, now)
+			if err != nil {
+				return err
+			}
+			done = append(done, MigrationStatus{Version: mig.version, Name: mig.name, AppliedAt: &now})
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the most recently applied migrations, newest first, and
+// returns the migrations it reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]MigrationStatus, error) {
+	var done []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn, migrations []migration, applied map[int]appliedMigration) error {
+		if err := verifyMigrations(migrations, applied); err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			mig := migrations[i]
+			if _, ok := applied[mig.version]; !ok {
+				continue
+			}
+			if mig.down == "" {
+				return fmt.Errorf("migration %s has no down migration", mig.name)
+			}
+
+			err := m.inTx(ctx, conn, mig.name, mig.down,
+				"DELETE FROM schema_migrations WHERE version = ?", mig.version)
+			if err != nil {
+				return err
+			}
+			done = append(done, MigrationStatus{Version: mig.version, Name: mig.name})
+		}
+		return nil
+	})
+	return done, err
+}
+
+// withLock runs fn on a single connection holding the dialect's migration
+// lock, with the shipped migrations and those already applied.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn, migrations []migration, applied map[int]appliedMigration) error) error {
+	d := m.db.dialect
+
+	migrations, err := loadMigrations(d)
+	if err != nil {
+		return err
+	}
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if d.migrationLock != "" {
+		if _, err := conn.ExecContext(ctx, d.migrationLock); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer conn.ExecContext(context.Background(), d.migrationUnlock)
+	}
+
+	if _, err := conn.ExecContext(ctx, d.migrationsTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var a appliedMigration
+		if err := rows.Scan(&a.version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+		applied[a.version] = a
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+	rows.Close()
+
+	return fn(conn, migrations, applied)
+}
+
+// inTx runs a migration's SQL and its schema_migrations bookkeeping in one
+// transaction.
+func (m *Migrator) inTx(ctx context.Context, conn *sql.Conn, name, script, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("failed to execute migration %s: %w", name, err)
+	}
+	if _, err := tx.ExecContext(ctx, m.db.dialect.rebind(record), args...); err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// verifyMigrations refuses to touch a schema whose applied migrations were
+// edited afterwards or come from a newer hub.
+func verifyMigrations(migrations []migration, applied map[int]appliedMigration) error {
+	known := make(map[int]migration, len(migrations))
+	for _, mig := range migrations {
+		known[mig.version] = mig
+	}
+
+	for version, a := range applied {
+		mig, ok := known[version]
+		if !ok {
+			return fmt.Errorf("database has migration %s which this hub does not know", a.name)
+		}
+		if mig.checksum != a.checksum {
+			return fmt.Errorf("migration %s was modified after it was applied", mig.name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+-- Reverts 001_initial_schema.sql
+DROP TABLE IF EXISTS agent_sessions;
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS deployment_instances;
+DROP TABLE IF EXISTS deployments;
+DROP TABLE IF EXISTS config_versions;
+DROP TABLE IF EXISTS instances;
+DROP TABLE IF EXISTS configs;
+DROP TABLE IF EXISTS users;
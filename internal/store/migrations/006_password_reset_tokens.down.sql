@@ -0,0 +1,2 @@
+-- Reverts 006_password_reset_tokens.sql
+DROP TABLE IF EXISTS password_reset_tokens;
@@ -0,0 +1,3 @@
+-- Reverts 007_mfa.sql
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TABLE IF EXISTS user_mfa;
@@ -0,0 +1,15 @@
+-- ============================================
+-- Refresh Token Rotation
+-- ============================================
+-- A user session keeps its ID for its whole lifetime while its refresh token
+-- is rotated on every refresh. Superseded token hashes are kept so that
+-- presenting one again (a sign of theft) can revoke the session.
+CREATE TABLE IF NOT EXISTS refresh_token_history (
+    token_hash TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    rotated_at DATETIME NOT NULL,
+
+    FOREIGN KEY (session_id) REFERENCES user_sessions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_token_history_session ON refresh_token_history(session_id);
@@ -0,0 +1,2 @@
+-- Reverts 009_signing_keys.sql
+DROP TABLE IF EXISTS signing_keys;
@@ -0,0 +1,13 @@
+-- ============================================
+-- Token Signing Keys
+-- ============================================
+-- Asymmetric keys the hub signs JWTs with. The newest key without retired_at
+-- signs new tokens; retired keys keep verifying tokens until expires_at.
+CREATE TABLE IF NOT EXISTS signing_keys (
+    id TEXT PRIMARY KEY,
+    algorithm TEXT NOT NULL,
+    private_key TEXT NOT NULL,  -- PEM-encoded PKCS #8
+    created_at DATETIME NOT NULL,
+    retired_at DATETIME,
+    expires_at DATETIME
+);
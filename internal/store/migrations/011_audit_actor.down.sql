@@ -0,0 +1,5 @@
+-- Reverts 011_audit_actor.sql
+ALTER TABLE audit_logs DROP COLUMN actor_cert_serial;
+ALTER TABLE audit_logs DROP COLUMN actor_spiffe_id;
+ALTER TABLE audit_logs DROP COLUMN actor_id;
+ALTER TABLE audit_logs DROP COLUMN actor_type;
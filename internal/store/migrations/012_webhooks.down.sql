@@ -0,0 +1,3 @@
+-- Reverts 012_webhooks.sql
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
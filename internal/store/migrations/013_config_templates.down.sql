@@ -0,0 +1,4 @@
+-- Reverts 013_config_templates.sql
+DROP TABLE IF EXISTS instance_variables;
+DROP TABLE IF EXISTS config_variables;
+ALTER TABLE config_versions DROP COLUMN is_template;
@@ -0,0 +1,3 @@
+-- Reverts 014_config_layers.sql
+ALTER TABLE config_versions DROP COLUMN layers;
+DROP TABLE IF EXISTS config_layers;
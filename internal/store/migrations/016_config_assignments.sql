@@ -0,0 +1,26 @@
+-- ============================================
+-- Config Assignments
+-- ============================================
+-- A version is approved once a deployment of it completes or someone
+-- approves it; assignments that follow a config use its latest approved
+-- version.
+ALTER TABLE config_versions ADD COLUMN approved_at DATETIME;
+
+-- Desired-state assignments: instances matching the JSON label selector get
+-- the config when they register without one. version pins a config
+-- version; NULL follows the latest approved version. The highest priority
+-- wins, then the most specific selector.
+CREATE TABLE IF NOT EXISTS config_assignments (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    config_id TEXT NOT NULL,
+    version INTEGER,
+    selector TEXT NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 0,
+    created_by TEXT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    FOREIGN KEY (config_id) REFERENCES configs(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_config_assignments_config ON config_assignments(config_id);
@@ -0,0 +1,8 @@
+-- ============================================
+-- Auto-Follow Policy
+-- ============================================
+-- Heartbeats reconcile instances against the version they were deployed or
+-- assigned. Configs and instances opted in with auto_follow follow the
+-- config's latest version instead, without a deployment.
+ALTER TABLE configs ADD COLUMN auto_follow BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE instances ADD COLUMN auto_follow BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+-- Reverts 018_config_drift.sql
+DROP TABLE IF EXISTS drift_policies;
+DROP INDEX IF EXISTS idx_instances_drifted_at;
+ALTER TABLE instances DROP COLUMN drifted_at;
+ALTER TABLE instances DROP COLUMN delivered_config_hash;
+ALTER TABLE instances DROP COLUMN reported_config_hash;
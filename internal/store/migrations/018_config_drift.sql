@@ -0,0 +1,26 @@
+-- ============================================
+-- Config Drift
+-- ============================================
+-- Agents report the hash of the config on disk. delivered_config_hash is
+-- the hash the hub last sent, so a local edit, which matches neither it
+-- nor the desired version, can be told apart from a change on the hub.
+-- drifted_at is set while an instance runs such an edit.
+ALTER TABLE instances ADD COLUMN reported_config_hash TEXT;
+ALTER TABLE instances ADD COLUMN delivered_config_hash TEXT;
+ALTER TABLE instances ADD COLUMN drifted_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_instances_drifted_at ON instances(drifted_at);
+
+-- Remediation policies for drifted instances matching the JSON label
+-- selector: alert, redeploy or adopt. The highest priority wins, then the
+-- most specific selector.
+CREATE TABLE IF NOT EXISTS drift_policies (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    selector TEXT NOT NULL,
+    action TEXT NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 0,
+    created_by TEXT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
@@ -0,0 +1,2 @@
+-- Reverts 002_user_sessions.sql
+DROP TABLE IF EXISTS user_sessions;
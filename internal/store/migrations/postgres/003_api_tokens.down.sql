@@ -0,0 +1,3 @@
+-- Reverts 003_api_tokens.sql
+DROP TABLE IF EXISTS api_tokens;
+DROP TABLE IF EXISTS service_accounts;
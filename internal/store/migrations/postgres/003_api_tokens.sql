@@ -0,0 +1,36 @@
+-- ============================================
+-- Service Accounts (non-human token owners)
+-- ============================================
+-- A service account is a row in users without credentials; this table
+-- marks which users are service accounts and records their metadata.
+CREATE TABLE IF NOT EXISTS service_accounts (
+    user_id TEXT PRIMARY KEY,
+    description TEXT,
+    created_by TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- ============================================
+-- API Tokens (long-lived, scoped bearer tokens)
+-- ============================================
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    user_id TEXT NOT NULL,  -- Owner (user or service account)
+    token_hash TEXT NOT NULL UNIQUE,  -- SHA256 hash of token
+    token_prefix TEXT NOT NULL,  -- First characters of token, for identification
+    scopes TEXT NOT NULL,  -- JSON array of scopes
+    labels TEXT,  -- JSON object; restricts instance access to matching labels
+    expires_at TIMESTAMPTZ,  -- NULL means no expiry
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_by TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_tokens_user ON api_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_api_tokens_hash ON api_tokens(token_hash);
@@ -0,0 +1,3 @@
+-- Reverts 004_rbac.sql
+DROP TABLE IF EXISTS role_bindings;
+DROP TABLE IF EXISTS roles;
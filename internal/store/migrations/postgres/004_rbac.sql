@@ -0,0 +1,29 @@
+-- ============================================
+-- Custom Roles (label- and name-scoped permissions)
+-- ============================================
+-- The built-in admin/operator/viewer roles live on users.role and are
+-- defined in code. Custom roles add permissions on top of them.
+CREATE TABLE IF NOT EXISTS roles (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT,
+    permissions TEXT NOT NULL,  -- JSON array of {permission, instance_selector, config_pattern}
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- ============================================
+-- Role Bindings (users to custom roles)
+-- ============================================
+CREATE TABLE IF NOT EXISTS role_bindings (
+    user_id TEXT NOT NULL,
+    role_id TEXT NOT NULL,
+    created_by TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (user_id, role_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_bindings_role ON role_bindings(role_id);
@@ -0,0 +1,2 @@
+-- Reverts 005_login_throttles.sql
+DROP TABLE IF EXISTS login_throttles;
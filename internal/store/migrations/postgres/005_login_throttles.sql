@@ -0,0 +1,11 @@
+-- ============================================
+-- Login Throttles (failed login tracking)
+-- ============================================
+-- One row per throttled key, e.g. "account:alice@example.com" or
+-- "ip:203.0.113.7". Rows are removed on successful login or admin unlock.
+CREATE TABLE IF NOT EXISTS login_throttles (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ
+);
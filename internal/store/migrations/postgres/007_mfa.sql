@@ -0,0 +1,25 @@
+-- ============================================
+-- Multi-Factor Authentication (TOTP)
+-- ============================================
+-- A row with enabled_at NULL is a pending enrollment awaiting its first code.
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id TEXT PRIMARY KEY,
+    secret TEXT NOT NULL,              -- base32 TOTP secret
+    last_used_step BIGINT NOT NULL DEFAULT 0,  -- rejects replayed codes
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    enabled_at TIMESTAMPTZ,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    code_hash TEXT NOT NULL UNIQUE,    -- SHA256 hash of the recovery code
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    used_at TIMESTAMPTZ,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user ON mfa_recovery_codes(user_id);
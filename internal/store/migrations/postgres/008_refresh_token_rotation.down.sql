@@ -0,0 +1,2 @@
+-- Reverts 008_refresh_token_rotation.sql
+DROP TABLE IF EXISTS refresh_token_history;
@@ -0,0 +1,7 @@
+-- Reverts 010_audit_chain.sql
+DROP TABLE IF EXISTS audit_checkpoints;
+DROP TABLE IF EXISTS audit_chain;
+DROP INDEX IF EXISTS idx_audit_logs_sequence;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS hash;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS prev_hash;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS sequence;
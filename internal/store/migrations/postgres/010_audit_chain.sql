@@ -0,0 +1,34 @@
+-- ============================================
+-- Audit Log Hash Chain
+-- ============================================
+-- Each audit log entry records the hash of the entry before it and a hash
+-- of its own content, so edited, inserted or deleted entries break the
+-- chain. Entries written before this migration have no sequence and are
+-- not part of the chain.
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS sequence BIGINT;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS prev_hash TEXT;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS hash TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_logs_sequence ON audit_logs(sequence);
+
+-- The head of the chain, kept apart from audit_logs so that retention can
+-- delete entries without the chain starting over. Writers lock this row.
+CREATE TABLE IF NOT EXISTS audit_chain (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    sequence BIGINT NOT NULL,
+    hash TEXT NOT NULL
+);
+
+INSERT INTO audit_chain (id, sequence, hash) VALUES (1, 0, '') ON CONFLICT DO NOTHING;
+
+-- Signed snapshots of the chain head. A checkpoint pins the entry at its
+-- sequence, so truncating the end of the chain is detected too.
+CREATE TABLE IF NOT EXISTS audit_checkpoints (
+    id TEXT PRIMARY KEY,
+    sequence BIGINT NOT NULL,
+    hash TEXT NOT NULL,
+    signature TEXT NOT NULL,  -- hex HMAC-SHA256 of the sequence and hash
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_checkpoints_sequence ON audit_checkpoints(sequence);
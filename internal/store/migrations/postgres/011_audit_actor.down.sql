@@ -0,0 +1,5 @@
+-- Reverts 011_audit_actor.sql
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS actor_cert_serial;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS actor_spiffe_id;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS actor_id;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS actor_type;
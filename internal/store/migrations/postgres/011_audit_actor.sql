@@ -0,0 +1,12 @@
+-- ============================================
+-- Audit Log Actors
+-- ============================================
+-- Records who performed an action: a user, an agent or the hub itself.
+-- Agents are identified by instance ID and, when they connect over mTLS,
+-- by their SPIFFE ID or client certificate serial. Entries written before
+-- this migration have no actor type; filters treat them as user entries
+-- when they have a user_id and as system entries otherwise.
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS actor_type TEXT;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS actor_id TEXT;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS actor_spiffe_id TEXT;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS actor_cert_serial TEXT;
@@ -0,0 +1,40 @@
+-- ============================================
+-- Outgoing Webhooks
+-- ============================================
+-- Admin-managed subscriptions that receive deployment and fleet events as
+-- signed HTTP POSTs.
+CREATE TABLE IF NOT EXISTS webhooks (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,       -- HMAC-SHA256 key payloads are signed with
+    event_types TEXT NOT NULL,  -- JSON array; empty receives every event
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by TEXT,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+-- One row per event sent to a webhook. Pending deliveries are retried at
+-- next_attempt_at until they succeed or run out of attempts; a redelivery is
+-- a new row pointing at the original.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id TEXT PRIMARY KEY,
+    webhook_id TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL,  -- pending, succeeded, failed
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_code INTEGER,
+    response_body TEXT,    -- truncated
+    error TEXT,
+    redelivery_of TEXT,
+    next_attempt_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL,
+    completed_at TIMESTAMPTZ,
+    FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
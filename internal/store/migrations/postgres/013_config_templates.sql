@@ -0,0 +1,25 @@
+-- ============================================
+-- Config Templates
+-- ============================================
+-- A template version is rendered per instance when an agent fetches it,
+-- with the instance's name, hostname and labels and the variables below.
+ALTER TABLE config_versions ADD COLUMN IF NOT EXISTS is_template BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Hub-managed template variables available to every instance.
+CREATE TABLE IF NOT EXISTS config_variables (
+    name TEXT PRIMARY KEY,
+    value TEXT NOT NULL,
+    updated_by TEXT,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+-- Per-instance variables; they take precedence over fleet-wide ones.
+CREATE TABLE IF NOT EXISTS instance_variables (
+    instance_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    value TEXT NOT NULL,
+    updated_by TEXT,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (instance_id, name),
+    FOREIGN KEY (instance_id) REFERENCES instances(id) ON DELETE CASCADE
+);
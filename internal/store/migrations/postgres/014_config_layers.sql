@@ -0,0 +1,20 @@
+-- ============================================
+-- Layered Configs
+-- ============================================
+-- A composed config is built from a base config (position 0) with overlay
+-- configs applied in order. Its versions are resolved by the hub whenever a
+-- layer changes.
+CREATE TABLE IF NOT EXISTS config_layers (
+    config_id TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    layer_config_id TEXT NOT NULL,
+    PRIMARY KEY (config_id, position),
+    FOREIGN KEY (config_id) REFERENCES configs(id),
+    FOREIGN KEY (layer_config_id) REFERENCES configs(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_config_layers_layer ON config_layers(layer_config_id);
+
+-- JSON array of the {config_id, version} layers a resolved version was
+-- built from; NULL for versions that were written directly.
+ALTER TABLE config_versions ADD COLUMN IF NOT EXISTS layers TEXT;
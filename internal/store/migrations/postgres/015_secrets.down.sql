@@ -0,0 +1,2 @@
+-- Reverts 015_secrets.sql
+DROP TABLE IF EXISTS secrets;
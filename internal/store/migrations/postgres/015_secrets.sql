@@ -0,0 +1,18 @@
+-- ============================================
+-- Secrets
+-- ============================================
+-- Values configs refer to with secret="name", resolved only when an agent
+-- fetches its config. Each value is encrypted with its own data key, which
+-- is in turn encrypted with the hub's master key (key_id identifies it).
+-- Both are stored base64-encoded.
+CREATE TABLE IF NOT EXISTS secrets (
+    name TEXT PRIMARY KEY,
+    description TEXT,
+    ciphertext TEXT NOT NULL,
+    encrypted_key TEXT NOT NULL,
+    key_id TEXT NOT NULL,
+    created_by TEXT,
+    updated_by TEXT,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,3 @@
+-- Reverts 016_config_assignments.sql
+DROP TABLE IF EXISTS config_assignments;
+ALTER TABLE config_versions DROP COLUMN approved_at;
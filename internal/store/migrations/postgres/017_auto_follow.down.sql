@@ -0,0 +1,3 @@
+-- Reverts 017_auto_follow.sql
+ALTER TABLE instances DROP COLUMN auto_follow;
+ALTER TABLE configs DROP COLUMN auto_follow;
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMigrator_Status(t *testing.T) {
+	s := setupTestStore(t)
+	m := &Migrator{db: s.db}
+
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+
+	migrations, _ := loadMigrations(s.db.dialect)
+	if len(statuses) != len(migrations) {
+		t.Fatalf("expected %d migrations, got %d", len(migrations), len(statuses))
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("migration %s not applied", status.Name)
+		}
+		if status.Modified || status.Unknown {
+			t.Errorf("migration %s unexpectedly flagged: %+v", status.Name, status)
+		}
+	}
+}
+
+func TestMigrator_UpIsIdempotent(t *testing.T) {
+	s := setupTestStore(t)
+	m := &Migrator{db: s.db}
+
+	applied, err := m.Up(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("expected no pending migrations, applied %d", len(applied))
+	}
+}
+
+func TestMigrator_DownAndUp(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestStore(t)
+	m := &Migrator{db: s.db}
+
+	migrations, _ := loadMigrations(s.db.dialect)
+	latest := migrations[len(migrations)-1]
+
+	reverted, err := m.Down(ctx, 1)
+	if err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	if len(reverted) != 1 || reverted[0].Version != latest.version {
+		t.Fatalf("expected to revert %s, got %+v", latest.name, reverted)
+	}
+
+	// The reverted migration's table is gone
+	if _, err := s.ListDriftPolicies(ctx); err == nil {
+		t.Error("expected drift_policies to be dropped")
+	}
+
+	applied, err := m.Up(ctx, 0)
+	if err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if len(applied) != 1 || applied[0].Version != latest.version {
+		t.Fatalf("expected to reapply %s, got %+v", latest.name, applied)
+	}
+	if _, err := s.ListDriftPolicies(ctx); err != nil {
+		t.Errorf("ListDriftPolicies after Up failed: %v", err)
+	}
+}
+
+func TestMigrator_UpToTarget(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestStore(t)
+	m := &Migrator{db: s.db}
+
+	if _, err := m.Down(ctx, 3); err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+
+	migrations, _ := loadMigrations(s.db.dialect)
+	target := migrations[len(migrations)-2].version
+
+	applied, err := m.Up(ctx, target)
+	if err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if len(applied) != 2 {
+		t.Fatalf("expected 2 migrations applied, got %d", len(applied))
+	}
+	if applied[len(applied)-1].Version != target {
+		t.Errorf("expected to stop at version %d, got %d", target, applied[len(applied)-1].Version)
+	}
+}
+
+func TestMigrator_DetectsModifiedMigration(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestStore(t)
+	m := &Migrator{db: s.db}
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE schema_migrations SET checksum = 'tampered' WHERE version = 1"); err != nil {
+		t.Fatalf("failed to tamper checksum: %v", err)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if !statuses[0].Modified {
+		t.Error("expected first migration to be flagged as modified")
+	}
+
+	_, err = m.Up(ctx, 0)
+	if err == nil || !strings.Contains(err.Error(), "modified") {
+		t.Errorf("expected modified migration error, got %v", err)
+	}
+}
+
+func TestMigrator_DetectsUnknownMigration(t *testing.T) {
+	ctx := context.Background()
+	s := setupTestStore(t)
+	m := &Migrator{db: s.db}
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)",
+		999, "999_from_the_future", "x")
+	if err != nil {
+		t.Fatalf("failed to insert migration: %v", err)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if last := statuses[len(statuses)-1]; last.Version != 999 || !last.Unknown {
+		t.Errorf("expected unknown migration 999 last, got %+v", last)
+	}
+
+	if _, err := m.Up(ctx, 0); err == nil {
+		t.Error("expected Up to refuse an unknown migration")
+	}
+}
@@ -19,6 +19,10 @@ type Instance struct {
 	CurrentConfigVersion *int              `json:"current_config_version,omitempty"`
 	Labels               map[string]string `json:"labels,omitempty"`
 	Capabilities         []string          `json:"capabilities,omitempty"`
+	AutoFollow           bool              `json:"auto_follow"`                     // Follow the config's latest version
+	ReportedConfigHash   *string           `json:"reported_config_hash,omitempty"`  // Hash of the config on disk
+	DeliveredConfigHash  *string           `json:"delivered_config_hash,omitempty"` // Hash the hub last sent
+	DriftedAt            *time.Time        `json:"drifted_at,omitempty"`            // Set while running local changes
 	CreatedAt            time.Time         `json:"created_at"`
 	UpdatedAt            time.Time         `json:"updated_at"`
 }
@@ -41,6 +45,7 @@ type Config struct {
 	Name           string     `json:"name"`
 	Description    *string    `json:"description,omitempty"`
 	CurrentVersion int        `json:"current_version"`
+	AutoFollow     bool       `json:"auto_follow"` // Instances follow the latest version
 	CreatedBy      *string    `json:"created_by,omitempty"`
 	CreatedAt      time.Time  `json:"created_at"`
 	UpdatedAt      time.Time  `json:"updated_at"`
@@ -49,14 +54,24 @@ type Config struct {
 
 // ConfigVersion represents an immutable version of a configuration.
 type ConfigVersion struct {
-	ID            string    `json:"id"`
-	ConfigID      string    `json:"config_id"`
-	Version       int       `json:"version"`
-	Content       string    `json:"content"`
-	ContentHash   string    `json:"content_hash"`
-	ChangeSummary *string   `json:"change_summary,omitempty"`
-	CreatedBy     *string   `json:"created_by,omitempty"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID            string        `json:"id"`
+	ConfigID      string        `json:"config_id"`
+	Version       int           `json:"version"`
+	Content       string        `json:"content"`
+	ContentHash   string        `json:"content_hash"`
+	IsTemplate    bool          `json:"is_template"`      // Rendered per instance on delivery
+	Layers        []ConfigLayer `json:"layers,omitempty"` // Layer versions a composed config was resolved from
+	ChangeSummary *string       `json:"change_summary,omitempty"`
+	CreatedBy     *string       `json:"created_by,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+	ApprovedAt    *time.Time    `json:"approved_at,omitempty"` // Set once deployed or approved
+}
+
+// ConfigLayer is a version of a config used as a layer of a composed
+// config. The base comes first, then overlays in the order they apply.
+type ConfigLayer struct {
+	ConfigID string `json:"config_id"`
+	Version  int    `json:"version"`
 }
 
 // Deployment represents a configuration deployment to instances.
@@ -149,6 +164,15 @@ const (
 	UserRoleViewer   UserRole = "viewer"
 )
 
+// ActorType identifies what kind of actor performed an audited action.
+type ActorType string
+
+const (
+	ActorTypeUser   ActorType = "user"
+	ActorTypeAgent  ActorType = "agent"
+	ActorTypeSystem ActorType = "system"
+)
+
 // AuditLog represents an audit log entry.
 type AuditLog struct {
 	ID           string          `json:"id"`
@@ -159,6 +183,28 @@ type AuditLog struct {
 	ResourceID   *string         `json:"resource_id,omitempty"`
 	Details      json.RawMessage `json:"details,omitempty"`
 	IPAddress    *string         `json:"ip_address,omitempty"`
+	// ActorType and ActorID name who performed the action: the user ID for
+	// users and the instance ID for agents. Agents connecting over mTLS are
+	// also identified by their SPIFFE ID or certificate serial. The actor is
+	// empty for entries written before actors were recorded.
+	ActorType       ActorType `json:"actor_type,omitempty"`
+	ActorID         *string   `json:"actor_id,omitempty"`
+	ActorSPIFFEID   *string   `json:"actor_spiffe_id,omitempty"`
+	ActorCertSerial *string   `json:"actor_cert_serial,omitempty"`
+	// Sequence, PrevHash and Hash link the entry into the audit hash chain.
+	// They are empty for entries written before chaining was introduced.
+	Sequence int64  `json:"sequence,omitempty"`
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// AuditCheckpoint is a signed record of the audit hash chain's head.
+type AuditCheckpoint struct {
+	ID        string    `json:"id"`
+	Sequence  int64     `json:"sequence"`
+	Hash      string    `json:"hash"`
+	Signature string    `json:"signature"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // AgentSession represents an active agent session.
@@ -183,6 +229,210 @@ type UserSession struct {
 	UserAgent        *string    `json:"user_agent,omitempty"`
 }
 
+// PasswordResetToken is a single-use token for setting a new password.
+type PasswordResetToken struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	TokenHash string     `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// UserMFA is a user's TOTP enrollment. EnabledAt is nil until the user
+// confirms the enrollment with a valid code.
+type UserMFA struct {
+	UserID       string     `json:"user_id"`
+	Secret       string     `json:"-"`
+	LastUsedStep int64      `json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+	EnabledAt    *time.Time `json:"enabled_at,omitempty"`
+}
+
+// SigningKey is a private key used to sign JWTs. Keys are retired on
+// rotation and removed once ExpiresAt has passed.
+type SigningKey struct {
+	ID         string     `json:"id"`
+	Algorithm  string     `json:"algorithm"`
+	PrivateKey string     `json:"-"` // PEM-encoded PKCS #8
+	CreatedAt  time.Time  `json:"created_at"`
+	RetiredAt  *time.Time `json:"retired_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// APIToken represents a long-lived, scoped bearer token for automation.
+type APIToken struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	UserID      string            `json:"user_id"`
+	TokenHash   string            `json:"-"`
+	TokenPrefix string            `json:"token_prefix"`
+	Scopes      []string          `json:"scopes"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`
+	LastUsedAt  *time.Time        `json:"last_used_at,omitempty"`
+	RevokedAt   *time.Time        `json:"revoked_at,omitempty"`
+	CreatedBy   *string           `json:"created_by,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// ServiceAccount represents a non-human user that owns API tokens.
+type ServiceAccount struct {
+	UserID      string    `json:"user_id"`
+	Name        string    `json:"name"`
+	Role        UserRole  `json:"role"`
+	Description *string   `json:"description,omitempty"`
+	CreatedBy   *string   `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Role is a named set of permissions that can be bound to users in
+// addition to their built-in role.
+type Role struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Description *string          `json:"description,omitempty"`
+	Permissions []RolePermission `json:"permissions"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// RolePermission grants a permission on resources matching its selectors.
+// Empty selectors match every resource.
+type RolePermission struct {
+	Permission       string            `json:"permission"`
+	InstanceSelector map[string]string `json:"instance_selector,omitempty"`
+	ConfigPattern    string            `json:"config_pattern,omitempty"` // Glob on config name
+}
+
+// RoleBinding assigns a custom role to a user.
+type RoleBinding struct {
+	UserID    string    `json:"user_id"`
+	RoleID    string    `json:"role_id"`
+	CreatedBy *string   `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LoginThrottle tracks failed logins for an account or client IP.
+type LoginThrottle struct {
+	Key           string     `json:"key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+}
+
+// Variable is a hub-managed value config templates can refer to. Variables
+// with an instance ID apply to that instance only and take precedence over
+// fleet-wide ones.
+type Variable struct {
+	InstanceID string    `json:"instance_id,omitempty"`
+	Name       string    `json:"name"`
+	Value      string    `json:"value"`
+	UpdatedBy  *string   `json:"updated_by,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ConfigAssignment binds a config to the instances matching a label
+// selector, so that instances registering without a config get one.
+type ConfigAssignment struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	ConfigID string `json:"config_id"`
+	// Version pins a config version; nil follows the latest approved one.
+	Version *int `json:"version,omitempty"`
+	// Selector matches instances carrying all of its labels; an empty
+	// selector matches every instance.
+	Selector  map[string]string `json:"selector"`
+	Priority  int               `json:"priority"`
+	CreatedBy *string           `json:"created_by,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// DriftAction is what the hub does about an instance running local changes
+// to its config.
+type DriftAction string
+
+const (
+	DriftActionAlert    DriftAction = "alert"    // Report the drift only
+	DriftActionRedeploy DriftAction = "redeploy" // Restore the desired version
+	DriftActionAdopt    DriftAction = "adopt"    // Save the local content as a new version
+)
+
+// DriftPolicy sets the remediation for drifted instances matching a label
+// selector.
+type DriftPolicy struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Selector matches instances carrying all of its labels; an empty
+	// selector matches every instance.
+	Selector  map[string]string `json:"selector"`
+	Action    DriftAction       `json:"action"`
+	Priority  int               `json:"priority"`
+	CreatedBy *string           `json:"created_by,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// Secret is an encrypted value configs refer to by name. The encrypted
+// fields are never serialized, so API responses only carry metadata.
+type Secret struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description,omitempty"`
+	// Ciphertext is the value encrypted with the data key.
+	Ciphertext []byte `json:"-"`
+	// EncryptedKey is the data key encrypted with the master key KeyID.
+	EncryptedKey []byte    `json:"-"`
+	KeyID        string    `json:"-"`
+	CreatedBy    *string   `json:"created_by,omitempty"`
+	UpdatedBy    *string   `json:"updated_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Webhook is a subscription that receives events as signed HTTP POSTs.
+type Webhook struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Secret signs payloads. It is only returned when the webhook is created.
+	Secret string `json:"-"`
+	// EventTypes filters the events delivered; empty means every event.
+	EventTypes []string  `json:"event_types"`
+	Enabled    bool      `json:"enabled"`
+	CreatedBy  *string   `json:"created_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// WebhookDeliveryStatus represents the state of a webhook delivery.
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery records an event sent to a webhook and the outcome of its
+// latest attempt.
+type WebhookDelivery struct {
+	ID            string                `json:"id"`
+	WebhookID     string                `json:"webhook_id"`
+	EventID       string                `json:"event_id"`
+	EventType     string                `json:"event_type"`
+	Payload       json.RawMessage       `json:"payload"`
+	Status        WebhookDeliveryStatus `json:"status"`
+	Attempts      int                   `json:"attempts"`
+	ResponseCode  *int                  `json:"response_code,omitempty"`
+	ResponseBody  *string               `json:"response_body,omitempty"`
+	Error         *string               `json:"error,omitempty"`
+	RedeliveryOf  *string               `json:"redelivery_of,omitempty"`
+	NextAttemptAt *time.Time            `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time             `json:"created_at"`
+	CompletedAt   *time.Time            `json:"completed_at,omitempty"`
+}
+
 // ListUsersOptions contains options for listing users.
 type ListUsersOptions struct {
 	Role   UserRole
@@ -193,11 +443,13 @@ type ListUsersOptions struct {
 // ListAuditLogsOptions contains options for listing audit logs.
 type ListAuditLogsOptions struct {
 	UserID       string
+	ActorType    ActorType
 	Action       string
 	ResourceType string
 	ResourceID   string
 	Since        *time.Time
 	Until        *time.Time
+	OldestFirst  bool
 	Limit        int
 	Offset       int
 }
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// ============================================
+// Secret Operations
+// ============================================
+
+// secretColumns are the columns read by scanSecret.
+const secretColumns = `name, description, ciphertext, encrypted_key, key_id, created_by, updated_by, created_at, updated_at`
+
+// scanSecret scans a single secrets row.
+func scanSecret(scan func(dest ...interface{}) error) (*Secret, error) {
+	var secret Secret
+	var description, createdBy, updatedBy sql.NullString
+	var ciphertext, encryptedKey string
+
+	err := scan(&secret.Name, &description, &ciphertext, &encryptedKey, &secret.KeyID,
+		&createdBy, &updatedBy, &secret.CreatedAt, &secret.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	secret.Description = StringPtr(description)
+	secret.CreatedBy = StringPtr(createdBy)
+	secret.UpdatedBy = StringPtr(updatedBy)
+	if secret.Ciphertext, err = base64.StdEncoding.DecodeString(ciphertext); err != nil {
+		return nil, fmt.Errorf("failed to decode secret %s: %w", secret.Name, err)
+	}
+	if secret.EncryptedKey, err = base64.StdEncoding.DecodeString(encryptedKey); err != nil {
+		return nil, fmt.Errorf("failed to decode secret %s: %w", secret.Name, err)
+	}
+	return &secret, nil
+}
+
+// SetSecret creates a secret or replaces its value and description. The
+// creator and creation time of an existing secret are kept; secret is
+// updated with the stored values.
+func (s *sqlStore) SetSecret(ctx context.Context, secret *Secret) error {
+	now := time.Now().UTC()
+	createdBy := secret.CreatedBy
+	if createdBy == nil {
+		createdBy = secret.UpdatedBy
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO secrets (name, description, ciphertext, encrypted_key, key_id, created_by, updated_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET
+			description = excluded.description,
+			ciphertext = excluded.ciphertext,
+			encrypted_key = excluded.encrypted_key,
+			key_id = excluded.key_id,
+			updated_by = excluded.updated_by,
+			updated_at = excluded.updated_at
+	`, secret.Name, NullString(secret.Description),
+		base64.StdEncoding.EncodeToString(secret.Ciphertext),
+		base64.StdEncoding.EncodeToString(secret.EncryptedKey),
+		secret.KeyID, NullString(createdBy), NullString(secret.UpdatedBy), now, now)
+	if err != nil {
+		return fmt.Errorf("failed to set secret: %w", err)
+	}
+
+	stored, err := s.GetSecret(ctx, secret.Name)
+	if err != nil {
+		return err
+	}
+	*secret = *stored
+	return nil
+}
+
+// GetSecret retrieves a secret by name, or nil if it does not exist.
+func (s *sqlStore) GetSecret(ctx context.Context, name string) (*Secret, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+secretColumns+` FROM secrets WHERE name = ?`, name)
+	secret, err := scanSecret(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get secret: %w", err)
+	}
+	return secret, nil
+}
+
+// ListSecrets retrieves all secrets ordered by name.
+func (s *sqlStore) ListSecrets(ctx context.Context) ([]Secret, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+secretColumns+` FROM secrets ORDER BY name ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	defer rows.Close()
+
+	var secrets []Secret
+	for rows.Next() {
+		secret, err := scanSecret(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan secret: %w", err)
+		}
+		secrets = append(secrets, *secret)
+	}
+
+	return secrets, rows.Err()
+}
+
+// DeleteSecret deletes a secret.
+func (s *sqlStore) DeleteSecret(ctx context.Context, name string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM secrets WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete secret: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("secret not found")
+	}
+
+	return nil
+}
@@ -3,86 +3,258 @@ package store
 import (
 	"context"
 	"database/sql"
-	_ "embed"
 	"encoding/json"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/mattn/go-sqlite3"
 )
 
-//go:embed migrations/001_initial_schema.sql
-var initialSchema string
+// Store provides database operations for the Hub. It is implemented for
+// SQLite and PostgreSQL; New picks one from the database URL.
+type Store interface {
+	// Close closes the database connection.
+	Close() error
+	// Ping checks that the database is reachable.
+	Ping(ctx context.Context) error
+
+	// Instance Operations
+	CreateInstance(ctx context.Context, inst *Instance) error
+	GetInstance(ctx context.Context, id string) (*Instance, error)
+	GetInstanceByName(ctx context.Context, name string) (*Instance, error)
+	ListInstances(ctx context.Context, opts ListInstancesOptions) ([]Instance, error)
+	UpdateInstance(ctx context.Context, inst *Instance) error
+	DeleteInstance(ctx context.Context, id string) error
+	UpdateInstanceStatus(ctx context.Context, id string, status InstanceStatus) error
+	UpdateInstanceHeartbeat(ctx context.Context, id string, status InstanceStatus, reportedConfigHash *string) error
+	AdvanceInstanceConfigVersion(ctx context.Context, id, configID string, from *int, to int) (bool, error)
+	SetInstanceDeliveredHash(ctx context.Context, id, hash string) error
+	SetInstanceDriftedAt(ctx context.Context, id string, driftedAt *time.Time) error
+
+	// Config Operations
+	CreateConfig(ctx context.Context, cfg *Config) error
+	GetConfig(ctx context.Context, id string) (*Config, error)
+	ListConfigs(ctx context.Context, opts ListConfigsOptions) ([]Config, error)
+	UpdateConfig(ctx context.Context, cfg *Config) error
+	DeleteConfig(ctx context.Context, id string) error
+
+	// Config Version Operations
+	CreateConfigVersion(ctx context.Context, ver *ConfigVersion) error
+	GetConfigVersion(ctx context.Context, configID string, version int) (*ConfigVersion, error)
+	GetLatestConfigVersion(ctx context.Context, configID string) (*ConfigVersion, error)
+	ListConfigVersions(ctx context.Context, configID string) ([]ConfigVersion, error)
+
+	// Config Layer Operations
+	SetConfigLayers(ctx context.Context, configID string, layerConfigIDs []string) error
+	ListConfigLayers(ctx context.Context, configID string) ([]string, error)
+	ListConfigDependents(ctx context.Context, layerConfigID string) ([]string, error)
+
+	// Deployment Operations
+	CreateDeployment(ctx context.Context, dep *Deployment) error
+	GetDeployment(ctx context.Context, id string) (*Deployment, error)
+	ListDeployments(ctx context.Context, opts ListDeploymentsOptions) ([]Deployment, error)
+	UpdateDeployment(ctx context.Context, dep *Deployment) error
+
+	// Deployment Instance Operations
+	CreateDeploymentInstance(ctx context.Context, di *DeploymentInstance) error
+	GetDeploymentInstance(ctx context.Context, deploymentID, instanceID string) (*DeploymentInstance, error)
+	ListDeploymentInstances(ctx context.Context, deploymentID string) ([]*DeploymentInstance, error)
+	UpdateDeploymentInstance(ctx context.Context, di *DeploymentInstance) error
+	UpsertDeploymentInstance(ctx context.Context, di *DeploymentInstance) error
+	DeleteDeploymentInstances(ctx context.Context, deploymentID string) error
+
+	// User Operations
+	CreateUser(ctx context.Context, user *User) error
+	GetUser(ctx context.Context, id string) (*User, error)
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	GetUserByOIDCSubject(ctx context.Context, subject string) (*User, error)
+	ListUsers(ctx context.Context, opts ListUsersOptions) ([]User, error)
+	UpdateUser(ctx context.Context, user *User) error
+	DeleteUser(ctx context.Context, id string) error
+	UpdateUserLastLogin(ctx context.Context, id string) error
+	CountUsers(ctx context.Context) (int, error)
+
+	// User Session Operations
+	CreateUserSession(ctx context.Context, session *UserSession) error
+	GetUserSessionByTokenHash(ctx context.Context, tokenHash string) (*UserSession, error)
+	GetUserSession(ctx context.Context, id string) (*UserSession, error)
+	GetUserSessionByPreviousTokenHash(ctx context.Context, tokenHash string) (*UserSession, error)
+	ListUserSessions(ctx context.Context, userID string) ([]UserSession, error)
+	RotateUserSessionToken(ctx context.Context, id, oldHash, newHash string, expiresAt time.Time) error
+	RevokeUserSession(ctx context.Context, id string) error
+	RevokeAllUserSessions(ctx context.Context, userID string) error
+	CleanupExpiredSessions(ctx context.Context) (int64, error)
+
+	// Password Reset Token Operations
+	CreatePasswordResetToken(ctx context.Context, token *PasswordResetToken) error
+	GetPasswordResetTokenByHash(ctx context.Context, tokenHash string) (*PasswordResetToken, error)
+	UsePasswordResetToken(ctx context.Context, id string) error
+	CleanupExpiredPasswordResetTokens(ctx context.Context) (int64, error)
+
+	// MFA Operations
+	UpsertUserMFA(ctx context.Context, mfa *UserMFA) error
+	GetUserMFA(ctx context.Context, userID string) (*UserMFA, error)
+	UseUserMFAStep(ctx context.Context, userID string, step int64) (bool, error)
+	EnableUserMFA(ctx context.Context, userID string, recoveryCodeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) error
+	CountRecoveryCodes(ctx context.Context, userID string) (int, error)
+	DeleteUserMFA(ctx context.Context, userID string) error
+
+	// Signing Key Operations
+	CreateSigningKey(ctx context.Context, key *SigningKey) error
+	ListSigningKeys(ctx context.Context) ([]SigningKey, error)
+	RetireSigningKey(ctx context.Context, id string, expiresAt time.Time) error
+	DeleteSigningKey(ctx context.Context, id string) error
+
+	// API Token Operations
+	CreateAPIToken(ctx context.Context, token *APIToken) error
+	GetAPIToken(ctx context.Context, id string) (*APIToken, error)
+	GetAPITokenByHash(ctx context.Context, tokenHash string) (*APIToken, error)
+	ListAPITokens(ctx context.Context, opts ListAPITokensOptions) ([]APIToken, error)
+	RevokeAPIToken(ctx context.Context, id string) error
+	UpdateAPITokenLastUsed(ctx context.Context, id string, usedAt time.Time) error
+
+	// Service Account Operations
+	CreateServiceAccount(ctx context.Context, user *User, sa *ServiceAccount) error
+	GetServiceAccount(ctx context.Context, userID string) (*ServiceAccount, error)
+	ListServiceAccounts(ctx context.Context) ([]ServiceAccount, error)
+
+	// Role Operations
+	CreateRole(ctx context.Context, role *Role) error
+	GetRole(ctx context.Context, id string) (*Role, error)
+	ListRoles(ctx context.Context) ([]Role, error)
+	UpdateRole(ctx context.Context, role *Role) error
+	DeleteRole(ctx context.Context, id string) error
+
+	// Role Binding Operations
+	CreateRoleBinding(ctx context.Context, binding *RoleBinding) error
+	DeleteRoleBinding(ctx context.Context, userID, roleID string) error
+	ListUserRoles(ctx context.Context, userID string) ([]Role, error)
+
+	// Login Throttle Operations
+	GetLoginThrottle(ctx context.Context, key string) (*LoginThrottle, error)
+	UpsertLoginThrottle(ctx context.Context, t *LoginThrottle) error
+	DeleteLoginThrottle(ctx context.Context, key string) error
+
+	// Audit Log Operations
+	CreateAuditLog(ctx context.Context, log *AuditLog) error
+	ListAuditLogs(ctx context.Context, opts ListAuditLogsOptions) ([]AuditLog, error)
+	ListAuditChain(ctx context.Context, afterSequence int64, limit int) ([]AuditLog, error)
+	CountUnchainedAuditLogs(ctx context.Context, since *time.Time) (int64, error)
+	GetAuditChainHead(ctx context.Context) (sequence int64, hash string, err error)
+	CreateAuditCheckpoint(ctx context.Context, cp *AuditCheckpoint) error
+	GetLatestAuditCheckpoint(ctx context.Context) (*AuditCheckpoint, error)
+	ListAuditCheckpoints(ctx context.Context) ([]AuditCheckpoint, error)
+
+	// Webhook Operations
+	CreateWebhook(ctx context.Context, wh *Webhook) error
+	GetWebhook(ctx context.Context, id string) (*Webhook, error)
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	UpdateWebhook(ctx context.Context, wh *Webhook) error
+	DeleteWebhook(ctx context.Context, id string) error
+
+	// Variable Operations
+	SetVariable(ctx context.Context, v *Variable) error
+	ListVariables(ctx context.Context, instanceID string) ([]Variable, error)
+	DeleteVariable(ctx context.Context, instanceID, name string) error
+
+	// Config Assignment Operations
+	ApproveConfigVersion(ctx context.Context, configID string, version int) error
+	GetLatestApprovedConfigVersion(ctx context.Context, configID string) (*ConfigVersion, error)
+	CreateConfigAssignment(ctx context.Context, a *ConfigAssignment) error
+	GetConfigAssignment(ctx context.Context, id string) (*ConfigAssignment, error)
+	ListConfigAssignments(ctx context.Context) ([]ConfigAssignment, error)
+	UpdateConfigAssignment(ctx context.Context, a *ConfigAssignment) error
+	DeleteConfigAssignment(ctx context.Context, id string) error
+
+	// Drift Policy Operations
+	CreateDriftPolicy(ctx context.Context, p *DriftPolicy) error
+	GetDriftPolicy(ctx context.Context, id string) (*DriftPolicy, error)
+	ListDriftPolicies(ctx context.Context) ([]DriftPolicy, error)
+	UpdateDriftPolicy(ctx context.Context, p *DriftPolicy) error
+	DeleteDriftPolicy(ctx context.Context, id string) error
+
+	// Secret Operations
+	SetSecret(ctx context.Context, secret *Secret) error
+	GetSecret(ctx context.Context, name string) (*Secret, error)
+	ListSecrets(ctx context.Context) ([]Secret, error)
+	DeleteSecret(ctx context.Context, name string) error
+
+	// Webhook Delivery Operations
+	CreateWebhookDelivery(ctx context.Context, d *WebhookDelivery) error
+	GetWebhookDelivery(ctx context.Context, id string) (*WebhookDelivery, error)
+	ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]WebhookDelivery, error)
+	ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, d *WebhookDelivery) error
+
+	// Retention Operations
+	DeleteDeploymentsBefore(ctx context.Context, before time.Time) (int64, error)
+	DeleteAuditLogsUntil(ctx context.Context, until time.Time) (int64, error)
+	CleanupExpiredAgentSessions(ctx context.Context) (int64, error)
+	DeleteUnusedConfigVersions(ctx context.Context, before time.Time, keep int) (int64, error)
+
+	// Export Operations
+	ExportSnapshot(ctx context.Context) (*Snapshot, error)
+	ImportSnapshot(ctx context.Context, snap *Snapshot) (*ImportResult, error)
+}
+
+// sqlStore implements Store on top of database/sql.
+type sqlStore struct {
+	db *sqlDB
+}
+
+// New creates a new Store and applies pending migrations. databaseURL is a
+// postgres:// or postgresql:// URL, or a SQLite path with an optional
+// sqlite:// prefix.
+func New(databaseURL string) (Store, error) {
+	db, err := open(databaseURL)
+	if err != nil {
+		return nil, err
+	}
 
-//go:embed migrations/002_user_sessions.sql
-var userSessionsSchema string
+	// Run migrations
+	if _, err := (&Migrator{db: db}).Up(context.Background(), 0); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
 
-// Store provides database operations for the Hub.
-type Store struct {
-	db *sql.DB
+	return &sqlStore{db: db}, nil
 }
 
-// New creates a new Store instance and initializes the database.
-func New(databaseURL string) (*Store, error) {
-	// Parse database URL (sqlite://path or just path)
-	dbPath := strings.TrimPrefix(databaseURL, "sqlite://")
+// open connects to the database without touching its schema.
+func open(databaseURL string) (*sqlDB, error) {
+	d, dsn := parseDatabaseURL(databaseURL)
 
-	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on&_journal_mode=WAL")
+	db, err := sql.Open(d.driverName, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	// Set connection pool settings
-	db.SetMaxOpenConns(1) // SQLite only supports one writer
-	db.SetMaxIdleConns(1)
-	db.SetConnMaxLifetime(time.Hour)
+	d.configure(db)
 
 	// Test connection
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	store := &Store{db: db}
-
-	// Run migrations
-	if err := store.migrate(); err != nil {
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
-	}
-
-	return store, nil
+	return &sqlDB{DB: db, dialect: d}, nil
 }
 
 // Close closes the database connection.
-func (s *Store) Close() error {
+func (s *sqlStore) Close() error {
 	return s.db.Close()
 }
 
-// migrate runs database migrations.
-func (s *Store) migrate() error {
-	// Run all migrations in order
-	migrations := []struct {
-		name string
-		sql  string
-	}{
-		{"001_initial_schema", initialSchema},
-		{"002_user_sessions", userSessionsSchema},
-	}
-
-	for _, m := range migrations {
-		_, err := s.db.Exec(m.sql)
-		if err != nil {
-			return fmt.Errorf("failed to execute migration %s: %w", m.name, err)
-		}
-	}
-
-	return nil
+// Ping checks that the database is reachable.
+func (s *sqlStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
 }
 
 // DB returns the underlying database connection for advanced queries.
-func (s *Store) DB() *sql.DB {
-	return s.db
+func (s *sqlStore) DB() *sql.DB {
+	return s.db.DB
 }
 
 // ============================================
@@ -90,13 +262,18 @@ func (s *Store) DB() *sql.DB {
 // ============================================
 
 // CreateInstance creates a new instance.
-func (s *Store) CreateInstance(ctx context.Context, inst *Instance) error {
+func (s *sqlStore) CreateInstance(ctx context.Context, inst *Instance) error {
 	if inst.ID == "" {
 		inst.ID = uuid.New().String()
 	}
 	inst.CreatedAt = time.Now().UTC()
 	inst.UpdatedAt = inst.CreatedAt
 
+	return insertInstance(ctx, s.db, inst)
+}
+
+// insertInstance writes an instance row as is.
+func insertInstance(ctx context.Context, db execer, inst *Instance) error {
 	labelsJSON, err := json.Marshal(inst.Labels)
 	if err != nil {
 		return fmt.Errorf("failed to marshal labels: %w", err)
@@ -107,16 +284,18 @@ func (s *Store) CreateInstance(ctx context.Context, inst *Instance) error {
 		return fmt.Errorf("failed to marshal capabilities: %w", err)
 	}
 
-	_, err = s.db.ExecContext(ctx, `
+	_, err = db.ExecContext(ctx, `
 		INSERT INTO instances (
 			id, name, hostname, agent_version, sentinel_version,
 			status, last_seen_at, current_config_id, current_config_version,
-			labels, capabilities, created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			labels, capabilities, auto_follow, reported_config_hash, delivered_config_hash,
+			drifted_at, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		inst.ID, inst.Name, inst.Hostname, inst.AgentVersion, inst.SentinelVersion,
 		inst.Status, NullTime(inst.LastSeenAt), NullString(inst.CurrentConfigID), NullInt(inst.CurrentConfigVersion),
-		string(labelsJSON), string(capsJSON), inst.CreatedAt, inst.UpdatedAt,
+		string(labelsJSON), string(capsJSON), inst.AutoFollow, NullString(inst.ReportedConfigHash), NullString(inst.DeliveredConfigHash),
+		NullTime(inst.DriftedAt), inst.CreatedAt, inst.UpdatedAt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert instance: %w", err)
@@ -126,22 +305,26 @@ func (s *Store) CreateInstance(ctx context.Context, inst *Instance) error {
 }
 
 // GetInstance retrieves an instance by ID.
-func (s *Store) GetInstance(ctx context.Context, id string) (*Instance, error) {
+func (s *sqlStore) GetInstance(ctx context.Context, id string) (*Instance, error) {
 	var inst Instance
 	var labelsJSON, capsJSON string
 	var lastSeenAt sql.NullTime
 	var configID sql.NullString
 	var configVersion sql.NullInt64
+	var reportedHash, deliveredHash sql.NullString
+	var driftedAt sql.NullTime
 
 	err := s.db.QueryRowContext(ctx, `
 		SELECT id, name, hostname, agent_version, sentinel_version,
 			   status, last_seen_at, current_config_id, current_config_version,
-			   labels, capabilities, created_at, updated_at
+			   labels, capabilities, auto_follow, reported_config_hash, delivered_config_hash,
+			   drifted_at, created_at, updated_at
 		FROM instances WHERE id = ?
 	`, id).Scan(
 		&inst.ID, &inst.Name, &inst.Hostname, &inst.AgentVersion, &inst.SentinelVersion,
 		&inst.Status, &lastSeenAt, &configID, &configVersion,
-		&labelsJSON, &capsJSON, &inst.CreatedAt, &inst.UpdatedAt,
+		&labelsJSON, &capsJSON, &inst.AutoFollow, &reportedHash, &deliveredHash,
+		&driftedAt, &inst.CreatedAt, &inst.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -153,6 +336,9 @@ func (s *Store) GetInstance(ctx context.Context, id string) (*Instance, error) {
 	inst.LastSeenAt = TimePtr(lastSeenAt)
 	inst.CurrentConfigID = StringPtr(configID)
 	inst.CurrentConfigVersion = IntPtr(configVersion)
+	inst.ReportedConfigHash = StringPtr(reportedHash)
+	inst.DeliveredConfigHash = StringPtr(deliveredHash)
+	inst.DriftedAt = TimePtr(driftedAt)
 
 	if labelsJSON != "" {
 		if err := json.Unmarshal([]byte(labelsJSON), &inst.Labels); err != nil {
@@ -169,7 +355,7 @@ func (s *Store) GetInstance(ctx context.Context, id string) (*Instance, error) {
 }
 
 // GetInstanceByName retrieves an instance by name.
-func (s *Store) GetInstanceByName(ctx context.Context, name string) (*Instance, error) {
+func (s *sqlStore) GetInstanceByName(ctx context.Context, name string) (*Instance, error) {
 	var id string
 	err := s.db.QueryRowContext(ctx, `SELECT id FROM instances WHERE name = ?`, name).Scan(&id)
 	if err == sql.ErrNoRows {
@@ -182,11 +368,12 @@ func (s *Store) GetInstanceByName(ctx context.Context, name string) (*Instance,
 }
 
 // ListInstances retrieves all instances with optional filtering.
-func (s *Store) ListInstances(ctx context.Context, opts ListInstancesOptions) ([]Instance, error) {
+func (s *sqlStore) ListInstances(ctx context.Context, opts ListInstancesOptions) ([]Instance, error) {
 	query := `
 		SELECT id, name, hostname, agent_version, sentinel_version,
 			   status, last_seen_at, current_config_id, current_config_version,
-			   labels, capabilities, created_at, updated_at
+			   labels, capabilities, auto_follow, reported_config_hash, delivered_config_hash,
+			   drifted_at, created_at, updated_at
 		FROM instances
 		WHERE 1=1
 	`
@@ -196,6 +383,17 @@ func (s *Store) ListInstances(ctx context.Context, opts ListInstancesOptions) ([
 		query += " AND status = ?"
 		args = append(args, opts.Status)
 	}
+	if opts.ConfigID != "" {
+		query += " AND current_config_id = ?"
+		args = append(args, opts.ConfigID)
+	}
+	if opts.Drifted != nil {
+		if *opts.Drifted {
+			query += " AND drifted_at IS NOT NULL"
+		} else {
+			query += " AND drifted_at IS NULL"
+		}
+	}
 
 	query += " ORDER BY name ASC"
 
@@ -219,11 +417,14 @@ func (s *Store) ListInstances(ctx context.Context, opts ListInstancesOptions) ([
 		var lastSeenAt sql.NullTime
 		var configID sql.NullString
 		var configVersion sql.NullInt64
+		var reportedHash, deliveredHash sql.NullString
+		var driftedAt sql.NullTime
 
 		err := rows.Scan(
 			&inst.ID, &inst.Name, &inst.Hostname, &inst.AgentVersion, &inst.SentinelVersion,
 			&inst.Status, &lastSeenAt, &configID, &configVersion,
-			&labelsJSON, &capsJSON, &inst.CreatedAt, &inst.UpdatedAt,
+			&labelsJSON, &capsJSON, &inst.AutoFollow, &reportedHash, &deliveredHash,
+			&driftedAt, &inst.CreatedAt, &inst.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan instance: %w", err)
@@ -232,6 +433,9 @@ func (s *Store) ListInstances(ctx context.Context, opts ListInstancesOptions) ([
 		inst.LastSeenAt = TimePtr(lastSeenAt)
 		inst.CurrentConfigID = StringPtr(configID)
 		inst.CurrentConfigVersion = IntPtr(configVersion)
+		inst.ReportedConfigHash = StringPtr(reportedHash)
+		inst.DeliveredConfigHash = StringPtr(deliveredHash)
+		inst.DriftedAt = TimePtr(driftedAt)
 
 		if labelsJSON != "" {
 			json.Unmarshal([]byte(labelsJSON), &inst.Labels)
@@ -248,13 +452,15 @@ func (s *Store) ListInstances(ctx context.Context, opts ListInstancesOptions) ([
 
 // ListInstancesOptions provides filtering options for ListInstances.
 type ListInstancesOptions struct {
-	Status InstanceStatus
-	Limit  int
-	Offset int
+	Status   InstanceStatus
+	ConfigID string // Instances currently running this config
+	Drifted  *bool  // Instances running, or not running, local changes
+	Limit    int
+	Offset   int
 }
 
 // UpdateInstance updates an existing instance.
-func (s *Store) UpdateInstance(ctx context.Context, inst *Instance) error {
+func (s *sqlStore) UpdateInstance(ctx context.Context, inst *Instance) error {
 	inst.UpdatedAt = time.Now().UTC()
 
 	labelsJSON, err := json.Marshal(inst.Labels)
@@ -271,12 +477,14 @@ func (s *Store) UpdateInstance(ctx context.Context, inst *Instance) error {
 		UPDATE instances SET
 			name = ?, hostname = ?, agent_version = ?, sentinel_version = ?,
 			status = ?, last_seen_at = ?, current_config_id = ?, current_config_version = ?,
-			labels = ?, capabilities = ?, updated_at = ?
+			labels = ?, capabilities = ?, auto_follow = ?, reported_config_hash = ?,
+			delivered_config_hash = ?, drifted_at = ?, updated_at = ?
 		WHERE id = ?
 	`,
 		inst.Name, inst.Hostname, inst.AgentVersion, inst.SentinelVersion,
 		inst.Status, NullTime(inst.LastSeenAt), NullString(inst.CurrentConfigID), NullInt(inst.CurrentConfigVersion),
-		string(labelsJSON), string(capsJSON), inst.UpdatedAt, inst.ID,
+		string(labelsJSON), string(capsJSON), inst.AutoFollow, NullString(inst.ReportedConfigHash),
+		NullString(inst.DeliveredConfigHash), NullTime(inst.DriftedAt), inst.UpdatedAt, inst.ID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update instance: %w", err)
@@ -294,7 +502,7 @@ func (s *Store) UpdateInstance(ctx context.Context, inst *Instance) error {
 }
 
 // DeleteInstance deletes an instance by ID.
-func (s *Store) DeleteInstance(ctx context.Context, id string) error {
+func (s *sqlStore) DeleteInstance(ctx context.Context, id string) error {
 	result, err := s.db.ExecContext(ctx, `DELETE FROM instances WHERE id = ?`, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete instance: %w", err)
@@ -312,7 +520,7 @@ func (s *Store) DeleteInstance(ctx context.Context, id string) error {
 }
 
 // UpdateInstanceStatus updates just the status and last_seen_at fields.
-func (s *Store) UpdateInstanceStatus(ctx context.Context, id string, status InstanceStatus) error {
+func (s *sqlStore) UpdateInstanceStatus(ctx context.Context, id string, status InstanceStatus) error {
 	now := time.Now().UTC()
 	_, err := s.db.ExecContext(ctx, `
 		UPDATE instances SET status = ?, last_seen_at = ?, updated_at = ? WHERE id = ?
@@ -323,12 +531,91 @@ func (s *Store) UpdateInstanceStatus(ctx context.Context, id string, status Inst
 	return nil
 }
 
+// UpdateInstanceHeartbeat records a heartbeat: the status, last_seen_at and,
+// when reportedConfigHash is set, the config hash the instance reported. The
+// config the instance should run is left alone.
+func (s *sqlStore) UpdateInstanceHeartbeat(ctx context.Context, id string, status InstanceStatus, reportedConfigHash *string) error {
+	now := time.Now().UTC()
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE instances SET
+			status = ?, last_seen_at = ?, reported_config_hash = COALESCE(?, reported_config_hash), updated_at = ?
+		WHERE id = ?
+	`, status, now, NullString(reportedConfigHash), now, id)
+	if err != nil {
+		return fmt.Errorf("failed to update instance heartbeat: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("instance not found")
+	}
+
+	return nil
+}
+
+// AdvanceInstanceConfigVersion moves an instance of configID from version
+// from (nil for none) to version to, reporting whether it did. Nothing is
+// changed if the instance's config or version has changed since it was read.
+func (s *sqlStore) AdvanceInstanceConfigVersion(ctx context.Context, id, configID string, from *int, to int) (bool, error) {
+	query := `
+		UPDATE instances SET current_config_version = ?, updated_at = ?
+		WHERE id = ? AND current_config_id = ? AND current_config_version = ?
+	`
+	args := []interface{}{to, time.Now().UTC(), id, configID}
+	if from == nil {
+		query = `
+		UPDATE instances SET current_config_version = ?, updated_at = ?
+		WHERE id = ? AND current_config_id = ? AND current_config_version IS NULL
+	`
+	} else {
+		args = append(args, *from)
+	}
+
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to advance instance config version: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// SetInstanceDeliveredHash records the hash of the content last sent to an
+// instance.
+func (s *sqlStore) SetInstanceDeliveredHash(ctx context.Context, id, hash string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE instances SET delivered_config_hash = ?, updated_at = ? WHERE id = ?
+	`, hash, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to set delivered config hash: %w", err)
+	}
+	return nil
+}
+
+// SetInstanceDriftedAt records when an instance started running local
+// changes to its config, or clears it when driftedAt is nil.
+func (s *sqlStore) SetInstanceDriftedAt(ctx context.Context, id string, driftedAt *time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE instances SET drifted_at = ?, updated_at = ? WHERE id = ?
+	`, NullTime(driftedAt), time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to set instance drift: %w", err)
+	}
+	return nil
+}
+
 // ============================================
 // Config Operations
 // ============================================
 
 // CreateConfig creates a new configuration.
-func (s *Store) CreateConfig(ctx context.Context, cfg *Config) error {
+func (s *sqlStore) CreateConfig(ctx context.Context, cfg *Config) error {
 	if cfg.ID == "" {
 		cfg.ID = uuid.New().String()
 	}
@@ -336,12 +623,17 @@ func (s *Store) CreateConfig(ctx context.Context, cfg *Config) error {
 	cfg.CreatedAt = time.Now().UTC()
 	cfg.UpdatedAt = cfg.CreatedAt
 
-	_, err := s.db.ExecContext(ctx, `
-		INSERT INTO configs (id, name, description, current_version, created_by, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+	return insertConfig(ctx, s.db, cfg)
+}
+
+// insertConfig writes a config row as is.
+func insertConfig(ctx context.Context, db execer, cfg *Config) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO configs (id, name, description, current_version, auto_follow, created_by, created_at, updated_at, deleted_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
-		cfg.ID, cfg.Name, NullString(cfg.Description), cfg.CurrentVersion,
-		NullString(cfg.CreatedBy), cfg.CreatedAt, cfg.UpdatedAt,
+		cfg.ID, cfg.Name, NullString(cfg.Description), cfg.CurrentVersion, cfg.AutoFollow,
+		NullString(cfg.CreatedBy), cfg.CreatedAt, cfg.UpdatedAt, NullTime(cfg.DeletedAt),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert config: %w", err)
@@ -351,16 +643,16 @@ func (s *Store) CreateConfig(ctx context.Context, cfg *Config) error {
 }
 
 // GetConfig retrieves a configuration by ID.
-func (s *Store) GetConfig(ctx context.Context, id string) (*Config, error) {
+func (s *sqlStore) GetConfig(ctx context.Context, id string) (*Config, error) {
 	var cfg Config
 	var description, createdBy sql.NullString
 	var deletedAt sql.NullTime
 
 	err := s.db.QueryRowContext(ctx, `
-		SELECT id, name, description, current_version, created_by, created_at, updated_at, deleted_at
+		SELECT id, name, description, current_version, auto_follow, created_by, created_at, updated_at, deleted_at
 		FROM configs WHERE id = ? AND deleted_at IS NULL
 	`, id).Scan(
-		&cfg.ID, &cfg.Name, &description, &cfg.CurrentVersion,
+		&cfg.ID, &cfg.Name, &description, &cfg.CurrentVersion, &cfg.AutoFollow,
 		&createdBy, &cfg.CreatedAt, &cfg.UpdatedAt, &deletedAt,
 	)
 	if err == sql.ErrNoRows {
@@ -378,13 +670,15 @@ func (s *Store) GetConfig(ctx context.Context, id string) (*Config, error) {
 }
 
 // ListConfigs retrieves all configurations.
-func (s *Store) ListConfigs(ctx context.Context, opts ListConfigsOptions) ([]Config, error) {
+func (s *sqlStore) ListConfigs(ctx context.Context, opts ListConfigsOptions) ([]Config, error) {
 	query := `
-		SELECT id, name, description, current_version, created_by, created_at, updated_at, deleted_at
+		SELECT id, name, description, current_version, auto_follow, created_by, created_at, updated_at, deleted_at
 		FROM configs
-		WHERE deleted_at IS NULL
-		ORDER BY name ASC
 	`
+	if !opts.IncludeDeleted {
+		query += " WHERE deleted_at IS NULL"
+	}
+	query += " ORDER BY name ASC"
 
 	if opts.Limit > 0 {
 		query += fmt.Sprintf(" LIMIT %d", opts.Limit)
@@ -406,7 +700,7 @@ func (s *Store) ListConfigs(ctx context.Context, opts ListConfigsOptions) ([]Con
 		var deletedAt sql.NullTime
 
 		err := rows.Scan(
-			&cfg.ID, &cfg.Name, &description, &cfg.CurrentVersion,
+			&cfg.ID, &cfg.Name, &description, &cfg.CurrentVersion, &cfg.AutoFollow,
 			&createdBy, &cfg.CreatedAt, &cfg.UpdatedAt, &deletedAt,
 		)
 		if err != nil {
@@ -425,20 +719,21 @@ func (s *Store) ListConfigs(ctx context.Context, opts ListConfigsOptions) ([]Con
 
 // ListConfigsOptions provides filtering options for ListConfigs.
 type ListConfigsOptions struct {
-	Limit  int
-	Offset int
+	IncludeDeleted bool
+	Limit          int
+	Offset         int
 }
 
 // UpdateConfig updates a configuration (creates a new version).
-func (s *Store) UpdateConfig(ctx context.Context, cfg *Config) error {
+func (s *sqlStore) UpdateConfig(ctx context.Context, cfg *Config) error {
 	cfg.UpdatedAt = time.Now().UTC()
 
 	result, err := s.db.ExecContext(ctx, `
 		UPDATE configs SET
-			name = ?, description = ?, current_version = ?, updated_at = ?
+			name = ?, description = ?, current_version = ?, auto_follow = ?, updated_at = ?
 		WHERE id = ? AND deleted_at IS NULL
 	`,
-		cfg.Name, NullString(cfg.Description), cfg.CurrentVersion, cfg.UpdatedAt, cfg.ID,
+		cfg.Name, NullString(cfg.Description), cfg.CurrentVersion, cfg.AutoFollow, cfg.UpdatedAt, cfg.ID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update config: %w", err)
@@ -456,7 +751,7 @@ func (s *Store) UpdateConfig(ctx context.Context, cfg *Config) error {
 }
 
 // DeleteConfig soft-deletes a configuration.
-func (s *Store) DeleteConfig(ctx context.Context, id string) error {
+func (s *sqlStore) DeleteConfig(ctx context.Context, id string) error {
 	now := time.Now().UTC()
 	result, err := s.db.ExecContext(ctx, `
 		UPDATE configs SET deleted_at = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL
@@ -481,18 +776,32 @@ func (s *Store) DeleteConfig(ctx context.Context, id string) error {
 // ============================================
 
 // CreateConfigVersion creates a new configuration version.
-func (s *Store) CreateConfigVersion(ctx context.Context, ver *ConfigVersion) error {
+func (s *sqlStore) CreateConfigVersion(ctx context.Context, ver *ConfigVersion) error {
 	if ver.ID == "" {
 		ver.ID = uuid.New().String()
 	}
 	ver.CreatedAt = time.Now().UTC()
 
-	_, err := s.db.ExecContext(ctx, `
-		INSERT INTO config_versions (id, config_id, version, content, content_hash, change_summary, created_by, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	return insertConfigVersion(ctx, s.db, ver)
+}
+
+// insertConfigVersion writes a config version row as is.
+func insertConfigVersion(ctx context.Context, db execer, ver *ConfigVersion) error {
+	var layers sql.NullString
+	if ver.Layers != nil {
+		data, err := json.Marshal(ver.Layers)
+		if err != nil {
+			return fmt.Errorf("failed to marshal layers: %w", err)
+		}
+		layers = sql.NullString{String: string(data), Valid: true}
+	}
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO config_versions (id, config_id, version, content, content_hash, is_template, layers, change_summary, created_by, created_at, approved_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
-		ver.ID, ver.ConfigID, ver.Version, ver.Content, ver.ContentHash,
-		NullString(ver.ChangeSummary), NullString(ver.CreatedBy), ver.CreatedAt,
+		ver.ID, ver.ConfigID, ver.Version, ver.Content, ver.ContentHash, ver.IsTemplate, layers,
+		NullString(ver.ChangeSummary), NullString(ver.CreatedBy), ver.CreatedAt, NullTime(ver.ApprovedAt),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert config version: %w", err)
@@ -501,43 +810,59 @@ func (s *Store) CreateConfigVersion(ctx context.Context, ver *ConfigVersion) err
 	return nil
 }
 
-// GetConfigVersion retrieves a specific configuration version.
-func (s *Store) GetConfigVersion(ctx context.Context, configID string, version int) (*ConfigVersion, error) {
+// configVersionColumns are the columns read by scanConfigVersion.
+const configVersionColumns = `id, config_id, version, content, content_hash, is_template, layers, change_summary, created_by, created_at, approved_at`
+
+// scanConfigVersion scans a single config_versions row.
+func scanConfigVersion(scan func(dest ...interface{}) error) (*ConfigVersion, error) {
 	var ver ConfigVersion
-	var changeSummary, createdBy sql.NullString
+	var layers, changeSummary, createdBy sql.NullString
+	var approvedAt sql.NullTime
 
-	err := s.db.QueryRowContext(ctx, `
-		SELECT id, config_id, version, content, content_hash, change_summary, created_by, created_at
-		FROM config_versions WHERE config_id = ? AND version = ?
-	`, configID, version).Scan(
-		&ver.ID, &ver.ConfigID, &ver.Version, &ver.Content, &ver.ContentHash,
-		&changeSummary, &createdBy, &ver.CreatedAt,
+	err := scan(
+		&ver.ID, &ver.ConfigID, &ver.Version, &ver.Content, &ver.ContentHash, &ver.IsTemplate, &layers,
+		&changeSummary, &createdBy, &ver.CreatedAt, &approvedAt,
 	)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get config version: %w", err)
+		return nil, err
 	}
 
+	if layers.Valid {
+		if err := json.Unmarshal([]byte(layers.String), &ver.Layers); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal layers: %w", err)
+		}
+	}
 	ver.ChangeSummary = StringPtr(changeSummary)
 	ver.CreatedBy = StringPtr(createdBy)
+	ver.ApprovedAt = TimePtr(approvedAt)
 
 	return &ver, nil
 }
 
-// GetLatestConfigVersion retrieves the latest version of a configuration.
-func (s *Store) GetLatestConfigVersion(ctx context.Context, configID string) (*ConfigVersion, error) {
-	var ver ConfigVersion
-	var changeSummary, createdBy sql.NullString
+// GetConfigVersion retrieves a specific configuration version.
+func (s *sqlStore) GetConfigVersion(ctx context.Context, configID string, version int) (*ConfigVersion, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT `+configVersionColumns+`
+		FROM config_versions WHERE config_id = ? AND version = ?
+	`, configID, version)
+	ver, err := scanConfigVersion(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config version: %w", err)
+	}
 
-	err := s.db.QueryRowContext(ctx, `
-		SELECT id, config_id, version, content, content_hash, change_summary, created_by, created_at
+	return ver, nil
+}
+
+// GetLatestConfigVersion retrieves the latest version of a configuration.
+func (s *sqlStore) GetLatestConfigVersion(ctx context.Context, configID string) (*ConfigVersion, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT `+configVersionColumns+`
 		FROM config_versions WHERE config_id = ? ORDER BY version DESC LIMIT 1
-	`, configID).Scan(
-		&ver.ID, &ver.ConfigID, &ver.Version, &ver.Content, &ver.ContentHash,
-		&changeSummary, &createdBy, &ver.CreatedAt,
-	)
+	`, configID)
+	ver, err := scanConfigVersion(row.Scan)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -545,16 +870,13 @@ func (s *Store) GetLatestConfigVersion(ctx context.Context, configID string) (*C
 		return nil, fmt.Errorf("failed to get latest config version: %w", err)
 	}
 
-	ver.ChangeSummary = StringPtr(changeSummary)
-	ver.CreatedBy = StringPtr(createdBy)
-
-	return &ver, nil
+	return ver, nil
 }
 
 // ListConfigVersions retrieves all versions of a configuration.
-func (s *Store) ListConfigVersions(ctx context.Context, configID string) ([]ConfigVersion, error) {
+func (s *sqlStore) ListConfigVersions(ctx context.Context, configID string) ([]ConfigVersion, error) {
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, config_id, version, content, content_hash, change_summary, created_by, created_at
+		SELECT `+configVersionColumns+`
 		FROM config_versions WHERE config_id = ? ORDER BY version DESC
 	`, configID)
 	if err != nil {
@@ -564,21 +886,11 @@ func (s *Store) ListConfigVersions(ctx context.Context, configID string) ([]Conf
 
 	var versions []ConfigVersion
 	for rows.Next() {
-		var ver ConfigVersion
-		var changeSummary, createdBy sql.NullString
-
-		err := rows.Scan(
-			&ver.ID, &ver.ConfigID, &ver.Version, &ver.Content, &ver.ContentHash,
-			&changeSummary, &createdBy, &ver.CreatedAt,
-		)
+		ver, err := scanConfigVersion(rows.Scan)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan config version: %w", err)
 		}
-
-		ver.ChangeSummary = StringPtr(changeSummary)
-		ver.CreatedBy = StringPtr(createdBy)
-
-		versions = append(versions, ver)
+		versions = append(versions, *ver)
 	}
 
 	return versions, rows.Err()
@@ -589,12 +901,17 @@ func (s *Store) ListConfigVersions(ctx context.Context, configID string) ([]Conf
 // ============================================
 
 // CreateDeployment creates a new deployment.
-func (s *Store) CreateDeployment(ctx context.Context, dep *Deployment) error {
+func (s *sqlStore) CreateDeployment(ctx context.Context, dep *Deployment) error {
 	if dep.ID == "" {
 		dep.ID = uuid.New().String()
 	}
 	dep.CreatedAt = time.Now().UTC()
 
+	return insertDeployment(ctx, s.db, dep)
+}
+
+// insertDeployment writes a deployment row as is.
+func insertDeployment(ctx context.Context, db execer, dep *Deployment) error {
 	targetsJSON, err := json.Marshal(dep.TargetInstances)
 	if err != nil {
 		return fmt.Errorf("failed to marshal target instances: %w", err)
@@ -608,7 +925,7 @@ func (s *Store) CreateDeployment(ctx context.Context, dep *Deployment) error {
 		}
 	}
 
-	_, err = s.db.ExecContext(ctx, `
+	_, err = db.ExecContext(ctx, `
 		INSERT INTO deployments (
 			id, config_id, config_version, target_instances, strategy, batch_size,
 			status, progress, started_at, completed_at, created_by, created_at
@@ -626,7 +943,7 @@ func (s *Store) CreateDeployment(ctx context.Context, dep *Deployment) error {
 }
 
 // GetDeployment retrieves a deployment by ID.
-func (s *Store) GetDeployment(ctx context.Context, id string) (*Deployment, error) {
+func (s *sqlStore) GetDeployment(ctx context.Context, id string) (*Deployment, error) {
 	var dep Deployment
 	var targetsJSON, progressJSON string
 	var startedAt, completedAt sql.NullTime
@@ -667,7 +984,7 @@ func (s *Store) GetDeployment(ctx context.Context, id string) (*Deployment, erro
 }
 
 // ListDeployments retrieves all deployments.
-func (s *Store) ListDeployments(ctx context.Context, opts ListDeploymentsOptions) ([]Deployment, error) {
+func (s *sqlStore) ListDeployments(ctx context.Context, opts ListDeploymentsOptions) ([]Deployment, error) {
 	query := `
 		SELECT id, config_id, config_version, target_instances, strategy, batch_size,
 			   status, progress, started_at, completed_at, created_by, created_at
@@ -737,7 +1054,7 @@ type ListDeploymentsOptions struct {
 }
 
 // UpdateDeployment updates a deployment.
-func (s *Store) UpdateDeployment(ctx context.Context, dep *Deployment) error {
+func (s *sqlStore) UpdateDeployment(ctx context.Context, dep *Deployment) error {
 	var progressJSON []byte
 	var err error
 	if dep.Progress != nil {
@@ -774,12 +1091,17 @@ func (s *Store) UpdateDeployment(ctx context.Context, dep *Deployment) error {
 // ============================================
 
 // CreateDeploymentInstance creates a new deployment instance record.
-func (s *Store) CreateDeploymentInstance(ctx context.Context, di *DeploymentInstance) error {
+func (s *sqlStore) CreateDeploymentInstance(ctx context.Context, di *DeploymentInstance) error {
 	if di.ID == "" {
 		di.ID = uuid.New().String()
 	}
 
-	_, err := s.db.ExecContext(ctx, `
+	return insertDeploymentInstance(ctx, s.db, di)
+}
+
+// insertDeploymentInstance writes a deployment instance row as is.
+func insertDeploymentInstance(ctx context.Context, db execer, di *DeploymentInstance) error {
+	_, err := db.ExecContext(ctx, `
 		INSERT INTO deployment_instances (id, deployment_id, instance_id, status, started_at, completed_at, last_status_at, error_message)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`,
@@ -794,7 +1116,7 @@ func (s *Store) CreateDeploymentInstance(ctx context.Context, di *DeploymentInst
 }
 
 // GetDeploymentInstance retrieves a deployment instance by deployment and instance ID.
-func (s *Store) GetDeploymentInstance(ctx context.Context, deploymentID, instanceID string) (*DeploymentInstance, error) {
+func (s *sqlStore) GetDeploymentInstance(ctx context.Context, deploymentID, instanceID string) (*DeploymentInstance, error) {
 	row := s.db.QueryRowContext(ctx, `
 		SELECT id, deployment_id, instance_id, status, started_at, completed_at, last_status_at, error_message
 		FROM deployment_instances
@@ -825,7 +1147,7 @@ func (s *Store) GetDeploymentInstance(ctx context.Context, deploymentID, instanc
 }
 
 // ListDeploymentInstances retrieves all instances for a deployment.
-func (s *Store) ListDeploymentInstances(ctx context.Context, deploymentID string) ([]*DeploymentInstance, error) {
+func (s *sqlStore) ListDeploymentInstances(ctx context.Context, deploymentID string) ([]*DeploymentInstance, error) {
 	rows, err := s.db.QueryContext(ctx, `
 		SELECT id, deployment_id, instance_id, status, started_at, completed_at, last_status_at, error_message
 		FROM deployment_instances
@@ -863,7 +1185,7 @@ func (s *Store) ListDeploymentInstances(ctx context.Context, deploymentID string
 }
 
 // UpdateDeploymentInstance updates a deployment instance record.
-func (s *Store) UpdateDeploymentInstance(ctx context.Context, di *DeploymentInstance) error {
+func (s *sqlStore) UpdateDeploymentInstance(ctx context.Context, di *DeploymentInstance) error {
 	result, err := s.db.ExecContext(ctx, `
 		UPDATE deployment_instances SET
 			status = ?, started_at = ?, completed_at = ?, last_status_at = ?, error_message = ?
@@ -888,7 +1210,7 @@ func (s *Store) UpdateDeploymentInstance(ctx context.Context, di *DeploymentInst
 }
 
 // UpsertDeploymentInstance creates or updates a deployment instance record.
-func (s *Store) UpsertDeploymentInstance(ctx context.Context, di *DeploymentInstance) error {
+func (s *sqlStore) UpsertDeploymentInstance(ctx context.Context, di *DeploymentInstance) error {
 	if di.ID == "" {
 		di.ID = uuid.New().String()
 	}
@@ -914,7 +1236,7 @@ func (s *Store) UpsertDeploymentInstance(ctx context.Context, di *DeploymentInst
 }
 
 // DeleteDeploymentInstances deletes all instance records for a deployment.
-func (s *Store) DeleteDeploymentInstances(ctx context.Context, deploymentID string) error {
+func (s *sqlStore) DeleteDeploymentInstances(ctx context.Context, deploymentID string) error {
 	_, err := s.db.ExecContext(ctx, `
 		DELETE FROM deployment_instances WHERE deployment_id = ?
 	`, deploymentID)
@@ -930,7 +1252,7 @@ func (s *Store) DeleteDeploymentInstances(ctx context.Context, deploymentID stri
 // ============================================
 
 // CreateUser creates a new user.
-func (s *Store) CreateUser(ctx context.Context, user *User) error {
+func (s *sqlStore) CreateUser(ctx context.Context, user *User) error {
 	if user.ID == "" {
 		user.ID = uuid.New().String()
 	}
@@ -945,7 +1267,7 @@ func (s *Store) CreateUser(ctx context.Context, user *User) error {
 		user.CreatedAt, NullTime(user.LastLoginAt),
 	)
 	if err != nil {
-		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		if isUniqueViolation(err) {
 			return fmt.Errorf("user with this email already exists")
 		}
 		return fmt.Errorf("failed to create user: %w", err)
@@ -955,7 +1277,7 @@ func (s *Store) CreateUser(ctx context.Context, user *User) error {
 }
 
 // GetUser retrieves a user by ID.
-func (s *Store) GetUser(ctx context.Context, id string) (*User, error) {
+func (s *sqlStore) GetUser(ctx context.Context, id string) (*User, error) {
 	var user User
 	var passwordHash, oidcSubject sql.NullString
 	var lastLoginAt sql.NullTime
@@ -982,7 +1304,7 @@ func (s *Store) GetUser(ctx context.Context, id string) (*User, error) {
 }
 
 // GetUserByEmail retrieves a user by email.
-func (s *Store) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+func (s *sqlStore) GetUserByEmail(ctx context.Context, email string) (*User, error) {
 	var user User
 	var passwordHash, oidcSubject sql.NullString
 	var lastLoginAt sql.NullTime
@@ -1008,8 +1330,35 @@ func (s *Store) GetUserByEmail(ctx context.Context, email string) (*User, error)
 	return &user, nil
 }
 
+// GetUserByOIDCSubject retrieves a user by their OIDC subject identifier.
+func (s *sqlStore) GetUserByOIDCSubject(ctx context.Context, subject string) (*User, error) {
+	var user User
+	var passwordHash, oidcSubject sql.NullString
+	var lastLoginAt sql.NullTime
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, email, name, role, password_hash, oidc_subject, created_at, last_login_at
+		FROM users WHERE oidc_subject = ?
+	`, subject).Scan(
+		&user.ID, &user.Email, &user.Name, &user.Role,
+		&passwordHash, &oidcSubject, &user.CreatedAt, &lastLoginAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by oidc subject: %w", err)
+	}
+
+	user.PasswordHash = StringPtr(passwordHash)
+	user.OIDCSubject = StringPtr(oidcSubject)
+	user.LastLoginAt = TimePtr(lastLoginAt)
+
+	return &user, nil
+}
+
 // ListUsers retrieves all users with optional filtering.
-func (s *Store) ListUsers(ctx context.Context, opts ListUsersOptions) ([]User, error) {
+func (s *sqlStore) ListUsers(ctx context.Context, opts ListUsersOptions) ([]User, error) {
 	query := `
 		SELECT id, email, name, role, password_hash, oidc_subject, created_at, last_login_at
 		FROM users
@@ -1062,7 +1411,7 @@ func (s *Store) ListUsers(ctx context.Context, opts ListUsersOptions) ([]User, e
 }
 
 // UpdateUser updates an existing user.
-func (s *Store) UpdateUser(ctx context.Context, user *User) error {
+func (s *sqlStore) UpdateUser(ctx context.Context, user *User) error {
 	result, err := s.db.ExecContext(ctx, `
 		UPDATE users SET
 			email = ?, name = ?, role = ?, password_hash = ?, oidc_subject = ?
@@ -1073,7 +1422,7 @@ func (s *Store) UpdateUser(ctx context.Context, user *User) error {
 		user.ID,
 	)
 	if err != nil {
-		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		if isUniqueViolation(err) {
 			return fmt.Errorf("user with this email already exists")
 		}
 		return fmt.Errorf("failed to update user: %w", err)
@@ -1091,7 +1440,7 @@ func (s *Store) UpdateUser(ctx context.Context, user *User) error {
 }
 
 // DeleteUser deletes a user by ID.
-func (s *Store) DeleteUser(ctx context.Context, id string) error {
+func (s *sqlStore) DeleteUser(ctx context.Context, id string) error {
 	result, err := s.db.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
@@ -1109,7 +1458,7 @@ func (s *Store) DeleteUser(ctx context.Context, id string) error {
 }
 
 // UpdateUserLastLogin updates the last_login_at timestamp for a user.
-func (s *Store) UpdateUserLastLogin(ctx context.Context, id string) error {
+func (s *sqlStore) UpdateUserLastLogin(ctx context.Context, id string) error {
 	now := time.Now().UTC()
 	_, err := s.db.ExecContext(ctx, `
 		UPDATE users SET last_login_at = ? WHERE id = ?
@@ -1121,7 +1470,7 @@ func (s *Store) UpdateUserLastLogin(ctx context.Context, id string) error {
 }
 
 // CountUsers returns the total number of users.
-func (s *Store) CountUsers(ctx context.Context) (int, error) {
+func (s *sqlStore) CountUsers(ctx context.Context) (int, error) {
 	var count int
 	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&count)
 	if err != nil {
@@ -1135,7 +1484,7 @@ func (s *Store) CountUsers(ctx context.Context) (int, error) {
 // ============================================
 
 // CreateUserSession creates a new user session.
-func (s *Store) CreateUserSession(ctx context.Context, session *UserSession) error {
+func (s *sqlStore) CreateUserSession(ctx context.Context, session *UserSession) error {
 	if session.ID == "" {
 		session.ID = uuid.New().String()
 	}
@@ -1157,35 +1506,137 @@ func (s *Store) CreateUserSession(ctx context.Context, session *UserSession) err
 }
 
 // GetUserSessionByTokenHash retrieves a session by refresh token hash.
-func (s *Store) GetUserSessionByTokenHash(ctx context.Context, tokenHash string) (*UserSession, error) {
+func (s *sqlStore) GetUserSessionByTokenHash(ctx context.Context, tokenHash string) (*UserSession, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+userSessionColumns+` FROM user_sessions WHERE refresh_token_hash = ?`, tokenHash)
+	session, err := scanUserSession(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user session: %w", err)
+	}
+	return session, nil
+}
+
+// userSessionColumns is the column list scanned by scanUserSession.
+const userSessionColumns = `id, user_id, refresh_token_hash, created_at, expires_at, revoked_at, ip_address, user_agent`
+
+// scanUserSession scans a user session row.
+func scanUserSession(scan func(dest ...interface{}) error) (*UserSession, error) {
 	var session UserSession
 	var revokedAt sql.NullTime
 	var ipAddress, userAgent sql.NullString
 
-	err := s.db.QueryRowContext(ctx, `
-		SELECT id, user_id, refresh_token_hash, created_at, expires_at, revoked_at, ip_address, user_agent
-		FROM user_sessions WHERE refresh_token_hash = ?
-	`, tokenHash).Scan(
+	if err := scan(
 		&session.ID, &session.UserID, &session.RefreshTokenHash,
 		&session.CreatedAt, &session.ExpiresAt, &revokedAt,
 		&ipAddress, &userAgent,
-	)
+	); err != nil {
+		return nil, err
+	}
+
+	session.RevokedAt = TimePtr(revokedAt)
+	session.IPAddress = StringPtr(ipAddress)
+	session.UserAgent = StringPtr(userAgent)
+
+	return &session, nil
+}
+
+// GetUserSession retrieves a session by ID.
+func (s *sqlStore) GetUserSession(ctx context.Context, id string) (*UserSession, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+userSessionColumns+` FROM user_sessions WHERE id = ?`, id)
+	session, err := scanUserSession(row.Scan)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user session: %w", err)
 	}
+	return session, nil
+}
 
-	session.RevokedAt = TimePtr(revokedAt)
-	session.IPAddress = StringPtr(ipAddress)
-	session.UserAgent = StringPtr(userAgent)
+// GetUserSessionByPreviousTokenHash retrieves the session a superseded
+// refresh token belonged to.
+func (s *sqlStore) GetUserSessionByPreviousTokenHash(ctx context.Context, tokenHash string) (*UserSession, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT `+userSessionColumns+` FROM user_sessions
+		WHERE id = (SELECT session_id FROM refresh_token_history WHERE token_hash = ?)
+	`, tokenHash)
+	session, err := scanUserSession(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user session: %w", err)
+	}
+	return session, nil
+}
 
-	return &session, nil
+// ListUserSessions lists a user's sessions that are neither revoked nor
+// expired, most recent first.
+func (s *sqlStore) ListUserSessions(ctx context.Context, userID string) ([]UserSession, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+userSessionColumns+` FROM user_sessions
+		WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ?
+		ORDER BY created_at DESC
+	`, userID, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []UserSession
+	for rows.Next() {
+		session, err := scanUserSession(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user session: %w", err)
+		}
+		sessions = append(sessions, *session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// RotateUserSessionToken replaces a session's refresh token and extends its
+// expiry. It fails if oldHash is no longer the session's current token, so
+// only one of two concurrent refreshes can succeed.
+func (s *sqlStore) RotateUserSessionToken(ctx context.Context, id, oldHash, newHash string, expiresAt time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE user_sessions SET refresh_token_hash = ?, expires_at = ?
+		WHERE id = ? AND refresh_token_hash = ? AND revoked_at IS NULL
+	`, newHash, expiresAt, id, oldHash)
+	if err != nil {
+		return fmt.Errorf("failed to rotate session token: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("session not found")
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO refresh_token_history (token_hash, session_id, rotated_at)
+		VALUES (?, ?, ?)
+	`, oldHash, id, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to record rotated token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
 }
 
 // RevokeUserSession marks a session as revoked.
-func (s *Store) RevokeUserSession(ctx context.Context, id string) error {
+func (s *sqlStore) RevokeUserSession(ctx context.Context, id string) error {
 	now := time.Now().UTC()
 	_, err := s.db.ExecContext(ctx, `
 		UPDATE user_sessions SET revoked_at = ? WHERE id = ?
@@ -1197,7 +1648,7 @@ func (s *Store) RevokeUserSession(ctx context.Context, id string) error {
 }
 
 // RevokeAllUserSessions revokes all sessions for a user.
-func (s *Store) RevokeAllUserSessions(ctx context.Context, userID string) error {
+func (s *sqlStore) RevokeAllUserSessions(ctx context.Context, userID string) error {
 	now := time.Now().UTC()
 	_, err := s.db.ExecContext(ctx, `
 		UPDATE user_sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL
@@ -1209,7 +1660,7 @@ func (s *Store) RevokeAllUserSessions(ctx context.Context, userID string) error
 }
 
 // CleanupExpiredSessions deletes sessions that have expired.
-func (s *Store) CleanupExpiredSessions(ctx context.Context) (int64, error) {
+func (s *sqlStore) CleanupExpiredSessions(ctx context.Context) (int64, error) {
 	now := time.Now().UTC()
 	result, err := s.db.ExecContext(ctx, `
 		DELETE FROM user_sessions WHERE expires_at < ?
@@ -1221,59 +1672,973 @@ func (s *Store) CleanupExpiredSessions(ctx context.Context) (int64, error) {
 }
 
 // ============================================
-// Audit Log Operations
+// Password Reset Token Operations
 // ============================================
 
-// CreateAuditLog creates a new audit log entry.
-func (s *Store) CreateAuditLog(ctx context.Context, log *AuditLog) error {
-	if log.ID == "" {
-		log.ID = uuid.New().String()
-	}
-	log.Timestamp = time.Now().UTC()
-
-	var detailsStr string
-	if log.Details != nil {
-		detailsStr = string(log.Details)
+// CreatePasswordResetToken stores a new password reset token.
+func (s *sqlStore) CreatePasswordResetToken(ctx context.Context, token *PasswordResetToken) error {
+	if token.ID == "" {
+		token.ID = uuid.New().String()
 	}
+	token.CreatedAt = time.Now().UTC()
 
 	_, err := s.db.ExecContext(ctx, `
-		INSERT INTO audit_logs (id, timestamp, user_id, action, resource_type, resource_id, details, ip_address)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO password_reset_tokens (id, user_id, token_hash, created_at, expires_at, used_at)
+		VALUES (?, ?, ?, ?, ?, ?)
 	`,
-		log.ID, log.Timestamp, NullString(log.UserID), log.Action,
-		log.ResourceType, NullString(log.ResourceID), detailsStr, NullString(log.IPAddress),
+		token.ID, token.UserID, token.TokenHash,
+		token.CreatedAt, token.ExpiresAt, NullTime(token.UsedAt),
 	)
 	if err != nil {
-		return fmt.Errorf("failed to create audit log: %w", err)
+		return fmt.Errorf("failed to create password reset token: %w", err)
 	}
 
 	return nil
 }
 
-// ListAuditLogs retrieves audit logs with optional filtering.
-func (s *Store) ListAuditLogs(ctx context.Context, opts ListAuditLogsOptions) ([]AuditLog, error) {
-	query := `
-		SELECT id, timestamp, user_id, action, resource_type, resource_id, details, ip_address
-		FROM audit_logs
-		WHERE 1=1
-	`
-	args := []interface{}{}
+// GetPasswordResetTokenByHash retrieves a password reset token by its hash.
+func (s *sqlStore) GetPasswordResetTokenByHash(ctx context.Context, tokenHash string) (*PasswordResetToken, error) {
+	var token PasswordResetToken
+	var usedAt sql.NullTime
 
-	if opts.UserID != "" {
-		query += " AND user_id = ?"
-		args = append(args, opts.UserID)
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, user_id, token_hash, created_at, expires_at, used_at
+		FROM password_reset_tokens WHERE token_hash = ?
+	`, tokenHash).Scan(
+		&token.ID, &token.UserID, &token.TokenHash,
+		&token.CreatedAt, &token.ExpiresAt, &usedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
-	if opts.Action != "" {
-		query += " AND action = ?"
-		args = append(args, opts.Action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get password reset token: %w", err)
 	}
-	if opts.ResourceType != "" {
-		query += " AND resource_type = ?"
-		args = append(args, opts.ResourceType)
+
+	token.UsedAt = TimePtr(usedAt)
+
+	return &token, nil
+}
+
+// UsePasswordResetToken marks a token as used, along with every other
+// outstanding token for the same user. It fails if the token was already used.
+func (s *sqlStore) UsePasswordResetToken(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	if opts.ResourceID != "" {
-		query += " AND resource_id = ?"
-		args = append(args, opts.ResourceID)
+	defer tx.Rollback()
+
+	var userID string
+	err = tx.QueryRowContext(ctx, `
+		SELECT user_id FROM password_reset_tokens WHERE id = ? AND used_at IS NULL
+	`, id).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("password reset token not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get password reset token: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE password_reset_tokens SET used_at = ? WHERE user_id = ? AND used_at IS NULL
+	`, now, userID); err != nil {
+		return fmt.Errorf("failed to use password reset token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// CleanupExpiredPasswordResetTokens deletes password reset tokens that have expired.
+func (s *sqlStore) CleanupExpiredPasswordResetTokens(ctx context.Context) (int64, error) {
+	now := time.Now().UTC()
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM password_reset_tokens WHERE expires_at < ?
+	`, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup expired password reset tokens: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// ============================================
+// MFA Operations
+// ============================================
+
+// UpsertUserMFA stores a pending TOTP enrollment, replacing any previous
+// enrollment for the user.
+func (s *sqlStore) UpsertUserMFA(ctx context.Context, mfa *UserMFA) error {
+	mfa.CreatedAt = time.Now().UTC()
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO user_mfa (user_id, secret, last_used_step, created_at, enabled_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			secret = excluded.secret,
+			last_used_step = excluded.last_used_step,
+			created_at = excluded.created_at,
+			enabled_at = excluded.enabled_at
+	`, mfa.UserID, mfa.Secret, mfa.LastUsedStep, mfa.CreatedAt, NullTime(mfa.EnabledAt))
+	if err != nil {
+		return fmt.Errorf("failed to upsert user mfa: %w", err)
+	}
+	return nil
+}
+
+// GetUserMFA retrieves a user's TOTP enrollment.
+func (s *sqlStore) GetUserMFA(ctx context.Context, userID string) (*UserMFA, error) {
+	var mfa UserMFA
+	var enabledAt sql.NullTime
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT user_id, secret, last_used_step, created_at, enabled_at
+		FROM user_mfa WHERE user_id = ?
+	`, userID).Scan(&mfa.UserID, &mfa.Secret, &mfa.LastUsedStep, &mfa.CreatedAt, &enabledAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user mfa: %w", err)
+	}
+
+	mfa.EnabledAt = TimePtr(enabledAt)
+
+	return &mfa, nil
+}
+
+// UseUserMFAStep records the TOTP time step of an accepted code. It returns
+// false if the step, or a later one, was already used.
+func (s *sqlStore) UseUserMFAStep(ctx context.Context, userID string, step int64) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE user_mfa SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?
+	`, step, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to update user mfa: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// EnableUserMFA activates a pending enrollment and replaces the user's
+// recovery codes.
+func (s *sqlStore) EnableUserMFA(ctx context.Context, userID string, recoveryCodeHashes []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	result, err := tx.ExecContext(ctx, `
+		UPDATE user_mfa SET enabled_at = ? WHERE user_id = ?
+	`, now, userID)
+	if err != nil {
+		return fmt.Errorf("failed to enable user mfa: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("user mfa not found")
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	for _, hash := range recoveryCodeHashes {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO mfa_recovery_codes (id, user_id, code_hash, created_at)
+			VALUES (?, ?, ?, ?)
+		`, uuid.New().String(), userID, hash, now); err != nil {
+			return fmt.Errorf("failed to create recovery code: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used.
+func (s *sqlStore) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	now := time.Now().UTC()
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE mfa_recovery_codes SET used_at = ?
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+	`, now, userID, codeHash)
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("recovery code not found")
+	}
+	return nil
+}
+
+// CountRecoveryCodes returns the number of unused recovery codes for a user.
+func (s *sqlStore) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = ? AND used_at IS NULL
+	`, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteUserMFA removes a user's TOTP enrollment and recovery codes.
+func (s *sqlStore) DeleteUserMFA(ctx context.Context, userID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM user_mfa WHERE user_id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete user mfa: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("user mfa not found")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ============================================
+// Signing Key Operations
+// ============================================
+
+// CreateSigningKey stores a new signing key.
+func (s *sqlStore) CreateSigningKey(ctx context.Context, key *SigningKey) error {
+	if key.ID == "" {
+		key.ID = uuid.New().String()
+	}
+	key.CreatedAt = time.Now().UTC()
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO signing_keys (id, algorithm, private_key, created_at, retired_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, key.ID, key.Algorithm, key.PrivateKey, key.CreatedAt, NullTime(key.RetiredAt), NullTime(key.ExpiresAt))
+	if err != nil {
+		return fmt.Errorf("failed to create signing key: %w", err)
+	}
+
+	return nil
+}
+
+// ListSigningKeys lists all signing keys, oldest first.
+func (s *sqlStore) ListSigningKeys(ctx context.Context) ([]SigningKey, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, algorithm, private_key, created_at, retired_at, expires_at
+		FROM signing_keys ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []SigningKey
+	for rows.Next() {
+		var key SigningKey
+		var retiredAt, expiresAt sql.NullTime
+		if err := rows.Scan(&key.ID, &key.Algorithm, &key.PrivateKey, &key.CreatedAt, &retiredAt, &expiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan signing key: %w", err)
+		}
+		key.RetiredAt = TimePtr(retiredAt)
+		key.ExpiresAt = TimePtr(expiresAt)
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// RetireSigningKey stops a key from signing new tokens. It can still verify
+// tokens until expiresAt.
+func (s *sqlStore) RetireSigningKey(ctx context.Context, id string, expiresAt time.Time) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE signing_keys SET retired_at = ?, expires_at = ?
+		WHERE id = ? AND retired_at IS NULL
+	`, time.Now().UTC(), expiresAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to retire signing key: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("signing key not found")
+	}
+
+	return nil
+}
+
+// DeleteSigningKey removes a signing key.
+func (s *sqlStore) DeleteSigningKey(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM signing_keys WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete signing key: %w", err)
+	}
+	return nil
+}
+
+// ============================================
+// API Token Operations
+// ============================================
+
+// CreateAPIToken creates a new API token.
+func (s *sqlStore) CreateAPIToken(ctx context.Context, token *APIToken) error {
+	if token.ID == "" {
+		token.ID = uuid.New().String()
+	}
+	token.CreatedAt = time.Now().UTC()
+
+	scopesJSON, err := json.Marshal(token.Scopes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal scopes: %w", err)
+	}
+
+	var labelsJSON sql.NullString
+	if len(token.Labels) > 0 {
+		data, err := json.Marshal(token.Labels)
+		if err != nil {
+			return fmt.Errorf("failed to marshal labels: %w", err)
+		}
+		labelsJSON = sql.NullString{String: string(data), Valid: true}
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO api_tokens (
+			id, name, user_id, token_hash, token_prefix, scopes, labels,
+			expires_at, last_used_at, revoked_at, created_by, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		token.ID, token.Name, token.UserID, token.TokenHash, token.TokenPrefix,
+		string(scopesJSON), labelsJSON,
+		NullTime(token.ExpiresAt), NullTime(token.LastUsedAt), NullTime(token.RevokedAt),
+		NullString(token.CreatedBy), token.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create api token: %w", err)
+	}
+
+	return nil
+}
+
+const apiTokenColumns = `
+	id, name, user_id, token_hash, token_prefix, scopes, labels,
+	expires_at, last_used_at, revoked_at, created_by, created_at
+`
+
+// scanAPIToken scans a single api_tokens row.
+func scanAPIToken(scan func(dest ...interface{}) error) (*APIToken, error) {
+	var token APIToken
+	var scopesJSON string
+	var labelsJSON, createdBy sql.NullString
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+
+	err := scan(
+		&token.ID, &token.Name, &token.UserID, &token.TokenHash, &token.TokenPrefix,
+		&scopesJSON, &labelsJSON, &expiresAt, &lastUsedAt, &revokedAt,
+		&createdBy, &token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	token.ExpiresAt = TimePtr(expiresAt)
+	token.LastUsedAt = TimePtr(lastUsedAt)
+	token.RevokedAt = TimePtr(revokedAt)
+	token.CreatedBy = StringPtr(createdBy)
+
+	if err := json.Unmarshal([]byte(scopesJSON), &token.Scopes); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal scopes: %w", err)
+	}
+	if labelsJSON.Valid && labelsJSON.String != "" {
+		if err := json.Unmarshal([]byte(labelsJSON.String), &token.Labels); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal labels: %w", err)
+		}
+	}
+
+	return &token, nil
+}
+
+// GetAPIToken retrieves an API token by ID.
+func (s *sqlStore) GetAPIToken(ctx context.Context, id string) (*APIToken, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+apiTokenColumns+` FROM api_tokens WHERE id = ?`, id)
+	token, err := scanAPIToken(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api token: %w", err)
+	}
+	return token, nil
+}
+
+// GetAPITokenByHash retrieves an API token by its token hash.
+func (s *sqlStore) GetAPITokenByHash(ctx context.Context, tokenHash string) (*APIToken, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+apiTokenColumns+` FROM api_tokens WHERE token_hash = ?`, tokenHash)
+	token, err := scanAPIToken(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api token: %w", err)
+	}
+	return token, nil
+}
+
+// ListAPITokens retrieves API tokens, optionally restricted to one owner.
+func (s *sqlStore) ListAPITokens(ctx context.Context, opts ListAPITokensOptions) ([]APIToken, error) {
+	query := `SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE 1=1`
+	args := []interface{}{}
+
+	if opts.UserID != "" {
+		query += " AND user_id = ?"
+		args = append(args, opts.UserID)
+	}
+	if !opts.IncludeRevoked {
+		query += " AND revoked_at IS NULL"
+	}
+
+	query += " ORDER BY created_at DESC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		token, err := scanAPIToken(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api token: %w", err)
+		}
+		tokens = append(tokens, *token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// ListAPITokensOptions provides filtering options for ListAPITokens.
+type ListAPITokensOptions struct {
+	UserID         string
+	IncludeRevoked bool
+}
+
+// RevokeAPIToken marks an API token as revoked.
+func (s *sqlStore) RevokeAPIToken(ctx context.Context, id string) error {
+	now := time.Now().UTC()
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL
+	`, now, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api token: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("api token not found")
+	}
+
+	return nil
+}
+
+// UpdateAPITokenLastUsed updates the last_used_at timestamp for a token.
+func (s *sqlStore) UpdateAPITokenLastUsed(ctx context.Context, id string, usedAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE api_tokens SET last_used_at = ? WHERE id = ?
+	`, usedAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to update api token last used: %w", err)
+	}
+	return nil
+}
+
+// ============================================
+// Service Account Operations
+// ============================================
+
+// CreateServiceAccount creates a credential-less user and marks it as a
+// service account. The user row is what API tokens and audit entries
+// reference.
+func (s *sqlStore) CreateServiceAccount(ctx context.Context, user *User, sa *ServiceAccount) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if user.ID == "" {
+		user.ID = uuid.New().String()
+	}
+	user.CreatedAt = time.Now().UTC()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO users (id, email, name, role, password_hash, oidc_subject, created_at)
+		VALUES (?, ?, ?, ?, NULL, NULL, ?)
+	`, user.ID, user.Email, user.Name, user.Role, user.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("service account with this name already exists")
+		}
+		return fmt.Errorf("failed to insert user: %w", err)
+	}
+
+	sa.UserID = user.ID
+	sa.Name = user.Name
+	sa.Role = user.Role
+	sa.CreatedAt = user.CreatedAt
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO service_accounts (user_id, description, created_by, created_at)
+		VALUES (?, ?, ?, ?)
+	`, sa.UserID, NullString(sa.Description), NullString(sa.CreatedBy), sa.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert service account: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetServiceAccount retrieves a service account by its user ID.
+func (s *sqlStore) GetServiceAccount(ctx context.Context, userID string) (*ServiceAccount, error) {
+	var sa ServiceAccount
+	var description, createdBy sql.NullString
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT sa.user_id, u.name, u.role, sa.description, sa.created_by, sa.created_at
+		FROM service_accounts sa JOIN users u ON u.id = sa.user_id
+		WHERE sa.user_id = ?
+	`, userID).Scan(&sa.UserID, &sa.Name, &sa.Role, &description, &createdBy, &sa.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service account: %w", err)
+	}
+
+	sa.Description = StringPtr(description)
+	sa.CreatedBy = StringPtr(createdBy)
+
+	return &sa, nil
+}
+
+// ListServiceAccounts retrieves all service accounts.
+func (s *sqlStore) ListServiceAccounts(ctx context.Context) ([]ServiceAccount, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT sa.user_id, u.name, u.role, sa.description, sa.created_by, sa.created_at
+		FROM service_accounts sa JOIN users u ON u.id = sa.user_id
+		ORDER BY u.name ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service accounts: %w", err)
+	}
+	defer rows.Close()
+
+	var accounts []ServiceAccount
+	for rows.Next() {
+		var sa ServiceAccount
+		var description, createdBy sql.NullString
+
+		if err := rows.Scan(&sa.UserID, &sa.Name, &sa.Role, &description, &createdBy, &sa.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan service account: %w", err)
+		}
+
+		sa.Description = StringPtr(description)
+		sa.CreatedBy = StringPtr(createdBy)
+
+		accounts = append(accounts, sa)
+	}
+
+	return accounts, rows.Err()
+}
+
+// ============================================
+// Role Operations
+// ============================================
+
+// CreateRole creates a new custom role.
+func (s *sqlStore) CreateRole(ctx context.Context, role *Role) error {
+	if role.ID == "" {
+		role.ID = uuid.New().String()
+	}
+	role.CreatedAt = time.Now().UTC()
+	role.UpdatedAt = role.CreatedAt
+
+	permsJSON, err := json.Marshal(role.Permissions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal permissions: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO roles (id, name, description, permissions, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, role.ID, role.Name, NullString(role.Description), string(permsJSON), role.CreatedAt, role.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("role with this name already exists")
+		}
+		return fmt.Errorf("failed to insert role: %w", err)
+	}
+
+	return nil
+}
+
+// scanRole scans a single roles row.
+func scanRole(scan func(dest ...interface{}) error) (*Role, error) {
+	var role Role
+	var description sql.NullString
+	var permsJSON string
+
+	if err := scan(&role.ID, &role.Name, &description, &permsJSON, &role.CreatedAt, &role.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	role.Description = StringPtr(description)
+	if err := json.Unmarshal([]byte(permsJSON), &role.Permissions); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal permissions: %w", err)
+	}
+
+	return &role, nil
+}
+
+// GetRole retrieves a custom role by ID.
+func (s *sqlStore) GetRole(ctx context.Context, id string) (*Role, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT id, name, description, permissions, created_at, updated_at
+		FROM roles WHERE id = ?
+	`, id)
+	role, err := scanRole(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+	return role, nil
+}
+
+// ListRoles retrieves all custom roles.
+func (s *sqlStore) ListRoles(ctx context.Context) ([]Role, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, name, description, permissions, created_at, updated_at
+		FROM roles ORDER BY name ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []Role
+	for rows.Next() {
+		role, err := scanRole(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		roles = append(roles, *role)
+	}
+
+	return roles, rows.Err()
+}
+
+// UpdateRole updates an existing custom role.
+func (s *sqlStore) UpdateRole(ctx context.Context, role *Role) error {
+	role.UpdatedAt = time.Now().UTC()
+
+	permsJSON, err := json.Marshal(role.Permissions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal permissions: %w", err)
+	}
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE roles SET name = ?, description = ?, permissions = ?, updated_at = ?
+		WHERE id = ?
+	`, role.Name, NullString(role.Description), string(permsJSON), role.UpdatedAt, role.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("role with this name already exists")
+		}
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("role not found")
+	}
+
+	return nil
+}
+
+// DeleteRole deletes a custom role and its bindings.
+func (s *sqlStore) DeleteRole(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM roles WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("role not found")
+	}
+
+	return nil
+}
+
+// ============================================
+// Role Binding Operations
+// ============================================
+
+// CreateRoleBinding binds a custom role to a user. Binding an already bound
+// role is a no-op.
+func (s *sqlStore) CreateRoleBinding(ctx context.Context, binding *RoleBinding) error {
+	binding.CreatedAt = time.Now().UTC()
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO role_bindings (user_id, role_id, created_by, created_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id, role_id) DO NOTHING
+	`, binding.UserID, binding.RoleID, NullString(binding.CreatedBy), binding.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create role binding: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteRoleBinding removes a custom role from a user.
+func (s *sqlStore) DeleteRoleBinding(ctx context.Context, userID, roleID string) error {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM role_bindings WHERE user_id = ? AND role_id = ?
+	`, userID, roleID)
+	if err != nil {
+		return fmt.Errorf("failed to delete role binding: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("role binding not found")
+	}
+
+	return nil
+}
+
+// ListUserRoles retrieves the custom roles bound to a user.
+func (s *sqlStore) ListUserRoles(ctx context.Context, userID string) ([]Role, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT r.id, r.name, r.description, r.permissions, r.created_at, r.updated_at
+		FROM roles r JOIN role_bindings b ON b.role_id = r.id
+		WHERE b.user_id = ?
+		ORDER BY r.name ASC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []Role
+	for rows.Next() {
+		role, err := scanRole(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		roles = append(roles, *role)
+	}
+
+	return roles, rows.Err()
+}
+
+// ============================================
+// Login Throttle Operations
+// ============================================
+
+// GetLoginThrottle retrieves the failed login state for a key.
+func (s *sqlStore) GetLoginThrottle(ctx context.Context, key string) (*LoginThrottle, error) {
+	var t LoginThrottle
+	var lockedUntil sql.NullTime
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT key, failures, last_failure_at, locked_until
+		FROM login_throttles WHERE key = ?
+	`, key).Scan(&t.Key, &t.Failures, &t.LastFailureAt, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login throttle: %w", err)
+	}
+
+	t.LockedUntil = TimePtr(lockedUntil)
+
+	return &t, nil
+}
+
+// UpsertLoginThrottle creates or replaces the failed login state for a key.
+func (s *sqlStore) UpsertLoginThrottle(ctx context.Context, t *LoginThrottle) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO login_throttles (key, failures, last_failure_at, locked_until)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = excluded.failures,
+			last_failure_at = excluded.last_failure_at,
+			locked_until = excluded.locked_until
+	`, t.Key, t.Failures, t.LastFailureAt, NullTime(t.LockedUntil))
+	if err != nil {
+		return fmt.Errorf("failed to upsert login throttle: %w", err)
+	}
+	return nil
+}
+
+// DeleteLoginThrottle clears the failed login state for a key.
+func (s *sqlStore) DeleteLoginThrottle(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM login_throttles WHERE key = ?`, key)
+	if err != nil {
+		return fmt.Errorf("failed to delete login throttle: %w", err)
+	}
+	return nil
+}
+
+// ============================================
+// Audit Log Operations
+// ============================================
+
+// CreateAuditLog appends an entry to the audit hash chain. Writers are
+// serialized on the chain head, so each entry links to the one before it.
+func (s *sqlStore) CreateAuditLog(ctx context.Context, log *AuditLog) error {
+	if log.ID == "" {
+		log.ID = uuid.New().String()
+	}
+	if log.ActorType == "" {
+		if log.UserID != nil {
+			log.ActorType = ActorTypeUser
+			if log.ActorID == nil {
+				log.ActorID = log.UserID
+			}
+		} else {
+			log.ActorType = ActorTypeSystem
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Updating the head first takes the write lock before it is read
+	if _, err := tx.ExecContext(ctx, `UPDATE audit_chain SET sequence = sequence + 1 WHERE id = 1`); err != nil {
+		return fmt.Errorf("failed to advance audit chain: %w", err)
+	}
+	err = tx.QueryRowContext(ctx, `SELECT sequence, hash FROM audit_chain WHERE id = 1`).Scan(&log.Sequence, &log.PrevHash)
+	if err != nil {
+		return fmt.Errorf("failed to read audit chain: %w", err)
+	}
+
+	// Truncated to what every backend stores, so the hash can be recomputed
+	log.Timestamp = time.Now().UTC().Truncate(time.Microsecond)
+	log.Hash = AuditLogHash(log)
+
+	var detailsStr string
+	if log.Details != nil {
+		detailsStr = string(log.Details)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO audit_logs (id, timestamp, user_id, action, resource_type, resource_id, details, ip_address,
+			actor_type, actor_id, actor_spiffe_id, actor_cert_serial, sequence, prev_hash, hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		log.ID, log.Timestamp, NullString(log.UserID), log.Action,
+		log.ResourceType, NullString(log.ResourceID), detailsStr, NullString(log.IPAddress),
+		string(log.ActorType), NullString(log.ActorID), NullString(log.ActorSPIFFEID), NullString(log.ActorCertSerial),
+		log.Sequence, log.PrevHash, log.Hash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE audit_chain SET hash = ? WHERE id = 1`, log.Hash); err != nil {
+		return fmt.Errorf("failed to advance audit chain: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ListAuditLogs retrieves audit logs with optional filtering.
+func (s *sqlStore) ListAuditLogs(ctx context.Context, opts ListAuditLogsOptions) ([]AuditLog, error) {
+	query := `SELECT ` + auditLogColumns + ` FROM audit_logs WHERE 1=1`
+	args := []interface{}{}
+
+	if opts.UserID != "" {
+		query += " AND user_id = ?"
+		args = append(args, opts.UserID)
+	}
+	if opts.ActorType != "" {
+		// Entries from before actors were recorded fall back to user_id
+		query += ` AND COALESCE(actor_type, CASE WHEN user_id IS NULL THEN 'system' ELSE 'user' END) = ?`
+		args = append(args, string(opts.ActorType))
+	}
+	if opts.Action != "" {
+		query += " AND action = ?"
+		args = append(args, opts.Action)
+	}
+	if opts.ResourceType != "" {
+		query += " AND resource_type = ?"
+		args = append(args, opts.ResourceType)
+	}
+	if opts.ResourceID != "" {
+		query += " AND resource_id = ?"
+		args = append(args, opts.ResourceID)
 	}
 	if opts.Since != nil {
 		query += " AND timestamp >= ?"
@@ -1284,7 +2649,11 @@ func (s *Store) ListAuditLogs(ctx context.Context, opts ListAuditLogsOptions) ([
 		args = append(args, *opts.Until)
 	}
 
-	query += " ORDER BY timestamp DESC"
+	if opts.OldestFirst {
+		query += " ORDER BY timestamp ASC"
+	} else {
+		query += " ORDER BY timestamp DESC"
+	}
 
 	if opts.Limit > 0 {
 		query += fmt.Sprintf(" LIMIT %d", opts.Limit)
@@ -1299,15 +2668,28 @@ func (s *Store) ListAuditLogs(ctx context.Context, opts ListAuditLogsOptions) ([
 	}
 	defer rows.Close()
 
+	return scanAuditLogs(rows)
+}
+
+// auditLogColumns are the columns read by scanAuditLogs.
+const auditLogColumns = `id, timestamp, user_id, action, resource_type, resource_id, details, ip_address,
+	actor_type, actor_id, actor_spiffe_id, actor_cert_serial, sequence, prev_hash, hash`
+
+// scanAuditLogs reads audit log rows selected with auditLogColumns.
+func scanAuditLogs(rows *sql.Rows) ([]AuditLog, error) {
 	var logs []AuditLog
 	for rows.Next() {
 		var log AuditLog
-		var userID, resourceID, ipAddress sql.NullString
+		var userID, resourceID, ipAddress, prevHash, hash sql.NullString
+		var actorType, actorID, actorSPIFFEID, actorCertSerial sql.NullString
+		var sequence sql.NullInt64
 		var details string
 
 		err := rows.Scan(
 			&log.ID, &log.Timestamp, &userID, &log.Action,
 			&log.ResourceType, &resourceID, &details, &ipAddress,
+			&actorType, &actorID, &actorSPIFFEID, &actorCertSerial,
+			&sequence, &prevHash, &hash,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan audit log: %w", err)
@@ -1319,9 +2701,123 @@ func (s *Store) ListAuditLogs(ctx context.Context, opts ListAuditLogsOptions) ([
 		if details != "" {
 			log.Details = json.RawMessage(details)
 		}
+		log.ActorType = ActorType(actorType.String)
+		log.ActorID = StringPtr(actorID)
+		log.ActorSPIFFEID = StringPtr(actorSPIFFEID)
+		log.ActorCertSerial = StringPtr(actorCertSerial)
+		log.Sequence = sequence.Int64
+		log.PrevHash = prevHash.String
+		log.Hash = hash.String
 
 		logs = append(logs, log)
 	}
 
 	return logs, rows.Err()
 }
+
+// ============================================
+// Retention Operations
+// ============================================
+
+// finishedDeploymentStatuses are the deployment states that retention may delete.
+var finishedDeploymentStatuses = []interface{}{
+	DeploymentStatusCompleted, DeploymentStatusFailed, DeploymentStatusCancelled,
+}
+
+// DeleteDeploymentsBefore deletes finished deployments, and their per-instance
+// records, that completed before the given time.
+func (s *sqlStore) DeleteDeploymentsBefore(ctx context.Context, before time.Time) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	where := `status IN (?, ?, ?) AND COALESCE(completed_at, created_at) < ?`
+	args := append(append([]interface{}{}, finishedDeploymentStatuses...), before)
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM deployment_instances WHERE deployment_id IN (SELECT id FROM deployments WHERE `+where+`)
+	`, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete deployment instances: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM deployments WHERE `+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete deployments: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// DeleteAuditLogsUntil deletes audit logs written at or before the given
+// time, matching ListAuditLogsOptions.Until.
+func (s *sqlStore) DeleteAuditLogsUntil(ctx context.Context, until time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM audit_logs WHERE timestamp <= ?`, until)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete audit logs: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// CleanupExpiredAgentSessions deletes agent sessions that have expired.
+func (s *sqlStore) CleanupExpiredAgentSessions(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM agent_sessions WHERE expires_at < ?
+	`, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup expired agent sessions: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// DeleteUnusedConfigVersions deletes config versions created before the
+// given time, keeping each config's newest keep versions, its current
+// version, its latest approved version, and any version an instance runs,
+// a deployment references or an assignment pins.
+func (s *sqlStore) DeleteUnusedConfigVersions(ctx context.Context, before time.Time, keep int) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM config_versions
+		WHERE created_at < ?
+		AND NOT EXISTS (
+			SELECT 1 FROM configs c
+			WHERE c.id = config_versions.config_id AND c.current_version = config_versions.version
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM instances i
+			WHERE i.current_config_id = config_versions.config_id AND i.current_config_version = config_versions.version
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM deployments d
+			WHERE d.config_id = config_versions.config_id AND d.config_version = config_versions.version
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM config_assignments a
+			WHERE a.config_id = config_versions.config_id AND a.version = config_versions.version
+		)
+		AND NOT (
+			config_versions.approved_at IS NOT NULL AND NOT EXISTS (
+				SELECT 1 FROM config_versions later
+				WHERE later.config_id = config_versions.config_id AND later.version > config_versions.version
+				AND later.approved_at IS NOT NULL
+			)
+		)
+		AND (
+			SELECT COUNT(*) FROM config_versions newer
+			WHERE newer.config_id = config_versions.config_id AND newer.version > config_versions.version
+		) >= ?
+	`, before, keep)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete config versions: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ============================================
+// Variable Operations
+// ============================================
+
+// SetVariable creates or replaces a template variable. Variables without an
+// instance ID are fleet-wide.
+func (s *sqlStore) SetVariable(ctx context.Context, v *Variable) error {
+	v.UpdatedAt = time.Now().UTC()
+
+	var err error
+	if v.InstanceID == "" {
+		_, err = s.db.ExecContext(ctx, `
+			INSERT INTO config_variables (name, value, updated_by, updated_at)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT (name) DO UPDATE SET
+				value = excluded.value,
+				updated_by = excluded.updated_by,
+				updated_at = excluded.updated_at
+		`, v.Name, v.Value, NullString(v.UpdatedBy), v.UpdatedAt)
+	} else {
+		_, err = s.db.ExecContext(ctx, `
+			INSERT INTO instance_variables (instance_id, name, value, updated_by, updated_at)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (instance_id, name) DO UPDATE SET
+				value = excluded.value,
+				updated_by = excluded.updated_by,
+				updated_at = excluded.updated_at
+		`, v.InstanceID, v.Name, v.Value, NullString(v.UpdatedBy), v.UpdatedAt)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set variable: %w", err)
+	}
+
+	return nil
+}
+
+// ListVariables retrieves the variables of an instance, or the fleet-wide
+// variables when instanceID is empty, ordered by name.
+func (s *sqlStore) ListVariables(ctx context.Context, instanceID string) ([]Variable, error) {
+	var rows *sql.Rows
+	var err error
+	if instanceID == "" {
+		rows, err = s.db.QueryContext(ctx, `
+			SELECT '', name, value, updated_by, updated_at FROM config_variables ORDER BY name ASC
+		`)
+	} else {
+		rows, err = s.db.QueryContext(ctx, `
+			SELECT instance_id, name, value, updated_by, updated_at FROM instance_variables
+			WHERE instance_id = ? ORDER BY name ASC
+		`, instanceID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list variables: %w", err)
+	}
+	defer rows.Close()
+
+	var vars []Variable
+	for rows.Next() {
+		var v Variable
+		var updatedBy sql.NullString
+		if err := rows.Scan(&v.InstanceID, &v.Name, &v.Value, &updatedBy, &v.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan variable: %w", err)
+		}
+		v.UpdatedBy = StringPtr(updatedBy)
+		vars = append(vars, v)
+	}
+
+	return vars, rows.Err()
+}
+
+// DeleteVariable deletes a variable of an instance, or a fleet-wide variable
+// when instanceID is empty.
+func (s *sqlStore) DeleteVariable(ctx context.Context, instanceID, name string) error {
+	var result sql.Result
+	var err error
+	if instanceID == "" {
+		result, err = s.db.ExecContext(ctx, `DELETE FROM config_variables WHERE name = ?`, name)
+	} else {
+		result, err = s.db.ExecContext(ctx, `DELETE FROM instance_variables WHERE instance_id = ? AND name = ?`, instanceID, name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete variable: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("variable not found")
+	}
+
+	return nil
+}
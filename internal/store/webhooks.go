@@ -0,0 +1,294 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ============================================
+// Webhook Operations
+// ============================================
+
+// webhookColumns are the columns read by scanWebhook.
+const webhookColumns = `id, name, url, secret, event_types, enabled, created_by, created_at, updated_at`
+
+// scanWebhook scans a single webhooks row.
+func scanWebhook(scan func(dest ...interface{}) error) (*Webhook, error) {
+	var wh Webhook
+	var createdBy sql.NullString
+	var eventTypesJSON string
+
+	err := scan(&wh.ID, &wh.Name, &wh.URL, &wh.Secret, &eventTypesJSON, &wh.Enabled,
+		&createdBy, &wh.CreatedAt, &wh.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	wh.CreatedBy = StringPtr(createdBy)
+	if err := json.Unmarshal([]byte(eventTypesJSON), &wh.EventTypes); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event types: %w", err)
+	}
+	return &wh, nil
+}
+
+// CreateWebhook creates a new webhook subscription.
+func (s *sqlStore) CreateWebhook(ctx context.Context, wh *Webhook) error {
+	if wh.ID == "" {
+		wh.ID = uuid.New().String()
+	}
+	if wh.EventTypes == nil {
+		wh.EventTypes = []string{}
+	}
+	wh.CreatedAt = time.Now().UTC()
+	wh.UpdatedAt = wh.CreatedAt
+
+	eventTypesJSON, err := json.Marshal(wh.EventTypes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event types: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO webhooks (id, name, url, secret, event_types, enabled, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, wh.ID, wh.Name, wh.URL, wh.Secret, string(eventTypesJSON), wh.Enabled,
+		NullString(wh.CreatedBy), wh.CreatedAt, wh.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook: %w", err)
+	}
+
+	return nil
+}
+
+// GetWebhook retrieves a webhook by ID.
+func (s *sqlStore) GetWebhook(ctx context.Context, id string) (*Webhook, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = ?`, id)
+	wh, err := scanWebhook(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return wh, nil
+}
+
+// ListWebhooks retrieves all webhooks.
+func (s *sqlStore) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+webhookColumns+` FROM webhooks ORDER BY name ASC, created_at ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var webhooks []Webhook
+	for rows.Next() {
+		wh, err := scanWebhook(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, *wh)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// UpdateWebhook updates a webhook's name, URL, secret, event types and
+// enabled flag.
+func (s *sqlStore) UpdateWebhook(ctx context.Context, wh *Webhook) error {
+	if wh.EventTypes == nil {
+		wh.EventTypes = []string{}
+	}
+	wh.UpdatedAt = time.Now().UTC()
+
+	eventTypesJSON, err := json.Marshal(wh.EventTypes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event types: %w", err)
+	}
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE webhooks SET name = ?, url = ?, secret = ?, event_types = ?, enabled = ?, updated_at = ?
+		WHERE id = ?
+	`, wh.Name, wh.URL, wh.Secret, string(eventTypesJSON), wh.Enabled, wh.UpdatedAt, wh.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("webhook not found")
+	}
+
+	return nil
+}
+
+// DeleteWebhook deletes a webhook and its delivery log.
+func (s *sqlStore) DeleteWebhook(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("webhook not found")
+	}
+
+	return nil
+}
+
+// ============================================
+// Webhook Delivery Operations
+// ============================================
+
+// webhookDeliveryColumns are the columns read by scanWebhookDelivery.
+const webhookDeliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts,
+	response_code, response_body, error, redelivery_of, next_attempt_at, created_at, completed_at`
+
+// scanWebhookDelivery scans a single webhook_deliveries row.
+func scanWebhookDelivery(scan func(dest ...interface{}) error) (*WebhookDelivery, error) {
+	var d WebhookDelivery
+	var payload string
+	var responseCode sql.NullInt64
+	var responseBody, errMsg, redeliveryOf sql.NullString
+	var nextAttemptAt, completedAt sql.NullTime
+
+	err := scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts,
+		&responseCode, &responseBody, &errMsg, &redeliveryOf, &nextAttemptAt, &d.CreatedAt, &completedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Payload = json.RawMessage(payload)
+	d.ResponseCode = IntPtr(responseCode)
+	d.ResponseBody = StringPtr(responseBody)
+	d.Error = StringPtr(errMsg)
+	d.RedeliveryOf = StringPtr(redeliveryOf)
+	d.NextAttemptAt = TimePtr(nextAttemptAt)
+	d.CompletedAt = TimePtr(completedAt)
+	return &d, nil
+}
+
+// CreateWebhookDelivery queues a delivery. A delivery without a status is
+// pending, and due immediately unless NextAttemptAt is set.
+func (s *sqlStore) CreateWebhookDelivery(ctx context.Context, d *WebhookDelivery) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	d.CreatedAt = time.Now().UTC()
+	if d.Status == "" {
+		d.Status = WebhookDeliveryPending
+		if d.NextAttemptAt == nil {
+			d.NextAttemptAt = &d.CreatedAt
+		}
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (id, webhook_id, event_id, event_type, payload, status, attempts,
+			response_code, response_body, error, redelivery_of, next_attempt_at, created_at, completed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, d.ID, d.WebhookID, d.EventID, d.EventType, string(d.Payload), d.Status, d.Attempts,
+		NullInt(d.ResponseCode), NullString(d.ResponseBody), NullString(d.Error), NullString(d.RedeliveryOf),
+		NullTime(d.NextAttemptAt), d.CreatedAt, NullTime(d.CompletedAt))
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+// GetWebhookDelivery retrieves a delivery by ID.
+func (s *sqlStore) GetWebhookDelivery(ctx context.Context, id string) (*WebhookDelivery, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries WHERE id = ?`, id)
+	d, err := scanWebhookDelivery(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+	return d, nil
+}
+
+// ListWebhookDeliveries returns a webhook's deliveries, newest first.
+func (s *sqlStore) ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]WebhookDelivery, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries
+		WHERE webhook_id = ?
+		ORDER BY created_at DESC
+		LIMIT ?
+	`, webhookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	return scanWebhookDeliveries(rows)
+}
+
+// ListDueWebhookDeliveries returns pending deliveries whose next attempt is
+// due at now, oldest first.
+func (s *sqlStore) ListDueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]WebhookDelivery, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at ASC
+		LIMIT ?
+	`, WebhookDeliveryPending, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	return scanWebhookDeliveries(rows)
+}
+
+// scanWebhookDeliveries reads webhook delivery rows.
+func scanWebhookDeliveries(rows *sql.Rows) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, *d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// UpdateWebhookDelivery records the outcome of a delivery attempt.
+func (s *sqlStore) UpdateWebhookDelivery(ctx context.Context, d *WebhookDelivery) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, response_code = ?, response_body = ?, error = ?,
+			next_attempt_at = ?, completed_at = ?
+		WHERE id = ?
+	`, d.Status, d.Attempts, NullInt(d.ResponseCode), NullString(d.ResponseBody), NullString(d.Error),
+		NullTime(d.NextAttemptAt), NullTime(d.CompletedAt), d.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("webhook delivery not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,371 @@
+// Package webhook delivers deployment and fleet events to admin-managed
+// HTTP endpoints. Deliveries are queued in the database, signed with the
+// webhook's secret and retried with exponential backoff.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/raskell-io/sentinel-hub/internal/events"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// Headers sent with every delivery.
+const (
+	EventHeader     = "X-Sentinel-Hub-Event"
+	DeliveryHeader  = "X-Sentinel-Hub-Delivery"
+	TimestampHeader = "X-Sentinel-Hub-Timestamp"
+	SignatureHeader = "X-Sentinel-Hub-Signature-256"
+)
+
+// maxResponseBody is how much of a response body is kept in the delivery log.
+const maxResponseBody = 1024
+
+// maxRetryBackoff caps the delay between delivery attempts.
+const maxRetryBackoff = time.Hour
+
+// ErrDeliveryNotFound is returned when redelivering an unknown delivery.
+var ErrDeliveryNotFound = errors.New("webhook delivery not found")
+
+// Config controls delivery.
+type Config struct {
+	// MaxAttempts is how often a delivery is tried before it is failed.
+	MaxAttempts int
+	// RetryBackoff is the delay before the first retry; it doubles with
+	// each further attempt.
+	RetryBackoff time.Duration
+	// PollInterval is how often due deliveries are looked for when no new
+	// event wakes the dispatcher.
+	PollInterval time.Duration
+	// Timeout bounds each HTTP request.
+	Timeout time.Duration
+}
+
+// DefaultConfig returns the default delivery settings: six attempts over
+// roughly fifteen minutes.
+func DefaultConfig() Config {
+	return Config{
+		MaxAttempts:  6,
+		RetryBackoff: 30 * time.Second,
+		PollInterval: 5 * time.Second,
+		Timeout:      10 * time.Second,
+	}
+}
+
+// withDefaults fills unset fields with their defaults.
+func (c Config) withDefaults() Config {
+	d := DefaultConfig()
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = d.MaxAttempts
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = d.RetryBackoff
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = d.PollInterval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = d.Timeout
+	}
+	return c
+}
+
+// Dispatcher queues events for matching webhooks and delivers them.
+type Dispatcher struct {
+	store  store.Store
+	config Config
+	client *http.Client
+	wake   chan struct{}
+}
+
+// NewDispatcher creates a dispatcher. Events are queued by Handle and sent
+// by Run.
+func NewDispatcher(s store.Store, config Config) *Dispatcher {
+	config = config.withDefaults()
+	return &Dispatcher{
+		store:  s,
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Sign returns the signature header value for a delivery: the hex
+// HMAC-SHA256 of the timestamp, a dot and the body, keyed with the
+// webhook's secret. Receivers should recompute it and reject stale
+// timestamps.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateSecret returns a random signing secret.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Matches reports whether a webhook receives events of type t. Progress
+// events are never sent to webhooks.
+func Matches(wh *store.Webhook, t events.Type) bool {
+	if !wh.Enabled || !t.Valid() {
+		return false
+	}
+	if len(wh.EventTypes) == 0 {
+		return true
+	}
+	for _, et := range wh.EventTypes {
+		if et == string(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle queues an event for every enabled webhook subscribed to its type.
+// It implements events.Handler.
+func (d *Dispatcher) Handle(ctx context.Context, e events.Event) {
+	// The event already happened; queue it even if the caller gives up
+	ctx = context.WithoutCancel(ctx)
+
+	webhooks, err := d.store.ListWebhooks(ctx)
+	if err != nil {
+		log.Error().Err(err).Str("event_type", string(e.Type)).Msg("Failed to list webhooks")
+		return
+	}
+
+	var payload []byte
+	queued := 0
+	for i := range webhooks {
+		wh := &webhooks[i]
+		if !Matches(wh, e.Type) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = json.Marshal(e); err != nil {
+				log.Error().Err(err).Str("event_type", string(e.Type)).Msg("Failed to encode webhook event")
+				return
+			}
+		}
+
+		delivery := &store.WebhookDelivery{
+			WebhookID: wh.ID,
+			EventID:   e.ID,
+			EventType: string(e.Type),
+			Payload:   payload,
+		}
+		if err := d.store.CreateWebhookDelivery(ctx, delivery); err != nil {
+			log.Error().Err(err).
+				Str("webhook_id", wh.ID).
+				Str("event_type", string(e.Type)).
+				Msg("Failed to queue webhook delivery")
+			continue
+		}
+		queued++
+	}
+
+	if queued > 0 {
+		d.notify()
+	}
+}
+
+// Redeliver queues a new delivery of an earlier delivery's payload.
+func (d *Dispatcher) Redeliver(ctx context.Context, deliveryID string) (*store.WebhookDelivery, error) {
+	original, err := d.store.GetWebhookDelivery(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if original == nil {
+		return nil, ErrDeliveryNotFound
+	}
+
+	delivery := &store.WebhookDelivery{
+		WebhookID:    original.WebhookID,
+		EventID:      original.EventID,
+		EventType:    original.EventType,
+		Payload:      original.Payload,
+		RedeliveryOf: &original.ID,
+	}
+	if err := d.store.CreateWebhookDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+
+	d.notify()
+	return delivery, nil
+}
+
+// notify wakes Run without blocking.
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers due deliveries until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.deliverDue(ctx); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("Failed to deliver webhooks")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// deliverDue attempts every delivery that is due.
+func (d *Dispatcher) deliverDue(ctx context.Context) error {
+	for {
+		due, err := d.store.ListDueWebhookDeliveries(ctx, time.Now().UTC(), 50)
+		if err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+
+		webhooks := make(map[string]*store.Webhook)
+		for i := range due {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			delivery := &due[i]
+			wh, ok := webhooks[delivery.WebhookID]
+			if !ok {
+				if wh, err = d.store.GetWebhook(ctx, delivery.WebhookID); err != nil {
+					return err
+				}
+				webhooks[delivery.WebhookID] = wh
+			}
+
+			d.attempt(ctx, wh, delivery)
+			if err := d.store.UpdateWebhookDelivery(ctx, delivery); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// attempt sends a delivery once and records the outcome on it.
+func (d *Dispatcher) attempt(ctx context.Context, wh *store.Webhook, delivery *store.WebhookDelivery) {
+	now := time.Now().UTC()
+	delivery.Attempts++
+	delivery.ResponseCode = nil
+	delivery.ResponseBody = nil
+	delivery.Error = nil
+
+	var err error
+	switch {
+	case wh == nil:
+		err = errors.New("webhook deleted")
+		delivery.Attempts = d.config.MaxAttempts
+	case !wh.Enabled:
+		err = errors.New("webhook disabled")
+		delivery.Attempts = d.config.MaxAttempts
+	default:
+		err = d.post(ctx, wh, delivery)
+	}
+
+	if err == nil {
+		delivery.Status = store.WebhookDeliverySucceeded
+		delivery.NextAttemptAt = nil
+		delivery.CompletedAt = &now
+		attemptsTotal.WithLabelValues("success").Inc()
+		deliveriesTotal.WithLabelValues(string(store.WebhookDeliverySucceeded)).Inc()
+		return
+	}
+
+	msg := err.Error()
+	delivery.Error = &msg
+	attemptsTotal.WithLabelValues("error").Inc()
+	log.Warn().Err(err).
+		Str("webhook_id", delivery.WebhookID).
+		Str("delivery_id", delivery.ID).
+		Int("attempt", delivery.Attempts).
+		Msg("Webhook delivery failed")
+
+	if delivery.Attempts >= d.config.MaxAttempts {
+		delivery.Status = store.WebhookDeliveryFailed
+		delivery.NextAttemptAt = nil
+		delivery.CompletedAt = &now
+		deliveriesTotal.WithLabelValues(string(store.WebhookDeliveryFailed)).Inc()
+		return
+	}
+
+	next := now.Add(d.backoff(delivery.Attempts))
+	delivery.NextAttemptAt = &next
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	backoff := d.config.RetryBackoff
+	for i := 1; i < attempts && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxRetryBackoff)
+}
+
+// post sends the signed payload and records the response on the delivery.
+func (d *Dispatcher) post(ctx context.Context, wh *store.Webhook, delivery *store.WebhookDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "sentinel-hub")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(wh.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	code := resp.StatusCode
+	delivery.ResponseCode = &code
+	if len(body) > 0 {
+		text := string(body)
+		delivery.ResponseBody = &text
+	}
+
+	if code < 200 || code > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
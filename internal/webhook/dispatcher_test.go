@@ -0,0 +1,283 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/raskell-io/sentinel-hub/internal/events"
+	"github.com/raskell-io/sentinel-hub/internal/store"
+)
+
+const testSecret = "0123456789abcdef"
+
+// receiver records deliveries and answers with the next queued status code,
+// or 200 once the queue is empty.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+	w.Write([]byte("ok"))
+}
+
+func (rc *receiver) count() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.requests)
+}
+
+func setupTestDispatcher(t *testing.T, rc *receiver, eventTypes ...string) (*Dispatcher, store.Store, *store.Webhook) {
+	t.Helper()
+
+	s, err := store.New(filepath.Join(t.TempDir(), "hub.db"))
+	if err != nil {
+		t.Fatalf("failed to create test store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+
+	wh := &store.Webhook{Name: "test", URL: srv.URL, Secret: testSecret, EventTypes: eventTypes, Enabled: true}
+	if err := s.CreateWebhook(context.Background(), wh); err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+
+	d := NewDispatcher(s, Config{MaxAttempts: 3, RetryBackoff: time.Nanosecond})
+	return d, s, wh
+}
+
+func publish(d *Dispatcher, t events.Type, data interface{}) {
+	bus := events.NewBus()
+	bus.Subscribe(d.Handle)
+	bus.Publish(context.Background(), t, data)
+}
+
+func TestDispatcher_DeliversSignedPayload(t *testing.T) {
+	ctx := context.Background()
+	rc := &receiver{}
+	d, s, wh := setupTestDispatcher(t, rc)
+
+	publish(d, events.DeploymentCompleted, events.DeploymentData{DeploymentID: "dep-1", Status: "completed"})
+	if err := d.deliverDue(ctx); err != nil {
+		t.Fatalf("deliverDue failed: %v", err)
+	}
+
+	if rc.count() != 1 {
+		t.Fatalf("expected 1 request, got %d", rc.count())
+	}
+	req, body := rc.requests[0], rc.bodies[0]
+
+	if got := req.Header.Get(EventHeader); got != string(events.DeploymentCompleted) {
+		t.Errorf("expected event header %q, got %q", events.DeploymentCompleted, got)
+	}
+	ts, err := strconv.ParseInt(req.Header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		t.Fatalf("invalid timestamp header: %v", err)
+	}
+	if got, want := req.Header.Get(SignatureHeader), Sign(testSecret, ts, body); got != want {
+		t.Errorf("expected signature %q, got %q", want, got)
+	}
+
+	var e events.Event
+	if err := json.Unmarshal(body, &e); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if e.Type != events.DeploymentCompleted || e.ID == "" {
+		t.Errorf("unexpected event: %+v", e)
+	}
+
+	deliveries, err := s.ListWebhookDeliveries(ctx, wh.ID, 10)
+	if err != nil {
+		t.Fatalf("ListWebhookDeliveries failed: %v", err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("expected 1 delivery, got %d", len(deliveries))
+	}
+	got := deliveries[0]
+	if got.Status != store.WebhookDeliverySucceeded || got.Attempts != 1 {
+		t.Errorf("expected succeeded after 1 attempt, got %s after %d", got.Status, got.Attempts)
+	}
+	if got.ResponseCode == nil || *got.ResponseCode != http.StatusOK {
+		t.Errorf("expected response code 200, got %v", got.ResponseCode)
+	}
+	if req.Header.Get(DeliveryHeader) != got.ID {
+		t.Errorf("expected delivery header %q, got %q", got.ID, req.Header.Get(DeliveryHeader))
+	}
+}
+
+func TestDispatcher_RetriesUntilSuccess(t *testing.T) {
+	ctx := context.Background()
+	rc := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusBadGateway}}
+	d, s, wh := setupTestDispatcher(t, rc)
+
+	publish(d, events.InstanceOffline, events.InstanceData{InstanceID: "inst-1", Status: "offline"})
+	for i := 0; i < 3; i++ {
+		time.Sleep(time.Millisecond)
+		if err := d.deliverDue(ctx); err != nil {
+			t.Fatalf("deliverDue failed: %v", err)
+		}
+	}
+
+	if rc.count() != 3 {
+		t.Fatalf("expected 3 requests, got %d", rc.count())
+	}
+	deliveries, _ := s.ListWebhookDeliveries(ctx, wh.ID, 10)
+	if len(deliveries) != 1 || deliveries[0].Status != store.WebhookDeliverySucceeded || deliveries[0].Attempts != 3 {
+		t.Errorf("expected one delivery succeeded after 3 attempts, got %+v", deliveries)
+	}
+}
+
+func TestDispatcher_FailsAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	rc := &receiver{statuses: []int{500, 500, 500, 500}}
+	d, s, wh := setupTestDispatcher(t, rc)
+
+	publish(d, events.DeploymentFailed, events.DeploymentData{DeploymentID: "dep-1"})
+	for i := 0; i < 5; i++ {
+		time.Sleep(time.Millisecond)
+		if err := d.deliverDue(ctx); err != nil {
+			t.Fatalf("deliverDue failed: %v", err)
+		}
+	}
+
+	if rc.count() != 3 {
+		t.Errorf("expected 3 requests, got %d", rc.count())
+	}
+	deliveries, _ := s.ListWebhookDeliveries(ctx, wh.ID, 10)
+	if len(deliveries) != 1 {
+		t.Fatalf("expected 1 delivery, got %d", len(deliveries))
+	}
+	got := deliveries[0]
+	if got.Status != store.WebhookDeliveryFailed || got.Attempts != 3 {
+		t.Errorf("expected failed after 3 attempts, got %s after %d", got.Status, got.Attempts)
+	}
+	if got.ResponseCode == nil || *got.ResponseCode != 500 || got.Error == nil {
+		t.Errorf("expected response code and error to be recorded, got %+v", got)
+	}
+}
+
+func TestDispatcher_FiltersEventTypes(t *testing.T) {
+	ctx := context.Background()
+	rc := &receiver{}
+	d, s, wh := setupTestDispatcher(t, rc, string(events.DeploymentFailed))
+
+	publish(d, events.DeploymentCompleted, events.DeploymentData{DeploymentID: "dep-1"})
+	publish(d, events.DeploymentFailed, events.DeploymentData{DeploymentID: "dep-2"})
+
+	deliveries, err := s.ListWebhookDeliveries(ctx, wh.ID, 10)
+	if err != nil {
+		t.Fatalf("ListWebhookDeliveries failed: %v", err)
+	}
+	if len(deliveries) != 1 || deliveries[0].EventType != string(events.DeploymentFailed) {
+		t.Errorf("expected only the failed event to be queued, got %+v", deliveries)
+	}
+
+	wh.Enabled = false
+	if err := s.UpdateWebhook(ctx, wh); err != nil {
+		t.Fatalf("UpdateWebhook failed: %v", err)
+	}
+	publish(d, events.DeploymentFailed, events.DeploymentData{DeploymentID: "dep-3"})
+	if deliveries, _ := s.ListWebhookDeliveries(ctx, wh.ID, 10); len(deliveries) != 1 {
+		t.Errorf("expected disabled webhook to receive nothing, got %d deliveries", len(deliveries))
+	}
+}
+
+func TestDispatcher_SkipsProgressEvents(t *testing.T) {
+	ctx := context.Background()
+	rc := &receiver{}
+	d, s, wh := setupTestDispatcher(t, rc)
+
+	publish(d, events.DeploymentProgress, events.DeploymentProgressData{DeploymentID: "dep-1"})
+	publish(d, events.DeploymentInstanceStatus, events.DeploymentInstanceData{DeploymentID: "dep-1", InstanceID: "inst-1"})
+
+	if deliveries, _ := s.ListWebhookDeliveries(ctx, wh.ID, 10); len(deliveries) != 0 {
+		t.Errorf("expected progress events to be skipped, got %d deliveries", len(deliveries))
+	}
+}
+
+func TestDispatcher_Redeliver(t *testing.T) {
+	ctx := context.Background()
+	rc := &receiver{statuses: []int{500, 500, 500}}
+	d, s, wh := setupTestDispatcher(t, rc)
+
+	publish(d, events.DeploymentStarted, events.DeploymentData{DeploymentID: "dep-1"})
+	for i := 0; i < 3; i++ {
+		time.Sleep(time.Millisecond)
+		d.deliverDue(ctx)
+	}
+
+	deliveries, _ := s.ListWebhookDeliveries(ctx, wh.ID, 10)
+	if len(deliveries) != 1 || deliveries[0].Status != store.WebhookDeliveryFailed {
+		t.Fatalf("expected one failed delivery, got %+v", deliveries)
+	}
+	original := deliveries[0]
+
+	if _, err := d.Redeliver(ctx, "missing"); err != ErrDeliveryNotFound {
+		t.Errorf("expected ErrDeliveryNotFound, got %v", err)
+	}
+
+	redelivery, err := d.Redeliver(ctx, original.ID)
+	if err != nil {
+		t.Fatalf("Redeliver failed: %v", err)
+	}
+	if err := d.deliverDue(ctx); err != nil {
+		t.Fatalf("deliverDue failed: %v", err)
+	}
+
+	got, err := s.GetWebhookDelivery(ctx, redelivery.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetWebhookDelivery failed: %v", err)
+	}
+	if got.Status != store.WebhookDeliverySucceeded {
+		t.Errorf("expected redelivery to succeed, got %s", got.Status)
+	}
+	if got.RedeliveryOf == nil || *got.RedeliveryOf != original.ID {
+		t.Errorf("expected redelivery_of %q, got %v", original.ID, got.RedeliveryOf)
+	}
+	if string(rc.bodies[len(rc.bodies)-1]) != string(original.Payload) {
+		t.Error("expected redelivery to send the original payload")
+	}
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	d := NewDispatcher(nil, Config{RetryBackoff: time.Minute})
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		{20, maxRetryBackoff},
+	}
+	for _, tt := range tests {
+		if got := d.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
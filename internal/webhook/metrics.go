@@ -0,0 +1,18 @@
+package webhook
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	attemptsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hub_webhook_delivery_attempts_total",
+		Help: "Webhook delivery attempts, by result (success or error).",
+	}, []string{"result"})
+
+	deliveriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hub_webhook_deliveries_total",
+		Help: "Webhook deliveries that finished, by final status.",
+	}, []string{"status"})
+)
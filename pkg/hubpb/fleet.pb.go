@@ -250,6 +250,8 @@ const (
 	ActionType_ACTION_TYPE_APPLY_CONFIG  ActionType = 2
 	ActionType_ACTION_TYPE_REPORT_STATUS ActionType = 3
 	ActionType_ACTION_TYPE_DRAIN         ActionType = 4
+	// Send the running config's content with the next heartbeat.
+	ActionType_ACTION_TYPE_REPORT_CONFIG ActionType = 5
 )
 
 // Enum value maps for ActionType.
@@ -260,6 +262,7 @@ var (
 		2: "ACTION_TYPE_APPLY_CONFIG",
 		3: "ACTION_TYPE_REPORT_STATUS",
 		4: "ACTION_TYPE_DRAIN",
+		5: "ACTION_TYPE_REPORT_CONFIG",
 	}
 	ActionType_value = map[string]int32{
 		"ACTION_TYPE_UNKNOWN":       0,
@@ -267,6 +270,7 @@ var (
 		"ACTION_TYPE_APPLY_CONFIG":  2,
 		"ACTION_TYPE_REPORT_STATUS": 3,
 		"ACTION_TYPE_DRAIN":         4,
+		"ACTION_TYPE_REPORT_CONFIG": 5,
 	}
 )
 
@@ -476,6 +480,8 @@ type HeartbeatRequest struct {
 	CurrentConfigVersion string                 `protobuf:"bytes,4,opt,name=current_config_version,json=currentConfigVersion,proto3" json:"current_config_version,omitempty"`
 	CurrentConfigHash    string                 `protobuf:"bytes,5,opt,name=current_config_hash,json=currentConfigHash,proto3" json:"current_config_hash,omitempty"`
 	Metrics              *InstanceMetrics       `protobuf:"bytes,6,opt,name=metrics,proto3" json:"metrics,omitempty"`
+	// Content of the running config, only sent after ACTION_TYPE_REPORT_CONFIG.
+	CurrentConfigContent string `protobuf:"bytes,7,opt,name=current_config_content,json=currentConfigContent,proto3" json:"current_config_content,omitempty"`
 	unknownFields        protoimpl.UnknownFields
 	sizeCache            protoimpl.SizeCache
 }
@@ -552,6 +558,13 @@ func (x *HeartbeatRequest) GetMetrics() *InstanceMetrics {
 	return nil
 }
 
+func (x *HeartbeatRequest) GetCurrentConfigContent() string {
+	if x != nil {
+		return x.CurrentConfigContent
+	}
+	return ""
+}
+
 type HeartbeatResponse struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Hint to fetch new config.
@@ -1959,7 +1972,7 @@ const file_fleet_proto_rawDesc = "" +
 	"\x0econfig_version\x18\x02 \x01(\tR\rconfigVersion\x12\x1f\n" +
 	"\vconfig_hash\x18\x03 \x01(\tR\n" +
 	"configHash\x12<\n" +
-	"\x1aheartbeat_interval_seconds\x18\x04 \x01(\x05R\x18heartbeatIntervalSeconds\"\xa4\x02\n" +
+	"\x1aheartbeat_interval_seconds\x18\x04 \x01(\x05R\x18heartbeatIntervalSeconds\"\xda\x02\n" +
 	"\x10HeartbeatRequest\x12\x1f\n" +
 	"\vinstance_id\x18\x01 \x01(\tR\n" +
 	"instanceId\x12\x14\n" +
@@ -1967,7 +1980,8 @@ const file_fleet_proto_rawDesc = "" +
 	"\x06status\x18\x03 \x01(\v2\x1f.sentinel.hub.v1.InstanceStatusR\x06status\x124\n" +
 	"\x16current_config_version\x18\x04 \x01(\tR\x14currentConfigVersion\x12.\n" +
 	"\x13current_config_hash\x18\x05 \x01(\tR\x11currentConfigHash\x12:\n" +
-	"\ametrics\x18\x06 \x01(\v2 .sentinel.hub.v1.InstanceMetricsR\ametrics\"\xb9\x01\n" +
+	"\ametrics\x18\x06 \x01(\v2 .sentinel.hub.v1.InstanceMetricsR\ametrics\x124\n" +
+	"\x16current_config_content\x18\a \x01(\tR\x14currentConfigContent\"\xb9\x01\n" +
 	"\x11HeartbeatResponse\x126\n" +
 	"\x17config_update_available\x18\x01 \x01(\bR\x15configUpdateAvailable\x122\n" +
 	"\x15latest_config_version\x18\x02 \x01(\tR\x13latestConfigVersion\x128\n" +
@@ -2107,14 +2121,15 @@ const file_fleet_proto_rawDesc = "" +
 	"\x1bDEPLOYMENT_STATE_VALIDATING\x10\x03\x12\x1e\n" +
 	"\x1aDEPLOYMENT_STATE_COMPLETED\x10\x04\x12\x1b\n" +
 	"\x17DEPLOYMENT_STATE_FAILED\x10\x05\x12 \n" +
-	"\x1cDEPLOYMENT_STATE_ROLLED_BACK\x10\x06*\x97\x01\n" +
+	"\x1cDEPLOYMENT_STATE_ROLLED_BACK\x10\x06*\xb6\x01\n" +
 	"\n" +
 	"ActionType\x12\x17\n" +
 	"\x13ACTION_TYPE_UNKNOWN\x10\x00\x12\x1c\n" +
 	"\x18ACTION_TYPE_FETCH_CONFIG\x10\x01\x12\x1c\n" +
 	"\x18ACTION_TYPE_APPLY_CONFIG\x10\x02\x12\x1d\n" +
 	"\x19ACTION_TYPE_REPORT_STATUS\x10\x03\x12\x15\n" +
-	"\x11ACTION_TYPE_DRAIN\x10\x042\xe0\x05\n" +
+	"\x11ACTION_TYPE_DRAIN\x10\x04\x12\x1d\n" +
+	"\x19ACTION_TYPE_REPORT_CONFIG\x10\x052\xe0\x05\n" +
 	"\fFleetService\x12O\n" +
 	"\bRegister\x12 .sentinel.hub.v1.RegisterRequest\x1a!.sentinel.hub.v1.RegisterResponse\x12R\n" +
 	"\tHeartbeat\x12!.sentinel.hub.v1.HeartbeatRequest\x1a\".sentinel.hub.v1.HeartbeatResponse\x12U\n" +